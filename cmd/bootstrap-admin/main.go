@@ -0,0 +1,50 @@
+// Command bootstrap-admin grants the platform_admin role to the first platform operator, who then grants every
+// other operator through POST /api/v1/platform/grant-operator. It refuses once a platform_admin exists.
+//
+//	go run ./cmd/bootstrap-admin -email ops@troo.earth
+//
+// The user must already have signed up. It reads the same environment as the API (.env, NODE_ENV, DATABASE_URL_*).
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	platformsvc "troo-backend/internal/application/platform"
+	"troo-backend/internal/config"
+	"troo-backend/internal/infrastructure/database"
+)
+
+func main() {
+	email := flag.String("email", "", "email of the user to make platform_admin")
+	flag.Parse()
+	if *email == "" {
+		fmt.Fprintln(os.Stderr, "usage: bootstrap-admin -email <email>")
+		os.Exit(2)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		fail("config load: " + err.Error())
+	}
+	if cfg.DatabaseURL == "" {
+		fail("no database configured (DATABASE_URL_DEV / DATABASE_URL_PROD / DATABASE_URL_TEST)")
+	}
+	db, err := database.Open(cfg.DatabaseURL)
+	if err != nil {
+		fail("database open: " + err.Error())
+	}
+
+	op, err := (&platformsvc.Service{DB: db}).BootstrapAdmin(context.Background(), *email)
+	if err != nil {
+		fail(err.Error())
+	}
+	fmt.Printf("%s (%s) is now platform_admin\n", op.Email, op.UserID)
+}
+
+func fail(msg string) {
+	fmt.Fprintln(os.Stderr, msg)
+	os.Exit(1)
+}
@@ -0,0 +1,235 @@
+package apikeys
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"troo-backend/internal/application/memberships"
+	"troo-backend/internal/domain"
+	"troo-backend/internal/pkg/constants"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// keyPrefix marks troo API keys so they are recognisable in logs and by secret scanners.
+const keyPrefix = "troo_"
+
+// lastUsedInterval throttles last_used_at writes for keys that are called in bursts.
+const lastUsedInterval = time.Minute
+
+// ErrInvalidAPIKey is returned for every key that cannot be used; see domain.ErrInvalidAPIKey.
+var ErrInvalidAPIKey = domain.ErrInvalidAPIKey
+
+// Service manages org API keys and resolves bearer tokens to the key and the member it acts as.
+type Service struct {
+	DB *gorm.DB
+}
+
+type CreateInput struct {
+	OrgID       uuid.UUID
+	ActorUserID uuid.UUID
+	Name        string
+	Permissions []string
+	ExpiresAt   *time.Time
+}
+
+// KeyView is an API key as listed; the secret itself is never shown again after creation.
+type KeyView struct {
+	KeyID       uuid.UUID  `json:"key_id"`
+	Name        string     `json:"name"`
+	Prefix      string     `json:"prefix"`
+	Permissions []string   `json:"permissions"`
+	CreatedBy   uuid.UUID  `json:"created_by"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	LastUsedAt  *time.Time `json:"last_used_at"`
+}
+
+// CreatedKey is returned once by CreateKey, with the full key to hand to the integration.
+type CreatedKey struct {
+	KeyView
+	Key string `json:"key"`
+}
+
+// Identity is what a valid bearer token resolves to.
+type Identity = domain.APIKeyIdentity
+
+// ListKeys returns the org's unrevoked keys, newest first.
+func (s *Service) ListKeys(ctx context.Context, orgID uuid.UUID) ([]KeyView, error) {
+	var rows []domain.OrgAPIKey
+	if err := s.DB.WithContext(ctx).
+		Where("org_id = ? AND revoked_at IS NULL", orgID).
+		Order(`"createdAt" DESC`).
+		Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	out := make([]KeyView, 0, len(rows))
+	for i := range rows {
+		out = append(out, view(&rows[i]))
+	}
+	return out, nil
+}
+
+// CreateKey issues a key for the org. The returned Key is the only copy of the secret.
+func (s *Service) CreateKey(ctx context.Context, in CreateInput) (*CreatedKey, error) {
+	name, perms, err := validate(in)
+	if err != nil {
+		return nil, err
+	}
+	id := make([]byte, 4)
+	secret := make([]byte, 24)
+	rand.Read(id)
+	rand.Read(secret)
+	prefix := keyPrefix + hex.EncodeToString(id)
+	token := prefix + "_" + hex.EncodeToString(secret)
+
+	b, _ := json.Marshal(perms)
+	key := domain.OrgAPIKey{
+		OrgID:       in.OrgID,
+		Name:        name,
+		Prefix:      prefix,
+		KeyHash:     hashKey(token),
+		Permissions: datatypes.JSON(b),
+		CreatedBy:   in.ActorUserID,
+		ExpiresAt:   in.ExpiresAt,
+	}
+	if err := s.DB.WithContext(ctx).Create(&key).Error; err != nil {
+		return nil, err
+	}
+	return &CreatedKey{KeyView: view(&key), Key: token}, nil
+}
+
+// RevokeKey stops a key from working. The row is kept so audit entries still resolve.
+func (s *Service) RevokeKey(ctx context.Context, orgID, keyID uuid.UUID) (*KeyView, error) {
+	var key domain.OrgAPIKey
+	if err := s.DB.WithContext(ctx).
+		Where("key_id = ? AND org_id = ? AND revoked_at IS NULL", keyID, orgID).
+		First(&key).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, errors.New("API key not found")
+		}
+		return nil, err
+	}
+	now := time.Now()
+	if err := s.DB.WithContext(ctx).Model(&key).Update("revoked_at", now).Error; err != nil {
+		return nil, err
+	}
+	v := view(&key)
+	return &v, nil
+}
+
+// Authenticate resolves a bearer token. The key acts as the member who created it, so their membership and
+// role are checked on every request: once they leave the org or no longer hold a role that manages keys, the
+// key is revoked for good, even if they later get the role back.
+func (s *Service) Authenticate(ctx context.Context, token string) (*Identity, error) {
+	token = strings.TrimSpace(token)
+	prefix, _, ok := strings.Cut(strings.TrimPrefix(token, keyPrefix), "_")
+	if !ok || !strings.HasPrefix(token, keyPrefix) {
+		return nil, ErrInvalidAPIKey
+	}
+	var key domain.OrgAPIKey
+	if err := s.DB.WithContext(ctx).
+		Where("prefix = ? AND revoked_at IS NULL", keyPrefix+prefix).
+		First(&key).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrInvalidAPIKey
+		}
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(hashKey(token)), []byte(key.KeyHash)) != 1 {
+		return nil, ErrInvalidAPIKey
+	}
+	now := time.Now()
+	if key.ExpiresAt != nil && !now.Before(*key.ExpiresAt) {
+		return nil, ErrInvalidAPIKey
+	}
+	var user domain.User
+	if err := s.DB.WithContext(ctx).Where("user_id = ?", key.CreatedBy).First(&user).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, s.revokeOrphaned(ctx, &key)
+		}
+		return nil, err
+	}
+	// The key works while its creator is a member of the org, whichever org they are working in
+	m, err := memberships.Get(s.DB.WithContext(ctx), &user, key.OrgID)
+	if err == memberships.ErrNotMember {
+		return nil, s.revokeOrphaned(ctx, &key)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if m.CustomRoleID != nil || !constants.AllowedRole(constants.ManageAPIKeys, m.Role) {
+		return nil, s.revokeOrphaned(ctx, &key)
+	}
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= lastUsedInterval {
+		if err := s.DB.WithContext(ctx).Model(&key).UpdateColumn("last_used_at", now).Error; err != nil {
+			return nil, err
+		}
+		key.LastUsedAt = &now
+	}
+	return &Identity{Key: &key, User: &user}, nil
+}
+
+// revokeOrphaned revokes a key whose creator can no longer stand behind it and returns ErrInvalidAPIKey.
+func (s *Service) revokeOrphaned(ctx context.Context, key *domain.OrgAPIKey) error {
+	if err := s.DB.WithContext(ctx).Model(key).UpdateColumn("revoked_at", time.Now()).Error; err != nil {
+		return err
+	}
+	return ErrInvalidAPIKey
+}
+
+func view(k *domain.OrgAPIKey) KeyView {
+	return KeyView{
+		KeyID:       k.KeyID,
+		Name:        k.Name,
+		Prefix:      k.Prefix,
+		Permissions: k.PermissionList(),
+		CreatedBy:   k.CreatedBy,
+		CreatedAt:   k.CreatedAt,
+		ExpiresAt:   k.ExpiresAt,
+		LastUsedAt:  k.LastUsedAt,
+	}
+}
+
+// validate trims the name and returns the de-duplicated, sorted permission set. Keys take the same
+// permissions as custom roles (constants.GrantablePermission), so a key can never manage keys itself.
+func validate(in CreateInput) (string, []string, error) {
+	name := strings.TrimSpace(in.Name)
+	if name == "" {
+		return "", nil, errors.New("API key name is required")
+	}
+	if len(in.Permissions) == 0 {
+		return "", nil, errors.New("At least one permission is required")
+	}
+	if in.ExpiresAt != nil && !in.ExpiresAt.After(time.Now()) {
+		return "", nil, errors.New("Expiry must be in the future")
+	}
+	seen := make(map[string]bool, len(in.Permissions))
+	perms := make([]string, 0, len(in.Permissions))
+	for _, p := range in.Permissions {
+		if !constants.GrantablePermission(p) {
+			return "", nil, errors.New("Permission cannot be granted to an API key")
+		}
+		if !seen[p] {
+			seen[p] = true
+			perms = append(perms, p)
+		}
+	}
+	sort.Strings(perms)
+	return name, perms, nil
+}
+
+func hashKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
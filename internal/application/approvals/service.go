@@ -0,0 +1,263 @@
+package approvals
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"troo-backend/internal/application/limits"
+	"troo-backend/internal/application/trading"
+	"troo-backend/internal/domain"
+	"troo-backend/internal/pkg/decimal"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	TypeTransfer = "transfer"
+	TypeRetire   = "retire"
+
+	StatusPending   = "pending"
+	StatusApproved  = "approved"
+	StatusRejected  = "rejected"
+	StatusCancelled = "cancelled"
+)
+
+// Service implements four-eyes control: transfers and retirements matching the org's ApprovalPolicy are stored
+// as pending requests and only executed (through the trading service) once a different admin approves them.
+type Service struct {
+	DB *gorm.DB
+}
+
+// GetPolicy returns the org's policy; orgs that never set one get an empty policy (nothing needs approval).
+func (s *Service) GetPolicy(ctx context.Context, orgID uuid.UUID) (*domain.ApprovalPolicy, error) {
+	var policy domain.ApprovalPolicy
+	err := s.DB.WithContext(ctx).Where("org_id = ?", orgID).First(&policy).Error
+	if err == gorm.ErrRecordNotFound {
+		return &domain.ApprovalPolicy{OrgID: orgID}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+type PolicyInput struct {
+	OrgID             uuid.UUID
+	UpdatedBy         uuid.UUID
+	TransferThreshold *decimal.Decimal
+	RetireThreshold   *decimal.Decimal
+	AllTransfers      bool
+}
+
+// SetPolicy creates or replaces the org's policy.
+func (s *Service) SetPolicy(ctx context.Context, in PolicyInput) (*domain.ApprovalPolicy, error) {
+	for _, t := range []*decimal.Decimal{in.TransferThreshold, in.RetireThreshold} {
+		if t != nil && t.IsNegative() {
+			return nil, errors.New("Invalid threshold")
+		}
+	}
+	policy := domain.ApprovalPolicy{
+		OrgID:             in.OrgID,
+		TransferThreshold: in.TransferThreshold,
+		RetireThreshold:   in.RetireThreshold,
+		AllTransfers:      in.AllTransfers,
+		UpdatedBy:         &in.UpdatedBy,
+	}
+	if err := s.DB.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "org_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"transfer_threshold", "retire_threshold", "all_transfers", "updated_by", "updatedAt"}),
+	}).Create(&policy).Error; err != nil {
+		return nil, err
+	}
+	return s.GetPolicy(ctx, in.OrgID)
+}
+
+// Requires reports whether an operation of kind and amount must go through approval under the org's policy.
+// Amounts strictly above the threshold need approval.
+func (s *Service) Requires(ctx context.Context, orgID uuid.UUID, kind string, amount decimal.Decimal) (bool, error) {
+	policy, err := s.GetPolicy(ctx, orgID)
+	if err != nil {
+		return false, err
+	}
+	switch kind {
+	case TypeTransfer:
+		if policy.AllTransfers {
+			return true, nil
+		}
+		return policy.TransferThreshold != nil && amount.GreaterThan(*policy.TransferThreshold), nil
+	case TypeRetire:
+		return policy.RetireThreshold != nil && amount.GreaterThan(*policy.RetireThreshold), nil
+	}
+	return false, nil
+}
+
+type SubmitInput struct {
+	OrgID       uuid.UUID
+	RequestedBy uuid.UUID
+	Type        string
+	ProjectID   uuid.UUID
+	// SubAccountID is the sub-account the credits leave; nil for the main account.
+	SubAccountID *uuid.UUID
+	Amount       decimal.Decimal
+	ToOrgCode    *string
+	Purpose      *string
+	Beneficiary  *string
+	// LimitUsageID is the requester's trading-limit reservation, released if the request is rejected.
+	LimitUsageID *uuid.UUID
+}
+
+// SubmitIfRequired stores a pending request when the policy requires approval and returns it;
+// it returns nil when the caller may execute the operation straight away.
+func (s *Service) SubmitIfRequired(ctx context.Context, in SubmitInput) (*domain.ApprovalRequest, error) {
+	required, err := s.Requires(ctx, in.OrgID, in.Type, in.Amount)
+	if err != nil || !required {
+		return nil, err
+	}
+	req := domain.ApprovalRequest{
+		OrgID:        in.OrgID,
+		Type:         in.Type,
+		ProjectID:    in.ProjectID,
+		SubAccountID: in.SubAccountID,
+		Amount:       in.Amount,
+		ToOrgCode:    in.ToOrgCode,
+		Purpose:      in.Purpose,
+		Beneficiary:  in.Beneficiary,
+		Status:       StatusPending,
+		RequestedBy:  in.RequestedBy,
+		LimitUsageID: in.LimitUsageID,
+	}
+	if err := s.DB.WithContext(ctx).Create(&req).Error; err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+// ListRequests returns the org's requests, newest first, optionally filtered by status.
+func (s *Service) ListRequests(ctx context.Context, orgID uuid.UUID, status string) ([]domain.ApprovalRequest, error) {
+	q := s.DB.WithContext(ctx).Where("org_id = ?", orgID)
+	if status != "" {
+		q = q.Where("status = ?", status)
+	}
+	var out []domain.ApprovalRequest
+	if err := q.Order(`"createdAt" DESC`).Find(&out).Error; err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Approve executes a pending request as the approver and marks it approved, in one transaction:
+// if the transfer/retirement fails (e.g. credits no longer available) the request stays pending.
+// The request row is locked first so two approvers cannot execute it twice.
+func (s *Service) Approve(ctx context.Context, requestID, orgID, approverID uuid.UUID) (*domain.ApprovalRequest, error) {
+	var req domain.ApprovalRequest
+	err := s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := lockPending(tx, &req, requestID, orgID); err != nil {
+			return err
+		}
+		if req.RequestedBy == approverID {
+			return errors.New("Requester cannot approve their own request")
+		}
+
+		// Runs inside tx (nested transaction), so credits move only if the approval is recorded.
+		// The requester is recorded as the acting user; the approver is on the request (decided_by).
+		ts := &trading.Service{DB: tx}
+		var result map[string]interface{}
+		var err error
+		switch req.Type {
+		case TypeTransfer:
+			toOrgCode := ""
+			if req.ToOrgCode != nil {
+				toOrgCode = *req.ToOrgCode
+			}
+			result, err = ts.TransferCredits(ctx, &req.RequestedBy, req.OrgID, req.SubAccountID, req.ProjectID, toOrgCode, req.Amount)
+		case TypeRetire:
+			result, err = ts.RetireCredits(ctx, &req.RequestedBy, req.OrgID, req.SubAccountID, req.ProjectID, req.Amount, req.Purpose, req.Beneficiary)
+		default:
+			return errors.New("Unknown approval request type")
+		}
+		if err != nil {
+			return err
+		}
+
+		resultBytes, _ := json.Marshal(result)
+		now := time.Now()
+		req.Status = StatusApproved
+		req.DecidedBy = &approverID
+		req.DecidedAt = &now
+		req.Result = datatypes.JSON(resultBytes)
+		return tx.Save(&req).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+// Reject closes a pending request without moving credits and releases the requester's trading-limit reservation.
+// The requester may reject (withdraw) their own request.
+func (s *Service) Reject(ctx context.Context, requestID, orgID, userID uuid.UUID, reason *string) (*domain.ApprovalRequest, error) {
+	var req domain.ApprovalRequest
+	err := s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := lockPending(tx, &req, requestID, orgID); err != nil {
+			return err
+		}
+		if err := limits.ReleaseTx(tx, req.LimitUsageID); err != nil {
+			return err
+		}
+		now := time.Now()
+		req.Status = StatusRejected
+		req.DecidedBy = &userID
+		req.DecidedAt = &now
+		req.RejectionReason = reason
+		return tx.Save(&req).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+// CancelPending closes the org's pending requests inside the caller's transaction, e.g. when the org is closed,
+// releasing their trading-limit reservations. userID is recorded as deciding them.
+func CancelPending(tx *gorm.DB, orgID, userID uuid.UUID) (int64, error) {
+	var pending []domain.ApprovalRequest
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("org_id = ? AND status = ?", orgID, StatusPending).
+		Find(&pending).Error; err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	for i := range pending {
+		req := &pending[i]
+		if err := limits.ReleaseTx(tx, req.LimitUsageID); err != nil {
+			return 0, err
+		}
+		req.Status = StatusCancelled
+		req.DecidedBy = &userID
+		req.DecidedAt = &now
+		if err := tx.Save(req).Error; err != nil {
+			return 0, err
+		}
+	}
+	return int64(len(pending)), nil
+}
+
+func lockPending(tx *gorm.DB, req *domain.ApprovalRequest, requestID, orgID uuid.UUID) error {
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("request_id = ? AND org_id = ?", requestID, orgID).
+		First(req).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return errors.New("Approval request not found")
+		}
+		return err
+	}
+	if req.Status != StatusPending {
+		return errors.New("Approval request is not pending")
+	}
+	return nil
+}
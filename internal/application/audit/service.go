@@ -0,0 +1,243 @@
+package audit
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strings"
+	"time"
+
+	"troo-backend/internal/domain"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// Actions recorded in the audit log.
+const (
+	ActionLogin              = "auth.login"
+	ActionLoginFailed        = "auth.login_failed"
+	ActionAccountLocked      = "auth.account_locked"
+	ActionSessionRevoked     = "auth.session_revoked"
+	ActionResetRequested     = "auth.password_reset_requested"
+	ActionPasswordReset      = "auth.password_reset"
+	ActionTwoFactorEnabled   = "auth.2fa_enabled"
+	ActionTwoFactorDisabled  = "auth.2fa_disabled"
+	ActionRoleChanged        = "user.role_changed"
+	ActionUserRemoved        = "user.removed"
+	ActionUserSignedOut      = "user.force_logout"
+	ActionOrgSwitched        = "user.org_switched"
+	ActionUserProvisioned    = "user.sso_provisioned"
+	ActionSCIMProvisioned    = "user.scim_provisioned"
+	ActionSCIMUpdated        = "user.scim_updated"
+	ActionSCIMDeprovisioned  = "user.scim_deprovisioned"
+	ActionSCIMGroupUpdated   = "user.scim_group_updated"
+	ActionInviteSent         = "invitation.sent"
+	ActionInviteResent       = "invitation.resent"
+	ActionInviteRevoked      = "invitation.revoked"
+	ActionInviteAccepted     = "invitation.accepted"
+	ActionOrgCreated         = "org.created"
+	ActionOrgUpdated         = "org.updated"
+	ActionOrgClosed          = "org.closed"
+	ActionPublicProfile      = "org.public_profile_updated"
+	ActionSecurityPolicy     = "org.security_policy_updated"
+	ActionSSOConfigUpdated   = "org.sso_config_updated"
+	ActionSSODomainVerified  = "org.sso_domain_verified"
+	ActionSCIMTokenRotated   = "org.scim_token_rotated"
+	ActionSCIMTokenRevoked   = "org.scim_token_revoked"
+	ActionCustomRoleCreated  = "custom_role.created"
+	ActionCustomRoleUpdated  = "custom_role.updated"
+	ActionCustomRoleDeleted  = "custom_role.deleted"
+	ActionAPIKeyCreated      = "api_key.created"
+	ActionAPIKeyRevoked      = "api_key.revoked"
+	ActionListingCreated     = "listing.created"
+	ActionListingEdited      = "listing.edited"
+	ActionListingCancelled   = "listing.cancelled"
+	ActionListingForceClosed = "listing.force_closed"
+	ActionPurchaseStarted    = "trade.purchase_started"
+	ActionPurchaseCompleted  = "trade.purchase_completed"
+	ActionCreditsSold        = "trade.sell"
+	ActionCreditsTransferred = "trade.transfer"
+	ActionCreditsRetired     = "trade.retire"
+	ActionApprovalRequested  = "approval.requested"
+	ActionApprovalApproved   = "approval.approved"
+	ActionApprovalRejected   = "approval.rejected"
+	ActionHoldingAdjusted    = "holding.adjusted"
+	// Verification reviews are done by platform operators but recorded against the org.
+	ActionVerificationSubmitted     = "org.verification_submitted"
+	ActionVerificationReviewStarted = "org.verification_review_started"
+	ActionOrgVerified               = "org.verified"
+	ActionVerificationRejected      = "org.verification_rejected"
+	// Sub-accounts split an org's credits; internal transfers move them between its accounts.
+	ActionSubAccountCreated = "sub_account.created"
+	ActionSubAccountUpdated = "sub_account.updated"
+	ActionSubAccountDeleted = "sub_account.deleted"
+	ActionInternalTransfer  = "trade.internal_transfer"
+	// Platform role changes have no org and are only visible in the table itself.
+	ActionPlatformRoleGranted = "platform.role_granted"
+	ActionPlatformRoleRevoked = "platform.role_revoked"
+)
+
+const (
+	defaultLimit = 50
+	maxLimit     = 500
+)
+
+// Service appends to and reads the audit log. A nil *Service records nothing, so callers built without one keep working.
+type Service struct {
+	DB *gorm.DB
+}
+
+// Entry is one action to record.
+type Entry = domain.AuditEntry
+
+// Filter narrows an org's audit log; zero values match everything.
+type Filter struct {
+	OrgID      uuid.UUID
+	ActorID    *uuid.UUID
+	Action     string
+	TargetType string
+	TargetID   string
+	From       *time.Time
+	To         *time.Time
+	Limit      int
+	Offset     int
+}
+
+// WithTx returns a Service writing through tx, so an entry commits or rolls back with the action it records.
+func (s *Service) WithTx(tx *gorm.DB) *Service {
+	if s == nil {
+		return nil
+	}
+	return &Service{DB: tx}
+}
+
+// Record appends e to the audit log.
+func (s *Service) Record(ctx context.Context, e Entry) error {
+	if s == nil || s.DB == nil {
+		return nil
+	}
+	row := domain.AuditLog{
+		ActorUserID: e.ActorID,
+		OrgID:       e.OrgID,
+		Action:      e.Action,
+		TargetType:  e.TargetType,
+		TargetID:    e.TargetID,
+		Before:      toJSON(e.Before),
+		After:       toJSON(e.After),
+		IP:          e.IP,
+		TraceID:     e.TraceID,
+	}
+	return s.DB.WithContext(ctx).Create(&row).Error
+}
+
+// List returns one page of the org's audit log, newest first, with the total number of matching entries.
+func (s *Service) List(ctx context.Context, f Filter) ([]domain.AuditLog, int64, error) {
+	var total int64
+	if err := s.filtered(ctx, f).Model(&domain.AuditLog{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	limit := f.Limit
+	if limit <= 0 {
+		limit = defaultLimit
+	}
+	if limit > maxLimit {
+		limit = maxLimit
+	}
+	out := []domain.AuditLog{}
+	if err := s.filtered(ctx, f).Order(`"createdAt" DESC`).Limit(limit).Offset(f.Offset).Find(&out).Error; err != nil {
+		return nil, 0, err
+	}
+	return out, total, nil
+}
+
+// ExportCSV writes every entry matching f (ignoring Limit and Offset) to w as CSV, newest first.
+func (s *Service) ExportCSV(ctx context.Context, f Filter, w io.Writer) error {
+	rows, err := s.filtered(ctx, f).Model(&domain.AuditLog{}).Order(`"createdAt" DESC`).Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"created_at", "action", "actor_user_id", "org_id", "target_type", "target_id", "before", "after", "ip", "trace_id"}); err != nil {
+		return err
+	}
+	db := s.DB.WithContext(ctx)
+	for rows.Next() {
+		var e domain.AuditLog
+		if err := db.ScanRows(rows, &e); err != nil {
+			return err
+		}
+		if err := cw.Write([]string{
+			e.CreatedAt.UTC().Format(time.RFC3339),
+			csvCell(e.Action),
+			uuidString(e.ActorUserID),
+			uuidString(e.OrgID),
+			csvCell(e.TargetType),
+			csvCell(e.TargetID),
+			csvCell(string(e.Before)),
+			csvCell(string(e.After)),
+			csvCell(e.IP),
+			csvCell(e.TraceID),
+		}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return err
+	}
+	return rows.Err()
+}
+
+// csvCell quotes a value that a spreadsheet would run as a formula (CSV injection) with a leading apostrophe.
+func csvCell(v string) string {
+	if v != "" && strings.ContainsRune("=+-@\t\r", rune(v[0])) {
+		return "'" + v
+	}
+	return v
+}
+
+func (s *Service) filtered(ctx context.Context, f Filter) *gorm.DB {
+	q := s.DB.WithContext(ctx).Where("org_id = ?", f.OrgID)
+	if f.ActorID != nil {
+		q = q.Where("actor_user_id = ?", *f.ActorID)
+	}
+	if f.Action != "" {
+		q = q.Where("action = ?", f.Action)
+	}
+	if f.TargetType != "" {
+		q = q.Where("target_type = ?", f.TargetType)
+	}
+	if f.TargetID != "" {
+		q = q.Where("target_id = ?", f.TargetID)
+	}
+	if f.From != nil {
+		q = q.Where(`"createdAt" >= ?`, *f.From)
+	}
+	if f.To != nil {
+		q = q.Where(`"createdAt" < ?`, *f.To)
+	}
+	return q
+}
+
+func toJSON(v interface{}) datatypes.JSON {
+	if v == nil {
+		return nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	return datatypes.JSON(b)
+}
+
+func uuidString(u *uuid.UUID) string {
+	if u == nil {
+		return ""
+	}
+	return u.String()
+}
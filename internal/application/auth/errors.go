@@ -7,4 +7,20 @@ var (
 	ErrInvalidEmail          = errors.New("Invalid Email")
 	ErrIncorrectPassword     = errors.New("Incorrect Password")
 	ErrNotAuthenticated      = errors.New("Not authenticated")
+	// ErrInvalidCredentials is what clients see for both ErrInvalidEmail and ErrIncorrectPassword.
+	ErrInvalidCredentials    = errors.New("Invalid email or password")
+	ErrTooManyLoginAttempts  = errors.New("Too many login attempts. Please try again later")
+	ErrEmailRequired         = errors.New("Email is required")
+	ErrTooManyResetRequests  = errors.New("Too many password reset requests. Please try again later")
+	ErrResetTokenRequired    = errors.New("Token and password are required")
+	ErrInvalidResetToken     = errors.New("Invalid or expired reset token")
+	ErrInvalidPasswordFormat = errors.New("Invalid password format")
+	ErrTwoFactorEnabled      = errors.New("Two-factor authentication is already enabled")
+	ErrTwoFactorNotEnabled   = errors.New("Two-factor authentication is not enabled")
+	ErrTwoFactorNotSetUp     = errors.New("Two-factor setup has not been started")
+	ErrTwoFactorUnavailable  = errors.New("Two-factor authentication is not configured")
+	ErrTwoFactorCodeRequired = errors.New("Two-factor code is required")
+	ErrInvalidTwoFactorCode  = errors.New("Invalid two-factor code")
+	ErrSessionNotFound       = errors.New("Session not found")
+	ErrCannotRevokeCurrent   = errors.New("Use logout to end the current session")
 )
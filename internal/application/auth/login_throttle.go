@@ -0,0 +1,105 @@
+package auth
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	loginWindow = 15 * time.Minute
+	// After loginDelayAfter failures for an account each further attempt must wait, doubling from one second
+	// up to maxLoginDelay; at loginLockAfter failures the account is locked for loginLockout.
+	loginDelayAfter = 3
+	maxLoginDelay   = 30 * time.Second
+	loginLockAfter  = 10
+	loginLockout    = 15 * time.Minute
+	// loginIPMaxFailures failures from one IP within loginWindow block it until the window ends,
+	// which catches attempts spread across many accounts.
+	loginIPMaxFailures = 50
+
+	loginFailIPPrefix   = "login_fail:ip:"
+	loginFailAcctPrefix = "login_fail:acct:"
+	loginDelayPrefix    = "login_delay:"
+	loginLockPrefix     = "login_lock:"
+)
+
+// LoginThrottle counts failed logins in Redis per client IP and per account email. Accounts are keyed by the
+// email as typed (normalized), whether or not it exists, so lockouts do not reveal which emails are registered.
+type LoginThrottle struct {
+	Rdb *redis.Client
+}
+
+// Wait returns how long the client must wait before another login attempt for this email; zero means go ahead.
+func (t LoginThrottle) Wait(ctx context.Context, ip, email string) (time.Duration, error) {
+	email = normalizeLoginEmail(email)
+	var wait time.Duration
+	for _, key := range []string{loginLockPrefix + email, loginDelayPrefix + email} {
+		ttl, err := t.Rdb.PTTL(ctx, key).Result()
+		if err != nil {
+			return 0, err
+		}
+		if ttl > wait {
+			wait = ttl
+		}
+	}
+	n, err := t.Rdb.Get(ctx, loginFailIPPrefix+ip).Int()
+	if err != nil && err != redis.Nil {
+		return 0, err
+	}
+	if n >= loginIPMaxFailures {
+		ttl, err := t.Rdb.PTTL(ctx, loginFailIPPrefix+ip).Result()
+		if err != nil {
+			return 0, err
+		}
+		if ttl > wait {
+			wait = ttl
+		}
+	}
+	return wait, nil
+}
+
+// Failed records a failed attempt and reports whether it locked the account.
+func (t LoginThrottle) Failed(ctx context.Context, ip, email string) (locked bool, err error) {
+	email = normalizeLoginEmail(email)
+	if _, err := t.incr(ctx, loginFailIPPrefix+ip); err != nil {
+		return false, err
+	}
+	n, err := t.incr(ctx, loginFailAcctPrefix+email)
+	if err != nil {
+		return false, err
+	}
+	switch {
+	case n >= loginLockAfter:
+		if err := t.Rdb.Set(ctx, loginLockPrefix+email, 1, loginLockout).Err(); err != nil {
+			return false, err
+		}
+		t.Rdb.Del(ctx, loginFailAcctPrefix+email, loginDelayPrefix+email)
+		return true, nil
+	case n >= loginDelayAfter:
+		delay := time.Second << (n - loginDelayAfter)
+		if delay > maxLoginDelay {
+			delay = maxLoginDelay
+		}
+		return false, t.Rdb.Set(ctx, loginDelayPrefix+email, 1, delay).Err()
+	}
+	return false, nil
+}
+
+// Succeeded clears the account's failure count after a correct password.
+func (t LoginThrottle) Succeeded(ctx context.Context, email string) {
+	email = normalizeLoginEmail(email)
+	t.Rdb.Del(ctx, loginFailAcctPrefix+email, loginDelayPrefix+email)
+}
+
+// incr counts within loginWindow, which starts at the first failure. The count and its expiry are set in one
+// script so a crash between them cannot leave a counter that never expires.
+func (t LoginThrottle) incr(ctx context.Context, key string) (int64, error) {
+	return incrWindow(ctx, t.Rdb, key, loginWindow)
+}
+
+func normalizeLoginEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
@@ -0,0 +1,171 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"troo-backend/internal/application/emails"
+	policies "troo-backend/internal/application/policies/user"
+	"troo-backend/internal/domain"
+	"troo-backend/internal/pkg/secretbox"
+	"troo-backend/internal/pkg/validation"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const resetTokenTTL = time.Hour
+
+// Service holds the account-recovery flows that need the database, Redis and email.
+type Service struct {
+	DB          *gorm.DB
+	Rdb         *redis.Client
+	EmailSender emails.Sender // optional; without it reset links are issued but never delivered
+	AppBaseURL  string        // frontend base URL for emailed links (same as INVITE_BASE_URL)
+	// Secrets encrypts TOTP secrets at rest (SECRETS_ENCRYPTION_KEY); without it 2FA cannot be set up.
+	Secrets *secretbox.Box
+}
+
+// RequestPasswordReset issues a reset token for the account with this email and emails the link.
+// Unknown emails succeed silently so the endpoint cannot be used to discover accounts.
+// Issuing a token invalidates any earlier unused ones. Returns the user when a token was issued.
+func (s *Service) RequestPasswordReset(ctx context.Context, email string) (*domain.User, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if email == "" {
+		return nil, ErrEmailRequired
+	}
+	var u domain.User
+	if err := s.DB.WithContext(ctx).Where("LOWER(email) = ?", email).First(&u).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	token := randomToken()
+	now := time.Now()
+	err := s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&domain.PasswordResetToken{}).
+			Where("user_id = ? AND used_at IS NULL", u.UserID).
+			Update("used_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Create(&domain.PasswordResetToken{
+			UserID:    u.UserID,
+			TokenHash: hashToken(token),
+			ExpiresAt: now.Add(resetTokenTTL),
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Send reset email (non-blocking, so response time does not reveal whether the account exists)
+	if s.EmailSender != nil {
+		firstName := u.Fullname
+		if idx := strings.IndexByte(u.Fullname, ' '); idx > 0 {
+			firstName = u.Fullname[:idx]
+		}
+		link := fmt.Sprintf("%s/reset-password?token=%s", strings.TrimSuffix(s.AppBaseURL, "/"), token)
+		go func(to, first string) {
+			if err := s.EmailSender.SendPasswordReset(context.Background(), to, first, link); err != nil {
+				log.Error().Err(err).Str("to", to).Msg("password reset email send failed")
+			}
+		}(u.Email, firstName)
+	}
+	return &u, nil
+}
+
+// ResetPassword consumes a reset token, sets the new password and logs the user out of every session.
+func (s *Service) ResetPassword(ctx context.Context, token, password string) (*domain.User, error) {
+	if token == "" || password == "" {
+		return nil, ErrResetTokenRequired
+	}
+	if !validation.IsValidPassword(password) {
+		return nil, ErrInvalidPasswordFormat
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), 10)
+	if err != nil {
+		return nil, err
+	}
+
+	var u domain.User
+	err = s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var t domain.PasswordResetToken
+		if err := tx.Where("token_hash = ?", hashToken(token)).First(&t).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return ErrInvalidResetToken
+			}
+			return err
+		}
+		now := time.Now()
+		if t.UsedAt != nil || t.ExpiresAt.Before(now) {
+			return ErrInvalidResetToken
+		}
+		// Conditional update so two concurrent resets cannot both consume the token.
+		res := tx.Model(&domain.PasswordResetToken{}).
+			Where("token_id = ? AND used_at IS NULL", t.TokenID).
+			Update("used_at", now)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrInvalidResetToken
+		}
+		if err := tx.Where("user_id = ?", t.UserID).First(&u).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return ErrInvalidResetToken
+			}
+			return err
+		}
+		return tx.Model(&u).Update("password_hash", string(hash)).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	policies.DestroyUserSessions(ctx, s.Rdb, u.UserID.String())
+	return &u, nil
+}
+
+// NotifyAccountLocked emails the owner of email, if there is one, that sign-in was locked (non-blocking).
+func (s *Service) NotifyAccountLocked(ctx context.Context, email string) (*domain.User, error) {
+	var u domain.User
+	if err := s.DB.WithContext(ctx).Where("LOWER(email) = ?", normalizeLoginEmail(email)).First(&u).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if s.EmailSender != nil {
+		firstName := u.Fullname
+		if idx := strings.IndexByte(u.Fullname, ' '); idx > 0 {
+			firstName = u.Fullname[:idx]
+		}
+		link := strings.TrimSuffix(s.AppBaseURL, "/") + "/forgot-password"
+		go func(to, first string) {
+			if err := s.EmailSender.SendAccountLocked(context.Background(), to, first, link); err != nil {
+				log.Error().Err(err).Str("to", to).Msg("account locked email send failed")
+			}
+		}(u.Email, firstName)
+	}
+	return &u, nil
+}
+
+func randomToken() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	resetWindow = time.Hour
+	// At most resetEmailMax reset requests per email and resetIPMax per client IP within resetWindow, which
+	// stops the endpoint being used to flood an inbox or to mail every address on a list.
+	resetEmailMax = 5
+	resetIPMax    = 20
+
+	resetReqEmailPrefix = "reset_req:email:"
+	resetReqIPPrefix    = "reset_req:ip:"
+)
+
+// windowIncr increments KEYS[1] and starts its expiry (ARGV[1] ms) on the first increment, in one step so
+// a key can never be left counting without an expiry.
+var windowIncr = redis.NewScript(`
+local n = redis.call("INCR", KEYS[1])
+if n == 1 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return n
+`)
+
+// incrWindow counts key within window, which starts at the first increment.
+func incrWindow(ctx context.Context, rdb *redis.Client, key string, window time.Duration) (int64, error) {
+	return windowIncr.Run(ctx, rdb, []string{key}, window.Milliseconds()).Int64()
+}
+
+// ResetThrottle counts password reset requests in Redis per client IP and per email. Emails are keyed as typed
+// (normalized), whether or not an account exists, so a refusal does not reveal which emails are registered.
+type ResetThrottle struct {
+	Rdb *redis.Client
+}
+
+// Allow records a reset request and returns how long the client must wait when it is over either limit;
+// zero means go ahead.
+func (t ResetThrottle) Allow(ctx context.Context, ip, email string) (time.Duration, error) {
+	var wait time.Duration
+	for key, max := range map[string]int64{
+		resetReqIPPrefix + ip:                            resetIPMax,
+		resetReqEmailPrefix + normalizeLoginEmail(email): resetEmailMax,
+	} {
+		n, err := incrWindow(ctx, t.Rdb, key, resetWindow)
+		if err != nil {
+			return 0, err
+		}
+		if n <= max {
+			continue
+		}
+		ttl, err := t.Rdb.PTTL(ctx, key).Result()
+		if err != nil {
+			return 0, err
+		}
+		if ttl > wait {
+			wait = ttl
+		}
+	}
+	return wait, nil
+}
@@ -15,11 +15,24 @@ type LoginInput struct {
 
 // SessionUserShape is the object stored in session and returned by /me (Express parity).
 type SessionUserShape struct {
-	UserID   string  `json:"user_id"`
-	Fullname string  `json:"fullname"`
-	Email    string  `json:"email"`
-	Role     string  `json:"role"`
-	OrgID    *string `json:"org_id"`
+	UserID       string   `json:"user_id"`
+	Fullname     string   `json:"fullname"`
+	Email        string   `json:"email"`
+	Role         string   `json:"role"`
+	OrgID        *string  `json:"org_id"`
+	CustomRoleID *string  `json:"custom_role_id,omitempty"`
+	Permissions  []string `json:"permissions,omitempty"`
+	PlatformRole *string  `json:"platform_role,omitempty"`
+	// EmailVerified is false until the emailed verification link is used; trading permissions require it.
+	EmailVerified bool `json:"email_verified"`
+	// TwoFactor is true when this session passed a TOTP check; TwoFactorRequired when the user's org requires 2FA.
+	TwoFactor         bool `json:"two_factor"`
+	TwoFactorRequired bool `json:"two_factor_required"`
+	// ImpersonatedBy is the operator's user id while they read-only impersonate OrgID.
+	ImpersonatedBy *string `json:"impersonated_by,omitempty"`
+	// AuthMethod is how the session signed in; SSOOrgID the org an SSO session is bound to.
+	AuthMethod string  `json:"auth_method,omitempty"`
+	SSOOrgID   *string `json:"sso_org_id,omitempty"`
 }
 
 // UserFinder abstracts user lookup by email+password (for production GORM or test doubles).
@@ -34,19 +47,24 @@ func (g *GormUserFinder) FindByEmailAndPassword(email, password string) (*domain
 	return LoginUser(g.DB, LoginInput{Email: email, Password: password})
 }
 
-// LoginUser finds user by email and verifies password. Returns user for session or error.
+// dummyHash is compared against when the email is unknown, so both failures take as long as a bcrypt check.
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("not-a-password"), 10)
+
+// LoginUser finds user by email and verifies password. Returns user (with CustomRole and PlatformOperator loaded) for session or error.
 func LoginUser(db *gorm.DB, input LoginInput) (*domain.User, error) {
 	if input.Email == "" || input.Password == "" {
 		return nil, ErrEmailPasswordRequired
 	}
 	var u domain.User
-	if err := db.Where("email = ?", input.Email).First(&u).Error; err != nil {
+	if err := db.Preload("CustomRole").Preload("PlatformOperator").Where("email = ?", input.Email).First(&u).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
+			_ = bcrypt.CompareHashAndPassword(dummyHash, []byte(input.Password))
 			return nil, ErrInvalidEmail
 		}
 		return nil, err
 	}
 	if u.PasswordHash == "" {
+		_ = bcrypt.CompareHashAndPassword(dummyHash, []byte(input.Password))
 		return nil, ErrInvalidEmail
 	}
 	if err := bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(input.Password)); err != nil {
@@ -74,11 +92,37 @@ func VerifyUser(sessionUser interface{}) (*SessionUserShape, error) {
 		Email:    str(m["email"]),
 		Role:     str(m["role"]),
 	}
+	// Sessions created before the flag existed belong to accounts grandfathered as verified.
+	verified, ok := m["email_verified"].(bool)
+	out.EmailVerified = !ok || verified
+	out.TwoFactor, _ = m["two_factor"].(bool)
+	out.TwoFactorRequired, _ = m["two_factor_required"].(bool)
 	if o, ok := m["org_id"]; ok && o != nil {
 		if s, ok := o.(string); ok {
 			out.OrgID = &s
 		}
 	}
+	if id := str(m["custom_role_id"]); id != "" {
+		out.CustomRoleID = &id
+		switch v := m["permissions"].(type) {
+		case []string:
+			out.Permissions = v
+		case []interface{}:
+			for _, p := range v {
+				out.Permissions = append(out.Permissions, str(p))
+			}
+		}
+	}
+	if r := str(m["platform_role"]); r != "" {
+		out.PlatformRole = &r
+	}
+	if by := str(m["impersonated_by"]); by != "" {
+		out.ImpersonatedBy = &by
+	}
+	out.AuthMethod = str(m["auth_method"])
+	if org := str(m["sso_org_id"]); org != "" {
+		out.SSOOrgID = &org
+	}
 	return out, nil
 }
 
@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	"troo-backend/internal/pkg/constants"
+)
+
+const userSessionsPrefix = constants.UserSessionsPrefix
+
+// ActiveSession is one signed-in session as shown to its user. ID is a hash of the session id, so the list
+// never exposes a value that could be replayed as a cookie.
+type ActiveSession struct {
+	ID        string `json:"session_id"`
+	Current   bool   `json:"current"`
+	CreatedAt string `json:"created_at,omitempty"`
+	LastSeen  string `json:"last_seen,omitempty"`
+	IP        string `json:"ip,omitempty"`
+	UserAgent string `json:"user_agent,omitempty"`
+}
+
+// ListSessions returns the user's sessions from user_sessions:<user_id>, pruning ids whose session has expired.
+// Sessions created before metadata was recorded are listed without it.
+func (s *Service) ListSessions(ctx context.Context, userID, currentSID string) ([]ActiveSession, error) {
+	key := userSessionsPrefix + userID
+	sids, err := s.Rdb.SMembers(ctx, key).Result()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]ActiveSession, 0, len(sids))
+	for _, sid := range sids {
+		b, err := s.Rdb.Get(ctx, constants.SessionRedisPrefix+sid).Bytes()
+		if err != nil {
+			s.Rdb.SRem(ctx, key, sid)
+			continue
+		}
+		var data struct {
+			Meta struct {
+				CreatedAt string `json:"created_at"`
+				LastSeen  string `json:"last_seen"`
+				IP        string `json:"ip"`
+				UserAgent string `json:"user_agent"`
+			} `json:"session_meta"`
+		}
+		_ = json.Unmarshal(b, &data)
+		out = append(out, ActiveSession{
+			ID:        PublicSessionID(sid),
+			Current:   sid == currentSID,
+			CreatedAt: data.Meta.CreatedAt,
+			LastSeen:  data.Meta.LastSeen,
+			IP:        data.Meta.IP,
+			UserAgent: data.Meta.UserAgent,
+		})
+	}
+	return out, nil
+}
+
+// RevokeSession ends one of the user's other sessions, identified by its public id.
+func (s *Service) RevokeSession(ctx context.Context, userID, publicID, currentSID string) error {
+	if publicID == PublicSessionID(currentSID) {
+		return ErrCannotRevokeCurrent
+	}
+	key := userSessionsPrefix + userID
+	sids, err := s.Rdb.SMembers(ctx, key).Result()
+	if err != nil {
+		return err
+	}
+	for _, sid := range sids {
+		if PublicSessionID(sid) == publicID {
+			s.Rdb.Del(ctx, constants.SessionRedisPrefix+sid)
+			return s.Rdb.SRem(ctx, key, sid).Err()
+		}
+	}
+	return ErrSessionNotFound
+}
+
+// RevokeOtherSessions ends every session of the user except the current one and returns how many were ended.
+func (s *Service) RevokeOtherSessions(ctx context.Context, userID, currentSID string) (int, error) {
+	key := userSessionsPrefix + userID
+	sids, err := s.Rdb.SMembers(ctx, key).Result()
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, sid := range sids {
+		if sid == currentSID {
+			continue
+		}
+		s.Rdb.Del(ctx, constants.SessionRedisPrefix+sid)
+		s.Rdb.SRem(ctx, key, sid)
+		n++
+	}
+	return n, nil
+}
+
+// PublicSessionID is the id clients use to refer to a session.
+func PublicSessionID(sid string) string {
+	sum := sha256.Sum256([]byte(sid))
+	return hex.EncodeToString(sum[:16])
+}
@@ -0,0 +1,270 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"troo-backend/internal/application/memberships"
+	"troo-backend/internal/domain"
+	"troo-backend/internal/pkg/secretbox"
+	"troo-backend/internal/pkg/totp"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	totpIssuer        = "troo.earth"
+	recoveryCodeCount = 10
+)
+
+// TwoFactorSetup is returned by SetupTwoFactor for the client to render as a QR code (URI) or for manual entry (Secret).
+type TwoFactorSetup struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+}
+
+// SetupTwoFactor starts (or restarts) enrolment with a fresh secret, stored encrypted with Secrets. Login does
+// not ask for a code until EnableTwoFactor has confirmed the authenticator works.
+func (s *Service) SetupTwoFactor(ctx context.Context, userID, email string) (*TwoFactorSetup, error) {
+	if s.Secrets == nil {
+		return nil, ErrTwoFactorUnavailable
+	}
+	tf, err := s.findTwoFactor(s.DB.WithContext(ctx), userID)
+	if err != nil {
+		return nil, err
+	}
+	if tf != nil && tf.EnabledAt != nil {
+		return nil, ErrTwoFactorEnabled
+	}
+	secret := totp.GenerateSecret()
+	if tf == nil {
+		uid, err := uuid.Parse(userID)
+		if err != nil {
+			return nil, ErrNotAuthenticated
+		}
+		tf = &domain.UserTwoFactor{UserID: uid}
+	}
+	if tf.Secret, err = s.Secrets.Seal(secret); err != nil {
+		return nil, err
+	}
+	tf.LastUsedStep = 0
+	tf.RecoveryCodes = nil
+	if err := s.DB.WithContext(ctx).Save(tf).Error; err != nil {
+		return nil, err
+	}
+	return &TwoFactorSetup{Secret: secret, URI: totp.ProvisioningURI(secret, totpIssuer, email)}, nil
+}
+
+// EnableTwoFactor confirms enrolment with a code from the authenticator and returns the recovery codes,
+// which are shown once and stored only as hashes.
+func (s *Service) EnableTwoFactor(ctx context.Context, userID, code string) ([]string, error) {
+	if strings.TrimSpace(code) == "" {
+		return nil, ErrTwoFactorCodeRequired
+	}
+	var codes []string
+	err := s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		tf, err := s.lockTwoFactor(tx, userID)
+		if err != nil {
+			return err
+		}
+		if tf == nil {
+			return ErrTwoFactorNotSetUp
+		}
+		if tf.EnabledAt != nil {
+			return ErrTwoFactorEnabled
+		}
+		secret, err := s.openSecret(tf)
+		if err != nil {
+			return err
+		}
+		step, ok := totp.Validate(secret, code, time.Now())
+		if !ok {
+			return ErrInvalidTwoFactorCode
+		}
+		var hashes datatypes.JSON
+		codes, hashes = newRecoveryCodes()
+		now := time.Now()
+		return tx.Model(tf).Updates(map[string]interface{}{
+			"enabled_at":     now,
+			"last_used_step": step,
+			"recovery_codes": hashes,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// VerifySecondFactor checks a TOTP code, or failing that a recovery code, for a user with 2FA enabled.
+// Each TOTP code is accepted once and each recovery code is consumed on use.
+func (s *Service) VerifySecondFactor(ctx context.Context, userID, code, recoveryCode string) error {
+	if strings.TrimSpace(code) == "" && strings.TrimSpace(recoveryCode) == "" {
+		return ErrTwoFactorCodeRequired
+	}
+	return s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		tf, err := s.lockTwoFactor(tx, userID)
+		if err != nil {
+			return err
+		}
+		if tf == nil || tf.EnabledAt == nil {
+			return ErrTwoFactorNotEnabled
+		}
+		if strings.TrimSpace(code) != "" {
+			secret, err := s.openSecret(tf)
+			if err != nil {
+				return err
+			}
+			step, ok := totp.Validate(secret, code, time.Now())
+			if !ok || step <= tf.LastUsedStep {
+				return ErrInvalidTwoFactorCode
+			}
+			updates := map[string]interface{}{"last_used_step": step}
+			// Enrolments from before secrets were encrypted are sealed on their next use.
+			if !secretbox.IsSealed(tf.Secret) && s.Secrets != nil {
+				sealed, err := s.Secrets.Seal(secret)
+				if err != nil {
+					return err
+				}
+				updates["secret"] = sealed
+			}
+			return tx.Model(tf).Updates(updates).Error
+		}
+
+		var hashes []string
+		_ = json.Unmarshal(tf.RecoveryCodes, &hashes)
+		want := hashToken(normalizeRecoveryCode(recoveryCode))
+		for i, h := range hashes {
+			if h == want {
+				rest := append(hashes[:i:i], hashes[i+1:]...)
+				b, _ := json.Marshal(rest)
+				return tx.Model(tf).Update("recovery_codes", datatypes.JSON(b)).Error
+			}
+		}
+		return ErrInvalidTwoFactorCode
+	})
+}
+
+// DisableTwoFactor removes the enrolment after checking a current code or a recovery code.
+func (s *Service) DisableTwoFactor(ctx context.Context, userID, code, recoveryCode string) error {
+	if err := s.VerifySecondFactor(ctx, userID, code, recoveryCode); err != nil {
+		return err
+	}
+	return s.DB.WithContext(ctx).Where("user_id = ?", userID).Delete(&domain.UserTwoFactor{}).Error
+}
+
+// RegenerateRecoveryCodes replaces every recovery code after checking a current TOTP code.
+func (s *Service) RegenerateRecoveryCodes(ctx context.Context, userID, code string) ([]string, error) {
+	if strings.TrimSpace(code) == "" {
+		return nil, ErrTwoFactorCodeRequired
+	}
+	if err := s.VerifySecondFactor(ctx, userID, code, ""); err != nil {
+		return nil, err
+	}
+	codes, hashes := newRecoveryCodes()
+	if err := s.DB.WithContext(ctx).Model(&domain.UserTwoFactor{}).
+		Where("user_id = ?", userID).
+		Update("recovery_codes", hashes).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// TwoFactorStatus reports whether the user has 2FA enabled and whether their org requires it.
+func (s *Service) TwoFactorStatus(ctx context.Context, user *domain.User) (enabled, orgRequires bool, err error) {
+	tf, err := s.findTwoFactor(s.DB.WithContext(ctx), user.UserID.String())
+	if err != nil {
+		return false, false, err
+	}
+	enabled = tf != nil && tf.EnabledAt != nil
+	if user.OrgID != nil {
+		var org domain.Org
+		if err := s.DB.WithContext(ctx).Select("require_two_factor").Where("org_id = ?", *user.OrgID).First(&org).Error; err != nil && err != gorm.ErrRecordNotFound {
+			return false, false, err
+		}
+		orgRequires = org.RequireTwoFactor
+	}
+	return enabled, orgRequires, nil
+}
+
+// FindSessionUser loads a user with the associations the session needs, for completing a two-step login.
+// ssoOrgID, set when the login came through that org's SSO, returns them as a member of it; a user who has
+// left it since is not authenticated.
+func (s *Service) FindSessionUser(ctx context.Context, userID, ssoOrgID string) (*domain.User, error) {
+	db := s.DB.WithContext(ctx)
+	var u domain.User
+	if err := db.Preload("CustomRole").Preload("PlatformOperator").Where("user_id = ?", userID).First(&u).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrNotAuthenticated
+		}
+		return nil, err
+	}
+	if ssoOrgID == "" {
+		return &u, nil
+	}
+	orgID, err := uuid.Parse(ssoOrgID)
+	if err != nil {
+		return nil, ErrNotAuthenticated
+	}
+	if _, err := memberships.Enter(db, &u, orgID); err != nil {
+		if err == memberships.ErrNotMember {
+			return nil, ErrNotAuthenticated
+		}
+		return nil, err
+	}
+	return &u, nil
+}
+
+func (s *Service) findTwoFactor(db *gorm.DB, userID string) (*domain.UserTwoFactor, error) {
+	var tf domain.UserTwoFactor
+	if err := db.Where("user_id = ?", userID).First(&tf).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &tf, nil
+}
+
+// openSecret decrypts the stored TOTP secret; one stored before encryption is returned as it is.
+func (s *Service) openSecret(tf *domain.UserTwoFactor) (string, error) {
+	if !secretbox.IsSealed(tf.Secret) {
+		return tf.Secret, nil
+	}
+	if s.Secrets == nil {
+		return "", ErrTwoFactorUnavailable
+	}
+	return s.Secrets.Open(tf.Secret)
+}
+
+// lockTwoFactor loads the row FOR UPDATE so concurrent checks cannot both accept the same code.
+func (s *Service) lockTwoFactor(tx *gorm.DB, userID string) (*domain.UserTwoFactor, error) {
+	return s.findTwoFactor(tx.Clauses(clause.Locking{Strength: "UPDATE"}), userID)
+}
+
+// newRecoveryCodes returns codes formatted for display (xxxxx-xxxxx) and the JSON array of their hashes.
+func newRecoveryCodes() ([]string, datatypes.JSON) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, 5)
+		rand.Read(b)
+		raw := hex.EncodeToString(b)
+		codes[i] = raw[:5] + "-" + raw[5:]
+		hashes[i] = hashToken(raw)
+	}
+	out, _ := json.Marshal(hashes)
+	return codes, datatypes.JSON(out)
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
@@ -35,11 +35,15 @@ type BrevoReplyTo struct {
 	Name  string `json:"name"`
 }
 
-// Sender sends transactional emails (welcome, invite, account updated). Nil = no-op.
+// Sender sends transactional emails (welcome, invite, account updated, password reset, email verification,
+// account locked). Nil = no-op.
 type Sender interface {
 	SendWelcome(ctx context.Context, toEmail, firstName string) error
 	SendInvite(ctx context.Context, toEmail, inviteLink, orgName, role, subject string) error
 	SendAccountUpdated(ctx context.Context, toEmail, firstName string) error
+	SendPasswordReset(ctx context.Context, toEmail, firstName, resetLink string) error
+	SendVerifyEmail(ctx context.Context, toEmail, firstName, verifyLink string) error
+	SendAccountLocked(ctx context.Context, toEmail, firstName, resetLink string) error
 }
 
 // BrevoClient sends emails via Brevo (Sendinblue) API. Same env as Express: SENDINBLUE_API_KEY, MAIL_FROM.
@@ -126,6 +130,42 @@ func (c *BrevoClient) SendAccountUpdated(ctx context.Context, toEmail, firstName
 	return c.send(ctx, toEmail, "Your troo.earth Account Was Updated", EmailLayout(content))
 }
 
+// SendPasswordReset sends the one-time password reset link.
+func (c *BrevoClient) SendPasswordReset(ctx context.Context, toEmail, firstName, resetLink string) error {
+	if c.APIKey == "" {
+		return nil
+	}
+	if firstName == "" {
+		firstName = "there"
+	}
+	content := passwordResetContent(firstName, resetLink)
+	return c.send(ctx, toEmail, "Reset your troo.earth password", EmailLayout(content))
+}
+
+// SendVerifyEmail sends the email verification link (registration and email change).
+func (c *BrevoClient) SendVerifyEmail(ctx context.Context, toEmail, firstName, verifyLink string) error {
+	if c.APIKey == "" {
+		return nil
+	}
+	if firstName == "" {
+		firstName = "there"
+	}
+	content := verifyEmailContent(firstName, verifyLink)
+	return c.send(ctx, toEmail, "Verify your troo.earth email address", EmailLayout(content))
+}
+
+// SendAccountLocked tells the owner that repeated failed logins locked sign-in, with a password reset link.
+func (c *BrevoClient) SendAccountLocked(ctx context.Context, toEmail, firstName, resetLink string) error {
+	if c.APIKey == "" {
+		return nil
+	}
+	if firstName == "" {
+		firstName = "there"
+	}
+	content := accountLockedContent(firstName, resetLink)
+	return c.send(ctx, toEmail, "Sign-in to your troo.earth account was locked", EmailLayout(content))
+}
+
 // welcomeContent matches Express accountCreatedTemplate content (inside layout).
 func welcomeContent(userName string) string {
 	dashboardURL := "https://troo.earth/"
@@ -174,3 +214,49 @@ func accountUpdatedContent(userName string) string {
     <p>— The troo.earth Team</p>
 `, EscapeHTML(userName), accountURL)
 }
+
+// passwordResetContent is the password reset email body; the link expiry matches auth.resetTokenTTL.
+func passwordResetContent(userName, resetLink string) string {
+	return fmt.Sprintf(`
+    <h1>Reset Your Password</h1>
+    <p>Hi %s,</p>
+    <p>We received a request to reset the password for your <strong>troo.earth</strong> account. Click the button below to choose a new one:</p>
+    <center>
+      <a href="%s" class="troo-button">Reset Password</a>
+    </center>
+    <p style="margin-top:20px;font-size:14px;color:#666;">
+      This link can be used once and expires in 1 hour. Resetting your password signs you out everywhere. If you did not request this, you can safely ignore this email.
+    </p>
+    <p>— The troo.earth Team</p>
+`, EscapeHTML(userName), resetLink)
+}
+
+// verifyEmailContent is the email verification body; the link expiry matches user.verifyTokenTTL.
+func verifyEmailContent(userName, verifyLink string) string {
+	return fmt.Sprintf(`
+    <h1>Verify Your Email Address</h1>
+    <p>Hi %s,</p>
+    <p>Please confirm that this is the email address for your <strong>troo.earth</strong> account. Until it is verified you can browse the marketplace, but buying, selling, transferring and retiring credits are disabled.</p>
+    <center>
+      <a href="%s" class="troo-button">Verify Email</a>
+    </center>
+    <p style="margin-top:20px;font-size:14px;color:#666;">
+      This link expires in 24 hours. If you did not create an account or change your email, you can safely ignore this email.
+    </p>
+    <p>— The troo.earth Team</p>
+`, EscapeHTML(userName), verifyLink)
+}
+
+// accountLockedContent is the lockout notice; the duration matches auth.loginLockout.
+func accountLockedContent(userName, resetLink string) string {
+	return fmt.Sprintf(`
+    <h1>Sign-in Temporarily Locked</h1>
+    <p>Hi %s,</p>
+    <p>There were too many failed sign-in attempts on your <strong>troo.earth</strong> account, so signing in has been locked for 15 minutes.</p>
+    <p>If this was you, wait and try again. If it was not, someone may know your email address; we recommend choosing a new password:</p>
+    <center>
+      <a href="%s" class="troo-button">Reset Password</a>
+    </center>
+    <p>— The troo.earth Team</p>
+`, EscapeHTML(userName), resetLink)
+}
@@ -0,0 +1,84 @@
+package holdings
+
+import (
+	"troo-backend/internal/domain"
+	"troo-backend/internal/pkg/decimal"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Lock order for every credit-moving transaction (sell, buy, transfer, retire, edit/cancel listing):
+//  1. Holdings, via LockHoldings (org_id order)
+//  2. the orgs' SubAccountHoldings for the project, via subaccounts.Lock, when sub-accounts are involved
+//     (a SubAccount row is only ever locked after these, see subaccounts.Apply and Delete)
+//  3. the Listing row, if any
+//  4. the Org row, only when a first holding has to be created (Credit)
+// Keeping one order everywhere means concurrent requests and Stripe webhooks queue on each other
+// instead of deadlocking.
+
+// ForUpdate adds SELECT ... FOR UPDATE to the next query in tx.
+func ForUpdate(tx *gorm.DB) *gorm.DB {
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"})
+}
+
+// LockHoldings loads the holdings of orgIDs for projectID with SELECT ... FOR UPDATE.
+// Rows are locked in org_id order so two transactions moving credits between the same orgs
+// (transfer A->B and B->A, or buys against each other's listings) cannot deadlock.
+// Orgs without a holding for the project are absent from the result.
+func LockHoldings(tx *gorm.DB, projectID uuid.UUID, orgIDs ...uuid.UUID) (map[uuid.UUID]*domain.Holding, error) {
+	var rows []domain.Holding
+	if err := ForUpdate(tx).
+		Where("project_id = ? AND org_id IN ?", projectID, orgIDs).
+		Order("org_id").
+		Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	out := make(map[uuid.UUID]*domain.Holding, len(rows))
+	for i := range rows {
+		out[rows[i].OrgID] = &rows[i]
+	}
+	return out, nil
+}
+
+// Credit adds amount to the org's holding for projectID, creating the holding when the org has none yet.
+// The org row is locked before creating so two concurrent first-time credits cannot insert duplicate holdings.
+func Credit(tx *gorm.DB, orgID, projectID uuid.UUID, amount decimal.Decimal) (*domain.Holding, error) {
+	locked, err := LockHoldings(tx, projectID, orgID)
+	if err != nil {
+		return nil, err
+	}
+	if h, ok := locked[orgID]; ok {
+		h.CreditBalance = h.CreditBalance.Add(amount)
+		if err := tx.Save(h).Error; err != nil {
+			return nil, err
+		}
+		return h, nil
+	}
+
+	var orgs []domain.Org
+	if err := ForUpdate(tx).Where("org_id = ?", orgID).Select("org_id").Find(&orgs).Error; err != nil {
+		return nil, err
+	}
+	// Re-check under the org lock: a concurrent transaction may have created it while we waited.
+	if locked, err = LockHoldings(tx, projectID, orgID); err != nil {
+		return nil, err
+	}
+	if h, ok := locked[orgID]; ok {
+		h.CreditBalance = h.CreditBalance.Add(amount)
+		if err := tx.Save(h).Error; err != nil {
+			return nil, err
+		}
+		return h, nil
+	}
+	h := &domain.Holding{
+		OrgID:         orgID,
+		ProjectID:     projectID,
+		CreditBalance: amount,
+	}
+	if err := tx.Create(h).Error; err != nil {
+		return nil, err
+	}
+	return h, nil
+}
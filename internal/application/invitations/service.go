@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"troo-backend/internal/application/emails"
+	"troo-backend/internal/application/memberships"
 	"troo-backend/internal/application/policies/invitations"
 	"troo-backend/internal/domain"
 	userPolicies "troo-backend/internal/application/policies/user"
@@ -133,6 +134,8 @@ type AcceptInviteResult struct {
 	OrgID   string `json:"org_id"`
 	Role    string `json:"role"`
 	OrgName string `json:"org_name"`
+	// RequireTwoFactor is the joined org's 2FA policy, carried into the new session.
+	RequireTwoFactor bool `json:"-"`
 }
 
 func (s *Service) AcceptInvite(ctx context.Context, in AcceptInviteInput) (*AcceptInviteResult, error) {
@@ -166,9 +169,8 @@ func (s *Service) AcceptInvite(ctx context.Context, in AcceptInviteInput) (*Acce
 		return nil, errors.New("Invitation email does not match logged-in user")
 	}
 
-	user.OrgID = &inv.OrgID
-	user.Role = inv.Role
-	if err := s.DB.WithContext(ctx).Save(&user).Error; err != nil {
+	// Joining makes the org the user's active org; other memberships they hold are kept
+	if err := memberships.Join(s.DB.WithContext(ctx), &user, inv.OrgID, inv.Role, nil); err != nil {
 		return nil, err
 	}
 
@@ -185,6 +187,7 @@ func (s *Service) AcceptInvite(ctx context.Context, in AcceptInviteInput) (*Acce
 		OrgID:   inv.OrgID.String(),
 		Role:    inv.Role,
 		OrgName: orgName,
+		RequireTwoFactor: org.RequireTwoFactor,
 	}, nil
 }
 
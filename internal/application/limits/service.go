@@ -0,0 +1,316 @@
+package limits
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"troo-backend/internal/application/memberships"
+	"troo-backend/internal/domain"
+	"troo-backend/internal/pkg/constants"
+	"troo-backend/internal/pkg/decimal"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	KindBuy      = "buy"
+	KindSell     = "sell"
+	KindTransfer = "transfer"
+	KindRetire   = "retire"
+)
+
+// PaymentHold is how long a buy's usage counts while its PaymentIntent has not succeeded.
+const PaymentHold = time.Hour
+
+// Service manages per-user and per-role trading limits and enforces them for the trading handlers,
+// on top of the role checks in constants.PermissionRoles.
+type Service struct {
+	DB *gorm.DB
+}
+
+type LimitInput struct {
+	OrgID                  uuid.UUID
+	UpdatedBy              uuid.UUID
+	UpdatedByRole          string // editors may not change a limit that applies to themselves
+	UserID                 *uuid.UUID
+	Role                   *string
+	MaxCreditsPerTrade     *decimal.Decimal
+	MaxDailySpend          *decimal.Decimal
+	MaxDailyTransferVolume *decimal.Decimal
+	AllowedProjectIDs      []uuid.UUID // nil = all projects
+}
+
+// SetLimit creates or replaces the limit for one user or one role of the org.
+func (s *Service) SetLimit(ctx context.Context, in LimitInput) (*domain.TradingLimit, error) {
+	if (in.UserID == nil) == (in.Role == nil) {
+		return nil, errors.New("Exactly one of user_id or role is required")
+	}
+	if (in.UserID != nil && *in.UserID == in.UpdatedBy) || (in.Role != nil && *in.Role == in.UpdatedByRole) {
+		return nil, errors.New("You cannot change your own trading limit")
+	}
+	for _, v := range []*decimal.Decimal{in.MaxCreditsPerTrade, in.MaxDailySpend, in.MaxDailyTransferVolume} {
+		if v != nil && v.IsNegative() {
+			return nil, errors.New("Invalid limit value")
+		}
+	}
+
+	q := s.DB.WithContext(ctx).Where("org_id = ?", in.OrgID)
+	if in.UserID != nil {
+		var member domain.User
+		err := s.DB.WithContext(ctx).Where("user_id = ?", *in.UserID).First(&member).Error
+		if err == nil {
+			_, err = memberships.Get(s.DB.WithContext(ctx), &member, in.OrgID)
+		}
+		if err != nil {
+			if err == gorm.ErrRecordNotFound || err == memberships.ErrNotMember {
+				return nil, errors.New("User not found in organization")
+			}
+			return nil, err
+		}
+		q = q.Where("user_id = ?", *in.UserID)
+	} else {
+		if !constants.IsValidRole(*in.Role) {
+			return nil, errors.New("Invalid role")
+		}
+		q = q.Where("role = ?", *in.Role)
+	}
+
+	var allowed datatypes.JSON
+	if in.AllowedProjectIDs != nil {
+		b, _ := json.Marshal(in.AllowedProjectIDs)
+		allowed = datatypes.JSON(b)
+	}
+
+	var limit domain.TradingLimit
+	if err := q.First(&limit).Error; err != nil && err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+	limit.OrgID = in.OrgID
+	limit.UserID = in.UserID
+	limit.Role = in.Role
+	limit.MaxCreditsPerTrade = in.MaxCreditsPerTrade
+	limit.MaxDailySpend = in.MaxDailySpend
+	limit.MaxDailyTransferVolume = in.MaxDailyTransferVolume
+	limit.AllowedProjectIDs = allowed
+	limit.UpdatedBy = &in.UpdatedBy
+	if err := s.DB.WithContext(ctx).Save(&limit).Error; err != nil {
+		return nil, err
+	}
+	return &limit, nil
+}
+
+// ListLimits returns every limit defined by the org.
+func (s *Service) ListLimits(ctx context.Context, orgID uuid.UUID) ([]domain.TradingLimit, error) {
+	var out []domain.TradingLimit
+	if err := s.DB.WithContext(ctx).Where("org_id = ?", orgID).Order(`"createdAt" ASC`).Find(&out).Error; err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// RemoveLimit deletes one of the org's limits, unless it applies to the acting user (their own or their role's).
+func (s *Service) RemoveLimit(ctx context.Context, orgID, limitID, actorID uuid.UUID, actorRole string) error {
+	var limit domain.TradingLimit
+	if err := s.DB.WithContext(ctx).Where("limit_id = ? AND org_id = ?", limitID, orgID).First(&limit).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return errors.New("Trading limit not found")
+		}
+		return err
+	}
+	if (limit.UserID != nil && *limit.UserID == actorID) || (limit.Role != nil && *limit.Role == actorRole) {
+		return errors.New("You cannot change your own trading limit")
+	}
+	return s.DB.WithContext(ctx).Where("limit_id = ?", limit.LimitID).Delete(&domain.TradingLimit{}).Error
+}
+
+// EffectiveLimit returns the user's own limit, else their role's limit, else nil (unlimited).
+func (s *Service) EffectiveLimit(ctx context.Context, orgID, userID uuid.UUID, role string) (*domain.TradingLimit, error) {
+	return effectiveLimit(s.DB.WithContext(ctx), orgID, userID, role)
+}
+
+func effectiveLimit(db *gorm.DB, orgID, userID uuid.UUID, role string) (*domain.TradingLimit, error) {
+	var rows []domain.TradingLimit
+	if err := db.Where("org_id = ? AND (user_id = ? OR role = ?)", orgID, userID, role).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	var byRole *domain.TradingLimit
+	for i := range rows {
+		if rows[i].UserID != nil {
+			return &rows[i], nil
+		}
+		byRole = &rows[i]
+	}
+	return byRole, nil
+}
+
+type ReserveInput struct {
+	OrgID     uuid.UUID
+	UserID    uuid.UUID
+	Role      string
+	Kind      string
+	ProjectID uuid.UUID
+	ListingID *uuid.UUID // buys: project and price are taken from the listing
+	Amount    decimal.Decimal
+}
+
+// Reserve checks one trade against the actor's effective limit and records it towards today's totals.
+// It returns nil when no limit applies. Callers Release the usage if the trade then fails.
+// The limit row is locked while checking so concurrent trades cannot both squeeze under a daily cap.
+func (s *Service) Reserve(ctx context.Context, in ReserveInput) (*domain.TradingLimitUsage, error) {
+	var usage *domain.TradingLimitUsage
+	err := s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		limit, err := effectiveLimit(tx, in.OrgID, in.UserID, in.Role)
+		if err != nil || limit == nil {
+			return err
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("limit_id = ?", limit.LimitID).First(limit).Error; err != nil {
+			return err
+		}
+
+		projectID := in.ProjectID
+		spend := decimal.Zero
+		if in.ListingID != nil {
+			var listing domain.Listing
+			if err := tx.Where("listing_id = ?", *in.ListingID).First(&listing).Error; err != nil {
+				if err == gorm.ErrRecordNotFound {
+					return errors.New("Listing not found")
+				}
+				return err
+			}
+			projectID = listing.ProjectID
+			spend = in.Amount.Mul(listing.PricePerCredit)
+		}
+
+		if len(limit.AllowedProjectIDs) > 0 {
+			var allowed []uuid.UUID
+			_ = json.Unmarshal(limit.AllowedProjectIDs, &allowed)
+			ok := false
+			for _, id := range allowed {
+				if id == projectID {
+					ok = true
+					break
+				}
+			}
+			if !ok {
+				return errors.New("Project not allowed by your trading limits")
+			}
+		}
+		if limit.MaxCreditsPerTrade != nil && in.Amount.GreaterThan(*limit.MaxCreditsPerTrade) {
+			return errors.New("Amount exceeds your per-trade limit")
+		}
+
+		startOfDay := time.Now().UTC().Truncate(24 * time.Hour)
+		if in.Kind == KindBuy && limit.MaxDailySpend != nil {
+			spent, err := sumSince(tx, in.OrgID, in.UserID, KindBuy, "spend", startOfDay)
+			if err != nil {
+				return err
+			}
+			if spent.Add(spend).GreaterThan(*limit.MaxDailySpend) {
+				return errors.New("Daily spend limit exceeded")
+			}
+		}
+		if in.Kind == KindTransfer && limit.MaxDailyTransferVolume != nil {
+			moved, err := sumSince(tx, in.OrgID, in.UserID, KindTransfer, "amount", startOfDay)
+			if err != nil {
+				return err
+			}
+			if moved.Add(in.Amount).GreaterThan(*limit.MaxDailyTransferVolume) {
+				return errors.New("Daily transfer volume limit exceeded")
+			}
+		}
+
+		usage = &domain.TradingLimitUsage{
+			OrgID:     in.OrgID,
+			UserID:    in.UserID,
+			Kind:      in.Kind,
+			ProjectID: projectID,
+			Amount:    in.Amount,
+			Spend:     spend,
+		}
+		if in.Kind == KindBuy {
+			expires := time.Now().Add(PaymentHold)
+			usage.ExpiresAt = &expires
+		}
+		return tx.Create(usage).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return usage, nil
+}
+
+// Release removes a reservation whose trade did not go through. Safe to call with nil.
+func (s *Service) Release(ctx context.Context, usage *domain.TradingLimitUsage) {
+	if usage == nil {
+		return
+	}
+	s.DB.WithContext(ctx).Where("usage_id = ?", usage.UsageID).Delete(&domain.TradingLimitUsage{})
+}
+
+// ReleaseTx removes a reservation inside the caller's transaction, e.g. when an approval request holding it is
+// rejected or cancelled. Safe to call with nil.
+func ReleaseTx(tx *gorm.DB, usageID *uuid.UUID) error {
+	if usageID == nil {
+		return nil
+	}
+	return tx.Where("usage_id = ?", *usageID).Delete(&domain.TradingLimitUsage{}).Error
+}
+
+// AttachPayment links a buy's usage to its PaymentIntent so the webhook can confirm or release it.
+// Safe to call with a nil receiver or usage.
+func (s *Service) AttachPayment(ctx context.Context, usage *domain.TradingLimitUsage, paymentIntentID string) error {
+	if s == nil || usage == nil {
+		return nil
+	}
+	return s.DB.WithContext(ctx).Model(&domain.TradingLimitUsage{}).Where("usage_id = ?", usage.UsageID).
+		Update("payment_intent_id", paymentIntentID).Error
+}
+
+// WithTx returns a Service writing through tx, so a reservation change commits or rolls back with the trade.
+func (s *Service) WithTx(tx *gorm.DB) *Service {
+	if s == nil {
+		return nil
+	}
+	return &Service{DB: tx}
+}
+
+// ConfirmPayment keeps the usage of a paid buy for good by clearing its expiry.
+func (s *Service) ConfirmPayment(ctx context.Context, paymentIntentID string) error {
+	if s == nil {
+		return nil
+	}
+	return s.DB.WithContext(ctx).Model(&domain.TradingLimitUsage{}).Where("payment_intent_id = ?", paymentIntentID).
+		Update("expires_at", nil).Error
+}
+
+// ReleasePayment removes the usage of a buy whose payment failed, was cancelled or could not be fulfilled.
+func (s *Service) ReleasePayment(ctx context.Context, paymentIntentID string) error {
+	if s == nil {
+		return nil
+	}
+	return s.DB.WithContext(ctx).Where("payment_intent_id = ?", paymentIntentID).Delete(&domain.TradingLimitUsage{}).Error
+}
+
+// sumSince adds up column (amount or spend) of the user's unexpired usages of kind in orgID since t. Limits
+// are set per org, so activity in the user's other orgs does not count.
+func sumSince(tx *gorm.DB, orgID, userID uuid.UUID, kind, column string, t time.Time) (decimal.Decimal, error) {
+	var rows []domain.TradingLimitUsage
+	if err := tx.Where(`org_id = ? AND user_id = ? AND kind = ? AND "createdAt" >= ? AND (expires_at IS NULL OR expires_at > ?)`,
+		orgID, userID, kind, t, time.Now()).Find(&rows).Error; err != nil {
+		return decimal.Zero, err
+	}
+	sum := decimal.Zero
+	for _, r := range rows {
+		if column == "spend" {
+			sum = sum.Add(r.Spend)
+		} else {
+			sum = sum.Add(r.Amount)
+		}
+	}
+	return sum, nil
+}
@@ -5,9 +5,12 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"math"
 
+	"troo-backend/internal/application/holdings"
+	"troo-backend/internal/application/subaccounts"
+	"troo-backend/internal/application/verification"
 	"troo-backend/internal/domain"
+	"troo-backend/internal/pkg/decimal"
 
 	"github.com/google/uuid"
 	"gorm.io/datatypes"
@@ -21,8 +24,8 @@ type Service struct {
 type CreateListingInput struct {
 	ProjectID        uuid.UUID
 	SellerID         *uuid.UUID
-	CreditsAvailable float64
-	PricePerCredit   float64
+	CreditsAvailable decimal.Decimal
+	PricePerCredit   decimal.Decimal
 	ExternalTradeID  *string
 	ProjectName      string
 	ProjectStartYear int
@@ -36,6 +39,7 @@ type CreateListingInput struct {
 	SdgNumbers       string
 	Methodology      string
 	VintageYear      int
+	ActorUserID      *uuid.UUID // platform operator creating the listing
 }
 
 func (s *Service) CreateListing(ctx context.Context, in CreateListingInput) (*domain.Listing, error) {
@@ -46,8 +50,8 @@ func (s *Service) CreateListing(ctx context.Context, in CreateListingInput) (*do
 	listing := &domain.Listing{
 		ProjectID:        in.ProjectID,
 		SellerID:         in.SellerID,
-		CreditsAvailable: in.CreditsAvailable,
-		PricePerCredit:   in.PricePerCredit,
+		CreditsAvailable: in.CreditsAvailable.Round(domain.CreditScale),
+		PricePerCredit:   in.PricePerCredit.Round(domain.CreditScale),
 		ExternalTradeID:  in.ExternalTradeID,
 		ProjectName:      in.ProjectName,
 		ProjectStartYear: in.ProjectStartYear,
@@ -84,6 +88,7 @@ func (s *Service) CreateListing(ctx context.Context, in CreateListingInput) (*do
 		EventType:    "CREATED",
 		EventData:    datatypes.JSON(eventDataBytes),
 		ActorOrgCode: nil,
+		ActorUserID:  in.ActorUserID,
 	}).Error; err != nil {
 		tx.Rollback()
 		return nil, fmt.Errorf("Failed to create listing event: %v", err)
@@ -116,8 +121,8 @@ func (s *Service) GetOrgListings(ctx context.Context, orgID uuid.UUID) ([]domain
 // GetListingByIDResult matches Express getListingByIdService return shape: { listing_id, price_per_credit, credits_available, seller, project }.
 type GetListingByIDResult struct {
 	ListingID        uuid.UUID       `json:"listing_id"`
-	PricePerCredit   float64         `json:"price_per_credit"`
-	CreditsAvailable float64         `json:"credits_available"`
+	PricePerCredit   decimal.Decimal `json:"price_per_credit"`
+	CreditsAvailable decimal.Decimal `json:"credits_available"`
 	Seller           GetListingSeller `json:"seller"`
 	Project          *domain.IcrProject `json:"project"`
 }
@@ -220,8 +225,9 @@ func (s *Service) GetOrgClosedListings(ctx context.Context, orgID uuid.UUID) ([]
 type EditListingInput struct {
 	ListingID   uuid.UUID
 	OrgID       uuid.UUID
-	NewPrice    *float64
-	NewQuantity *float64
+	ActorUserID *uuid.UUID
+	NewPrice    *decimal.Decimal
+	NewQuantity *decimal.Decimal
 }
 
 func (s *Service) EditListing(ctx context.Context, in EditListingInput) (*domain.Listing, error) {
@@ -232,6 +238,7 @@ func (s *Service) EditListing(ctx context.Context, in EditListingInput) (*domain
 		return nil, errors.New("Missing org_id")
 	}
 
+	// Unlocked read for the project (immutable); everything else is re-read under lock below.
 	var listing domain.Listing
 	if err := s.DB.WithContext(ctx).Where("listing_id = ?", in.ListingID).First(&listing).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
@@ -239,124 +246,128 @@ func (s *Service) EditListing(ctx context.Context, in EditListingInput) (*domain
 		}
 		return nil, err
 	}
-	if listing.Status != "open" {
-		return nil, fmt.Errorf("Listing is not editable (status: %q). Only open listings can be edited", listing.Status)
-	}
-	if listing.SellerID == nil {
-		return nil, errors.New("Registry listings cannot be edited by User")
-	}
-	if *listing.SellerID != in.OrgID {
-		return nil, errors.New("Unauthorized listing edit")
-	}
 
-	updates := map[string]interface{}{}
-	eventData := make(map[string]interface{})
-
-	if in.NewPrice != nil {
-		price := *in.NewPrice
-		if math.IsNaN(price) || price <= 0 {
-			return nil, errors.New("Invalid price")
-		}
-		if price != listing.PricePerCredit {
-			updates["price_per_credit"] = price
-			eventData["new_price_per_credit"] = price
+	err := s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Holding before listing (holdings lock order); a missing holding only matters if quantity changes.
+		locked, err := holdings.LockHoldings(tx, listing.ProjectID, in.OrgID)
+		if err != nil {
+			return err
 		}
-	}
-
-	if in.NewQuantity != nil {
-		qty := *in.NewQuantity
-		if math.IsNaN(qty) || qty <= 0 {
-			return nil, errors.New("Invalid quantity")
+		if _, err := subaccounts.Lock(tx, listing.ProjectID, in.OrgID); err != nil {
+			return err
 		}
-		currentQty := listing.CreditsAvailable
-		delta := qty - currentQty
-
-		if delta != 0 {
-			var holding domain.Holding
-			if err := s.DB.WithContext(ctx).Where("org_id = ? AND project_id = ?", in.OrgID, listing.ProjectID).First(&holding).Error; err != nil {
-				if err == gorm.ErrRecordNotFound {
-					return nil, errors.New("Holdings not found")
-				}
-				return nil, err
+		if err := holdings.ForUpdate(tx).Where("listing_id = ?", in.ListingID).First(&listing).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return errors.New("Listing not found")
 			}
+			return err
+		}
+		if listing.Status != "open" {
+			return fmt.Errorf("Listing is not editable (status: %q). Only open listings can be edited", listing.Status)
+		}
+		if listing.SellerID == nil {
+			return errors.New("Registry listings cannot be edited by User")
+		}
+		if *listing.SellerID != in.OrgID {
+			return errors.New("Unauthorized listing edit")
+		}
 
-			locked := holding.LockedForSale
-			balance := holding.CreditBalance
-			available := balance - locked
+		updates := map[string]interface{}{}
+		eventData := make(map[string]interface{})
 
-			if delta > 0 && available < delta {
-				return nil, errors.New("Insufficient credits to increase listing")
+		if in.NewPrice != nil {
+			price := in.NewPrice.Round(domain.CreditScale)
+			if price.Sign() <= 0 {
+				return errors.New("Invalid price")
 			}
-			if delta < 0 && -delta > currentQty {
-				return nil, errors.New("Cannot reduce listing below already sold amount")
+			if !price.Equal(listing.PricePerCredit) {
+				updates["price_per_credit"] = price
+				eventData["new_price_per_credit"] = price
 			}
+		}
 
-			newLocked := locked + delta
-			if newLocked < 0 {
-				return nil, errors.New("Invalid locked_for_sale state")
+		var holding *domain.Holding
+		var delta decimal.Decimal
+		if in.NewQuantity != nil {
+			qty := in.NewQuantity.Round(domain.CreditScale)
+			if qty.Sign() <= 0 {
+				return errors.New("Invalid quantity")
 			}
+			currentQty := listing.CreditsAvailable
+			delta = qty.Sub(currentQty)
 
-			updates["credits_available"] = qty
-			eventData["quantity_delta"] = delta
-			eventData["new_credits_available"] = qty
-		}
-	}
+			if !delta.IsZero() {
+				var ok bool
+				if holding, ok = locked[in.OrgID]; !ok {
+					return errors.New("Holdings not found")
+				}
 
-	if len(updates) == 0 {
-		return nil, errors.New("No valid changes provided")
-	}
+				available, err := subaccounts.Available(tx, holding, listing.SubAccountID)
+				if err != nil {
+					return err
+				}
 
-	tx := s.DB.WithContext(ctx).Begin()
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
+				if delta.IsPositive() && available.LessThan(delta) {
+					return errors.New("Insufficient credits to increase listing")
+				}
+				// Listing more is selling more: only verified orgs may (see verification).
+				if delta.IsPositive() {
+					if err := verification.Require(tx, in.OrgID); err != nil {
+						return err
+					}
+				}
+				if delta.IsNegative() && delta.Neg().GreaterThan(currentQty) {
+					return errors.New("Cannot reduce listing below already sold amount")
+				}
+				if holding.LockedForSale.Add(delta).IsNegative() {
+					return errors.New("Invalid locked_for_sale state")
+				}
+
+				updates["credits_available"] = qty
+				eventData["quantity_delta"] = delta
+				eventData["new_credits_available"] = qty
+			}
 		}
-	}()
-	// Apply holding locked_for_sale change if quantity changed (same tx as listing + event).
-	if qty, ok := updates["credits_available"].(float64); ok {
-		currentQty := listing.CreditsAvailable
-		delta := qty - currentQty
-		var holding domain.Holding
-		if err := tx.Where("org_id = ? AND project_id = ?", in.OrgID, listing.ProjectID).First(&holding).Error; err != nil {
-			tx.Rollback()
-			if err == gorm.ErrRecordNotFound {
-				return nil, errors.New("Holdings not found")
+
+		if len(updates) == 0 {
+			return errors.New("No valid changes provided")
+		}
+
+		// Apply holding locked_for_sale change if quantity changed (same tx as listing + event).
+		if holding != nil {
+			if err := tx.Model(holding).Update("locked_for_sale", holding.LockedForSale.Add(delta)).Error; err != nil {
+				return err
+			}
+			if err := subaccounts.Apply(tx, in.OrgID, listing.SubAccountID, listing.ProjectID, decimal.Zero, delta); err != nil {
+				return err
 			}
-			return nil, err
 		}
-		newLocked := holding.LockedForSale + delta
-		if err := tx.Model(&holding).Update("locked_for_sale", newLocked).Error; err != nil {
-			tx.Rollback()
-			return nil, err
+		if err := tx.Model(&listing).Updates(updates).Error; err != nil {
+			return err
 		}
-	}
-	if err := tx.Model(&listing).Updates(updates).Error; err != nil {
-		tx.Rollback()
-		return nil, err
-	}
-	var org domain.Org
-	if err := tx.Where("org_id = ?", in.OrgID).Select("org_code").First(&org).Error; err != nil {
-		tx.Rollback()
-		return nil, errors.New("Org not found")
-	}
-	eventDataBytes, _ := json.Marshal(eventData)
-	if err := tx.Create(&domain.ListingEvent{
-		ListingID:    listing.ListingID,
-		EventType:    "UPDATED",
-		ActorOrgCode: &org.OrgCode,
-		EventData:    datatypes.JSON(eventDataBytes),
-	}).Error; err != nil {
-		tx.Rollback()
-		return nil, err
-	}
-	if err := tx.Commit().Error; err != nil {
+		var org domain.Org
+		if err := tx.Where("org_id = ?", in.OrgID).Select("org_code").First(&org).Error; err != nil {
+			return errors.New("Org not found")
+		}
+		eventDataBytes, _ := json.Marshal(eventData)
+		return tx.Create(&domain.ListingEvent{
+			ListingID:    listing.ListingID,
+			EventType:    "UPDATED",
+			ActorOrgCode: &org.OrgCode,
+			ActorUserID:  in.ActorUserID,
+			EventData:    datatypes.JSON(eventDataBytes),
+		}).Error
+	})
+	if err != nil {
 		return nil, err
 	}
 	s.DB.WithContext(ctx).Where("listing_id = ?", in.ListingID).First(&listing)
 	return &listing, nil
 }
 
-func (s *Service) CancelListing(ctx context.Context, listingID, orgID uuid.UUID) (*domain.Listing, error) {
+// CancelListing closes the org's open listing and releases its locked credits; actorID is recorded on the event.
+func (s *Service) CancelListing(ctx context.Context, actorID *uuid.UUID, listingID, orgID uuid.UUID) (*domain.Listing, error) {
+	// Unlocked read for the project (immutable); status and quantities are re-read under lock below.
 	var listing domain.Listing
 	if err := s.DB.WithContext(ctx).Where("listing_id = ?", listingID).First(&listing).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
@@ -364,60 +375,64 @@ func (s *Service) CancelListing(ctx context.Context, listingID, orgID uuid.UUID)
 		}
 		return nil, err
 	}
-	if listing.Status != "open" {
-		return nil, errors.New("Listing is not open")
-	}
-	if listing.SellerID == nil {
-		return nil, errors.New("Registry listings cannot be cancelled")
-	}
-	if *listing.SellerID != orgID {
-		return nil, errors.New("Unauthorized")
-	}
 
-	var holding domain.Holding
-	if err := s.DB.WithContext(ctx).Where("org_id = ? AND project_id = ?", orgID, listing.ProjectID).First(&holding).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return nil, errors.New("Holdings not found")
+	err := s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		locked, err := holdings.LockHoldings(tx, listing.ProjectID, orgID)
+		if err != nil {
+			return err
+		}
+		if _, err := subaccounts.Lock(tx, listing.ProjectID, orgID); err != nil {
+			return err
+		}
+		if err := holdings.ForUpdate(tx).Where("listing_id = ?", listingID).First(&listing).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return errors.New("Listing not found")
+			}
+			return err
+		}
+		if listing.Status != "open" {
+			return errors.New("Listing is not open")
+		}
+		if listing.SellerID == nil {
+			return errors.New("Registry listings cannot be cancelled")
+		}
+		if *listing.SellerID != orgID {
+			return errors.New("Unauthorized")
 		}
-		return nil, err
-	}
 
-	newLocked := holding.LockedForSale - listing.CreditsAvailable
-	if newLocked < 0 {
-		return nil, errors.New("Invalid locked state")
-	}
+		holding, ok := locked[orgID]
+		if !ok {
+			return errors.New("Holdings not found")
+		}
+		newLocked := holding.LockedForSale.Sub(listing.CreditsAvailable)
+		if newLocked.IsNegative() {
+			return errors.New("Invalid locked state")
+		}
 
-	tx := s.DB.WithContext(ctx).Begin()
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
+		if err := tx.Model(holding).Update("locked_for_sale", newLocked).Error; err != nil {
+			return err
 		}
-	}()
-	if err := tx.Model(&holding).Update("locked_for_sale", newLocked).Error; err != nil {
-		tx.Rollback()
-		return nil, err
-	}
-	listing.Status = "closed"
-	if err := tx.Save(&listing).Error; err != nil {
-		tx.Rollback()
-		return nil, err
-	}
-	var org domain.Org
-	if err := tx.Where("org_id = ?", orgID).Select("org_code").First(&org).Error; err != nil {
-		tx.Rollback()
-		return nil, errors.New("Org not found")
-	}
-	eventDataBytes, _ := json.Marshal(map[string]interface{}{"remaining_credits": listing.CreditsAvailable})
-	if err := tx.Create(&domain.ListingEvent{
-		ListingID:    listing.ListingID,
-		EventType:    "CANCELLED",
-		ActorOrgCode: &org.OrgCode,
-		EventData:    datatypes.JSON(eventDataBytes),
-	}).Error; err != nil {
-		tx.Rollback()
-		return nil, err
-	}
-	if err := tx.Commit().Error; err != nil {
+		if err := subaccounts.Apply(tx, orgID, listing.SubAccountID, listing.ProjectID, decimal.Zero, listing.CreditsAvailable.Neg()); err != nil {
+			return err
+		}
+		listing.Status = "closed"
+		if err := tx.Save(&listing).Error; err != nil {
+			return err
+		}
+		var org domain.Org
+		if err := tx.Where("org_id = ?", orgID).Select("org_code").First(&org).Error; err != nil {
+			return errors.New("Org not found")
+		}
+		eventDataBytes, _ := json.Marshal(map[string]interface{}{"remaining_credits": listing.CreditsAvailable})
+		return tx.Create(&domain.ListingEvent{
+			ListingID:    listing.ListingID,
+			EventType:    "CANCELLED",
+			ActorOrgCode: &org.OrgCode,
+			ActorUserID:  actorID,
+			EventData:    datatypes.JSON(eventDataBytes),
+		}).Error
+	})
+	if err != nil {
 		return nil, err
 	}
 	return &listing, nil
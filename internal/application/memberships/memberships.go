@@ -0,0 +1,183 @@
+// Package memberships keeps users' org memberships. A user may belong to several orgs, each with its own
+// role; the Users row's org_id, role and custom_role_id mirror their home org, which password sign-ins start
+// in and the Express API reads. The org a session works in is kept on the session (see Enter), so switching
+// org in one session leaves the user's other sessions where they are.
+package memberships
+
+import (
+	"errors"
+
+	"troo-backend/internal/domain"
+	"troo-backend/internal/pkg/constants"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrNotMember = errors.New("You are not a member of this organization")
+
+// Get returns the user's membership of orgID. A Users row whose org_id was set without a membership
+// (by Express, before the next migration backfills it) counts as a membership with that row's role.
+func Get(db *gorm.DB, user *domain.User, orgID uuid.UUID) (*domain.OrgMembership, error) {
+	var m domain.OrgMembership
+	err := db.Where("user_id = ? AND org_id = ?", user.UserID, orgID).First(&m).Error
+	if err == nil {
+		return &m, nil
+	}
+	if err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+	if user.OrgID != nil && *user.OrgID == orgID {
+		return active(user), nil
+	}
+	return nil, ErrNotMember
+}
+
+// List returns the user's memberships, oldest first.
+func List(db *gorm.DB, user *domain.User) ([]domain.OrgMembership, error) {
+	var out []domain.OrgMembership
+	if err := db.Where("user_id = ?", user.UserID).Order(`"createdAt" ASC, org_id`).Find(&out).Error; err != nil {
+		return nil, err
+	}
+	if user.OrgID == nil {
+		return out, nil
+	}
+	for _, m := range out {
+		if m.OrgID == *user.OrgID {
+			return out, nil
+		}
+	}
+	return append(out, *active(user)), nil
+}
+
+// Members is a subquery of the org's memberships (user_id, org_id, role, custom_role_id, "createdAt"), counting
+// a Users row that names the org without a membership row as one, as Get does.
+func Members(db *gorm.DB, orgID uuid.UUID) *gorm.DB {
+	return db.Session(&gorm.Session{NewDB: true}).Raw(`SELECT user_id, org_id, role, custom_role_id, "createdAt" FROM "OrgMemberships" WHERE org_id = ?
+		UNION ALL
+		SELECT user_id, org_id, role, custom_role_id, "createdAt" FROM "Users" u WHERE u.org_id = ? AND NOT EXISTS (
+			SELECT 1 FROM "OrgMemberships" m WHERE m.user_id = u.user_id AND m.org_id = u.org_id
+		)`, orgID, orgID)
+}
+
+// CountRole counts the org's members holding the built-in role.
+func CountRole(db *gorm.DB, orgID uuid.UUID, role string) (int64, error) {
+	var count int64
+	err := db.Table("(?) AS m", Members(db, orgID)).Where("m.role = ?", role).Count(&count).Error
+	return count, err
+}
+
+// Add makes the user a member of orgID with the role, or changes their role there if they already are.
+// The org becomes their home org when they have none.
+func Add(db *gorm.DB, user *domain.User, orgID uuid.UUID, role string, customRoleID *uuid.UUID) error {
+	if user.OrgID == nil {
+		user.OrgID = &orgID
+	}
+	return SetRole(db, user, orgID, role, customRoleID)
+}
+
+// Join adds the user to orgID and makes it their home org, as when they accept an invitation.
+func Join(db *gorm.DB, user *domain.User, orgID uuid.UUID, role string, customRoleID *uuid.UUID) error {
+	if err := keepActive(db, user); err != nil {
+		return err
+	}
+	user.OrgID = &orgID
+	return SetRole(db, user, orgID, role, customRoleID)
+}
+
+// SetRole stores the user's role in orgID, creating the membership if needed, and mirrors it onto the
+// Users row when orgID is their home org.
+func SetRole(db *gorm.DB, user *domain.User, orgID uuid.UUID, role string, customRoleID *uuid.UUID) error {
+	m := domain.OrgMembership{UserID: user.UserID, OrgID: orgID, Role: role, CustomRoleID: customRoleID}
+	res := db.Model(&m).Select("role", "custom_role_id").Updates(&m)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		if err := db.Create(&m).Error; err != nil {
+			return err
+		}
+	}
+	if user.OrgID == nil || *user.OrgID != orgID {
+		return nil
+	}
+	return activate(db, user, &m)
+}
+
+// Enter points user, in memory only, at their membership of orgID: org_id, role and custom role (loaded) are
+// the ones they hold there. The Users row is not written. They must be a member of orgID.
+func Enter(db *gorm.DB, user *domain.User, orgID uuid.UUID) (*domain.OrgMembership, error) {
+	m, err := Get(db, user, orgID)
+	if err != nil {
+		return nil, err
+	}
+	var role *domain.CustomRole
+	if m.CustomRoleID != nil {
+		role = &domain.CustomRole{}
+		if err := db.Where("role_id = ?", *m.CustomRoleID).First(role).Error; err != nil {
+			return nil, err
+		}
+	}
+	user.OrgID = &orgID
+	user.Role = m.Role
+	user.CustomRoleID = m.CustomRoleID
+	user.CustomRole = role
+	return m, nil
+}
+
+// Remove takes the user out of orgID. If it was their home org they move to their oldest remaining
+// membership, or to no org as a viewer when they have none.
+func Remove(db *gorm.DB, user *domain.User, orgID uuid.UUID) error {
+	if err := db.Where("user_id = ? AND org_id = ?", user.UserID, orgID).Delete(&domain.OrgMembership{}).Error; err != nil {
+		return err
+	}
+	if user.OrgID == nil || *user.OrgID != orgID {
+		return nil
+	}
+	var next domain.OrgMembership
+	err := db.Where("user_id = ?", user.UserID).Order(`"createdAt" ASC, org_id`).First(&next).Error
+	if err == gorm.ErrRecordNotFound {
+		return activate(db, user, &domain.OrgMembership{Role: constants.Viewer})
+	}
+	if err != nil {
+		return err
+	}
+	return activate(db, user, &next)
+}
+
+// activate copies m onto the Users row; a zero OrgID leaves the user without an org.
+func activate(db *gorm.DB, user *domain.User, m *domain.OrgMembership) error {
+	user.OrgID = nil
+	if m.OrgID != uuid.Nil {
+		orgID := m.OrgID
+		user.OrgID = &orgID
+	}
+	user.Role = m.Role
+	user.CustomRoleID = m.CustomRoleID
+	user.CustomRole = nil
+	return db.Model(user).Select("org_id", "role", "custom_role_id").Updates(user).Error
+}
+
+// keepActive writes the membership row of the user's home org if it has none (see Get), so that it
+// survives their moving to another org.
+func keepActive(db *gorm.DB, user *domain.User) error {
+	if user.OrgID == nil {
+		return nil
+	}
+	m := active(user)
+	m.CustomRole = nil
+	return db.Clauses(clause.OnConflict{DoNothing: true}).Create(m).Error
+}
+
+func active(user *domain.User) *domain.OrgMembership {
+	return &domain.OrgMembership{
+		UserID:       user.UserID,
+		OrgID:        *user.OrgID,
+		Role:         user.Role,
+		CustomRoleID: user.CustomRoleID,
+		CustomRole:   user.CustomRole,
+		CreatedAt:    user.CreatedAt,
+		UpdatedAt:    user.UpdatedAt,
+	}
+}
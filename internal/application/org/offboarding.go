@@ -0,0 +1,271 @@
+package org
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"troo-backend/internal/application/approvals"
+	"troo-backend/internal/application/limits"
+	"troo-backend/internal/application/memberships"
+	policies "troo-backend/internal/application/policies/user"
+	"troo-backend/internal/application/trading"
+	"troo-backend/internal/domain"
+	"troo-backend/internal/pkg/decimal"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrOrgClosed      = errors.New("Organization is closed")
+	ErrNotConfirmed   = errors.New("confirm_org_code must match the organization's code")
+	ErrOpenListings   = errors.New("Cancel all open listings before closing the organization")
+	ErrHoldingsRemain = errors.New("Transfer out or retire all credits before closing the organization")
+	ErrNeedsApproval  = errors.New("Transferring these credits out needs approval: transfer them out and have the transfers approved before closing the organization")
+)
+
+// CloseOrgInput is an org superadmin's request to offboard their org.
+type CloseOrgInput struct {
+	OrgID       uuid.UUID
+	ActorUserID uuid.UUID
+	// ActorRole is the actor's role in the org, for the trading limits the transfer-out is held to.
+	ActorRole string
+	// ConfirmOrgCode must repeat the org's code, so an org is not closed by a stray request.
+	ConfirmOrgCode string
+	// TransferToOrgCode receives the org's remaining credits; without it the org must hold none.
+	TransferToOrgCode *string
+	// AnonymiseMembers scrubs the personal details of members who are left without an org; others are only detached.
+	AnonymiseMembers bool
+}
+
+// ClosedTransfer is one transfer-out made while closing the org.
+type ClosedTransfer struct {
+	ProjectID    uuid.UUID       `json:"project_id"`
+	SubAccountID *uuid.UUID      `json:"sub_account_id"`
+	Amount       decimal.Decimal `json:"amount"`
+}
+
+// CloseOrgResult reports what closing the org did.
+type CloseOrgResult struct {
+	Org                *domain.Org      `json:"org"`
+	Transfers          []ClosedTransfer `json:"transfers"`
+	MembersDetached    int              `json:"members_detached"`
+	MembersAnonymised  int              `json:"members_anonymised"`
+	InvitationsRevoked int64            `json:"invitations_revoked"`
+	APIKeysRevoked     int64            `json:"api_keys_revoked"`
+	ApprovalsCancelled int64            `json:"approvals_cancelled"`
+}
+
+// CloseOrg offboards the org. It must have no open listings, and either no credits or a target org to
+// transfer them to (as ordinary transfers, so the org must be verified, and held to the actor's trading limits
+// and the org's approval policy). Members are detached (moving to another of their orgs, or to none) and
+// signed out; pending approval requests are cancelled, and pending invitations, API keys, the SCIM token and
+// SSO are turned off. The Orgs row, holdings, listings, transactions, payments and certificates are kept
+// unchanged for retention; the org is only marked closed.
+func (s *Service) CloseOrg(ctx context.Context, in CloseOrgInput) (*CloseOrgResult, error) {
+	out := &CloseOrgResult{Transfers: []ClosedTransfer{}}
+	var signOut []uuid.UUID
+	err := s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var org domain.Org
+		if err := tx.Where("org_id = ?", in.OrgID).First(&org).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return errors.New("Org not found")
+			}
+			return err
+		}
+		if org.ClosedAt != nil {
+			return ErrOrgClosed
+		}
+		if strings.TrimSpace(in.ConfirmOrgCode) != org.OrgCode {
+			return ErrNotConfirmed
+		}
+
+		var open int64
+		if err := tx.Model(&domain.Listing{}).Where("seller_id = ? AND status = ?", in.OrgID, "open").Count(&open).Error; err != nil {
+			return err
+		}
+		if open > 0 {
+			return ErrOpenListings
+		}
+		// Before the transfer-out: requests are locked ahead of holdings, as Approve does, and their
+		// reservations stop counting towards the actor's limits.
+		cancelled, err := approvals.CancelPending(tx, in.OrgID, in.ActorUserID)
+		if err != nil {
+			return err
+		}
+		out.ApprovalsCancelled = cancelled
+		transfers, err := transferOut(ctx, tx, in)
+		if err != nil {
+			return err
+		}
+		out.Transfers = transfers
+
+		now := time.Now()
+		res := tx.Model(&domain.Invitation{}).Where("org_id = ? AND status = ?", in.OrgID, "pending").Update("status", "revoked")
+		if res.Error != nil {
+			return res.Error
+		}
+		out.InvitationsRevoked = res.RowsAffected
+		res = tx.Model(&domain.OrgAPIKey{}).Where("org_id = ? AND revoked_at IS NULL", in.OrgID).Update("revoked_at", now)
+		if res.Error != nil {
+			return res.Error
+		}
+		out.APIKeysRevoked = res.RowsAffected
+		if err := tx.Where("org_id = ?", in.OrgID).Delete(&domain.OrgSCIMToken{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&domain.OrgSSOConfig{}).Where("org_id = ?", in.OrgID).Update("enabled", false).Error; err != nil {
+			return err
+		}
+
+		var memberIDs []uuid.UUID
+		if err := tx.Table("(?) AS m", memberships.Members(tx, in.OrgID)).Pluck("m.user_id", &memberIDs).Error; err != nil {
+			return err
+		}
+		for _, id := range memberIDs {
+			var u domain.User
+			if err := tx.Where("user_id = ?", id).First(&u).Error; err != nil {
+				return err
+			}
+			if err := memberships.Remove(tx, &u, in.OrgID); err != nil {
+				return err
+			}
+			out.MembersDetached++
+			signOut = append(signOut, id)
+			if !in.AnonymiseMembers || u.OrgID != nil {
+				continue
+			}
+			anonymised, err := anonymise(tx, &u)
+			if err != nil {
+				return err
+			}
+			if anonymised {
+				out.MembersAnonymised++
+			}
+		}
+
+		// The Org row is locked last (see holdings lock order); a concurrent close that got here first wins.
+		res = tx.Model(&domain.Org{}).Where("org_id = ? AND closed_at IS NULL", in.OrgID).
+			Updates(map[string]interface{}{"closed_at": now, "closed_by": in.ActorUserID})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrOrgClosed
+		}
+		org.ClosedAt = &now
+		org.ClosedBy = &in.ActorUserID
+		out.Org = &org
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if s.Rdb != nil {
+		for _, id := range signOut {
+			policies.DestroyUserSessions(ctx, s.Rdb, id.String())
+		}
+	}
+	return out, nil
+}
+
+// transferOut moves every credit the org holds to in.TransferToOrgCode, each sub-account's first and then the
+// main account's, through the trading service so each move is an ordinary transfer transaction. Each move is
+// reserved against the actor's trading limits; one the org's approval policy would hold is ErrNeedsApproval,
+// as closing cannot wait for a second approver.
+func transferOut(ctx context.Context, tx *gorm.DB, in CloseOrgInput) ([]ClosedTransfer, error) {
+	var held []domain.Holding
+	if err := tx.Where("org_id = ? AND credit_balance > 0", in.OrgID).Order("project_id").Find(&held).Error; err != nil {
+		return nil, err
+	}
+	out := []ClosedTransfer{}
+	if len(held) == 0 {
+		return out, nil
+	}
+	if in.TransferToOrgCode == nil || *in.TransferToOrgCode == "" {
+		return nil, ErrHoldingsRemain
+	}
+	ts := &trading.Service{DB: tx}
+	as := &approvals.Service{DB: tx}
+	ls := &limits.Service{DB: tx}
+	move := func(projectID uuid.UUID, subAccountID *uuid.UUID, amount decimal.Decimal) error {
+		if amount.Sign() <= 0 {
+			return nil
+		}
+		held, err := as.Requires(ctx, in.OrgID, approvals.TypeTransfer, amount)
+		if err != nil {
+			return err
+		}
+		if held {
+			return ErrNeedsApproval
+		}
+		if _, err := ls.Reserve(ctx, limits.ReserveInput{
+			OrgID: in.OrgID, UserID: in.ActorUserID, Role: in.ActorRole, Kind: limits.KindTransfer, ProjectID: projectID, Amount: amount,
+		}); err != nil {
+			return err
+		}
+		if _, err := ts.TransferCredits(ctx, &in.ActorUserID, in.OrgID, subAccountID, projectID, *in.TransferToOrgCode, amount); err != nil {
+			return err
+		}
+		out = append(out, ClosedTransfer{ProjectID: projectID, SubAccountID: subAccountID, Amount: amount})
+		return nil
+	}
+	for _, h := range held {
+		var subs []domain.SubAccountHolding
+		if err := tx.Where("org_id = ? AND project_id = ? AND credit_balance > 0", in.OrgID, h.ProjectID).Order("sub_account_id").Find(&subs).Error; err != nil {
+			return nil, err
+		}
+		for _, sub := range subs {
+			id := sub.SubAccountID
+			if err := move(h.ProjectID, &id, sub.CreditBalance.Sub(sub.LockedForSale)); err != nil {
+				return nil, err
+			}
+		}
+		var rest domain.Holding
+		if err := tx.Where("org_id = ? AND project_id = ?", in.OrgID, h.ProjectID).First(&rest).Error; err != nil {
+			return nil, err
+		}
+		if err := move(h.ProjectID, nil, rest.CreditBalance.Sub(rest.LockedForSale)); err != nil {
+			return nil, err
+		}
+	}
+	var remaining int64
+	if err := tx.Model(&domain.Holding{}).Where("org_id = ? AND credit_balance > 0", in.OrgID).Count(&remaining).Error; err != nil {
+		return nil, err
+	}
+	if remaining > 0 {
+		return nil, ErrHoldingsRemain
+	}
+	return out, nil
+}
+
+// anonymise replaces the personal details of a user who no longer belongs to any org and removes their
+// credentials and tokens. The Users row stays, so transactions and audit entries naming them still resolve.
+// Platform operators are left as they are.
+func anonymise(tx *gorm.DB, u *domain.User) (bool, error) {
+	var operators int64
+	if err := tx.Model(&domain.PlatformOperator{}).Where("user_id = ?", u.UserID).Count(&operators).Error; err != nil {
+		return false, err
+	}
+	if operators > 0 {
+		return false, nil
+	}
+	id := u.UserID.String()
+	if err := tx.Model(u).Updates(map[string]interface{}{
+		"fullname":       "Deleted user",
+		"user_name":      "deleted-" + id[:8],
+		"email":          "deleted+" + id + "@users.invalid",
+		"email_verified": false,
+		"password_hash":  "!",
+	}).Error; err != nil {
+		return false, err
+	}
+	for _, model := range []interface{}{&domain.UserTwoFactor{}, &domain.UserSSOIdentity{}, &domain.EmailVerificationToken{}, &domain.PasswordResetToken{}} {
+		if err := tx.Where("user_id = ?", u.UserID).Delete(model).Error; err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
@@ -6,16 +6,21 @@ import (
 	"regexp"
 	"strings"
 
+	"troo-backend/internal/application/memberships"
+	policies "troo-backend/internal/application/policies/user"
+	"troo-backend/internal/application/verification"
 	"troo-backend/internal/domain"
 	"troo-backend/internal/pkg/constants"
 
 	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
 	"gorm.io/gorm"
 )
 
 // Service encapsulates org-related operations.
 type Service struct {
-	DB *gorm.DB
+	DB  *gorm.DB
+	Rdb *redis.Client // signs members out when the security policy changes
 }
 
 // CreateOrgInput mirrors Express createOrgService payload.
@@ -69,17 +74,19 @@ func (s *Service) CreateOrg(ctx context.Context, in CreateOrgInput, userID uuid.
 		org.IncorporationDocURL = in.IncorporationDocURL
 	}
 
-	if err := s.DB.WithContext(ctx).Create(org).Error; err != nil {
-		return nil, err
-	}
-
-	// Attach creator to org and make superadmin (Express: User.update({ org_id, role: 'superadmin' }, { where: { user_id } }))
-	if err := s.DB.WithContext(ctx).Model(&domain.User{}).
-		Where("user_id = ?", userID).
-		Updates(map[string]interface{}{
-			"org_id": org.OrgID,
-			"role":   constants.Superadmin,
-		}).Error; err != nil {
+	err := s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(org).Error; err != nil {
+			return err
+		}
+		// Attach creator to org as superadmin and make it their active org (Express: User.update({ org_id, role: 'superadmin' }));
+		// orgs they already belong to stay in their memberships
+		var user domain.User
+		if err := tx.Where("user_id = ?", userID).First(&user).Error; err != nil {
+			return err
+		}
+		return memberships.Join(tx, &user, org.OrgID, constants.Superadmin, nil)
+	})
+	if err != nil {
 		return nil, err
 	}
 
@@ -107,11 +114,12 @@ func (s *Service) GetOrgByID(ctx context.Context, orgID uuid.UUID) (map[string]i
 		Role      string    `json:"role"`
 		CreatedAt string    `json:"createdAt"`
 	}
+	// Employees are the org's members with their role in it, whichever org they are working in
 	if err := s.DB.WithContext(ctx).
-		Model(&domain.User{}).
-		Select(`user_id, fullname, email, user_name, role, "createdAt"`).
-		Where("org_id = ?", orgID).
-		Order(`"createdAt" ASC`).
+		Table("(?) AS m", memberships.Members(s.DB, orgID)).
+		Joins(`JOIN "Users" u ON u.user_id = m.user_id`).
+		Select(`u.user_id, u.fullname, u.email, u.user_name, m.role, u."createdAt"`).
+		Order(`u."createdAt" ASC`).
 		Scan(&employees).Error; err != nil {
 		return nil, err
 	}
@@ -124,6 +132,7 @@ func (s *Service) GetOrgByID(ctx context.Context, orgID uuid.UUID) (map[string]i
 		"registration_id":      org.RegistrationID,
 		"logo_url":             org.LogoURL,
 		"incorporation_doc_url": org.IncorporationDocURL,
+		"require_two_factor":   org.RequireTwoFactor,
 		"createdAt":            org.CreatedAt,
 		"updatedAt":            org.UpdatedAt,
 		"employees":            employees,
@@ -167,6 +176,64 @@ func (s *Service) UpdateOrg(ctx context.Context, orgID uuid.UUID, fields map[str
 		return nil, errors.New("Org not found")
 	}
 
+	var org domain.Org
+	if err := s.DB.WithContext(ctx).Where("org_id = ?", orgID).First(&org).Error; err != nil {
+		return nil, err
+	}
+	// New registration details must be reviewed again before the org can trade.
+	if err := verification.Revoke(s.DB.WithContext(ctx), &org); err != nil {
+		return nil, err
+	}
+	return &org, nil
+}
+
+// UpdatePublicProfile sets whether the org's retirement totals and certificates appear on its public profile.
+func (s *Service) UpdatePublicProfile(ctx context.Context, orgID uuid.UUID, publicRetirements bool) (*domain.Org, error) {
+	if orgID == uuid.Nil {
+		return nil, errors.New("Missing org_id")
+	}
+	result := s.DB.WithContext(ctx).Model(&domain.Org{}).
+		Where("org_id = ?", orgID).
+		Update("public_retirements", publicRetirements)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, errors.New("Org not found")
+	}
+	var org domain.Org
+	if err := s.DB.WithContext(ctx).Where("org_id = ?", orgID).First(&org).Error; err != nil {
+		return nil, err
+	}
+	return &org, nil
+}
+
+// UpdateSecurityPolicy sets whether members need two-factor authentication for trading permissions.
+// Every member is signed out so their next session picks up the policy; callers restore their own session.
+func (s *Service) UpdateSecurityPolicy(ctx context.Context, orgID uuid.UUID, requireTwoFactor bool) (*domain.Org, error) {
+	if orgID == uuid.Nil {
+		return nil, errors.New("Missing org_id")
+	}
+	result := s.DB.WithContext(ctx).Model(&domain.Org{}).
+		Where("org_id = ?", orgID).
+		Update("require_two_factor", requireTwoFactor)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, errors.New("Org not found")
+	}
+
+	if s.Rdb != nil {
+		var memberIDs []uuid.UUID
+		if err := s.DB.WithContext(ctx).Model(&domain.User{}).Where("org_id = ?", orgID).Pluck("user_id", &memberIDs).Error; err != nil {
+			return nil, err
+		}
+		for _, id := range memberIDs {
+			policies.DestroyUserSessions(ctx, s.Rdb, id.String())
+		}
+	}
+
 	var org domain.Org
 	if err := s.DB.WithContext(ctx).Where("org_id = ?", orgID).First(&org).Error; err != nil {
 		return nil, err
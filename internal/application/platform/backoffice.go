@@ -0,0 +1,228 @@
+package platform
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"troo-backend/internal/application/holdings"
+	"troo-backend/internal/application/subaccounts"
+	"troo-backend/internal/domain"
+	"troo-backend/internal/pkg/decimal"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+const searchLimit = 50
+
+// UserResult is one row of a back-office user search.
+type UserResult struct {
+	UserID   uuid.UUID  `json:"user_id"`
+	Fullname string     `json:"fullname"`
+	UserName string     `json:"user_name"`
+	Email    string     `json:"email"`
+	Role     string     `json:"role"`
+	OrgID    *uuid.UUID `json:"org_id"`
+	OrgName  *string    `json:"org_name"`
+	OrgCode  *string    `json:"org_code"`
+}
+
+// SearchUsers matches q against email, full name and user name (case-insensitive), at most 50 rows.
+func (s *Service) SearchUsers(ctx context.Context, q string) ([]UserResult, error) {
+	pattern := likePattern(q)
+	if pattern == "" {
+		return nil, errors.New("Search query is required")
+	}
+	out := []UserResult{}
+	err := s.DB.WithContext(ctx).Table(`"Users" AS u`).
+		Select(`u.user_id, u.fullname, u.user_name, u.email, u.role, u.org_id, o.org_name, o.org_code`).
+		Joins(`LEFT JOIN "Orgs" AS o ON o.org_id = u.org_id`).
+		Where(`LOWER(u.email) LIKE ? ESCAPE '\' OR LOWER(u.fullname) LIKE ? ESCAPE '\' OR LOWER(u.user_name) LIKE ? ESCAPE '\'`, pattern, pattern, pattern).
+		Order("u.email ASC").
+		Limit(searchLimit).
+		Scan(&out).Error
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// SearchOrgs matches q against org name and org code (case-insensitive), at most 50 rows.
+func (s *Service) SearchOrgs(ctx context.Context, q string) ([]domain.Org, error) {
+	pattern := likePattern(q)
+	if pattern == "" {
+		return nil, errors.New("Search query is required")
+	}
+	out := []domain.Org{}
+	if err := s.DB.WithContext(ctx).
+		Where(`LOWER(org_name) LIKE ? ESCAPE '\' OR LOWER(org_code) LIKE ? ESCAPE '\'`, pattern, pattern).
+		Order("org_name ASC").
+		Limit(searchLimit).
+		Find(&out).Error; err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// FindOrg returns the org or "Organization not found".
+func (s *Service) FindOrg(ctx context.Context, orgID uuid.UUID) (*domain.Org, error) {
+	var org domain.Org
+	if err := s.DB.WithContext(ctx).Where("org_id = ?", orgID).First(&org).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, errors.New("Organization not found")
+		}
+		return nil, err
+	}
+	return &org, nil
+}
+
+// ForceCloseListing closes any open listing regardless of seller, releasing the seller's locked credits.
+// The CANCELLED listing event carries the operator and reason.
+func (s *Service) ForceCloseListing(ctx context.Context, actorID, listingID uuid.UUID, reason string) (*domain.Listing, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, errors.New("Reason is required")
+	}
+	var listing domain.Listing
+	if err := s.DB.WithContext(ctx).Where("listing_id = ?", listingID).First(&listing).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, errors.New("Listing not found")
+		}
+		return nil, err
+	}
+
+	err := s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var locked map[uuid.UUID]*domain.Holding
+		if listing.SellerID != nil {
+			var err error
+			if locked, err = holdings.LockHoldings(tx, listing.ProjectID, *listing.SellerID); err != nil {
+				return err
+			}
+			if _, err := subaccounts.Lock(tx, listing.ProjectID, *listing.SellerID); err != nil {
+				return err
+			}
+		}
+		if err := holdings.ForUpdate(tx).Where("listing_id = ?", listingID).First(&listing).Error; err != nil {
+			return err
+		}
+		if listing.Status != "open" {
+			return errors.New("Listing is not open")
+		}
+		if listing.SellerID != nil {
+			// A seller holding that does not cover the listing is a ledger inconsistency to fix with an adjustment
+			// first, not something to paper over by closing the listing.
+			h, ok := locked[*listing.SellerID]
+			if !ok || h.LockedForSale.LessThan(listing.CreditsAvailable) {
+				return errors.New("Seller holding does not cover the listing")
+			}
+			if err := tx.Model(h).Update("locked_for_sale", h.LockedForSale.Sub(listing.CreditsAvailable)).Error; err != nil {
+				return err
+			}
+			if err := subaccounts.Apply(tx, *listing.SellerID, listing.SubAccountID, listing.ProjectID, decimal.Zero, listing.CreditsAvailable.Neg()); err != nil {
+				return err
+			}
+		}
+		listing.Status = "closed"
+		if err := tx.Save(&listing).Error; err != nil {
+			return err
+		}
+		eventData, _ := json.Marshal(map[string]interface{}{
+			"remaining_credits": listing.CreditsAvailable,
+			"forced_by":         actorID,
+			"reason":            reason,
+		})
+		return tx.Create(&domain.ListingEvent{
+			ListingID:   listing.ListingID,
+			EventType:   "CANCELLED",
+			EventData:   datatypes.JSON(eventData),
+			ActorUserID: &actorID,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &listing, nil
+}
+
+type AdjustHoldingInput struct {
+	ActorID   uuid.UUID
+	OrgID     uuid.UUID
+	ProjectID uuid.UUID
+	Delta     decimal.Decimal
+	Reason    string
+}
+
+// AdjustHolding adds Delta (may be negative) to an org's balance for a project and records the adjustment.
+// The balance may not drop below zero or below the credits locked in open listings. Adjustments go to the org's
+// main account, so they cannot take credits its sub-accounts hold.
+func (s *Service) AdjustHolding(ctx context.Context, in AdjustHoldingInput) (*domain.HoldingAdjustment, error) {
+	in.Reason = strings.TrimSpace(in.Reason)
+	if in.Reason == "" {
+		return nil, errors.New("Reason is required")
+	}
+	if in.Delta.IsZero() {
+		return nil, errors.New("Invalid adjustment amount")
+	}
+	if _, err := s.FindOrg(ctx, in.OrgID); err != nil {
+		return nil, err
+	}
+
+	var adj *domain.HoldingAdjustment
+	err := s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		locked, err := holdings.LockHoldings(tx, in.ProjectID, in.OrgID)
+		if err != nil {
+			return err
+		}
+		before, lockedForSale := decimal.Zero, decimal.Zero
+		if h, ok := locked[in.OrgID]; ok {
+			before, lockedForSale = h.CreditBalance, h.LockedForSale
+		}
+		main, err := subaccounts.Available(tx, locked[in.OrgID], nil)
+		if err != nil {
+			return err
+		}
+		after := before.Add(in.Delta)
+		if after.IsNegative() || after.LessThan(lockedForSale) || main.Add(in.Delta).IsNegative() {
+			return errors.New("Adjustment would leave balance below locked credits")
+		}
+		if _, err := holdings.Credit(tx, in.OrgID, in.ProjectID, in.Delta); err != nil {
+			return err
+		}
+		adj = &domain.HoldingAdjustment{
+			OrgID:         in.OrgID,
+			ProjectID:     in.ProjectID,
+			Delta:         in.Delta,
+			BalanceBefore: before,
+			BalanceAfter:  after,
+			Reason:        in.Reason,
+			AdjustedBy:    in.ActorID,
+		}
+		return tx.Create(adj).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return adj, nil
+}
+
+// ListAdjustments returns an org's holding adjustments, newest first.
+func (s *Service) ListAdjustments(ctx context.Context, orgID uuid.UUID) ([]domain.HoldingAdjustment, error) {
+	out := []domain.HoldingAdjustment{}
+	if err := s.DB.WithContext(ctx).Where("org_id = ?", orgID).Order(`"createdAt" DESC`).Find(&out).Error; err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// likePattern lowercases q and escapes LIKE wildcards; "" when q is blank.
+func likePattern(q string) string {
+	q = strings.ToLower(strings.TrimSpace(q))
+	if q == "" {
+		return ""
+	}
+	q = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(q)
+	return "%" + q + "%"
+}
@@ -0,0 +1,130 @@
+package platform
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	policies "troo-backend/internal/application/policies/user"
+	"troo-backend/internal/domain"
+	"troo-backend/internal/pkg/constants"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Service backs the /api/v1/platform back-office routes. Platform roles are snapshotted into the session
+// at login, so granting or revoking one destroys the user's sessions.
+type Service struct {
+	DB  *gorm.DB
+	Rdb *redis.Client
+}
+
+// OperatorView is a platform operator with the user's identity.
+type OperatorView struct {
+	UserID    uuid.UUID  `json:"user_id"`
+	Fullname  string     `json:"fullname"`
+	Email     string     `json:"email"`
+	Role      string     `json:"role"`
+	GrantedBy *uuid.UUID `json:"granted_by"`
+}
+
+// ListOperators returns every platform operator.
+func (s *Service) ListOperators(ctx context.Context) ([]OperatorView, error) {
+	var out []OperatorView
+	err := s.DB.WithContext(ctx).Table(`"PlatformOperators" AS p`).
+		Select(`p.user_id, u.fullname, u.email, p.role, p.granted_by`).
+		Joins(`JOIN "Users" AS u ON u.user_id = p.user_id`).
+		Order("u.email ASC").
+		Scan(&out).Error
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GrantOperator gives the user with email the platform role, replacing any role they already hold.
+func (s *Service) GrantOperator(ctx context.Context, actorID uuid.UUID, email, role string) (*OperatorView, error) {
+	if !constants.IsValidPlatformRole(role) {
+		return nil, errors.New("Invalid platform role")
+	}
+	var u domain.User
+	if err := s.DB.WithContext(ctx).Where("email = ?", strings.TrimSpace(strings.ToLower(email))).First(&u).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, errors.New("User not found")
+		}
+		return nil, err
+	}
+	if u.UserID == actorID {
+		return nil, errors.New("You cannot change your own platform role")
+	}
+	op := domain.PlatformOperator{UserID: u.UserID, Role: role, GrantedBy: &actorID}
+	if err := s.DB.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"role", "granted_by", "updatedAt"}),
+	}).Create(&op).Error; err != nil {
+		return nil, err
+	}
+	s.destroySessions(ctx, u.UserID)
+	return &OperatorView{UserID: u.UserID, Fullname: u.Fullname, Email: u.Email, Role: role, GrantedBy: &actorID}, nil
+}
+
+// BootstrapAdmin makes the user with email the first platform_admin (cmd/bootstrap-admin). Every later operator is
+// granted through GrantOperator by an existing platform_admin, so this refuses once any platform_admin exists.
+func (s *Service) BootstrapAdmin(ctx context.Context, email string) (*OperatorView, error) {
+	var view *OperatorView
+	err := s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var admins int64
+		if err := tx.Model(&domain.PlatformOperator{}).Where("role = ?", constants.PlatformAdmin).Count(&admins).Error; err != nil {
+			return err
+		}
+		if admins > 0 {
+			return errors.New("A platform_admin already exists")
+		}
+		var u domain.User
+		if err := tx.Where("email = ?", strings.TrimSpace(strings.ToLower(email))).First(&u).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return errors.New("User not found")
+			}
+			return err
+		}
+		op := domain.PlatformOperator{UserID: u.UserID, Role: constants.PlatformAdmin}
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"role", "granted_by", "updatedAt"}),
+		}).Create(&op).Error; err != nil {
+			return err
+		}
+		view = &OperatorView{UserID: u.UserID, Fullname: u.Fullname, Email: u.Email, Role: op.Role}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.destroySessions(ctx, view.UserID)
+	return view, nil
+}
+
+// RevokeOperator removes a user's platform role.
+func (s *Service) RevokeOperator(ctx context.Context, actorID, userID uuid.UUID) error {
+	if userID == actorID {
+		return errors.New("You cannot change your own platform role")
+	}
+	res := s.DB.WithContext(ctx).Where("user_id = ?", userID).Delete(&domain.PlatformOperator{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return errors.New("Platform operator not found")
+	}
+	s.destroySessions(ctx, userID)
+	return nil
+}
+
+func (s *Service) destroySessions(ctx context.Context, userID uuid.UUID) {
+	if s.Rdb != nil {
+		policies.DestroyUserSessions(ctx, s.Rdb, userID.String())
+	}
+}
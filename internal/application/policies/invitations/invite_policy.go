@@ -5,8 +5,10 @@ import (
 	"strings"
 	"time"
 
+	"troo-backend/internal/application/memberships"
 	"troo-backend/internal/domain"
 
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
@@ -20,8 +22,10 @@ func ValidateInviteCreation(db *gorm.DB, email string, orgID string, actorEmail
 
 	var user domain.User
 	if err := db.Where("email = ?", normalized).First(&user).Error; err == nil {
-		if user.OrgID != nil && user.OrgID.String() == orgID {
-			return errors.New("User already belongs to this organization")
+		if id, err := uuid.Parse(orgID); err == nil {
+			if _, err := memberships.Get(db, &user, id); err == nil {
+				return errors.New("User already belongs to this organization")
+			}
 		}
 	}
 
@@ -13,4 +13,9 @@ var (
 	ErrUserNotFound                       = errors.New("User not found")
 	ErrUserDoesNotBelongToYourOrg          = errors.New("User does not belong to your organization")
 	ErrAdminsCannotRemoveAdminsOrSuperadmins = errors.New("Admins cannot remove admins or superadmins")
+	ErrCustomRoleNotFound                    = errors.New("Custom role not found")
+	ErrOnlySuperadminsCanAssignPrivilegedRoles = errors.New("Only superadmins can assign custom roles with admin permissions")
+	ErrOnlyAdminsCanChangeAdminRoles         = errors.New("Only admins can change the role of an admin or superadmin")
+	ErrUseSessionsToSignOutYourself          = errors.New("Use your active sessions to sign yourself out")
+	ErrAdminsCannotSignOutAdmins             = errors.New("Admins cannot sign out admins or superadmins")
 )
@@ -1,23 +1,13 @@
 package policies
 
 import (
+	"troo-backend/internal/application/memberships"
 	"troo-backend/internal/domain"
 	"troo-backend/internal/pkg/constants"
 
-	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
-func sameOrgMembership(orgIDStr *string, orgIDUUID *uuid.UUID) bool {
-	if orgIDStr == nil && orgIDUUID == nil {
-		return true
-	}
-	if orgIDStr == nil || orgIDUUID == nil {
-		return false
-	}
-	return *orgIDStr == orgIDUUID.String()
-}
-
 // ValidateOrgMembershipChange replicates express user/policies/membershipGovernance.js.
 // Returns the target user on success; returns an error with the exact Express message on failure.
 func ValidateOrgMembershipChange(db *gorm.DB, params ValidateOrgMembershipChangeParams) (*domain.User, error) {
@@ -31,21 +21,22 @@ func ValidateOrgMembershipChange(db *gorm.DB, params ValidateOrgMembershipChange
 		}
 		return nil, err
 	}
-	if !sameOrgMembership(params.OrgID, target.OrgID) {
-		return nil, ErrUserDoesNotBelongToYourOrg
+	membership, err := orgMembership(db, &target, params.OrgID)
+	if err != nil {
+		if err == memberships.ErrNotMember {
+			return nil, ErrUserDoesNotBelongToYourOrg
+		}
+		return nil, err
 	}
-	// Admin cannot remove admin/superadmin
-	if params.ActorRole == constants.Admin &&
-		(target.Role == constants.Admin || target.Role == constants.Superadmin) {
+	// Only superadmins remove admin-level users (admin, superadmin or privileged custom role)
+	if params.ActorRole != constants.Superadmin && holdsAdminAccess(db, membership) {
 		return nil, ErrAdminsCannotRemoveAdminsOrSuperadmins
 	}
 	// Prevent last superadmin removal
-	if target.Role == constants.Superadmin {
-		var count int64
-		if target.OrgID == nil {
-			db.Model(&domain.User{}).Where("org_id IS NULL AND role = ?", constants.Superadmin).Count(&count)
-		} else {
-			db.Model(&domain.User{}).Where("org_id = ? AND role = ?", target.OrgID, constants.Superadmin).Count(&count)
+	if membership.Role == constants.Superadmin {
+		count, err := countSuperadmins(db, params.OrgID)
+		if err != nil {
+			return nil, err
 		}
 		if count <= 1 {
 			return nil, ErrOrgMustHaveAtLeastOneSuperadmin
@@ -54,6 +45,35 @@ func ValidateOrgMembershipChange(db *gorm.DB, params ValidateOrgMembershipChange
 	return &target, nil
 }
 
+// ValidateForceLogout checks that the actor may sign a member of their org out of every session: not
+// themselves, same org, and only superadmins may sign out admin-level users. Returns the target user.
+func ValidateForceLogout(db *gorm.DB, params ValidateOrgMembershipChangeParams) (*domain.User, error) {
+	if params.ActorUserID == params.TargetUserID {
+		return nil, ErrUseSessionsToSignOutYourself
+	}
+	var target domain.User
+	if err := db.Where("user_id = ?", params.TargetUserID).First(&target).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	if params.OrgID == nil {
+		return nil, ErrUserDoesNotBelongToYourOrg
+	}
+	membership, err := orgMembership(db, &target, params.OrgID)
+	if err != nil {
+		if err == memberships.ErrNotMember {
+			return nil, ErrUserDoesNotBelongToYourOrg
+		}
+		return nil, err
+	}
+	if params.ActorRole != constants.Superadmin && holdsAdminAccess(db, membership) {
+		return nil, ErrAdminsCannotSignOutAdmins
+	}
+	return &target, nil
+}
+
 type ValidateOrgMembershipChangeParams struct {
 	ActorUserID  string
 	ActorRole    string
@@ -16,7 +16,7 @@ import (
 func setupPolicyDB(t *testing.T) *gorm.DB {
 	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
 	require.NoError(t, err)
-	require.NoError(t, db.AutoMigrate(&domain.User{}))
+	require.NoError(t, db.AutoMigrate(&domain.User{}, &domain.OrgMembership{}))
 	return db
 }
 
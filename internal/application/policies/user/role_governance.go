@@ -1,6 +1,7 @@
 package policies
 
 import (
+	"troo-backend/internal/application/memberships"
 	"troo-backend/internal/domain"
 	"troo-backend/internal/pkg/constants"
 
@@ -8,16 +9,6 @@ import (
 	"gorm.io/gorm"
 )
 
-func sameOrg(orgIDStr *string, orgIDUUID *uuid.UUID) bool {
-	if orgIDStr == nil && orgIDUUID == nil {
-		return true
-	}
-	if orgIDStr == nil || orgIDUUID == nil {
-		return false
-	}
-	return *orgIDStr == orgIDUUID.String()
-}
-
 // ValidateRoleAssignment replicates express user/policies/roleGovernance.js.
 // Returns nil on success; returns an error with the exact Express message on failure.
 func ValidateRoleAssignment(db *gorm.DB, params ValidateRoleAssignmentParams) error {
@@ -26,6 +17,22 @@ func ValidateRoleAssignment(db *gorm.DB, params ValidateRoleAssignmentParams) er
 		params.ActorRole != constants.Superadmin {
 		return ErrOnlySuperadminsCanAssignAdminOrSuperadmin
 	}
+	if params.TargetCustomRoleID != "" {
+		var role domain.CustomRole
+		if err := db.Where("role_id = ?", params.TargetCustomRoleID).First(&role).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return ErrCustomRoleNotFound
+			}
+			return err
+		}
+		if params.OrgID == nil || *params.OrgID != role.OrgID.String() {
+			return ErrCustomRoleNotFound
+		}
+		// A custom role carrying admin-level permissions is governed like the admin role
+		if IsPrivilegedCustomRole(&role) && params.ActorRole != constants.Superadmin {
+			return ErrOnlySuperadminsCanAssignPrivilegedRoles
+		}
+	}
 	if params.TargetUserID == "" {
 		return nil // invitations stop here
 	}
@@ -36,20 +43,26 @@ func ValidateRoleAssignment(db *gorm.DB, params ValidateRoleAssignmentParams) er
 		}
 		return err
 	}
-	if !sameOrg(params.OrgID, target.OrgID) {
-		return ErrCannotModifyUsersOutsideYourOrg
+	membership, err := orgMembership(db, &target, params.OrgID)
+	if err != nil {
+		if err == memberships.ErrNotMember {
+			return ErrCannotModifyUsersOutsideYourOrg
+		}
+		return err
 	}
 	// Prevent self-role modification
 	if params.ActorUserID == params.TargetUserID && params.ActorRole != constants.Superadmin {
 		return ErrUsersCannotModifyTheirOwnRole
 	}
+	// Custom-role holders with ASSIGN_ROLE cannot touch admin-level users
+	if params.ActorRole != constants.Admin && params.ActorRole != constants.Superadmin && holdsAdminAccess(db, membership) {
+		return ErrOnlyAdminsCanChangeAdminRoles
+	}
 	// Prevent last superadmin downgrade
-	if target.Role == constants.Superadmin && params.TargetRole != constants.Superadmin {
-		var count int64
-		if params.OrgID == nil {
-			db.Model(&domain.User{}).Where("org_id IS NULL AND role = ?", constants.Superadmin).Count(&count)
-		} else {
-			db.Model(&domain.User{}).Where("org_id = ? AND role = ?", params.OrgID, constants.Superadmin).Count(&count)
+	if membership.Role == constants.Superadmin && params.TargetRole != constants.Superadmin {
+		count, err := countSuperadmins(db, params.OrgID)
+		if err != nil {
+			return err
 		}
 		if count <= 1 {
 			return ErrOrgMustHaveAtLeastOneSuperadmin
@@ -64,4 +77,61 @@ type ValidateRoleAssignmentParams struct {
 	ActorUserID  string
 	TargetUserID string
 	OrgID        *string
+	// TargetCustomRoleID is set when assigning a custom role; TargetRole is then the base role (viewer).
+	TargetCustomRoleID string
+}
+
+// IsPrivilegedCustomRole reports whether the role grants any permission a manager does not hold.
+func IsPrivilegedCustomRole(role *domain.CustomRole) bool {
+	for _, p := range role.PermissionList() {
+		if constants.PrivilegedPermission(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// holdsAdminAccess is true for admins, superadmins and holders of a privileged custom role.
+func holdsAdminAccess(db *gorm.DB, m *domain.OrgMembership) bool {
+	if m.Role == constants.Admin || m.Role == constants.Superadmin {
+		return true
+	}
+	if m.CustomRoleID == nil {
+		return false
+	}
+	var role domain.CustomRole
+	if err := db.Where("role_id = ?", *m.CustomRoleID).First(&role).Error; err != nil {
+		return false
+	}
+	return IsPrivilegedCustomRole(&role)
+}
+
+// orgMembership returns the target's membership of the actor's org. An actor without an org (nil orgID)
+// only reaches users who have none either, through their Users row.
+func orgMembership(db *gorm.DB, target *domain.User, orgID *string) (*domain.OrgMembership, error) {
+	if orgID == nil {
+		if target.OrgID != nil {
+			return nil, memberships.ErrNotMember
+		}
+		return &domain.OrgMembership{UserID: target.UserID, Role: target.Role, CustomRoleID: target.CustomRoleID}, nil
+	}
+	id, err := uuid.Parse(*orgID)
+	if err != nil {
+		return nil, memberships.ErrNotMember
+	}
+	return memberships.Get(db, target, id)
+}
+
+// countSuperadmins counts the superadmins of the org, or of users without an org when orgID is nil.
+func countSuperadmins(db *gorm.DB, orgID *string) (int64, error) {
+	if orgID == nil {
+		var count int64
+		err := db.Model(&domain.User{}).Where("org_id IS NULL AND role = ?", constants.Superadmin).Count(&count).Error
+		return count, err
+	}
+	id, err := uuid.Parse(*orgID)
+	if err != nil {
+		return 0, err
+	}
+	return memberships.CountRole(db, id, constants.Superadmin)
 }
@@ -3,7 +3,7 @@ package policies
 import (
 	"context"
 
-	"troo-backend/internal/middleware"
+	"troo-backend/internal/pkg/constants"
 
 	"github.com/redis/go-redis/v9"
 )
@@ -14,14 +14,14 @@ func DestroyUserSessions(ctx context.Context, rdb *redis.Client, userID string)
 	if userID == "" {
 		return
 	}
-	key := "user_sessions:" + userID
+	key := constants.UserSessionsPrefix + userID
 	sessionIDs, err := rdb.SMembers(ctx, key).Result()
 	if err != nil || len(sessionIDs) == 0 {
 		rdb.Del(ctx, key)
 		return
 	}
 	for _, sid := range sessionIDs {
-		rdb.Del(ctx, middleware.SessionRedisPrefix+sid)
+		rdb.Del(ctx, constants.SessionRedisPrefix+sid)
 	}
 	rdb.Del(ctx, key)
 }
@@ -0,0 +1,141 @@
+// Package profiles serves orgs' public profile pages, which need no session. The profile (name, logo, country
+// and verification badge) is public for every open org; retirement totals and certificates only once the org
+// opts in (Orgs.public_retirements).
+package profiles
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"troo-backend/internal/application/verification"
+	"troo-backend/internal/domain"
+	"troo-backend/internal/pkg/decimal"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrNotFound          = errors.New("Organization not found")
+	ErrRetirementsHidden = errors.New("This organization does not share its retirements")
+)
+
+type Service struct {
+	DB *gorm.DB
+}
+
+// Profile is what anyone may see of an org.
+type Profile struct {
+	OrgName     string     `json:"org_name"`
+	OrgCode     string     `json:"org_code"`
+	CountryCode string     `json:"country_code"`
+	LogoURL     *string    `json:"logo_url"`
+	Verified    bool       `json:"verified"`
+	VerifiedAt  *time.Time `json:"verified_at"`
+	// Retirements is nil unless the org shares them.
+	Retirements *Retirements `json:"retirements"`
+}
+
+// Retirements totals the org's retired credits, overall and by project.
+type Retirements struct {
+	TotalRetired decimal.Decimal `json:"total_retired"`
+	Certificates int64           `json:"certificates"`
+	Projects     []ProjectTotal  `json:"projects"`
+}
+
+type ProjectTotal struct {
+	ProjectID   uuid.UUID       `json:"project_id"`
+	ProjectName *string         `json:"project_name"`
+	Amount      decimal.Decimal `json:"amount"`
+}
+
+// Certificate is the public part of a retirement certificate.
+type Certificate struct {
+	CertificateNumber string          `json:"certificate_number"`
+	ProjectID         uuid.UUID       `json:"project_id"`
+	ProjectName       *string         `json:"project_name"`
+	Amount            decimal.Decimal `json:"amount"`
+	RetiredAt         time.Time       `json:"retired_at"`
+	Purpose           *string         `json:"purpose"`
+	Beneficiary       *string         `json:"beneficiary"`
+}
+
+// Profile returns the public profile of the org with orgCode. Closed orgs have none.
+func (s *Service) Profile(ctx context.Context, orgCode string) (*Profile, error) {
+	db := s.DB.WithContext(ctx)
+	org, err := find(db, orgCode)
+	if err != nil {
+		return nil, err
+	}
+	out := &Profile{OrgName: org.OrgName, OrgCode: org.OrgCode, CountryCode: org.CountryCode, LogoURL: org.LogoURL}
+	// The badge follows the same rule as trading: verified with the documents the org has now.
+	switch err := verification.Require(db, org.OrgID); err {
+	case nil:
+		v, err := verification.Get(db, org.OrgID)
+		if err != nil {
+			return nil, err
+		}
+		out.Verified, out.VerifiedAt = true, v.ReviewedAt
+	case verification.ErrNotVerified:
+	default:
+		return nil, err
+	}
+	if !org.PublicRetirements {
+		return out, nil
+	}
+
+	r := &Retirements{TotalRetired: decimal.Zero, Projects: []ProjectTotal{}}
+	if err := db.Table(`"RetirementCertificates" AS c`).
+		Select(`c.project_id, p."fullName" AS project_name, SUM(c.amount) AS amount`).
+		Joins(`LEFT JOIN "icrProjects" p ON p.id = c.project_id`).
+		Where("c.org_id = ? AND c.status = ?", org.OrgID, "issued").
+		Group(`c.project_id, p."fullName"`).
+		Order("amount DESC, c.project_id").
+		Scan(&r.Projects).Error; err != nil {
+		return nil, err
+	}
+	for _, p := range r.Projects {
+		r.TotalRetired = r.TotalRetired.Add(p.Amount)
+	}
+	if err := db.Model(&domain.RetirementCertificate{}).
+		Where("org_id = ? AND status = ?", org.OrgID, "issued").
+		Count(&r.Certificates).Error; err != nil {
+		return nil, err
+	}
+	out.Retirements = r
+	return out, nil
+}
+
+// Certificates returns the org's issued retirement certificates, newest first, if it shares its retirements.
+func (s *Service) Certificates(ctx context.Context, orgCode string) ([]Certificate, error) {
+	db := s.DB.WithContext(ctx)
+	org, err := find(db, orgCode)
+	if err != nil {
+		return nil, err
+	}
+	if !org.PublicRetirements {
+		return nil, ErrRetirementsHidden
+	}
+	out := []Certificate{}
+	if err := db.Table(`"RetirementCertificates" AS c`).
+		Select(`c.certificate_number, c.project_id, p."fullName" AS project_name, c.amount, c.retired_at, c.purpose, c.beneficiary`).
+		Joins(`LEFT JOIN "icrProjects" p ON p.id = c.project_id`).
+		Where("c.org_id = ? AND c.status = ?", org.OrgID, "issued").
+		Order("c.retired_at DESC, c.certificate_number").
+		Scan(&out).Error; err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func find(db *gorm.DB, orgCode string) (*domain.Org, error) {
+	var org domain.Org
+	if err := db.Where("org_code = ? AND closed_at IS NULL", orgCode).First(&org).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &org, nil
+}
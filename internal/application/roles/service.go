@@ -0,0 +1,219 @@
+package roles
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+
+	"troo-backend/internal/application/memberships"
+	policies "troo-backend/internal/application/policies/user"
+	"troo-backend/internal/domain"
+	"troo-backend/internal/pkg/constants"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// Service manages org-defined custom roles. Holders' permissions are snapshotted into their session at login,
+// so every change to a role destroys the sessions of the users holding it.
+type Service struct {
+	DB  *gorm.DB
+	Rdb *redis.Client
+}
+
+type RoleInput struct {
+	OrgID       uuid.UUID
+	ActorUserID uuid.UUID
+	Name        string
+	Description *string
+	Permissions []string
+}
+
+// CustomRoleView is a custom role with its decoded permission list and holder count.
+type CustomRoleView struct {
+	RoleID      uuid.UUID `json:"role_id"`
+	Name        string    `json:"name"`
+	Description *string   `json:"description"`
+	Permissions []string  `json:"permissions"`
+	Privileged  bool      `json:"privileged"`
+	UserCount   int64     `json:"user_count"`
+}
+
+// GrantablePermissions lists the permissions that may be put in a custom role.
+func GrantablePermissions() []string {
+	out := make([]string, 0, len(constants.PermissionRoles))
+	for p := range constants.PermissionRoles {
+		if constants.GrantablePermission(p) {
+			out = append(out, p)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// ListRoles returns the org's custom roles by name.
+func (s *Service) ListRoles(ctx context.Context, orgID uuid.UUID) ([]CustomRoleView, error) {
+	var rows []domain.CustomRole
+	if err := s.DB.WithContext(ctx).Where("org_id = ?", orgID).Order("name ASC").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	out := make([]CustomRoleView, 0, len(rows))
+	for i := range rows {
+		v, err := s.view(ctx, &rows[i])
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *v)
+	}
+	return out, nil
+}
+
+// CreateRole adds a custom role to the org.
+func (s *Service) CreateRole(ctx context.Context, in RoleInput) (*CustomRoleView, error) {
+	name, perms, err := validate(in)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkNameFree(ctx, in.OrgID, name, uuid.Nil); err != nil {
+		return nil, err
+	}
+	b, _ := json.Marshal(perms)
+	role := domain.CustomRole{
+		OrgID:       in.OrgID,
+		Name:        name,
+		Description: in.Description,
+		Permissions: datatypes.JSON(b),
+		CreatedBy:   &in.ActorUserID,
+	}
+	if err := s.DB.WithContext(ctx).Create(&role).Error; err != nil {
+		return nil, err
+	}
+	return s.view(ctx, &role)
+}
+
+// UpdateRole replaces a custom role's name, description and permissions and logs its holders out.
+func (s *Service) UpdateRole(ctx context.Context, roleID uuid.UUID, in RoleInput) (*CustomRoleView, error) {
+	name, perms, err := validate(in)
+	if err != nil {
+		return nil, err
+	}
+	role, err := s.find(ctx, in.OrgID, roleID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkNameFree(ctx, in.OrgID, name, roleID); err != nil {
+		return nil, err
+	}
+	b, _ := json.Marshal(perms)
+	role.Name = name
+	role.Description = in.Description
+	role.Permissions = datatypes.JSON(b)
+	if err := s.DB.WithContext(ctx).Save(role).Error; err != nil {
+		return nil, err
+	}
+	s.destroyHolderSessions(ctx, role)
+	return s.view(ctx, role)
+}
+
+// DeleteRole removes an unassigned custom role.
+func (s *Service) DeleteRole(ctx context.Context, orgID, roleID uuid.UUID) error {
+	role, err := s.find(ctx, orgID, roleID)
+	if err != nil {
+		return err
+	}
+	var holders int64
+	if err := holdersOf(s.DB.WithContext(ctx), role).Count(&holders).Error; err != nil {
+		return err
+	}
+	if holders > 0 {
+		return errors.New("Custom role is still assigned to users")
+	}
+	return s.DB.WithContext(ctx).Delete(role).Error
+}
+
+func (s *Service) find(ctx context.Context, orgID, roleID uuid.UUID) (*domain.CustomRole, error) {
+	var role domain.CustomRole
+	if err := s.DB.WithContext(ctx).Where("role_id = ? AND org_id = ?", roleID, orgID).First(&role).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, errors.New("Custom role not found")
+		}
+		return nil, err
+	}
+	return &role, nil
+}
+
+func (s *Service) checkNameFree(ctx context.Context, orgID uuid.UUID, name string, except uuid.UUID) error {
+	var count int64
+	if err := s.DB.WithContext(ctx).Model(&domain.CustomRole{}).
+		Where("org_id = ? AND LOWER(name) = LOWER(?) AND role_id <> ?", orgID, name, except).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return errors.New("A custom role with this name already exists")
+	}
+	return nil
+}
+
+func (s *Service) view(ctx context.Context, role *domain.CustomRole) (*CustomRoleView, error) {
+	var holders int64
+	if err := holdersOf(s.DB.WithContext(ctx), role).Count(&holders).Error; err != nil {
+		return nil, err
+	}
+	return &CustomRoleView{
+		RoleID:      role.RoleID,
+		Name:        role.Name,
+		Description: role.Description,
+		Permissions: role.PermissionList(),
+		Privileged:  policies.IsPrivilegedCustomRole(role),
+		UserCount:   holders,
+	}, nil
+}
+
+// holdersOf selects the role's holders from the org's memberships, so that users holding it in an org other
+// than their home org count too.
+func holdersOf(db *gorm.DB, role *domain.CustomRole) *gorm.DB {
+	return db.Table("(?) AS m", memberships.Members(db, role.OrgID)).Where("m.custom_role_id = ?", role.RoleID)
+}
+
+func (s *Service) destroyHolderSessions(ctx context.Context, role *domain.CustomRole) {
+	if s.Rdb == nil {
+		return
+	}
+	var ids []uuid.UUID
+	holdersOf(s.DB.WithContext(ctx), role).Pluck("m.user_id", &ids)
+	for _, id := range ids {
+		policies.DestroyUserSessions(ctx, s.Rdb, id.String())
+	}
+}
+
+// validate trims the name and returns the de-duplicated, sorted permission set.
+func validate(in RoleInput) (string, []string, error) {
+	name := strings.TrimSpace(in.Name)
+	if name == "" {
+		return "", nil, errors.New("Role name is required")
+	}
+	if constants.IsValidRole(strings.ToLower(name)) {
+		return "", nil, errors.New("Role name is reserved")
+	}
+	if len(in.Permissions) == 0 {
+		return "", nil, errors.New("At least one permission is required")
+	}
+	seen := make(map[string]bool, len(in.Permissions))
+	perms := make([]string, 0, len(in.Permissions))
+	for _, p := range in.Permissions {
+		if !constants.GrantablePermission(p) {
+			return "", nil, errors.New("Permission cannot be granted to a custom role")
+		}
+		if !seen[p] {
+			seen[p] = true
+			perms = append(perms, p)
+		}
+	}
+	sort.Strings(perms)
+	return name, perms, nil
+}
@@ -0,0 +1,383 @@
+package scim
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"time"
+
+	"troo-backend/internal/application/memberships"
+	policies "troo-backend/internal/application/policies/user"
+	"troo-backend/internal/domain"
+	"troo-backend/internal/pkg/constants"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// memberFilterPath is the `members[value eq "<user id>"]` path providers use to remove one member.
+var memberFilterPath = regexp.MustCompile(`^(?i:members)\[\s*(?i:value)\s+(?i:eq)\s+"([^"]+)"\s*\]$`)
+
+// role is one group: a built-in role (customRoleID nil) or a custom role of the org.
+type role struct {
+	id           string
+	name         string
+	customRoleID *uuid.UUID
+	created      *time.Time
+	modified     *time.Time
+}
+
+// ListGroups returns a page of the org's roles with their members, optionally filtered by displayName.
+func (s *Service) ListGroups(ctx context.Context, orgID uuid.UUID, filter string, offset, limit int) ([]Group, int64, error) {
+	attr, value, err := parseFilter(filter, "displayName")
+	if err != nil {
+		return nil, 0, err
+	}
+	roles, err := s.roles(s.DB.WithContext(ctx), orgID)
+	if err != nil {
+		return nil, 0, err
+	}
+	if attr != "" {
+		var matched []role
+		for _, r := range roles {
+			if strings.EqualFold(r.name, value) {
+				matched = append(matched, r)
+			}
+		}
+		roles = matched
+	}
+	total := int64(len(roles))
+	if offset > len(roles) {
+		offset = len(roles)
+	}
+	roles = roles[offset:]
+	if limit < len(roles) {
+		roles = roles[:limit]
+	}
+	out := make([]Group, 0, len(roles))
+	for _, r := range roles {
+		g, err := s.group(s.DB.WithContext(ctx), orgID, r)
+		if err != nil {
+			return nil, 0, err
+		}
+		out = append(out, *g)
+	}
+	return out, total, nil
+}
+
+// GetGroup returns one of the org's roles with its members.
+func (s *Service) GetGroup(ctx context.Context, orgID uuid.UUID, id string) (*Group, error) {
+	db := s.DB.WithContext(ctx)
+	r, err := s.role(db, orgID, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.group(db, orgID, *r)
+}
+
+// CreateGroup never creates a role: a displayName naming an existing role is a conflict, so providers link
+// to it, and any other name is refused.
+func (s *Service) CreateGroup(ctx context.Context, orgID uuid.UUID, in GroupInput) error {
+	roles, err := s.roles(s.DB.WithContext(ctx), orgID)
+	if err != nil {
+		return err
+	}
+	for _, r := range roles {
+		if strings.EqualFold(r.name, strings.TrimSpace(in.DisplayName)) {
+			return ErrGroupExists
+		}
+	}
+	return ErrUnknownGroup
+}
+
+// ReplaceGroup applies a PUT: the members become exactly in.Members. The displayName cannot change.
+func (s *Service) ReplaceGroup(ctx context.Context, orgID uuid.UUID, id string, in GroupInput) (*Group, error) {
+	return s.changeMembers(ctx, orgID, id, func(r *role, m *memberChanges) error {
+		if in.DisplayName != "" && !strings.EqualFold(in.DisplayName, r.name) {
+			return ErrGroupImmutable
+		}
+		m.replace(in.Members)
+		return nil
+	})
+}
+
+// PatchGroup applies add, remove and replace operations on members. Removing a member moves them back to viewer.
+func (s *Service) PatchGroup(ctx context.Context, orgID uuid.UUID, id string, ops []PatchOperation) (*Group, error) {
+	return s.changeMembers(ctx, orgID, id, func(r *role, m *memberChanges) error {
+		for _, op := range ops {
+			if err := patchMembers(r, m, strings.ToLower(op.Op), op.Path, op.Value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func patchMembers(r *role, m *memberChanges, kind, path string, v json.RawMessage) error {
+	if path == "" {
+		if kind == "remove" {
+			return ErrInvalidPatch
+		}
+		var values map[string]json.RawMessage
+		if err := json.Unmarshal(v, &values); err != nil {
+			return ErrInvalidPatch
+		}
+		for p, pv := range values {
+			if err := patchMembers(r, m, kind, p, pv); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if sub := memberFilterPath.FindStringSubmatch(path); sub != nil && kind == "remove" {
+		m.remove(sub[1])
+		return nil
+	}
+	switch strings.ToLower(path) {
+	case "displayname":
+		name, err := stringValue(v)
+		if err != nil || kind == "remove" || !strings.EqualFold(name, r.name) {
+			return ErrGroupImmutable
+		}
+		return nil
+	case "members":
+		var refs []Ref
+		if len(v) > 0 && json.Unmarshal(v, &refs) != nil {
+			return ErrInvalidPatch
+		}
+		switch kind {
+		case "add":
+			for _, ref := range refs {
+				m.add(ref.Value)
+			}
+		case "replace":
+			m.replace(refs)
+		case "remove":
+			if len(v) == 0 {
+				m.removeAll = true
+			}
+			for _, ref := range refs {
+				m.remove(ref.Value)
+			}
+		default:
+			return ErrInvalidPatch
+		}
+		return nil
+	}
+	return ErrInvalidPatch
+}
+
+// memberChanges collects the user ids to move into and out of a group.
+type memberChanges struct {
+	adds      []string
+	removes   []string
+	removeAll bool
+}
+
+func (m *memberChanges) add(id string) {
+	m.adds = append(m.adds, id)
+}
+
+func (m *memberChanges) remove(id string) {
+	m.removes = append(m.removes, id)
+}
+
+func (m *memberChanges) replace(refs []Ref) {
+	m.removeAll = true
+	m.adds = m.adds[:0]
+	m.removes = m.removes[:0]
+	for _, ref := range refs {
+		m.add(ref.Value)
+	}
+}
+
+// changeMembers collects member changes with collect and applies them in one transaction: removals first,
+// then additions, each through ValidateRoleAssignment with the provider acting as a superadmin. Every user
+// whose role changed is signed out.
+func (s *Service) changeMembers(ctx context.Context, orgID uuid.UUID, id string, collect func(*role, *memberChanges) error) (*Group, error) {
+	var r *role
+	var changed []string
+	err := s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		if r, err = s.role(tx, orgID, id); err != nil {
+			return err
+		}
+		var m memberChanges
+		if err := collect(r, &m); err != nil {
+			return err
+		}
+		keep := make(map[string]bool, len(m.adds))
+		for _, a := range m.adds {
+			keep[a] = true
+		}
+		removes := m.removes
+		if m.removeAll {
+			members, err := s.members(tx, orgID, *r)
+			if err != nil {
+				return err
+			}
+			for _, u := range members {
+				if !keep[u.UserID.String()] {
+					removes = append(removes, u.UserID.String())
+				}
+			}
+		}
+		for _, userID := range removes {
+			ok, err := s.assign(tx, orgID, userID, r, false)
+			if err != nil {
+				return err
+			}
+			if ok {
+				changed = append(changed, userID)
+			}
+		}
+		for _, userID := range m.adds {
+			ok, err := s.assign(tx, orgID, userID, r, true)
+			if err != nil {
+				return err
+			}
+			if ok {
+				changed = append(changed, userID)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, userID := range changed {
+		policies.DestroyUserSessions(ctx, s.Rdb, userID)
+	}
+	return s.group(s.DB.WithContext(ctx), orgID, *r)
+}
+
+// assign puts the member into r (join) or, if they hold r, back to viewer (leave). It reports whether their
+// role changed. Removing someone from the viewer group leaves them a viewer.
+func (s *Service) assign(tx *gorm.DB, orgID uuid.UUID, userID string, r *role, join bool) (bool, error) {
+	id, err := uuid.Parse(userID)
+	if err != nil {
+		return false, ErrInvalidMember
+	}
+	var u domain.User
+	err = tx.Where("user_id = ?", id).First(&u).Error
+	var m *domain.OrgMembership
+	if err == nil {
+		m, err = memberships.Get(tx, &u, orgID)
+	}
+	if err == gorm.ErrRecordNotFound || err == memberships.ErrNotMember {
+		if join {
+			return false, ErrInvalidMember
+		}
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if join == holdsRole(m, r) || (!join && r.id == constants.Viewer) {
+		return false, nil
+	}
+	// The provider can assign any role below superadmin; superadmins are appointed in Troo.
+	if join && r.customRoleID == nil && r.id == constants.Superadmin {
+		return false, ErrRoleNotAssignable
+	}
+	org := orgID.String()
+	params := policies.ValidateRoleAssignmentParams{
+		ActorRole:    constants.Superadmin,
+		TargetRole:   constants.Viewer,
+		TargetUserID: userID,
+		OrgID:        &org,
+	}
+	var customRoleID *uuid.UUID
+	if join && r.customRoleID != nil {
+		customRoleID = r.customRoleID
+		params.TargetCustomRoleID = r.customRoleID.String()
+	} else if join {
+		params.TargetRole = r.id
+	}
+	if err := policies.ValidateRoleAssignment(tx, params); err != nil {
+		return false, err
+	}
+	if err := memberships.SetRole(tx, &u, orgID, params.TargetRole, customRoleID); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// roles lists the built-in roles followed by the org's custom roles by name.
+func (s *Service) roles(db *gorm.DB, orgID uuid.UUID) ([]role, error) {
+	out := make([]role, 0, len(constants.ValidRoles))
+	for _, r := range constants.ValidRoles {
+		out = append(out, role{id: r, name: r})
+	}
+	var custom []domain.CustomRole
+	if err := db.Where("org_id = ?", orgID).Order("name").Find(&custom).Error; err != nil {
+		return nil, err
+	}
+	for i := range custom {
+		out = append(out, customRole(&custom[i]))
+	}
+	return out, nil
+}
+
+func (s *Service) role(db *gorm.DB, orgID uuid.UUID, id string) (*role, error) {
+	if constants.IsValidRole(id) {
+		return &role{id: id, name: id}, nil
+	}
+	roleID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, ErrGroupNotFound
+	}
+	var cr domain.CustomRole
+	if err := db.Where("role_id = ? AND org_id = ?", roleID, orgID).First(&cr).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrGroupNotFound
+		}
+		return nil, err
+	}
+	r := customRole(&cr)
+	return &r, nil
+}
+
+func (s *Service) members(db *gorm.DB, orgID uuid.UUID, r role) ([]domain.User, error) {
+	q := db.Joins(`JOIN (?) m ON m.user_id = "Users".user_id`, memberships.Members(db, orgID))
+	if r.customRoleID != nil {
+		q = q.Where("m.custom_role_id = ?", *r.customRoleID)
+	} else {
+		q = q.Where("m.role = ? AND m.custom_role_id IS NULL", r.id)
+	}
+	var users []domain.User
+	if err := q.Order(`"Users"."createdAt", "Users".user_id`).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (s *Service) group(db *gorm.DB, orgID uuid.UUID, r role) (*Group, error) {
+	users, err := s.members(db, orgID, r)
+	if err != nil {
+		return nil, err
+	}
+	g := &Group{
+		Schemas:     []string{GroupSchema},
+		ID:          r.id,
+		DisplayName: r.name,
+		Members:     make([]Ref, 0, len(users)),
+		Meta:        Meta{ResourceType: "Group", Created: r.created, LastModified: r.modified},
+	}
+	for i := range users {
+		g.Members = append(g.Members, Ref{Value: users[i].UserID.String(), Display: users[i].Email})
+	}
+	return g, nil
+}
+
+func customRole(cr *domain.CustomRole) role {
+	return role{id: cr.RoleID.String(), name: cr.Name, customRoleID: &cr.RoleID, created: &cr.CreatedAt, modified: &cr.UpdatedAt}
+}
+
+func holdsRole(m *domain.OrgMembership, r *role) bool {
+	if r.customRoleID != nil {
+		return m.CustomRoleID != nil && *m.CustomRoleID == *r.customRoleID
+	}
+	return m.CustomRoleID == nil && m.Role == r.id
+}
@@ -0,0 +1,267 @@
+// Package scim implements SCIM 2.0 (RFC 7643/7644) Users and Groups for an org's identity provider.
+// Users are the org's members, keyed by user_id, with userName as their email. Groups are the org's roles:
+// the four built-in roles (id = role name) and its custom roles (id = role_id). Group membership is role
+// assignment, so a user is in exactly one group.
+package scim
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"troo-backend/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+// tokenPrefix marks SCIM tokens so they are recognisable in logs and by secret scanners.
+const tokenPrefix = "troo_scim_"
+
+// lastUsedInterval throttles last_used_at writes; identity providers sync in bursts.
+const lastUsedInterval = time.Minute
+
+const (
+	defaultCount = 100
+	maxCount     = 200
+)
+
+// SCIM schema and message URNs.
+const (
+	UserSchema         = "urn:ietf:params:scim:schemas:core:2.0:User"
+	GroupSchema        = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	ListResponseSchema = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	PatchOpSchema      = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	ErrorSchema        = "urn:ietf:params:scim:api:messages:2.0:Error"
+)
+
+var (
+	ErrInvalidToken      = errors.New("Invalid SCIM token")
+	ErrUserNotFound      = errors.New("User not found")
+	ErrGroupNotFound     = errors.New("Group not found")
+	ErrUserExists        = errors.New("A user with this userName already exists")
+	ErrInvalidUserName   = errors.New("userName must be an email address")
+	ErrUnverifiedDomain  = errors.New("userName must be at a domain the organization has verified for single sign-on")
+	ErrGroupExists       = errors.New("A group with this displayName already exists")
+	ErrUnknownGroup      = errors.New("Groups are the organization's roles; create a custom role first")
+	ErrGroupImmutable    = errors.New("Groups are the organization's roles and cannot be renamed or deleted")
+	ErrInvalidMember     = errors.New("Group members must be active users of the organization")
+	ErrInvalidFilter     = errors.New("Unsupported filter")
+	ErrInvalidPatch      = errors.New("Unsupported patch operation")
+	ErrIdentityManaged   = errors.New("This user's email and name are managed outside this organization")
+	ErrRoleNotAssignable = errors.New("The superadmin role cannot be assigned over SCIM")
+)
+
+// Service serves one org's SCIM resources; every method takes the org the token authenticated.
+type Service struct {
+	DB *gorm.DB
+	// Rdb is used to end the sessions of users who are deactivated or change role.
+	Rdb *redis.Client
+}
+
+type Meta struct {
+	ResourceType string     `json:"resourceType"`
+	Created      *time.Time `json:"created,omitempty"`
+	LastModified *time.Time `json:"lastModified,omitempty"`
+}
+
+type Name struct {
+	Formatted  string `json:"formatted,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+}
+
+type Email struct {
+	Value   string `json:"value"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+// Ref points at another resource: a user's group or a group's member.
+type Ref struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+}
+
+type User struct {
+	Schemas     []string `json:"schemas"`
+	ID          string   `json:"id"`
+	ExternalID  string   `json:"externalId,omitempty"`
+	UserName    string   `json:"userName"`
+	Name        Name     `json:"name"`
+	DisplayName string   `json:"displayName"`
+	Emails      []Email  `json:"emails"`
+	Active      bool     `json:"active"`
+	Groups      []Ref    `json:"groups"`
+	Meta        Meta     `json:"meta"`
+}
+
+// UserInput is a POST or PUT body. A nil Active leaves the user's state unchanged (active on create).
+type UserInput struct {
+	UserName    string  `json:"userName"`
+	ExternalID  string  `json:"externalId"`
+	Name        *Name   `json:"name"`
+	DisplayName string  `json:"displayName"`
+	Emails      []Email `json:"emails"`
+	Active      *bool   `json:"active"`
+}
+
+type Group struct {
+	Schemas     []string `json:"schemas"`
+	ID          string   `json:"id"`
+	DisplayName string   `json:"displayName"`
+	Members     []Ref    `json:"members"`
+	Meta        Meta     `json:"meta"`
+}
+
+type GroupInput struct {
+	DisplayName string `json:"displayName"`
+	Members     []Ref  `json:"members"`
+}
+
+// PatchOperation is one entry of a PatchOp request's Operations.
+type PatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value"`
+}
+
+// TokenStatus describes the org's SCIM token without revealing it.
+type TokenStatus struct {
+	Enabled    bool       `json:"enabled"`
+	CreatedBy  *uuid.UUID `json:"created_by"`
+	CreatedAt  *time.Time `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
+// TokenStatus reports whether the org has a SCIM token and when it was issued and last used.
+func (s *Service) TokenStatus(ctx context.Context, orgID uuid.UUID) (*TokenStatus, error) {
+	var tok domain.OrgSCIMToken
+	if err := s.DB.WithContext(ctx).Where("org_id = ?", orgID).First(&tok).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return &TokenStatus{}, nil
+		}
+		return nil, err
+	}
+	return &TokenStatus{Enabled: true, CreatedBy: &tok.CreatedBy, CreatedAt: &tok.CreatedAt, LastUsedAt: tok.LastUsedAt}, nil
+}
+
+// RotateToken issues a new token for the org, invalidating the previous one. The returned token is the only copy.
+func (s *Service) RotateToken(ctx context.Context, orgID, actorID uuid.UUID) (string, error) {
+	b := make([]byte, 32)
+	rand.Read(b)
+	token := tokenPrefix + hex.EncodeToString(b)
+	err := s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("org_id = ?", orgID).Delete(&domain.OrgSCIMToken{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&domain.OrgSCIMToken{OrgID: orgID, TokenHash: hashToken(token), CreatedBy: actorID}).Error
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// RevokeToken turns SCIM off for the org. Users it provisioned stay as they are.
+func (s *Service) RevokeToken(ctx context.Context, orgID uuid.UUID) error {
+	return s.DB.WithContext(ctx).Where("org_id = ?", orgID).Delete(&domain.OrgSCIMToken{}).Error
+}
+
+// Authenticate resolves a bearer token to its org.
+func (s *Service) Authenticate(ctx context.Context, token string) (uuid.UUID, error) {
+	token = strings.TrimSpace(token)
+	if !strings.HasPrefix(token, tokenPrefix) {
+		return uuid.Nil, ErrInvalidToken
+	}
+	var tok domain.OrgSCIMToken
+	if err := s.DB.WithContext(ctx).Where("token_hash = ?", hashToken(token)).First(&tok).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return uuid.Nil, ErrInvalidToken
+		}
+		return uuid.Nil, err
+	}
+	now := time.Now()
+	if tok.LastUsedAt == nil || now.Sub(*tok.LastUsedAt) >= lastUsedInterval {
+		if err := s.DB.WithContext(ctx).Model(&tok).UpdateColumn("last_used_at", now).Error; err != nil {
+			return uuid.Nil, err
+		}
+	}
+	return tok.OrgID, nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Page turns SCIM's 1-based startIndex and count query parameters into an offset and limit.
+func Page(startIndex, count string) (offset, limit int) {
+	start, err := strconv.Atoi(startIndex)
+	if err != nil || start < 1 {
+		start = 1
+	}
+	limit, err = strconv.Atoi(count)
+	if err != nil || limit < 0 {
+		limit = defaultCount
+	}
+	if limit > maxCount {
+		limit = maxCount
+	}
+	return start - 1, limit
+}
+
+var filterExpr = regexp.MustCompile(`^\s*([A-Za-z.]+)\s+(?i:eq)\s+"((?:[^"\\]|\\.)*)"\s*$`)
+
+// parseFilter accepts the single `attribute eq "value"` form identity providers use to look resources up.
+// An empty filter returns an empty attribute.
+func parseFilter(filter string, allowed ...string) (attr, value string, err error) {
+	if strings.TrimSpace(filter) == "" {
+		return "", "", nil
+	}
+	m := filterExpr.FindStringSubmatch(filter)
+	if m == nil {
+		return "", "", ErrInvalidFilter
+	}
+	for _, a := range allowed {
+		if strings.EqualFold(m[1], a) {
+			value, err := strconv.Unquote(`"` + m[2] + `"`)
+			if err != nil {
+				return "", "", ErrInvalidFilter
+			}
+			return a, value, nil
+		}
+	}
+	return "", "", ErrInvalidFilter
+}
+
+// boolValue reads a patch value that is a JSON boolean or, as some providers send it, a "True"/"False" string.
+func boolValue(raw json.RawMessage) (bool, error) {
+	var b bool
+	if err := json.Unmarshal(raw, &b); err == nil {
+		return b, nil
+	}
+	var str string
+	if err := json.Unmarshal(raw, &str); err == nil {
+		if v, err := strconv.ParseBool(str); err == nil {
+			return v, nil
+		}
+	}
+	return false, ErrInvalidPatch
+}
+
+func stringValue(raw json.RawMessage) (string, error) {
+	var str string
+	if err := json.Unmarshal(raw, &str); err != nil {
+		return "", ErrInvalidPatch
+	}
+	return str, nil
+}
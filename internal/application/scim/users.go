@@ -0,0 +1,554 @@
+package scim
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"troo-backend/internal/application/memberships"
+	policies "troo-backend/internal/application/policies/user"
+	"troo-backend/internal/domain"
+	"troo-backend/internal/pkg/constants"
+	"troo-backend/internal/pkg/validation"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// userChanges are the attributes a PUT or PATCH sets; nil leaves one as it is.
+type userChanges struct {
+	email      *string
+	fullname   *string
+	givenName  *string
+	familyName *string
+	externalID *string
+	active     *bool
+}
+
+// ListUsers returns a page of the org's members and of the users its provider deactivated, optionally
+// filtered by userName or externalId, with the total count.
+func (s *Service) ListUsers(ctx context.Context, orgID uuid.UUID, filter string, offset, limit int) ([]User, int64, error) {
+	attr, value, err := parseFilter(filter, "userName", "externalId")
+	if err != nil {
+		return nil, 0, err
+	}
+	linked := s.DB.Model(&domain.SCIMUser{}).Select("user_id").Where("org_id = ?", orgID)
+	members := s.DB.Table("(?) AS m", memberships.Members(s.DB, orgID)).Select("m.user_id")
+	q := s.DB.WithContext(ctx).Model(&domain.User{}).Where("user_id IN (?) OR user_id IN (?)", members, linked)
+	switch attr {
+	case "userName":
+		q = q.Where("LOWER(email) = ?", strings.ToLower(value))
+	case "externalId":
+		q = q.Where("user_id IN (?)", s.DB.Model(&domain.SCIMUser{}).Select("user_id").Where("org_id = ? AND external_id = ?", orgID, value))
+	}
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var users []domain.User
+	if limit > 0 {
+		if err := q.Order(`"createdAt", user_id`).Offset(offset).Limit(limit).Find(&users).Error; err != nil {
+			return nil, 0, err
+		}
+	}
+	ids := make([]uuid.UUID, 0, len(users))
+	for i := range users {
+		ids = append(ids, users[i].UserID)
+	}
+	var links []domain.SCIMUser
+	if len(ids) > 0 {
+		if err := s.DB.WithContext(ctx).Where("org_id = ? AND user_id IN ?", orgID, ids).Find(&links).Error; err != nil {
+			return nil, 0, err
+		}
+	}
+	byUser := make(map[uuid.UUID]*domain.SCIMUser, len(links))
+	for i := range links {
+		byUser[links[i].UserID] = &links[i]
+	}
+	roles, err := membershipsOf(s.DB.WithContext(ctx), orgID, ids)
+	if err != nil {
+		return nil, 0, err
+	}
+	out := make([]User, 0, len(users))
+	for i := range users {
+		out = append(out, userResource(&users[i], byUser[users[i].UserID], roles[users[i].UserID]))
+	}
+	return out, total, nil
+}
+
+// GetUser returns one member or deactivated user of the org.
+func (s *Service) GetUser(ctx context.Context, orgID uuid.UUID, id string) (*User, error) {
+	u, link, err := s.managedUser(s.DB.WithContext(ctx), orgID, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.view(ctx, orgID, u.UserID, link)
+}
+
+// CreateUser provisions a new user into the org as a viewer (or outside it when active is false); groups
+// assign their role. Like single sign-on users they have no password and a verified email, so the address
+// must be at one of the org's verified domains.
+func (s *Service) CreateUser(ctx context.Context, orgID uuid.UUID, in UserInput) (*User, error) {
+	email, err := userNameEmail(in.UserName)
+	if err != nil {
+		return nil, err
+	}
+	fullname := inputFullname(in)
+	if fullname == "" {
+		fullname = email
+	}
+	var u domain.User
+	var link domain.SCIMUser
+	err = s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := ownsDomain(tx, orgID, email); err != nil {
+			return err
+		}
+		var existing domain.User
+		err := tx.Where("LOWER(email) = ?", email).First(&existing).Error
+		if err == nil {
+			return ErrUserExists
+		}
+		if err != gorm.ErrRecordNotFound {
+			return err
+		}
+		u = domain.User{
+			Fullname:      fullname,
+			UserName:      strings.SplitN(email, "@", 2)[0],
+			Email:         email,
+			EmailVerified: true,
+			Role:          constants.Viewer,
+		}
+		if err := tx.Create(&u).Error; err != nil {
+			return err
+		}
+		if in.Active == nil || *in.Active {
+			if err := memberships.Add(tx, &u, orgID, constants.Viewer, nil); err != nil {
+				return err
+			}
+		}
+		link = domain.SCIMUser{UserID: u.UserID, OrgID: orgID, ExternalID: in.ExternalID, Provisioned: true}
+		return tx.Create(&link).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.view(ctx, orgID, u.UserID, &link)
+}
+
+// ReplaceUser applies a PUT: userName, name, externalId and active.
+func (s *Service) ReplaceUser(ctx context.Context, orgID uuid.UUID, id string, in UserInput) (*User, error) {
+	email, err := userNameEmail(in.UserName)
+	if err != nil {
+		return nil, err
+	}
+	ch := userChanges{email: &email, externalID: &in.ExternalID, active: in.Active}
+	if fullname := inputFullname(in); fullname != "" {
+		ch.fullname = &fullname
+	}
+	return s.update(ctx, orgID, id, ch)
+}
+
+// PatchUser applies add and replace operations on active, userName, externalId, displayName and name, with
+// or without a path, and remove on externalId. Attributes Troo does not store (emails, phone numbers, extensions) are ignored; the
+// email is always the userName.
+func (s *Service) PatchUser(ctx context.Context, orgID uuid.UUID, id string, ops []PatchOperation) (*User, error) {
+	var ch userChanges
+	for _, op := range ops {
+		kind := strings.ToLower(op.Op)
+		if kind != "add" && kind != "replace" && kind != "remove" {
+			return nil, ErrInvalidPatch
+		}
+		if op.Path == "" {
+			if kind == "remove" {
+				return nil, ErrInvalidPatch
+			}
+			var values map[string]json.RawMessage
+			if err := json.Unmarshal(op.Value, &values); err != nil {
+				return nil, ErrInvalidPatch
+			}
+			for path, v := range values {
+				if err := ch.apply(kind, path, v); err != nil {
+					return nil, err
+				}
+			}
+			continue
+		}
+		if err := ch.apply(kind, op.Path, op.Value); err != nil {
+			return nil, err
+		}
+	}
+	if ch.email != nil {
+		email, err := userNameEmail(*ch.email)
+		if err != nil {
+			return nil, err
+		}
+		ch.email = &email
+	}
+	return s.update(ctx, orgID, id, ch)
+}
+
+// DeleteUser deprovisions the user: they leave the org as on deactivation, and the provider no longer sees them.
+func (s *Service) DeleteUser(ctx context.Context, orgID uuid.UUID, id string) error {
+	var signOut string
+	err := s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		u, _, err := s.managedUser(tx, orgID, id)
+		if err != nil {
+			return err
+		}
+		member, err := isMember(tx, u, orgID)
+		if err != nil {
+			return err
+		}
+		if member {
+			if err := deactivate(tx, orgID, u); err != nil {
+				return err
+			}
+			signOut = u.UserID.String()
+		}
+		return tx.Where("user_id = ? AND org_id = ?", u.UserID, orgID).Delete(&domain.SCIMUser{}).Error
+	})
+	if err != nil {
+		return err
+	}
+	policies.DestroyUserSessions(ctx, s.Rdb, signOut)
+	return nil
+}
+
+// apply records one patch operation. remove is only meaningful for externalId.
+func (ch *userChanges) apply(kind, path string, v json.RawMessage) error {
+	str := func() (*string, error) {
+		if kind == "remove" {
+			return nil, ErrInvalidPatch
+		}
+		s, err := stringValue(v)
+		return &s, err
+	}
+	var err error
+	switch strings.ToLower(path) {
+	case "active":
+		if kind == "remove" {
+			return ErrInvalidPatch
+		}
+		var b bool
+		b, err = boolValue(v)
+		ch.active = &b
+	case "username":
+		ch.email, err = str()
+	case "externalid":
+		if kind == "remove" {
+			empty := ""
+			ch.externalID = &empty
+			return nil
+		}
+		ch.externalID, err = str()
+	case "displayname", "name.formatted":
+		ch.fullname, err = str()
+	case "name.givenname":
+		ch.givenName, err = str()
+	case "name.familyname":
+		ch.familyName, err = str()
+	case "name":
+		if kind == "remove" {
+			return ErrInvalidPatch
+		}
+		var n Name
+		if json.Unmarshal(v, &n) != nil {
+			return ErrInvalidPatch
+		}
+		if n.Formatted != "" {
+			ch.fullname = &n.Formatted
+		}
+		if n.GivenName != "" {
+			ch.givenName = &n.GivenName
+		}
+		if n.FamilyName != "" {
+			ch.familyName = &n.FamilyName
+		}
+	}
+	return err
+}
+
+// update applies ch in one transaction. The email and name only change for users the provider created who
+// belong to no other org (ownsIdentity). Deactivation goes through the same membership policy as removing a
+// member (the provider acts as a superadmin, so the last superadmin still cannot be removed) and ends the
+// user's sessions.
+func (s *Service) update(ctx context.Context, orgID uuid.UUID, id string, ch userChanges) (*User, error) {
+	var u *domain.User
+	var link *domain.SCIMUser
+	var signOut string
+	err := s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		if u, link, err = s.managedUser(tx, orgID, id); err != nil {
+			return err
+		}
+		name := mergeName(u.Fullname, ch)
+		emailChanged := ch.email != nil && *ch.email != strings.ToLower(u.Email)
+		if emailChanged || (name != "" && name != u.Fullname) {
+			if err := ownsIdentity(tx, u, link, orgID); err != nil {
+				return err
+			}
+		}
+		if emailChanged {
+			if err := ownsDomain(tx, orgID, *ch.email); err != nil {
+				return err
+			}
+			var count int64
+			if err := tx.Model(&domain.User{}).Where("LOWER(email) = ? AND user_id <> ?", *ch.email, u.UserID).Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				return ErrUserExists
+			}
+			u.Email = *ch.email
+		}
+		if name != "" {
+			u.Fullname = name
+		}
+		member, err := isMember(tx, u, orgID)
+		if err != nil {
+			return err
+		}
+		if ch.active != nil && *ch.active != member {
+			// Reactivating adds the membership back; an org the user is working in stays their active org
+			if *ch.active {
+				if err := memberships.Add(tx, u, orgID, constants.Viewer, nil); err != nil {
+					return err
+				}
+			} else {
+				if err := deactivate(tx, orgID, u); err != nil {
+					return err
+				}
+				signOut = u.UserID.String()
+			}
+		}
+		if err := tx.Model(u).Select("email", "fullname").Updates(u).Error; err != nil {
+			return err
+		}
+		if link == nil {
+			link = &domain.SCIMUser{UserID: u.UserID, OrgID: orgID}
+		}
+		if ch.externalID != nil {
+			link.ExternalID = *ch.externalID
+		}
+		return tx.Save(link).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	policies.DestroyUserSessions(ctx, s.Rdb, signOut)
+	return s.view(ctx, orgID, u.UserID, link)
+}
+
+// ownsIdentity allows the org's provider to change u's email and name only when it created the account and u
+// belongs to no other org, whose members would otherwise see, and sign in as, an identity this org chose.
+func ownsIdentity(tx *gorm.DB, u *domain.User, link *domain.SCIMUser, orgID uuid.UUID) error {
+	if link == nil || !link.Provisioned {
+		return ErrIdentityManaged
+	}
+	ms, err := memberships.List(tx, u)
+	if err != nil {
+		return err
+	}
+	for _, m := range ms {
+		if m.OrgID != orgID {
+			return ErrIdentityManaged
+		}
+	}
+	return nil
+}
+
+// ownsDomain returns ErrUnverifiedDomain unless email is at one of the domains the org has verified for single
+// sign-on, as SSO requires before it signs anyone in, so an org cannot claim another company's addresses.
+func ownsDomain(tx *gorm.DB, orgID uuid.UUID, email string) error {
+	var cfg domain.OrgSSOConfig
+	err := tx.Where("org_id = ?", orgID).First(&cfg).Error
+	if err == gorm.ErrRecordNotFound {
+		return ErrUnverifiedDomain
+	}
+	if err != nil {
+		return err
+	}
+	_, d, _ := strings.Cut(email, "@")
+	for _, v := range cfg.VerifiedDomainList() {
+		if v == d {
+			return nil
+		}
+	}
+	return ErrUnverifiedDomain
+}
+
+// managedUser loads a member of the org, or a user its provider manages who has since left it.
+func (s *Service) managedUser(db *gorm.DB, orgID uuid.UUID, id string) (*domain.User, *domain.SCIMUser, error) {
+	userID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, nil, ErrUserNotFound
+	}
+	var u domain.User
+	if err := db.Where("user_id = ?", userID).First(&u).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil, ErrUserNotFound
+		}
+		return nil, nil, err
+	}
+	var row domain.SCIMUser
+	err = db.Where("user_id = ? AND org_id = ?", userID, orgID).First(&row).Error
+	if err == nil {
+		return &u, &row, nil
+	}
+	if err != gorm.ErrRecordNotFound {
+		return nil, nil, err
+	}
+	member, err := isMember(db, &u, orgID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !member {
+		return nil, nil, ErrUserNotFound
+	}
+	return &u, nil, nil
+}
+
+func (s *Service) view(ctx context.Context, orgID, userID uuid.UUID, link *domain.SCIMUser) (*User, error) {
+	db := s.DB.WithContext(ctx)
+	var u domain.User
+	if err := db.Where("user_id = ?", userID).First(&u).Error; err != nil {
+		return nil, err
+	}
+	roles, err := membershipsOf(db, orgID, []uuid.UUID{userID})
+	if err != nil {
+		return nil, err
+	}
+	out := userResource(&u, link, roles[userID])
+	return &out, nil
+}
+
+// deactivate ends u's membership of the org after ValidateOrgMembershipChange, as RemoveUserFromOrg does.
+func deactivate(tx *gorm.DB, orgID uuid.UUID, u *domain.User) error {
+	org := orgID.String()
+	if _, err := policies.ValidateOrgMembershipChange(tx, policies.ValidateOrgMembershipChangeParams{
+		ActorRole:    constants.Superadmin,
+		TargetUserID: u.UserID.String(),
+		OrgID:        &org,
+	}); err != nil {
+		return err
+	}
+	return memberships.Remove(tx, u, orgID)
+}
+
+// userResource renders u; m is their membership of the org, nil when they have been deactivated.
+func userResource(u *domain.User, link *domain.SCIMUser, m *domain.OrgMembership) User {
+	out := User{
+		Schemas:     []string{UserSchema},
+		ID:          u.UserID.String(),
+		UserName:    u.Email,
+		Name:        splitName(u.Fullname),
+		DisplayName: u.Fullname,
+		Emails:      []Email{{Value: u.Email, Type: "work", Primary: true}},
+		Active:      m != nil,
+		Groups:      []Ref{},
+		Meta:        Meta{ResourceType: "User", Created: &u.CreatedAt, LastModified: &u.UpdatedAt},
+	}
+	if link != nil {
+		out.ExternalID = link.ExternalID
+	}
+	if out.Active {
+		out.Groups = append(out.Groups, roleRef(m))
+	}
+	return out
+}
+
+// roleRef is the group a member is in: their custom role, else their built-in role.
+func roleRef(m *domain.OrgMembership) Ref {
+	if m.CustomRoleID != nil && m.CustomRole != nil {
+		return Ref{Value: m.CustomRoleID.String(), Display: m.CustomRole.Name}
+	}
+	return Ref{Value: m.Role, Display: m.Role}
+}
+
+func isMember(db *gorm.DB, u *domain.User, orgID uuid.UUID) (bool, error) {
+	_, err := memberships.Get(db, u, orgID)
+	if err == memberships.ErrNotMember {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// membershipsOf returns the org memberships of the users among ids, by user id, with custom roles loaded.
+func membershipsOf(db *gorm.DB, orgID uuid.UUID, ids []uuid.UUID) (map[uuid.UUID]*domain.OrgMembership, error) {
+	out := make(map[uuid.UUID]*domain.OrgMembership, len(ids))
+	if len(ids) == 0 {
+		return out, nil
+	}
+	var rows []domain.OrgMembership
+	if err := db.Table("(?) AS m", memberships.Members(db, orgID)).Where("m.user_id IN ?", ids).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	var roleIDs []uuid.UUID
+	for _, m := range rows {
+		if m.CustomRoleID != nil {
+			roleIDs = append(roleIDs, *m.CustomRoleID)
+		}
+	}
+	roles := make(map[uuid.UUID]*domain.CustomRole, len(roleIDs))
+	if len(roleIDs) > 0 {
+		var found []domain.CustomRole
+		if err := db.Where("role_id IN ?", roleIDs).Find(&found).Error; err != nil {
+			return nil, err
+		}
+		for i := range found {
+			roles[found[i].RoleID] = &found[i]
+		}
+	}
+	for i := range rows {
+		if rows[i].CustomRoleID != nil {
+			rows[i].CustomRole = roles[*rows[i].CustomRoleID]
+		}
+		out[rows[i].UserID] = &rows[i]
+	}
+	return out, nil
+}
+
+func userNameEmail(userName string) (string, error) {
+	email := strings.ToLower(strings.TrimSpace(userName))
+	if !validation.IsValidEmail(email) {
+		return "", ErrInvalidUserName
+	}
+	return email, nil
+}
+
+// inputFullname is name.formatted, else givenName familyName, else displayName.
+func inputFullname(in UserInput) string {
+	if in.Name != nil {
+		if f := strings.TrimSpace(in.Name.Formatted); f != "" {
+			return f
+		}
+		if f := strings.TrimSpace(in.Name.GivenName + " " + in.Name.FamilyName); f != "" {
+			return f
+		}
+	}
+	return strings.TrimSpace(in.DisplayName)
+}
+
+// mergeName returns the new full name for ch, filling a missing given or family name from current.
+func mergeName(current string, ch userChanges) string {
+	if ch.fullname != nil {
+		return strings.TrimSpace(*ch.fullname)
+	}
+	if ch.givenName == nil && ch.familyName == nil {
+		return ""
+	}
+	n := splitName(current)
+	if ch.givenName != nil {
+		n.GivenName = *ch.givenName
+	}
+	if ch.familyName != nil {
+		n.FamilyName = *ch.familyName
+	}
+	return strings.TrimSpace(strings.TrimSpace(n.GivenName) + " " + strings.TrimSpace(n.FamilyName))
+}
+
+// splitName treats the first word of a full name as the given name.
+func splitName(fullname string) Name {
+	given, family, _ := strings.Cut(strings.TrimSpace(fullname), " ")
+	return Name{Formatted: fullname, GivenName: given, FamilyName: strings.TrimSpace(family)}
+}
@@ -0,0 +1,457 @@
+package sso
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"troo-backend/internal/application/memberships"
+	"troo-backend/internal/domain"
+	"troo-backend/internal/pkg/constants"
+	"troo-backend/internal/pkg/oidc"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	stateKeyPrefix = "sso_state:"
+	// stateTTL bounds how long the user may spend at the identity provider.
+	stateTTL = 10 * time.Minute
+	// An org proves it owns a domain with a TXT record "troo-verification=<token>" at _troo-verification.<domain>.
+	verificationHostPrefix  = "_troo-verification."
+	verificationValuePrefix = "troo-verification="
+	// discoveryTTL is how long a provider's discovery document is reused before it is fetched again.
+	discoveryTTL = time.Hour
+	// idpTimeout bounds each call to an identity provider.
+	idpTimeout = 10 * time.Second
+)
+
+// defaultClient is used when Service.HTTPClient is nil; it only reaches public addresses.
+var defaultClient = oidc.NewClient(idpTimeout)
+
+var (
+	ErrNotConfigured    = errors.New("Single sign-on is not set up for this email domain")
+	ErrInvalidState     = errors.New("Invalid or expired sign-in request")
+	ErrSignInFailed     = errors.New("Single sign-on failed")
+	ErrEmailNotAllowed  = errors.New("Your identity provider returned an email outside your organization's domains")
+	ErrAccountConflict  = errors.New("An account with this email already exists outside your organization")
+	ErrConfigIncomplete = errors.New("Issuer, client_id and client_secret are required")
+	ErrDomainRequired   = errors.New("At least one email domain is required")
+	ErrInvalidDomain    = errors.New("Invalid email domain")
+	ErrDomainTaken      = errors.New("Email domain is already used by another organization")
+	ErrInvalidRole      = errors.New("Invalid default role")
+	ErrIdPUnreachable   = errors.New("Could not reach the identity provider")
+	ErrDomainNotClaimed = errors.New("Email domain is not in this organization's SSO configuration")
+	ErrDomainUnverified = errors.New("Domain verification TXT record not found")
+	ErrUnavailable      = errors.New("Single sign-on is not available")
+)
+
+// Service signs users in through their org's OpenID Connect provider (authorization code + PKCE) and
+// provisions them into the org on first sign-in.
+type Service struct {
+	DB  *gorm.DB
+	Rdb *redis.Client
+	// HTTPClient talks to identity providers; nil uses a client with a timeout that refuses non-public
+	// addresses, since issuers are entered by org admins.
+	HTTPClient *http.Client
+	// RedirectURL is the frontend page the provider returns to; it posts code and state to /auth/complete-sso.
+	RedirectURL string
+	// LookupTXT resolves DNS TXT records for domain verification; nil uses net.DefaultResolver.
+	LookupTXT func(ctx context.Context, name string) ([]string, error)
+
+	mu        sync.Mutex
+	providers map[string]cachedProvider
+}
+
+type cachedProvider struct {
+	provider  *oidc.Provider
+	fetchedAt time.Time
+}
+
+type ConfigInput struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string // empty keeps the stored secret
+	EmailDomains []string
+	DefaultRole  string
+	Enabled      bool
+}
+
+// pendingSignIn is stored under the state until the browser comes back. Binding is also set as a cookie on the
+// browser that started the sign-in, so a state lured into another browser cannot be completed there.
+type pendingSignIn struct {
+	OrgID    uuid.UUID `json:"org_id"`
+	Verifier string    `json:"verifier"`
+	Nonce    string    `json:"nonce"`
+	Binding  string    `json:"binding"`
+}
+
+// GetConfig returns the org's provider configuration, or nil when none is set.
+func (s *Service) GetConfig(ctx context.Context, orgID uuid.UUID) (*domain.OrgSSOConfig, error) {
+	var cfg domain.OrgSSOConfig
+	if err := s.DB.WithContext(ctx).Where("org_id = ?", orgID).First(&cfg).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// UpdateConfig creates or replaces the org's provider configuration. The issuer's discovery document must load.
+// Domains already verified stay verified while they remain listed; new ones must go through VerifyDomain.
+func (s *Service) UpdateConfig(ctx context.Context, orgID uuid.UUID, in ConfigInput) (*domain.OrgSSOConfig, error) {
+	existing, err := s.GetConfig(ctx, orgID)
+	if err != nil {
+		return nil, err
+	}
+	cfg := domain.OrgSSOConfig{OrgID: orgID}
+	if existing != nil {
+		cfg = *existing
+	}
+	cfg.Issuer = strings.TrimSuffix(strings.TrimSpace(in.Issuer), "/")
+	cfg.ClientID = strings.TrimSpace(in.ClientID)
+	if in.ClientSecret != "" {
+		cfg.ClientSecret = in.ClientSecret
+	}
+	if cfg.Issuer == "" || cfg.ClientID == "" || cfg.ClientSecret == "" {
+		return nil, ErrConfigIncomplete
+	}
+	cfg.DefaultRole = strings.TrimSpace(in.DefaultRole)
+	if cfg.DefaultRole == "" {
+		cfg.DefaultRole = constants.Viewer
+	}
+	// Provisioned users never start as superadmin; that stays a deliberate promotion.
+	if !constants.IsValidRole(cfg.DefaultRole) || cfg.DefaultRole == constants.Superadmin {
+		return nil, ErrInvalidRole
+	}
+	domains, err := normalizeDomains(in.EmailDomains)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkDomainsFree(s.DB.WithContext(ctx), orgID, domains); err != nil {
+		return nil, err
+	}
+	if _, err := s.discover(ctx, cfg.Issuer, true); err != nil {
+		return nil, ErrIdPUnreachable
+	}
+	b, _ := json.Marshal(domains)
+	cfg.EmailDomains = datatypes.JSON(b)
+	var verified []string
+	for _, d := range cfg.VerifiedDomainList() {
+		if contains(domains, d) {
+			verified = append(verified, d)
+		}
+	}
+	b, _ = json.Marshal(verified)
+	cfg.VerifiedDomains = datatypes.JSON(b)
+	if cfg.VerificationToken == "" {
+		cfg.VerificationToken = oidc.RandomString(24)
+	}
+	cfg.Enabled = in.Enabled
+	if err := s.DB.WithContext(ctx).Save(&cfg).Error; err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// VerificationRecord is the DNS TXT record (name and value) that proves the org owns d.
+func VerificationRecord(cfg *domain.OrgSSOConfig, d string) (name, value string) {
+	return verificationHostPrefix + d, verificationValuePrefix + cfg.VerificationToken
+}
+
+// VerifyDomain looks up d's verification TXT record and, when it carries the org's token, marks d verified so
+// sign-ins with its emails route to the org. A domain verified by another org stays theirs.
+func (s *Service) VerifyDomain(ctx context.Context, orgID uuid.UUID, d string) (*domain.OrgSSOConfig, error) {
+	d = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(d), "@")))
+	cfg, err := s.GetConfig(ctx, orgID)
+	if err != nil {
+		return nil, err
+	}
+	if cfg == nil || cfg.VerificationToken == "" || !contains(cfg.DomainList(), d) {
+		return nil, ErrDomainNotClaimed
+	}
+	if contains(cfg.VerifiedDomainList(), d) {
+		return cfg, nil
+	}
+	lookup := s.LookupTXT
+	if lookup == nil {
+		lookup = net.DefaultResolver.LookupTXT
+	}
+	name, want := VerificationRecord(cfg, d)
+	records, err := lookup(ctx, name)
+	if err != nil || !contains(records, want) {
+		return nil, ErrDomainUnverified
+	}
+	err = s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("org_id = ?", orgID).First(cfg).Error; err != nil {
+			return err
+		}
+		if err := checkDomainsFree(tx, orgID, []string{d}); err != nil {
+			return err
+		}
+		b, _ := json.Marshal(append(cfg.VerifiedDomainList(), d))
+		cfg.VerifiedDomains = datatypes.JSON(b)
+		return tx.Model(cfg).Update("verified_domains", cfg.VerifiedDomains).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Start routes email to its org's provider by domain and returns the URL to send the browser to, and the
+// binding the caller must set as a cookie on that browser and pass back to Complete.
+func (s *Service) Start(ctx context.Context, email string) (authURL, binding string, err error) {
+	cfg, err := s.configForDomain(ctx, emailDomain(email))
+	if err != nil {
+		return "", "", err
+	}
+	p, err := s.discover(ctx, cfg.Issuer, false)
+	if err != nil {
+		return "", "", ErrIdPUnreachable
+	}
+	state := oidc.RandomString(24)
+	pending := pendingSignIn{
+		OrgID: cfg.OrgID, Verifier: oidc.RandomString(32), Nonce: oidc.RandomString(16), Binding: oidc.RandomString(24),
+	}
+	b, _ := json.Marshal(pending)
+	if err := s.Rdb.Set(ctx, stateKeyPrefix+state, b, stateTTL).Err(); err != nil {
+		return "", "", err
+	}
+	return p.AuthCodeURL(cfg.ClientID, s.RedirectURL, state, pending.Nonce, pending.Verifier), pending.Binding, nil
+}
+
+// Complete redeems the provider's code for the sign-in started with state, from the browser holding binding,
+// and returns the user, created in the org on first sign-in (created is then true). Each state is usable once.
+func (s *Service) Complete(ctx context.Context, code, state, binding string) (user *domain.User, created bool, err error) {
+	if code == "" || state == "" || binding == "" {
+		return nil, false, ErrInvalidState
+	}
+	raw, err := s.Rdb.GetDel(ctx, stateKeyPrefix+state).Bytes()
+	if err == redis.Nil {
+		return nil, false, ErrInvalidState
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	var pending pendingSignIn
+	if err := json.Unmarshal(raw, &pending); err != nil {
+		return nil, false, ErrInvalidState
+	}
+	if subtle.ConstantTimeCompare([]byte(pending.Binding), []byte(binding)) != 1 {
+		return nil, false, ErrInvalidState
+	}
+	cfg, err := s.GetConfig(ctx, pending.OrgID)
+	if err != nil {
+		return nil, false, err
+	}
+	if cfg == nil || !cfg.Enabled {
+		return nil, false, ErrNotConfigured
+	}
+	p, err := s.discover(ctx, cfg.Issuer, false)
+	if err != nil {
+		return nil, false, ErrIdPUnreachable
+	}
+	idToken, err := p.Exchange(ctx, cfg.ClientID, cfg.ClientSecret, s.RedirectURL, code, pending.Verifier)
+	if err != nil {
+		return nil, false, ErrSignInFailed
+	}
+	claims, err := p.Verify(ctx, idToken, cfg.ClientID, pending.Nonce, time.Now())
+	if err != nil {
+		return nil, false, ErrSignInFailed
+	}
+	user, created, err = s.provision(ctx, cfg, claims)
+	if err != nil {
+		return nil, false, err
+	}
+	return user, created, nil
+}
+
+// provision finds the user the provider's subject is linked to, links an org member with the same email on
+// their first SSO sign-in, or creates a new member with the org's default role. An existing account that is
+// not already a member of the org is never linked, whatever email the provider asserts.
+func (s *Service) provision(ctx context.Context, cfg *domain.OrgSSOConfig, claims *oidc.Claims) (*domain.User, bool, error) {
+	var user domain.User
+	created := false
+	err := s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var ident domain.UserSSOIdentity
+		err := tx.Where("issuer = ? AND subject = ?", cfg.Issuer, claims.Subject).First(&ident).Error
+		if err == nil {
+			if err := tx.Where("user_id = ?", ident.UserID).First(&user).Error; err != nil {
+				return err
+			}
+			return requireMember(tx, &user, cfg.OrgID)
+		}
+		if err != gorm.ErrRecordNotFound {
+			return err
+		}
+
+		email := strings.ToLower(strings.TrimSpace(claims.Email))
+		// Only an email the provider explicitly vouches for may create or link an account.
+		if email == "" || claims.EmailVerified == nil || !*claims.EmailVerified || !contains(cfg.VerifiedDomainList(), emailDomain(email)) {
+			return ErrEmailNotAllowed
+		}
+		err = tx.Where("LOWER(email) = ?", email).First(&user).Error
+		switch {
+		case err == nil:
+			if err := requireMember(tx, &user, cfg.OrgID); err != nil {
+				return err
+			}
+		case err == gorm.ErrRecordNotFound:
+			name := strings.TrimSpace(claims.Name)
+			if name == "" {
+				name = email
+			}
+			// No password: SSO users sign in through the provider (or set one via forgot-password).
+			user = domain.User{
+				Fullname:      name,
+				UserName:      strings.SplitN(email, "@", 2)[0],
+				Email:         email,
+				EmailVerified: true,
+				Role:          cfg.DefaultRole,
+			}
+			if err := tx.Create(&user).Error; err != nil {
+				return err
+			}
+			if err := memberships.Join(tx, &user, cfg.OrgID, cfg.DefaultRole, nil); err != nil {
+				return err
+			}
+			created = true
+		default:
+			return err
+		}
+		return tx.Create(&domain.UserSSOIdentity{Issuer: cfg.Issuer, Subject: claims.Subject, UserID: user.UserID}).Error
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	db := s.DB.WithContext(ctx)
+	if err := db.Preload("PlatformOperator").Where("user_id = ?", user.UserID).First(&user).Error; err != nil {
+		return nil, false, err
+	}
+	// The session signs in to the SSO org whatever the user's home org; their Users row is left alone.
+	if _, err := memberships.Enter(db, &user, cfg.OrgID); err != nil {
+		return nil, false, err
+	}
+	return &user, created, nil
+}
+
+// requireMember refuses signing in to the SSO org accounts that are not members of it.
+func requireMember(tx *gorm.DB, user *domain.User, orgID uuid.UUID) error {
+	if _, err := memberships.Get(tx, user, orgID); err != nil {
+		if err == memberships.ErrNotMember {
+			return ErrAccountConflict
+		}
+		return err
+	}
+	return nil
+}
+
+// discover returns issuer's provider, reusing a discovery document fetched within discoveryTTL unless fresh
+// is set. Failures are not cached.
+func (s *Service) discover(ctx context.Context, issuer string, fresh bool) (*oidc.Provider, error) {
+	s.mu.Lock()
+	c, ok := s.providers[issuer]
+	s.mu.Unlock()
+	if ok && !fresh && time.Since(c.fetchedAt) < discoveryTTL {
+		return c.provider, nil
+	}
+	client := s.HTTPClient
+	if client == nil {
+		client = defaultClient
+	}
+	p, err := oidc.Discover(ctx, client, issuer)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	if s.providers == nil {
+		s.providers = map[string]cachedProvider{}
+	}
+	s.providers[issuer] = cachedProvider{provider: p, fetchedAt: time.Now()}
+	s.mu.Unlock()
+	return p, nil
+}
+
+func (s *Service) configForDomain(ctx context.Context, d string) (*domain.OrgSSOConfig, error) {
+	if d == "" {
+		return nil, ErrNotConfigured
+	}
+	var configs []domain.OrgSSOConfig
+	if err := s.DB.WithContext(ctx).Where("enabled = ?", true).Find(&configs).Error; err != nil {
+		return nil, err
+	}
+	for i := range configs {
+		if contains(configs[i].VerifiedDomainList(), d) {
+			return &configs[i], nil
+		}
+	}
+	return nil, ErrNotConfigured
+}
+
+// checkDomainsFree fails when another org has verified one of domains. Unverified claims do not block, so an
+// org cannot reserve a domain it does not own.
+func checkDomainsFree(db *gorm.DB, orgID uuid.UUID, domains []string) error {
+	var others []domain.OrgSSOConfig
+	if err := db.Where("org_id <> ?", orgID).Find(&others).Error; err != nil {
+		return err
+	}
+	for i := range others {
+		for _, d := range domains {
+			if contains(others[i].VerifiedDomainList(), d) {
+				return ErrDomainTaken
+			}
+		}
+	}
+	return nil
+}
+
+// normalizeDomains lower-cases, de-duplicates and sorts the domains.
+func normalizeDomains(in []string) ([]string, error) {
+	seen := map[string]bool{}
+	out := make([]string, 0, len(in))
+	for _, d := range in {
+		d = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(d), "@")))
+		if d == "" || !strings.Contains(d, ".") || strings.ContainsAny(d, "@/ ") {
+			return nil, ErrInvalidDomain
+		}
+		if !seen[d] {
+			seen[d] = true
+			out = append(out, d)
+		}
+	}
+	if len(out) == 0 {
+		return nil, ErrDomainRequired
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+func emailDomain(email string) string {
+	_, d, ok := strings.Cut(strings.ToLower(strings.TrimSpace(email)), "@")
+	if !ok {
+		return ""
+	}
+	return d
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,118 @@
+package subaccounts
+
+import (
+	"troo-backend/internal/application/holdings"
+	"troo-backend/internal/domain"
+	"troo-backend/internal/pkg/decimal"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// The org's Holding for a project is the total across its accounts; SubAccountHoldings attribute parts of it to
+// sub-accounts and the rest is the main account. Credit-moving paths lock the Holding first (see holdings lock
+// order), then the org's sub-account holdings for the project, so the split cannot change under them. A
+// SubAccount row is locked after its holdings, by Apply when it gives the sub-account a first holding and by
+// Delete, so credits cannot arrive in a sub-account that is being deleted.
+
+// Lock loads the sub-account holdings of orgIDs for projectID with SELECT ... FOR UPDATE, keyed by sub-account.
+// Rows are locked in org_id order, as LockHoldings does.
+func Lock(tx *gorm.DB, projectID uuid.UUID, orgIDs ...uuid.UUID) (map[uuid.UUID]*domain.SubAccountHolding, error) {
+	var rows []domain.SubAccountHolding
+	if err := holdings.ForUpdate(tx).
+		Where("project_id = ? AND org_id IN ?", projectID, orgIDs).
+		Order("org_id, sub_account_id").
+		Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	out := make(map[uuid.UUID]*domain.SubAccountHolding, len(rows))
+	for i := range rows {
+		out[rows[i].SubAccountID] = &rows[i]
+	}
+	return out, nil
+}
+
+// Available returns the credits an account may sell, transfer or retire from h: what it holds less what it has
+// listed. subAccountID nil is the main account, which gets what the sub-accounts do not hold. Sub-accounts
+// listing more than they hold, or together holding more than the org has available, are ErrLedgerMismatch.
+func Available(tx *gorm.DB, h *domain.Holding, subAccountID *uuid.UUID) (decimal.Decimal, error) {
+	if h == nil {
+		return decimal.Zero, nil
+	}
+	subs, err := Lock(tx, h.ProjectID, h.OrgID)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	main := h.CreditBalance.Sub(h.LockedForSale)
+	for _, s := range subs {
+		free := s.CreditBalance.Sub(s.LockedForSale)
+		if free.IsNegative() {
+			return decimal.Zero, ErrLedgerMismatch
+		}
+		main = main.Sub(free)
+	}
+	if main.IsNegative() {
+		return decimal.Zero, ErrLedgerMismatch
+	}
+	if subAccountID == nil {
+		return main, nil
+	}
+	s, ok := subs[*subAccountID]
+	if !ok {
+		return decimal.Zero, nil
+	}
+	return s.CreditBalance.Sub(s.LockedForSale), nil
+}
+
+// Apply adds balance and locked to the sub-account's holding for projectID, creating it when needed. The main
+// account (nil) has no row: the org's Holding already carries the change. Callers check Available before taking
+// credits out, so a result below zero, or listing more than the row holds, is ErrLedgerMismatch. A sub-account
+// deleted since the caller's Check is ErrNotFound.
+func Apply(tx *gorm.DB, orgID uuid.UUID, subAccountID *uuid.UUID, projectID uuid.UUID, balance, locked decimal.Decimal) error {
+	if subAccountID == nil {
+		return nil
+	}
+	var row domain.SubAccountHolding
+	err := holdings.ForUpdate(tx).Where("sub_account_id = ? AND project_id = ?", *subAccountID, projectID).First(&row).Error
+	if err == gorm.ErrRecordNotFound {
+		// Waits for a concurrent Delete, which then has removed the sub-account: ErrNotFound.
+		if _, err := Find(holdings.ForUpdate(tx), orgID, *subAccountID); err != nil {
+			return err
+		}
+		row = domain.SubAccountHolding{SubAccountID: *subAccountID, ProjectID: projectID, OrgID: orgID}
+		if err := tx.Create(&row).Error; err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+	newBalance, newLocked := row.CreditBalance.Add(balance), row.LockedForSale.Add(locked)
+	if newBalance.IsNegative() || newLocked.IsNegative() || newLocked.GreaterThan(newBalance) {
+		return ErrLedgerMismatch
+	}
+	return tx.Model(&row).Updates(map[string]interface{}{
+		"credit_balance":  newBalance,
+		"locked_for_sale": newLocked,
+	}).Error
+}
+
+// Find returns the org's sub-account, or ErrNotFound when it does not exist or belongs to another org.
+func Find(db *gorm.DB, orgID, subAccountID uuid.UUID) (*domain.SubAccount, error) {
+	var s domain.SubAccount
+	if err := db.Where("sub_account_id = ? AND org_id = ?", subAccountID, orgID).First(&s).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Check returns ErrNotFound unless subAccountID is nil (the main account) or one of the org's sub-accounts.
+func Check(db *gorm.DB, orgID uuid.UUID, subAccountID *uuid.UUID) error {
+	if subAccountID == nil {
+		return nil
+	}
+	_, err := Find(db, orgID, *subAccountID)
+	return err
+}
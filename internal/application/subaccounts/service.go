@@ -0,0 +1,297 @@
+// Package subaccounts splits an org's credits across sub-accounts (business units, clients, funds). Holdings,
+// listings, transfers and retirements name the sub-account they belong to; credits no sub-account holds are the
+// org's main account, which is also where credits from other orgs arrive unless a buy names a sub-account.
+package subaccounts
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"troo-backend/internal/application/holdings"
+	"troo-backend/internal/domain"
+	"troo-backend/internal/pkg/decimal"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// TypeInternalTransfer is the Transactions.type of a move between two accounts of the same org.
+const TypeInternalTransfer = "internal_transfer"
+
+var (
+	ErrNotFound     = errors.New("Sub-account not found")
+	ErrNameRequired = errors.New("Sub-account name is required")
+	ErrNameTaken    = errors.New("A sub-account with this name already exists")
+	ErrNotEmpty     = errors.New("Sub-account still holds or lists credits")
+	ErrSameAccount  = errors.New("Cannot transfer to the same account")
+	// ErrLedgerMismatch means the sub-accounts hold or list more than the org's Holding, e.g. after Express moved
+	// credits without them; nothing is moved until the split is corrected.
+	ErrLedgerMismatch = errors.New("Sub-account holdings do not match the organization's holdings")
+)
+
+type Service struct {
+	DB *gorm.DB
+}
+
+// AccountHolding is one project's credits in an account.
+type AccountHolding struct {
+	ProjectID     uuid.UUID       `json:"project_id"`
+	CreditBalance decimal.Decimal `json:"credit_balance"`
+	LockedForSale decimal.Decimal `json:"locked_for_sale"`
+}
+
+// Account is the report of one account: the main account has no SubAccount.
+type Account struct {
+	*domain.SubAccount
+	Holdings      []AccountHolding `json:"holdings"`
+	CreditBalance decimal.Decimal  `json:"credit_balance"`
+	LockedForSale decimal.Decimal  `json:"locked_for_sale"`
+	Retired       decimal.Decimal  `json:"retired"`
+}
+
+// Report is an org's credits by account.
+type Report struct {
+	Main        Account   `json:"main"`
+	SubAccounts []Account `json:"sub_accounts"`
+}
+
+// Report returns the org's sub-accounts, oldest first, and its main account, each with its holdings and the
+// credits it has retired.
+func (s *Service) Report(ctx context.Context, orgID uuid.UUID) (*Report, error) {
+	db := s.DB.WithContext(ctx)
+	var subs []domain.SubAccount
+	if err := db.Where("org_id = ?", orgID).Order(`"createdAt" ASC, name`).Find(&subs).Error; err != nil {
+		return nil, err
+	}
+	var rows []domain.SubAccountHolding
+	if err := db.Where("org_id = ?", orgID).Order("project_id").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	var orgHoldings []domain.Holding
+	if err := db.Where("org_id = ?", orgID).Order("project_id").Find(&orgHoldings).Error; err != nil {
+		return nil, err
+	}
+	var retired []struct {
+		SubAccountID *uuid.UUID
+		Total        decimal.Decimal
+	}
+	if err := db.Model(&domain.Transaction{}).
+		Select("sub_account_id, SUM(amount) AS total").
+		Where("type = ? AND from_org_id = ?", "retire", orgID).
+		Group("sub_account_id").
+		Scan(&retired).Error; err != nil {
+		return nil, err
+	}
+
+	// The main account holds each project's total less what the sub-accounts hold.
+	type split struct{ balance, locked decimal.Decimal }
+	allocated := map[uuid.UUID]split{}
+	bySub := map[uuid.UUID][]AccountHolding{}
+	for _, r := range rows {
+		a := allocated[r.ProjectID]
+		allocated[r.ProjectID] = split{a.balance.Add(r.CreditBalance), a.locked.Add(r.LockedForSale)}
+		if r.CreditBalance.IsZero() && r.LockedForSale.IsZero() {
+			continue
+		}
+		bySub[r.SubAccountID] = append(bySub[r.SubAccountID], AccountHolding{r.ProjectID, r.CreditBalance, r.LockedForSale})
+	}
+	out := &Report{SubAccounts: []Account{}}
+	var main []AccountHolding
+	for _, h := range orgHoldings {
+		a := allocated[h.ProjectID]
+		balance := h.CreditBalance.Sub(a.balance)
+		locked := h.LockedForSale.Sub(a.locked)
+		if balance.IsNegative() || locked.IsNegative() {
+			return nil, ErrLedgerMismatch
+		}
+		if balance.IsZero() && locked.IsZero() {
+			continue
+		}
+		main = append(main, AccountHolding{h.ProjectID, balance, locked})
+	}
+	out.Main = account(nil, main)
+	for i := range subs {
+		out.SubAccounts = append(out.SubAccounts, account(&subs[i], bySub[subs[i].SubAccountID]))
+	}
+	for _, r := range retired {
+		if r.SubAccountID == nil {
+			out.Main.Retired = r.Total
+			continue
+		}
+		for i := range out.SubAccounts {
+			if out.SubAccounts[i].SubAccountID == *r.SubAccountID {
+				out.SubAccounts[i].Retired = r.Total
+			}
+		}
+	}
+	return out, nil
+}
+
+func account(sub *domain.SubAccount, hs []AccountHolding) Account {
+	a := Account{SubAccount: sub, Holdings: hs, CreditBalance: decimal.Zero, LockedForSale: decimal.Zero, Retired: decimal.Zero}
+	if a.Holdings == nil {
+		a.Holdings = []AccountHolding{}
+	}
+	for _, h := range hs {
+		a.CreditBalance = a.CreditBalance.Add(h.CreditBalance)
+		a.LockedForSale = a.LockedForSale.Add(h.LockedForSale)
+	}
+	return a
+}
+
+// Create adds a sub-account to the org. Names are unique within the org.
+func (s *Service) Create(ctx context.Context, orgID, userID uuid.UUID, name string, description *string) (*domain.SubAccount, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, ErrNameRequired
+	}
+	db := s.DB.WithContext(ctx)
+	if err := s.checkName(db, orgID, uuid.Nil, name); err != nil {
+		return nil, err
+	}
+	sub := domain.SubAccount{OrgID: orgID, Name: name, Description: description, CreatedBy: &userID}
+	if err := db.Create(&sub).Error; err != nil {
+		return nil, err
+	}
+	return &sub, nil
+}
+
+// Update renames the sub-account and/or replaces its description; nil leaves a field unchanged.
+func (s *Service) Update(ctx context.Context, orgID, subAccountID uuid.UUID, name, description *string) (*domain.SubAccount, error) {
+	db := s.DB.WithContext(ctx)
+	sub, err := Find(db, orgID, subAccountID)
+	if err != nil {
+		return nil, err
+	}
+	if name != nil {
+		n := strings.TrimSpace(*name)
+		if n == "" {
+			return nil, ErrNameRequired
+		}
+		if err := s.checkName(db, orgID, subAccountID, n); err != nil {
+			return nil, err
+		}
+		sub.Name = n
+	}
+	if description != nil {
+		sub.Description = description
+	}
+	if err := db.Save(sub).Error; err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+// Delete removes an empty sub-account: it may hold no credits and have no open listings. Its holdings and then
+// the sub-account are locked (see Apply), so credits cannot arrive between the check and the delete.
+func (s *Service) Delete(ctx context.Context, orgID, subAccountID uuid.UUID) error {
+	return s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var rows []domain.SubAccountHolding
+		if err := holdings.ForUpdate(tx).Where("sub_account_id = ?", subAccountID).Order("project_id").Find(&rows).Error; err != nil {
+			return err
+		}
+		if _, err := Find(holdings.ForUpdate(tx), orgID, subAccountID); err != nil {
+			return err
+		}
+		var held, listed int64
+		for _, r := range rows {
+			if !r.CreditBalance.IsZero() || !r.LockedForSale.IsZero() {
+				held++
+			}
+		}
+		if err := tx.Model(&domain.Listing{}).
+			Where("sub_account_id = ? AND status = ?", subAccountID, "open").
+			Count(&listed).Error; err != nil {
+			return err
+		}
+		if held > 0 || listed > 0 {
+			return ErrNotEmpty
+		}
+		if err := tx.Where("sub_account_id = ?", subAccountID).Delete(&domain.SubAccountHolding{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("sub_account_id = ?", subAccountID).Delete(&domain.SubAccount{}).Error
+	})
+}
+
+// Transfer moves credits of a project between two of the org's accounts (nil is the main account). The org's
+// total does not change; the move is recorded as an internal_transfer transaction.
+func (s *Service) Transfer(ctx context.Context, actorID *uuid.UUID, orgID, projectID uuid.UUID, from, to *uuid.UUID, amount decimal.Decimal) (*domain.Transaction, error) {
+	if (from == nil && to == nil) || (from != nil && to != nil && *from == *to) {
+		return nil, ErrSameAccount
+	}
+	var record *domain.Transaction
+	err := s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := Check(tx, orgID, from); err != nil {
+			return err
+		}
+		if err := Check(tx, orgID, to); err != nil {
+			return err
+		}
+		locked, err := holdings.LockHoldings(tx, projectID, orgID)
+		if err != nil {
+			return err
+		}
+		available, err := Available(tx, locked[orgID], from)
+		if err != nil {
+			return err
+		}
+		if available.LessThan(amount) {
+			return errors.New("Insufficient available credits to transfer")
+		}
+		if err := Apply(tx, orgID, from, projectID, amount.Neg(), decimal.Zero); err != nil {
+			return err
+		}
+		if err := Apply(tx, orgID, to, projectID, amount, decimal.Zero); err != nil {
+			return err
+		}
+		record = &domain.Transaction{
+			Type:           TypeInternalTransfer,
+			FromOrgID:      &orgID,
+			ToOrgID:        &orgID,
+			ProjectID:      projectID,
+			Amount:         amount,
+			ActorUserID:    actorID,
+			SubAccountID:   from,
+			ToSubAccountID: to,
+		}
+		return tx.Create(record).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// Transactions returns the account's transactions, newest first: credits that left it (sub_account_id) or
+// arrived in it (to_sub_account_id). subAccountID nil is the main account.
+func (s *Service) Transactions(ctx context.Context, orgID uuid.UUID, subAccountID *uuid.UUID) ([]domain.Transaction, error) {
+	db := s.DB.WithContext(ctx)
+	if err := Check(db, orgID, subAccountID); err != nil {
+		return nil, err
+	}
+	q := db.Where("(from_org_id = ? AND sub_account_id = ?) OR (to_org_id = ? AND to_sub_account_id = ?)", orgID, subAccountID, orgID, subAccountID)
+	if subAccountID == nil {
+		q = db.Where("(from_org_id = ? AND sub_account_id IS NULL) OR (to_org_id = ? AND to_sub_account_id IS NULL)", orgID, orgID)
+	}
+	out := []domain.Transaction{}
+	if err := q.Order(`"createdAt" DESC`).Find(&out).Error; err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// checkName returns ErrNameTaken if another of the org's sub-accounts (not except) has the name, ignoring case.
+func (s *Service) checkName(db *gorm.DB, orgID, except uuid.UUID, name string) error {
+	var count int64
+	if err := db.Model(&domain.SubAccount{}).
+		Where("org_id = ? AND LOWER(name) = ? AND sub_account_id <> ?", orgID, strings.ToLower(name), except).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrNameTaken
+	}
+	return nil
+}
@@ -0,0 +1,207 @@
+//go:build integration
+// +build integration
+
+package trading
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	listsvc "troo-backend/internal/application/listings"
+	"troo-backend/internal/application/subaccounts"
+	"troo-backend/internal/domain"
+	"troo-backend/internal/pkg/decimal"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// These tests race the credit-moving paths on Postgres, where FOR UPDATE really blocks, when DATABASE_URL_TEST is set.
+// Run with: go test -tags=integration ./internal/application/trading/... -run TestConcurrent -v
+func setupPostgres(t *testing.T) *gorm.DB {
+	dsn := os.Getenv("DATABASE_URL_TEST")
+	if dsn == "" {
+		t.Skip("DATABASE_URL_TEST not set, skipping integration test")
+	}
+	cfg := &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)}
+	admin, err := gorm.Open(postgres.Open(dsn), cfg)
+	require.NoError(t, err)
+	// Each test gets its own schema, dropped afterwards.
+	schema := "trading_test_" + strings.ReplaceAll(uuid.NewString(), "-", "")
+	require.NoError(t, admin.Exec("CREATE SCHEMA "+schema).Error)
+	t.Cleanup(func() {
+		admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+		if sqlDB, err := admin.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	sep := " "
+	if strings.Contains(dsn, "://") {
+		sep = "?"
+		if strings.Contains(dsn, "?") {
+			sep = "&"
+		}
+	}
+	db, err := gorm.Open(postgres.Open(dsn+sep+"search_path="+schema), cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	migrate(t, db)
+	return db
+}
+
+// run starts n goroutines together and returns how many calls succeeded.
+func run(n int, fn func(i int) error) int {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	ok := 0
+	start := make(chan struct{})
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			if fn(i) == nil {
+				mu.Lock()
+				ok++
+				mu.Unlock()
+			}
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+	return ok
+}
+
+func TestConcurrentSellCredits_NoOversell(t *testing.T) {
+	f := newFixture(t, setupPostgres(t), 100)
+	svc := &Service{DB: f.db}
+
+	// 20 sells of 10 against a balance of 100: exactly 10 may succeed.
+	ok := run(20, func(i int) error {
+		_, err := svc.SellCredits(context.Background(), nil, f.orgID, nil, f.projectID, decimal.NewFromInt(10), decimal.NewFromInt(int64(5+i%3)))
+		return err
+	})
+	assert.Equal(t, 10, ok)
+
+	h := f.holding(t, f.orgID)
+	assert.Equal(t, "100", h.CreditBalance.String())
+	assert.Equal(t, "100", h.LockedForSale.String())
+
+	assert.Equal(t, "100", f.listed(t).String())
+}
+
+func TestConcurrentTransferAndRetire_NoOverdraw(t *testing.T) {
+	f := newFixture(t, setupPostgres(t), 50)
+	svc := &Service{DB: f.db}
+
+	// Mixed transfers and retirements of 5 against a balance of 50: exactly 10 may succeed.
+	ok := run(30, func(i int) error {
+		if i%2 == 0 {
+			_, err := svc.TransferCredits(context.Background(), nil, f.orgID, nil, f.projectID, f.otherOrg.OrgCode, decimal.NewFromInt(5))
+			return err
+		}
+		_, err := svc.RetireCredits(context.Background(), nil, f.orgID, nil, f.projectID, decimal.NewFromInt(5), nil, nil)
+		return err
+	})
+	assert.Equal(t, 10, ok)
+
+	sender := f.holding(t, f.orgID)
+	assert.Equal(t, "0", sender.CreditBalance.String())
+
+	// Receiver holding must be created once, not once per concurrent first transfer.
+	var receivers int64
+	require.NoError(t, f.db.Model(&domain.Holding{}).Where("org_id = ? AND project_id = ?", f.otherOrg.OrgID, f.projectID).Count(&receivers).Error)
+	var transfers, retires int64
+	require.NoError(t, f.db.Model(&domain.Transaction{}).Where("type = ?", "transfer").Count(&transfers).Error)
+	require.NoError(t, f.db.Model(&domain.Transaction{}).Where("type = ?", "retire").Count(&retires).Error)
+	if transfers > 0 {
+		assert.Equal(t, int64(1), receivers)
+		assert.Equal(t, decimal.NewFromInt(transfers*5).String(), f.holding(t, f.otherOrg.OrgID).CreditBalance.String())
+	}
+	assert.Equal(t, int64(10), transfers+retires)
+}
+
+func TestConcurrentEditListingAndSell_NoOversell(t *testing.T) {
+	f := newFixture(t, setupPostgres(t), 100)
+	svc := &Service{DB: f.db}
+	ls := &listsvc.Service{DB: f.db}
+
+	res, err := svc.SellCredits(context.Background(), nil, f.orgID, nil, f.projectID, decimal.NewFromInt(10), decimal.NewFromInt(5))
+	require.NoError(t, err)
+	listingID := res["listing_id"].(uuid.UUID)
+
+	// Edits growing the listing to 100 race sells of the same remaining 90 credits at another price:
+	// only one of them can win.
+	ok := run(20, func(i int) error {
+		if i%2 == 0 {
+			qty := decimal.NewFromInt(100)
+			_, err := ls.EditListing(context.Background(), listsvc.EditListingInput{
+				ListingID: listingID, OrgID: f.orgID, NewQuantity: &qty,
+			})
+			return err
+		}
+		_, err := svc.SellCredits(context.Background(), nil, f.orgID, nil, f.projectID, decimal.NewFromInt(90), decimal.NewFromInt(7))
+		return err
+	})
+	assert.Equal(t, 1, ok)
+
+	h := f.holding(t, f.orgID)
+	assert.Equal(t, "100", h.LockedForSale.String())
+
+	assert.Equal(t, h.LockedForSale.String(), f.listed(t).String())
+}
+
+func TestConcurrentCancelListing_ReleasesOnce(t *testing.T) {
+	f := newFixture(t, setupPostgres(t), 100)
+	svc := &Service{DB: f.db}
+	ls := &listsvc.Service{DB: f.db}
+
+	res, err := svc.SellCredits(context.Background(), nil, f.orgID, nil, f.projectID, decimal.NewFromInt(40), decimal.NewFromInt(5))
+	require.NoError(t, err)
+	listingID := res["listing_id"].(uuid.UUID)
+
+	ok := run(10, func(int) error {
+		_, err := ls.CancelListing(context.Background(), nil, listingID, f.orgID)
+		return err
+	})
+	assert.Equal(t, 1, ok)
+	assert.Equal(t, "0", f.holding(t, f.orgID).LockedForSale.String())
+}
+
+func TestConcurrentSubAccountDeleteAndTransfer_NoOrphanedCredits(t *testing.T) {
+	f := newFixture(t, setupPostgres(t), 100)
+	ss := &subaccounts.Service{DB: f.db}
+
+	for round := 0; round < 10; round++ {
+		sub, err := ss.Create(context.Background(), f.orgID, uuid.New(), "Fund "+strconv.Itoa(round), nil)
+		require.NoError(t, err)
+		// Moves into the empty sub-account race its deletion: either the moves land first and the delete
+		// is refused, or the sub-account is gone and the moves are refused.
+		run(6, func(i int) error {
+			if i == 0 {
+				return ss.Delete(context.Background(), f.orgID, sub.SubAccountID)
+			}
+			_, err := ss.Transfer(context.Background(), nil, f.orgID, f.projectID, nil, &sub.SubAccountID, decimal.NewFromInt(1))
+			return err
+		})
+		var subs, rows int64
+		require.NoError(t, f.db.Model(&domain.SubAccount{}).Where("sub_account_id = ?", sub.SubAccountID).Count(&subs).Error)
+		require.NoError(t, f.db.Model(&domain.SubAccountHolding{}).Where("sub_account_id = ?", sub.SubAccountID).Count(&rows).Error)
+		if subs == 0 {
+			assert.Zero(t, rows, "round %d", round)
+		}
+	}
+}
@@ -5,10 +5,13 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"math"
 	"time"
 
+	"troo-backend/internal/application/holdings"
+	"troo-backend/internal/application/subaccounts"
+	"troo-backend/internal/application/verification"
 	"troo-backend/internal/domain"
+	"troo-backend/internal/pkg/decimal"
 
 	"github.com/google/uuid"
 	"gorm.io/datatypes"
@@ -19,10 +22,28 @@ type Service struct {
 	DB *gorm.DB
 }
 
+// CheckSeller refuses a purchase from a listing whose org seller is not verified (see verification), before the
+// buyer is charged; the webhook then delivers what was paid for. A listing that does not exist is left to the
+// webhook, which refuses it as before.
+func (s *Service) CheckSeller(ctx context.Context, listingID uuid.UUID) error {
+	var listing domain.Listing
+	err := s.DB.WithContext(ctx).Select("seller_id").Where("listing_id = ?", listingID).First(&listing).Error
+	if err == gorm.ErrRecordNotFound || (err == nil && listing.SellerID == nil) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return verification.Require(s.DB.WithContext(ctx), *listing.SellerID)
+}
+
 // SellCredits mirrors Express sellCreditsService (transactional).
 // When a person sells credits we never create a new holding: we only edit their existing holding
 // and set the amount they list under locked_for_sale (same as Express).
-func (s *Service) SellCredits(ctx context.Context, orgID, projectID uuid.UUID, amount, price float64) (map[string]interface{}, error) {
+// actorID is the session user listing the credits; it is recorded on the listing event.
+// subAccountID is the sub-account the credits are listed from; nil lists from the org's main account.
+// Only verified orgs may sell (see verification).
+func (s *Service) SellCredits(ctx context.Context, actorID *uuid.UUID, orgID uuid.UUID, subAccountID *uuid.UUID, projectID uuid.UUID, amount, price decimal.Decimal) (map[string]interface{}, error) {
 	var result map[string]interface{}
 
 	err := s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
@@ -33,18 +54,29 @@ func (s *Service) SellCredits(ctx context.Context, orgID, projectID uuid.UUID, a
 			}
 			return err
 		}
+		if err := verification.Require(tx, orgID); err != nil {
+			return err
+		}
+		if err := subaccounts.Check(tx, orgID, subAccountID); err != nil {
+			return err
+		}
 
 		// Must find existing holding only — never create a new one when selling (match Express).
-		var holding domain.Holding
-		if err := tx.Where("org_id = ? AND project_id = ?", orgID, projectID).First(&holding).Error; err != nil {
-			if err == gorm.ErrRecordNotFound {
-				return errors.New("No holdings found for this project")
-			}
+		// Locked before the listing (see holdings lock order) so concurrent sells cannot both pass the balance check.
+		locked, err := holdings.LockHoldings(tx, projectID, orgID)
+		if err != nil {
 			return err
 		}
+		holding, ok := locked[orgID]
+		if !ok {
+			return errors.New("No holdings found for this project")
+		}
 
-		available := holding.CreditBalance - holding.LockedForSale
-		if available < amount {
+		available, err := subaccounts.Available(tx, holding, subAccountID)
+		if err != nil {
+			return err
+		}
+		if available.LessThan(amount) {
 			return errors.New("Insufficient credits to sell")
 		}
 
@@ -57,16 +89,25 @@ func (s *Service) SellCredits(ctx context.Context, orgID, projectID uuid.UUID, a
 		}
 
 		var existingListing domain.Listing
-		err := tx.Where("seller_id = ? AND project_id = ? AND price_per_credit = ? AND status = ?", orgID, projectID, price, "open").First(&existingListing).Error
+		q := holdings.ForUpdate(tx).Where("seller_id = ? AND project_id = ? AND price_per_credit = ? AND status = ?", orgID, projectID, price, "open")
+		if subAccountID == nil {
+			q = q.Where("sub_account_id IS NULL")
+		} else {
+			q = q.Where("sub_account_id = ?", *subAccountID)
+		}
+		err = q.First(&existingListing).Error
 
 		if err == nil {
-			existingListing.CreditsAvailable = math.Round((existingListing.CreditsAvailable+amount)*100) / 100
+			existingListing.CreditsAvailable = existingListing.CreditsAvailable.Add(amount)
 			if err := tx.Save(&existingListing).Error; err != nil {
 				return err
 			}
 			// Edit existing holding only: add listed amount to locked_for_sale (no new holding).
-			holding.LockedForSale = math.Round((holding.LockedForSale+amount)*100) / 100
-			if err := tx.Save(&holding).Error; err != nil {
+			holding.LockedForSale = holding.LockedForSale.Add(amount)
+			if err := tx.Save(holding).Error; err != nil {
+				return err
+			}
+			if err := subaccounts.Apply(tx, orgID, subAccountID, projectID, decimal.Zero, amount); err != nil {
 				return err
 			}
 			eventDataBytes, _ := json.Marshal(map[string]interface{}{
@@ -78,6 +119,7 @@ func (s *Service) SellCredits(ctx context.Context, orgID, projectID uuid.UUID, a
 				ListingID:    existingListing.ListingID,
 				EventType:    "UPDATED",
 				ActorOrgCode: &org.OrgCode,
+				ActorUserID:  actorID,
 				EventData:    datatypes.JSON(eventDataBytes),
 			}).Error; err != nil {
 				return err
@@ -90,8 +132,11 @@ func (s *Service) SellCredits(ctx context.Context, orgID, projectID uuid.UUID, a
 		}
 
 		// Edit existing holding only: add listed amount to locked_for_sale, then create new Listing (no new holding).
-		holding.LockedForSale = math.Round((holding.LockedForSale+amount)*100) / 100
-		if err := tx.Save(&holding).Error; err != nil {
+		holding.LockedForSale = holding.LockedForSale.Add(amount)
+		if err := tx.Save(holding).Error; err != nil {
+			return err
+		}
+		if err := subaccounts.Apply(tx, orgID, subAccountID, projectID, decimal.Zero, amount); err != nil {
 			return err
 		}
 
@@ -102,6 +147,7 @@ func (s *Service) SellCredits(ctx context.Context, orgID, projectID uuid.UUID, a
 
 		listing := domain.Listing{
 			SellerID:         &orgID,
+			SubAccountID:     subAccountID,
 			ProjectID:        projectID,
 			CreditsAvailable: amount,
 			PricePerCredit:   price,
@@ -128,6 +174,7 @@ func (s *Service) SellCredits(ctx context.Context, orgID, projectID uuid.UUID, a
 			ListingID:    listing.ListingID,
 			EventType:    "CREATED",
 			ActorOrgCode: &org.OrgCode,
+			ActorUserID:  actorID,
 			EventData:    datatypes.JSON(eventDataBytes),
 		}).Error; err != nil {
 			return err
@@ -142,13 +189,15 @@ func (s *Service) SellCredits(ctx context.Context, orgID, projectID uuid.UUID, a
 	return result, err
 }
 
-// TransferCredits mirrors Express transferCreditsService (transactional).
-func (s *Service) TransferCredits(ctx context.Context, fromOrgID, projectID uuid.UUID, toOrgCode string, amount float64) (map[string]interface{}, error) {
+// TransferCredits mirrors Express transferCreditsService (transactional). Only verified orgs may send credits.
+// They leave fromSubAccountID (nil for the main account) and arrive in the target org's main account.
+func (s *Service) TransferCredits(ctx context.Context, actorID *uuid.UUID, fromOrgID uuid.UUID, fromSubAccountID *uuid.UUID, projectID uuid.UUID, toOrgCode string, amount decimal.Decimal) (map[string]interface{}, error) {
 	var result map[string]interface{}
 
 	err := s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		var toOrg domain.Org
-		if err := tx.Where("org_code = ?", toOrgCode).First(&toOrg).Error; err != nil {
+		// Closed orgs keep their code but can no longer receive credits.
+		if err := tx.Where("org_code = ? AND closed_at IS NULL", toOrgCode).First(&toOrg).Error; err != nil {
 			if err == gorm.ErrRecordNotFound {
 				return errors.New("Target organization not found")
 			}
@@ -158,51 +207,50 @@ func (s *Service) TransferCredits(ctx context.Context, fromOrgID, projectID uuid
 		if fromOrgID == toOrg.OrgID {
 			return errors.New("Cannot transfer to the same organization")
 		}
+		if err := verification.Require(tx, fromOrgID); err != nil {
+			return err
+		}
+		if err := subaccounts.Check(tx, fromOrgID, fromSubAccountID); err != nil {
+			return err
+		}
 
-		var sender domain.Holding
-		if err := tx.Where("org_id = ? AND project_id = ?", fromOrgID, projectID).First(&sender).Error; err != nil {
-			if err == gorm.ErrRecordNotFound {
-				return errors.New("No Holdings found for this project")
-			}
+		locked, err := holdings.LockHoldings(tx, projectID, fromOrgID, toOrg.OrgID)
+		if err != nil {
 			return err
 		}
+		sender, ok := locked[fromOrgID]
+		if !ok {
+			return errors.New("No Holdings found for this project")
+		}
 
-		available := sender.CreditBalance - sender.LockedForSale
-		if available < amount {
+		available, err := subaccounts.Available(tx, sender, fromSubAccountID)
+		if err != nil {
+			return err
+		}
+		if available.LessThan(amount) {
 			return errors.New("Insufficient available credits to transfer")
 		}
 
-		sender.CreditBalance = math.Round((sender.CreditBalance-amount)*100) / 100
-		if err := tx.Save(&sender).Error; err != nil {
+		sender.CreditBalance = sender.CreditBalance.Sub(amount)
+		if err := tx.Save(sender).Error; err != nil {
+			return err
+		}
+		if err := subaccounts.Apply(tx, fromOrgID, fromSubAccountID, projectID, amount.Neg(), decimal.Zero); err != nil {
 			return err
 		}
 
-		var receiver domain.Holding
-		err := tx.Where("org_id = ? AND project_id = ?", toOrg.OrgID, projectID).First(&receiver).Error
-		if err == gorm.ErrRecordNotFound {
-			receiver = domain.Holding{
-				OrgID:         toOrg.OrgID,
-				ProjectID:     projectID,
-				CreditBalance: amount,
-			}
-			if err := tx.Create(&receiver).Error; err != nil {
-				return err
-			}
-		} else if err != nil {
+		if _, err := holdings.Credit(tx, toOrg.OrgID, projectID, amount); err != nil {
 			return err
-		} else {
-			receiver.CreditBalance = math.Round((receiver.CreditBalance+amount)*100) / 100
-			if err := tx.Save(&receiver).Error; err != nil {
-				return err
-			}
 		}
 
 		txRecord := domain.Transaction{
-			Type:      "transfer",
-			FromOrgID: &fromOrgID,
-			ToOrgID:   &toOrg.OrgID,
-			ProjectID: projectID,
-			Amount:    amount,
+			Type:         "transfer",
+			FromOrgID:    &fromOrgID,
+			ToOrgID:      &toOrg.OrgID,
+			ProjectID:    projectID,
+			Amount:       amount,
+			ActorUserID:  actorID,
+			SubAccountID: fromSubAccountID,
 		}
 		if err := tx.Create(&txRecord).Error; err != nil {
 			return err
@@ -218,34 +266,47 @@ func (s *Service) TransferCredits(ctx context.Context, fromOrgID, projectID uuid
 	return result, err
 }
 
-// RetireCredits mirrors Express retireCreditsService (transactional).
-func (s *Service) RetireCredits(ctx context.Context, orgID, projectID uuid.UUID, amount float64, purpose, beneficiary *string) (map[string]interface{}, error) {
+// RetireCredits mirrors Express retireCreditsService (transactional). subAccountID is the sub-account the
+// credits are retired from; nil retires from the org's main account.
+func (s *Service) RetireCredits(ctx context.Context, actorID *uuid.UUID, orgID uuid.UUID, subAccountID *uuid.UUID, projectID uuid.UUID, amount decimal.Decimal, purpose, beneficiary *string) (map[string]interface{}, error) {
 	var result map[string]interface{}
 
 	err := s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
-		var holding domain.Holding
-		if err := tx.Where("org_id = ? AND project_id = ?", orgID, projectID).First(&holding).Error; err != nil {
-			if err == gorm.ErrRecordNotFound {
-				return errors.New("No holdings found")
-			}
+		if err := subaccounts.Check(tx, orgID, subAccountID); err != nil {
+			return err
+		}
+		locked, err := holdings.LockHoldings(tx, projectID, orgID)
+		if err != nil {
 			return err
 		}
+		holding, ok := locked[orgID]
+		if !ok {
+			return errors.New("No holdings found")
+		}
 
-		available := holding.CreditBalance - holding.LockedForSale
-		if available < amount {
+		available, err := subaccounts.Available(tx, holding, subAccountID)
+		if err != nil {
+			return err
+		}
+		if available.LessThan(amount) {
 			return errors.New("Insufficient available credits to retire")
 		}
 
-		holding.CreditBalance = math.Round((holding.CreditBalance-amount)*100) / 100
-		if err := tx.Save(&holding).Error; err != nil {
+		holding.CreditBalance = holding.CreditBalance.Sub(amount)
+		if err := tx.Save(holding).Error; err != nil {
+			return err
+		}
+		if err := subaccounts.Apply(tx, orgID, subAccountID, projectID, amount.Neg(), decimal.Zero); err != nil {
 			return err
 		}
 
 		txRecord := domain.Transaction{
-			Type:      "retire",
-			FromOrgID: &orgID,
-			ProjectID: projectID,
-			Amount:    amount,
+			Type:         "retire",
+			FromOrgID:    &orgID,
+			ProjectID:    projectID,
+			Amount:       amount,
+			ActorUserID:  actorID,
+			SubAccountID: subAccountID,
 		}
 		if err := tx.Create(&txRecord).Error; err != nil {
 			return err
@@ -0,0 +1,231 @@
+package trading
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"troo-backend/internal/application/holdings"
+	listsvc "troo-backend/internal/application/listings"
+	"troo-backend/internal/application/subaccounts"
+	"troo-backend/internal/application/verification"
+	"troo-backend/internal/domain"
+	"troo-backend/internal/pkg/decimal"
+
+	"github.com/glebarez/sqlite"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/logger"
+)
+
+// setupDB opens a file-backed SQLite DB shared by all pool connections (":memory:" gives each connection its
+// own DB). SQLite runs one writer at a time, so tests on it cannot show a race; the locks themselves are checked
+// by recordLocks, and integration_test.go races the credit-moving paths on Postgres.
+func setupDB(t *testing.T) *gorm.DB {
+	dsn := "file:" + filepath.Join(t.TempDir(), "trading.db") + "?_pragma=busy_timeout(10000)"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	require.NoError(t, err)
+	migrate(t, db)
+	return db
+}
+
+func migrate(t *testing.T, db *gorm.DB) {
+	require.NoError(t, db.AutoMigrate(
+		&domain.Listing{}, &domain.Holding{}, &domain.Org{}, &domain.ListingEvent{},
+		&domain.Transaction{}, &domain.RetirementCertificate{}, &domain.IcrProject{}, &domain.OrgVerification{}, &domain.SubAccount{}, &domain.SubAccountHolding{},
+	))
+}
+
+type fixture struct {
+	db        *gorm.DB
+	orgID     uuid.UUID
+	otherOrg  domain.Org
+	projectID uuid.UUID
+}
+
+func newFixture(t *testing.T, db *gorm.DB, balance int64) *fixture {
+	f := &fixture{db: db, orgID: uuid.New(), projectID: uuid.New()}
+	name := "Mangrove Restoration"
+	require.NoError(t, db.Create(&domain.Org{OrgID: f.orgID, OrgName: "Seller", OrgCode: "SE-000001", CountryCode: "SG"}).Error)
+	f.otherOrg = domain.Org{OrgID: uuid.New(), OrgName: "Other", OrgCode: "OT-000001", CountryCode: "SG"}
+	require.NoError(t, db.Create(&f.otherOrg).Error)
+	require.NoError(t, db.Create(&domain.OrgVerification{OrgID: f.orgID, Status: verification.StatusVerified}).Error)
+	require.NoError(t, db.Create(&domain.IcrProject{ID: f.projectID, FullName: &name, Status: "validated"}).Error)
+	require.NoError(t, db.Create(&domain.Holding{OrgID: f.orgID, ProjectID: f.projectID, CreditBalance: decimal.NewFromInt(balance)}).Error)
+	return f
+}
+
+func (f *fixture) holding(t *testing.T, orgID uuid.UUID) domain.Holding {
+	var h domain.Holding
+	require.NoError(t, f.db.Where("org_id = ? AND project_id = ?", orgID, f.projectID).First(&h).Error)
+	return h
+}
+
+// listed sums credits_available over the seller's open listings.
+func (f *fixture) listed(t *testing.T) decimal.Decimal {
+	var open []domain.Listing
+	require.NoError(t, f.db.Where("seller_id = ? AND status = ?", f.orgID, "open").Find(&open).Error)
+	sum := decimal.Zero
+	for _, l := range open {
+		sum = sum.Add(l.CreditsAvailable)
+	}
+	return sum
+}
+
+// recordLocks records the table of every SELECT ... FOR UPDATE run on db. SQLite leaves the locking clause out
+// of the SQL it runs, but the statement still carries it.
+func recordLocks(t *testing.T, db *gorm.DB) func() []string {
+	var mu sync.Mutex
+	var tables []string
+	require.NoError(t, db.Callback().Query().Before("gorm:query").Register("test:locks", func(tx *gorm.DB) {
+		if l, ok := tx.Statement.Clauses["FOR"].Expression.(clause.Locking); ok && l.Strength == "UPDATE" {
+			mu.Lock()
+			tables = append(tables, tx.Statement.Table)
+			mu.Unlock()
+		}
+	}))
+	// The returned func hands over the tables locked since its last call, each once, in the order first locked.
+	return func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		seen := map[string]bool{}
+		var out []string
+		for _, table := range tables {
+			if !seen[table] {
+				seen[table] = true
+				out = append(out, table)
+			}
+		}
+		tables = nil
+		return out
+	}
+}
+
+func TestCreditMovingPaths_LockInHoldingsOrder(t *testing.T) {
+	f := newFixture(t, setupDB(t), 100)
+	svc := &Service{DB: f.db}
+	ls := &listsvc.Service{DB: f.db}
+	locks := recordLocks(t, f.db)
+	ctx := context.Background()
+
+	res, err := svc.SellCredits(ctx, nil, f.orgID, nil, f.projectID, decimal.NewFromInt(10), decimal.NewFromInt(5))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Holdings", "SubAccountHoldings", "Listings"}, locks())
+	listingID := res["listing_id"].(uuid.UUID)
+
+	qty := decimal.NewFromInt(20)
+	_, err = ls.EditListing(ctx, listsvc.EditListingInput{ListingID: listingID, OrgID: f.orgID, NewQuantity: &qty})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Holdings", "SubAccountHoldings", "Listings"}, locks())
+	_, err = ls.CancelListing(ctx, nil, listingID, f.orgID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Holdings", "SubAccountHoldings", "Listings"}, locks())
+
+	// The receiver's first holding is created under its Orgs row, taken last.
+	_, err = svc.TransferCredits(ctx, nil, f.orgID, nil, f.projectID, f.otherOrg.OrgCode, decimal.NewFromInt(5))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Holdings", "SubAccountHoldings", "Orgs"}, locks())
+	_, err = svc.RetireCredits(ctx, nil, f.orgID, nil, f.projectID, decimal.NewFromInt(5), nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Holdings", "SubAccountHoldings"}, locks())
+}
+
+func TestCreditMovingPaths_RecordActingUser(t *testing.T) {
+	f := newFixture(t, setupDB(t), 100)
+	svc := &Service{DB: f.db}
+	ls := &listsvc.Service{DB: f.db}
+	ctx := context.Background()
+	actor := uuid.New()
+	actorEvents := func(listingID uuid.UUID) []string {
+		var events []domain.ListingEvent
+		require.NoError(t, f.db.Where("listing_id = ? AND actor_user_id = ?", listingID, actor).Order(`"createdAt" ASC`).Find(&events).Error)
+		var types []string
+		for _, e := range events {
+			types = append(types, e.EventType)
+		}
+		return types
+	}
+
+	res, err := svc.SellCredits(ctx, &actor, f.orgID, nil, f.projectID, decimal.NewFromInt(10), decimal.NewFromInt(5))
+	require.NoError(t, err)
+	listingID := res["listing_id"].(uuid.UUID)
+	assert.Len(t, actorEvents(listingID), 1)
+
+	qty := decimal.NewFromInt(20)
+	_, err = ls.EditListing(ctx, listsvc.EditListingInput{ListingID: listingID, OrgID: f.orgID, ActorUserID: &actor, NewQuantity: &qty})
+	require.NoError(t, err)
+	assert.Len(t, actorEvents(listingID), 2)
+	_, err = ls.CancelListing(ctx, &actor, listingID, f.orgID)
+	require.NoError(t, err)
+	assert.Len(t, actorEvents(listingID), 3)
+
+	_, err = svc.TransferCredits(ctx, &actor, f.orgID, nil, f.projectID, f.otherOrg.OrgCode, decimal.NewFromInt(5))
+	require.NoError(t, err)
+	_, err = svc.RetireCredits(ctx, &actor, f.orgID, nil, f.projectID, decimal.NewFromInt(5), nil, nil)
+	require.NoError(t, err)
+	for _, typ := range []string{"transfer", "retire"} {
+		var tx domain.Transaction
+		require.NoError(t, f.db.Where("type = ?", typ).First(&tx).Error, typ)
+		require.NotNil(t, tx.ActorUserID, typ)
+		assert.Equal(t, actor, *tx.ActorUserID, typ)
+	}
+}
+
+func TestLockHoldings_ForUpdateInOrgOrder(t *testing.T) {
+	// DryRun builds the Postgres SQL without a server.
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	require.NoError(t, err)
+	var sql []string
+	require.NoError(t, db.Callback().Query().After("gorm:query").Register("test:sql", func(tx *gorm.DB) {
+		sql = append(sql, tx.Statement.SQL.String())
+	}))
+
+	_, err = holdings.LockHoldings(db, uuid.New(), uuid.New(), uuid.New())
+	require.NoError(t, err)
+	_, err = subaccounts.Lock(db, uuid.New(), uuid.New(), uuid.New())
+	require.NoError(t, err)
+	require.Len(t, sql, 2)
+	assert.True(t, strings.HasSuffix(sql[0], "ORDER BY org_id FOR UPDATE"), sql[0])
+	assert.True(t, strings.HasSuffix(sql[1], "ORDER BY org_id, sub_account_id FOR UPDATE"), sql[1])
+}
+
+func TestSellCredits_ExactDecimalArithmetic(t *testing.T) {
+	f := newFixture(t, setupDB(t), 0)
+	require.NoError(t, f.db.Model(&domain.Holding{}).Where("org_id = ?", f.orgID).
+		Update("credit_balance", decimal.RequireFromString("0.3")).Error)
+	svc := &Service{DB: f.db}
+
+	// 0.1 + 0.1 + 0.1 is not 0.3 in float64; the third sell must still fit and a fourth must not.
+	tenth := decimal.RequireFromString("0.1")
+	for i := 0; i < 3; i++ {
+		_, err := svc.SellCredits(context.Background(), nil, f.orgID, nil, f.projectID, tenth, decimal.RequireFromString("12.34"))
+		require.NoError(t, err)
+	}
+	_, err := svc.SellCredits(context.Background(), nil, f.orgID, nil, f.projectID, tenth, decimal.RequireFromString("12.34"))
+	assert.EqualError(t, err, "Insufficient credits to sell")
+
+	assert.Equal(t, "0.3", f.holding(t, f.orgID).LockedForSale.String())
+	assert.Equal(t, "0.3", f.listed(t).String())
+}
+
+func TestUnverifiedOrg_CannotSellOrTransfer(t *testing.T) {
+	f := newFixture(t, setupDB(t), 100)
+	svc := &Service{DB: f.db}
+	require.NoError(t, f.db.Create(&domain.Holding{OrgID: f.otherOrg.OrgID, ProjectID: f.projectID, CreditBalance: decimal.NewFromInt(10)}).Error)
+
+	_, err := svc.SellCredits(context.Background(), nil, f.otherOrg.OrgID, nil, f.projectID, decimal.NewFromInt(5), decimal.NewFromInt(10))
+	assert.Equal(t, verification.ErrNotVerified, err)
+	_, err = svc.TransferCredits(context.Background(), nil, f.otherOrg.OrgID, nil, f.projectID, "SE-000001", decimal.NewFromInt(5))
+	assert.Equal(t, verification.ErrNotVerified, err)
+	// Unverified orgs can still receive and retire credits.
+	_, err = svc.TransferCredits(context.Background(), nil, f.orgID, nil, f.projectID, f.otherOrg.OrgCode, decimal.NewFromInt(5))
+	require.NoError(t, err)
+	_, err = svc.RetireCredits(context.Background(), nil, f.otherOrg.OrgID, nil, f.projectID, decimal.NewFromInt(15), nil, nil)
+	require.NoError(t, err)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"troo-backend/internal/domain"
+	"troo-backend/internal/pkg/decimal"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
@@ -14,15 +15,16 @@ type Service struct {
 }
 
 type FormattedTx struct {
-	TxID             uuid.UUID   `json:"tx_id"`
-	Type             string      `json:"type"`
-	Amount           float64     `json:"amount"`
-	CreatedAt        interface{} `json:"created_at"`
-	FromOrgCode      *string     `json:"from_org_code"`
-	ToOrgCode        *string     `json:"to_org_code"`
-	ProjectID        uuid.UUID   `json:"project_id"`
-	ProjectName      *string     `json:"project_name"`
-	ProjectThumbnail *string     `json:"project_thumbnail"`
+	TxID             uuid.UUID       `json:"tx_id"`
+	Type             string          `json:"type"`
+	Amount           decimal.Decimal `json:"amount"`
+	CreatedAt        interface{}     `json:"created_at"`
+	FromOrgCode      *string         `json:"from_org_code"`
+	ToOrgCode        *string         `json:"to_org_code"`
+	ProjectID        uuid.UUID       `json:"project_id"`
+	ProjectName      *string         `json:"project_name"`
+	ProjectThumbnail *string         `json:"project_thumbnail"`
+	ActorUserID      *uuid.UUID      `json:"actor_user_id"`
 }
 
 func (s *Service) ViewTransactions(ctx context.Context, orgID string) (interface{}, string, int) {
@@ -95,6 +97,14 @@ func (s *Service) ViewTransactions(ctx context.Context, orgID string) (interface
 			CreatedAt: tx.CreatedAt,
 			ProjectID: tx.ProjectID,
 		}
+		// The acting user is shown only to the org that acted, never to the counterparty.
+		actingOrg := tx.FromOrgID
+		if tx.Type == "buy" {
+			actingOrg = tx.ToOrgID
+		}
+		if actingOrg != nil && actingOrg.String() == orgID {
+			ft.ActorUserID = tx.ActorUserID
+		}
 		if tx.FromOrgID != nil {
 			if code, ok := orgCodeMap[tx.FromOrgID.String()]; ok {
 				ft.FromOrgCode = &code
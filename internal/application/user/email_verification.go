@@ -0,0 +1,137 @@
+package user
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"troo-backend/internal/domain"
+
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+const (
+	verifyTokenTTL     = 24 * time.Hour
+	verifyResendWindow = time.Minute
+)
+
+// ResendVerification emails a fresh verification link for the user's current email.
+func (s *Service) ResendVerification(ctx context.Context, userID string) error {
+	var u domain.User
+	if err := s.DB.WithContext(ctx).Where("user_id = ?", userID).First(&u).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return errors.New("User not found")
+		}
+		return err
+	}
+	if u.EmailVerified {
+		return errors.New("Email already verified")
+	}
+	var last domain.EmailVerificationToken
+	if err := s.DB.WithContext(ctx).Where("user_id = ?", u.UserID).Order(`"createdAt" DESC`).First(&last).Error; err == nil {
+		if time.Since(last.CreatedAt) < verifyResendWindow {
+			return errors.New("Verification email can only be resent once per minute")
+		}
+	}
+	return s.issueVerification(ctx, &u)
+}
+
+// VerifyEmail consumes a verification token and marks the user's email verified.
+// The token only counts while the user's email is still the address it was sent to.
+func (s *Service) VerifyEmail(ctx context.Context, token string) (*domain.User, error) {
+	if token == "" {
+		return nil, errors.New("Verification token is required")
+	}
+	invalid := errors.New("Invalid or expired verification token")
+	var u domain.User
+	err := s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var t domain.EmailVerificationToken
+		if err := tx.Where("token_hash = ?", hashToken(token)).First(&t).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return invalid
+			}
+			return err
+		}
+		now := time.Now()
+		if t.UsedAt != nil || t.ExpiresAt.Before(now) {
+			return invalid
+		}
+		if err := tx.Where("user_id = ?", t.UserID).First(&u).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return invalid
+			}
+			return err
+		}
+		if !strings.EqualFold(u.Email, t.Email) {
+			return invalid
+		}
+		res := tx.Model(&domain.EmailVerificationToken{}).
+			Where("token_id = ? AND used_at IS NULL", t.TokenID).
+			Update("used_at", now)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return invalid
+		}
+		u.EmailVerified = true
+		return tx.Model(&u).Update("email_verified", true).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// issueVerification replaces any unused verification tokens for u and emails the new link (non-blocking).
+func (s *Service) issueVerification(ctx context.Context, u *domain.User) error {
+	token := randomToken()
+	now := time.Now()
+	err := s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&domain.EmailVerificationToken{}).
+			Where("user_id = ? AND used_at IS NULL", u.UserID).
+			Update("used_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Create(&domain.EmailVerificationToken{
+			UserID:    u.UserID,
+			Email:     u.Email,
+			TokenHash: hashToken(token),
+			ExpiresAt: now.Add(verifyTokenTTL),
+		}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	if s.EmailSender != nil {
+		firstName := u.Fullname
+		if idx := strings.IndexByte(u.Fullname, ' '); idx > 0 {
+			firstName = u.Fullname[:idx]
+		}
+		link := fmt.Sprintf("%s/verify-email?token=%s", strings.TrimSuffix(s.AppBaseURL, "/"), token)
+		go func(to, first string) {
+			if err := s.EmailSender.SendVerifyEmail(context.Background(), to, first, link); err != nil {
+				log.Error().Err(err).Str("to", to).Msg("verification email send failed")
+			}
+		}(u.Email, firstName)
+	}
+	return nil
+}
+
+func randomToken() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
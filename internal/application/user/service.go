@@ -4,9 +4,11 @@ import (
 	"context"
 	"errors"
 	"strings"
+	"time"
 	"unicode"
 
 	"troo-backend/internal/application/emails"
+	"troo-backend/internal/application/memberships"
 	"troo-backend/internal/application/policies/user"
 	"troo-backend/internal/domain"
 	"troo-backend/internal/pkg/constants"
@@ -24,6 +26,7 @@ type Service struct {
 	DB          *gorm.DB
 	Rdb         *redis.Client
 	EmailSender emails.Sender // optional; when set, welcome email is sent after CreateUser (same as Express)
+	AppBaseURL  string        // frontend base URL for the email verification link
 }
 
 // CreateUserInput matches Express createUserService({ user_name, email, password, fullname }).
@@ -81,6 +84,11 @@ func (s *Service) CreateUser(ctx context.Context, in CreateUserInput) (*domain.U
 		return nil, err
 	}
 
+	// New accounts cannot trade until the email is verified; registration still succeeds if the link fails.
+	if err := s.issueVerification(ctx, u); err != nil {
+		log.Error().Err(err).Str("user_id", u.UserID.String()).Msg("email verification issue failed")
+	}
+
 	// Send welcome email (non-blocking, same as Express)
 	if s.EmailSender != nil {
 		firstName := fullname
@@ -163,11 +171,17 @@ func (s *Service) UpdateUser(ctx context.Context, userID string, fields map[stri
 	}
 
 	// Uniqueness: no other user (excluding this one) may have the new email or user_name
+	emailChanged := false
 	if e, ok := upd["email"].(string); ok {
 		var dup domain.User
 		if err := s.DB.WithContext(ctx).Where("email = ? AND user_id != ?", e, userID).First(&dup).Error; err == nil {
 			return nil, errors.New("Email already registered")
 		}
+		var current domain.User
+		if err := s.DB.WithContext(ctx).Where("user_id = ?", userID).Select("email").First(&current).Error; err == nil && current.Email != e {
+			emailChanged = true
+			upd["email_verified"] = false
+		}
 	}
 	if un, ok := upd["user_name"].(string); ok {
 		var dup domain.User
@@ -189,6 +203,14 @@ func (s *Service) UpdateUser(ctx context.Context, userID string, fields map[stri
 		return nil, err
 	}
 
+	// A new email must be verified again; other sessions still carry the old verified flag, so end them.
+	if emailChanged {
+		if err := s.issueVerification(ctx, &u); err != nil {
+			log.Error().Err(err).Str("user_id", userID).Msg("email verification issue failed")
+		}
+		policies.DestroyUserSessions(ctx, s.Rdb, userID)
+	}
+
 	// Send account-updated email (non-blocking, same as Express)
 	if s.EmailSender != nil {
 		firstName := u.Fullname
@@ -227,19 +249,29 @@ type UpdateUserRoleInput struct {
 	TargetUserID string
 	TargetRole   string
 	OrgID        *string
+	// TargetCustomRoleID assigns an org-defined custom role instead of TargetRole.
+	TargetCustomRoleID string
 }
 
 // UpdateUserRole updates target user's role after policy check and destroys their sessions (Express updateUserRoleService).
+// A custom role assignment stores role=viewer plus custom_role_id; a built-in role clears custom_role_id.
 func (s *Service) UpdateUserRole(ctx context.Context, in UpdateUserRoleInput) (*domain.User, error) {
+	if in.TargetCustomRoleID != "" {
+		if _, err := uuid.Parse(in.TargetCustomRoleID); err != nil {
+			return nil, errors.New("Invalid custom_role_id")
+		}
+		in.TargetRole = constants.Viewer
+	}
 	if !constants.IsValidRole(in.TargetRole) {
 		return nil, errors.New("Invalid role. Allowed: viewer, manager, admin, superadmin")
 	}
 	if err := policies.ValidateRoleAssignment(s.DB, policies.ValidateRoleAssignmentParams{
-		ActorRole:    in.ActorRole,
-		TargetRole:   in.TargetRole,
-		ActorUserID:  in.ActorUserID,
-		TargetUserID: in.TargetUserID,
-		OrgID:        in.OrgID,
+		ActorRole:          in.ActorRole,
+		TargetRole:         in.TargetRole,
+		ActorUserID:        in.ActorUserID,
+		TargetUserID:       in.TargetUserID,
+		OrgID:              in.OrgID,
+		TargetCustomRoleID: in.TargetCustomRoleID,
 	}); err != nil {
 		return nil, err
 	}
@@ -250,14 +282,55 @@ func (s *Service) UpdateUserRole(ctx context.Context, in UpdateUserRoleInput) (*
 		}
 		return nil, err
 	}
-	u.Role = in.TargetRole
-	if err := s.DB.WithContext(ctx).Save(&u).Error; err != nil {
-		return nil, err
+	var customRoleID *uuid.UUID
+	if in.TargetCustomRoleID != "" {
+		roleID := uuid.MustParse(in.TargetCustomRoleID)
+		customRoleID = &roleID
+	}
+	if in.OrgID == nil {
+		u.Role = in.TargetRole
+		u.CustomRoleID = customRoleID
+		if err := s.DB.WithContext(ctx).Save(&u).Error; err != nil {
+			return nil, err
+		}
+	} else {
+		orgID := uuid.MustParse(*in.OrgID)
+		if err := memberships.SetRole(s.DB.WithContext(ctx), &u, orgID, in.TargetRole, customRoleID); err != nil {
+			return nil, err
+		}
+		asMemberOf(&u, &domain.OrgMembership{OrgID: orgID, Role: in.TargetRole, CustomRoleID: customRoleID})
 	}
 	policies.DestroyUserSessions(ctx, s.Rdb, in.TargetUserID)
 	return &u, nil
 }
 
+// ViewMember returns the user with the org, role and custom role of their membership of orgID, which may
+// differ from their active org. A user who is not a member is returned as stored.
+func (s *Service) ViewMember(ctx context.Context, userID string, orgID *string) (*domain.User, error) {
+	u, err := s.ViewUser(ctx, userID)
+	if err != nil || orgID == nil {
+		return u, err
+	}
+	id, err := uuid.Parse(*orgID)
+	if err != nil {
+		return u, nil
+	}
+	m, err := memberships.Get(s.DB.WithContext(ctx), u, id)
+	if err == nil {
+		asMemberOf(u, m)
+	} else if err != memberships.ErrNotMember {
+		return nil, err
+	}
+	return u, nil
+}
+
+func asMemberOf(u *domain.User, m *domain.OrgMembership) {
+	orgID := m.OrgID
+	u.OrgID = &orgID
+	u.Role = m.Role
+	u.CustomRoleID = m.CustomRoleID
+}
+
 // RemoveUserFromOrgInput matches Express removeUserFromOrgService({ actorUserId, actorRole, targetUserId, org_id }).
 type RemoveUserFromOrgInput struct {
 	ActorUserID  string
@@ -266,7 +339,8 @@ type RemoveUserFromOrgInput struct {
 	OrgID        *string
 }
 
-// RemoveUserFromOrg validates via policy, sets target org_id=nil and role=viewer, destroys sessions (Express removeUserFromOrgService).
+// RemoveUserFromOrg validates via policy, ends the target's membership of the org, destroys sessions (Express removeUserFromOrgService).
+// If it was their active org they move to another of their orgs, or to no org as a viewer.
 func (s *Service) RemoveUserFromOrg(ctx context.Context, in RemoveUserFromOrgInput) error {
 	target, err := policies.ValidateOrgMembershipChange(s.DB, policies.ValidateOrgMembershipChangeParams{
 		ActorUserID:  in.ActorUserID,
@@ -277,15 +351,136 @@ func (s *Service) RemoveUserFromOrg(ctx context.Context, in RemoveUserFromOrgInp
 	if err != nil {
 		return err
 	}
-	target.OrgID = nil
-	target.Role = constants.Viewer
-	if err := s.DB.WithContext(ctx).Save(target).Error; err != nil {
+	if in.OrgID == nil {
+		target.Role = constants.Viewer
+		target.CustomRoleID = nil
+		if err := s.DB.WithContext(ctx).Save(target).Error; err != nil {
+			return err
+		}
+	} else if err := memberships.Remove(s.DB.WithContext(ctx), target, uuid.MustParse(*in.OrgID)); err != nil {
+		return err
+	}
+	policies.DestroyUserSessions(ctx, s.Rdb, in.TargetUserID)
+	return nil
+}
+
+// ForceLogout ends every session of a member of the actor's org (see policies.ValidateForceLogout).
+func (s *Service) ForceLogout(ctx context.Context, in RemoveUserFromOrgInput) error {
+	if _, err := policies.ValidateForceLogout(s.DB.WithContext(ctx), policies.ValidateOrgMembershipChangeParams{
+		ActorUserID:  in.ActorUserID,
+		ActorRole:    in.ActorRole,
+		TargetUserID: in.TargetUserID,
+		OrgID:        in.OrgID,
+	}); err != nil {
 		return err
 	}
 	policies.DestroyUserSessions(ctx, s.Rdb, in.TargetUserID)
 	return nil
 }
 
+// Membership is one of the user's orgs as listed by view-memberships.
+type Membership struct {
+	OrgID          uuid.UUID  `json:"org_id"`
+	OrgName        string     `json:"org_name"`
+	OrgCode        string     `json:"org_code"`
+	Role           string     `json:"role"`
+	CustomRoleID   *uuid.UUID `json:"custom_role_id"`
+	CustomRoleName *string    `json:"custom_role_name"`
+	Active         bool       `json:"active"`
+	JoinedAt       time.Time  `json:"joined_at"`
+}
+
+// ListMemberships returns the orgs the user belongs to, oldest membership first, marking activeOrgID, the
+// session's org.
+func (s *Service) ListMemberships(ctx context.Context, userID string, activeOrgID *string) ([]Membership, error) {
+	u, err := s.ViewUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	db := s.DB.WithContext(ctx)
+	rows, err := memberships.List(db, u)
+	if err != nil {
+		return nil, err
+	}
+	orgIDs := make([]uuid.UUID, 0, len(rows))
+	var roleIDs []uuid.UUID
+	for _, m := range rows {
+		orgIDs = append(orgIDs, m.OrgID)
+		if m.CustomRoleID != nil {
+			roleIDs = append(roleIDs, *m.CustomRoleID)
+		}
+	}
+	var orgs []domain.Org
+	if err := db.Where("org_id IN ?", orgIDs).Find(&orgs).Error; err != nil {
+		return nil, err
+	}
+	orgByID := make(map[uuid.UUID]*domain.Org, len(orgs))
+	for i := range orgs {
+		orgByID[orgs[i].OrgID] = &orgs[i]
+	}
+	roleNames := make(map[uuid.UUID]string, len(roleIDs))
+	if len(roleIDs) > 0 {
+		var roles []domain.CustomRole
+		if err := db.Where("role_id IN ?", roleIDs).Find(&roles).Error; err != nil {
+			return nil, err
+		}
+		for _, r := range roles {
+			roleNames[r.RoleID] = r.Name
+		}
+	}
+	out := make([]Membership, 0, len(rows))
+	for _, m := range rows {
+		org, ok := orgByID[m.OrgID]
+		if !ok {
+			continue
+		}
+		entry := Membership{
+			OrgID:        m.OrgID,
+			OrgName:      org.OrgName,
+			OrgCode:      org.OrgCode,
+			Role:         m.Role,
+			CustomRoleID: m.CustomRoleID,
+			Active:       activeOrgID != nil && *activeOrgID == m.OrgID.String(),
+			JoinedAt:     m.CreatedAt,
+		}
+		if m.CustomRoleID != nil {
+			if name, ok := roleNames[*m.CustomRoleID]; ok {
+				entry.CustomRoleName = &name
+			}
+		}
+		out = append(out, entry)
+	}
+	return out, nil
+}
+
+// SwitchOrgResult is the user as a member of the org switched to, with their custom role there loaded, and
+// that org.
+type SwitchOrgResult struct {
+	User *domain.User
+	Org  *domain.Org
+}
+
+// SwitchOrg resolves the user's membership of orgID for a session moving to it. The Users row keeps their
+// home org: the switch belongs to the session alone.
+func (s *Service) SwitchOrg(ctx context.Context, userID string, orgID uuid.UUID) (*SwitchOrgResult, error) {
+	u, err := s.ViewUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	db := s.DB.WithContext(ctx)
+	var org domain.Org
+	if err := db.Where("org_id = ?", orgID).First(&org).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, memberships.ErrNotMember
+		}
+		return nil, err
+	}
+	if _, err := memberships.Enter(db, u, orgID); err != nil {
+		return nil, err
+	}
+	return &SwitchOrgResult{User: u, Org: &org}, nil
+}
+
 func titleCaseAndNormalize(s string) string {
 	s = strings.TrimSpace(strings.ToLower(s))
 	runes := []rune(s)
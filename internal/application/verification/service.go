@@ -0,0 +1,304 @@
+// Package verification runs the know-your-business (KYB) review of orgs. An org submits its registration ID
+// and incorporation document, a platform operator reviews them, and only verified orgs may sell or transfer
+// credits:
+//
+//	unverified ─submit→ submitted ─start review→ under_review ─approve→ verified
+//	rejected ───submit──┘                                     └─reject→ rejected
+//
+// Changing the registration ID or incorporation document after submitting sends the org back to unverified.
+package verification
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"troo-backend/internal/domain"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	StatusUnverified  = "unverified"
+	StatusSubmitted   = "submitted"
+	StatusUnderReview = "under_review"
+	StatusVerified    = "verified"
+	StatusRejected    = "rejected"
+)
+
+// ValidStatuses is the set of OrgVerifications.status values.
+var ValidStatuses = []string{StatusUnverified, StatusSubmitted, StatusUnderReview, StatusVerified, StatusRejected}
+
+var (
+	ErrNotVerified      = errors.New("Organization must be verified to sell or transfer credits")
+	ErrDocumentsMissing = errors.New("Verification documents are missing")
+	ErrNotSubmittable   = errors.New("Verification is already submitted or verified")
+	ErrNotSubmitted     = errors.New("Verification is not awaiting review")
+	ErrNotUnderReview   = errors.New("Verification is not under review")
+)
+
+// Service reads and moves orgs through the verification workflow.
+type Service struct {
+	DB *gorm.DB
+}
+
+// Requirement is a document an org must provide before it can submit for verification.
+type Requirement struct {
+	Field     string `json:"field"`
+	Label     string `json:"label"`
+	Satisfied bool   `json:"satisfied"`
+}
+
+// View is an org's verification with the requirements checked against its current profile.
+type View struct {
+	domain.OrgVerification
+	Requirements []Requirement `json:"requirements"`
+}
+
+// QueueEntry is a verification in the operators' review queue.
+type QueueEntry struct {
+	domain.OrgVerification
+	OrgName string `json:"org_name"`
+	OrgCode string `json:"org_code"`
+}
+
+// Get returns the org's verification; orgs that never submitted are unverified.
+func Get(db *gorm.DB, orgID uuid.UUID) (*domain.OrgVerification, error) {
+	var v domain.OrgVerification
+	err := db.Where("org_id = ?", orgID).First(&v).Error
+	if err == gorm.ErrRecordNotFound {
+		return &domain.OrgVerification{OrgID: orgID, Status: StatusUnverified}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// Require returns ErrNotVerified unless the org is verified with the registration ID and incorporation document
+// it has now; Express can edit the Orgs row without going through Revoke. Trading calls it inside its transaction.
+func Require(db *gorm.DB, orgID uuid.UUID) error {
+	org, err := findOrg(db, orgID)
+	if err != nil {
+		return err
+	}
+	v, err := Get(db, orgID)
+	if err != nil {
+		return err
+	}
+	if v.Status != StatusVerified || !reviewed(v, org) {
+		return ErrNotVerified
+	}
+	return nil
+}
+
+// Revoke sends the org back to unverified when its registration ID or incorporation document differ from the
+// ones it submitted, so that changed documents are reviewed again.
+func Revoke(db *gorm.DB, org *domain.Org) error {
+	v, err := Get(db, org.OrgID)
+	if err != nil {
+		return err
+	}
+	if v.Status == StatusUnverified || reviewed(v, org) {
+		return nil
+	}
+	return db.Model(&domain.OrgVerification{}).Where("org_id = ?", org.OrgID).Updates(map[string]interface{}{
+		"status":                StatusUnverified,
+		"registration_id":       nil,
+		"incorporation_doc_url": nil,
+		"submitted_by":          nil,
+		"submitted_at":          nil,
+		"reviewed_by":           nil,
+		"review_started_at":     nil,
+		"reviewed_at":           nil,
+		"rejection_reason":      nil,
+	}).Error
+}
+
+// Requirements checks the org's profile against the documents verification needs.
+func Requirements(org *domain.Org) []Requirement {
+	return []Requirement{
+		{Field: "registration_id", Label: "Company registration ID", Satisfied: present(org.RegistrationID)},
+		{Field: "incorporation_doc_url", Label: "Certificate of incorporation", Satisfied: present(org.IncorporationDocURL)},
+	}
+}
+
+// View returns the org's verification and its requirements. A verification whose documents have since been
+// changed outside Go (see Require) is shown as unverified.
+func (s *Service) View(ctx context.Context, orgID uuid.UUID) (*View, error) {
+	db := s.DB.WithContext(ctx)
+	org, err := findOrg(db, orgID)
+	if err != nil {
+		return nil, err
+	}
+	v, err := Get(db, orgID)
+	if err != nil {
+		return nil, err
+	}
+	if !reviewed(v, org) {
+		v = &domain.OrgVerification{OrgID: orgID, Status: StatusUnverified}
+	}
+	return &View{OrgVerification: *v, Requirements: Requirements(org)}, nil
+}
+
+// Submit puts an unverified or rejected org in the review queue with its current registration ID and
+// incorporation document. Both must be set.
+func (s *Service) Submit(ctx context.Context, orgID, userID uuid.UUID) (*View, error) {
+	var out *View
+	err := s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		org, err := findOrg(tx, orgID)
+		if err != nil {
+			return err
+		}
+		reqs := Requirements(org)
+		for _, r := range reqs {
+			if !r.Satisfied {
+				return ErrDocumentsMissing
+			}
+		}
+		v, err := lock(tx, orgID)
+		if err != nil {
+			return err
+		}
+		if v.Status != StatusUnverified && v.Status != StatusRejected {
+			return ErrNotSubmittable
+		}
+		now := time.Now()
+		*v = domain.OrgVerification{
+			OrgID:               orgID,
+			Status:              StatusSubmitted,
+			RegistrationID:      org.RegistrationID,
+			IncorporationDocURL: org.IncorporationDocURL,
+			SubmittedBy:         &userID,
+			SubmittedAt:         &now,
+			CreatedAt:           v.CreatedAt,
+		}
+		if err := tx.Save(v).Error; err != nil {
+			return err
+		}
+		out = &View{OrgVerification: *v, Requirements: reqs}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// List returns the verifications in status (all when empty), oldest submission first.
+func (s *Service) List(ctx context.Context, status string) ([]QueueEntry, error) {
+	q := s.DB.WithContext(ctx).Table(`"OrgVerifications" AS v`).
+		Select("v.*, o.org_name, o.org_code").
+		Joins(`JOIN "Orgs" AS o ON o.org_id = v.org_id`)
+	if status != "" {
+		if !validStatus(status) {
+			return nil, errors.New("Invalid status")
+		}
+		q = q.Where("v.status = ?", status)
+	}
+	out := []QueueEntry{}
+	if err := q.Order("v.submitted_at ASC, v.org_id").Scan(&out).Error; err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// StartReview claims a submitted verification for the operator.
+func (s *Service) StartReview(ctx context.Context, orgID, operatorID uuid.UUID) (*domain.OrgVerification, error) {
+	return s.transition(ctx, orgID, StatusSubmitted, ErrNotSubmitted, func(v *domain.OrgVerification, now time.Time) {
+		v.Status = StatusUnderReview
+		v.ReviewedBy = &operatorID
+		v.ReviewStartedAt = &now
+	})
+}
+
+// Approve verifies an org under review.
+func (s *Service) Approve(ctx context.Context, orgID, operatorID uuid.UUID) (*domain.OrgVerification, error) {
+	return s.transition(ctx, orgID, StatusUnderReview, ErrNotUnderReview, func(v *domain.OrgVerification, now time.Time) {
+		v.Status = StatusVerified
+		v.ReviewedBy = &operatorID
+		v.ReviewedAt = &now
+		v.RejectionReason = nil
+	})
+}
+
+// Reject turns down an org under review; the reason is shown to the org, which may fix it and resubmit.
+func (s *Service) Reject(ctx context.Context, orgID, operatorID uuid.UUID, reason string) (*domain.OrgVerification, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, errors.New("Reason is required")
+	}
+	return s.transition(ctx, orgID, StatusUnderReview, ErrNotUnderReview, func(v *domain.OrgVerification, now time.Time) {
+		v.Status = StatusRejected
+		v.ReviewedBy = &operatorID
+		v.ReviewedAt = &now
+		v.RejectionReason = &reason
+	})
+}
+
+// transition applies fn to the org's verification if it is in status from, else returns errWrongStatus.
+func (s *Service) transition(ctx context.Context, orgID uuid.UUID, from string, errWrongStatus error, fn func(*domain.OrgVerification, time.Time)) (*domain.OrgVerification, error) {
+	var v *domain.OrgVerification
+	err := s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if _, err := findOrg(tx, orgID); err != nil {
+			return err
+		}
+		var err error
+		if v, err = lock(tx, orgID); err != nil {
+			return err
+		}
+		if v.Status != from {
+			return errWrongStatus
+		}
+		fn(v, time.Now())
+		return tx.Save(v).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// lock reads the org's verification for update; a missing row is returned as unverified.
+func lock(tx *gorm.DB, orgID uuid.UUID) (*domain.OrgVerification, error) {
+	return Get(tx.Clauses(clause.Locking{Strength: "UPDATE"}), orgID)
+}
+
+func findOrg(db *gorm.DB, orgID uuid.UUID) (*domain.Org, error) {
+	var org domain.Org
+	if err := db.Where("org_id = ?", orgID).First(&org).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, errors.New("Organization not found")
+		}
+		return nil, err
+	}
+	return &org, nil
+}
+
+// reviewed reports whether v was submitted with the org's current documents.
+func reviewed(v *domain.OrgVerification, org *domain.Org) bool {
+	return equal(v.RegistrationID, org.RegistrationID) && equal(v.IncorporationDocURL, org.IncorporationDocURL)
+}
+
+func equal(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func present(s *string) bool {
+	return s != nil && strings.TrimSpace(*s) != ""
+}
+
+func validStatus(status string) bool {
+	for _, s := range ValidStatuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
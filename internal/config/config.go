@@ -4,6 +4,7 @@ import (
 	"errors"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -13,6 +14,12 @@ type Config struct {
 	Env                string
 	Port               string
 	SessionSecret      string
+	// SessionPreviousSecrets (SESSION_PREVIOUS_SECRETS, comma-separated) still verify cookies after SESSION_SECRET is rotated.
+	SessionPreviousSecrets []string
+	// SessionIdleTimeout (SESSION_IDLE_TIMEOUT) and SessionMaxLifetime (SESSION_MAX_LIFETIME) are Go durations
+	// such as "30m" or "168h"; unset keeps the 24h default.
+	SessionIdleTimeout time.Duration
+	SessionMaxLifetime time.Duration
 	DatabaseURL        string
 	RedisURL           string
 	SupabaseURL        string // e.g. https://xwsiuytkbefejvoqpjyg.supabase.co — used for storage sign URLs and public URLs
@@ -27,8 +34,15 @@ type Config struct {
 	SendinblueAPIKey     string // SENDINBLUE_API_KEY for welcome/notification emails (Brevo)
 	MailFrom             string // MAIL_FROM sender email (default noreply@troo.earth)
 	InviteBaseURL        string // Base URL for invite links (e.g. https://atlas.troo.earth), same logic as Express
+	SecretsEncryptionKey string // SECRETS_ENCRYPTION_KEY: base64 of 32 bytes, encrypts TOTP secrets at rest
+	// TrustedProxies (TRUSTED_PROXIES, comma-separated IPs or CIDRs) are the load balancers whose
+	// X-Forwarded-For is believed; unset trusts loopback and private networks.
+	TrustedProxies []string
 }
 
+// defaultTrustedProxies covers a load balancer on the same host or inside a private network.
+var defaultTrustedProxies = []string{"127.0.0.1", "::1", "10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "fc00::/7"}
+
 // Load loads config from env and optional .env file.
 func Load() (*Config, error) {
 	viper.SetConfigFile(".env")
@@ -71,6 +85,9 @@ func Load() (*Config, error) {
 		Env:                 env,
 		Port:                port,
 		SessionSecret:       viper.GetString("SESSION_SECRET"),
+		SessionPreviousSecrets: splitList(viper.GetString("SESSION_PREVIOUS_SECRETS")),
+		SessionIdleTimeout:  viper.GetDuration("SESSION_IDLE_TIMEOUT"),
+		SessionMaxLifetime:  viper.GetDuration("SESSION_MAX_LIFETIME"),
 		DatabaseURL:         dbURL,
 		RedisURL:            redisURL,
 		SupabaseURL:         viper.GetString("SUPABASE_URL"),
@@ -85,9 +102,28 @@ func Load() (*Config, error) {
 		SendinblueAPIKey:     viper.GetString("SENDINBLUE_API_KEY"),
 		MailFrom:             viper.GetString("MAIL_FROM"),
 		InviteBaseURL:        inviteBaseURL(viper.GetString("INVITE_BASE_URL")),
+		SecretsEncryptionKey: viper.GetString("SECRETS_ENCRYPTION_KEY"),
+		TrustedProxies:       trustedProxies(viper.GetString("TRUSTED_PROXIES")),
 	}, nil
 }
 
+func splitList(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func trustedProxies(s string) []string {
+	if list := splitList(s); len(list) > 0 {
+		return list
+	}
+	return defaultTrustedProxies
+}
+
 func inviteBaseURL(s string) string {
 	s = strings.TrimSpace(s)
 	if s == "" {
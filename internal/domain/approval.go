@@ -0,0 +1,61 @@
+package domain
+
+import (
+	"time"
+
+	"troo-backend/internal/pkg/decimal"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// ApprovalPolicy is an org's four-eyes rule for credits leaving the org. A nil threshold means no approval
+// is needed for that operation; AllTransfers puts every transfer behind approval regardless of amount.
+type ApprovalPolicy struct {
+	OrgID             uuid.UUID        `gorm:"column:org_id;type:uuid;primaryKey" json:"org_id"`
+	TransferThreshold *decimal.Decimal `gorm:"column:transfer_threshold;type:decimal(18,2)" json:"transfer_threshold"`
+	RetireThreshold   *decimal.Decimal `gorm:"column:retire_threshold;type:decimal(18,2)" json:"retire_threshold"`
+	AllTransfers      bool             `gorm:"column:all_transfers;not null;default:false" json:"all_transfers"`
+	UpdatedBy         *uuid.UUID       `gorm:"column:updated_by;type:uuid" json:"updated_by"`
+	CreatedAt         time.Time        `gorm:"column:createdAt" json:"createdAt"`
+	UpdatedAt         time.Time        `gorm:"column:updatedAt" json:"updatedAt"`
+}
+
+func (ApprovalPolicy) TableName() string {
+	return "ApprovalPolicies"
+}
+
+// ApprovalRequest is a transfer or retirement held back by ApprovalPolicy until a second admin decides on it.
+type ApprovalRequest struct {
+	RequestID       uuid.UUID       `gorm:"column:request_id;type:uuid;primaryKey" json:"request_id"`
+	OrgID           uuid.UUID       `gorm:"column:org_id;type:uuid;not null;index" json:"org_id"`
+	Type            string          `gorm:"column:type;type:varchar(20);not null" json:"type"` // "transfer" | "retire"
+	ProjectID       uuid.UUID       `gorm:"column:project_id;type:uuid;not null" json:"project_id"`
+	SubAccountID    *uuid.UUID      `gorm:"column:sub_account_id;type:uuid" json:"sub_account_id"`
+	Amount          decimal.Decimal `gorm:"column:amount;type:decimal(18,2);not null" json:"amount"`
+	ToOrgCode       *string         `gorm:"column:to_org_code" json:"to_org_code"`
+	Purpose         *string         `gorm:"column:purpose" json:"purpose"`
+	Beneficiary     *string         `gorm:"column:beneficiary" json:"beneficiary"`
+	Status          string          `gorm:"column:status;type:varchar(20);not null;default:'pending'" json:"status"` // "pending" | "approved" | "rejected" | "cancelled"
+	RequestedBy     uuid.UUID       `gorm:"column:requested_by;type:uuid;not null" json:"requested_by"`
+	DecidedBy       *uuid.UUID      `gorm:"column:decided_by;type:uuid" json:"decided_by"`
+	DecidedAt       *time.Time      `gorm:"column:decided_at" json:"decided_at"`
+	RejectionReason *string         `gorm:"column:rejection_reason" json:"rejection_reason"`
+	Result          datatypes.JSON  `gorm:"column:result;type:jsonb" json:"result"`
+	LimitUsageID    *uuid.UUID      `gorm:"column:limit_usage_id;type:uuid" json:"-"` // trading-limit reservation released on rejection
+	CreatedAt       time.Time       `gorm:"column:createdAt" json:"createdAt"`
+	UpdatedAt       time.Time       `gorm:"column:updatedAt" json:"updatedAt"`
+}
+
+func (ApprovalRequest) TableName() string {
+	return "ApprovalRequests"
+}
+
+// BeforeCreate: never insert zero UUID for primary key; generate random when not set.
+func (r *ApprovalRequest) BeforeCreate(tx *gorm.DB) error {
+	if r.RequestID == uuid.Nil {
+		r.RequestID = uuid.New()
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package domain
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// ErrAuditLogImmutable is returned when code tries to change or delete an audit entry.
+var ErrAuditLogImmutable = errors.New("Audit log entries are immutable")
+
+// AuditEntry is one action to record as an AuditLog. Before and After are marshalled to JSON; nil leaves the
+// column empty. It lives here rather than in the audit service so request middleware can fill it in.
+type AuditEntry struct {
+	ActorID    *uuid.UUID
+	OrgID      *uuid.UUID
+	Action     string
+	TargetType string
+	TargetID   string
+	Before     interface{}
+	After      interface{}
+	IP         string
+	TraceID    string
+}
+
+// AuditLog is one security or business action: who did what to which target, with the state before and after.
+// Entries are append-only; the model refuses updates and deletes.
+type AuditLog struct {
+	AuditID     uuid.UUID      `gorm:"column:audit_id;type:uuid;primaryKey" json:"audit_id"`
+	ActorUserID *uuid.UUID     `gorm:"column:actor_user_id;type:uuid;index" json:"actor_user_id"`
+	OrgID       *uuid.UUID     `gorm:"column:org_id;type:uuid;index:idx_audit_org_created" json:"org_id"`
+	Action      string         `gorm:"column:action;type:varchar(50);not null;index" json:"action"`
+	TargetType  string         `gorm:"column:target_type;type:varchar(30)" json:"target_type"`
+	TargetID    string         `gorm:"column:target_id" json:"target_id"`
+	Before      datatypes.JSON `gorm:"column:before;type:jsonb" json:"before"`
+	After       datatypes.JSON `gorm:"column:after;type:jsonb" json:"after"`
+	IP          string         `gorm:"column:ip" json:"ip"`
+	TraceID     string         `gorm:"column:trace_id" json:"trace_id"`
+	CreatedAt   time.Time      `gorm:"column:createdAt;index:idx_audit_org_created" json:"createdAt"`
+}
+
+func (AuditLog) TableName() string {
+	return "AuditLogs"
+}
+
+// BeforeCreate: never insert zero UUID for primary key; generate random when not set.
+func (a *AuditLog) BeforeCreate(tx *gorm.DB) error {
+	if a.AuditID == uuid.Nil {
+		a.AuditID = uuid.New()
+	}
+	return nil
+}
+
+func (a *AuditLog) BeforeUpdate(tx *gorm.DB) error {
+	return ErrAuditLogImmutable
+}
+
+func (a *AuditLog) BeforeDelete(tx *gorm.DB) error {
+	return ErrAuditLogImmutable
+}
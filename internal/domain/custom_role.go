@@ -0,0 +1,42 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// CustomRole is an org-defined role made of existing permissions (constants.PermissionRoles keys).
+// Users holding one keep role=viewer in Users.role and get their permissions from the set instead.
+type CustomRole struct {
+	RoleID      uuid.UUID      `gorm:"column:role_id;type:uuid;primaryKey" json:"role_id"`
+	OrgID       uuid.UUID      `gorm:"column:org_id;type:uuid;not null;uniqueIndex:idx_custom_roles_org_name" json:"org_id"`
+	Name        string         `gorm:"column:name;not null;uniqueIndex:idx_custom_roles_org_name" json:"name"`
+	Description *string        `gorm:"column:description" json:"description"`
+	Permissions datatypes.JSON `gorm:"column:permissions;type:jsonb;not null" json:"permissions"`
+	CreatedBy   *uuid.UUID     `gorm:"column:created_by;type:uuid" json:"created_by"`
+	CreatedAt   time.Time      `gorm:"column:createdAt" json:"createdAt"`
+	UpdatedAt   time.Time      `gorm:"column:updatedAt" json:"updatedAt"`
+}
+
+func (CustomRole) TableName() string {
+	return "CustomRoles"
+}
+
+// BeforeCreate: never insert zero UUID for primary key; generate random when not set.
+func (r *CustomRole) BeforeCreate(tx *gorm.DB) error {
+	if r.RoleID == uuid.Nil {
+		r.RoleID = uuid.New()
+	}
+	return nil
+}
+
+// PermissionList decodes Permissions; a malformed value yields no permissions.
+func (r *CustomRole) PermissionList() []string {
+	var out []string
+	_ = json.Unmarshal(r.Permissions, &out)
+	return out
+}
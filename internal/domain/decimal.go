@@ -0,0 +1,5 @@
+package domain
+
+// CreditScale is the number of decimal places of every decimal(18,2) credit amount and price column.
+// Amounts and prices are troo-backend/internal/pkg/decimal values, rounded to this scale where they enter the API.
+const CreditScale = 2
@@ -0,0 +1,32 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// EmailVerificationToken proves ownership of Email for UserID. Only the SHA-256 of the emailed token is stored;
+// a token stops working once the user's email no longer matches Email.
+type EmailVerificationToken struct {
+	TokenID   uuid.UUID  `gorm:"column:token_id;type:uuid;primaryKey" json:"token_id"`
+	UserID    uuid.UUID  `gorm:"column:user_id;type:uuid;not null;index" json:"user_id"`
+	Email     string     `gorm:"column:email;not null" json:"email"`
+	TokenHash string     `gorm:"column:token_hash;not null;uniqueIndex" json:"-"`
+	ExpiresAt time.Time  `gorm:"column:expires_at;not null" json:"expires_at"`
+	UsedAt    *time.Time `gorm:"column:used_at" json:"used_at"`
+	CreatedAt time.Time  `gorm:"column:createdAt" json:"createdAt"`
+}
+
+func (EmailVerificationToken) TableName() string {
+	return "EmailVerificationTokens"
+}
+
+// BeforeCreate: never insert zero UUID for primary key; generate random when not set.
+func (t *EmailVerificationToken) BeforeCreate(tx *gorm.DB) error {
+	if t.TokenID == uuid.Nil {
+		t.TokenID = uuid.New()
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package domain
+
+import (
+	"time"
+
+	"troo-backend/internal/pkg/decimal"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// HoldingAdjustment records a manual balance correction made by a platform operator, with its mandatory reason.
+type HoldingAdjustment struct {
+	AdjustmentID  uuid.UUID       `gorm:"column:adjustment_id;type:uuid;primaryKey" json:"adjustment_id"`
+	OrgID         uuid.UUID       `gorm:"column:org_id;type:uuid;not null;index" json:"org_id"`
+	ProjectID     uuid.UUID       `gorm:"column:project_id;type:uuid;not null" json:"project_id"`
+	Delta         decimal.Decimal `gorm:"column:delta;type:decimal(18,2);not null" json:"delta"`
+	BalanceBefore decimal.Decimal `gorm:"column:balance_before;type:decimal(18,2);not null" json:"balance_before"`
+	BalanceAfter  decimal.Decimal `gorm:"column:balance_after;type:decimal(18,2);not null" json:"balance_after"`
+	Reason        string          `gorm:"column:reason;type:text;not null" json:"reason"`
+	AdjustedBy    uuid.UUID       `gorm:"column:adjusted_by;type:uuid;not null" json:"adjusted_by"`
+	CreatedAt     time.Time       `gorm:"column:createdAt" json:"createdAt"`
+}
+
+func (HoldingAdjustment) TableName() string {
+	return "HoldingAdjustments"
+}
+
+// BeforeCreate: never insert zero UUID for primary key; generate random when not set.
+func (a *HoldingAdjustment) BeforeCreate(tx *gorm.DB) error {
+	if a.AdjustmentID == uuid.Nil {
+		a.AdjustmentID = uuid.New()
+	}
+	return nil
+}
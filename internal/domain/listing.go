@@ -6,6 +6,8 @@ import (
 	"errors"
 	"time"
 
+	"troo-backend/internal/pkg/decimal"
+
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
@@ -70,8 +72,10 @@ type Listing struct {
 	ListingID        uuid.UUID      `gorm:"column:listing_id;type:uuid;primaryKey" json:"listing_id"`
 	ProjectID        uuid.UUID      `gorm:"column:project_id;type:uuid;not null" json:"project_id"`
 	SellerID         *uuid.UUID     `gorm:"column:seller_id;type:uuid" json:"seller_id"`
-	CreditsAvailable float64        `gorm:"column:credits_available;type:decimal(18,2);not null" json:"credits_available"`
-	PricePerCredit   float64        `gorm:"column:price_per_credit;type:decimal(18,2);not null" json:"price_per_credit"`
+	// SubAccountID is the seller's sub-account the credits are listed from; nil for the main account (Go-only column).
+	SubAccountID     *uuid.UUID     `gorm:"column:sub_account_id;type:uuid" json:"sub_account_id"`
+	CreditsAvailable decimal.Decimal `gorm:"column:credits_available;type:decimal(18,2);not null" json:"credits_available"`
+	PricePerCredit   decimal.Decimal `gorm:"column:price_per_credit;type:decimal(18,2);not null" json:"price_per_credit"`
 	ExternalTradeID  *string        `gorm:"column:external_trade_id" json:"external_trade_id"`
 	ProjectName      string         `gorm:"column:project_name;not null" json:"project_name"`
 	ProjectStartYear int            `gorm:"column:project_start_year;not null" json:"project_start_year"`
@@ -16,6 +16,13 @@ type Org struct {
 	RegistrationID     *string        `gorm:"column:registration_id" json:"registration_id"`
 	LogoURL            *string        `gorm:"column:logo_url" json:"logo_url"`
 	IncorporationDocURL *string       `gorm:"column:incorporation_doc_url" json:"incorporation_doc_url"`
+	// RequireTwoFactor makes members without TOTP lose their trading permissions (Go-only column).
+	RequireTwoFactor   bool           `gorm:"column:require_two_factor;not null;default:false" json:"require_two_factor"`
+	// PublicRetirements opts the org's retirement totals and certificates into its public profile (Go-only column).
+	PublicRetirements bool `gorm:"column:public_retirements;not null;default:false" json:"public_retirements"`
+	// ClosedAt is set when the org is offboarded; the row is kept for its financial records (Go-only columns).
+	ClosedAt *time.Time `gorm:"column:closed_at" json:"closed_at"`
+	ClosedBy *uuid.UUID `gorm:"column:closed_by;type:uuid" json:"closed_by"`
 	CreatedAt time.Time `gorm:"column:createdAt" json:"createdAt"`
 	UpdatedAt time.Time `gorm:"column:updatedAt" json:"updatedAt"`
 }
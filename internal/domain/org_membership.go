@@ -0,0 +1,24 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OrgMembership is a user's role in one org. A user may belong to several orgs; the Users row's org_id,
+// role and custom_role_id mirror the membership of their home org. The org a session works in is kept on
+// the session.
+type OrgMembership struct {
+	UserID       uuid.UUID   `gorm:"column:user_id;type:uuid;primaryKey" json:"user_id"`
+	OrgID        uuid.UUID   `gorm:"column:org_id;type:uuid;primaryKey;index" json:"org_id"`
+	Role         string      `gorm:"column:role;not null;default:viewer" json:"role"`
+	CustomRoleID *uuid.UUID  `gorm:"column:custom_role_id;type:uuid" json:"custom_role_id"`
+	CustomRole   *CustomRole `gorm:"foreignKey:CustomRoleID;references:RoleID" json:"-"`
+	CreatedAt    time.Time   `gorm:"column:createdAt" json:"createdAt"`
+	UpdatedAt    time.Time   `gorm:"column:updatedAt" json:"updatedAt"`
+}
+
+func (OrgMembership) TableName() string {
+	return "OrgMemberships"
+}
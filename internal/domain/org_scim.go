@@ -0,0 +1,40 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OrgSCIMToken is the bearer token an org's identity provider uses on the SCIM endpoints. One per org;
+// only a SHA-256 hash is stored, and rotating it replaces the row.
+type OrgSCIMToken struct {
+	OrgID      uuid.UUID  `gorm:"column:org_id;type:uuid;primaryKey" json:"org_id"`
+	TokenHash  string     `gorm:"column:token_hash;not null;uniqueIndex" json:"-"`
+	CreatedBy  uuid.UUID  `gorm:"column:created_by;type:uuid;not null" json:"created_by"`
+	LastUsedAt *time.Time `gorm:"column:last_used_at" json:"last_used_at"`
+	CreatedAt  time.Time  `gorm:"column:createdAt" json:"createdAt"`
+	UpdatedAt  time.Time  `gorm:"column:updatedAt" json:"updatedAt"`
+}
+
+func (OrgSCIMToken) TableName() string {
+	return "OrgSCIMTokens"
+}
+
+// SCIMUser links a user to the org whose identity provider manages them. It outlives membership, so a
+// deactivated user (removed from the org) can still be read and reactivated over SCIM. A user is active
+// while they are a member of OrgID.
+type SCIMUser struct {
+	UserID     uuid.UUID `gorm:"column:user_id;type:uuid;primaryKey" json:"user_id"`
+	OrgID      uuid.UUID `gorm:"column:org_id;type:uuid;not null;index" json:"org_id"`
+	ExternalID string    `gorm:"column:external_id" json:"external_id"`
+	// Provisioned is set when the org's provider created the account; only then may it change the user's
+	// email and name.
+	Provisioned bool      `gorm:"column:provisioned;not null;default:false" json:"provisioned"`
+	CreatedAt   time.Time `gorm:"column:createdAt" json:"createdAt"`
+	UpdatedAt   time.Time `gorm:"column:updatedAt" json:"updatedAt"`
+}
+
+func (SCIMUser) TableName() string {
+	return "SCIMUsers"
+}
@@ -0,0 +1,46 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+)
+
+// OrgSSOConfig is an org's OpenID Connect identity provider. Sign-in is routed to it by email domain, and
+// users it signs in for the first time are created in the org with DefaultRole. A domain only routes sign-ins
+// once the org has proven it owns it with a DNS TXT record carrying VerificationToken.
+type OrgSSOConfig struct {
+	OrgID        uuid.UUID `gorm:"column:org_id;type:uuid;primaryKey" json:"org_id"`
+	Issuer       string    `gorm:"column:issuer;not null" json:"issuer"`
+	ClientID     string    `gorm:"column:client_id;not null" json:"client_id"`
+	ClientSecret string    `gorm:"column:client_secret;not null" json:"-"`
+	// EmailDomains is a JSON array of lower-case domains the org claims. Several orgs may claim a domain, but
+	// only one can verify it; VerifiedDomains is the verified subset, the only one used for sign-in.
+	EmailDomains      datatypes.JSON `gorm:"column:email_domains;type:jsonb;not null" json:"email_domains"`
+	VerifiedDomains   datatypes.JSON `gorm:"column:verified_domains;type:jsonb" json:"verified_domains"`
+	VerificationToken string         `gorm:"column:verification_token" json:"-"`
+	DefaultRole       string         `gorm:"column:default_role;not null;default:viewer" json:"default_role"`
+	Enabled           bool           `gorm:"column:enabled;not null;default:false" json:"enabled"`
+	CreatedAt         time.Time      `gorm:"column:createdAt" json:"createdAt"`
+	UpdatedAt         time.Time      `gorm:"column:updatedAt" json:"updatedAt"`
+}
+
+func (OrgSSOConfig) TableName() string {
+	return "OrgSSOConfigs"
+}
+
+// DomainList decodes EmailDomains; a malformed value yields no domains.
+func (c *OrgSSOConfig) DomainList() []string {
+	var out []string
+	_ = json.Unmarshal(c.EmailDomains, &out)
+	return out
+}
+
+// VerifiedDomainList decodes VerifiedDomains; a malformed or empty value yields no domains.
+func (c *OrgSSOConfig) VerifiedDomainList() []string {
+	var out []string
+	_ = json.Unmarshal(c.VerifiedDomains, &out)
+	return out
+}
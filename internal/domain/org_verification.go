@@ -0,0 +1,29 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OrgVerification is an org's know-your-business review. Orgs without a row are unverified. The registration
+// ID and incorporation document are copied from the Orgs row when the org submits, so the operator reviews
+// what was submitted even if the org edits its profile afterwards.
+type OrgVerification struct {
+	OrgID               uuid.UUID  `gorm:"column:org_id;type:uuid;primaryKey" json:"org_id"`
+	Status              string     `gorm:"column:status;type:varchar(20);not null;default:'unverified';index" json:"status"` // "unverified" | "submitted" | "under_review" | "verified" | "rejected"
+	RegistrationID      *string    `gorm:"column:registration_id" json:"registration_id"`
+	IncorporationDocURL *string    `gorm:"column:incorporation_doc_url" json:"incorporation_doc_url"`
+	SubmittedBy         *uuid.UUID `gorm:"column:submitted_by;type:uuid" json:"submitted_by"`
+	SubmittedAt         *time.Time `gorm:"column:submitted_at" json:"submitted_at"`
+	ReviewedBy          *uuid.UUID `gorm:"column:reviewed_by;type:uuid" json:"reviewed_by"`
+	ReviewStartedAt     *time.Time `gorm:"column:review_started_at" json:"review_started_at"`
+	ReviewedAt          *time.Time `gorm:"column:reviewed_at" json:"reviewed_at"`
+	RejectionReason     *string    `gorm:"column:rejection_reason" json:"rejection_reason"`
+	CreatedAt           time.Time  `gorm:"column:createdAt" json:"createdAt"`
+	UpdatedAt           time.Time  `gorm:"column:updatedAt" json:"updatedAt"`
+}
+
+func (OrgVerification) TableName() string {
+	return "OrgVerifications"
+}
@@ -0,0 +1,30 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// PasswordResetToken is a single-use password reset link. Only the SHA-256 of the emailed token is stored.
+type PasswordResetToken struct {
+	TokenID   uuid.UUID  `gorm:"column:token_id;type:uuid;primaryKey" json:"token_id"`
+	UserID    uuid.UUID  `gorm:"column:user_id;type:uuid;not null;index" json:"user_id"`
+	TokenHash string     `gorm:"column:token_hash;not null;uniqueIndex" json:"-"`
+	ExpiresAt time.Time  `gorm:"column:expires_at;not null" json:"expires_at"`
+	UsedAt    *time.Time `gorm:"column:used_at" json:"used_at"`
+	CreatedAt time.Time  `gorm:"column:createdAt" json:"createdAt"`
+}
+
+func (PasswordResetToken) TableName() string {
+	return "PasswordResetTokens"
+}
+
+// BeforeCreate: never insert zero UUID for primary key; generate random when not set.
+func (t *PasswordResetToken) BeforeCreate(tx *gorm.DB) error {
+	if t.TokenID == uuid.Nil {
+		t.TokenID = uuid.New()
+	}
+	return nil
+}
@@ -3,6 +3,8 @@ package domain
 import (
 	"time"
 
+	"troo-backend/internal/pkg/decimal"
+
 	"github.com/google/uuid"
 	"gorm.io/datatypes"
 	"gorm.io/gorm"
@@ -14,7 +16,7 @@ type Payment struct {
 	StripeEventID          string         `gorm:"column:stripe_event_id;uniqueIndex;not null" json:"stripe_event_id"`
 	BuyerOrgID             uuid.UUID      `gorm:"column:buyer_org_id;type:uuid;not null" json:"buyer_org_id"`
 	ListingID              uuid.UUID      `gorm:"column:listing_id;type:uuid;not null" json:"listing_id"`
-	CreditsAmount          float64        `gorm:"column:credits_amount;type:decimal;not null" json:"credits_amount"`
+	CreditsAmount          decimal.Decimal `gorm:"column:credits_amount;type:decimal;not null" json:"credits_amount"`
 	AmountPaidCents        int            `gorm:"column:amount_paid_cents;not null" json:"amount_paid_cents"`
 	Currency               string         `gorm:"column:currency;not null" json:"currency"`
 	Status                 string         `gorm:"column:status;not null" json:"status"`
@@ -0,0 +1,21 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PlatformOperator grants a user a platform role (constants.ValidPlatformRoles) for cross-org back-office work.
+// It is separate from Users.role, which only ever applies within the user's own org.
+type PlatformOperator struct {
+	UserID    uuid.UUID  `gorm:"column:user_id;type:uuid;primaryKey" json:"user_id"`
+	Role      string     `gorm:"column:role;type:varchar(32);not null" json:"role"`
+	GrantedBy *uuid.UUID `gorm:"column:granted_by;type:uuid" json:"granted_by"`
+	CreatedAt time.Time  `gorm:"column:createdAt" json:"createdAt"`
+	UpdatedAt time.Time  `gorm:"column:updatedAt" json:"updatedAt"`
+}
+
+func (PlatformOperator) TableName() string {
+	return "PlatformOperators"
+}
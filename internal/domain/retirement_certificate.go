@@ -3,6 +3,8 @@ package domain
 import (
 	"time"
 
+	"troo-backend/internal/pkg/decimal"
+
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
@@ -11,7 +13,7 @@ type RetirementCertificate struct {
 	CertificateID     uuid.UUID      `gorm:"column:certificate_id;type:uuid;primaryKey" json:"certificate_id"`
 	OrgID             uuid.UUID      `gorm:"column:org_id;type:uuid;not null" json:"org_id"`
 	ProjectID         uuid.UUID      `gorm:"column:project_id;type:uuid;not null" json:"project_id"`
-	Amount            float64        `gorm:"column:amount;type:decimal(18,2);not null" json:"amount"`
+	Amount            decimal.Decimal `gorm:"column:amount;type:decimal(18,2);not null" json:"amount"`
 	RetiredAt         time.Time      `gorm:"column:retired_at;not null" json:"retired_at"`
 	Purpose           *string        `gorm:"column:purpose" json:"purpose"`
 	Beneficiary       *string        `gorm:"column:beneficiary" json:"beneficiary"`
@@ -0,0 +1,49 @@
+package domain
+
+import (
+	"time"
+
+	"troo-backend/internal/pkg/decimal"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// SubAccount is a business unit, client or fund within an org that credits can be attributed to.
+type SubAccount struct {
+	SubAccountID uuid.UUID  `gorm:"column:sub_account_id;type:uuid;primaryKey" json:"sub_account_id"`
+	OrgID        uuid.UUID  `gorm:"column:org_id;type:uuid;not null;uniqueIndex:idx_sub_accounts_org_name" json:"org_id"`
+	Name         string     `gorm:"column:name;not null;uniqueIndex:idx_sub_accounts_org_name" json:"name"`
+	Description  *string    `gorm:"column:description" json:"description"`
+	CreatedBy    *uuid.UUID `gorm:"column:created_by;type:uuid" json:"created_by"`
+	CreatedAt    time.Time  `gorm:"column:createdAt" json:"createdAt"`
+	UpdatedAt    time.Time  `gorm:"column:updatedAt" json:"updatedAt"`
+}
+
+func (SubAccount) TableName() string {
+	return "SubAccounts"
+}
+
+// BeforeCreate: never insert zero UUID for primary key; generate random when not set.
+func (s *SubAccount) BeforeCreate(tx *gorm.DB) error {
+	if s.SubAccountID == uuid.Nil {
+		s.SubAccountID = uuid.New()
+	}
+	return nil
+}
+
+// SubAccountHolding is the part of an org's Holding for a project attributed to one sub-account. The Holdings
+// row stays the org's total (as Express sees it); what no sub-account holds is the org's main account.
+type SubAccountHolding struct {
+	SubAccountID  uuid.UUID       `gorm:"column:sub_account_id;type:uuid;primaryKey" json:"sub_account_id"`
+	ProjectID     uuid.UUID       `gorm:"column:project_id;type:uuid;primaryKey" json:"project_id"`
+	OrgID         uuid.UUID       `gorm:"column:org_id;type:uuid;not null;index" json:"org_id"`
+	CreditBalance decimal.Decimal `gorm:"column:credit_balance;type:decimal(18,2);not null;default:0" json:"credit_balance"`
+	LockedForSale decimal.Decimal `gorm:"column:locked_for_sale;type:decimal(18,2);not null;default:0" json:"locked_for_sale"`
+	CreatedAt     time.Time       `gorm:"column:createdAt" json:"createdAt"`
+	UpdatedAt     time.Time       `gorm:"column:updatedAt" json:"updatedAt"`
+}
+
+func (SubAccountHolding) TableName() string {
+	return "SubAccountHoldings"
+}
@@ -0,0 +1,67 @@
+package domain
+
+import (
+	"time"
+
+	"troo-backend/internal/pkg/decimal"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// TradingLimit caps what one user, or every user holding a role, may trade on behalf of the org.
+// Exactly one of UserID and Role is set; a user's own limit replaces the limit of their role. Nil caps are unlimited.
+type TradingLimit struct {
+	LimitID                uuid.UUID        `gorm:"column:limit_id;type:uuid;primaryKey" json:"limit_id"`
+	OrgID                  uuid.UUID        `gorm:"column:org_id;type:uuid;not null;index" json:"org_id"`
+	UserID                 *uuid.UUID       `gorm:"column:user_id;type:uuid" json:"user_id"`
+	Role                   *string          `gorm:"column:role" json:"role"`
+	MaxCreditsPerTrade     *decimal.Decimal `gorm:"column:max_credits_per_trade;type:decimal(18,2)" json:"max_credits_per_trade"`
+	MaxDailySpend          *decimal.Decimal `gorm:"column:max_daily_spend;type:decimal(18,2)" json:"max_daily_spend"`
+	MaxDailyTransferVolume *decimal.Decimal `gorm:"column:max_daily_transfer_volume;type:decimal(18,2)" json:"max_daily_transfer_volume"`
+	AllowedProjectIDs      datatypes.JSON   `gorm:"column:allowed_project_ids;type:jsonb" json:"allowed_project_ids"` // null = all projects
+	UpdatedBy              *uuid.UUID       `gorm:"column:updated_by;type:uuid" json:"updated_by"`
+	CreatedAt              time.Time        `gorm:"column:createdAt" json:"createdAt"`
+	UpdatedAt              time.Time        `gorm:"column:updatedAt" json:"updatedAt"`
+}
+
+func (TradingLimit) TableName() string {
+	return "TradingLimits"
+}
+
+// BeforeCreate: never insert zero UUID for primary key; generate random when not set.
+func (l *TradingLimit) BeforeCreate(tx *gorm.DB) error {
+	if l.LimitID == uuid.Nil {
+		l.LimitID = uuid.New()
+	}
+	return nil
+}
+
+// TradingLimitUsage records one trade counted against a user's daily limits (Spend is set for buys only).
+// A buy holds its usage until the payment settles: PaymentIntentID links it to the Stripe webhook and the usage
+// stops counting at ExpiresAt unless the payment succeeds first (which clears ExpiresAt).
+type TradingLimitUsage struct {
+	UsageID         uuid.UUID       `gorm:"column:usage_id;type:uuid;primaryKey" json:"usage_id"`
+	OrgID           uuid.UUID       `gorm:"column:org_id;type:uuid;not null" json:"org_id"`
+	UserID          uuid.UUID       `gorm:"column:user_id;type:uuid;not null;index" json:"user_id"`
+	Kind            string          `gorm:"column:kind;type:varchar(20);not null" json:"kind"`
+	ProjectID       uuid.UUID       `gorm:"column:project_id;type:uuid;not null" json:"project_id"`
+	Amount          decimal.Decimal `gorm:"column:amount;type:decimal(18,2);not null" json:"amount"`
+	Spend           decimal.Decimal `gorm:"column:spend;type:decimal(18,2);not null;default:0" json:"spend"`
+	PaymentIntentID *string         `gorm:"column:payment_intent_id;index" json:"payment_intent_id"`
+	ExpiresAt       *time.Time      `gorm:"column:expires_at" json:"expires_at"`
+	CreatedAt       time.Time       `gorm:"column:createdAt" json:"createdAt"`
+}
+
+func (TradingLimitUsage) TableName() string {
+	return "TradingLimitUsages"
+}
+
+// BeforeCreate: never insert zero UUID for primary key; generate random when not set.
+func (u *TradingLimitUsage) BeforeCreate(tx *gorm.DB) error {
+	if u.UsageID == uuid.Nil {
+		u.UsageID = uuid.New()
+	}
+	return nil
+}
@@ -3,6 +3,8 @@ package domain
 import (
 	"time"
 
+	"troo-backend/internal/pkg/decimal"
+
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
@@ -13,8 +15,12 @@ type Transaction struct {
 	ProjectID        uuid.UUID      `gorm:"column:project_id;type:uuid;not null" json:"project_id"`
 	FromOrgID        *uuid.UUID     `gorm:"column:from_org_id;type:uuid" json:"from_org_id"`
 	ToOrgID          *uuid.UUID     `gorm:"column:to_org_id;type:uuid" json:"to_org_id"`
-	Amount           float64        `gorm:"column:amount;type:decimal(18,2);not null" json:"amount"`
+	Amount           decimal.Decimal `gorm:"column:amount;type:decimal(18,2);not null" json:"amount"`
 	RelatedListingID *uuid.UUID `gorm:"column:related_listing_id;type:uuid" json:"related_listing_id"`
+	ActorUserID      *uuid.UUID `gorm:"column:actor_user_id;type:uuid" json:"actor_user_id"` // user who bought, sold, transferred or retired
+	// Sub-accounts the credits left and arrived in; nil for the main account (Go-only columns).
+	SubAccountID     *uuid.UUID `gorm:"column:sub_account_id;type:uuid" json:"sub_account_id"`
+	ToSubAccountID   *uuid.UUID `gorm:"column:to_sub_account_id;type:uuid" json:"to_sub_account_id"`
 	CreatedAt        time.Time  `gorm:"column:createdAt" json:"createdAt"`
 	UpdatedAt        time.Time  `gorm:"column:updatedAt" json:"updatedAt"`
 }
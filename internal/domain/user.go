@@ -13,9 +13,15 @@ type User struct {
 	Fullname    string         `gorm:"column:fullname;not null" json:"fullname"`
 	UserName    string         `gorm:"column:user_name;not null" json:"user_name"`
 	Email       string         `gorm:"column:email;not null;uniqueIndex" json:"email"`
+	// EmailVerified is cleared on registration and on every email change until the emailed link is used.
+	EmailVerified bool         `gorm:"column:email_verified;not null;default:false" json:"email_verified"`
 	PasswordHash string        `gorm:"column:password_hash;not null" json:"-"`
+	// OrgID, Role and CustomRoleID are the user's active org membership (see OrgMembership).
 	OrgID       *uuid.UUID     `gorm:"column:org_id;type:uuid" json:"org_id"`
 	Role        string    `gorm:"column:role;not null;default:viewer" json:"role"`
+	CustomRoleID *uuid.UUID  `gorm:"column:custom_role_id;type:uuid" json:"custom_role_id"`
+	CustomRole   *CustomRole `gorm:"foreignKey:CustomRoleID;references:RoleID" json:"-"`
+	PlatformOperator *PlatformOperator `gorm:"foreignKey:UserID;references:UserID" json:"-"`
 	CreatedAt   time.Time `gorm:"column:createdAt" json:"createdAt"`
 	UpdatedAt   time.Time `gorm:"column:updatedAt" json:"updatedAt"`
 }
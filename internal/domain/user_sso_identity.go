@@ -0,0 +1,20 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UserSSOIdentity links a user to the subject an identity provider signs them in as, so a later change of
+// email at the provider still signs in the same user.
+type UserSSOIdentity struct {
+	Issuer    string    `gorm:"column:issuer;primaryKey" json:"issuer"`
+	Subject   string    `gorm:"column:subject;primaryKey" json:"subject"`
+	UserID    uuid.UUID `gorm:"column:user_id;type:uuid;not null;index" json:"user_id"`
+	CreatedAt time.Time `gorm:"column:createdAt" json:"createdAt"`
+}
+
+func (UserSSOIdentity) TableName() string {
+	return "UserSSOIdentities"
+}
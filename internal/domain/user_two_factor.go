@@ -0,0 +1,25 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+)
+
+// UserTwoFactor holds a user's TOTP enrolment. The row exists from setup; EnabledAt is set once a first code
+// has been verified, and only then does login ask for the second factor.
+type UserTwoFactor struct {
+	UserID       uuid.UUID  `gorm:"column:user_id;type:uuid;primaryKey" json:"user_id"`
+	Secret       string     `gorm:"column:secret;not null" json:"-"`
+	EnabledAt    *time.Time `gorm:"column:enabled_at" json:"enabled_at"`
+	LastUsedStep int64      `gorm:"column:last_used_step;not null;default:0" json:"-"`
+	// RecoveryCodes is a JSON array of SHA-256 hashes; a code is removed once used.
+	RecoveryCodes datatypes.JSON `gorm:"column:recovery_codes;type:jsonb" json:"-"`
+	CreatedAt     time.Time      `gorm:"column:createdAt" json:"createdAt"`
+	UpdatedAt     time.Time      `gorm:"column:updatedAt" json:"updatedAt"`
+}
+
+func (UserTwoFactor) TableName() string {
+	return "UserTwoFactors"
+}
@@ -17,7 +17,89 @@ func Open(dsn string) (*gorm.DB, error) {
 	}), &gorm.Config{})
 }
 
-// AutoMigrate runs migrations for core models (User for auth).
+// AutoMigrate runs migrations for core models (User for auth) and the tables owned by the Go service only.
 func AutoMigrate(db *gorm.DB) error {
-	return db.AutoMigrate(&domain.User{})
+	// Accounts created before email verification existed are treated as verified.
+	grandfatherEmails := !db.Migrator().HasColumn(&domain.User{}, "EmailVerified")
+	// Orgs that were trading before verification existed are treated as verified.
+	grandfatherOrgs := !db.Migrator().HasTable(&domain.OrgVerification{})
+	if err := db.AutoMigrate(
+		&domain.CustomRole{},
+		&domain.User{},
+		&domain.OrgMembership{},
+		&domain.PlatformOperator{},
+		&domain.HoldingAdjustment{},
+		&domain.ApprovalPolicy{},
+		&domain.ApprovalRequest{},
+		&domain.TradingLimit{},
+		&domain.TradingLimitUsage{},
+		&domain.AuditLog{},
+		&domain.PasswordResetToken{},
+		&domain.EmailVerificationToken{},
+		&domain.UserTwoFactor{},
+		&domain.OrgAPIKey{},
+		&domain.OrgSSOConfig{},
+		&domain.UserSSOIdentity{},
+		&domain.OrgSCIMToken{},
+		&domain.SCIMUser{},
+		&domain.OrgVerification{},
+		&domain.SubAccount{},
+		&domain.SubAccountHolding{},
+	); err != nil {
+		return err
+	}
+	if grandfatherEmails {
+		if err := db.Model(&domain.User{}).Where("1 = 1").Update("email_verified", true).Error; err != nil {
+			return err
+		}
+	}
+	if grandfatherOrgs {
+		if err := db.Exec(`INSERT INTO "OrgVerifications" (org_id, status, registration_id, incorporation_doc_url, "createdAt", "updatedAt")
+			SELECT org_id, 'verified', registration_id, incorporation_doc_url, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP FROM "Orgs"`).Error; err != nil {
+			return err
+		}
+	}
+	if err := backfillMemberships(db); err != nil {
+		return err
+	}
+	return addSharedColumns(db)
+}
+
+// backfillMemberships gives every user with an org a membership of it, covering users who joined before
+// memberships existed and any whose org Express has set since the last run.
+func backfillMemberships(db *gorm.DB) error {
+	return db.Exec(`INSERT INTO "OrgMemberships" (user_id, org_id, role, custom_role_id, "createdAt", "updatedAt")
+		SELECT u.user_id, u.org_id, u.role, u.custom_role_id, u."createdAt", u."updatedAt" FROM "Users" u
+		WHERE u.org_id IS NOT NULL AND NOT EXISTS (
+			SELECT 1 FROM "OrgMemberships" m WHERE m.user_id = u.user_id AND m.org_id = u.org_id
+		)`).Error
+}
+
+// addSharedColumns adds the Go-only columns to tables shared with Express. Those tables are not
+// AutoMigrated because that would also realign the types of their existing columns.
+func addSharedColumns(db *gorm.DB) error {
+	shared := []struct {
+		model interface{}
+		field string
+	}{
+		{&domain.ListingEvent{}, "ActorUserID"},
+		{&domain.Transaction{}, "ActorUserID"},
+		{&domain.Listing{}, "SubAccountID"},
+		{&domain.Transaction{}, "SubAccountID"},
+		{&domain.Transaction{}, "ToSubAccountID"},
+		{&domain.Org{}, "RequireTwoFactor"},
+		{&domain.Org{}, "PublicRetirements"},
+		{&domain.Org{}, "ClosedAt"},
+		{&domain.Org{}, "ClosedBy"},
+	}
+	m := db.Migrator()
+	for _, c := range shared {
+		if m.HasColumn(c.model, c.field) {
+			continue
+		}
+		if err := m.AddColumn(c.model, c.field); err != nil {
+			return err
+		}
+	}
+	return nil
 }
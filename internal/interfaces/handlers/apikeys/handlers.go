@@ -0,0 +1,109 @@
+package apikeys
+
+import (
+	"time"
+
+	apikeysvc "troo-backend/internal/application/apikeys"
+	auditsvc "troo-backend/internal/application/audit"
+	"troo-backend/internal/middleware"
+	"troo-backend/internal/pkg/response"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type Handlers struct {
+	Service *apikeysvc.Service
+	Audit   *auditsvc.Service
+}
+
+type keyBody struct {
+	KeyID       string     `json:"key_id"`
+	Name        string     `json:"name"`
+	Permissions []string   `json:"permissions"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+}
+
+var keyErrorStatus = map[string]int{
+	"API key name is required":                   400,
+	"At least one permission is required":        400,
+	"Expiry must be in the future":               400,
+	"Permission cannot be granted to an API key": 400,
+	"API key not found":                          404,
+}
+
+// GET /api/v1/api-keys/view-keys — the org's active keys, without their secrets.
+func (h *Handlers) ViewKeys(c *fiber.Ctx) error {
+	_, orgID, ok := middleware.SessionActor(c)
+	if !ok {
+		return response.Error(c, "User is not associated with an organization", 403, nil)
+	}
+	out, err := h.Service.ListKeys(c.Context(), orgID)
+	if err != nil {
+		return response.Error(c, "Internal Server Error", 500, nil)
+	}
+	return response.Success(c, "API keys fetched successfully", out, nil)
+}
+
+// POST /api/v1/api-keys/create-key — data.key is shown only in this response. In orgs that require
+// two-factor authentication the session must have passed it, as keys can trade without one.
+func (h *Handlers) CreateKey(c *fiber.Ctx) error {
+	userID, orgID, ok := middleware.SessionActor(c)
+	if !ok {
+		return response.Error(c, "User is not associated with an organization", 403, nil)
+	}
+	if m, _ := middleware.GetUser(c).(map[string]interface{}); m["two_factor_required"] == true && m["two_factor"] != true {
+		return response.Error(c, "Your organization requires two-factor authentication for this action", 403, nil)
+	}
+	var body keyBody
+	if err := c.BodyParser(&body); err != nil {
+		return response.Error(c, "Invalid request body", 400, nil)
+	}
+	key, err := h.Service.CreateKey(c.Context(), apikeysvc.CreateInput{
+		OrgID: orgID, ActorUserID: userID, Name: body.Name, Permissions: body.Permissions, ExpiresAt: body.ExpiresAt,
+	})
+	if err != nil {
+		return keyError(c, err)
+	}
+	middleware.Audit(c, h.Audit, auditsvc.Entry{
+		Action:     auditsvc.ActionAPIKeyCreated,
+		TargetType: "api_key",
+		TargetID:   key.KeyID.String(),
+		After:      key.KeyView,
+	})
+	return response.SuccessCreated(c, "API key created successfully", key, nil)
+}
+
+// DELETE /api/v1/api-keys/revoke-key
+func (h *Handlers) RevokeKey(c *fiber.Ctx) error {
+	_, orgID, ok := middleware.SessionActor(c)
+	if !ok {
+		return response.Error(c, "User is not associated with an organization", 403, nil)
+	}
+	var body keyBody
+	if err := c.BodyParser(&body); err != nil {
+		return response.Error(c, "Invalid request body", 400, nil)
+	}
+	keyID, err := uuid.Parse(body.KeyID)
+	if err != nil {
+		return response.Error(c, "Invalid key_id", 400, nil)
+	}
+	key, err := h.Service.RevokeKey(c.Context(), orgID, keyID)
+	if err != nil {
+		return keyError(c, err)
+	}
+	middleware.Audit(c, h.Audit, auditsvc.Entry{
+		Action:     auditsvc.ActionAPIKeyRevoked,
+		TargetType: "api_key",
+		TargetID:   keyID.String(),
+		Before:     key,
+	})
+	return response.Success(c, "API key revoked successfully", nil, nil)
+}
+
+func keyError(c *fiber.Ctx, err error) error {
+	if code, ok := keyErrorStatus[err.Error()]; ok {
+		return response.Error(c, err.Error(), code, nil)
+	}
+	return response.Error(c, "Internal Server Error", 500, nil)
+}
@@ -0,0 +1,174 @@
+package apikeys
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	apikeysvc "troo-backend/internal/application/apikeys"
+	"troo-backend/internal/domain"
+	"troo-backend/internal/middleware"
+	"troo-backend/internal/pkg/constants"
+
+	"github.com/glebarez/sqlite"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type keysFixture struct {
+	db    *gorm.DB
+	app   *fiber.App
+	owner domain.User
+}
+
+// setupKeysTest signs in X-Test-User as a session would and mounts an integration route behind APIKeyAuth.
+func setupKeysTest(t *testing.T) *keysFixture {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&domain.CustomRole{}, &domain.User{}, &domain.OrgMembership{}, &domain.OrgAPIKey{}))
+	orgID := uuid.New()
+	f := &keysFixture{db: db, owner: domain.User{
+		UserID: uuid.New(), UserName: "owner", Email: "owner@example.com", Fullname: "Owner",
+		Role: constants.Superadmin, OrgID: &orgID, EmailVerified: true,
+	}}
+	require.NoError(t, db.Create(&f.owner).Error)
+
+	svc := &apikeysvc.Service{DB: db}
+	h := &Handlers{Service: svc}
+	f.app = fiber.New()
+	f.app.Use(func(c *fiber.Ctx) error {
+		var u domain.User
+		if err := db.Where("user_id = ?", c.Get("X-Test-User")).First(&u).Error; err == nil {
+			org := u.OrgID.String()
+			c.Locals("user", map[string]interface{}{"user_id": u.UserID.String(), "role": u.Role, "org_id": org, "email_verified": true})
+		}
+		return c.Next()
+	})
+	f.app.Get("/view-keys", middleware.AuthorizePermission(constants.ManageAPIKeys), h.ViewKeys)
+	f.app.Post("/create-key", middleware.AuthorizePermission(constants.ManageAPIKeys), h.CreateKey)
+	f.app.Delete("/revoke-key", middleware.AuthorizePermission(constants.ManageAPIKeys), h.RevokeKey)
+	erp := f.app.Group("/erp", middleware.APIKeyAuth(svc), middleware.RequireAuth())
+	erp.Post("/retire", middleware.AuthorizePermission(constants.RetireCredits), func(c *fiber.Ctx) error {
+		return c.JSON(middleware.GetUser(c))
+	})
+	erp.Post("/buy", middleware.AuthorizePermission(constants.BuyCredits), func(c *fiber.Ctx) error { return c.SendStatus(200) })
+	return f
+}
+
+func (f *keysFixture) do(t *testing.T, method, path string, body interface{}, headers map[string]string) (int, map[string]interface{}) {
+	b, _ := json.Marshal(body)
+	req := httptest.NewRequest(method, path, bytes.NewReader(b))
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := f.app.Test(req)
+	require.NoError(t, err)
+	var out map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&out)
+	return resp.StatusCode, out
+}
+
+func TestAPIKeys_Lifecycle(t *testing.T) {
+	f := setupKeysTest(t)
+	owner := map[string]string{"X-Test-User": f.owner.UserID.String()}
+
+	code, out := f.do(t, "POST", "/create-key", map[string]interface{}{"name": "ERP", "permissions": []string{constants.ManageAPIKeys}}, owner)
+	assert.Equal(t, 400, code)
+	assert.Equal(t, "Permission cannot be granted to an API key", out["error"].(map[string]interface{})["message"])
+	code, _ = f.do(t, "POST", "/create-key", map[string]interface{}{"name": "ERP", "permissions": []string{constants.RetireCredits}, "expires_at": time.Now().Add(-time.Hour)}, owner)
+	assert.Equal(t, 400, code)
+
+	code, out = f.do(t, "POST", "/create-key", map[string]interface{}{"name": "ERP", "permissions": []string{constants.RetireCredits, constants.RetireCredits}}, owner)
+	require.Equal(t, 201, code, out)
+	data := out["data"].(map[string]interface{})
+	key := data["key"].(string)
+	assert.True(t, strings.HasPrefix(key, data["prefix"].(string)+"_"))
+	assert.Equal(t, []interface{}{constants.RetireCredits}, data["permissions"])
+	bearer := map[string]string{"Authorization": "Bearer " + key}
+
+	// The key acts as its creator, limited to its own permissions.
+	code, out = f.do(t, "POST", "/erp/retire", nil, bearer)
+	require.Equal(t, 200, code, out)
+	assert.Equal(t, f.owner.UserID.String(), out["user_id"])
+	assert.Equal(t, f.owner.OrgID.String(), out["org_id"])
+	assert.Equal(t, data["key_id"], out["api_key_id"])
+	code, _ = f.do(t, "POST", "/erp/buy", nil, bearer)
+	assert.Equal(t, 403, code)
+	code, _ = f.do(t, "POST", "/erp/retire", nil, map[string]string{"Authorization": "Bearer " + key[:len(key)-1] + "x"})
+	assert.Equal(t, 401, code)
+	code, _ = f.do(t, "POST", "/erp/retire", nil, nil)
+	assert.Equal(t, 401, code)
+
+	code, out = f.do(t, "GET", "/view-keys", nil, owner)
+	require.Equal(t, 200, code)
+	listed := out["data"].([]interface{})
+	require.Len(t, listed, 1)
+	assert.NotNil(t, listed[0].(map[string]interface{})["last_used_at"])
+	assert.NotContains(t, listed[0], "key")
+
+	code, _ = f.do(t, "DELETE", "/revoke-key", map[string]string{"key_id": data["key_id"].(string)}, owner)
+	require.Equal(t, 200, code)
+	code, _ = f.do(t, "POST", "/erp/retire", nil, bearer)
+	assert.Equal(t, 401, code)
+	code, _ = f.do(t, "DELETE", "/revoke-key", map[string]string{"key_id": data["key_id"].(string)}, owner)
+	assert.Equal(t, 404, code)
+}
+
+func TestAPIKeys_ExpiryAndCreatorLeaving(t *testing.T) {
+	f := setupKeysTest(t)
+	owner := map[string]string{"X-Test-User": f.owner.UserID.String()}
+	create := func() (string, string) {
+		code, out := f.do(t, "POST", "/create-key", map[string]interface{}{
+			"name": "ERP", "permissions": []string{constants.RetireCredits}, "expires_at": time.Now().Add(time.Hour),
+		}, owner)
+		require.Equal(t, 201, code, out)
+		data := out["data"].(map[string]interface{})
+		return data["key_id"].(string), data["key"].(string)
+	}
+
+	keyID, key := create()
+	code, _ := f.do(t, "POST", "/erp/retire", nil, map[string]string{"Authorization": "Bearer " + key})
+	require.Equal(t, 200, code)
+	require.NoError(t, f.db.Model(&domain.OrgAPIKey{}).Where("key_id = ?", keyID).Update("expires_at", time.Now().Add(-time.Second)).Error)
+	code, _ = f.do(t, "POST", "/erp/retire", nil, map[string]string{"Authorization": "Bearer " + key})
+	assert.Equal(t, 401, code)
+
+	keyID, key = create()
+	require.NoError(t, f.db.Model(&domain.User{}).Where("user_id = ?", f.owner.UserID).Update("org_id", nil).Error)
+	code, out := f.do(t, "POST", "/erp/retire", nil, map[string]string{"Authorization": "Bearer " + key})
+	assert.Equal(t, 401, code)
+	assert.Equal(t, "Invalid API key", out["error"].(map[string]interface{})["message"])
+	var stored domain.OrgAPIKey
+	require.NoError(t, f.db.Where("key_id = ?", keyID).First(&stored).Error)
+	assert.NotNil(t, stored.RevokedAt, "a key whose creator left is revoked")
+}
+
+func TestAPIKeys_RevokedWhenCreatorLosesRole(t *testing.T) {
+	f := setupKeysTest(t)
+	owner := map[string]string{"X-Test-User": f.owner.UserID.String()}
+	code, out := f.do(t, "POST", "/create-key", map[string]interface{}{"name": "ERP", "permissions": []string{constants.RetireCredits}}, owner)
+	require.Equal(t, 201, code, out)
+	data := out["data"].(map[string]interface{})
+	bearer := map[string]string{"Authorization": "Bearer " + data["key"].(string)}
+	code, _ = f.do(t, "POST", "/erp/retire", nil, bearer)
+	require.Equal(t, 200, code)
+
+	require.NoError(t, f.db.Model(&domain.User{}).Where("user_id = ?", f.owner.UserID).Update("role", constants.Admin).Error)
+	code, _ = f.do(t, "POST", "/erp/retire", nil, bearer)
+	assert.Equal(t, 401, code)
+	var stored domain.OrgAPIKey
+	require.NoError(t, f.db.Where("key_id = ?", data["key_id"]).First(&stored).Error)
+	assert.NotNil(t, stored.RevokedAt)
+
+	// Getting the role back does not bring the key back.
+	require.NoError(t, f.db.Model(&domain.User{}).Where("user_id = ?", f.owner.UserID).Update("role", constants.Superadmin).Error)
+	code, _ = f.do(t, "POST", "/erp/retire", nil, bearer)
+	assert.Equal(t, 401, code)
+}
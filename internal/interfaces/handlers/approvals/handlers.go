@@ -0,0 +1,166 @@
+package approvals
+
+import (
+	approvalsvc "troo-backend/internal/application/approvals"
+	auditsvc "troo-backend/internal/application/audit"
+	"troo-backend/internal/middleware"
+	"troo-backend/internal/pkg/decimal"
+	"troo-backend/internal/pkg/response"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type Handlers struct {
+	Service *approvalsvc.Service
+	Audit   *auditsvc.Service
+}
+
+// GET /api/v1/approvals/view-policy
+func (h *Handlers) ViewPolicy(c *fiber.Ctx) error {
+	_, orgID, ok := middleware.SessionActor(c)
+	if !ok {
+		return response.Error(c, "User is not associated with an organization", 403, nil)
+	}
+	policy, err := h.Service.GetPolicy(c.Context(), orgID)
+	if err != nil {
+		return response.Error(c, "Internal Server Error", 500, nil)
+	}
+	return response.Success(c, "Approval policy fetched successfully", policy, nil)
+}
+
+// PUT /api/v1/approvals/update-policy — null threshold disables approval for that operation.
+func (h *Handlers) UpdatePolicy(c *fiber.Ctx) error {
+	userID, orgID, ok := middleware.SessionActor(c)
+	if !ok {
+		return response.Error(c, "User is not associated with an organization", 403, nil)
+	}
+	var body struct {
+		TransferThreshold *decimal.Decimal `json:"transfer_threshold"`
+		RetireThreshold   *decimal.Decimal `json:"retire_threshold"`
+		AllTransfers      bool             `json:"all_transfers"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return response.Error(c, "Invalid request body", 400, nil)
+	}
+	policy, err := h.Service.SetPolicy(c.Context(), approvalsvc.PolicyInput{
+		OrgID:             orgID,
+		UpdatedBy:         userID,
+		TransferThreshold: body.TransferThreshold,
+		RetireThreshold:   body.RetireThreshold,
+		AllTransfers:      body.AllTransfers,
+	})
+	if err != nil {
+		if err.Error() == "Invalid threshold" {
+			return response.Error(c, err.Error(), 400, nil)
+		}
+		return response.Error(c, "Internal Server Error", 500, nil)
+	}
+	return response.Success(c, "Approval policy updated successfully", policy, nil)
+}
+
+// GET /api/v1/approvals/view-requests?status=pending
+func (h *Handlers) ViewRequests(c *fiber.Ctx) error {
+	_, orgID, ok := middleware.SessionActor(c)
+	if !ok {
+		return response.Error(c, "User is not associated with an organization", 403, nil)
+	}
+	status := c.Query("status")
+	switch status {
+	case "", approvalsvc.StatusPending, approvalsvc.StatusApproved, approvalsvc.StatusRejected, approvalsvc.StatusCancelled:
+	default:
+		return response.Error(c, "Invalid status", 400, nil)
+	}
+	reqs, err := h.Service.ListRequests(c.Context(), orgID, status)
+	if err != nil {
+		return response.Error(c, "Internal Server Error", 500, nil)
+	}
+	return response.Success(c, "Approval requests fetched successfully", reqs, nil)
+}
+
+// POST /api/v1/approvals/approve-request — executes the held transfer/retirement.
+func (h *Handlers) ApproveRequest(c *fiber.Ctx) error {
+	userID, orgID, ok := middleware.SessionActor(c)
+	if !ok {
+		return response.Error(c, "User is not associated with an organization", 403, nil)
+	}
+	var body struct {
+		RequestID string `json:"request_id"`
+	}
+	if err := c.BodyParser(&body); err != nil || body.RequestID == "" {
+		return response.Error(c, "request_id is required", 400, nil)
+	}
+	requestID, err := uuid.Parse(body.RequestID)
+	if err != nil {
+		return response.Error(c, "Invalid request_id", 400, nil)
+	}
+
+	req, err := h.Service.Approve(c.Context(), requestID, orgID, userID)
+	if err != nil {
+		statusMap := map[string]int{
+			"Approval request not found":                 404,
+			"Approval request is not pending":            409,
+			"Requester cannot approve their own request": 403,
+			// Execution errors, same codes as the trading endpoints.
+			"Cannot transfer to the same organization":   400,
+			"No Holdings found for this project":         404,
+			"Insufficient available credits to transfer": 400,
+			"Target organization not found":              404,
+			"No holdings found":                          400,
+			"Insufficient available credits to retire":   400,
+			// The org may have lost its verification since the transfer was requested.
+			"Organization must be verified to sell or transfer credits": 403,
+			// The sub-account may have been deleted since.
+			"Sub-account not found": 404,
+		}
+		if code, ok := statusMap[err.Error()]; ok {
+			return response.Error(c, err.Error(), code, nil)
+		}
+		return response.Error(c, "Internal Server Error", 500, nil)
+	}
+	middleware.Audit(c, h.Audit, auditsvc.Entry{
+		Action:     auditsvc.ActionApprovalApproved,
+		TargetType: "approval_request",
+		TargetID:   req.RequestID.String(),
+		After:      req,
+	})
+	return response.Success(c, "Approval request approved", req, nil)
+}
+
+// POST /api/v1/approvals/reject-request
+func (h *Handlers) RejectRequest(c *fiber.Ctx) error {
+	userID, orgID, ok := middleware.SessionActor(c)
+	if !ok {
+		return response.Error(c, "User is not associated with an organization", 403, nil)
+	}
+	var body struct {
+		RequestID string  `json:"request_id"`
+		Reason    *string `json:"reason"`
+	}
+	if err := c.BodyParser(&body); err != nil || body.RequestID == "" {
+		return response.Error(c, "request_id is required", 400, nil)
+	}
+	requestID, err := uuid.Parse(body.RequestID)
+	if err != nil {
+		return response.Error(c, "Invalid request_id", 400, nil)
+	}
+
+	req, err := h.Service.Reject(c.Context(), requestID, orgID, userID, body.Reason)
+	if err != nil {
+		statusMap := map[string]int{
+			"Approval request not found":      404,
+			"Approval request is not pending": 409,
+		}
+		if code, ok := statusMap[err.Error()]; ok {
+			return response.Error(c, err.Error(), code, nil)
+		}
+		return response.Error(c, "Internal Server Error", 500, nil)
+	}
+	middleware.Audit(c, h.Audit, auditsvc.Entry{
+		Action:     auditsvc.ActionApprovalRejected,
+		TargetType: "approval_request",
+		TargetID:   req.RequestID.String(),
+		After:      req,
+	})
+	return response.Success(c, "Approval request rejected", req, nil)
+}
@@ -0,0 +1,206 @@
+package approvals
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	approvalsvc "troo-backend/internal/application/approvals"
+	limitsvc "troo-backend/internal/application/limits"
+	tradesvc "troo-backend/internal/application/trading"
+	"troo-backend/internal/application/verification"
+	"troo-backend/internal/domain"
+	tradehandler "troo-backend/internal/interfaces/handlers/trading"
+	"troo-backend/internal/pkg/decimal"
+
+	"github.com/glebarez/sqlite"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type approvalsFixture struct {
+	db        *gorm.DB
+	app       *fiber.App
+	orgID     uuid.UUID
+	target    domain.Org
+	projectID uuid.UUID
+	requester uuid.UUID
+	approver  uuid.UUID
+}
+
+// setupApprovalsTest mounts trading and approvals routes; the acting user id comes from the X-Test-User header.
+func setupApprovalsTest(t *testing.T) *approvalsFixture {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(
+		&domain.Listing{}, &domain.Holding{}, &domain.Org{},
+		&domain.Transaction{}, &domain.RetirementCertificate{},
+		&domain.ApprovalPolicy{}, &domain.ApprovalRequest{}, &domain.OrgVerification{}, &domain.SubAccount{}, &domain.SubAccountHolding{},
+		&domain.TradingLimit{}, &domain.TradingLimitUsage{},
+	))
+	f := &approvalsFixture{db: db, orgID: uuid.New(), projectID: uuid.New(), requester: uuid.New(), approver: uuid.New()}
+	require.NoError(t, db.Create(&domain.Org{OrgID: f.orgID, OrgName: "Seller", OrgCode: "SE-000001", CountryCode: "SG"}).Error)
+	f.target = domain.Org{OrgID: uuid.New(), OrgName: "Target", OrgCode: "TA-000001", CountryCode: "SG"}
+	require.NoError(t, db.Create(&f.target).Error)
+	require.NoError(t, db.Create(&domain.OrgVerification{OrgID: f.orgID, Status: verification.StatusVerified}).Error)
+	require.NoError(t, db.Create(&domain.Holding{OrgID: f.orgID, ProjectID: f.projectID, CreditBalance: decimal.NewFromInt(100)}).Error)
+
+	aps := &approvalsvc.Service{DB: db}
+	th := &tradehandler.Handlers{Service: &tradesvc.Service{DB: db}, Approvals: aps, Limits: &limitsvc.Service{DB: db}}
+	h := &Handlers{Service: aps}
+
+	f.app = fiber.New()
+	f.app.Use(func(c *fiber.Ctx) error {
+		c.Locals("user", map[string]interface{}{
+			"user_id": c.Get("X-Test-User"),
+			"org_id":  f.orgID.String(),
+			"role":    "admin",
+		})
+		return c.Next()
+	})
+	f.app.Post("/transfer-credits", th.TransferCredits)
+	f.app.Post("/retire-credits", th.RetireCredits)
+	f.app.Put("/update-policy", h.UpdatePolicy)
+	f.app.Get("/view-requests", h.ViewRequests)
+	f.app.Post("/approve-request", h.ApproveRequest)
+	f.app.Post("/reject-request", h.RejectRequest)
+	return f
+}
+
+func (f *approvalsFixture) do(t *testing.T, method, path string, user uuid.UUID, body interface{}) (int, map[string]interface{}) {
+	var reader *bytes.Reader
+	if body != nil {
+		b, _ := json.Marshal(body)
+		reader = bytes.NewReader(b)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Test-User", user.String())
+	resp, err := f.app.Test(req)
+	require.NoError(t, err)
+	var result map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&result)
+	return resp.StatusCode, result
+}
+
+func (f *approvalsFixture) balance(t *testing.T, orgID uuid.UUID) string {
+	var hs []domain.Holding
+	require.NoError(t, f.db.Where("org_id = ? AND project_id = ?", orgID, f.projectID).Find(&hs).Error)
+	if len(hs) == 0 {
+		return "none"
+	}
+	return hs[0].CreditBalance.String()
+}
+
+func TestTransferAboveThreshold_HeldUntilSecondAdminApproves(t *testing.T) {
+	f := setupApprovalsTest(t)
+
+	code, _ := f.do(t, "PUT", "/update-policy", f.requester, map[string]interface{}{"transfer_threshold": 50})
+	require.Equal(t, 200, code)
+
+	// Below the threshold: executes directly.
+	code, _ = f.do(t, "POST", "/transfer-credits", f.requester, map[string]interface{}{
+		"to_org_code": f.target.OrgCode, "project_id": f.projectID.String(), "amount": 20,
+	})
+	assert.Equal(t, 200, code)
+	assert.Equal(t, "20", f.balance(t, f.target.OrgID))
+
+	// Above the threshold: held.
+	code, result := f.do(t, "POST", "/transfer-credits", f.requester, map[string]interface{}{
+		"to_org_code": f.target.OrgCode, "project_id": f.projectID.String(), "amount": 50.5,
+	})
+	require.Equal(t, 202, code)
+	data := result["data"].(map[string]interface{})
+	assert.Equal(t, "pending", data["status"])
+	requestID := data["request_id"].(string)
+	assert.Equal(t, "80", f.balance(t, f.orgID))
+
+	code, result = f.do(t, "POST", "/approve-request", f.requester, map[string]interface{}{"request_id": requestID})
+	assert.Equal(t, 403, code)
+	assert.Equal(t, "Requester cannot approve their own request", result["error"].(map[string]interface{})["message"])
+
+	code, result = f.do(t, "POST", "/approve-request", f.approver, map[string]interface{}{"request_id": requestID})
+	require.Equal(t, 200, code)
+	data = result["data"].(map[string]interface{})
+	assert.Equal(t, "approved", data["status"])
+	assert.Equal(t, f.approver.String(), data["decided_by"])
+	assert.Equal(t, "29.5", f.balance(t, f.orgID))
+	assert.Equal(t, "70.5", f.balance(t, f.target.OrgID))
+
+	code, _ = f.do(t, "POST", "/approve-request", f.approver, map[string]interface{}{"request_id": requestID})
+	assert.Equal(t, 409, code)
+}
+
+func TestApproveFailure_KeepsRequestPending(t *testing.T) {
+	f := setupApprovalsTest(t)
+	f.do(t, "PUT", "/update-policy", f.requester, map[string]interface{}{"retire_threshold": 10})
+
+	code, result := f.do(t, "POST", "/retire-credits", f.requester, map[string]interface{}{
+		"project_id": f.projectID.String(), "amount": 80,
+	})
+	require.Equal(t, 202, code)
+	requestID := result["data"].(map[string]interface{})["request_id"].(string)
+
+	// Credits leave by another route before approval.
+	require.NoError(t, f.db.Model(&domain.Holding{}).Where("org_id = ?", f.orgID).Update("credit_balance", decimal.NewFromInt(30)).Error)
+
+	code, _ = f.do(t, "POST", "/approve-request", f.approver, map[string]interface{}{"request_id": requestID})
+	assert.Equal(t, 400, code)
+
+	code, result = f.do(t, "GET", "/view-requests?status=pending", f.approver, nil)
+	require.Equal(t, 200, code)
+	assert.Len(t, result["data"], 1)
+	var certs int64
+	f.db.Model(&domain.RetirementCertificate{}).Count(&certs)
+	assert.Equal(t, int64(0), certs)
+}
+
+func TestRejectRequest(t *testing.T) {
+	f := setupApprovalsTest(t)
+	f.do(t, "PUT", "/update-policy", f.requester, map[string]interface{}{"all_transfers": true})
+
+	code, result := f.do(t, "POST", "/transfer-credits", f.requester, map[string]interface{}{
+		"to_org_code": f.target.OrgCode, "project_id": f.projectID.String(), "amount": 1,
+	})
+	require.Equal(t, 202, code)
+	requestID := result["data"].(map[string]interface{})["request_id"].(string)
+
+	code, result = f.do(t, "POST", "/reject-request", f.approver, map[string]interface{}{"request_id": requestID, "reason": "Not budgeted"})
+	require.Equal(t, 200, code)
+	assert.Equal(t, "rejected", result["data"].(map[string]interface{})["status"])
+
+	code, _ = f.do(t, "POST", "/approve-request", f.approver, map[string]interface{}{"request_id": requestID})
+	assert.Equal(t, 409, code)
+	assert.Equal(t, "100", f.balance(t, f.orgID))
+	assert.Equal(t, "none", f.balance(t, f.target.OrgID))
+}
+
+func TestRejectRequest_ReleasesTradingLimitReservation(t *testing.T) {
+	f := setupApprovalsTest(t)
+	f.do(t, "PUT", "/update-policy", f.requester, map[string]interface{}{"all_transfers": true})
+	role := "admin"
+	volume := decimal.NewFromInt(10)
+	require.NoError(t, f.db.Create(&domain.TradingLimit{OrgID: f.orgID, Role: &role, MaxDailyTransferVolume: &volume}).Error)
+
+	transfer := func() (int, map[string]interface{}) {
+		return f.do(t, "POST", "/transfer-credits", f.requester, map[string]interface{}{
+			"to_org_code": f.target.OrgCode, "project_id": f.projectID.String(), "amount": 10,
+		})
+	}
+	code, result := transfer()
+	require.Equal(t, 202, code)
+	requestID := result["data"].(map[string]interface{})["request_id"].(string)
+	code, _ = transfer()
+	assert.Equal(t, 403, code, "the held transfer counts towards the daily volume")
+
+	code, _ = f.do(t, "POST", "/reject-request", f.approver, map[string]interface{}{"request_id": requestID})
+	require.Equal(t, 200, code)
+	code, _ = transfer()
+	assert.Equal(t, 202, code, "rejection gives the volume back")
+}
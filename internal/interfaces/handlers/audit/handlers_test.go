@@ -0,0 +1,194 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	auditsvc "troo-backend/internal/application/audit"
+	usersvc "troo-backend/internal/application/user"
+	"troo-backend/internal/domain"
+	userhandler "troo-backend/internal/interfaces/handlers/user"
+	"troo-backend/internal/middleware"
+	"troo-backend/internal/pkg/constants"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/glebarez/sqlite"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type auditFixture struct {
+	db    *gorm.DB
+	svc   *auditsvc.Service
+	app   *fiber.App
+	orgID uuid.UUID
+}
+
+func setupAuditTest(t *testing.T) *auditFixture {
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() {
+		rdb.Close()
+		mr.Close()
+	})
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&domain.AuditLog{}, &domain.User{}, &domain.OrgMembership{}, &domain.CustomRole{}))
+
+	f := &auditFixture{db: db, svc: &auditsvc.Service{DB: db}, orgID: uuid.New()}
+	h := &Handlers{Service: f.svc}
+	uh := &userhandler.Handlers{Service: &usersvc.Service{DB: db, Rdb: rdb}, Audit: f.svc}
+
+	f.app = fiber.New()
+	f.app.Use(middleware.Tracing())
+	f.app.Use(func(c *fiber.Ctx) error {
+		var u domain.User
+		if err := db.Where("user_id = ?", c.Get("X-Test-User")).First(&u).Error; err != nil {
+			return c.Next()
+		}
+		c.Locals("user", map[string]interface{}{"user_id": u.UserID.String(), "role": u.Role, "org_id": u.OrgID.String()})
+		return c.Next()
+	})
+	f.app.Get("/view-logs", middleware.AuthorizePermission(constants.ViewAuditLog), h.ViewLogs)
+	f.app.Get("/export-logs", middleware.AuthorizePermission(constants.ViewAuditLog), h.ExportLogs)
+	f.app.Patch("/update-role", middleware.AuthorizePermission(constants.AssignRole), uh.UpdateRole)
+	return f
+}
+
+func (f *auditFixture) user(t *testing.T, role string) uuid.UUID {
+	id := uuid.New()
+	require.NoError(t, f.db.Create(&domain.User{
+		UserID: id, UserName: id.String(), Email: id.String() + "@example.com", PasswordHash: "x", Fullname: "Test User", Role: role, OrgID: &f.orgID,
+	}).Error)
+	return id
+}
+
+func (f *auditFixture) do(t *testing.T, method, path string, user uuid.UUID, body interface{}) fiber.Map {
+	t.Helper()
+	b, _ := json.Marshal(body)
+	req := httptest.NewRequest(method, path, bytes.NewReader(b))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Test-User", user.String())
+	resp, err := f.app.Test(req)
+	require.NoError(t, err)
+	var result fiber.Map
+	json.NewDecoder(resp.Body).Decode(&result)
+	result["_status"] = resp.StatusCode
+	result["_trace"] = resp.Header.Get("X-Trace-Id")
+	return result
+}
+
+func TestAuditLog_RoleChangeRecordedWithBeforeAfter(t *testing.T) {
+	f := setupAuditTest(t)
+	admin := f.user(t, constants.Admin)
+	target := f.user(t, constants.Viewer)
+
+	res := f.do(t, "PATCH", "/update-role", admin, map[string]string{"user_id": target.String(), "role": constants.Manager})
+	require.Equal(t, 200, res["_status"], res)
+
+	var entry domain.AuditLog
+	require.NoError(t, f.db.Where("action = ?", auditsvc.ActionRoleChanged).First(&entry).Error)
+	assert.Equal(t, admin, *entry.ActorUserID)
+	assert.Equal(t, f.orgID, *entry.OrgID)
+	assert.Equal(t, target.String(), entry.TargetID)
+	assert.JSONEq(t, `{"role":"viewer","custom_role_id":null}`, string(entry.Before))
+	assert.JSONEq(t, `{"role":"manager","custom_role_id":null}`, string(entry.After))
+	assert.Equal(t, res["_trace"], entry.TraceID)
+	assert.NotEmpty(t, entry.IP)
+
+	// Entries cannot be changed or removed through the model.
+	assert.ErrorIs(t, f.db.Model(&entry).Update("action", "x").Error, domain.ErrAuditLogImmutable)
+	assert.ErrorIs(t, f.db.Delete(&entry).Error, domain.ErrAuditLogImmutable)
+}
+
+func TestAuditLog_ViewFiltersAndScopesToOrg(t *testing.T) {
+	f := setupAuditTest(t)
+	admin := f.user(t, constants.Admin)
+	manager := f.user(t, constants.Manager)
+	other := uuid.New()
+	ctx := context.Background()
+	require.NoError(t, f.svc.Record(ctx, auditsvc.Entry{ActorID: &admin, OrgID: &f.orgID, Action: auditsvc.ActionLogin}))
+	require.NoError(t, f.svc.Record(ctx, auditsvc.Entry{ActorID: &manager, OrgID: &f.orgID, Action: auditsvc.ActionLogin}))
+	require.NoError(t, f.svc.Record(ctx, auditsvc.Entry{ActorID: &manager, OrgID: &f.orgID, Action: auditsvc.ActionCreditsRetired, TargetType: "retirement_certificate", TargetID: "c-1"}))
+	require.NoError(t, f.svc.Record(ctx, auditsvc.Entry{OrgID: &other, Action: auditsvc.ActionLogin}))
+
+	res := f.do(t, "GET", "/view-logs", manager, nil)
+	assert.Equal(t, 403, res["_status"])
+
+	res = f.do(t, "GET", "/view-logs", admin, nil)
+	require.Equal(t, 200, res["_status"])
+	assert.Len(t, res["data"], 3)
+	assert.Equal(t, float64(3), res["metadata"].(map[string]interface{})["total"])
+
+	res = f.do(t, "GET", "/view-logs?action=auth.login&actor_id="+manager.String(), admin, nil)
+	require.Equal(t, 200, res["_status"])
+	assert.Len(t, res["data"], 1)
+
+	res = f.do(t, "GET", "/view-logs?limit=2", admin, nil)
+	assert.Len(t, res["data"], 2)
+	res = f.do(t, "GET", "/view-logs?from=2999-01-01", admin, nil)
+	assert.Len(t, res["data"], 0)
+	res = f.do(t, "GET", "/view-logs?from=yesterday", admin, nil)
+	assert.Equal(t, 400, res["_status"])
+}
+
+func TestAuditLog_ExportCSV(t *testing.T) {
+	f := setupAuditTest(t)
+	admin := f.user(t, constants.Admin)
+	require.NoError(t, f.svc.Record(context.Background(), auditsvc.Entry{
+		ActorID: &admin, OrgID: &f.orgID, Action: auditsvc.ActionOrgUpdated, TargetType: "org", TargetID: f.orgID.String(),
+		Before: map[string]string{"org_name": "Old, Name"}, After: map[string]string{"org_name": "New"},
+	}))
+
+	req := httptest.NewRequest("GET", "/export-logs?action=org.updated", nil)
+	req.Header.Set("X-Test-User", admin.String())
+	resp, err := f.app.Test(req)
+	require.NoError(t, err)
+	require.Equal(t, 200, resp.StatusCode)
+	assert.Contains(t, resp.Header.Get("Content-Type"), "text/csv")
+	assert.Contains(t, resp.Header.Get("Content-Disposition"), "attachment")
+
+	body, _ := io.ReadAll(resp.Body)
+	rows, err := csv.NewReader(bytes.NewReader(body)).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	assert.Equal(t, "action", rows[0][1])
+	assert.Equal(t, "org.updated", rows[1][1])
+	assert.Equal(t, admin.String(), rows[1][2])
+	assert.JSONEq(t, `{"org_name":"Old, Name"}`, rows[1][6])
+}
+
+func TestAuditLog_ExportCSV_EscapesFormulas(t *testing.T) {
+	f := setupAuditTest(t)
+	admin := f.user(t, constants.Admin)
+	for _, target := range []string{"=HYPERLINK(\"http://evil\")", "+1", "-1", "@SUM(A1)", "plain"} {
+		require.NoError(t, f.svc.Record(context.Background(), auditsvc.Entry{
+			ActorID: &admin, OrgID: &f.orgID, Action: auditsvc.ActionOrgUpdated, TargetType: "org", TargetID: target,
+		}))
+	}
+
+	req := httptest.NewRequest("GET", "/export-logs", nil)
+	req.Header.Set("X-Test-User", admin.String())
+	resp, err := f.app.Test(req)
+	require.NoError(t, err)
+	require.Equal(t, 200, resp.StatusCode)
+	body, _ := io.ReadAll(resp.Body)
+	rows, err := csv.NewReader(bytes.NewReader(body)).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, rows, 6)
+	var targets []string
+	for _, r := range rows[1:] {
+		targets = append(targets, r[5])
+	}
+	assert.ElementsMatch(t, []string{"'=HYPERLINK(\"http://evil\")", "'+1", "'-1", "'@SUM(A1)", "plain"}, targets)
+}
@@ -2,23 +2,42 @@ package auth
 
 import (
 	"context"
+	"math"
+	"strconv"
+	"time"
 
+	auditsvc "troo-backend/internal/application/audit"
 	authsvc "troo-backend/internal/application/auth"
+	ssosvc "troo-backend/internal/application/sso"
+	"troo-backend/internal/domain"
 	"troo-backend/internal/middleware"
+	"troo-backend/internal/pkg/constants"
 	"troo-backend/internal/pkg/response"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
 )
 
-const userSessionsPrefix = "user_sessions:"
+const (
+	// pendingTwoFactorTTL and maxTwoFactorAttempts bound the second step of a two-step login.
+	pendingTwoFactorTTL  = 5 * time.Minute
+	maxTwoFactorAttempts = 5
+	// ssoCookieName ties an SSO sign-in to the browser that started it; it lives as long as the sign-in state.
+	ssoCookieName = "troo.sso"
+	ssoCookieTTL  = 10 * time.Minute
+)
 
 // Handlers holds dependencies for auth endpoints.
 type Handlers struct {
 	UserFinder authsvc.UserFinder
+	Service    *authsvc.Service
 	Rdb        *redis.Client
 	Config     middleware.SessionConfig
+	Audit      *auditsvc.Service
+	// SSO is nil when single sign-on is not wired (no database or Redis); its endpoints then return 500.
+	SSO *ssosvc.Service
 }
 
 // LoginRequest body (Express: req.body email, password).
@@ -28,6 +47,7 @@ type LoginRequest struct {
 }
 
 // Login POST /api/v1/auth/login — authenticate, create session, SAdd user_sessions:user_id, set cookie, return success.
+// Users with 2FA enabled get a pending session instead (data.two_factor_required) and finish at /verify-2fa.
 func (h *Handlers) Login(c *fiber.Ctx) error {
 	if h.UserFinder == nil {
 		return response.Error(c, "Internal Server Error", fiber.StatusInternalServerError, nil)
@@ -40,61 +60,185 @@ func (h *Handlers) Login(c *fiber.Ctx) error {
 		return response.Error(c, "Email and password are required", fiber.StatusBadRequest, nil)
 	}
 
+	// Checked before the password so a locked account cannot be probed, even with the right password.
+	throttle := authsvc.LoginThrottle{Rdb: h.Rdb}
+	wait, err := throttle.Wait(c.Context(), c.IP(), req.Email)
+	if err != nil {
+		return response.Error(c, "Internal Server Error", fiber.StatusInternalServerError, nil)
+	}
+	if wait > 0 {
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		return response.Error(c, authsvc.ErrTooManyLoginAttempts.Error(), fiber.StatusTooManyRequests, nil)
+	}
+
 	user, err := h.UserFinder.FindByEmailAndPassword(req.Email, req.Password)
 	if err != nil {
 		switch err {
 		case authsvc.ErrEmailPasswordRequired:
 			return response.Error(c, err.Error(), fiber.StatusBadRequest, nil)
 		case authsvc.ErrInvalidEmail, authsvc.ErrIncorrectPassword:
-			return response.Error(c, err.Error(), fiber.StatusUnauthorized, nil)
+			// The audit log keeps the real reason; the client only learns the credentials were wrong.
+			middleware.Audit(c, h.Audit, auditsvc.Entry{
+				Action:     auditsvc.ActionLoginFailed,
+				TargetType: "user",
+				After:      fiber.Map{"email": req.Email, "reason": err.Error()},
+			})
+			locked, _ := throttle.Failed(c.Context(), c.IP(), req.Email)
+			if locked {
+				h.accountLocked(c, req.Email)
+			}
+			return response.Error(c, authsvc.ErrInvalidCredentials.Error(), fiber.StatusUnauthorized, nil)
 		default:
 			return response.Error(c, "Internal Server Error", fiber.StatusInternalServerError, nil)
 		}
 	}
+	// The failure count is cleared by finishLogin or, with 2FA, only once the second factor passes.
+	return h.finishLogin(c, user, "")
+}
 
-	// Regenerate session ID (new session for this login)
-	sessionID := middleware.RegenerateSessionID(c)
-	orgIDStr := nilString(user.OrgID)
-
-	middleware.SetSessionUser(c, middleware.SessionUser{
-		UserID:   user.UserID.String(),
-		Fullname: user.Fullname,
-		Email:    user.Email,
-		Role:     user.Role,
-		OrgID:    orgIDStr,
+// finishLogin signs in a user whose first factor (password or SSO) has been checked: users with 2FA
+// enabled get a pending session, everyone else a full one that also clears the account's failed logins.
+// ssoOrgID is the org an SSO sign-in came through, which the session is then bound to; "" for a password one.
+func (h *Handlers) finishLogin(c *fiber.Ctx, user *domain.User, ssoOrgID string) error {
+	twoFactor, orgRequires := false, false
+	if h.Service != nil {
+		var err error
+		twoFactor, orgRequires, err = h.Service.TwoFactorStatus(c.Context(), user)
+		if err != nil {
+			return response.Error(c, "Internal Server Error", fiber.StatusInternalServerError, nil)
+		}
+	}
+	if twoFactor {
+		// First factor accepted; the session stays anonymous until /verify-2fa.
+		sessionID := middleware.RegenerateSessionID(c)
+		middleware.SetPendingTwoFactor(c, user.UserID.String(), ssoOrgID, time.Now().Add(pendingTwoFactorTTL))
+		h.setSessionCookie(c, sessionID)
+		return response.Success(c, "Two-factor code required", fiber.Map{"two_factor_required": true}, nil)
+	}
+
+	sessionUser, err := h.startSession(c, user, false, orgRequires, ssoOrgID)
+	if err != nil {
+		return response.Error(c, "Internal Server Error", fiber.StatusInternalServerError, nil)
+	}
+	authsvc.LoginThrottle{Rdb: h.Rdb}.Succeeded(c.Context(), user.Email)
+	var detail interface{}
+	if ssoOrgID != "" {
+		detail = fiber.Map{"method": middleware.AuthMethodSSO}
+	}
+	middleware.Audit(c, h.Audit, auditsvc.Entry{
+		Action:     auditsvc.ActionLogin,
+		TargetType: "user",
+		TargetID:   user.UserID.String(),
+		After:      detail,
 	})
+	return loginResponse(c, user, sessionUser)
+}
+
+// accountLocked emails the account owner, if the email belongs to one, and records the lockout.
+func (h *Handlers) accountLocked(c *fiber.Ctx, email string) {
+	entry := auditsvc.Entry{
+		Action:     auditsvc.ActionAccountLocked,
+		TargetType: "user",
+		After:      fiber.Map{"email": email},
+	}
+	if h.Service != nil {
+		user, err := h.Service.NotifyAccountLocked(c.Context(), email)
+		if err != nil {
+			log.Error().Err(err).Msg("account lockout notification failed")
+		}
+		if user != nil {
+			entry.ActorID = &user.UserID
+			entry.OrgID = user.OrgID
+			entry.TargetID = user.UserID.String()
+		}
+	}
+	middleware.Audit(c, h.Audit, entry)
+}
+
+// startSession regenerates the session ID, stores the user in it, tracks it in user_sessions and sets the cookie.
+// A non-empty ssoOrgID binds the session to that org (see finishLogin).
+func (h *Handlers) startSession(c *fiber.Ctx, user *domain.User, twoFactor, orgRequires bool, ssoOrgID string) (middleware.SessionUser, error) {
+	// Regenerate session ID (new session for this login); starting from empty data also restarts the
+	// absolute lifetime, as Express's session.regenerate does
+	middleware.DestroySession(c)
+	sessionID := middleware.RegenerateSessionID(c)
+
+	sessionUser := middleware.SessionUser{
+		UserID:            user.UserID.String(),
+		Fullname:          user.Fullname,
+		Email:             user.Email,
+		Role:              user.Role,
+		OrgID:             nilString(user.OrgID),
+		EmailVerified:     user.EmailVerified,
+		TwoFactor:         twoFactor,
+		TwoFactorRequired: orgRequires,
+		AuthMethod:        middleware.AuthMethodPassword,
+	}
+	if ssoOrgID != "" {
+		sessionUser.AuthMethod = middleware.AuthMethodSSO
+		sessionUser.SSOOrgID = &ssoOrgID
+	}
+	if user.CustomRole != nil {
+		roleID := user.CustomRole.RoleID.String()
+		sessionUser.CustomRoleID = &roleID
+		sessionUser.Permissions = user.CustomRole.PermissionList()
+	}
+	if user.PlatformOperator != nil {
+		sessionUser.PlatformRole = &user.PlatformOperator.Role
+	}
+	middleware.SetSessionUser(c, sessionUser)
 
 	// Track session in Redis (Express: redisClient.sAdd(`user_sessions:${user.user_id}`, req.sessionID))
 	ctx := context.Background()
-	if err := h.Rdb.SAdd(ctx, userSessionsPrefix+user.UserID.String(), sessionID).Err(); err != nil {
-		return response.Error(c, "Internal Server Error", fiber.StatusInternalServerError, nil)
+	if err := h.Rdb.SAdd(ctx, constants.UserSessionsPrefix+user.UserID.String(), sessionID).Err(); err != nil {
+		return sessionUser, err
 	}
+	h.setSessionCookie(c, sessionID)
+	return sessionUser, nil
+}
 
-	// Set cookie so client gets troo.sid = "s:"+sessionID (Express: no domain when setting)
+// setSessionCookie sets the signed troo.sid cookie (Express: no domain when setting).
+func (h *Handlers) setSessionCookie(c *fiber.Ctx, sessionID string) {
 	cookie := middleware.SessionCookieConfig(h.Config)
-	cookie.Value = "s:" + sessionID
+	cookie.Value = middleware.SessionCookieValue(h.Config, sessionID)
 	c.Cookie(&cookie)
+}
 
-	// Response: standard success with user object (no password)
+// loginResponse: standard success with user object (no password).
+func loginResponse(c *fiber.Ctx, user *domain.User, sessionUser middleware.SessionUser) error {
 	return response.Success(c, "Login successful", fiber.Map{
 		"user": fiber.Map{
-			"user_id":  user.UserID.String(),
-			"fullname": user.Fullname,
-			"email":    user.Email,
-			"role":     user.Role,
-			"org_id":   orgIDStr,
+			"user_id":        user.UserID.String(),
+			"fullname":       user.Fullname,
+			"email":          user.Email,
+			"email_verified": user.EmailVerified,
+			"role":           user.Role,
+			"org_id":         sessionUser.OrgID,
+			"custom_role_id": sessionUser.CustomRoleID,
+			"permissions":    sessionUser.Permissions,
+			"platform_role":  sessionUser.PlatformRole,
+			"two_factor":     sessionUser.TwoFactor,
 		},
 	}, nil)
 }
 
-// Me GET /api/v1/auth/me — return current session user in standard success format.
+// Me GET /api/v1/auth/me — return current session user in standard success format, with when the session
+// expires (session.idle_expires_at if unused, session.expires_at at the latest).
 func (h *Handlers) Me(c *fiber.Ctx) error {
 	sessionUser := middleware.GetUser(c)
 	user, err := authsvc.VerifyUser(sessionUser)
 	if err != nil {
 		return response.Error(c, "Not authenticated", fiber.StatusUnauthorized, nil)
 	}
-	return response.Success(c, "Authenticated", fiber.Map{"user": user}, nil)
+	data := fiber.Map{"user": user}
+	if idleAt, expiresAt, ok := middleware.SessionExpiry(c, h.Config); ok {
+		data["session"] = fiber.Map{
+			"idle_expires_at":   idleAt.UTC().Format(time.RFC3339),
+			"expires_at":        expiresAt.UTC().Format(time.RFC3339),
+			"remaining_seconds": int(time.Until(idleAt).Seconds()),
+		}
+	}
+	return response.Success(c, "Authenticated", data, nil)
 }
 
 // Logout DELETE /api/v1/auth/logout — SRem user_sessions:user_id, Del session key, clear cookie, return success.
@@ -107,7 +251,7 @@ func (h *Handlers) Logout(c *fiber.Ctx) error {
 	if sessionUser != nil && sessionID != "" {
 		if m, ok := sessionUser.(map[string]interface{}); ok {
 			if userID, _ := m["user_id"].(string); userID != "" {
-				_ = h.Rdb.SRem(ctx, userSessionsPrefix+userID, sessionID).Err()
+				_ = h.Rdb.SRem(ctx, constants.UserSessionsPrefix+userID, sessionID).Err()
 			}
 		}
 	}
@@ -122,7 +266,6 @@ func (h *Handlers) Logout(c *fiber.Ctx) error {
 	// Clear cookie (same options as Express)
 	cookie := middleware.SessionCookieConfig(h.Config)
 	cookie.Value = ""
-	cookie.Expires = cookie.Expires
 	cookie.MaxAge = -1
 	if h.Config.IsProduction && !h.Config.AllowCrossSiteDev {
 		cookie.Domain = ".troo.earth"
@@ -132,6 +275,429 @@ func (h *Handlers) Logout(c *fiber.Ctx) error {
 	return response.Success(c, "Logged out successfully", nil, nil)
 }
 
+// ForgotPassword POST /api/v1/auth/forgot-password — body: email. Always answers the same way so accounts cannot be discovered.
+func (h *Handlers) ForgotPassword(c *fiber.Ctx) error {
+	if h.Service == nil {
+		return response.Error(c, "Internal Server Error", fiber.StatusInternalServerError, nil)
+	}
+	var body struct {
+		Email string `json:"email"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return response.Error(c, "Invalid request body", fiber.StatusBadRequest, nil)
+	}
+	if h.Rdb != nil && body.Email != "" {
+		wait, err := authsvc.ResetThrottle{Rdb: h.Rdb}.Allow(c.Context(), c.IP(), body.Email)
+		if err != nil {
+			return response.Error(c, "Internal Server Error", fiber.StatusInternalServerError, nil)
+		}
+		if wait > 0 {
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			return response.Error(c, authsvc.ErrTooManyResetRequests.Error(), fiber.StatusTooManyRequests, nil)
+		}
+	}
+	user, err := h.Service.RequestPasswordReset(c.Context(), body.Email)
+	if err != nil {
+		if err == authsvc.ErrEmailRequired {
+			return response.Error(c, err.Error(), fiber.StatusBadRequest, nil)
+		}
+		return response.Error(c, "Internal Server Error", fiber.StatusInternalServerError, nil)
+	}
+	if user != nil {
+		middleware.Audit(c, h.Audit, auditsvc.Entry{
+			ActorID:    &user.UserID,
+			OrgID:      user.OrgID,
+			Action:     auditsvc.ActionResetRequested,
+			TargetType: "user",
+			TargetID:   user.UserID.String(),
+		})
+	}
+	return response.Success(c, "If an account exists for this email, a password reset link has been sent", nil, nil)
+}
+
+// ResetPassword POST /api/v1/auth/reset-password — body: token, password. Consumes the token and logs the user out everywhere.
+func (h *Handlers) ResetPassword(c *fiber.Ctx) error {
+	if h.Service == nil {
+		return response.Error(c, "Internal Server Error", fiber.StatusInternalServerError, nil)
+	}
+	var body struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return response.Error(c, "Invalid request body", fiber.StatusBadRequest, nil)
+	}
+	user, err := h.Service.ResetPassword(c.Context(), body.Token, body.Password)
+	if err != nil {
+		switch err {
+		case authsvc.ErrResetTokenRequired, authsvc.ErrInvalidPasswordFormat, authsvc.ErrInvalidResetToken:
+			return response.Error(c, err.Error(), fiber.StatusBadRequest, nil)
+		default:
+			return response.Error(c, "Internal Server Error", fiber.StatusInternalServerError, nil)
+		}
+	}
+	middleware.Audit(c, h.Audit, auditsvc.Entry{
+		ActorID:    &user.UserID,
+		OrgID:      user.OrgID,
+		Action:     auditsvc.ActionPasswordReset,
+		TargetType: "user",
+		TargetID:   user.UserID.String(),
+	})
+	return response.Success(c, "Password reset successfully", nil, nil)
+}
+
+type twoFactorBody struct {
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+// VerifyTwoFactor POST /api/v1/auth/verify-2fa — body: code or recovery_code. Completes a login that Login left
+// pending; after maxTwoFactorAttempts wrong codes the pending login is dropped and the password must be re-entered.
+// Wrong codes also count as failed logins for the account, so its lockout covers guessing the second factor
+// across many pending logins.
+func (h *Handlers) VerifyTwoFactor(c *fiber.Ctx) error {
+	if h.Service == nil {
+		return response.Error(c, "Internal Server Error", fiber.StatusInternalServerError, nil)
+	}
+	userID, ssoOrgID, attempts, ok := middleware.GetPendingTwoFactor(c)
+	if !ok || attempts >= maxTwoFactorAttempts {
+		return response.Error(c, "No pending two-factor login", fiber.StatusUnauthorized, nil)
+	}
+	var body twoFactorBody
+	if err := c.BodyParser(&body); err != nil {
+		return response.Error(c, "Invalid request body", fiber.StatusBadRequest, nil)
+	}
+	ctx := c.Context()
+	user, err := h.Service.FindSessionUser(ctx, userID, ssoOrgID)
+	if err != nil {
+		return response.Error(c, "Not authenticated", fiber.StatusUnauthorized, nil)
+	}
+	throttle := authsvc.LoginThrottle{Rdb: h.Rdb}
+	wait, err := throttle.Wait(ctx, c.IP(), user.Email)
+	if err != nil {
+		return response.Error(c, "Internal Server Error", fiber.StatusInternalServerError, nil)
+	}
+	if wait > 0 {
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		return response.Error(c, authsvc.ErrTooManyLoginAttempts.Error(), fiber.StatusTooManyRequests, nil)
+	}
+	if err := h.Service.VerifySecondFactor(ctx, userID, body.Code, body.RecoveryCode); err != nil {
+		switch err {
+		case authsvc.ErrTwoFactorCodeRequired:
+			return response.Error(c, err.Error(), fiber.StatusBadRequest, nil)
+		case authsvc.ErrInvalidTwoFactorCode, authsvc.ErrTwoFactorNotEnabled:
+			middleware.RecordPendingTwoFactorFailure(c)
+			middleware.Audit(c, h.Audit, auditsvc.Entry{
+				ActorID:    &user.UserID,
+				Action:     auditsvc.ActionLoginFailed,
+				TargetType: "user",
+				TargetID:   userID,
+				After:      fiber.Map{"reason": authsvc.ErrInvalidTwoFactorCode.Error()},
+			})
+			if locked, _ := throttle.Failed(ctx, c.IP(), user.Email); locked {
+				h.accountLocked(c, user.Email)
+			}
+			return response.Error(c, authsvc.ErrInvalidTwoFactorCode.Error(), fiber.StatusUnauthorized, nil)
+		default:
+			return response.Error(c, "Internal Server Error", fiber.StatusInternalServerError, nil)
+		}
+	}
+	throttle.Succeeded(ctx, user.Email)
+
+	_, orgRequires, err := h.Service.TwoFactorStatus(ctx, user)
+	if err != nil {
+		return response.Error(c, "Internal Server Error", fiber.StatusInternalServerError, nil)
+	}
+	if old := middleware.GetSessionID(c); old != "" {
+		_ = h.Rdb.Del(context.Background(), middleware.SessionRedisPrefix+old).Err()
+	}
+	middleware.DestroySession(c)
+	sessionUser, err := h.startSession(c, user, true, orgRequires, ssoOrgID)
+	if err != nil {
+		return response.Error(c, "Internal Server Error", fiber.StatusInternalServerError, nil)
+	}
+	middleware.Audit(c, h.Audit, auditsvc.Entry{
+		Action:     auditsvc.ActionLogin,
+		TargetType: "user",
+		TargetID:   user.UserID.String(),
+		After:      fiber.Map{"two_factor": true},
+	})
+	return loginResponse(c, user, sessionUser)
+}
+
+// SetupTwoFactor POST /api/v1/auth/setup-2fa — new TOTP secret and otpauth URI for the signed-in user.
+func (h *Handlers) SetupTwoFactor(c *fiber.Ctx) error {
+	if h.Service == nil {
+		return response.Error(c, "Internal Server Error", fiber.StatusInternalServerError, nil)
+	}
+	user, err := authsvc.VerifyUser(middleware.GetUser(c))
+	if err != nil {
+		return response.Error(c, "Not authenticated", fiber.StatusUnauthorized, nil)
+	}
+	setup, err := h.Service.SetupTwoFactor(c.Context(), user.UserID, user.Email)
+	if err != nil {
+		return h.twoFactorError(c, err)
+	}
+	return response.Success(c, "Scan the QR code with your authenticator app, then confirm a code", setup, nil)
+}
+
+// EnableTwoFactor POST /api/v1/auth/enable-2fa — body: code. Turns 2FA on and returns the recovery codes once.
+func (h *Handlers) EnableTwoFactor(c *fiber.Ctx) error {
+	if h.Service == nil {
+		return response.Error(c, "Internal Server Error", fiber.StatusInternalServerError, nil)
+	}
+	user, err := authsvc.VerifyUser(middleware.GetUser(c))
+	if err != nil {
+		return response.Error(c, "Not authenticated", fiber.StatusUnauthorized, nil)
+	}
+	var body twoFactorBody
+	if err := c.BodyParser(&body); err != nil {
+		return response.Error(c, "Invalid request body", fiber.StatusBadRequest, nil)
+	}
+	codes, err := h.Service.EnableTwoFactor(c.Context(), user.UserID, body.Code)
+	if err != nil {
+		return h.twoFactorError(c, err)
+	}
+	// The code just proved the second factor, so this session counts as two-factor.
+	if m, ok := middleware.GetUser(c).(map[string]interface{}); ok {
+		m["two_factor"] = true
+	}
+	middleware.Audit(c, h.Audit, auditsvc.Entry{
+		Action:     auditsvc.ActionTwoFactorEnabled,
+		TargetType: "user",
+		TargetID:   user.UserID,
+	})
+	return response.Success(c, "Two-factor authentication enabled", fiber.Map{"recovery_codes": codes}, nil)
+}
+
+// DisableTwoFactor POST /api/v1/auth/disable-2fa — body: code or recovery_code. Refused while the user's org requires 2FA.
+func (h *Handlers) DisableTwoFactor(c *fiber.Ctx) error {
+	if h.Service == nil {
+		return response.Error(c, "Internal Server Error", fiber.StatusInternalServerError, nil)
+	}
+	user, err := authsvc.VerifyUser(middleware.GetUser(c))
+	if err != nil {
+		return response.Error(c, "Not authenticated", fiber.StatusUnauthorized, nil)
+	}
+	if user.TwoFactorRequired {
+		return response.Error(c, "Your organization requires two-factor authentication", fiber.StatusForbidden, nil)
+	}
+	var body twoFactorBody
+	if err := c.BodyParser(&body); err != nil {
+		return response.Error(c, "Invalid request body", fiber.StatusBadRequest, nil)
+	}
+	if err := h.Service.DisableTwoFactor(c.Context(), user.UserID, body.Code, body.RecoveryCode); err != nil {
+		return h.twoFactorError(c, err)
+	}
+	if m, ok := middleware.GetUser(c).(map[string]interface{}); ok {
+		delete(m, "two_factor")
+	}
+	middleware.Audit(c, h.Audit, auditsvc.Entry{
+		Action:     auditsvc.ActionTwoFactorDisabled,
+		TargetType: "user",
+		TargetID:   user.UserID,
+	})
+	return response.Success(c, "Two-factor authentication disabled", nil, nil)
+}
+
+// RegenerateRecoveryCodes POST /api/v1/auth/regenerate-recovery-codes — body: code. Old recovery codes stop working.
+func (h *Handlers) RegenerateRecoveryCodes(c *fiber.Ctx) error {
+	if h.Service == nil {
+		return response.Error(c, "Internal Server Error", fiber.StatusInternalServerError, nil)
+	}
+	user, err := authsvc.VerifyUser(middleware.GetUser(c))
+	if err != nil {
+		return response.Error(c, "Not authenticated", fiber.StatusUnauthorized, nil)
+	}
+	var body twoFactorBody
+	if err := c.BodyParser(&body); err != nil {
+		return response.Error(c, "Invalid request body", fiber.StatusBadRequest, nil)
+	}
+	codes, err := h.Service.RegenerateRecoveryCodes(c.Context(), user.UserID, body.Code)
+	if err != nil {
+		return h.twoFactorError(c, err)
+	}
+	return response.Success(c, "Recovery codes regenerated", fiber.Map{"recovery_codes": codes}, nil)
+}
+
+// ListSessions GET /api/v1/auth/sessions — the signed-in user's active sessions, current one flagged.
+func (h *Handlers) ListSessions(c *fiber.Ctx) error {
+	if h.Service == nil {
+		return response.Error(c, "Internal Server Error", fiber.StatusInternalServerError, nil)
+	}
+	user, err := authsvc.VerifyUser(middleware.GetUser(c))
+	if err != nil {
+		return response.Error(c, "Not authenticated", fiber.StatusUnauthorized, nil)
+	}
+	sessions, err := h.Service.ListSessions(c.Context(), user.UserID, middleware.GetSessionID(c))
+	if err != nil {
+		return response.Error(c, "Internal Server Error", fiber.StatusInternalServerError, nil)
+	}
+	return response.Success(c, "Sessions fetched successfully", fiber.Map{"sessions": sessions}, nil)
+}
+
+// RevokeSession DELETE /api/v1/auth/revoke-session — body: session_id (from /sessions). Signs that device out.
+func (h *Handlers) RevokeSession(c *fiber.Ctx) error {
+	if h.Service == nil {
+		return response.Error(c, "Internal Server Error", fiber.StatusInternalServerError, nil)
+	}
+	user, err := authsvc.VerifyUser(middleware.GetUser(c))
+	if err != nil {
+		return response.Error(c, "Not authenticated", fiber.StatusUnauthorized, nil)
+	}
+	var body struct {
+		SessionID string `json:"session_id"`
+	}
+	if err := c.BodyParser(&body); err != nil || body.SessionID == "" {
+		return response.Error(c, "session_id is required", fiber.StatusBadRequest, nil)
+	}
+	if err := h.Service.RevokeSession(c.Context(), user.UserID, body.SessionID, middleware.GetSessionID(c)); err != nil {
+		switch err {
+		case authsvc.ErrCannotRevokeCurrent:
+			return response.Error(c, err.Error(), fiber.StatusBadRequest, nil)
+		case authsvc.ErrSessionNotFound:
+			return response.Error(c, err.Error(), fiber.StatusNotFound, nil)
+		default:
+			return response.Error(c, "Internal Server Error", fiber.StatusInternalServerError, nil)
+		}
+	}
+	middleware.Audit(c, h.Audit, auditsvc.Entry{
+		Action:     auditsvc.ActionSessionRevoked,
+		TargetType: "user",
+		TargetID:   user.UserID,
+		After:      fiber.Map{"session_id": body.SessionID},
+	})
+	return response.Success(c, "Session revoked", nil, nil)
+}
+
+// RevokeOtherSessions DELETE /api/v1/auth/revoke-other-sessions — signs out every device except this one.
+func (h *Handlers) RevokeOtherSessions(c *fiber.Ctx) error {
+	if h.Service == nil {
+		return response.Error(c, "Internal Server Error", fiber.StatusInternalServerError, nil)
+	}
+	user, err := authsvc.VerifyUser(middleware.GetUser(c))
+	if err != nil {
+		return response.Error(c, "Not authenticated", fiber.StatusUnauthorized, nil)
+	}
+	n, err := h.Service.RevokeOtherSessions(c.Context(), user.UserID, middleware.GetSessionID(c))
+	if err != nil {
+		return response.Error(c, "Internal Server Error", fiber.StatusInternalServerError, nil)
+	}
+	middleware.Audit(c, h.Audit, auditsvc.Entry{
+		Action:     auditsvc.ActionSessionRevoked,
+		TargetType: "user",
+		TargetID:   user.UserID,
+		After:      fiber.Map{"revoked": n, "scope": "others"},
+	})
+	return response.Success(c, "Other sessions revoked", fiber.Map{"revoked": n}, nil)
+}
+
+// StartSSO POST /api/v1/auth/start-sso — body: email. Routes the email's domain to its org's identity provider
+// and returns data.authorization_url to send the browser to.
+func (h *Handlers) StartSSO(c *fiber.Ctx) error {
+	if h.SSO == nil {
+		return response.Error(c, "Internal Server Error", fiber.StatusInternalServerError, nil)
+	}
+	var body struct {
+		Email string `json:"email"`
+	}
+	if err := c.BodyParser(&body); err != nil || body.Email == "" {
+		return response.Error(c, authsvc.ErrEmailRequired.Error(), fiber.StatusBadRequest, nil)
+	}
+	authURL, binding, err := h.SSO.Start(c.Context(), body.Email)
+	if err != nil {
+		return ssoError(c, err)
+	}
+	c.Cookie(h.ssoCookie(binding, int(ssoCookieTTL.Seconds())))
+	return response.Success(c, "Continue at your identity provider", fiber.Map{"authorization_url": authURL}, nil)
+}
+
+// CompleteSSO POST /api/v1/auth/complete-sso — body: code, state from the provider's redirect. Signs the user
+// in like Login (2FA still applies), creating them in the org with its default role on first sign-in.
+func (h *Handlers) CompleteSSO(c *fiber.Ctx) error {
+	if h.SSO == nil {
+		return response.Error(c, "Internal Server Error", fiber.StatusInternalServerError, nil)
+	}
+	var body struct {
+		Code  string `json:"code"`
+		State string `json:"state"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return response.Error(c, "Invalid request body", fiber.StatusBadRequest, nil)
+	}
+	user, created, err := h.SSO.Complete(c.Context(), body.Code, body.State, c.Cookies(ssoCookieName))
+	if err != ssosvc.ErrInvalidState {
+		// The state has been used up; an unknown state leaves the browser's real sign-in pending.
+		c.Cookie(h.ssoCookie("", -1))
+	}
+	if err != nil {
+		if err == ssosvc.ErrSignInFailed {
+			middleware.Audit(c, h.Audit, auditsvc.Entry{
+				Action:     auditsvc.ActionLoginFailed,
+				TargetType: "user",
+				After:      fiber.Map{"method": "sso", "reason": err.Error()},
+			})
+		}
+		return ssoError(c, err)
+	}
+	if created {
+		middleware.Audit(c, h.Audit, auditsvc.Entry{
+			ActorID:    &user.UserID,
+			OrgID:      user.OrgID,
+			Action:     auditsvc.ActionUserProvisioned,
+			TargetType: "user",
+			TargetID:   user.UserID.String(),
+			After:      fiber.Map{"email": user.Email, "role": user.Role},
+		})
+	}
+	return h.finishLogin(c, user, user.OrgID.String())
+}
+
+// ssoCookie is the SSO binding cookie, with the session cookie's SameSite and Secure settings and scoped to
+// the auth routes.
+func (h *Handlers) ssoCookie(value string, maxAge int) *fiber.Cookie {
+	cookie := middleware.SessionCookieConfig(h.Config)
+	cookie.Name = ssoCookieName
+	cookie.Value = value
+	cookie.Path = "/api/v1/auth"
+	cookie.MaxAge = maxAge
+	return &cookie
+}
+
+func ssoError(c *fiber.Ctx, err error) error {
+	switch err {
+	case ssosvc.ErrInvalidState:
+		return response.Error(c, err.Error(), fiber.StatusBadRequest, nil)
+	case ssosvc.ErrSignInFailed:
+		return response.Error(c, err.Error(), fiber.StatusUnauthorized, nil)
+	case ssosvc.ErrEmailNotAllowed:
+		return response.Error(c, err.Error(), fiber.StatusForbidden, nil)
+	case ssosvc.ErrNotConfigured:
+		return response.Error(c, err.Error(), fiber.StatusNotFound, nil)
+	case ssosvc.ErrAccountConflict:
+		return response.Error(c, err.Error(), fiber.StatusConflict, nil)
+	case ssosvc.ErrIdPUnreachable:
+		return response.Error(c, err.Error(), fiber.StatusBadGateway, nil)
+	default:
+		return response.Error(c, "Internal Server Error", fiber.StatusInternalServerError, nil)
+	}
+}
+
+func (h *Handlers) twoFactorError(c *fiber.Ctx, err error) error {
+	switch err {
+	case authsvc.ErrTwoFactorCodeRequired, authsvc.ErrInvalidTwoFactorCode:
+		return response.Error(c, err.Error(), fiber.StatusBadRequest, nil)
+	case authsvc.ErrTwoFactorEnabled, authsvc.ErrTwoFactorNotEnabled, authsvc.ErrTwoFactorNotSetUp:
+		return response.Error(c, err.Error(), fiber.StatusConflict, nil)
+	case authsvc.ErrNotAuthenticated:
+		return response.Error(c, "Not authenticated", fiber.StatusUnauthorized, nil)
+	case authsvc.ErrTwoFactorUnavailable:
+		return response.Error(c, err.Error(), fiber.StatusServiceUnavailable, nil)
+	default:
+		return response.Error(c, "Internal Server Error", fiber.StatusInternalServerError, nil)
+	}
+}
+
 func nilString(u *uuid.UUID) *string {
 	if u == nil {
 		return nil
@@ -0,0 +1,139 @@
+package auth
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	authsvc "troo-backend/internal/application/auth"
+	"troo-backend/internal/domain"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/glebarez/sqlite"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+func setupThrottleTest(t *testing.T) (*fiber.App, *miniredis.Miniredis, *fakeResetSender) {
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	t.Cleanup(mr.Close)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&domain.Org{}, &domain.User{}, &domain.OrgMembership{}, &domain.CustomRole{}, &domain.PlatformOperator{}, &domain.UserTwoFactor{}))
+	hash, _ := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+	require.NoError(t, db.Create(&domain.User{
+		UserID: uuid.New(), UserName: "jane", Email: "jane@example.com", PasswordHash: string(hash), Fullname: "Jane Doe", Role: "viewer",
+	}).Error)
+
+	sender := &fakeResetSender{locked: make(chan string, 2)}
+	h := &Handlers{
+		UserFinder: &authsvc.GormUserFinder{DB: db},
+		Service:    &authsvc.Service{DB: db, Rdb: rdb, EmailSender: sender},
+		Rdb:        rdb,
+	}
+	app := fiber.New()
+	app.Post("/login", h.Login)
+	return app, mr, sender
+}
+
+func attemptLogin(t *testing.T, app *fiber.App, email, password string) (int, string, string) {
+	b, _ := json.Marshal(map[string]string{"email": email, "password": password})
+	req := httptest.NewRequest("POST", "/login", bytes.NewReader(b))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	var out map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&out)
+	msg, _ := out["message"].(string)
+	if e, ok := out["error"].(map[string]interface{}); ok {
+		msg, _ = e["message"].(string)
+	}
+	return resp.StatusCode, msg, resp.Header.Get(fiber.HeaderRetryAfter)
+}
+
+func TestLoginThrottle_UnifiedErrorAndProgressiveDelay(t *testing.T) {
+	app, mr, _ := setupThrottleTest(t)
+
+	_, unknown, _ := attemptLogin(t, app, "nobody@example.com", "password123")
+	code, wrong, _ := attemptLogin(t, app, "jane@example.com", "wrong")
+	assert.Equal(t, 401, code)
+	assert.Equal(t, "Invalid email or password", wrong)
+	assert.Equal(t, unknown, wrong)
+
+	attemptLogin(t, app, "jane@example.com", "wrong")
+	code, _, _ = attemptLogin(t, app, "JANE@example.com", "wrong")
+	assert.Equal(t, 401, code)
+
+	// The third failure starts a one second delay, which applies even to the right password.
+	code, msg, retry := attemptLogin(t, app, "jane@example.com", "password123")
+	assert.Equal(t, 429, code)
+	assert.Equal(t, "Too many login attempts. Please try again later", msg)
+	assert.Equal(t, "1", retry)
+
+	mr.FastForward(time.Second)
+	code, _, _ = attemptLogin(t, app, "jane@example.com", "wrong")
+	assert.Equal(t, 401, code)
+	_, _, retry = attemptLogin(t, app, "jane@example.com", "password123")
+	assert.Equal(t, "2", retry)
+
+	// A successful login clears the count.
+	mr.FastForward(2 * time.Second)
+	code, _, _ = attemptLogin(t, app, "jane@example.com", "password123")
+	require.Equal(t, 200, code)
+	code, _, _ = attemptLogin(t, app, "jane@example.com", "wrong")
+	assert.Equal(t, 401, code)
+	code, _, _ = attemptLogin(t, app, "jane@example.com", "wrong")
+	assert.Equal(t, 401, code)
+}
+
+func TestLoginThrottle_LockoutNotifiesOwner(t *testing.T) {
+	app, mr, sender := setupThrottleTest(t)
+
+	for i := 0; i < 10; i++ {
+		if i > 0 {
+			mr.FastForward(30 * time.Second) // past any delay
+		}
+		code, _, retry := attemptLogin(t, app, "jane@example.com", "wrong")
+		require.Equal(t, 401, code, "attempt %d", i+1)
+		require.Empty(t, retry)
+	}
+	select {
+	case to := <-sender.locked:
+		assert.Equal(t, "jane@example.com", to)
+	case <-time.After(2 * time.Second):
+		t.Fatal("no lockout email sent")
+	}
+
+	code, _, retry := attemptLogin(t, app, "jane@example.com", "password123")
+	assert.Equal(t, 429, code)
+	assert.Equal(t, "900", retry)
+
+	mr.FastForward(15 * time.Minute)
+	code, _, _ = attemptLogin(t, app, "jane@example.com", "password123")
+	assert.Equal(t, 200, code)
+}
+
+func TestLoginThrottle_PerIPLimit(t *testing.T) {
+	app, _, sender := setupThrottleTest(t)
+
+	// Spread across accounts so no single account is delayed.
+	for i := 0; i < 50; i++ {
+		code, _, _ := attemptLogin(t, app, fmt.Sprintf("user%d@example.com", i), "wrong")
+		require.Equal(t, 401, code)
+	}
+	code, _, retry := attemptLogin(t, app, "jane@example.com", "password123")
+	assert.Equal(t, 429, code)
+	assert.NotEmpty(t, retry)
+	assert.Empty(t, sender.locked)
+}
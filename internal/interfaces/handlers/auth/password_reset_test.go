@@ -0,0 +1,172 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	authsvc "troo-backend/internal/application/auth"
+	"troo-backend/internal/domain"
+	"troo-backend/internal/middleware"
+
+	"github.com/glebarez/sqlite"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// fakeResetSender captures reset links and lockout notices; the other emails are not sent by these flows.
+type fakeResetSender struct {
+	links  chan string
+	locked chan string
+}
+
+func (f *fakeResetSender) SendWelcome(ctx context.Context, toEmail, firstName string) error {
+	return nil
+}
+func (f *fakeResetSender) SendInvite(ctx context.Context, toEmail, inviteLink, orgName, role, subject string) error {
+	return nil
+}
+func (f *fakeResetSender) SendAccountUpdated(ctx context.Context, toEmail, firstName string) error {
+	return nil
+}
+func (f *fakeResetSender) SendVerifyEmail(ctx context.Context, toEmail, firstName, verifyLink string) error {
+	return nil
+}
+func (f *fakeResetSender) SendAccountLocked(ctx context.Context, toEmail, firstName, resetLink string) error {
+	if f.locked != nil {
+		f.locked <- toEmail
+	}
+	return nil
+}
+func (f *fakeResetSender) SendPasswordReset(ctx context.Context, toEmail, firstName, resetLink string) error {
+	f.links <- resetLink
+	return nil
+}
+
+func setupResetTest(t *testing.T) (*fiber.App, *gorm.DB, *Handlers, *fakeResetSender) {
+	h, rdb := setupAuthHandlers(t, nil)
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&domain.User{}, &domain.OrgMembership{}, &domain.PasswordResetToken{}))
+	sender := &fakeResetSender{links: make(chan string, 4)}
+	h.Service = &authsvc.Service{DB: db, Rdb: rdb, EmailSender: sender, AppBaseURL: "https://atlas.troo.earth/"}
+
+	app := fiber.New()
+	app.Post("/forgot-password", h.ForgotPassword)
+	app.Post("/reset-password", h.ResetPassword)
+	return app, db, h, sender
+}
+
+func postJSON(t *testing.T, app *fiber.App, path string, body interface{}) (int, map[string]interface{}) {
+	b, _ := json.Marshal(body)
+	req := httptest.NewRequest("POST", path, bytes.NewReader(b))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	var out map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&out)
+	return resp.StatusCode, out
+}
+
+func (f *fakeResetSender) token(t *testing.T) string {
+	select {
+	case link := <-f.links:
+		u, err := url.Parse(link)
+		require.NoError(t, err)
+		assert.Equal(t, "/reset-password", u.Path)
+		return u.Query().Get("token")
+	case <-time.After(2 * time.Second):
+		t.Fatal("no reset email sent")
+		return ""
+	}
+}
+
+func TestPasswordReset_Flow(t *testing.T) {
+	app, db, h, sender := setupResetTest(t)
+	uid := uuid.New()
+	require.NoError(t, db.Create(&domain.User{
+		UserID: uid, UserName: "jane", Email: "jane@example.com", PasswordHash: "old", Fullname: "Jane Doe", Role: "viewer",
+	}).Error)
+	ctx := context.Background()
+	require.NoError(t, h.Rdb.SAdd(ctx, "user_sessions:"+uid.String(), "sid-1").Err())
+	require.NoError(t, h.Rdb.Set(ctx, middleware.SessionRedisPrefix+"sid-1", "{}", 0).Err())
+
+	// Unknown and known emails get the same answer.
+	code, unknown := postJSON(t, app, "/forgot-password", map[string]string{"email": "nobody@example.com"})
+	require.Equal(t, 200, code)
+	code, known := postJSON(t, app, "/forgot-password", map[string]string{"email": "Jane@Example.com"})
+	require.Equal(t, 200, code)
+	assert.Equal(t, unknown["message"], known["message"])
+	first := sender.token(t)
+
+	// A newer request supersedes the first link, and only the hash is stored.
+	postJSON(t, app, "/forgot-password", map[string]string{"email": "jane@example.com"})
+	token := sender.token(t)
+	var stored domain.PasswordResetToken
+	require.NoError(t, db.Where("used_at IS NULL").First(&stored).Error)
+	assert.NotEqual(t, token, stored.TokenHash)
+
+	code, _ = postJSON(t, app, "/reset-password", map[string]string{"token": first, "password": "N3w-password!"})
+	assert.Equal(t, 400, code)
+	code, out := postJSON(t, app, "/reset-password", map[string]string{"token": token, "password": "weak"})
+	assert.Equal(t, 400, code)
+	assert.Equal(t, "Invalid password format", out["error"].(map[string]interface{})["message"])
+
+	code, _ = postJSON(t, app, "/reset-password", map[string]string{"token": token, "password": "N3w-password!"})
+	require.Equal(t, 200, code)
+	var u domain.User
+	require.NoError(t, db.Where("user_id = ?", uid).First(&u).Error)
+	assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte("N3w-password!")))
+	assert.Equal(t, int64(0), h.Rdb.Exists(ctx, middleware.SessionRedisPrefix+"sid-1").Val())
+
+	// Single use.
+	code, _ = postJSON(t, app, "/reset-password", map[string]string{"token": token, "password": "An0ther-password!"})
+	assert.Equal(t, 400, code)
+}
+
+func TestPasswordReset_ExpiredToken(t *testing.T) {
+	app, db, _, sender := setupResetTest(t)
+	require.NoError(t, db.Create(&domain.User{
+		UserID: uuid.New(), UserName: "jane", Email: "jane@example.com", PasswordHash: "old", Fullname: "Jane", Role: "viewer",
+	}).Error)
+
+	postJSON(t, app, "/forgot-password", map[string]string{"email": "jane@example.com"})
+	token := sender.token(t)
+	require.NoError(t, db.Model(&domain.PasswordResetToken{}).Where("1 = 1").Update("expires_at", time.Now().Add(-time.Minute)).Error)
+
+	code, out := postJSON(t, app, "/reset-password", map[string]string{"token": token, "password": "N3w-password!"})
+	assert.Equal(t, 400, code)
+	assert.Equal(t, "Invalid or expired reset token", out["error"].(map[string]interface{})["message"])
+
+	code, _ = postJSON(t, app, "/forgot-password", map[string]string{"email": ""})
+	assert.Equal(t, 400, code)
+}
+
+func TestForgotPassword_RateLimitedPerEmailAndIP(t *testing.T) {
+	app, _, _, _ := setupResetTest(t)
+
+	for i := 0; i < 5; i++ {
+		code, _ := postJSON(t, app, "/forgot-password", map[string]string{"email": "nobody@example.com"})
+		require.Equal(t, 200, code, "request %d", i+1)
+	}
+	code, out := postJSON(t, app, "/forgot-password", map[string]string{"email": "Nobody@Example.com"})
+	assert.Equal(t, 429, code)
+	assert.Equal(t, "Too many password reset requests. Please try again later", out["error"].(map[string]interface{})["message"])
+
+	// Spread across emails, the same client hits the per-IP cap (5 already counted above, plus the refused one).
+	for i := 0; i < 14; i++ {
+		code, _ = postJSON(t, app, "/forgot-password", map[string]string{"email": fmt.Sprintf("user%d@example.com", i)})
+		require.Equal(t, 200, code, "request %d", i+1)
+	}
+	code, _ = postJSON(t, app, "/forgot-password", map[string]string{"email": "other@example.com"})
+	assert.Equal(t, 429, code)
+}
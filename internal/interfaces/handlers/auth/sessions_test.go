@@ -0,0 +1,62 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessions_ListAndRevoke(t *testing.T) {
+	laptop, _ := setupLoginApp(t, false)
+	laptop.userAgent = "Laptop"
+	phone := &twoFactorClient{t: t, app: laptop.app, userAgent: "Phone"}
+	tablet := &twoFactorClient{t: t, app: laptop.app, userAgent: "Tablet"}
+	for _, cl := range []*twoFactorClient{laptop, phone, tablet} {
+		code, _ := login(cl)
+		require.Equal(t, 200, code)
+	}
+
+	code, out := laptop.do("GET", "/sessions", nil)
+	require.Equal(t, 200, code)
+	sessions := out["data"].(map[string]interface{})["sessions"].([]interface{})
+	require.Len(t, sessions, 3)
+	var current, phoneID string
+	for _, s := range sessions {
+		m := s.(map[string]interface{})
+		assert.NotEmpty(t, m["created_at"])
+		assert.NotEmpty(t, m["last_seen"])
+		if m["current"] == true {
+			current = m["session_id"].(string)
+			assert.Equal(t, "Laptop", m["user_agent"])
+		}
+		if m["user_agent"] == "Phone" {
+			phoneID = m["session_id"].(string)
+		}
+	}
+	require.NotEmpty(t, phoneID)
+	assert.NotContains(t, laptop.cookie, current, "listed ids are not the session ids")
+
+	code, _ = laptop.do("DELETE", "/revoke-session", map[string]string{"session_id": current})
+	assert.Equal(t, 400, code)
+	code, _ = laptop.do("DELETE", "/revoke-session", map[string]string{"session_id": "unknown"})
+	assert.Equal(t, 404, code)
+	code, _ = laptop.do("DELETE", "/revoke-session", map[string]string{"session_id": phoneID})
+	require.Equal(t, 200, code)
+	code, _ = phone.do("GET", "/me", nil)
+	assert.Equal(t, 401, code)
+	code, _ = tablet.do("GET", "/me", nil)
+	assert.Equal(t, 200, code)
+
+	login(phone)
+	code, out = laptop.do("DELETE", "/revoke-other-sessions", nil)
+	require.Equal(t, 200, code)
+	assert.Equal(t, float64(2), out["data"].(map[string]interface{})["revoked"])
+	for _, cl := range []*twoFactorClient{phone, tablet} {
+		code, _ = cl.do("GET", "/me", nil)
+		assert.Equal(t, 401, code)
+	}
+	code, out = laptop.do("GET", "/sessions", nil)
+	require.Equal(t, 200, code)
+	assert.Len(t, out["data"].(map[string]interface{})["sessions"], 1)
+}
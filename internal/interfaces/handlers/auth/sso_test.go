@@ -0,0 +1,267 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	authsvc "troo-backend/internal/application/auth"
+	"troo-backend/internal/application/memberships"
+	ssosvc "troo-backend/internal/application/sso"
+	"troo-backend/internal/domain"
+	"troo-backend/internal/middleware"
+	"troo-backend/internal/pkg/constants"
+	"troo-backend/internal/pkg/oidc/oidctest"
+	"troo-backend/internal/pkg/totp"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/glebarez/sqlite"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// fakeDNS maps TXT record names to their values.
+type fakeDNS map[string][]string
+
+func (d fakeDNS) lookup(_ context.Context, name string) ([]string, error) {
+	if v, ok := d[name]; ok {
+		return v, nil
+	}
+	return nil, errors.New("no such host")
+}
+
+// dnsTXT is the DNS every SSO test sees; setupSSOApp publishes acme.com's verification record in it.
+var dnsTXT = fakeDNS{}
+
+// setupSSOApp serves the SSO endpoints behind the real session middleware, with acme.com routed to a mock IdP.
+func setupSSOApp(t *testing.T) (*twoFactorClient, *oidctest.Server, *gorm.DB, uuid.UUID) {
+	idp := oidctest.NewServer("troo-client", "troo-secret")
+	t.Cleanup(idp.Close)
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	t.Cleanup(mr.Close)
+	session, rdb, err := middleware.Session(middleware.SessionConfig{RedisURL: "redis://" + mr.Addr()})
+	require.NoError(t, err)
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&domain.Org{}, &domain.User{}, &domain.OrgMembership{}, &domain.CustomRole{}, &domain.PlatformOperator{},
+		&domain.UserTwoFactor{}, &domain.OrgSSOConfig{}, &domain.UserSSOIdentity{}))
+	orgID, otherOrg := uuid.New(), uuid.New()
+	require.NoError(t, db.Create(&domain.Org{OrgID: orgID, OrgName: "Acme", OrgCode: "ACME", CountryCode: "GB"}).Error)
+	require.NoError(t, db.Create(&domain.User{
+		UserID: uuid.New(), UserName: "bob", Email: "bob@acme.com", Fullname: "Bob", Role: constants.Manager, OrgID: &orgID, EmailVerified: true,
+	}).Error)
+	require.NoError(t, db.Create(&domain.User{
+		UserID: uuid.New(), UserName: "carol", Email: "carol@acme.com", Fullname: "Carol", Role: constants.Admin, OrgID: &otherOrg, EmailVerified: true,
+	}).Error)
+
+	sso := &ssosvc.Service{DB: db, Rdb: rdb, HTTPClient: idp.Client(), RedirectURL: "https://atlas.troo.earth/sso/callback", LookupTXT: dnsTXT.lookup}
+	cfg, err := sso.UpdateConfig(context.Background(), orgID, ssosvc.ConfigInput{
+		Issuer: idp.Issuer(), ClientID: "troo-client", ClientSecret: "troo-secret", EmailDomains: []string{"@ACME.com"}, Enabled: true,
+	})
+	require.NoError(t, err)
+	name, value := ssosvc.VerificationRecord(cfg, "acme.com")
+	dnsTXT[name] = []string{"v=spf1 -all", value}
+	_, err = sso.VerifyDomain(context.Background(), orgID, "acme.com")
+	require.NoError(t, err)
+
+	h := &Handlers{
+		UserFinder: &authsvc.GormUserFinder{DB: db},
+		Service:    &authsvc.Service{DB: db, Rdb: rdb, Secrets: testSecrets(t)},
+		Rdb:        rdb,
+		SSO:        sso,
+	}
+	app := fiber.New()
+	app.Use(session)
+	app.Post("/start-sso", h.StartSSO)
+	app.Post("/complete-sso", h.CompleteSSO)
+	app.Get("/me", h.Me)
+	app.Post("/verify-2fa", h.VerifyTwoFactor)
+	app.Post("/setup-2fa", middleware.RequireAuth(), h.SetupTwoFactor)
+	app.Post("/enable-2fa", middleware.RequireAuth(), h.EnableTwoFactor)
+	return &twoFactorClient{t: t, app: app}, idp, db, orgID
+}
+
+// ssoLogin runs the browser round trip: start, sign in at the IdP as claims, complete.
+func ssoLogin(t *testing.T, cl *twoFactorClient, idp *oidctest.Server, email string, claims map[string]interface{}) (int, map[string]interface{}) {
+	cl.cookie = ""
+	code, out := cl.do("POST", "/start-sso", map[string]string{"email": email})
+	require.Equal(t, 200, code, out)
+	authCode, state, err := idp.Authorize(out["data"].(map[string]interface{})["authorization_url"].(string), claims)
+	require.NoError(t, err)
+	return cl.do("POST", "/complete-sso", map[string]string{"code": authCode, "state": state})
+}
+
+func TestSSO_ProvisionsAndLinksUsers(t *testing.T) {
+	cl, idp, db, orgID := setupSSOApp(t)
+
+	code, _ := cl.do("POST", "/start-sso", map[string]string{"email": "jane@example.com"})
+	assert.Equal(t, 404, code)
+
+	// First sign-in creates the user in the org with the default role.
+	code, out := ssoLogin(t, cl, idp, "new@acme.com", map[string]interface{}{"sub": "sub-new", "email": "New@Acme.com", "name": "New Person", "email_verified": true})
+	require.Equal(t, 200, code, out)
+	user := out["data"].(map[string]interface{})["user"].(map[string]interface{})
+	assert.Equal(t, constants.Viewer, user["role"])
+	assert.Equal(t, orgID.String(), user["org_id"])
+	assert.Equal(t, "new@acme.com", user["email"])
+	code, _ = cl.do("GET", "/me", nil)
+	assert.Equal(t, 200, code)
+
+	// The subject stays linked when the IdP changes the email.
+	code, out = ssoLogin(t, cl, idp, "new@acme.com", map[string]interface{}{"sub": "sub-new", "email": "renamed@acme.com"})
+	require.Equal(t, 200, code, out)
+	assert.Equal(t, user["user_id"], out["data"].(map[string]interface{})["user"].(map[string]interface{})["user_id"])
+	var count int64
+	db.Model(&domain.User{}).Count(&count)
+	assert.Equal(t, int64(3), count)
+
+	// Existing members are linked by email and keep their role.
+	code, out = ssoLogin(t, cl, idp, "bob@acme.com", map[string]interface{}{"sub": "sub-bob", "email": "bob@acme.com", "email_verified": true})
+	require.Equal(t, 200, code, out)
+	assert.Equal(t, constants.Manager, out["data"].(map[string]interface{})["user"].(map[string]interface{})["role"])
+
+	code, _ = ssoLogin(t, cl, idp, "carol@acme.com", map[string]interface{}{"sub": "sub-carol", "email": "carol@acme.com", "email_verified": true})
+	assert.Equal(t, 409, code, "member of another org")
+	code, _ = ssoLogin(t, cl, idp, "x@acme.com", map[string]interface{}{"sub": "sub-x", "email": "x@elsewhere.com", "email_verified": true})
+	assert.Equal(t, 403, code, "email outside the org's domains")
+	code, _ = ssoLogin(t, cl, idp, "y@acme.com", map[string]interface{}{"sub": "sub-y", "email": "y@acme.com", "email_verified": false})
+	assert.Equal(t, 403, code, "unverified email")
+	code, _ = ssoLogin(t, cl, idp, "z@acme.com", map[string]interface{}{"sub": "sub-z", "email": "z@acme.com"})
+	assert.Equal(t, 403, code, "email_verified must be present")
+}
+
+func TestSSO_StateIsSingleUse(t *testing.T) {
+	cl, idp, _, _ := setupSSOApp(t)
+	code, out := cl.do("POST", "/start-sso", map[string]string{"email": "new@acme.com"})
+	require.Equal(t, 200, code)
+	authURL := out["data"].(map[string]interface{})["authorization_url"].(string)
+	authCode, state, err := idp.Authorize(authURL, map[string]interface{}{"sub": "sub-new", "email": "new@acme.com"})
+	require.NoError(t, err)
+
+	code, _ = cl.do("POST", "/complete-sso", map[string]string{"code": authCode, "state": "forged"})
+	assert.Equal(t, 400, code)
+	require.NotEmpty(t, cl.ssoCookie, "start-sso binds the sign-in to this browser")
+	code, _ = cl.do("POST", "/complete-sso", map[string]string{"code": "wrong", "state": state})
+	assert.Equal(t, 401, code)
+	code, _ = cl.do("POST", "/complete-sso", map[string]string{"code": authCode, "state": state})
+	assert.Equal(t, 400, code, "state was consumed by the failed attempt")
+}
+
+func TestSSO_DomainsBelongToOneOrg(t *testing.T) {
+	_, idp, db, _ := setupSSOApp(t)
+	sso := &ssosvc.Service{DB: db, HTTPClient: idp.Client()}
+	_, err := sso.UpdateConfig(context.Background(), uuid.New(), ssosvc.ConfigInput{
+		Issuer: idp.Issuer(), ClientID: "c", ClientSecret: "s", EmailDomains: []string{"acme.com"},
+	})
+	assert.Equal(t, ssosvc.ErrDomainTaken, err)
+	_, err = sso.UpdateConfig(context.Background(), uuid.New(), ssosvc.ConfigInput{
+		Issuer: idp.Issuer(), ClientID: "c", ClientSecret: "s", EmailDomains: []string{"beta.com"}, DefaultRole: constants.Superadmin,
+	})
+	assert.Equal(t, ssosvc.ErrInvalidRole, err)
+}
+
+func TestSSO_DomainsMustBeVerified(t *testing.T) {
+	cl, idp, db, _ := setupSSOApp(t)
+	sso := &ssosvc.Service{DB: db, HTTPClient: idp.Client(), LookupTXT: dnsTXT.lookup}
+	ctx := context.Background()
+
+	// Another org may list a domain it does not own, but nothing routes to it until it is verified.
+	squatter := uuid.New()
+	_, err := sso.UpdateConfig(ctx, squatter, ssosvc.ConfigInput{
+		Issuer: idp.Issuer(), ClientID: "c", ClientSecret: "s", EmailDomains: []string{"beta.com"}, Enabled: true,
+	})
+	require.NoError(t, err)
+	code, _ := cl.do("POST", "/start-sso", map[string]string{"email": "ann@beta.com"})
+	assert.Equal(t, 404, code)
+	_, err = sso.VerifyDomain(ctx, squatter, "beta.com")
+	assert.Equal(t, ssosvc.ErrDomainUnverified, err)
+	_, err = sso.VerifyDomain(ctx, squatter, "gamma.com")
+	assert.Equal(t, ssosvc.ErrDomainNotClaimed, err)
+
+	// Publishing another org's token does not verify the domain for this one.
+	owner := uuid.New()
+	cfg, err := sso.UpdateConfig(ctx, owner, ssosvc.ConfigInput{
+		Issuer: idp.Issuer(), ClientID: "c", ClientSecret: "s", EmailDomains: []string{"beta.com"}, Enabled: true,
+	})
+	require.NoError(t, err)
+	name, value := ssosvc.VerificationRecord(cfg, "beta.com")
+	dnsTXT[name] = []string{value}
+	t.Cleanup(func() { delete(dnsTXT, name) })
+	_, err = sso.VerifyDomain(ctx, squatter, "beta.com")
+	assert.Equal(t, ssosvc.ErrDomainUnverified, err)
+	cfg, err = sso.VerifyDomain(ctx, owner, "beta.com")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"beta.com"}, cfg.VerifiedDomainList())
+
+	code, out := cl.do("POST", "/start-sso", map[string]string{"email": "ann@beta.com"})
+	require.Equal(t, 200, code, out)
+	_, err = sso.UpdateConfig(ctx, squatter, ssosvc.ConfigInput{
+		Issuer: idp.Issuer(), ClientID: "c", ClientSecret: "s", EmailDomains: []string{"beta.com"}, Enabled: true,
+	})
+	assert.Equal(t, ssosvc.ErrDomainTaken, err, "verified by another org")
+}
+
+func TestSSO_StateIsBoundToTheStartingBrowser(t *testing.T) {
+	cl, idp, _, _ := setupSSOApp(t)
+	code, out := cl.do("POST", "/start-sso", map[string]string{"email": "new@acme.com"})
+	require.Equal(t, 200, code)
+	authCode, state, err := idp.Authorize(out["data"].(map[string]interface{})["authorization_url"].(string),
+		map[string]interface{}{"sub": "sub-new", "email": "new@acme.com", "email_verified": true})
+	require.NoError(t, err)
+
+	// A victim's browser, lured to the attacker's callback link, has no binding cookie for this state.
+	victim := &twoFactorClient{t: t, app: cl.app}
+	code, _ = victim.do("POST", "/complete-sso", map[string]string{"code": authCode, "state": state})
+	assert.Equal(t, 400, code)
+	assert.Empty(t, victim.cookie)
+}
+
+func TestSSO_SessionIsBoundToTheSSOOrg(t *testing.T) {
+	cl, idp, db, orgID := setupSSOApp(t)
+	var carol domain.User
+	require.NoError(t, db.Where("email = ?", "carol@acme.com").First(&carol).Error)
+	home := *carol.OrgID
+	require.NoError(t, memberships.Add(db, &carol, orgID, constants.Viewer, nil))
+
+	// Signing in through Acme's SSO puts the session in Acme with the role held there.
+	code, out := ssoLogin(t, cl, idp, "carol@acme.com", map[string]interface{}{"sub": "sub-carol", "email": "carol@acme.com", "email_verified": true})
+	require.Equal(t, 200, code, out)
+	code, out = cl.do("GET", "/me", nil)
+	require.Equal(t, 200, code, out)
+	me := out["data"].(map[string]interface{})["user"].(map[string]interface{})
+	assert.Equal(t, orgID.String(), me["org_id"])
+	assert.Equal(t, constants.Viewer, me["role"])
+	assert.Equal(t, middleware.AuthMethodSSO, me["auth_method"])
+	assert.Equal(t, orgID.String(), me["sso_org_id"])
+
+	// The Users row keeps carol's home org, which her other sessions work in.
+	require.NoError(t, db.Where("user_id = ?", carol.UserID).First(&carol).Error)
+	assert.Equal(t, home, *carol.OrgID)
+	assert.Equal(t, constants.Admin, carol.Role)
+
+	// With 2FA the binding survives the second step.
+	code, out = cl.do("POST", "/setup-2fa", nil)
+	require.Equal(t, 200, code, out)
+	secret := out["data"].(map[string]interface{})["secret"].(string)
+	now, _ := totp.CodeAt(secret, totp.Step(time.Now()))
+	code, out = cl.do("POST", "/enable-2fa", map[string]string{"code": now})
+	require.Equal(t, 200, code, out)
+	code, out = ssoLogin(t, cl, idp, "carol@acme.com", map[string]interface{}{"sub": "sub-carol", "email": "carol@acme.com", "email_verified": true})
+	require.Equal(t, 200, code, out)
+	assert.Equal(t, true, out["data"].(map[string]interface{})["two_factor_required"])
+	next, _ := totp.CodeAt(secret, totp.Step(time.Now())+1)
+	code, out = cl.do("POST", "/verify-2fa", map[string]string{"code": next})
+	require.Equal(t, 200, code, out)
+	code, out = cl.do("GET", "/me", nil)
+	require.Equal(t, 200, code, out)
+	me = out["data"].(map[string]interface{})["user"].(map[string]interface{})
+	assert.Equal(t, orgID.String(), me["org_id"])
+	assert.Equal(t, constants.Viewer, me["role"])
+	assert.Equal(t, orgID.String(), me["sso_org_id"])
+}
@@ -0,0 +1,241 @@
+package auth
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	authsvc "troo-backend/internal/application/auth"
+	"troo-backend/internal/domain"
+	"troo-backend/internal/middleware"
+	"troo-backend/internal/pkg/constants"
+	"troo-backend/internal/pkg/secretbox"
+	"troo-backend/internal/pkg/totp"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/glebarez/sqlite"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// twoFactorClient drives the app through the real session middleware, carrying the cookie like a browser.
+type twoFactorClient struct {
+	t         *testing.T
+	app       *fiber.App
+	cookie    string
+	ssoCookie string
+	userAgent string
+	mr        *miniredis.Miniredis
+}
+
+func (cl *twoFactorClient) do(method, path string, body interface{}) (int, map[string]interface{}) {
+	b, _ := json.Marshal(body)
+	req := httptest.NewRequest(method, path, bytes.NewReader(b))
+	req.Header.Set("Content-Type", "application/json")
+	if cookies := strings.Trim(cl.cookie+"; "+cl.ssoCookie, "; "); cookies != "" {
+		req.Header.Set("Cookie", cookies)
+	}
+	if cl.userAgent != "" {
+		req.Header.Set("User-Agent", cl.userAgent)
+	}
+	resp, err := cl.app.Test(req)
+	require.NoError(cl.t, err)
+	for _, ck := range resp.Cookies() {
+		switch {
+		case ck.Name == middleware.SessionCookieName:
+			cl.cookie = ck.Name + "=" + ck.Value
+		case ck.Name == ssoCookieName && ck.Value == "":
+			cl.ssoCookie = ""
+		case ck.Name == ssoCookieName:
+			cl.ssoCookie = ck.Name + "=" + ck.Value
+		}
+	}
+	var out map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&out)
+	return resp.StatusCode, out
+}
+
+// setupLoginApp serves the auth endpoints behind the real session middleware, for a manager of an org.
+func setupLoginApp(t *testing.T, requireTwoFactor bool) (*twoFactorClient, *gorm.DB) {
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	t.Cleanup(mr.Close)
+	session, rdb, err := middleware.Session(middleware.SessionConfig{RedisURL: "redis://" + mr.Addr()})
+	require.NoError(t, err)
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&domain.Org{}, &domain.User{}, &domain.OrgMembership{}, &domain.CustomRole{}, &domain.PlatformOperator{}, &domain.UserTwoFactor{}))
+	orgID := uuid.New()
+	require.NoError(t, db.Create(&domain.Org{OrgID: orgID, OrgName: "Acme", OrgCode: "ACME", CountryCode: "GB", RequireTwoFactor: requireTwoFactor}).Error)
+	hash, _ := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+	require.NoError(t, db.Create(&domain.User{
+		UserID: uuid.New(), UserName: "jane", Email: "jane@example.com", PasswordHash: string(hash),
+		Fullname: "Jane Doe", Role: constants.Manager, OrgID: &orgID, EmailVerified: true,
+	}).Error)
+
+	h := &Handlers{
+		UserFinder: &authsvc.GormUserFinder{DB: db},
+		Service:    &authsvc.Service{DB: db, Rdb: rdb, Secrets: testSecrets(t)},
+		Rdb:        rdb,
+	}
+	app := fiber.New()
+	app.Use(session)
+	app.Post("/login", h.Login)
+	app.Get("/me", h.Me)
+	app.Delete("/logout", h.Logout)
+	app.Post("/verify-2fa", h.VerifyTwoFactor)
+	app.Post("/setup-2fa", middleware.RequireAuth(), h.SetupTwoFactor)
+	app.Post("/enable-2fa", middleware.RequireAuth(), h.EnableTwoFactor)
+	app.Post("/disable-2fa", middleware.RequireAuth(), h.DisableTwoFactor)
+	app.Get("/sessions", middleware.RequireAuth(), h.ListSessions)
+	app.Delete("/revoke-session", middleware.RequireAuth(), h.RevokeSession)
+	app.Delete("/revoke-other-sessions", middleware.RequireAuth(), h.RevokeOtherSessions)
+	app.Post("/buy", middleware.RequireAuth(), middleware.AuthorizePermission(constants.BuyCredits), func(c *fiber.Ctx) error { return c.SendStatus(200) })
+	return &twoFactorClient{t: t, app: app, mr: mr}, db
+}
+
+func testSecrets(t *testing.T) *secretbox.Box {
+	box, err := secretbox.New(base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{7}, 32)))
+	require.NoError(t, err)
+	return box
+}
+
+func login(cl *twoFactorClient) (int, map[string]interface{}) {
+	cl.cookie = ""
+	return cl.do("POST", "/login", map[string]string{"email": "jane@example.com", "password": "password123"})
+}
+
+// enrol turns 2FA on for the signed-in user and returns the secret and recovery codes.
+func enrol(t *testing.T, cl *twoFactorClient) (string, []interface{}) {
+	code, out := cl.do("POST", "/setup-2fa", nil)
+	require.Equal(t, 200, code, out)
+	data := out["data"].(map[string]interface{})
+	secret := data["secret"].(string)
+	assert.Contains(t, data["otpauth_uri"], "otpauth://totp/troo.earth:jane@example.com?")
+
+	code, _ = cl.do("POST", "/enable-2fa", map[string]string{"code": "000000"})
+	assert.Equal(t, 400, code)
+	now, _ := totp.CodeAt(secret, totp.Step(time.Now()))
+	code, out = cl.do("POST", "/enable-2fa", map[string]string{"code": now})
+	require.Equal(t, 200, code, out)
+	return secret, out["data"].(map[string]interface{})["recovery_codes"].([]interface{})
+}
+
+func TestTwoFactor_TwoStepLogin(t *testing.T) {
+	cl, db := setupLoginApp(t, false)
+	code, out := login(cl)
+	require.Equal(t, 200, code, out)
+	secret, recovery := enrol(t, cl)
+	assert.Len(t, recovery, 10)
+
+	// Password alone now only yields a pending session.
+	code, out = login(cl)
+	require.Equal(t, 200, code)
+	assert.Equal(t, true, out["data"].(map[string]interface{})["two_factor_required"])
+	code, _ = cl.do("GET", "/me", nil)
+	assert.Equal(t, 401, code)
+
+	// The code used to enable 2FA cannot be replayed; the next step's code is accepted.
+	var tf domain.UserTwoFactor
+	require.NoError(t, db.First(&tf).Error)
+	step := tf.LastUsedStep
+	used, _ := totp.CodeAt(secret, step)
+	code, _ = cl.do("POST", "/verify-2fa", map[string]string{"code": used})
+	assert.Equal(t, 401, code)
+	next, _ := totp.CodeAt(secret, step+1)
+	code, out = cl.do("POST", "/verify-2fa", map[string]string{"code": next})
+	require.Equal(t, 200, code, out)
+	code, out = cl.do("GET", "/me", nil)
+	require.Equal(t, 200, code)
+	assert.Equal(t, true, out["data"].(map[string]interface{})["user"].(map[string]interface{})["two_factor"])
+
+	// Recovery codes work once each.
+	login(cl)
+	code, _ = cl.do("POST", "/verify-2fa", map[string]string{"recovery_code": recovery[0].(string)})
+	require.Equal(t, 200, code)
+	login(cl)
+	code, _ = cl.do("POST", "/verify-2fa", map[string]string{"recovery_code": recovery[0].(string)})
+	assert.Equal(t, 401, code)
+	code, _ = cl.do("POST", "/verify-2fa", map[string]string{"recovery_code": recovery[1].(string)})
+	require.Equal(t, 200, code)
+
+	code, _ = cl.do("POST", "/disable-2fa", map[string]string{"recovery_code": recovery[2].(string)})
+	require.Equal(t, 200, code)
+	code, out = login(cl)
+	require.Equal(t, 200, code)
+	assert.NotNil(t, out["data"].(map[string]interface{})["user"])
+}
+
+func TestTwoFactor_AttemptsAreLimited(t *testing.T) {
+	cl, _ := setupLoginApp(t, false)
+	login(cl)
+	secret, _ := enrol(t, cl)
+
+	login(cl)
+	for i := 0; i < maxTwoFactorAttempts; i++ {
+		cl.mr.FastForward(30 * time.Second) // past the account's login delay
+		code, _ := cl.do("POST", "/verify-2fa", map[string]string{"code": "000000"})
+		require.Equal(t, 401, code)
+	}
+	next, _ := totp.CodeAt(secret, totp.Step(time.Now())+1)
+	code, out := cl.do("POST", "/verify-2fa", map[string]string{"code": next})
+	assert.Equal(t, 401, code)
+	assert.Equal(t, "No pending two-factor login", out["error"].(map[string]interface{})["message"])
+}
+
+func TestTwoFactor_OrgPolicyGatesTrading(t *testing.T) {
+	cl, _ := setupLoginApp(t, true)
+	code, _ := login(cl)
+	require.Equal(t, 200, code)
+
+	code, out := cl.do("POST", "/buy", nil)
+	assert.Equal(t, 403, code)
+	assert.Equal(t, "Your organization requires two-factor authentication for this action", out["error"].(map[string]interface{})["message"])
+
+	// Enrolling proves the second factor for this session.
+	_, recovery := enrol(t, cl)
+	code, _ = cl.do("POST", "/buy", nil)
+	assert.Equal(t, 200, code)
+
+	code, _ = cl.do("POST", "/disable-2fa", map[string]string{"recovery_code": recovery[0].(string)})
+	assert.Equal(t, 403, code)
+}
+
+func TestTwoFactor_SecretStoredEncrypted(t *testing.T) {
+	cl, db := setupLoginApp(t, false)
+	code, _ := login(cl)
+	require.Equal(t, 200, code)
+	secret, _ := enrol(t, cl)
+
+	var tf domain.UserTwoFactor
+	require.NoError(t, db.First(&tf).Error)
+	assert.True(t, secretbox.IsSealed(tf.Secret))
+	assert.NotContains(t, tf.Secret, secret)
+}
+
+func TestTwoFactor_WrongCodesCountTowardsAccountThrottle(t *testing.T) {
+	cl, _ := setupLoginApp(t, false)
+	code, _ := login(cl)
+	require.Equal(t, 200, code)
+	enrol(t, cl)
+
+	// The right password alone does not clear the account's failures; three wrong codes start the login delay.
+	code, out := login(cl)
+	require.Equal(t, 200, code)
+	require.Equal(t, true, out["data"].(map[string]interface{})["two_factor_required"])
+	for i := 0; i < 3; i++ {
+		code, _ = cl.do("POST", "/verify-2fa", map[string]string{"code": "000000"})
+		require.Equal(t, 401, code)
+	}
+	code, out = login(cl)
+	assert.Equal(t, 429, code, out)
+}
@@ -20,7 +20,7 @@ import (
 func setupHoldingsTest(t *testing.T) (*Handlers, *gorm.DB) {
 	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
 	require.NoError(t, err)
-	require.NoError(t, db.AutoMigrate(&domain.Org{}, &domain.User{}, &domain.Holding{}, &domain.IcrProject{}))
+	require.NoError(t, db.AutoMigrate(&domain.Org{}, &domain.User{}, &domain.OrgMembership{}, &domain.Holding{}, &domain.IcrProject{}))
 	svc := &holdsvc.Service{DB: db}
 	h := &Handlers{Service: svc}
 	return h, db
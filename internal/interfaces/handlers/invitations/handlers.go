@@ -1,7 +1,9 @@
 package invitations
 
 import (
+	auditsvc "troo-backend/internal/application/audit"
 	invsvc "troo-backend/internal/application/invitations"
+	"troo-backend/internal/domain"
 	"troo-backend/internal/middleware"
 	"troo-backend/internal/pkg/response"
 
@@ -11,6 +13,7 @@ import (
 type Handlers struct {
 	Service *invsvc.Service
 	Config  middleware.SessionConfig
+	Audit   *auditsvc.Service
 }
 
 // POST /api/v1/invitations/create-invite (INVITE_USER permission via middleware)
@@ -42,6 +45,7 @@ func (h *Handlers) SendInvite(c *fiber.Ctx) error {
 	if err != nil {
 		return response.Error(c, err.Error(), 400, nil)
 	}
+	h.auditInvite(c, auditsvc.ActionInviteSent, inv)
 	return response.Success(c, "Invitation sent successfully", inv, nil)
 }
 
@@ -66,6 +70,7 @@ func (h *Handlers) ResendInvite(c *fiber.Ctx) error {
 	if err != nil {
 		return response.Error(c, err.Error(), 400, nil)
 	}
+	h.auditInvite(c, auditsvc.ActionInviteResent, inv)
 	return response.Success(c, "Invitation resent successfully", inv, nil)
 }
 
@@ -82,6 +87,10 @@ func (h *Handlers) AcceptInvite(c *fiber.Ctx) error {
 	if actor == nil {
 		return response.Unauthorized(c, "Unauthorized")
 	}
+	// The session would move to the inviting org
+	if middleware.SSOOrgID(middleware.GetUser(c)) != "" {
+		return response.Error(c, middleware.ErrSSOSessionBound.Error(), 403, nil)
+	}
 
 	result, err := h.Service.AcceptInvite(c.Context(), invsvc.AcceptInviteInput{
 		Token:  body.Token,
@@ -92,18 +101,31 @@ func (h *Handlers) AcceptInvite(c *fiber.Ctx) error {
 	}
 
 	// Regenerate session (Express: req.session.regenerate, set org_id + role)
+	verified := middleware.EmailVerified(middleware.GetUser(c))
+	sessionUser, _ := middleware.GetUser(c).(map[string]interface{})
+	twoFactor, _ := sessionUser["two_factor"].(bool)
 	sid := middleware.RegenerateSessionID(c)
 	middleware.SetSessionUser(c, middleware.SessionUser{
-		UserID:   actor.UserID,
-		Fullname: actor.Fullname,
-		Email:    actor.Email,
-		Role:     result.Role,
-		OrgID:    &result.OrgID,
+		UserID:            actor.UserID,
+		Fullname:          actor.Fullname,
+		Email:             actor.Email,
+		Role:              result.Role,
+		OrgID:             &result.OrgID,
+		EmailVerified:     verified,
+		TwoFactor:         twoFactor,
+		TwoFactorRequired: result.RequireTwoFactor,
+		AuthMethod:        middleware.AuthMethodPassword,
 	})
 	cookie := middleware.SessionCookieConfig(h.Config)
-	cookie.Value = "s:" + sid
+	cookie.Value = middleware.SessionCookieValue(h.Config, sid)
 	c.Cookie(&cookie)
 
+	middleware.Audit(c, h.Audit, auditsvc.Entry{
+		Action:     auditsvc.ActionInviteAccepted,
+		TargetType: "user",
+		TargetID:   actor.UserID,
+		After:      fiber.Map{"email": actor.Email, "role": result.Role, "org_id": result.OrgID},
+	})
 	return response.Success(c, "Invitation accepted successfully", result, nil)
 }
 
@@ -128,6 +150,7 @@ func (h *Handlers) RevokeInvite(c *fiber.Ctx) error {
 	if err != nil {
 		return response.Error(c, err.Error(), 400, nil)
 	}
+	h.auditInvite(c, auditsvc.ActionInviteRevoked, inv)
 	return response.Success(c, "Invitation revoked successfully", inv, nil)
 }
 
@@ -165,6 +188,16 @@ func (h *Handlers) CheckToken(c *fiber.Ctx) error {
 	return response.Success(c, "Invitation token verified", result, nil)
 }
 
+// auditInvite records an invitation change; the invite token is never written to the audit log.
+func (h *Handlers) auditInvite(c *fiber.Ctx, action string, inv *domain.Invitation) {
+	middleware.Audit(c, h.Audit, auditsvc.Entry{
+		Action:     action,
+		TargetType: "invitation",
+		TargetID:   inv.InviteID.String(),
+		After:      fiber.Map{"email": inv.Email, "role": inv.Role, "status": inv.Status, "expires_at": inv.ExpiresAt},
+	})
+}
+
 type actorInfo struct {
 	UserID   string
 	Fullname string
@@ -22,7 +22,7 @@ import (
 func setupInvitationsTest(t *testing.T) (*Handlers, *invsvc.Service, *gorm.DB) {
 	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
 	require.NoError(t, err)
-	require.NoError(t, db.AutoMigrate(&domain.User{}, &domain.Org{}, &domain.Invitation{}))
+	require.NoError(t, db.AutoMigrate(&domain.User{}, &domain.OrgMembership{}, &domain.Org{}, &domain.Invitation{}))
 	svc := &invsvc.Service{DB: db}
 	h := &Handlers{
 		Service: svc,
@@ -0,0 +1,150 @@
+package limits
+
+import (
+	limitsvc "troo-backend/internal/application/limits"
+	"troo-backend/internal/middleware"
+	"troo-backend/internal/pkg/decimal"
+	"troo-backend/internal/pkg/response"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type Handlers struct {
+	Service *limitsvc.Service
+}
+
+// GET /api/v1/trading-limits/view-limits
+func (h *Handlers) ViewLimits(c *fiber.Ctx) error {
+	_, orgID, _, ok := actor(c)
+	if !ok {
+		return response.Error(c, "User is not associated with an organization", 403, nil)
+	}
+	out, err := h.Service.ListLimits(c.Context(), orgID)
+	if err != nil {
+		return response.Error(c, "Internal Server Error", 500, nil)
+	}
+	return response.Success(c, "Trading limits fetched successfully", out, nil)
+}
+
+// GET /api/v1/trading-limits/view-my-limit — the limit applied to the session user (null when unlimited).
+func (h *Handlers) ViewMyLimit(c *fiber.Ctx) error {
+	userID, orgID, role, ok := actor(c)
+	if !ok {
+		return response.Error(c, "User is not associated with an organization", 403, nil)
+	}
+	limit, err := h.Service.EffectiveLimit(c.Context(), orgID, userID, role)
+	if err != nil {
+		return response.Error(c, "Internal Server Error", 500, nil)
+	}
+	return response.Success(c, "Trading limit fetched successfully", limit, nil)
+}
+
+// PUT /api/v1/trading-limits/set-limit — body has either user_id or role; null caps are unlimited.
+func (h *Handlers) SetLimit(c *fiber.Ctx) error {
+	userID, orgID, role, ok := actor(c)
+	if !ok {
+		return response.Error(c, "User is not associated with an organization", 403, nil)
+	}
+	var body struct {
+		UserID                 *string          `json:"user_id"`
+		Role                   *string          `json:"role"`
+		MaxCreditsPerTrade     *decimal.Decimal `json:"max_credits_per_trade"`
+		MaxDailySpend          *decimal.Decimal `json:"max_daily_spend"`
+		MaxDailyTransferVolume *decimal.Decimal `json:"max_daily_transfer_volume"`
+		AllowedProjectIDs      []string         `json:"allowed_project_ids"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return response.Error(c, "Invalid request body", 400, nil)
+	}
+
+	in := limitsvc.LimitInput{
+		OrgID:                  orgID,
+		UpdatedBy:              userID,
+		UpdatedByRole:          role,
+		Role:                   body.Role,
+		MaxCreditsPerTrade:     body.MaxCreditsPerTrade,
+		MaxDailySpend:          body.MaxDailySpend,
+		MaxDailyTransferVolume: body.MaxDailyTransferVolume,
+	}
+	if body.UserID != nil {
+		id, err := uuid.Parse(*body.UserID)
+		if err != nil {
+			return response.Error(c, "Invalid user_id", 400, nil)
+		}
+		in.UserID = &id
+	}
+	if body.AllowedProjectIDs != nil {
+		in.AllowedProjectIDs = make([]uuid.UUID, 0, len(body.AllowedProjectIDs))
+		for _, s := range body.AllowedProjectIDs {
+			id, err := uuid.Parse(s)
+			if err != nil {
+				return response.Error(c, "Invalid project id in allowed_project_ids", 400, nil)
+			}
+			in.AllowedProjectIDs = append(in.AllowedProjectIDs, id)
+		}
+	}
+
+	limit, err := h.Service.SetLimit(c.Context(), in)
+	if err != nil {
+		statusMap := map[string]int{
+			"Exactly one of user_id or role is required": 400,
+			"Invalid limit value":                        400,
+			"Invalid role":                               400,
+			"User not found in organization":             404,
+			"You cannot change your own trading limit":   403,
+		}
+		if code, ok := statusMap[err.Error()]; ok {
+			return response.Error(c, err.Error(), code, nil)
+		}
+		return response.Error(c, "Internal Server Error", 500, nil)
+	}
+	return response.Success(c, "Trading limit saved successfully", limit, nil)
+}
+
+// DELETE /api/v1/trading-limits/remove-limit
+func (h *Handlers) RemoveLimit(c *fiber.Ctx) error {
+	userID, orgID, role, ok := actor(c)
+	if !ok {
+		return response.Error(c, "User is not associated with an organization", 403, nil)
+	}
+	var body struct {
+		LimitID string `json:"limit_id"`
+	}
+	if err := c.BodyParser(&body); err != nil || body.LimitID == "" {
+		return response.Error(c, "limit_id is required", 400, nil)
+	}
+	limitID, err := uuid.Parse(body.LimitID)
+	if err != nil {
+		return response.Error(c, "Invalid limit_id", 400, nil)
+	}
+	if err := h.Service.RemoveLimit(c.Context(), orgID, limitID, userID, role); err != nil {
+		switch err.Error() {
+		case "Trading limit not found":
+			return response.Error(c, err.Error(), 404, nil)
+		case "You cannot change your own trading limit":
+			return response.Error(c, err.Error(), 403, nil)
+		}
+		return response.Error(c, "Internal Server Error", 500, nil)
+	}
+	return response.Success(c, "Trading limit removed successfully", nil, nil)
+}
+
+// actor returns the session user's id, org id and role; ok is false when user or org is missing.
+func actor(c *fiber.Ctx) (userID, orgID uuid.UUID, role string, ok bool) {
+	m, isMap := middleware.GetUser(c).(map[string]interface{})
+	if !isMap {
+		return uuid.Nil, uuid.Nil, "", false
+	}
+	userStr, _ := m["user_id"].(string)
+	orgStr, _ := m["org_id"].(string)
+	role, _ = m["role"].(string)
+	var err error
+	if userID, err = uuid.Parse(userStr); err != nil {
+		return uuid.Nil, uuid.Nil, "", false
+	}
+	if orgID, err = uuid.Parse(orgStr); err != nil {
+		return uuid.Nil, uuid.Nil, "", false
+	}
+	return userID, orgID, role, true
+}
@@ -0,0 +1,250 @@
+package limits
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	limitsvc "troo-backend/internal/application/limits"
+	tradesvc "troo-backend/internal/application/trading"
+	"troo-backend/internal/application/verification"
+	"troo-backend/internal/domain"
+	tradehandler "troo-backend/internal/interfaces/handlers/trading"
+	"troo-backend/internal/pkg/decimal"
+
+	"github.com/glebarez/sqlite"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type fakeStripe struct{}
+
+func (f *fakeStripe) Create(amountCents int64, currency string, metadata map[string]string) (*tradehandler.StripePaymentIntentResult, error) {
+	return &tradehandler.StripePaymentIntentResult{ID: "pi_test", ClientSecret: "secret"}, nil
+}
+
+type limitsFixture struct {
+	db        *gorm.DB
+	app       *fiber.App
+	orgID     uuid.UUID
+	target    domain.Org
+	projectID uuid.UUID
+	manager   uuid.UUID
+	admin     uuid.UUID
+}
+
+// setupLimitsTest mounts trading and limit routes; X-Test-User / X-Test-Role set the session user.
+func setupLimitsTest(t *testing.T) *limitsFixture {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(
+		&domain.User{}, &domain.OrgMembership{}, &domain.Listing{}, &domain.Holding{}, &domain.Org{},
+		&domain.Transaction{}, &domain.RetirementCertificate{},
+		&domain.TradingLimit{}, &domain.TradingLimitUsage{}, &domain.OrgVerification{}, &domain.SubAccount{}, &domain.SubAccountHolding{},
+	))
+	f := &limitsFixture{db: db, orgID: uuid.New(), projectID: uuid.New(), manager: uuid.New(), admin: uuid.New()}
+	require.NoError(t, db.Create(&domain.Org{OrgID: f.orgID, OrgName: "Buyer", OrgCode: "BU-000001", CountryCode: "SG"}).Error)
+	f.target = domain.Org{OrgID: uuid.New(), OrgName: "Target", OrgCode: "TA-000001", CountryCode: "SG"}
+	require.NoError(t, db.Create(&f.target).Error)
+	require.NoError(t, db.Create(&domain.OrgVerification{OrgID: f.orgID, Status: verification.StatusVerified}).Error)
+	require.NoError(t, db.Create(&domain.User{UserID: f.manager, Fullname: "Mia Manager", UserName: "mia", Email: "mia@example.com", PasswordHash: "x", OrgID: &f.orgID, Role: "manager"}).Error)
+	require.NoError(t, db.Create(&domain.Holding{OrgID: f.orgID, ProjectID: f.projectID, CreditBalance: decimal.NewFromInt(100)}).Error)
+
+	ls := &limitsvc.Service{DB: db}
+	th := &tradehandler.Handlers{Service: &tradesvc.Service{DB: db}, StripeCreator: &fakeStripe{}, Limits: ls}
+	h := &Handlers{Service: ls}
+
+	f.app = fiber.New()
+	f.app.Use(func(c *fiber.Ctx) error {
+		c.Locals("user", map[string]interface{}{
+			"user_id": c.Get("X-Test-User"),
+			"org_id":  f.orgID.String(),
+			"role":    c.Get("X-Test-Role"),
+		})
+		return c.Next()
+	})
+	f.app.Post("/buy-credits", th.BuyCredits)
+	f.app.Post("/transfer-credits", th.TransferCredits)
+	f.app.Post("/retire-credits", th.RetireCredits)
+	f.app.Put("/set-limit", h.SetLimit)
+	f.app.Delete("/remove-limit", h.RemoveLimit)
+	f.app.Get("/view-my-limit", h.ViewMyLimit)
+	return f
+}
+
+func (f *limitsFixture) do(t *testing.T, method, path string, user uuid.UUID, role string, body interface{}) (int, map[string]interface{}) {
+	b, _ := json.Marshal(body)
+	req := httptest.NewRequest(method, path, bytes.NewReader(b))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Test-User", user.String())
+	req.Header.Set("X-Test-Role", role)
+	resp, err := f.app.Test(req)
+	require.NoError(t, err)
+	var result map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&result)
+	return resp.StatusCode, result
+}
+
+func errMessage(result map[string]interface{}) interface{} {
+	e, _ := result["error"].(map[string]interface{})
+	return e["message"]
+}
+
+func TestRoleLimit_PerTradeAndDailyTransferVolume(t *testing.T) {
+	f := setupLimitsTest(t)
+	code, _ := f.do(t, "PUT", "/set-limit", f.admin, "admin", map[string]interface{}{
+		"role": "manager", "max_credits_per_trade": 10, "max_daily_transfer_volume": 15,
+	})
+	require.Equal(t, 200, code)
+
+	transfer := func(amount float64) (int, map[string]interface{}) {
+		return f.do(t, "POST", "/transfer-credits", f.manager, "manager", map[string]interface{}{
+			"to_org_code": f.target.OrgCode, "project_id": f.projectID.String(), "amount": amount,
+		})
+	}
+
+	code, result := transfer(11)
+	assert.Equal(t, 403, code)
+	assert.Equal(t, "Amount exceeds your per-trade limit", errMessage(result))
+
+	code, _ = transfer(10)
+	assert.Equal(t, 200, code)
+	code, result = transfer(6)
+	assert.Equal(t, 403, code)
+	assert.Equal(t, "Daily transfer volume limit exceeded", errMessage(result))
+	code, _ = transfer(5)
+	assert.Equal(t, 200, code)
+
+	// Admins are not covered by the manager limit.
+	code, _ = f.do(t, "POST", "/transfer-credits", f.admin, "admin", map[string]interface{}{
+		"to_org_code": f.target.OrgCode, "project_id": f.projectID.String(), "amount": 50,
+	})
+	assert.Equal(t, 200, code)
+}
+
+func TestFailedTradeReleasesReservation(t *testing.T) {
+	f := setupLimitsTest(t)
+	f.do(t, "PUT", "/set-limit", f.admin, "admin", map[string]interface{}{"role": "manager", "max_daily_transfer_volume": 15})
+
+	code, _ := f.do(t, "POST", "/transfer-credits", f.manager, "manager", map[string]interface{}{
+		"to_org_code": "XX-999999", "project_id": f.projectID.String(), "amount": 15,
+	})
+	assert.Equal(t, 404, code)
+
+	code, _ = f.do(t, "POST", "/transfer-credits", f.manager, "manager", map[string]interface{}{
+		"to_org_code": f.target.OrgCode, "project_id": f.projectID.String(), "amount": 15,
+	})
+	assert.Equal(t, 200, code)
+}
+
+func TestDailyTotals_CountOnlyTheLimitsOrg(t *testing.T) {
+	f := setupLimitsTest(t)
+	f.do(t, "PUT", "/set-limit", f.admin, "admin", map[string]interface{}{"role": "manager", "max_daily_transfer_volume": 15})
+
+	// The manager used up a 15 cap in another org they belong to today.
+	require.NoError(t, f.db.Create(&domain.TradingLimitUsage{
+		OrgID: uuid.New(), UserID: f.manager, Kind: limitsvc.KindTransfer, ProjectID: f.projectID, Amount: decimal.NewFromInt(15),
+	}).Error)
+
+	code, result := f.do(t, "POST", "/transfer-credits", f.manager, "manager", map[string]interface{}{
+		"to_org_code": f.target.OrgCode, "project_id": f.projectID.String(), "amount": 15,
+	})
+	assert.Equal(t, 200, code, result)
+	code, result = f.do(t, "POST", "/transfer-credits", f.manager, "manager", map[string]interface{}{
+		"to_org_code": f.target.OrgCode, "project_id": f.projectID.String(), "amount": 1,
+	})
+	assert.Equal(t, 403, code)
+	assert.Equal(t, "Daily transfer volume limit exceeded", errMessage(result))
+}
+
+func TestUserLimit_OverridesRoleAndRestrictsProjects(t *testing.T) {
+	f := setupLimitsTest(t)
+	f.do(t, "PUT", "/set-limit", f.admin, "admin", map[string]interface{}{"role": "manager", "max_credits_per_trade": 1})
+	code, _ := f.do(t, "PUT", "/set-limit", f.admin, "admin", map[string]interface{}{
+		"user_id": f.manager.String(), "max_daily_spend": 100, "allowed_project_ids": []string{f.projectID.String()},
+	})
+	require.Equal(t, 200, code)
+
+	code, result := f.do(t, "GET", "/view-my-limit", f.manager, "manager", nil)
+	require.Equal(t, 200, code)
+	assert.Equal(t, f.manager.String(), result["data"].(map[string]interface{})["user_id"])
+
+	listing := domain.Listing{ProjectID: f.projectID, CreditsAvailable: decimal.NewFromInt(50), PricePerCredit: decimal.RequireFromString("7.5"), Status: "open"}
+	require.NoError(t, f.db.Create(&listing).Error)
+	other := domain.Listing{ProjectID: uuid.New(), CreditsAvailable: decimal.NewFromInt(50), PricePerCredit: decimal.NewFromInt(1), Status: "open"}
+	require.NoError(t, f.db.Create(&other).Error)
+
+	buy := func(listingID uuid.UUID, amount float64) (int, map[string]interface{}) {
+		return f.do(t, "POST", "/buy-credits", f.manager, "manager", map[string]interface{}{"listing_id": listingID.String(), "amount": amount})
+	}
+
+	// 10 x 7.5 = 75 fits; another 4 x 7.5 = 30 would make 105.
+	code, _ = buy(listing.ListingID, 10)
+	assert.Equal(t, 200, code)
+	code, result = buy(listing.ListingID, 4)
+	assert.Equal(t, 403, code)
+	assert.Equal(t, "Daily spend limit exceeded", errMessage(result))
+
+	code, result = buy(other.ListingID, 1)
+	assert.Equal(t, 403, code)
+	assert.Equal(t, "Project not allowed by your trading limits", errMessage(result))
+}
+
+func TestSetLimit_Validation(t *testing.T) {
+	f := setupLimitsTest(t)
+	code, _ := f.do(t, "PUT", "/set-limit", f.admin, "admin", map[string]interface{}{"max_credits_per_trade": 1})
+	assert.Equal(t, 400, code)
+	code, _ = f.do(t, "PUT", "/set-limit", f.admin, "admin", map[string]interface{}{"role": "trader"})
+	assert.Equal(t, 400, code)
+	code, _ = f.do(t, "PUT", "/set-limit", f.admin, "admin", map[string]interface{}{"user_id": uuid.New().String()})
+	assert.Equal(t, 404, code)
+	code, _ = f.do(t, "PUT", "/set-limit", f.admin, "admin", map[string]interface{}{"role": "manager", "max_daily_spend": -1})
+	assert.Equal(t, 400, code)
+}
+
+func TestLimits_CannotChangeOwnLimit(t *testing.T) {
+	f := setupLimitsTest(t)
+	code, result := f.do(t, "PUT", "/set-limit", f.admin, "admin", map[string]interface{}{"role": "admin", "max_credits_per_trade": 1000})
+	assert.Equal(t, 403, code)
+	assert.Equal(t, "You cannot change your own trading limit", errMessage(result))
+	code, _ = f.do(t, "PUT", "/set-limit", f.manager, "manager", map[string]interface{}{"user_id": f.manager.String()})
+	assert.Equal(t, 403, code)
+
+	role := "admin"
+	own := domain.TradingLimit{OrgID: f.orgID, Role: &role, MaxCreditsPerTrade: ptr(decimal.NewFromInt(1))}
+	require.NoError(t, f.db.Create(&own).Error)
+	code, _ = f.do(t, "DELETE", "/remove-limit", f.admin, "admin", map[string]interface{}{"limit_id": own.LimitID.String()})
+	assert.Equal(t, 403, code)
+	code, _ = f.do(t, "DELETE", "/remove-limit", f.manager, "superadmin", map[string]interface{}{"limit_id": own.LimitID.String()})
+	assert.Equal(t, 200, code)
+}
+
+func TestBuyReservation_StopsCountingWhenPaymentExpires(t *testing.T) {
+	f := setupLimitsTest(t)
+	f.do(t, "PUT", "/set-limit", f.admin, "admin", map[string]interface{}{"role": "manager", "max_daily_spend": 100})
+	listing := domain.Listing{ProjectID: f.projectID, CreditsAvailable: decimal.NewFromInt(50), PricePerCredit: decimal.NewFromInt(10), Status: "open"}
+	require.NoError(t, f.db.Create(&listing).Error)
+	buy := func() int {
+		code, _ := f.do(t, "POST", "/buy-credits", f.manager, "manager", map[string]interface{}{"listing_id": listing.ListingID.String(), "amount": 10})
+		return code
+	}
+
+	require.Equal(t, 200, buy())
+	var usage domain.TradingLimitUsage
+	require.NoError(t, f.db.First(&usage).Error)
+	require.NotNil(t, usage.PaymentIntentID)
+	assert.Equal(t, "pi_test", *usage.PaymentIntentID)
+	require.NotNil(t, usage.ExpiresAt)
+	assert.Equal(t, 403, buy())
+
+	// The PaymentIntent was never paid: once the hold lapses the spend no longer counts.
+	require.NoError(t, f.db.Model(&usage).Update("expires_at", time.Now().Add(-time.Minute)).Error)
+	assert.Equal(t, 200, buy())
+}
+
+func ptr[T any](v T) *T { return &v }
@@ -1,14 +1,17 @@
 package listings
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"strconv"
 	"strings"
 
+	auditsvc "troo-backend/internal/application/audit"
 	listsvc "troo-backend/internal/application/listings"
 	"troo-backend/internal/domain"
 	"troo-backend/internal/middleware"
+	"troo-backend/internal/pkg/decimal"
 	"troo-backend/internal/pkg/response"
 
 	"github.com/gofiber/fiber/v2"
@@ -17,12 +20,16 @@ import (
 
 type Handlers struct {
 	Service *listsvc.Service
+	Audit   *auditsvc.Service
 }
 
 // POST /api/v1/listings/create-listing — 201 with { status, message, data }
 func (h *Handlers) CreateListing(c *fiber.Ctx) error {
+	// UseNumber keeps credits_available/price_per_credit as written instead of rounding through float64.
 	var body map[string]interface{}
-	if err := json.Unmarshal(c.Body(), &body); err != nil {
+	dec := json.NewDecoder(bytes.NewReader(c.Body()))
+	dec.UseNumber()
+	if err := dec.Decode(&body); err != nil {
 		return response.Error(c, "Invalid request body", 400, nil)
 	}
 
@@ -56,8 +63,8 @@ func (h *Handlers) CreateListing(c *fiber.Ctx) error {
 	listing, err := h.Service.CreateListing(c.Context(), listsvc.CreateListingInput{
 		ProjectID:        projectID,
 		SellerID:         sellerID,
-		CreditsAvailable: asFloat(body["credits_available"]),
-		PricePerCredit:   asFloat(body["price_per_credit"]),
+		CreditsAvailable: asDecimal(body["credits_available"]),
+		PricePerCredit:   asDecimal(body["price_per_credit"]),
 		ExternalTradeID:  extID,
 		ProjectName:      asString(body["project_name"]),
 		ProjectStartYear: asInt(body["project_start_year"]),
@@ -71,10 +78,19 @@ func (h *Handlers) CreateListing(c *fiber.Ctx) error {
 		SdgNumbers:       asJSONSliceString(body["sdg_numbers"], "[]"),
 		Methodology:      asString(body["methodology"]),
 		VintageYear:      asInt(body["vintage_year"]),
+		ActorUserID:      actorUserID(c),
 	})
 	if err != nil {
 		return response.Error(c, err.Error(), 500, nil)
 	}
+	// Registry listings are created by platform operators; file them under the seller org, not the operator's.
+	middleware.AuditPlatform(c, h.Audit, auditsvc.Entry{
+		OrgID:      sellerID,
+		Action:     auditsvc.ActionListingCreated,
+		TargetType: "listing",
+		TargetID:   listing.ListingID.String(),
+		After:      listingState(listing),
+	})
 	return response.SuccessCreated(c, "Listing created successfully", listingToMapNoTimestamps(listing), nil)
 }
 
@@ -175,9 +191,9 @@ func (h *Handlers) GetOrgClosedListings(c *fiber.Ctx) error {
 // PUT /api/v1/listings/edit-listing
 func (h *Handlers) EditListing(c *fiber.Ctx) error {
 	var body struct {
-		ListingID string   `json:"listing_id"`
-		Price     *float64 `json:"price"`
-		Quantity  *float64 `json:"quantity"`
+		ListingID string           `json:"listing_id"`
+		Price     *decimal.Decimal `json:"price"`
+		Quantity  *decimal.Decimal `json:"quantity"`
 	}
 	if err := c.BodyParser(&body); err != nil {
 		return response.Error(c, "listing_id, price and quantity are required", 400, nil)
@@ -194,9 +210,14 @@ func (h *Handlers) EditListing(c *fiber.Ctx) error {
 		return response.Error(c, "Invalid listing_id", 400, nil)
 	}
 
+	var before interface{}
+	if prev, err := h.Service.GetListingByID(c.Context(), listingID); err == nil {
+		before = fiber.Map{"price_per_credit": prev.PricePerCredit, "credits_available": prev.CreditsAvailable}
+	}
 	result, err := h.Service.EditListing(c.Context(), listsvc.EditListingInput{
 		ListingID:   listingID,
 		OrgID:       orgID,
+		ActorUserID: actorUserID(c),
 		NewPrice:    body.Price,
 		NewQuantity: body.Quantity,
 	})
@@ -212,6 +233,7 @@ func (h *Handlers) EditListing(c *fiber.Ctx) error {
 			"Registry listings cannot be edited by User":    403,
 			"Insufficient credits to increase listing":     400,
 			"Cannot reduce listing below already sold amount": 400,
+			"Organization must be verified to sell or transfer credits": 403,
 			"Holdings not found":                            404,
 			"Org not found":                                 404,
 		}
@@ -223,6 +245,13 @@ func (h *Handlers) EditListing(c *fiber.Ctx) error {
 		}
 		return response.Error(c, "Internal Server Error", 500, nil)
 	}
+	middleware.Audit(c, h.Audit, auditsvc.Entry{
+		Action:     auditsvc.ActionListingEdited,
+		TargetType: "listing",
+		TargetID:   listingID.String(),
+		Before:     before,
+		After:      listingState(result),
+	})
 	return response.Success(c, "Listing updated successfully", listingToMapNoTimestamps(result), nil)
 }
 
@@ -243,7 +272,7 @@ func (h *Handlers) CancelListing(c *fiber.Ctx) error {
 		return response.Error(c, "Invalid listing_id", 400, nil)
 	}
 
-	result, err := h.Service.CancelListing(c.Context(), listingID, orgID)
+	result, err := h.Service.CancelListing(c.Context(), actorUserID(c), listingID, orgID)
 	if err != nil {
 		statusMap := map[string]int{
 			"Listing not found":                   404,
@@ -257,11 +286,38 @@ func (h *Handlers) CancelListing(c *fiber.Ctx) error {
 		}
 		return response.Error(c, "Internal Server Error", 500, nil)
 	}
+	middleware.Audit(c, h.Audit, auditsvc.Entry{
+		Action:     auditsvc.ActionListingCancelled,
+		TargetType: "listing",
+		TargetID:   listingID.String(),
+		After:      listingState(result),
+	})
 	return response.Success(c, "Listing cancelled successfully", listingToMapNoTimestamps(result), nil)
 }
 
 // --- helpers ---
 
+// listingState is the audited view of a listing.
+func listingState(l *domain.Listing) fiber.Map {
+	return fiber.Map{
+		"project_id":        l.ProjectID,
+		"price_per_credit":  l.PricePerCredit,
+		"credits_available": l.CreditsAvailable,
+		"status":            l.Status,
+	}
+}
+
+// actorUserID is the session user recorded on listing events; nil when absent.
+func actorUserID(c *fiber.Ctx) *uuid.UUID {
+	m, _ := middleware.GetUser(c).(map[string]interface{})
+	s, _ := m["user_id"].(string)
+	id, err := uuid.Parse(s)
+	if err != nil {
+		return nil
+	}
+	return &id
+}
+
 func actorOrgID(c *fiber.Ctx) (uuid.UUID, error) {
 	user := middleware.GetUser(c)
 	if user == nil {
@@ -315,21 +371,27 @@ func asJSONSliceString(v interface{}, def string) string {
 	return string(bs)
 }
 
-func asFloat(v interface{}) float64 {
+// asDecimal parses a credit amount or price from the JSON body, rounded to the column scale.
+func asDecimal(v interface{}) decimal.Decimal {
+	var d decimal.Decimal
 	switch x := v.(type) {
+	case json.Number:
+		d, _ = decimal.NewFromString(x.String())
+	case string:
+		d, _ = decimal.NewFromString(x)
 	case float64:
-		return x
+		d = decimal.NewFromFloat(x)
 	case int:
-		return float64(x)
-	case string:
-		f, _ := strconv.ParseFloat(x, 64)
-		return f
+		d = decimal.NewFromInt(int64(x))
 	}
-	return 0
+	return d.Round(domain.CreditScale)
 }
 
 func asInt(v interface{}) int {
 	switch x := v.(type) {
+	case json.Number:
+		f, _ := x.Float64()
+		return int(f)
 	case float64:
 		return int(x)
 	case int:
@@ -364,15 +426,7 @@ func listingToMapNoTimestamps(l *domain.Listing) map[string]interface{} {
 	delete(m, "createdAt")
 	delete(m, "updatedAt")
 	// Match Express: DECIMAL fields come back as strings in JSON
-	if v, ok := m["credits_available"]; ok {
-		if f, ok := v.(float64); ok {
-			m["credits_available"] = fmt.Sprintf("%.2f", f)
-		}
-	}
-	if v, ok := m["price_per_credit"]; ok {
-		if f, ok := v.(float64); ok {
-			m["price_per_credit"] = fmt.Sprintf("%.2f", f)
-		}
-	}
+	m["credits_available"] = l.CreditsAvailable.StringFixed(domain.CreditScale)
+	m["price_per_credit"] = l.PricePerCredit.StringFixed(domain.CreditScale)
 	return m
 }
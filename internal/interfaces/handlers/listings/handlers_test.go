@@ -7,10 +7,13 @@ import (
 	"testing"
 
 	listsvc "troo-backend/internal/application/listings"
+	"troo-backend/internal/application/verification"
 	"troo-backend/internal/domain"
+	"troo-backend/internal/pkg/decimal"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/glebarez/sqlite"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"gorm.io/gorm"
@@ -52,8 +55,9 @@ func TestCreateListing_MissingField(t *testing.T) {
 
 	var result map[string]interface{}
 	json.NewDecoder(resp.Body).Decode(&result)
-	assert.Equal(t, false, result["success"])
-	assert.Equal(t, "Missing required field: project_id", result["message"])
+	assert.Equal(t, "error", result["status"])
+	errObj, _ := result["error"].(map[string]interface{})
+	assert.Equal(t, "Missing required field: project_id", errObj["message"])
 }
 
 func TestGetAllListings_EmptyDB(t *testing.T) {
@@ -68,7 +72,7 @@ func TestGetAllListings_EmptyDB(t *testing.T) {
 
 	var result map[string]interface{}
 	json.NewDecoder(resp.Body).Decode(&result)
-	assert.Equal(t, true, result["success"])
+	assert.Equal(t, "success", result["status"])
 	assert.Equal(t, "Listings fetched successfully", result["message"])
 }
 
@@ -117,3 +121,71 @@ func TestCancelListing_InvalidID(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, 400, resp.StatusCode)
 }
+
+func TestEditListing_RoundsToCreditScale(t *testing.T) {
+	h, db := setupListingsTest(t)
+	require.NoError(t, db.AutoMigrate(&domain.Org{}, &domain.SubAccountHolding{}, &domain.ListingEvent{}, &domain.OrgVerification{}))
+	org := domain.Org{OrgName: "Seller", OrgCode: "SE-000001", CountryCode: "SG"}
+	require.NoError(t, db.Create(&org).Error)
+	require.NoError(t, db.Create(&domain.OrgVerification{OrgID: org.OrgID, Status: verification.StatusVerified}).Error)
+	projectID := uuid.New()
+	require.NoError(t, db.Create(&domain.Holding{OrgID: org.OrgID, ProjectID: projectID, CreditBalance: decimal.NewFromInt(100), LockedForSale: decimal.NewFromInt(10)}).Error)
+	listing := domain.Listing{ProjectID: projectID, SellerID: &org.OrgID, CreditsAvailable: decimal.NewFromInt(10), PricePerCredit: decimal.NewFromInt(5), Status: "open", SdgNumbers: domain.SDGNumbers("[]")}
+	require.NoError(t, db.Create(&listing).Error)
+
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("user", map[string]interface{}{"user_id": uuid.NewString(), "role": "admin", "org_id": org.OrgID.String()})
+		return c.Next()
+	})
+	app.Put("/edit-listing", h.EditListing)
+	body := []byte(`{"listing_id":"` + listing.ListingID.String() + `","price":5.129,"quantity":12.345}`)
+	req := httptest.NewRequest("PUT", "/edit-listing", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	require.Equal(t, 200, resp.StatusCode)
+
+	require.NoError(t, db.First(&listing, "listing_id = ?", listing.ListingID).Error)
+	assert.Equal(t, "5.13", listing.PricePerCredit.String())
+	assert.Equal(t, "12.35", listing.CreditsAvailable.String())
+	var holding domain.Holding
+	require.NoError(t, db.First(&holding, "org_id = ?", org.OrgID).Error)
+	assert.Equal(t, "12.35", holding.LockedForSale.String())
+}
+
+func TestEditListing_IncreaseRequiresVerification(t *testing.T) {
+	h, db := setupListingsTest(t)
+	require.NoError(t, db.AutoMigrate(&domain.Org{}, &domain.SubAccountHolding{}, &domain.ListingEvent{}, &domain.OrgVerification{}))
+	org := domain.Org{OrgName: "Seller", OrgCode: "SE-000001", CountryCode: "SG"}
+	require.NoError(t, db.Create(&org).Error)
+	projectID := uuid.New()
+	require.NoError(t, db.Create(&domain.Holding{OrgID: org.OrgID, ProjectID: projectID, CreditBalance: decimal.NewFromInt(100), LockedForSale: decimal.NewFromInt(10)}).Error)
+	listing := domain.Listing{ProjectID: projectID, SellerID: &org.OrgID, CreditsAvailable: decimal.NewFromInt(10), PricePerCredit: decimal.NewFromInt(5), Status: "open", SdgNumbers: domain.SDGNumbers("[]")}
+	require.NoError(t, db.Create(&listing).Error)
+
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("user", map[string]interface{}{"user_id": uuid.NewString(), "role": "admin", "org_id": org.OrgID.String()})
+		return c.Next()
+	})
+	app.Put("/edit-listing", h.EditListing)
+	edit := func(body string) int {
+		req := httptest.NewRequest("PUT", "/edit-listing", bytes.NewReader([]byte(`{"listing_id":"`+listing.ListingID.String()+`",`+body+`}`)))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return resp.StatusCode
+	}
+
+	// An unverified org cannot list more, but may still reprice or list less.
+	assert.Equal(t, 403, edit(`"price":5,"quantity":20`))
+	assert.Equal(t, 200, edit(`"price":6,"quantity":10`))
+	assert.Equal(t, 200, edit(`"price":6,"quantity":8`))
+	require.NoError(t, db.First(&listing, "listing_id = ?", listing.ListingID).Error)
+	assert.Equal(t, "6", listing.PricePerCredit.String())
+	assert.Equal(t, "8", listing.CreditsAvailable.String())
+
+	require.NoError(t, db.Create(&domain.OrgVerification{OrgID: org.OrgID, Status: verification.StatusVerified}).Error)
+	assert.Equal(t, 200, edit(`"price":6,"quantity":20`))
+}
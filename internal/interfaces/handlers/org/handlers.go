@@ -1,10 +1,18 @@
 package org
 
 import (
+	"context"
 	"encoding/json"
+	"strings"
 
+	auditsvc "troo-backend/internal/application/audit"
 	orgsvc "troo-backend/internal/application/org"
+	scimsvc "troo-backend/internal/application/scim"
+	ssosvc "troo-backend/internal/application/sso"
+	verifysvc "troo-backend/internal/application/verification"
+	"troo-backend/internal/domain"
 	"troo-backend/internal/middleware"
+	"troo-backend/internal/pkg/constants"
 	"troo-backend/internal/pkg/response"
 
 	"github.com/gofiber/fiber/v2"
@@ -15,6 +23,12 @@ import (
 type Handlers struct {
 	Service *orgsvc.Service
 	Config  middleware.SessionConfig
+	Audit   *auditsvc.Service
+	// SSO is nil when single sign-on is not wired (no database or Redis); its endpoints then return 503.
+	SSO  *ssosvc.Service
+	SCIM *scimsvc.Service
+	// Verification is the org's KYB review; only verified orgs may sell or transfer credits.
+	Verification *verifysvc.Service
 }
 
 // CreateOrg POST /api/v1/orgs/create-org
@@ -46,6 +60,10 @@ func (h *Handlers) CreateOrg(c *fiber.Ctx) error {
 	if err != nil {
 		return response.Error(c, "Authorization error", 500, nil)
 	}
+	// The session would move to the new org
+	if middleware.SSOOrgID(m) != "" {
+		return response.Error(c, middleware.ErrSSOSessionBound.Error(), 403, nil)
+	}
 
 	var regIDPtr, logoPtr, docPtr *string
 	if v, ok := body["registration_id"].(string); ok {
@@ -78,18 +96,28 @@ func (h *Handlers) CreateOrg(c *fiber.Ctx) error {
 	fullname, _ := m["fullname"].(string)
 	email, _ := m["email"].(string)
 	middleware.SetSessionUser(c, middleware.SessionUser{
-		UserID:   actorIDStr,
-		Fullname: fullname,
-		Email:    email,
-		Role:     "superadmin",
-		OrgID:    &orgIDStr,
+		UserID:        actorIDStr,
+		Fullname:      fullname,
+		Email:         email,
+		Role:          "superadmin",
+		OrgID:         &orgIDStr,
+		EmailVerified: middleware.EmailVerified(m),
+		TwoFactor:     m["two_factor"] == true,
+		AuthMethod:    middleware.AuthMethodPassword,
 	})
 
 	// Cookie: troo.sid (Express: same as login, no domain when setting)
 	cookie := middleware.SessionCookieConfig(h.Config)
-	cookie.Value = "s:" + sessionID
+	cookie.Value = middleware.SessionCookieValue(h.Config, sessionID)
 	c.Cookie(&cookie)
 
+	middleware.Audit(c, h.Audit, auditsvc.Entry{
+		Action:     auditsvc.ActionOrgCreated,
+		TargetType: "org",
+		TargetID:   orgIDStr,
+		After:      org,
+	})
+
 	// User asked for 201 on create endpoints; use SuccessCreated
 	return response.SuccessCreated(c, "Organization created successfully", org, nil)
 }
@@ -151,6 +179,8 @@ func (h *Handlers) UpdateOrg(c *fiber.Ctx) error {
 		return response.Error(c, "No update fields provided", 400, nil)
 	}
 
+	before, _ := h.Service.GetOrgByID(c.Context(), orgID)
+	delete(before, "employees")
 	org, err := h.Service.UpdateOrg(c.Context(), orgID, body)
 	if err != nil {
 		switch err.Error() {
@@ -162,5 +192,439 @@ func (h *Handlers) UpdateOrg(c *fiber.Ctx) error {
 			return response.Error(c, "Internal Server Error", 500, nil)
 		}
 	}
+	middleware.Audit(c, h.Audit, auditsvc.Entry{
+		Action:     auditsvc.ActionOrgUpdated,
+		TargetType: "org",
+		TargetID:   orgIDStr,
+		Before:     before,
+		After:      org,
+	})
 	return response.Success(c, "Organization updated successfully", org, nil)
 }
+
+// UpdateSecurityPolicy PUT /api/v1/orgs/update-security-policy — body: require_two_factor. Superadmin only.
+// Members are signed out so the policy applies from their next login; the caller keeps this session.
+func (h *Handlers) UpdateSecurityPolicy(c *fiber.Ctx) error {
+	actor := middleware.GetUser(c)
+	if actor == nil {
+		return response.Unauthorized(c, "Unauthorized")
+	}
+	m, ok := actor.(map[string]interface{})
+	if !ok {
+		return response.Error(c, "Authorization error", 500, nil)
+	}
+	orgIDStr, _ := m["org_id"].(string)
+	orgID, err := uuid.Parse(orgIDStr)
+	if err != nil {
+		return response.Error(c, "User is not associated with any organization", 403, nil)
+	}
+
+	var body struct {
+		RequireTwoFactor *bool `json:"require_two_factor"`
+	}
+	if err := json.Unmarshal(c.Body(), &body); err != nil || body.RequireTwoFactor == nil {
+		return response.Error(c, "require_two_factor is required", 400, nil)
+	}
+
+	org, err := h.Service.UpdateSecurityPolicy(c.Context(), orgID, *body.RequireTwoFactor)
+	if err != nil {
+		switch err.Error() {
+		case "Missing org_id":
+			return response.Error(c, err.Error(), 400, nil)
+		case "Org not found":
+			return response.Error(c, err.Error(), 404, nil)
+		default:
+			return response.Error(c, "Internal Server Error", 500, nil)
+		}
+	}
+
+	if *body.RequireTwoFactor {
+		m["two_factor_required"] = true
+	} else {
+		delete(m, "two_factor_required")
+	}
+	if sid := middleware.GetSessionID(c); sid != "" && h.Service.Rdb != nil {
+		userID, _ := m["user_id"].(string)
+		_ = h.Service.Rdb.SAdd(context.Background(), constants.UserSessionsPrefix+userID, sid).Err()
+	}
+
+	middleware.Audit(c, h.Audit, auditsvc.Entry{
+		Action:     auditsvc.ActionSecurityPolicy,
+		TargetType: "org",
+		TargetID:   orgIDStr,
+		After:      fiber.Map{"require_two_factor": org.RequireTwoFactor},
+	})
+	return response.Success(c, "Security policy updated successfully", fiber.Map{"require_two_factor": org.RequireTwoFactor}, nil)
+}
+
+// ViewSSOConfig GET /api/v1/orgs/view-sso-config — the org's identity provider, without its client secret.
+func (h *Handlers) ViewSSOConfig(c *fiber.Ctx) error {
+	if h.SSO == nil {
+		return response.Error(c, ssosvc.ErrUnavailable.Error(), 503, nil)
+	}
+	orgID, ok := callerOrgID(c)
+	if !ok {
+		return response.Error(c, "User is not associated with any organization", 403, nil)
+	}
+	cfg, err := h.SSO.GetConfig(c.Context(), orgID)
+	if err != nil {
+		return response.Error(c, "Internal Server Error", 500, nil)
+	}
+	if cfg == nil {
+		return response.Success(c, "SSO is not configured", nil, nil)
+	}
+	return response.Success(c, "SSO configuration fetched successfully", ssoConfigView(cfg), nil)
+}
+
+// UpdateSSOConfig PUT /api/v1/orgs/update-sso-config — body: issuer, client_id, client_secret (omit to keep),
+// email_domains, default_role, enabled. Superadmin only; the issuer must serve OIDC discovery.
+func (h *Handlers) UpdateSSOConfig(c *fiber.Ctx) error {
+	if h.SSO == nil {
+		return response.Error(c, ssosvc.ErrUnavailable.Error(), 503, nil)
+	}
+	orgID, ok := callerOrgID(c)
+	if !ok {
+		return response.Error(c, "User is not associated with any organization", 403, nil)
+	}
+	var body struct {
+		Issuer       string   `json:"issuer"`
+		ClientID     string   `json:"client_id"`
+		ClientSecret string   `json:"client_secret"`
+		EmailDomains []string `json:"email_domains"`
+		DefaultRole  string   `json:"default_role"`
+		Enabled      bool     `json:"enabled"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return response.Error(c, "Invalid request body", 400, nil)
+	}
+	cfg, err := h.SSO.UpdateConfig(c.Context(), orgID, ssosvc.ConfigInput{
+		Issuer:       body.Issuer,
+		ClientID:     body.ClientID,
+		ClientSecret: body.ClientSecret,
+		EmailDomains: body.EmailDomains,
+		DefaultRole:  body.DefaultRole,
+		Enabled:      body.Enabled,
+	})
+	if err != nil {
+		switch err {
+		case ssosvc.ErrConfigIncomplete, ssosvc.ErrDomainRequired, ssosvc.ErrInvalidDomain, ssosvc.ErrInvalidRole, ssosvc.ErrIdPUnreachable:
+			return response.Error(c, err.Error(), 400, nil)
+		case ssosvc.ErrDomainTaken:
+			return response.Error(c, err.Error(), 409, nil)
+		default:
+			return response.Error(c, "Internal Server Error", 500, nil)
+		}
+	}
+	view := ssoConfigView(cfg)
+	middleware.Audit(c, h.Audit, auditsvc.Entry{
+		Action:     auditsvc.ActionSSOConfigUpdated,
+		TargetType: "org",
+		TargetID:   orgID.String(),
+		After:      view,
+	})
+	return response.Success(c, "SSO configuration updated successfully", view, nil)
+}
+
+// VerifySSODomain POST /api/v1/orgs/verify-sso-domain — body: domain. Checks the domain's verification TXT record
+// (see view-sso-config); only verified domains route sign-ins to the org's provider.
+func (h *Handlers) VerifySSODomain(c *fiber.Ctx) error {
+	if h.SSO == nil {
+		return response.Error(c, ssosvc.ErrUnavailable.Error(), 503, nil)
+	}
+	orgID, ok := callerOrgID(c)
+	if !ok {
+		return response.Error(c, "User is not associated with any organization", 403, nil)
+	}
+	var body struct {
+		Domain string `json:"domain"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return response.Error(c, "Invalid request body", 400, nil)
+	}
+	cfg, err := h.SSO.VerifyDomain(c.Context(), orgID, body.Domain)
+	if err != nil {
+		switch err {
+		case ssosvc.ErrDomainNotClaimed:
+			return response.Error(c, err.Error(), 404, nil)
+		case ssosvc.ErrDomainUnverified:
+			return response.Error(c, err.Error(), 400, nil)
+		case ssosvc.ErrDomainTaken:
+			return response.Error(c, err.Error(), 409, nil)
+		default:
+			return response.Error(c, "Internal Server Error", 500, nil)
+		}
+	}
+	view := ssoConfigView(cfg)
+	middleware.Audit(c, h.Audit, auditsvc.Entry{
+		Action:     auditsvc.ActionSSODomainVerified,
+		TargetType: "org",
+		TargetID:   orgID.String(),
+		After:      fiber.Map{"domain": strings.ToLower(strings.TrimSpace(body.Domain))},
+	})
+	return response.Success(c, "Domain verified successfully", view, nil)
+}
+
+// ViewSCIMToken GET /api/v1/orgs/view-scim-token — whether the org has a SCIM token, and when it was last used.
+func (h *Handlers) ViewSCIMToken(c *fiber.Ctx) error {
+	orgID, ok := callerOrgID(c)
+	if !ok {
+		return response.Error(c, "User is not associated with any organization", 403, nil)
+	}
+	status, err := h.SCIM.TokenStatus(c.Context(), orgID)
+	if err != nil {
+		return response.Error(c, "Internal Server Error", 500, nil)
+	}
+	return response.Success(c, "SCIM token fetched successfully", status, nil)
+}
+
+// RotateSCIMToken POST /api/v1/orgs/rotate-scim-token — issues the bearer token for /api/v1/scim/v2 and
+// invalidates the previous one. data.token is shown only in this response. The provider can assign any role,
+// so in orgs that require two-factor authentication the session must have passed it.
+func (h *Handlers) RotateSCIMToken(c *fiber.Ctx) error {
+	m, _ := middleware.GetUser(c).(map[string]interface{})
+	orgID, ok := callerOrgID(c)
+	if !ok {
+		return response.Error(c, "User is not associated with any organization", 403, nil)
+	}
+	if m["two_factor_required"] == true && m["two_factor"] != true {
+		return response.Error(c, "Your organization requires two-factor authentication for this action", 403, nil)
+	}
+	userIDStr, _ := m["user_id"].(string)
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		return response.Unauthorized(c, "Unauthorized")
+	}
+	token, err := h.SCIM.RotateToken(c.Context(), orgID, userID)
+	if err != nil {
+		return response.Error(c, "Internal Server Error", 500, nil)
+	}
+	middleware.Audit(c, h.Audit, auditsvc.Entry{
+		Action:     auditsvc.ActionSCIMTokenRotated,
+		TargetType: "org",
+		TargetID:   orgID.String(),
+	})
+	return response.Success(c, "SCIM token issued successfully", fiber.Map{"token": token}, nil)
+}
+
+// RevokeSCIMToken DELETE /api/v1/orgs/revoke-scim-token — turns SCIM provisioning off; members are unaffected.
+func (h *Handlers) RevokeSCIMToken(c *fiber.Ctx) error {
+	orgID, ok := callerOrgID(c)
+	if !ok {
+		return response.Error(c, "User is not associated with any organization", 403, nil)
+	}
+	if err := h.SCIM.RevokeToken(c.Context(), orgID); err != nil {
+		return response.Error(c, "Internal Server Error", 500, nil)
+	}
+	middleware.Audit(c, h.Audit, auditsvc.Entry{
+		Action:     auditsvc.ActionSCIMTokenRevoked,
+		TargetType: "org",
+		TargetID:   orgID.String(),
+	})
+	return response.Success(c, "SCIM token revoked successfully", nil, nil)
+}
+
+// ViewVerification GET /api/v1/orgs/view-verification — the org's verification status and which of the required
+// documents its profile has.
+func (h *Handlers) ViewVerification(c *fiber.Ctx) error {
+	orgID, ok := callerOrgID(c)
+	if !ok {
+		return response.Error(c, "User is not associated with any organization", 403, nil)
+	}
+	view, err := h.Verification.View(c.Context(), orgID)
+	if err != nil {
+		if err.Error() == "Organization not found" {
+			return response.Error(c, err.Error(), 404, nil)
+		}
+		return response.Error(c, "Internal Server Error", 500, nil)
+	}
+	return response.Success(c, "Verification fetched successfully", view, nil)
+}
+
+// SubmitVerification POST /api/v1/orgs/submit-verification — sends the org's registration_id and
+// incorporation_doc_url (set through update-org) for review. Allowed when unverified or rejected.
+func (h *Handlers) SubmitVerification(c *fiber.Ctx) error {
+	m, _ := middleware.GetUser(c).(map[string]interface{})
+	orgID, ok := callerOrgID(c)
+	if !ok {
+		return response.Error(c, "User is not associated with any organization", 403, nil)
+	}
+	userIDStr, _ := m["user_id"].(string)
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		return response.Unauthorized(c, "Unauthorized")
+	}
+	view, err := h.Verification.Submit(c.Context(), orgID, userID)
+	if err != nil {
+		switch err {
+		case verifysvc.ErrDocumentsMissing:
+			// details lists the requirements so the client can show what is missing.
+			current, _ := h.Verification.View(c.Context(), orgID)
+			var details interface{}
+			if current != nil {
+				details = current.Requirements
+			}
+			return response.Error(c, err.Error(), 400, details)
+		case verifysvc.ErrNotSubmittable:
+			return response.Error(c, err.Error(), 409, nil)
+		}
+		if err.Error() == "Organization not found" {
+			return response.Error(c, err.Error(), 404, nil)
+		}
+		return response.Error(c, "Internal Server Error", 500, nil)
+	}
+	middleware.Audit(c, h.Audit, auditsvc.Entry{
+		Action:     auditsvc.ActionVerificationSubmitted,
+		TargetType: "org",
+		TargetID:   orgID.String(),
+		After:      fiber.Map{"status": view.Status, "registration_id": view.RegistrationID, "incorporation_doc_url": view.IncorporationDocURL},
+	})
+	return response.Success(c, "Verification submitted successfully", view, nil)
+}
+
+// UpdatePublicProfile PUT /api/v1/orgs/update-public-profile — body: public_retirements. Shares (or hides) the
+// org's retirement totals and certificates on its public profile.
+func (h *Handlers) UpdatePublicProfile(c *fiber.Ctx) error {
+	orgID, ok := callerOrgID(c)
+	if !ok {
+		return response.Error(c, "User is not associated with any organization", 403, nil)
+	}
+	var body struct {
+		PublicRetirements *bool `json:"public_retirements"`
+	}
+	if err := json.Unmarshal(c.Body(), &body); err != nil || body.PublicRetirements == nil {
+		return response.Error(c, "public_retirements is required", 400, nil)
+	}
+	org, err := h.Service.UpdatePublicProfile(c.Context(), orgID, *body.PublicRetirements)
+	if err != nil {
+		switch err.Error() {
+		case "Missing org_id":
+			return response.Error(c, err.Error(), 400, nil)
+		case "Org not found":
+			return response.Error(c, err.Error(), 404, nil)
+		default:
+			return response.Error(c, "Internal Server Error", 500, nil)
+		}
+	}
+	middleware.Audit(c, h.Audit, auditsvc.Entry{
+		Action:     auditsvc.ActionPublicProfile,
+		TargetType: "org",
+		TargetID:   orgID.String(),
+		After:      fiber.Map{"public_retirements": org.PublicRetirements},
+	})
+	return response.Success(c, "Public profile updated successfully", fiber.Map{"org_code": org.OrgCode, "public_retirements": org.PublicRetirements}, nil)
+}
+
+// CloseOrg POST /api/v1/orgs/close-org — body: confirm_org_code, transfer_to_org_code, anonymise_members.
+// Superadmin only. Every member, the caller included, is detached from the org and signed out.
+func (h *Handlers) CloseOrg(c *fiber.Ctx) error {
+	m, _ := middleware.GetUser(c).(map[string]interface{})
+	orgID, ok := callerOrgID(c)
+	if !ok {
+		return response.Error(c, "User is not associated with any organization", 403, nil)
+	}
+	userIDStr, _ := m["user_id"].(string)
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		return response.Unauthorized(c, "Unauthorized")
+	}
+	if m["two_factor_required"] == true && m["two_factor"] != true {
+		return response.Error(c, "Your organization requires two-factor authentication for this action", 403, nil)
+	}
+	role, _ := m["role"].(string)
+	var body struct {
+		ConfirmOrgCode    string  `json:"confirm_org_code"`
+		TransferToOrgCode *string `json:"transfer_to_org_code"`
+		AnonymiseMembers  bool    `json:"anonymise_members"`
+	}
+	if err := json.Unmarshal(c.Body(), &body); err != nil {
+		return response.Error(c, "Invalid request body", 400, nil)
+	}
+	out, err := h.Service.CloseOrg(c.Context(), orgsvc.CloseOrgInput{
+		OrgID:             orgID,
+		ActorUserID:       userID,
+		ActorRole:         role,
+		ConfirmOrgCode:    body.ConfirmOrgCode,
+		TransferToOrgCode: body.TransferToOrgCode,
+		AnonymiseMembers:  body.AnonymiseMembers,
+	})
+	if err != nil {
+		if code, ok := closeErrorStatus[err.Error()]; ok {
+			return response.Error(c, err.Error(), code, nil)
+		}
+		return response.Error(c, "Internal Server Error", 500, nil)
+	}
+	middleware.Audit(c, h.Audit, auditsvc.Entry{
+		Action:     auditsvc.ActionOrgClosed,
+		TargetType: "org",
+		TargetID:   orgID.String(),
+		After: fiber.Map{
+			"transfers":           out.Transfers,
+			"members_detached":    out.MembersDetached,
+			"members_anonymised":  out.MembersAnonymised,
+			"invitations_revoked": out.InvitationsRevoked,
+			"api_keys_revoked":    out.APIKeysRevoked,
+			"approvals_cancelled": out.ApprovalsCancelled,
+		},
+	})
+	return response.Success(c, "Organization closed successfully", out, nil)
+}
+
+// closeErrorStatus maps CloseOrg errors, including those of the transfer-out, to status codes.
+var closeErrorStatus = map[string]int{
+	"Org not found": 404,
+	"confirm_org_code must match the organization's code":                400,
+	"Organization is closed":                                             409,
+	"Cancel all open listings before closing the organization":           409,
+	"Transfer out or retire all credits before closing the organization": 409,
+	"Cannot transfer to the same organization":                           400,
+	"Target organization not found":                                      404,
+	"Insufficient available credits to transfer":                         409,
+	"Organization must be verified to sell or transfer credits":          403,
+	"Project not allowed by your trading limits":                         403,
+	"Amount exceeds your per-trade limit":                                403,
+	"Daily transfer volume limit exceeded":                               403,
+	orgsvc.ErrNeedsApproval.Error():                                      409,
+}
+
+// ssoConfigView lists, for each domain still to verify, the TXT record to publish.
+func ssoConfigView(cfg *domain.OrgSSOConfig) fiber.Map {
+	verified := cfg.VerifiedDomainList()
+	pending := []fiber.Map{}
+	for _, d := range cfg.DomainList() {
+		if containsString(verified, d) {
+			continue
+		}
+		name, value := ssosvc.VerificationRecord(cfg, d)
+		pending = append(pending, fiber.Map{"domain": d, "txt_name": name, "txt_value": value})
+	}
+	if verified == nil {
+		verified = []string{}
+	}
+	return fiber.Map{
+		"issuer":            cfg.Issuer,
+		"client_id":         cfg.ClientID,
+		"has_client_secret": cfg.ClientSecret != "",
+		"email_domains":     cfg.DomainList(),
+		"verified_domains":  verified,
+		"pending_domains":   pending,
+		"default_role":      cfg.DefaultRole,
+		"enabled":           cfg.Enabled,
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// callerOrgID returns the session user's org id.
+func callerOrgID(c *fiber.Ctx) (uuid.UUID, bool) {
+	m, _ := middleware.GetUser(c).(map[string]interface{})
+	orgIDStr, _ := m["org_id"].(string)
+	orgID, err := uuid.Parse(orgIDStr)
+	return orgID, err == nil
+}
@@ -2,6 +2,7 @@ package org
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"net/http/httptest"
 	"testing"
@@ -10,8 +11,10 @@ import (
 	"troo-backend/internal/domain"
 	"troo-backend/internal/middleware"
 
+	"github.com/alicebob/miniredis/v2"
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/glebarez/sqlite"
@@ -21,7 +24,7 @@ import (
 func setupOrgTest(t *testing.T) (*Handlers, *gorm.DB) {
 	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
 	require.NoError(t, err)
-	require.NoError(t, db.AutoMigrate(&domain.Org{}, &domain.User{}))
+	require.NoError(t, db.AutoMigrate(&domain.Org{}, &domain.User{}, &domain.OrgMembership{}))
 
 	service := &orgsvc.Service{DB: db}
 	handlers := &Handlers{
@@ -90,3 +93,71 @@ func TestUpdateOrg_NoOrgInSession(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, fiber.StatusForbidden, resp.StatusCode)
 }
+
+// TestUpdateSecurityPolicy_SignsMembersOut sets the flag and drops members' sessions, keeping the caller's.
+func TestUpdateSecurityPolicy_SignsMembersOut(t *testing.T) {
+	h, db := setupOrgTest(t)
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	t.Cleanup(mr.Close)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	h.Service.Rdb = rdb
+
+	orgID := uuid.New()
+	require.NoError(t, db.Create(&domain.Org{OrgID: orgID, OrgName: "Acme", OrgCode: "ACME", CountryCode: "GB"}).Error)
+	admin, member := uuid.New(), uuid.New()
+	for i, id := range []uuid.UUID{admin, member} {
+		require.NoError(t, db.Create(&domain.User{UserID: id, UserName: id.String()[:8], Email: id.String() + "@example.com", Fullname: "U", Role: []string{"superadmin", "manager"}[i], OrgID: &orgID}).Error)
+	}
+	ctx := context.Background()
+	rdb.SAdd(ctx, "user_sessions:"+admin.String(), "sid-admin")
+	rdb.SAdd(ctx, "user_sessions:"+member.String(), "sid-member")
+	rdb.Set(ctx, middleware.SessionRedisPrefix+"sid-member", "{}", 0)
+
+	sessionUser := map[string]interface{}{"user_id": admin.String(), "role": "superadmin", "org_id": orgID.String()}
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("user", sessionUser)
+		c.Locals("session_id", "sid-admin")
+		return c.Next()
+	})
+	app.Put("/update-security-policy", h.UpdateSecurityPolicy)
+
+	req := httptest.NewRequest("PUT", "/update-security-policy", bytes.NewReader([]byte(`{}`)))
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+
+	req = httptest.NewRequest("PUT", "/update-security-policy", bytes.NewReader([]byte(`{"require_two_factor":true}`)))
+	resp, err = app.Test(req)
+	require.NoError(t, err)
+	require.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+	var org domain.Org
+	require.NoError(t, db.Where("org_id = ?", orgID).First(&org).Error)
+	assert.True(t, org.RequireTwoFactor)
+	assert.Equal(t, int64(0), rdb.Exists(ctx, middleware.SessionRedisPrefix+"sid-member").Val())
+	assert.True(t, rdb.SIsMember(ctx, "user_sessions:"+admin.String(), "sid-admin").Val())
+	assert.Equal(t, true, sessionUser["two_factor_required"])
+}
+
+// TestSSOConfig_UnavailableWithoutSSO returns 503 when single sign-on is not wired.
+func TestSSOConfig_UnavailableWithoutSSO(t *testing.T) {
+	h, _ := setupOrgTest(t)
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("user", map[string]interface{}{"user_id": uuid.New().String(), "org_id": uuid.New().String()})
+		return c.Next()
+	})
+	app.Get("/api/v1/orgs/view-sso-config", h.ViewSSOConfig)
+	app.Put("/api/v1/orgs/update-sso-config", h.UpdateSSOConfig)
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/api/v1/orgs/view-sso-config", nil))
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusServiceUnavailable, resp.StatusCode)
+	req := httptest.NewRequest("PUT", "/api/v1/orgs/update-sso-config", bytes.NewReader([]byte(`{}`)))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err = app.Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusServiceUnavailable, resp.StatusCode)
+}
@@ -0,0 +1,173 @@
+package org
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"troo-backend/internal/application/approvals"
+	"troo-backend/internal/application/limits"
+	orgsvc "troo-backend/internal/application/org"
+	"troo-backend/internal/application/verification"
+	"troo-backend/internal/domain"
+	"troo-backend/internal/middleware"
+	"troo-backend/internal/pkg/constants"
+	"troo-backend/internal/pkg/decimal"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/glebarez/sqlite"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+func TestCloseOrg_Offboarding(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(
+		&domain.Org{}, &domain.User{}, &domain.OrgMembership{}, &domain.PlatformOperator{}, &domain.Invitation{},
+		&domain.OrgAPIKey{}, &domain.OrgSCIMToken{}, &domain.OrgSSOConfig{}, &domain.UserTwoFactor{}, &domain.UserSSOIdentity{},
+		&domain.EmailVerificationToken{}, &domain.PasswordResetToken{}, &domain.Listing{}, &domain.Holding{},
+		&domain.Transaction{}, &domain.OrgVerification{}, &domain.SubAccount{}, &domain.SubAccountHolding{},
+		&domain.ApprovalPolicy{}, &domain.ApprovalRequest{}, &domain.TradingLimit{}, &domain.TradingLimitUsage{},
+	))
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	defer mr.Close()
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	ctx := context.Background()
+
+	closing := domain.Org{OrgName: "Closing Co", OrgCode: "CL-000001", CountryCode: "SG"}
+	target := domain.Org{OrgName: "Buyer Co", OrgCode: "BU-000001", CountryCode: "SG"}
+	require.NoError(t, db.Create(&closing).Error)
+	require.NoError(t, db.Create(&target).Error)
+	require.NoError(t, db.Create(&domain.OrgVerification{OrgID: closing.OrgID, Status: verification.StatusVerified}).Error)
+	owner := domain.User{Fullname: "Owner", UserName: "owner", Email: "owner@closing.example", PasswordHash: "x", OrgID: &closing.OrgID, Role: constants.Superadmin}
+	shared := domain.User{Fullname: "Shared", UserName: "shared", Email: "shared@closing.example", PasswordHash: "x", OrgID: &closing.OrgID, Role: constants.Admin}
+	require.NoError(t, db.Create(&owner).Error)
+	require.NoError(t, db.Create(&shared).Error)
+	require.NoError(t, db.Create(&domain.OrgMembership{UserID: shared.UserID, OrgID: target.OrgID, Role: constants.Viewer}).Error)
+	rdb.SAdd(ctx, "user_sessions:"+shared.UserID.String(), "sid-shared")
+	rdb.Set(ctx, middleware.SessionRedisPrefix+"sid-shared", "{}", 0)
+
+	// 50 credits, 20 of them in a sub-account, and one open listing.
+	projectID := uuid.New()
+	fund := domain.SubAccount{OrgID: closing.OrgID, Name: "Fund"}
+	require.NoError(t, db.Create(&fund).Error)
+	require.NoError(t, db.Create(&domain.Holding{OrgID: closing.OrgID, ProjectID: projectID, CreditBalance: decimal.NewFromInt(50)}).Error)
+	require.NoError(t, db.Create(&domain.SubAccountHolding{SubAccountID: fund.SubAccountID, OrgID: closing.OrgID, ProjectID: projectID, CreditBalance: decimal.NewFromInt(20)}).Error)
+	listing := domain.Listing{ProjectID: projectID, SellerID: &closing.OrgID, Status: "open"}
+	require.NoError(t, db.Create(&listing).Error)
+	require.NoError(t, db.Create(&domain.Invitation{OrgID: closing.OrgID, Email: "new@closing.example", Role: constants.Viewer, InviteToken: "tok", CreatedBy: owner.UserID.String()}).Error)
+	require.NoError(t, db.Create(&domain.OrgAPIKey{OrgID: closing.OrgID, Name: "ERP", Prefix: "troo_ab", KeyHash: "h", Permissions: datatypes.JSON(`["view_data"]`), CreatedBy: owner.UserID}).Error)
+
+	// A pending transfer request holding a trading-limit reservation.
+	usage := domain.TradingLimitUsage{OrgID: closing.OrgID, UserID: shared.UserID, Kind: limits.KindTransfer, ProjectID: projectID, Amount: decimal.NewFromInt(5)}
+	require.NoError(t, db.Create(&usage).Error)
+	pending := domain.ApprovalRequest{OrgID: closing.OrgID, Type: approvals.TypeTransfer, ProjectID: projectID, Amount: decimal.NewFromInt(5), Status: approvals.StatusPending, RequestedBy: shared.UserID, LimitUsageID: &usage.UsageID}
+	require.NoError(t, db.Create(&pending).Error)
+
+	h := &Handlers{Service: &orgsvc.Service{DB: db, Rdb: rdb}}
+	app := fiber.New()
+	session := map[string]interface{}{"user_id": owner.UserID.String(), "role": constants.Superadmin, "org_id": closing.OrgID.String()}
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("user", session)
+		return c.Next()
+	})
+	app.Post("/close-org", middleware.AuthorizePermission(constants.CloseOrg), h.CloseOrg)
+	do := func(body map[string]interface{}) (int, map[string]interface{}) {
+		b, _ := json.Marshal(body)
+		req := httptest.NewRequest("POST", "/close-org", bytes.NewReader(b))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		var out map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&out)
+		return resp.StatusCode, out
+	}
+
+	code, _ := do(map[string]interface{}{"confirm_org_code": "CL-000002"})
+	assert.Equal(t, 400, code)
+	code, out := do(map[string]interface{}{"confirm_org_code": "CL-000001"})
+	assert.Equal(t, 409, code)
+	assert.Equal(t, orgsvc.ErrOpenListings.Error(), out["error"].(map[string]interface{})["message"])
+	require.NoError(t, db.Model(&listing).Update("status", "closed").Error)
+	code, out = do(map[string]interface{}{"confirm_org_code": "CL-000001"})
+	assert.Equal(t, 409, code)
+	assert.Equal(t, orgsvc.ErrHoldingsRemain.Error(), out["error"].(map[string]interface{})["message"])
+
+	closeBody := map[string]interface{}{"confirm_org_code": "CL-000001", "transfer_to_org_code": "BU-000001", "anonymise_members": true}
+	session["two_factor_required"] = true
+	code, _ = do(closeBody)
+	assert.Equal(t, 403, code)
+	session["two_factor"] = true
+
+	// The transfer-out is held to the approval policy and the actor's trading limits.
+	policy := domain.ApprovalPolicy{OrgID: closing.OrgID, AllTransfers: true}
+	require.NoError(t, db.Create(&policy).Error)
+	code, out = do(closeBody)
+	assert.Equal(t, 409, code)
+	assert.Equal(t, orgsvc.ErrNeedsApproval.Error(), out["error"].(map[string]interface{})["message"])
+	require.NoError(t, db.Delete(&policy).Error)
+	perTrade := decimal.NewFromInt(25)
+	limit := domain.TradingLimit{OrgID: closing.OrgID, UserID: &owner.UserID, MaxCreditsPerTrade: &perTrade}
+	require.NoError(t, db.Create(&limit).Error)
+	code, out = do(closeBody)
+	assert.Equal(t, 403, code)
+	assert.Equal(t, "Amount exceeds your per-trade limit", out["error"].(map[string]interface{})["message"])
+	require.NoError(t, db.Delete(&limit).Error)
+	var stillPending domain.ApprovalRequest
+	require.NoError(t, db.First(&stillPending, "request_id = ?", pending.RequestID).Error)
+	assert.Equal(t, approvals.StatusPending, stillPending.Status)
+
+	code, out = do(closeBody)
+	require.Equal(t, 200, code, out)
+	data := out["data"].(map[string]interface{})
+	assert.Len(t, data["transfers"], 2)
+	assert.Equal(t, float64(2), data["members_detached"])
+	assert.Equal(t, float64(1), data["members_anonymised"])
+	assert.Equal(t, float64(1), data["invitations_revoked"])
+	assert.Equal(t, float64(1), data["api_keys_revoked"])
+	assert.Equal(t, float64(1), data["approvals_cancelled"])
+
+	// The pending request is cancelled and its reservation released.
+	var cancelled domain.ApprovalRequest
+	require.NoError(t, db.First(&cancelled, "request_id = ?", pending.RequestID).Error)
+	assert.Equal(t, approvals.StatusCancelled, cancelled.Status)
+	assert.Equal(t, owner.UserID, *cancelled.DecidedBy)
+	var reserved int64
+	require.NoError(t, db.Model(&domain.TradingLimitUsage{}).Where("usage_id = ?", usage.UsageID).Count(&reserved).Error)
+	assert.Equal(t, int64(0), reserved)
+
+	// Credits moved as ordinary transfers; the org's records stay.
+	var received domain.Holding
+	require.NoError(t, db.Where("org_id = ? AND project_id = ?", target.OrgID, projectID).First(&received).Error)
+	assert.True(t, received.CreditBalance.Equal(decimal.NewFromInt(50)))
+	var transfers int64
+	require.NoError(t, db.Model(&domain.Transaction{}).Where("type = ? AND from_org_id = ?", "transfer", closing.OrgID).Count(&transfers).Error)
+	assert.Equal(t, int64(2), transfers)
+	var closed domain.Org
+	require.NoError(t, db.First(&closed, "org_id = ?", closing.OrgID).Error)
+	require.NotNil(t, closed.ClosedAt)
+	assert.Equal(t, owner.UserID, *closed.ClosedBy)
+
+	// The owner had no other org and is anonymised; the shared member moves to their other org.
+	var gone, moved domain.User
+	require.NoError(t, db.First(&gone, "user_id = ?", owner.UserID).Error)
+	assert.Nil(t, gone.OrgID)
+	assert.Equal(t, "Deleted user", gone.Fullname)
+	assert.NotEqual(t, "owner@closing.example", gone.Email)
+	require.NoError(t, db.First(&moved, "user_id = ?", shared.UserID).Error)
+	assert.Equal(t, target.OrgID, *moved.OrgID)
+	assert.Equal(t, "Shared", moved.Fullname)
+	assert.Equal(t, int64(0), rdb.Exists(ctx, middleware.SessionRedisPrefix+"sid-shared").Val())
+
+	_, err = h.Service.CloseOrg(ctx, orgsvc.CloseOrgInput{OrgID: closing.OrgID, ActorUserID: owner.UserID, ConfirmOrgCode: "CL-000001"})
+	assert.Equal(t, orgsvc.ErrOrgClosed, err)
+}
@@ -1,18 +1,23 @@
 package payments
 
 import (
+	"context"
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"math"
 	"strconv"
 	"strings"
 	"time"
 
+	auditsvc "troo-backend/internal/application/audit"
+	"troo-backend/internal/application/holdings"
+	limitsvc "troo-backend/internal/application/limits"
+	"troo-backend/internal/application/subaccounts"
 	"troo-backend/internal/domain"
+	"troo-backend/internal/pkg/decimal"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
@@ -24,6 +29,12 @@ import (
 type WebhookHandler struct {
 	DB            *gorm.DB
 	WebhookSecret string
+	// Audit records completed purchases (no session: actor and IP are empty) in the same transaction as the
+	// credit movement; nil records nothing.
+	Audit *auditsvc.Service
+	// Limits settles the buyer's trading-limit reservation: kept when credits are delivered, released when the
+	// payment fails or cannot be fulfilled; nil does nothing.
+	Limits *limitsvc.Service
 }
 
 type stripeEvent struct {
@@ -64,17 +75,24 @@ func (wh *WebhookHandler) HandleWebhook(c *fiber.Ctx) error {
 		return c.Status(400).SendString(fmt.Sprintf("Webhook Error: %s", err.Error()))
 	}
 
-	if event.Type == "payment_intent.succeeded" {
+	switch event.Type {
+	case "payment_intent.succeeded":
 		var pi paymentIntentObject
 		if err := json.Unmarshal(event.Data.Object, &pi); err != nil {
 			return c.Status(200).SendString("ok")
 		}
 
 		if err := wh.handlePaymentIntentSucceeded(pi, event.ID, rawBody); err != nil {
+			wh.releaseLimit(pi.ID)
 			// Return 200 with error in body so Stripe won't retry, but dashboard shows the error
 			log.Warn().Err(err).Str("payment_intent", pi.ID).Msg("Stripe webhook payment_intent.succeeded processing failed (credits not transferred)")
 			return c.Status(200).JSON(fiber.Map{"ok": false, "error": err.Error()})
 		}
+	case "payment_intent.payment_failed", "payment_intent.canceled":
+		var pi paymentIntentObject
+		if err := json.Unmarshal(event.Data.Object, &pi); err == nil {
+			wh.releaseLimit(pi.ID)
+		}
 	}
 
 	return c.Status(200).SendString("ok")
@@ -89,8 +107,8 @@ func (wh *WebhookHandler) handlePaymentIntentSucceeded(pi paymentIntentObject, e
 		return nil // skip silently, like Express
 	}
 
-	amount, err := strconv.ParseFloat(creditsAmountStr, 64)
-	if err != nil || amount <= 0 {
+	amount, err := decimal.NewFromString(creditsAmountStr)
+	if err != nil || amount.Sign() <= 0 {
 		return nil
 	}
 
@@ -104,6 +122,16 @@ func (wh *WebhookHandler) handlePaymentIntentSucceeded(pi paymentIntentObject, e
 		// Create Payment record
 		listingUUID, _ := uuid.Parse(listingID)
 		buyerUUID, _ := uuid.Parse(buyerOrgID)
+		// Intents created before buyer_user_id was added carry no acting user.
+		var buyerUserID *uuid.UUID
+		if id, err := uuid.Parse(pi.Metadata["buyer_user_id"]); err == nil {
+			buyerUserID = &id
+		}
+		// The sub-account the buyer chose, if any; one deleted since then falls back to the main account.
+		var buyerSubAccountID *uuid.UUID
+		if id, err := uuid.Parse(pi.Metadata["buyer_sub_account_id"]); err == nil && subaccounts.Check(tx, buyerUUID, &id) == nil {
+			buyerSubAccountID = &id
+		}
 
 		payment := domain.Payment{
 			StripePaymentIntentID: pi.ID,
@@ -121,12 +149,43 @@ func (wh *WebhookHandler) handlePaymentIntentSucceeded(pi paymentIntentObject, e
 		}
 
 		// Call buyCreditsService logic (same as Express tradingService.buyCreditsService)
-		return buyCreditsInTransaction(tx, listingUUID, buyerUUID, amount)
+		if err := buyCreditsInTransaction(tx, listingUUID, buyerUUID, buyerSubAccountID, buyerUserID, amount); err != nil {
+			return err
+		}
+		if err := wh.Limits.WithTx(tx).ConfirmPayment(context.Background(), pi.ID); err != nil {
+			return err
+		}
+		return wh.Audit.WithTx(tx).Record(context.Background(), auditsvc.Entry{
+			ActorID:    buyerUserID,
+			OrgID:      &buyerUUID,
+			Action:     auditsvc.ActionPurchaseCompleted,
+			TargetType: "listing",
+			TargetID:   listingID,
+			After: map[string]interface{}{
+				"amount":            amount,
+				"payment_intent_id": pi.ID,
+				"amount_paid_cents": pi.AmountReceived,
+				"currency":          pi.Currency,
+			},
+		})
 	})
 }
 
+// releaseLimit drops the trading-limit reservation of a payment that will not deliver credits.
+func (wh *WebhookHandler) releaseLimit(paymentIntentID string) {
+	if err := wh.Limits.ReleasePayment(context.Background(), paymentIntentID); err != nil {
+		log.Warn().Err(err).Str("payment_intent", paymentIntentID).Msg("Stripe webhook failed to release trading limit reservation")
+	}
+}
+
 // buyCreditsInTransaction mirrors Express buyCreditsService({ transaction }).
-func buyCreditsInTransaction(tx *gorm.DB, listingID, buyerOrgID uuid.UUID, amount float64) error {
+// Seller and buyer holdings are locked before the listing (holdings lock order), so concurrent
+// webhooks, sells, edits and cancels against the same listing are serialized and cannot oversell.
+// Credits leave the listing's sub-account and arrive in buyerSubAccountID (nil for the main account). The
+// seller's verification was checked before the buyer paid (trading CheckSeller), so a seller that has lost it
+// since still delivers what was paid for.
+func buyCreditsInTransaction(tx *gorm.DB, listingID, buyerOrgID uuid.UUID, buyerSubAccountID, buyerUserID *uuid.UUID, amount decimal.Decimal) error {
+	// Unlocked read for seller and project (immutable); status and quantity are re-read under lock.
 	var listing domain.Listing
 	if err := tx.Where("listing_id = ?", listingID).First(&listing).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
@@ -134,15 +193,31 @@ func buyCreditsInTransaction(tx *gorm.DB, listingID, buyerOrgID uuid.UUID, amoun
 		}
 		return err
 	}
+
+	lockOrgs := []uuid.UUID{buyerOrgID}
+	if listing.SellerID != nil {
+		lockOrgs = append(lockOrgs, *listing.SellerID)
+	}
+	locked, err := holdings.LockHoldings(tx, listing.ProjectID, lockOrgs...)
+	if err != nil {
+		return err
+	}
+	if _, err := subaccounts.Lock(tx, listing.ProjectID, lockOrgs...); err != nil {
+		return err
+	}
+	if err := holdings.ForUpdate(tx).Where("listing_id = ?", listingID).First(&listing).Error; err != nil {
+		return err
+	}
+
 	if listing.Status != "open" {
 		return errors.New("Listing is not open for purchase")
 	}
-	if listing.CreditsAvailable < amount {
+	if listing.CreditsAvailable.LessThan(amount) {
 		return errors.New("Insufficient credits available in the listing")
 	}
 
-	listing.CreditsAvailable = math.Round((listing.CreditsAvailable-amount)*100) / 100
-	if listing.CreditsAvailable == 0 {
+	listing.CreditsAvailable = listing.CreditsAvailable.Sub(amount)
+	if listing.CreditsAvailable.IsZero() {
 		listing.Status = "closed"
 	}
 	if err := tx.Save(&listing).Error; err != nil {
@@ -158,7 +233,7 @@ func buyCreditsInTransaction(tx *gorm.DB, listingID, buyerOrgID uuid.UUID, amoun
 		}
 		remainingCredits := listing.CreditsAvailable
 		eventType := "FILLED"
-		if remainingCredits > 0 {
+		if remainingCredits.IsPositive() {
 			eventType = "PARTIALLY_FILLED"
 		}
 		fillEventData, _ := json.Marshal(map[string]interface{}{
@@ -170,11 +245,12 @@ func buyCreditsInTransaction(tx *gorm.DB, listingID, buyerOrgID uuid.UUID, amoun
 			ListingID:    listing.ListingID,
 			EventType:    eventType,
 			ActorOrgCode: &buyerOrg.OrgCode,
+			ActorUserID:  buyerUserID,
 			EventData:    datatypes.JSON(fillEventData),
 		}).Error; err != nil {
 			return err
 		}
-		if remainingCredits == 0 {
+		if remainingCredits.IsZero() {
 			closedEventData, _ := json.Marshal(map[string]interface{}{"reason": "fully_filled"})
 			if err := tx.Create(&domain.ListingEvent{
 				ListingID:    listing.ListingID,
@@ -189,42 +265,29 @@ func buyCreditsInTransaction(tx *gorm.DB, listingID, buyerOrgID uuid.UUID, amoun
 
 	// Reduce seller holdings (if org-owned listing)
 	if listing.SellerID != nil {
-		var sellerHolding domain.Holding
-		if err := tx.Where("org_id = ? AND project_id = ?", listing.SellerID, listing.ProjectID).First(&sellerHolding).Error; err != nil {
-			if err == gorm.ErrRecordNotFound {
-				return errors.New("Seller holdings not found")
-			}
-			return err
+		sellerHolding, ok := locked[*listing.SellerID]
+		if !ok {
+			return errors.New("Seller holdings not found")
 		}
-		if sellerHolding.LockedForSale < amount {
+		if sellerHolding.LockedForSale.LessThan(amount) {
 			return errors.New("Seller does not have enough locked credits")
 		}
-		sellerHolding.LockedForSale = math.Round((sellerHolding.LockedForSale-amount)*100) / 100
-		sellerHolding.CreditBalance = math.Round((sellerHolding.CreditBalance-amount)*100) / 100
-		if err := tx.Save(&sellerHolding).Error; err != nil {
+		sellerHolding.LockedForSale = sellerHolding.LockedForSale.Sub(amount)
+		sellerHolding.CreditBalance = sellerHolding.CreditBalance.Sub(amount)
+		if err := tx.Save(sellerHolding).Error; err != nil {
+			return err
+		}
+		if err := subaccounts.Apply(tx, *listing.SellerID, listing.SubAccountID, listing.ProjectID, amount.Neg(), amount.Neg()); err != nil {
 			return err
 		}
 	}
 
 	// Add credits to buyer holdings
-	var buyerHolding domain.Holding
-	err := tx.Where("org_id = ? AND project_id = ?", buyerOrgID, listing.ProjectID).First(&buyerHolding).Error
-	if err == gorm.ErrRecordNotFound {
-		buyerHolding = domain.Holding{
-			OrgID:         buyerOrgID,
-			ProjectID:     listing.ProjectID,
-			CreditBalance: amount,
-		}
-		if err := tx.Create(&buyerHolding).Error; err != nil {
-			return err
-		}
-	} else if err != nil {
+	if _, err := holdings.Credit(tx, buyerOrgID, listing.ProjectID, amount); err != nil {
+		return err
+	}
+	if err := subaccounts.Apply(tx, buyerOrgID, buyerSubAccountID, listing.ProjectID, amount, decimal.Zero); err != nil {
 		return err
-	} else {
-		buyerHolding.CreditBalance = math.Round((buyerHolding.CreditBalance+amount)*100) / 100
-		if err := tx.Save(&buyerHolding).Error; err != nil {
-			return err
-		}
 	}
 
 	// Transaction record
@@ -236,6 +299,9 @@ func buyCreditsInTransaction(tx *gorm.DB, listingID, buyerOrgID uuid.UUID, amoun
 		ProjectID:        listing.ProjectID,
 		Amount:           amount,
 		RelatedListingID: &listing.ListingID,
+		ActorUserID:      buyerUserID,
+		SubAccountID:     listing.SubAccountID,
+		ToSubAccountID:   buyerSubAccountID,
 	}
 	return tx.Create(&txRecord).Error
 }
@@ -7,11 +7,17 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http/httptest"
+	"path/filepath"
+	"runtime"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	limitsvc "troo-backend/internal/application/limits"
+	"troo-backend/internal/application/verification"
 	"troo-backend/internal/domain"
+	"troo-backend/internal/pkg/decimal"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
@@ -19,6 +25,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
 )
 
 const testSecret = "whsec_test_secret_123"
@@ -27,13 +34,20 @@ func setupWebhookTest(t *testing.T) (*WebhookHandler, *gorm.DB) {
 	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
 	require.NoError(t, err)
 	require.NoError(t, db.AutoMigrate(
-		&domain.Listing{}, &domain.Holding{}, &domain.Payment{},
-		&domain.Transaction{}, &domain.Org{},
+		&domain.Listing{}, &domain.Holding{}, &domain.Payment{}, &domain.SubAccountHolding{},
+		&domain.Transaction{}, &domain.Org{}, &domain.ListingEvent{}, &domain.OrgVerification{},
+		&domain.TradingLimitUsage{},
 	))
-	wh := &WebhookHandler{DB: db, WebhookSecret: testSecret}
+	wh := &WebhookHandler{DB: db, WebhookSecret: testSecret, Limits: &limitsvc.Service{DB: db}}
 	return wh, db
 }
 
+// verifiedSeller creates the seller org, verified so that its listings can be bought.
+func verifiedSeller(t *testing.T, db *gorm.DB, orgID uuid.UUID) {
+	require.NoError(t, db.Create(&domain.Org{OrgID: orgID, OrgName: "Seller", OrgCode: "SE-000001", CountryCode: "SG"}).Error)
+	require.NoError(t, db.Create(&domain.OrgVerification{OrgID: orgID, Status: verification.StatusVerified}).Error)
+}
+
 func signPayload(t *testing.T, payload []byte, secret string) string {
 	ts := fmt.Sprintf("%d", time.Now().Unix())
 	mac := hmac.New(sha256.New, []byte(secret))
@@ -101,7 +115,7 @@ func TestWebhook_PaymentIntentSucceeded_BuysCredits(t *testing.T) {
 	require.NoError(t, db.Create(&domain.Listing{
 		ListingID: uuid.MustParse("11111111-1111-1111-1111-111111111111"),
 		ProjectID: projectID, SellerID: &sellerOrgID,
-		CreditsAvailable: 100, PricePerCredit: 5, Status: "open",
+		CreditsAvailable: decimal.NewFromInt(100), PricePerCredit: decimal.NewFromInt(5), Status: "open",
 		ProjectName: "Test", Registry: "R", Category: "C",
 		LocationCity: "X", LocationState: "Y", LocationCountry: "Z",
 		ThumbnailURL: "u", Methodology: "M",
@@ -109,9 +123,14 @@ func TestWebhook_PaymentIntentSucceeded_BuysCredits(t *testing.T) {
 
 	require.NoError(t, db.Create(&domain.Holding{
 		HoldingID: uuid.New(), OrgID: sellerOrgID, ProjectID: projectID,
-		CreditBalance: 100, LockedForSale: 100,
+		CreditBalance: decimal.NewFromInt(100), LockedForSale: decimal.NewFromInt(100),
 	}).Error)
+	require.NoError(t, db.Create(&domain.Org{
+		OrgID: buyerOrgID, OrgName: "Buyer", OrgCode: "BU-000001", CountryCode: "SG",
+	}).Error)
+	verifiedSeller(t, db, sellerOrgID)
 
+	buyerUserID := uuid.New()
 	piObj := map[string]interface{}{
 		"id":              "pi_test_buy_001",
 		"amount_received": 5000,
@@ -120,6 +139,7 @@ func TestWebhook_PaymentIntentSucceeded_BuysCredits(t *testing.T) {
 		"metadata": map[string]string{
 			"listing_id":     "11111111-1111-1111-1111-111111111111",
 			"buyer_org_id":   buyerOrgID.String(),
+			"buyer_user_id":  buyerUserID.String(),
 			"credits_amount": "10",
 		},
 	}
@@ -146,21 +166,249 @@ func TestWebhook_PaymentIntentSucceeded_BuysCredits(t *testing.T) {
 	// Verify payment record was created
 	var payment domain.Payment
 	require.NoError(t, db.Where("stripe_payment_intent_id = ?", "pi_test_buy_001").First(&payment).Error)
-	assert.Equal(t, 10.0, payment.CreditsAmount)
+	assert.Equal(t, "10", payment.CreditsAmount.String())
 
 	// Verify listing was updated
 	var listing domain.Listing
 	require.NoError(t, db.Where("listing_id = ?", "11111111-1111-1111-1111-111111111111").First(&listing).Error)
-	assert.Equal(t, 90.0, listing.CreditsAvailable)
+	assert.Equal(t, "90", listing.CreditsAvailable.String())
 
 	// Verify buyer holding was created
 	var buyerHolding domain.Holding
 	require.NoError(t, db.Where("org_id = ? AND project_id = ?", buyerOrgID, projectID).First(&buyerHolding).Error)
-	assert.Equal(t, 10.0, buyerHolding.CreditBalance)
+	assert.Equal(t, "10", buyerHolding.CreditBalance.String())
 
 	// Verify seller holding was decremented
 	var sellerHolding domain.Holding
 	require.NoError(t, db.Where("org_id = ? AND project_id = ?", sellerOrgID, projectID).First(&sellerHolding).Error)
-	assert.Equal(t, 90.0, sellerHolding.CreditBalance)
-	assert.Equal(t, 90.0, sellerHolding.LockedForSale)
+	assert.Equal(t, "90", sellerHolding.CreditBalance.String())
+	assert.Equal(t, "90", sellerHolding.LockedForSale.String())
+
+	// The buyer who started checkout is recorded as the acting user.
+	var txRecord domain.Transaction
+	require.NoError(t, db.Where("type = ?", "buy").First(&txRecord).Error)
+	require.NotNil(t, txRecord.ActorUserID)
+	assert.Equal(t, buyerUserID, *txRecord.ActorUserID)
+	var fill domain.ListingEvent
+	require.NoError(t, db.Where("event_type = ?", "PARTIALLY_FILLED").First(&fill).Error)
+	require.NotNil(t, fill.ActorUserID)
+	assert.Equal(t, buyerUserID, *fill.ActorUserID)
+}
+
+func TestWebhook_SettlesTradingLimitReservation(t *testing.T) {
+	wh, db := setupWebhookTest(t)
+	app := fiber.New()
+	app.Post("/webhook", wh.HandleWebhook)
+
+	sellerOrgID := uuid.New()
+	buyerOrgID := uuid.New()
+	projectID := uuid.New()
+	listingID := uuid.New()
+	require.NoError(t, db.Create(&domain.Listing{
+		ListingID: listingID, ProjectID: projectID, SellerID: &sellerOrgID,
+		CreditsAvailable: decimal.NewFromInt(100), PricePerCredit: decimal.NewFromInt(5), Status: "open",
+	}).Error)
+	require.NoError(t, db.Create(&domain.Holding{
+		OrgID: sellerOrgID, ProjectID: projectID, CreditBalance: decimal.NewFromInt(100), LockedForSale: decimal.NewFromInt(100),
+	}).Error)
+	require.NoError(t, db.Create(&domain.Org{OrgID: buyerOrgID, OrgName: "Buyer", OrgCode: "BU-000001", CountryCode: "SG"}).Error)
+	verifiedSeller(t, db, sellerOrgID)
+
+	expires := time.Now().Add(limitsvc.PaymentHold)
+	for _, pi := range []string{"pi_paid", "pi_failed", "pi_unfulfilled"} {
+		pi := pi
+		require.NoError(t, db.Create(&domain.TradingLimitUsage{
+			OrgID: buyerOrgID, UserID: uuid.New(), Kind: limitsvc.KindBuy, ProjectID: projectID,
+			Amount: decimal.NewFromInt(10), Spend: decimal.NewFromInt(50), PaymentIntentID: &pi, ExpiresAt: &expires,
+		}).Error)
+	}
+
+	send := func(eventType, piID, listing string) {
+		body, _ := json.Marshal(map[string]interface{}{
+			"id":   "evt_" + piID,
+			"type": eventType,
+			"data": map[string]interface{}{"object": map[string]interface{}{
+				"id": piID, "amount_received": 5000, "currency": "sgd", "status": "succeeded",
+				"metadata": map[string]string{"listing_id": listing, "buyer_org_id": buyerOrgID.String(), "credits_amount": "10"},
+			}},
+		})
+		req := httptest.NewRequest("POST", "/webhook", strings.NewReader(string(body)))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("stripe-signature", signPayload(t, body, testSecret))
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		require.Equal(t, 200, resp.StatusCode)
+	}
+	send("payment_intent.succeeded", "pi_paid", listingID.String())
+	send("payment_intent.payment_failed", "pi_failed", listingID.String())
+	send("payment_intent.succeeded", "pi_unfulfilled", uuid.New().String())
+
+	var paid domain.TradingLimitUsage
+	require.NoError(t, db.Where("payment_intent_id = ?", "pi_paid").First(&paid).Error)
+	assert.Nil(t, paid.ExpiresAt, "a delivered purchase counts for good")
+	var n int64
+	db.Model(&domain.TradingLimitUsage{}).Where("payment_intent_id IN ?", []string{"pi_failed", "pi_unfulfilled"}).Count(&n)
+	assert.Zero(t, n, "failed and unfulfilled payments release their reservation")
+}
+
+func TestWebhook_ConcurrentPaymentIntents_NoOversell(t *testing.T) {
+	// File-backed DB so concurrent deliveries run on separate connections against the same data.
+	dsn := "file:" + filepath.Join(t.TempDir(), "webhook.db") + "?_pragma=busy_timeout(10000)&_txlock=immediate"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	require.NoError(t, err)
+	require.NoError(t, db.Callback().Query().After("gorm:query").Register("test:yield", func(*gorm.DB) {
+		runtime.Gosched()
+	}))
+	require.NoError(t, db.AutoMigrate(
+		&domain.Listing{}, &domain.Holding{}, &domain.Payment{}, &domain.SubAccountHolding{},
+		&domain.Transaction{}, &domain.Org{}, &domain.ListingEvent{}, &domain.OrgVerification{},
+	))
+	wh := &WebhookHandler{DB: db, WebhookSecret: testSecret}
+
+	sellerOrgID, buyerOrgID, projectID, listingID := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	require.NoError(t, db.Create(&domain.Org{OrgID: buyerOrgID, OrgName: "Buyer", OrgCode: "BU-000001", CountryCode: "SG"}).Error)
+	verifiedSeller(t, db, sellerOrgID)
+	require.NoError(t, db.Create(&domain.Listing{
+		ListingID: listingID, ProjectID: projectID, SellerID: &sellerOrgID,
+		CreditsAvailable: decimal.NewFromInt(100), PricePerCredit: decimal.NewFromInt(5), Status: "open",
+		ProjectName: "Test", Registry: "R", Category: "C",
+		LocationCity: "X", LocationState: "Y", LocationCountry: "Z",
+		ThumbnailURL: "u", Methodology: "M",
+	}).Error)
+	require.NoError(t, db.Create(&domain.Holding{
+		OrgID: sellerOrgID, ProjectID: projectID, CreditBalance: decimal.NewFromInt(100), LockedForSale: decimal.NewFromInt(100),
+	}).Error)
+
+	// 10 distinct payments of 30 credits against a listing of 100: only 3 can be filled.
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			pi := paymentIntentObject{
+				ID: fmt.Sprintf("pi_concurrent_%d", i), AmountReceived: 15000, Currency: "sgd", Status: "succeeded",
+				Metadata: map[string]string{
+					"listing_id":     listingID.String(),
+					"buyer_org_id":   buyerOrgID.String(),
+					"credits_amount": "30",
+				},
+			}
+			_ = wh.handlePaymentIntentSucceeded(pi, fmt.Sprintf("evt_concurrent_%d", i), []byte(`{}`))
+		}(i)
+	}
+	wg.Wait()
+
+	var listing domain.Listing
+	require.NoError(t, db.Where("listing_id = ?", listingID).First(&listing).Error)
+	assert.Equal(t, "10", listing.CreditsAvailable.String())
+
+	var seller, buyer domain.Holding
+	require.NoError(t, db.Where("org_id = ? AND project_id = ?", sellerOrgID, projectID).First(&seller).Error)
+	assert.Equal(t, "10", seller.CreditBalance.String())
+	assert.Equal(t, "10", seller.LockedForSale.String())
+	require.NoError(t, db.Where("org_id = ? AND project_id = ?", buyerOrgID, projectID).First(&buyer).Error)
+	assert.Equal(t, "90", buyer.CreditBalance.String())
+
+	var buyerHoldings, payments int64
+	require.NoError(t, db.Model(&domain.Holding{}).Where("org_id = ?", buyerOrgID).Count(&buyerHoldings).Error)
+	assert.Equal(t, int64(1), buyerHoldings)
+	require.NoError(t, db.Model(&domain.Payment{}).Count(&payments).Error)
+	assert.Equal(t, int64(3), payments)
+}
+
+// The seller's verification is checked before the buyer pays (trading CheckSeller); a purchase already paid
+// for is delivered even if the seller has lost its verification since.
+func TestWebhook_PaidPurchaseIsDeliveredAfterSellerLosesVerification(t *testing.T) {
+	wh, db := setupWebhookTest(t)
+	sellerOrgID, buyerOrgID, projectID, listingID := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	require.NoError(t, db.Create(&domain.Org{OrgID: buyerOrgID, OrgName: "Buyer", OrgCode: "BU-000001", CountryCode: "SG"}).Error)
+	require.NoError(t, db.Create(&domain.Org{OrgID: sellerOrgID, OrgName: "Seller", OrgCode: "SE-000001", CountryCode: "SG"}).Error)
+	// Verified when the buyer paid, rejected on review since.
+	require.NoError(t, db.Create(&domain.OrgVerification{OrgID: sellerOrgID, Status: verification.StatusRejected}).Error)
+	require.NoError(t, db.Create(&domain.Listing{
+		ListingID: listingID, ProjectID: projectID, SellerID: &sellerOrgID,
+		CreditsAvailable: decimal.NewFromInt(100), PricePerCredit: decimal.NewFromInt(5), Status: "open",
+	}).Error)
+	require.NoError(t, db.Create(&domain.Holding{
+		OrgID: sellerOrgID, ProjectID: projectID, CreditBalance: decimal.NewFromInt(100), LockedForSale: decimal.NewFromInt(100),
+	}).Error)
+
+	pi := paymentIntentObject{
+		ID: "pi_unverified", AmountReceived: 5000, Currency: "sgd", Status: "succeeded",
+		Metadata: map[string]string{"listing_id": listingID.String(), "buyer_org_id": buyerOrgID.String(), "credits_amount": "10"},
+	}
+	require.NoError(t, wh.handlePaymentIntentSucceeded(pi, "evt_unverified", []byte(`{}`)))
+
+	var listing domain.Listing
+	require.NoError(t, db.Where("listing_id = ?", listingID).First(&listing).Error)
+	assert.Equal(t, "90", listing.CreditsAvailable.String())
+	var buyer domain.Holding
+	require.NoError(t, db.Where("org_id = ? AND project_id = ?", buyerOrgID, projectID).First(&buyer).Error)
+	assert.Equal(t, "10", buyer.CreditBalance.String())
+	var n int64
+	db.Model(&domain.Payment{}).Where("stripe_payment_intent_id = ?", "pi_unverified").Count(&n)
+	assert.Equal(t, int64(1), n)
+}
+
+func TestWebhook_BuysBetweenSubAccounts(t *testing.T) {
+	wh, db := setupWebhookTest(t)
+	require.NoError(t, db.AutoMigrate(&domain.SubAccount{}))
+	sellerOrgID, buyerOrgID, projectID, listingID := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	require.NoError(t, db.Create(&domain.Org{OrgID: buyerOrgID, OrgName: "Buyer", OrgCode: "BU-000001", CountryCode: "SG"}).Error)
+	verifiedSeller(t, db, sellerOrgID)
+	fund := domain.SubAccount{OrgID: sellerOrgID, Name: "Fund"}
+	require.NoError(t, db.Create(&fund).Error)
+	client := domain.SubAccount{OrgID: buyerOrgID, Name: "Client"}
+	require.NoError(t, db.Create(&client).Error)
+
+	// The seller holds 100: 40 in the fund, all of it listed, and 60 in its main account.
+	require.NoError(t, db.Create(&domain.Holding{
+		OrgID: sellerOrgID, ProjectID: projectID, CreditBalance: decimal.NewFromInt(100), LockedForSale: decimal.NewFromInt(40),
+	}).Error)
+	require.NoError(t, db.Create(&domain.SubAccountHolding{
+		SubAccountID: fund.SubAccountID, ProjectID: projectID, OrgID: sellerOrgID, CreditBalance: decimal.NewFromInt(40), LockedForSale: decimal.NewFromInt(40),
+	}).Error)
+	require.NoError(t, db.Create(&domain.Listing{
+		ListingID: listingID, ProjectID: projectID, SellerID: &sellerOrgID, SubAccountID: &fund.SubAccountID,
+		CreditsAvailable: decimal.NewFromInt(40), PricePerCredit: decimal.NewFromInt(5), Status: "open",
+	}).Error)
+
+	buy := func(piID, subAccountID string) {
+		pi := paymentIntentObject{
+			ID: piID, AmountReceived: 5000, Currency: "sgd", Status: "succeeded",
+			Metadata: map[string]string{
+				"listing_id": listingID.String(), "buyer_org_id": buyerOrgID.String(), "credits_amount": "10",
+				"buyer_sub_account_id": subAccountID,
+			},
+		}
+		require.NoError(t, wh.handlePaymentIntentSucceeded(pi, "evt_"+piID, []byte(`{}`)))
+	}
+	buy("pi_into_client", client.SubAccountID.String())
+	// A sub-account of another org is not the buyer's: the credits land in its main account.
+	buy("pi_into_main", fund.SubAccountID.String())
+
+	var sold domain.SubAccountHolding
+	require.NoError(t, db.Where("sub_account_id = ? AND project_id = ?", fund.SubAccountID, projectID).First(&sold).Error)
+	assert.Equal(t, "20", sold.CreditBalance.String())
+	assert.Equal(t, "20", sold.LockedForSale.String())
+	var seller domain.Holding
+	require.NoError(t, db.Where("org_id = ? AND project_id = ?", sellerOrgID, projectID).First(&seller).Error)
+	assert.Equal(t, "80", seller.CreditBalance.String())
+	assert.Equal(t, "20", seller.LockedForSale.String())
+
+	var bought domain.SubAccountHolding
+	require.NoError(t, db.Where("sub_account_id = ? AND project_id = ?", client.SubAccountID, projectID).First(&bought).Error)
+	assert.Equal(t, "10", bought.CreditBalance.String())
+	assert.Equal(t, "0", bought.LockedForSale.String())
+	var buyer domain.Holding
+	require.NoError(t, db.Where("org_id = ? AND project_id = ?", buyerOrgID, projectID).First(&buyer).Error)
+	assert.Equal(t, "20", buyer.CreditBalance.String())
+
+	var txRecord domain.Transaction
+	require.NoError(t, db.Where("type = ? AND to_sub_account_id = ?", "buy", client.SubAccountID).First(&txRecord).Error)
+	require.NotNil(t, txRecord.SubAccountID)
+	assert.Equal(t, fund.SubAccountID, *txRecord.SubAccountID)
+	var n int64
+	db.Model(&domain.Transaction{}).Where("type = ? AND to_sub_account_id IS NULL", "buy").Count(&n)
+	assert.Equal(t, int64(1), n)
 }
@@ -0,0 +1,217 @@
+package platform
+
+import (
+	auditsvc "troo-backend/internal/application/audit"
+	platformsvc "troo-backend/internal/application/platform"
+	"troo-backend/internal/domain"
+	"troo-backend/internal/middleware"
+	"troo-backend/internal/pkg/decimal"
+	"troo-backend/internal/pkg/response"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+var backofficeErrorStatus = map[string]int{
+	"Search query is required":                            400,
+	"Reason is required":                                  400,
+	"Invalid adjustment amount":                           400,
+	"Adjustment would leave balance below locked credits": 400,
+	"Organization not found":                              404,
+	"Listing not found":                                   404,
+	"Listing is not open":                                 409,
+	"Seller holding does not cover the listing":           409,
+}
+
+// GET /api/v1/platform/search-users?q=
+func (h *Handlers) SearchUsers(c *fiber.Ctx) error {
+	out, err := h.Service.SearchUsers(c.Context(), c.Query("q"))
+	if err != nil {
+		return backofficeError(c, err)
+	}
+	return response.Success(c, "Users fetched successfully", out, nil)
+}
+
+// GET /api/v1/platform/search-orgs?q=
+func (h *Handlers) SearchOrgs(c *fiber.Ctx) error {
+	out, err := h.Service.SearchOrgs(c.Context(), c.Query("q"))
+	if err != nil {
+		return backofficeError(c, err)
+	}
+	return response.Success(c, "Organizations fetched successfully", out, nil)
+}
+
+// GET /api/v1/platform/view-org-holdings/:org_id
+func (h *Handlers) ViewOrgHoldings(c *fiber.Ctx) error {
+	orgID, ok := h.pathOrg(c)
+	if !ok {
+		return nil
+	}
+	data, err := h.Holdings.ViewHoldings(c.Context(), orgID)
+	if err != nil {
+		return backofficeError(c, err)
+	}
+	return response.Success(c, "Holdings fetched successfully", data, nil)
+}
+
+// GET /api/v1/platform/view-org-transactions/:org_id
+func (h *Handlers) ViewOrgTransactions(c *fiber.Ctx) error {
+	orgID, ok := h.pathOrg(c)
+	if !ok {
+		return nil
+	}
+	data, errMsg, code := h.Transactions.ViewTransactions(c.Context(), orgID.String())
+	if errMsg != "" {
+		return response.Error(c, errMsg, code, nil)
+	}
+	return response.Success(c, "Transactions fetched successfully", data, nil)
+}
+
+// GET /api/v1/platform/view-org-listings/:org_id
+func (h *Handlers) ViewOrgListings(c *fiber.Ctx) error {
+	orgID, ok := h.pathOrg(c)
+	if !ok {
+		return nil
+	}
+	data, err := h.Listings.GetOrgListings(c.Context(), orgID)
+	if err != nil {
+		return backofficeError(c, err)
+	}
+	return response.Success(c, "Listings fetched successfully", data, nil)
+}
+
+// GET /api/v1/platform/view-org-adjustments/:org_id
+func (h *Handlers) ViewOrgAdjustments(c *fiber.Ctx) error {
+	orgID, ok := h.pathOrg(c)
+	if !ok {
+		return nil
+	}
+	data, err := h.Service.ListAdjustments(c.Context(), orgID)
+	if err != nil {
+		return backofficeError(c, err)
+	}
+	return response.Success(c, "Holding adjustments fetched successfully", data, nil)
+}
+
+// POST /api/v1/platform/force-close-listing — body: listing_id, reason (required).
+func (h *Handlers) ForceCloseListing(c *fiber.Ctx) error {
+	actorID, ok := operatorID(c)
+	if !ok {
+		return response.Unauthorized(c, "Unauthorized")
+	}
+	var body struct {
+		ListingID string `json:"listing_id"`
+		Reason    string `json:"reason"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return response.Error(c, "Invalid request body", 400, nil)
+	}
+	listingID, err := uuid.Parse(body.ListingID)
+	if err != nil {
+		return response.Error(c, "Invalid listing_id", 400, nil)
+	}
+	listing, err := h.Service.ForceCloseListing(c.Context(), actorID, listingID, body.Reason)
+	if err != nil {
+		return backofficeError(c, err)
+	}
+	middleware.AuditPlatform(c, h.Audit, auditsvc.Entry{
+		OrgID:      listing.SellerID,
+		Action:     auditsvc.ActionListingForceClosed,
+		TargetType: "listing",
+		TargetID:   listing.ListingID.String(),
+		After:      fiber.Map{"status": listing.Status, "remaining_credits": listing.CreditsAvailable, "reason": body.Reason},
+	})
+	return response.Success(c, "Listing closed successfully", listing, nil)
+}
+
+// POST /api/v1/platform/adjust-holding — body: org_id, project_id, delta (signed), reason (required).
+func (h *Handlers) AdjustHolding(c *fiber.Ctx) error {
+	actorID, ok := operatorID(c)
+	if !ok {
+		return response.Unauthorized(c, "Unauthorized")
+	}
+	var body struct {
+		OrgID     string          `json:"org_id"`
+		ProjectID string          `json:"project_id"`
+		Delta     decimal.Decimal `json:"delta"`
+		Reason    string          `json:"reason"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return response.Error(c, "Invalid request body", 400, nil)
+	}
+	orgID, err := uuid.Parse(body.OrgID)
+	if err != nil {
+		return response.Error(c, "Invalid org_id", 400, nil)
+	}
+	projectID, err := uuid.Parse(body.ProjectID)
+	if err != nil {
+		return response.Error(c, "Invalid project_id", 400, nil)
+	}
+	adj, err := h.Service.AdjustHolding(c.Context(), platformsvc.AdjustHoldingInput{
+		ActorID:   actorID,
+		OrgID:     orgID,
+		ProjectID: projectID,
+		Delta:     body.Delta.Round(domain.CreditScale),
+		Reason:    body.Reason,
+	})
+	if err != nil {
+		return backofficeError(c, err)
+	}
+	middleware.AuditPlatform(c, h.Audit, auditsvc.Entry{
+		OrgID:      &adj.OrgID,
+		Action:     auditsvc.ActionHoldingAdjusted,
+		TargetType: "project",
+		TargetID:   adj.ProjectID.String(),
+		Before:     fiber.Map{"credit_balance": adj.BalanceBefore},
+		After:      fiber.Map{"credit_balance": adj.BalanceAfter, "delta": adj.Delta, "reason": adj.Reason},
+	})
+	return response.Success(c, "Holding adjusted successfully", adj, nil)
+}
+
+// POST /api/v1/platform/impersonate — body: org_id. Until stopped, GET requests elsewhere see a viewer of that org.
+func (h *Handlers) StartImpersonation(c *fiber.Ctx) error {
+	var body struct {
+		OrgID string `json:"org_id"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return response.Error(c, "Invalid request body", 400, nil)
+	}
+	orgID, err := uuid.Parse(body.OrgID)
+	if err != nil {
+		return response.Error(c, "Invalid org_id", 400, nil)
+	}
+	org, err := h.Service.FindOrg(c.Context(), orgID)
+	if err != nil {
+		return backofficeError(c, err)
+	}
+	id := org.OrgID.String()
+	middleware.SetImpersonation(c, &id)
+	return response.Success(c, "Impersonation started (read-only)", fiber.Map{"org_id": id, "org_name": org.OrgName}, nil)
+}
+
+// DELETE /api/v1/platform/impersonate
+func (h *Handlers) StopImpersonation(c *fiber.Ctx) error {
+	middleware.SetImpersonation(c, nil)
+	return response.Success(c, "Impersonation stopped", nil, nil)
+}
+
+// pathOrg parses :org_id and checks the org exists; on failure it has already written the response.
+func (h *Handlers) pathOrg(c *fiber.Ctx) (uuid.UUID, bool) {
+	orgID, err := uuid.Parse(c.Params("org_id"))
+	if err != nil {
+		_ = response.Error(c, "Invalid org_id", 400, nil)
+		return uuid.Nil, false
+	}
+	if _, err := h.Service.FindOrg(c.Context(), orgID); err != nil {
+		_ = backofficeError(c, err)
+		return uuid.Nil, false
+	}
+	return orgID, true
+}
+
+func backofficeError(c *fiber.Ctx, err error) error {
+	if code, ok := backofficeErrorStatus[err.Error()]; ok {
+		return response.Error(c, err.Error(), code, nil)
+	}
+	return response.Error(c, "Internal Server Error", 500, nil)
+}
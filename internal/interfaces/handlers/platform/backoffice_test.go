@@ -0,0 +1,122 @@
+package platform
+
+import (
+	"testing"
+
+	"troo-backend/internal/domain"
+	"troo-backend/internal/pkg/constants"
+	"troo-backend/internal/pkg/decimal"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (f *platformFixture) org(t *testing.T, name, code string) uuid.UUID {
+	org := domain.Org{OrgName: name, OrgCode: code, CountryCode: "IN"}
+	require.NoError(t, f.db.Create(&org).Error)
+	return org.OrgID
+}
+
+func TestBackoffice_SearchOrgs(t *testing.T) {
+	f := setupPlatformTest(t)
+	support := f.user(t, "support@troo.earth", constants.Viewer, constants.PlatformSupport)
+	f.org(t, "Acme Carbon", "ACME")
+	f.org(t, "Green 100% Ltd", "GRN")
+
+	code, result := f.do(t, "GET", "/search-orgs?q=acme", support, nil)
+	require.Equal(t, 200, code)
+	assert.Len(t, result["data"], 1)
+	// LIKE wildcards in the query are matched literally.
+	code, result = f.do(t, "GET", "/search-orgs?q=%25", support, nil)
+	require.Equal(t, 200, code)
+	assert.Len(t, result["data"], 1)
+	code, _ = f.do(t, "GET", "/search-orgs?q=", support, nil)
+	assert.Equal(t, 400, code)
+}
+
+func TestBackoffice_AdjustHolding(t *testing.T) {
+	f := setupPlatformTest(t)
+	support := f.user(t, "support@troo.earth", constants.Viewer, constants.PlatformSupport)
+	admin := f.user(t, "ops@troo.earth", constants.Viewer, constants.PlatformAdmin)
+	orgID := f.org(t, "Acme Carbon", "ACME")
+	projectID := uuid.New()
+	require.NoError(t, f.db.Create(&domain.Holding{
+		OrgID: orgID, ProjectID: projectID, CreditBalance: decimal.NewFromInt(100), LockedForSale: decimal.NewFromInt(40),
+	}).Error)
+	body := func(delta, reason string) map[string]string {
+		return map[string]string{"org_id": orgID.String(), "project_id": projectID.String(), "delta": delta, "reason": reason}
+	}
+
+	code, _ := f.do(t, "POST", "/adjust-holding", support, body("5", "Registry correction"))
+	assert.Equal(t, 403, code)
+	code, _ = f.do(t, "POST", "/adjust-holding", admin, body("5", " "))
+	assert.Equal(t, 400, code)
+	code, result := f.do(t, "POST", "/adjust-holding", admin, body("-61", "Registry correction"))
+	assert.Equal(t, 400, code)
+	assert.Equal(t, "Adjustment would leave balance below locked credits", result["error"].(map[string]interface{})["message"])
+
+	code, _ = f.do(t, "POST", "/adjust-holding", admin, body("-60", "Registry correction"))
+	require.Equal(t, 200, code)
+	var h domain.Holding
+	require.NoError(t, f.db.Where("org_id = ?", orgID).First(&h).Error)
+	assert.True(t, h.CreditBalance.Equal(decimal.NewFromInt(40)))
+
+	code, result = f.do(t, "GET", "/view-org-adjustments/"+orgID.String(), support, nil)
+	require.Equal(t, 200, code)
+	require.Len(t, result["data"], 1)
+	adj := result["data"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, admin.String(), adj["adjusted_by"])
+	assert.Equal(t, "Registry correction", adj["reason"])
+}
+
+func TestBackoffice_ForceCloseListing(t *testing.T) {
+	f := setupPlatformTest(t)
+	admin := f.user(t, "ops@troo.earth", constants.Viewer, constants.PlatformAdmin)
+	seller := f.org(t, "Acme Carbon", "ACME")
+	projectID := uuid.New()
+	require.NoError(t, f.db.Create(&domain.Holding{
+		OrgID: seller, ProjectID: projectID, CreditBalance: decimal.NewFromInt(100), LockedForSale: decimal.NewFromInt(40),
+	}).Error)
+	listing := domain.Listing{ProjectID: projectID, SellerID: &seller, CreditsAvailable: decimal.NewFromInt(40),
+		PricePerCredit: decimal.NewFromInt(10), ProjectName: "Mangroves", Status: "open"}
+	require.NoError(t, f.db.Create(&listing).Error)
+
+	code, _ := f.do(t, "POST", "/force-close-listing", admin, map[string]string{"listing_id": listing.ListingID.String()})
+	assert.Equal(t, 400, code)
+	code, _ = f.do(t, "POST", "/force-close-listing", admin, map[string]string{"listing_id": listing.ListingID.String(), "reason": "Fraud report"})
+	require.Equal(t, 200, code)
+	code, _ = f.do(t, "POST", "/force-close-listing", admin, map[string]string{"listing_id": listing.ListingID.String(), "reason": "Fraud report"})
+	assert.Equal(t, 409, code)
+
+	var h domain.Holding
+	require.NoError(t, f.db.Where("org_id = ?", seller).First(&h).Error)
+	assert.True(t, h.LockedForSale.IsZero())
+	var ev domain.ListingEvent
+	require.NoError(t, f.db.Where("listing_id = ?", listing.ListingID).First(&ev).Error)
+	assert.Equal(t, "CANCELLED", ev.EventType)
+	assert.Contains(t, string(ev.EventData), "Fraud report")
+}
+
+func TestBackoffice_ForceCloseListing_FailsWhenHoldingDoesNotCoverListing(t *testing.T) {
+	f := setupPlatformTest(t)
+	admin := f.user(t, "ops@troo.earth", constants.Viewer, constants.PlatformAdmin)
+	seller := f.org(t, "Acme Carbon", "ACME")
+	projectID := uuid.New()
+	require.NoError(t, f.db.Create(&domain.Holding{
+		OrgID: seller, ProjectID: projectID, CreditBalance: decimal.NewFromInt(100), LockedForSale: decimal.NewFromInt(10),
+	}).Error)
+	listing := domain.Listing{ProjectID: projectID, SellerID: &seller, CreditsAvailable: decimal.NewFromInt(40),
+		PricePerCredit: decimal.NewFromInt(10), ProjectName: "Mangroves", Status: "open"}
+	require.NoError(t, f.db.Create(&listing).Error)
+
+	code, result := f.do(t, "POST", "/force-close-listing", admin, map[string]string{"listing_id": listing.ListingID.String(), "reason": "Fraud report"})
+	assert.Equal(t, 409, code)
+	assert.Equal(t, "Seller holding does not cover the listing", result["error"].(map[string]interface{})["message"])
+
+	var h domain.Holding
+	require.NoError(t, f.db.Where("org_id = ?", seller).First(&h).Error)
+	assert.Equal(t, "10", h.LockedForSale.String())
+	require.NoError(t, f.db.Where("listing_id = ?", listing.ListingID).First(&listing).Error)
+	assert.Equal(t, "open", listing.Status)
+}
@@ -0,0 +1,112 @@
+package platform
+
+import (
+	auditsvc "troo-backend/internal/application/audit"
+	holdsvc "troo-backend/internal/application/holdings"
+	listsvc "troo-backend/internal/application/listings"
+	platformsvc "troo-backend/internal/application/platform"
+	txsvc "troo-backend/internal/application/transactions"
+	verifysvc "troo-backend/internal/application/verification"
+	"troo-backend/internal/middleware"
+	"troo-backend/internal/pkg/response"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// Handlers serve the operator-only /api/v1/platform routes; AuthorizePlatformPermission guards each one.
+type Handlers struct {
+	Service      *platformsvc.Service
+	Holdings     *holdsvc.Service
+	Transactions *txsvc.Service
+	Listings     *listsvc.Service
+	Audit        *auditsvc.Service
+	Verification *verifysvc.Service
+}
+
+var operatorErrorStatus = map[string]int{
+	"Invalid platform role":                    400,
+	"You cannot change your own platform role": 400,
+	"User not found":                           404,
+	"Platform operator not found":              404,
+}
+
+// GET /api/v1/platform/view-operators
+func (h *Handlers) ViewOperators(c *fiber.Ctx) error {
+	out, err := h.Service.ListOperators(c.Context())
+	if err != nil {
+		return response.Error(c, "Internal Server Error", 500, nil)
+	}
+	return response.Success(c, "Platform operators fetched successfully", out, nil)
+}
+
+// POST /api/v1/platform/grant-operator — body: email, role (platform_admin | platform_support).
+func (h *Handlers) GrantOperator(c *fiber.Ctx) error {
+	actorID, ok := operatorID(c)
+	if !ok {
+		return response.Unauthorized(c, "Unauthorized")
+	}
+	var body struct {
+		Email string `json:"email"`
+		Role  string `json:"role"`
+	}
+	if err := c.BodyParser(&body); err != nil || body.Email == "" || body.Role == "" {
+		return response.Error(c, "email and role are required", 400, nil)
+	}
+	op, err := h.Service.GrantOperator(c.Context(), actorID, body.Email, body.Role)
+	if err != nil {
+		return operatorError(c, err)
+	}
+	middleware.AuditPlatform(c, h.Audit, auditsvc.Entry{
+		Action:     auditsvc.ActionPlatformRoleGranted,
+		TargetType: "user",
+		TargetID:   op.UserID.String(),
+		After:      fiber.Map{"platform_role": op.Role},
+	})
+	return response.Success(c, "Platform role granted successfully", op, nil)
+}
+
+// DELETE /api/v1/platform/revoke-operator — body: user_id.
+func (h *Handlers) RevokeOperator(c *fiber.Ctx) error {
+	actorID, ok := operatorID(c)
+	if !ok {
+		return response.Unauthorized(c, "Unauthorized")
+	}
+	var body struct {
+		UserID string `json:"user_id"`
+	}
+	if err := c.BodyParser(&body); err != nil || body.UserID == "" {
+		return response.Error(c, "user_id is required", 400, nil)
+	}
+	userID, err := uuid.Parse(body.UserID)
+	if err != nil {
+		return response.Error(c, "Invalid user_id", 400, nil)
+	}
+	if err := h.Service.RevokeOperator(c.Context(), actorID, userID); err != nil {
+		return operatorError(c, err)
+	}
+	middleware.AuditPlatform(c, h.Audit, auditsvc.Entry{
+		Action:     auditsvc.ActionPlatformRoleRevoked,
+		TargetType: "user",
+		TargetID:   userID.String(),
+	})
+	return response.Success(c, "Platform role revoked successfully", nil, nil)
+}
+
+func operatorError(c *fiber.Ctx, err error) error {
+	if code, ok := operatorErrorStatus[err.Error()]; ok {
+		return response.Error(c, err.Error(), code, nil)
+	}
+	return response.Error(c, "Internal Server Error", 500, nil)
+}
+
+// operatorID returns the session user's id; operators need not belong to an org.
+func operatorID(c *fiber.Ctx) (uuid.UUID, bool) {
+	m, ok := middleware.GetUser(c).(map[string]interface{})
+	if !ok {
+		return uuid.Nil, false
+	}
+	s, _ := m["user_id"].(string)
+	id, err := uuid.Parse(s)
+	return id, err == nil
+}
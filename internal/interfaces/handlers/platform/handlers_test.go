@@ -0,0 +1,173 @@
+package platform
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	platformsvc "troo-backend/internal/application/platform"
+	verifysvc "troo-backend/internal/application/verification"
+	"troo-backend/internal/domain"
+	"troo-backend/internal/middleware"
+	"troo-backend/internal/pkg/constants"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/glebarez/sqlite"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type platformFixture struct {
+	db  *gorm.DB
+	rdb *redis.Client
+	app *fiber.App
+}
+
+// setupPlatformTest builds the session the way login does: X-Test-User is loaded with its platform role.
+func setupPlatformTest(t *testing.T) *platformFixture {
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() {
+		rdb.Close()
+		mr.Close()
+	})
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&domain.User{}, &domain.OrgMembership{}, &domain.PlatformOperator{}, &domain.Org{}, &domain.Holding{},
+		&domain.Listing{}, &domain.ListingEvent{}, &domain.HoldingAdjustment{}, &domain.OrgVerification{}, &domain.SubAccount{}, &domain.SubAccountHolding{}))
+
+	f := &platformFixture{db: db, rdb: rdb}
+	h := &Handlers{Service: &platformsvc.Service{DB: db, Rdb: rdb}, Verification: &verifysvc.Service{DB: db}}
+
+	f.app = fiber.New()
+	f.app.Use(func(c *fiber.Ctx) error {
+		var u domain.User
+		if err := db.Preload("PlatformOperator").Where("user_id = ?", c.Get("X-Test-User")).First(&u).Error; err != nil {
+			return c.Next()
+		}
+		su := middleware.SessionUser{UserID: u.UserID.String(), Email: u.Email, Role: u.Role}
+		if u.PlatformOperator != nil {
+			su.PlatformRole = &u.PlatformOperator.Role
+		}
+		middleware.SetSessionUser(c, su)
+		return c.Next()
+	})
+	ok := func(c *fiber.Ctx) error { return c.SendStatus(200) }
+	f.app.Post("/marketplace/admin-sync", middleware.AuthorizePlatformPermission(constants.SyncRegistry), ok)
+	f.app.Get("/view-operators", middleware.AuthorizePlatformPermission(constants.ManageOperators), h.ViewOperators)
+	f.app.Post("/grant-operator", middleware.AuthorizePlatformPermission(constants.ManageOperators), h.GrantOperator)
+	f.app.Delete("/revoke-operator", middleware.AuthorizePlatformPermission(constants.ManageOperators), h.RevokeOperator)
+	f.app.Get("/search-orgs", middleware.AuthorizePlatformPermission(constants.ViewPlatformData), h.SearchOrgs)
+	f.app.Get("/view-org-adjustments/:org_id", middleware.AuthorizePlatformPermission(constants.ViewPlatformData), h.ViewOrgAdjustments)
+	f.app.Post("/force-close-listing", middleware.AuthorizePlatformPermission(constants.ForceCloseListings), h.ForceCloseListing)
+	f.app.Post("/adjust-holding", middleware.AuthorizePlatformPermission(constants.AdjustHoldings), h.AdjustHolding)
+	f.app.Get("/view-verifications", middleware.AuthorizePlatformPermission(constants.ViewPlatformData), h.ViewVerifications)
+	f.app.Post("/start-verification-review", middleware.AuthorizePlatformPermission(constants.ReviewVerifications), h.StartVerificationReview)
+	f.app.Post("/approve-verification", middleware.AuthorizePlatformPermission(constants.ReviewVerifications), h.ApproveVerification)
+	f.app.Post("/reject-verification", middleware.AuthorizePlatformPermission(constants.ReviewVerifications), h.RejectVerification)
+	return f
+}
+
+func (f *platformFixture) user(t *testing.T, email, role string, platformRole string) uuid.UUID {
+	id := uuid.New()
+	orgID := uuid.New()
+	require.NoError(t, f.db.Create(&domain.User{
+		UserID: id, UserName: email, Email: email, PasswordHash: "x", Fullname: "Test User", Role: role, OrgID: &orgID,
+	}).Error)
+	if platformRole != "" {
+		require.NoError(t, f.db.Create(&domain.PlatformOperator{UserID: id, Role: platformRole}).Error)
+	}
+	return id
+}
+
+func (f *platformFixture) do(t *testing.T, method, path string, user uuid.UUID, body interface{}) (int, map[string]interface{}) {
+	b, _ := json.Marshal(body)
+	req := httptest.NewRequest(method, path, bytes.NewReader(b))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Test-User", user.String())
+	resp, err := f.app.Test(req)
+	require.NoError(t, err)
+	var result map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&result)
+	return resp.StatusCode, result
+}
+
+func TestPlatformRoutes_OrgSuperadminIsNotAnOperator(t *testing.T) {
+	f := setupPlatformTest(t)
+	owner := f.user(t, "owner@example.com", constants.Superadmin, "")
+
+	code, result := f.do(t, "POST", "/marketplace/admin-sync", owner, nil)
+	assert.Equal(t, 403, code)
+	assert.Equal(t, "Platform operator access required", result["error"].(map[string]interface{})["message"])
+
+	code, _ = f.do(t, "POST", "/marketplace/admin-sync", uuid.New(), nil)
+	assert.Equal(t, 401, code)
+}
+
+func TestPlatformRoutes_PermissionSetPerPlatformRole(t *testing.T) {
+	f := setupPlatformTest(t)
+	support := f.user(t, "support@troo.earth", constants.Viewer, constants.PlatformSupport)
+	admin := f.user(t, "ops@troo.earth", constants.Viewer, constants.PlatformAdmin)
+
+	code, _ := f.do(t, "POST", "/marketplace/admin-sync", support, nil)
+	assert.Equal(t, 403, code)
+	code, _ = f.do(t, "POST", "/marketplace/admin-sync", admin, nil)
+	assert.Equal(t, 200, code)
+	code, _ = f.do(t, "GET", "/view-operators", support, nil)
+	assert.Equal(t, 403, code)
+}
+
+func TestBootstrapAdmin_OnlyWhileNoPlatformAdminExists(t *testing.T) {
+	f := setupPlatformTest(t)
+	svc := &platformsvc.Service{DB: f.db, Rdb: f.rdb}
+	first := f.user(t, "ops@troo.earth", constants.Viewer, constants.PlatformSupport)
+	f.user(t, "second@troo.earth", constants.Viewer, "")
+
+	_, err := svc.BootstrapAdmin(context.Background(), "nobody@troo.earth")
+	assert.EqualError(t, err, "User not found")
+
+	op, err := svc.BootstrapAdmin(context.Background(), "Ops@Troo.earth")
+	require.NoError(t, err)
+	assert.Equal(t, first, op.UserID)
+	code, _ := f.do(t, "GET", "/view-operators", first, nil)
+	assert.Equal(t, 200, code)
+
+	_, err = svc.BootstrapAdmin(context.Background(), "second@troo.earth")
+	assert.EqualError(t, err, "A platform_admin already exists")
+}
+
+func TestGrantAndRevokeOperator(t *testing.T) {
+	f := setupPlatformTest(t)
+	admin := f.user(t, "ops@troo.earth", constants.Viewer, constants.PlatformAdmin)
+	agent := f.user(t, "agent@troo.earth", constants.Viewer, "")
+
+	require.NoError(t, f.rdb.SAdd(context.Background(), "user_sessions:"+agent.String(), "sid-1").Err())
+	require.NoError(t, f.rdb.Set(context.Background(), middleware.SessionRedisPrefix+"sid-1", "{}", 0).Err())
+
+	code, _ := f.do(t, "POST", "/grant-operator", admin, map[string]string{"email": "agent@troo.earth", "role": "superadmin"})
+	assert.Equal(t, 400, code)
+	code, _ = f.do(t, "POST", "/grant-operator", admin, map[string]string{"email": "nobody@troo.earth", "role": constants.PlatformSupport})
+	assert.Equal(t, 404, code)
+	code, _ = f.do(t, "POST", "/grant-operator", admin, map[string]string{"email": "Agent@Troo.earth", "role": constants.PlatformSupport})
+	require.Equal(t, 200, code)
+	// The new role applies from the agent's next login.
+	assert.Equal(t, int64(0), f.rdb.Exists(context.Background(), middleware.SessionRedisPrefix+"sid-1").Val())
+
+	code, result := f.do(t, "GET", "/view-operators", admin, nil)
+	require.Equal(t, 200, code)
+	assert.Len(t, result["data"], 2)
+
+	code, _ = f.do(t, "DELETE", "/revoke-operator", admin, map[string]string{"user_id": admin.String()})
+	assert.Equal(t, 400, code)
+	code, _ = f.do(t, "DELETE", "/revoke-operator", admin, map[string]string{"user_id": agent.String()})
+	assert.Equal(t, 200, code)
+	code, _ = f.do(t, "DELETE", "/revoke-operator", admin, map[string]string{"user_id": agent.String()})
+	assert.Equal(t, 404, code)
+}
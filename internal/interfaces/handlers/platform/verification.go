@@ -0,0 +1,87 @@
+package platform
+
+import (
+	auditsvc "troo-backend/internal/application/audit"
+	verifysvc "troo-backend/internal/application/verification"
+	"troo-backend/internal/domain"
+	"troo-backend/internal/middleware"
+	"troo-backend/internal/pkg/response"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+var verificationErrorStatus = map[error]int{
+	verifysvc.ErrNotSubmitted:   409,
+	verifysvc.ErrNotUnderReview: 409,
+}
+
+// GET /api/v1/platform/view-verifications?status= — the review queue, oldest submission first.
+func (h *Handlers) ViewVerifications(c *fiber.Ctx) error {
+	out, err := h.Verification.List(c.Context(), c.Query("status"))
+	if err != nil {
+		if err.Error() == "Invalid status" {
+			return response.Error(c, err.Error(), 400, nil)
+		}
+		return response.Error(c, "Internal Server Error", 500, nil)
+	}
+	return response.Success(c, "Verifications fetched successfully", out, nil)
+}
+
+// POST /api/v1/platform/start-verification-review — body: org_id. Moves a submitted verification to under_review.
+func (h *Handlers) StartVerificationReview(c *fiber.Ctx) error {
+	return h.reviewVerification(c, auditsvc.ActionVerificationReviewStarted, "Verification review started",
+		func(orgID, actorID uuid.UUID, _ string) (*domain.OrgVerification, error) {
+			return h.Verification.StartReview(c.Context(), orgID, actorID)
+		})
+}
+
+// POST /api/v1/platform/approve-verification — body: org_id. The org may sell and transfer credits from now on.
+func (h *Handlers) ApproveVerification(c *fiber.Ctx) error {
+	return h.reviewVerification(c, auditsvc.ActionOrgVerified, "Organization verified",
+		func(orgID, actorID uuid.UUID, _ string) (*domain.OrgVerification, error) {
+			return h.Verification.Approve(c.Context(), orgID, actorID)
+		})
+}
+
+// POST /api/v1/platform/reject-verification — body: org_id, reason (required, shown to the org).
+func (h *Handlers) RejectVerification(c *fiber.Ctx) error {
+	return h.reviewVerification(c, auditsvc.ActionVerificationRejected, "Verification rejected",
+		func(orgID, actorID uuid.UUID, reason string) (*domain.OrgVerification, error) {
+			return h.Verification.Reject(c.Context(), orgID, actorID, reason)
+		})
+}
+
+// reviewVerification parses the body, applies one review step and records it against the org.
+func (h *Handlers) reviewVerification(c *fiber.Ctx, action, message string, step func(orgID, actorID uuid.UUID, reason string) (*domain.OrgVerification, error)) error {
+	actorID, ok := operatorID(c)
+	if !ok {
+		return response.Unauthorized(c, "Unauthorized")
+	}
+	var body struct {
+		OrgID  string `json:"org_id"`
+		Reason string `json:"reason"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return response.Error(c, "Invalid request body", 400, nil)
+	}
+	orgID, err := uuid.Parse(body.OrgID)
+	if err != nil {
+		return response.Error(c, "Invalid org_id", 400, nil)
+	}
+	v, err := step(orgID, actorID, body.Reason)
+	if err != nil {
+		if code, ok := verificationErrorStatus[err]; ok {
+			return response.Error(c, err.Error(), code, nil)
+		}
+		return backofficeError(c, err)
+	}
+	middleware.AuditPlatform(c, h.Audit, auditsvc.Entry{
+		OrgID:      &v.OrgID,
+		Action:     action,
+		TargetType: "org",
+		TargetID:   v.OrgID.String(),
+		After:      fiber.Map{"status": v.Status, "rejection_reason": v.RejectionReason},
+	})
+	return response.Success(c, message, v, nil)
+}
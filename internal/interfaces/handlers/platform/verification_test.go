@@ -0,0 +1,101 @@
+package platform
+
+import (
+	"context"
+	"testing"
+
+	orgsvc "troo-backend/internal/application/org"
+	verifysvc "troo-backend/internal/application/verification"
+	"troo-backend/internal/domain"
+	"troo-backend/internal/pkg/constants"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerification_ReviewWorkflow(t *testing.T) {
+	f := setupPlatformTest(t)
+	support := f.user(t, "support@troo.earth", constants.Viewer, constants.PlatformSupport)
+	admin := f.user(t, "ops@troo.earth", constants.Viewer, constants.PlatformAdmin)
+	orgID := f.org(t, "Acme Carbon", "ACME")
+	svc := &verifysvc.Service{DB: f.db}
+	ctx := context.Background()
+	member := uuid.New()
+	body := map[string]string{"org_id": orgID.String()}
+
+	// Both documents are required before submitting.
+	_, err := svc.Submit(ctx, orgID, member)
+	assert.Equal(t, verifysvc.ErrDocumentsMissing, err)
+	orgs := &orgsvc.Service{DB: f.db}
+	_, err = orgs.UpdateOrg(ctx, orgID, map[string]interface{}{"registration_id": "201912345A", "incorporation_doc_url": "https://cdn.example.com/acme.pdf"})
+	require.NoError(t, err)
+	view, err := svc.View(ctx, orgID)
+	require.NoError(t, err)
+	assert.Equal(t, verifysvc.StatusUnverified, view.Status)
+	for _, r := range view.Requirements {
+		assert.True(t, r.Satisfied, r.Field)
+	}
+	assert.Equal(t, verifysvc.ErrNotVerified, verifysvc.Require(f.db, orgID))
+
+	_, err = svc.Submit(ctx, orgID, member)
+	require.NoError(t, err)
+	_, err = svc.Submit(ctx, orgID, member)
+	assert.Equal(t, verifysvc.ErrNotSubmittable, err)
+
+	code, result := f.do(t, "GET", "/view-verifications?status=submitted", support, nil)
+	require.Equal(t, 200, code)
+	require.Len(t, result["data"], 1)
+	assert.Equal(t, "ACME", result["data"].([]interface{})[0].(map[string]interface{})["org_code"])
+	code, _ = f.do(t, "GET", "/view-verifications?status=pending", support, nil)
+	assert.Equal(t, 400, code)
+
+	// Support can see the queue but not decide; decisions follow the state machine.
+	code, _ = f.do(t, "POST", "/start-verification-review", support, body)
+	assert.Equal(t, 403, code)
+	code, _ = f.do(t, "POST", "/approve-verification", admin, body)
+	assert.Equal(t, 409, code)
+	code, _ = f.do(t, "POST", "/start-verification-review", admin, body)
+	require.Equal(t, 200, code)
+	code, _ = f.do(t, "POST", "/reject-verification", admin, body)
+	assert.Equal(t, 400, code)
+	code, result = f.do(t, "POST", "/reject-verification", admin, map[string]string{"org_id": orgID.String(), "reason": "Document is illegible"})
+	require.Equal(t, 200, code)
+	assert.Equal(t, verifysvc.StatusRejected, result["data"].(map[string]interface{})["status"])
+	assert.Equal(t, verifysvc.ErrNotVerified, verifysvc.Require(f.db, orgID))
+
+	// A rejected org may resubmit.
+	_, err = svc.Submit(ctx, orgID, member)
+	require.NoError(t, err)
+	code, _ = f.do(t, "POST", "/start-verification-review", admin, body)
+	require.Equal(t, 200, code)
+	code, result = f.do(t, "POST", "/approve-verification", admin, body)
+	require.Equal(t, 200, code)
+	assert.Equal(t, verifysvc.StatusVerified, result["data"].(map[string]interface{})["status"])
+	assert.Nil(t, result["data"].(map[string]interface{})["rejection_reason"])
+	require.NoError(t, verifysvc.Require(f.db, orgID))
+
+	// Changing the reviewed documents sends the org back to unverified.
+	_, err = orgs.UpdateOrg(ctx, orgID, map[string]interface{}{"org_name": "Acme Carbon Pte Ltd"})
+	require.NoError(t, err)
+	require.NoError(t, verifysvc.Require(f.db, orgID))
+	_, err = orgs.UpdateOrg(ctx, orgID, map[string]interface{}{"registration_id": "201999999Z"})
+	require.NoError(t, err)
+	assert.Equal(t, verifysvc.ErrNotVerified, verifysvc.Require(f.db, orgID))
+	view, err = svc.View(ctx, orgID)
+	require.NoError(t, err)
+	assert.Equal(t, verifysvc.StatusUnverified, view.Status)
+}
+
+func TestVerification_EditOutsideGoIsNotVerified(t *testing.T) {
+	f := setupPlatformTest(t)
+	regID := "201912345A"
+	orgID := f.org(t, "Acme Carbon", "ACME")
+	require.NoError(t, f.db.Create(&domain.OrgVerification{OrgID: orgID, Status: verifysvc.StatusVerified, RegistrationID: &regID}).Error)
+	require.NoError(t, f.db.Model(&domain.Org{}).Where("org_id = ?", orgID).Update("registration_id", regID).Error)
+	require.NoError(t, verifysvc.Require(f.db, orgID))
+
+	// Express writes the Orgs row directly, without revoking the verification.
+	require.NoError(t, f.db.Model(&domain.Org{}).Where("org_id = ?", orgID).Update("registration_id", "201999999Z").Error)
+	assert.Equal(t, verifysvc.ErrNotVerified, verifysvc.Require(f.db, orgID))
+}
@@ -0,0 +1,40 @@
+package profiles
+
+import (
+	profilesvc "troo-backend/internal/application/profiles"
+	"troo-backend/internal/pkg/response"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Handlers serve public org profiles; the routes need no session.
+type Handlers struct {
+	Service *profilesvc.Service
+}
+
+// GET /api/v1/public/view-org/:org_code — name, logo, country and verification badge, plus retirement totals
+// when the org shares them.
+func (h *Handlers) ViewOrg(c *fiber.Ctx) error {
+	out, err := h.Service.Profile(c.Context(), c.Params("org_code"))
+	if err != nil {
+		return profileError(c, err)
+	}
+	return response.Success(c, "Organization profile fetched successfully", out, nil)
+}
+
+// GET /api/v1/public/view-retirements/:org_code — the org's retirement certificates, newest first.
+func (h *Handlers) ViewRetirements(c *fiber.Ctx) error {
+	out, err := h.Service.Certificates(c.Context(), c.Params("org_code"))
+	if err != nil {
+		return profileError(c, err)
+	}
+	return response.Success(c, "Organization retirements fetched successfully", out, nil)
+}
+
+func profileError(c *fiber.Ctx, err error) error {
+	switch err {
+	case profilesvc.ErrNotFound, profilesvc.ErrRetirementsHidden:
+		return response.Error(c, err.Error(), 404, nil)
+	}
+	return response.Error(c, "Internal Server Error", 500, nil)
+}
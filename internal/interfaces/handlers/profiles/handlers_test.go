@@ -0,0 +1,90 @@
+package profiles
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	profilesvc "troo-backend/internal/application/profiles"
+	"troo-backend/internal/application/verification"
+	"troo-backend/internal/domain"
+	"troo-backend/internal/pkg/decimal"
+
+	"github.com/glebarez/sqlite"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func setupProfilesTest(t *testing.T) (*fiber.App, *gorm.DB) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&domain.Org{}, &domain.OrgVerification{}, &domain.RetirementCertificate{}, &domain.IcrProject{}))
+	h := &Handlers{Service: &profilesvc.Service{DB: db}}
+	app := fiber.New()
+	app.Get("/view-org/:org_code", h.ViewOrg)
+	app.Get("/view-retirements/:org_code", h.ViewRetirements)
+	return app, db
+}
+
+func get(t *testing.T, app *fiber.App, path string) (int, map[string]interface{}) {
+	resp, err := app.Test(httptest.NewRequest("GET", path, nil))
+	require.NoError(t, err)
+	var out map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&out)
+	return resp.StatusCode, out
+}
+
+func TestPublicProfile_RetirementsAreOptIn(t *testing.T) {
+	app, db := setupProfilesTest(t)
+	regID := "201912345A"
+	org := domain.Org{OrgName: "Acme Carbon", OrgCode: "AC-000001", CountryCode: "SG", RegistrationID: &regID}
+	require.NoError(t, db.Create(&org).Error)
+	reviewedAt := time.Now()
+	require.NoError(t, db.Create(&domain.OrgVerification{OrgID: org.OrgID, Status: verification.StatusVerified, RegistrationID: &regID, ReviewedAt: &reviewedAt}).Error)
+	name := "Mangrove Restoration"
+	projectID := uuid.New()
+	require.NoError(t, db.Create(&domain.IcrProject{ID: projectID, FullName: &name, Status: "validated"}).Error)
+	for i, amount := range []int64{10, 15} {
+		require.NoError(t, db.Create(&domain.RetirementCertificate{
+			OrgID: org.OrgID, ProjectID: projectID, Amount: decimal.NewFromInt(amount), RetiredAt: time.Now().Add(time.Duration(i) * time.Hour),
+			TransactionID: uuid.New(), CertificateNumber: "CERT-" + string(rune('A'+i)), Status: "issued",
+		}).Error)
+	}
+
+	code, out := get(t, app, "/view-org/AC-000001")
+	require.Equal(t, 200, code)
+	data := out["data"].(map[string]interface{})
+	assert.Equal(t, "Acme Carbon", data["org_name"])
+	assert.Equal(t, true, data["verified"])
+	assert.Nil(t, data["retirements"])
+	assert.NotContains(t, data, "org_id")
+	code, _ = get(t, app, "/view-retirements/AC-000001")
+	assert.Equal(t, 404, code)
+
+	require.NoError(t, db.Model(&org).Update("public_retirements", true).Error)
+	code, out = get(t, app, "/view-org/AC-000001")
+	require.Equal(t, 200, code)
+	retirements := out["data"].(map[string]interface{})["retirements"].(map[string]interface{})
+	assert.Equal(t, float64(25), retirements["total_retired"])
+	assert.Equal(t, float64(2), retirements["certificates"])
+	assert.Equal(t, name, retirements["projects"].([]interface{})[0].(map[string]interface{})["project_name"])
+	code, out = get(t, app, "/view-retirements/AC-000001")
+	require.Equal(t, 200, code)
+	certs := out["data"].([]interface{})
+	require.Len(t, certs, 2)
+	assert.Equal(t, "CERT-B", certs[0].(map[string]interface{})["certificate_number"])
+
+	// Changed documents lose the badge; closed orgs have no profile.
+	require.NoError(t, db.Model(&org).Update("registration_id", "201999999Z").Error)
+	_, out = get(t, app, "/view-org/AC-000001")
+	assert.Equal(t, false, out["data"].(map[string]interface{})["verified"])
+	require.NoError(t, db.Model(&org).Update("closed_at", time.Now()).Error)
+	code, _ = get(t, app, "/view-org/AC-000001")
+	assert.Equal(t, 404, code)
+	code, _ = get(t, app, "/view-org/NO-000000")
+	assert.Equal(t, 404, code)
+}
@@ -9,6 +9,7 @@ import (
 
 	retsvc "troo-backend/internal/application/retirements"
 	"troo-backend/internal/domain"
+	"troo-backend/internal/pkg/decimal"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
@@ -50,7 +51,7 @@ func TestViewOrg_Success(t *testing.T) {
 	orgID := uuid.New()
 	require.NoError(t, db.Create(&domain.RetirementCertificate{
 		CertificateID: uuid.New(), OrgID: orgID, ProjectID: uuid.New(),
-		Amount: 100, RetiredAt: time.Now(), TransactionID: uuid.New(),
+		Amount: decimal.NewFromInt(100), RetiredAt: time.Now(), TransactionID: uuid.New(),
 		CertificateNumber: "CERT-TEST-1", Status: "issued",
 	}).Error)
 
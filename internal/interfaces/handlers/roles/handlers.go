@@ -0,0 +1,137 @@
+package roles
+
+import (
+	auditsvc "troo-backend/internal/application/audit"
+	rolesvc "troo-backend/internal/application/roles"
+	"troo-backend/internal/middleware"
+	"troo-backend/internal/pkg/response"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type Handlers struct {
+	Service *rolesvc.Service
+	Audit   *auditsvc.Service
+}
+
+type roleBody struct {
+	RoleID      string   `json:"role_id"`
+	Name        string   `json:"name"`
+	Description *string  `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+var roleErrorStatus = map[string]int{
+	"Role name is required":                         400,
+	"Role name is reserved":                         400,
+	"At least one permission is required":           400,
+	"Permission cannot be granted to a custom role": 400,
+	"Custom role not found":                         404,
+	"A custom role with this name already exists":   409,
+	"Custom role is still assigned to users":        409,
+}
+
+// GET /api/v1/roles/view-permissions — permissions that can be put in a custom role.
+func (h *Handlers) ViewPermissions(c *fiber.Ctx) error {
+	return response.Success(c, "Permissions fetched successfully", rolesvc.GrantablePermissions(), nil)
+}
+
+// GET /api/v1/roles/view-roles
+func (h *Handlers) ViewRoles(c *fiber.Ctx) error {
+	_, orgID, ok := middleware.SessionActor(c)
+	if !ok {
+		return response.Error(c, "User is not associated with an organization", 403, nil)
+	}
+	out, err := h.Service.ListRoles(c.Context(), orgID)
+	if err != nil {
+		return response.Error(c, "Internal Server Error", 500, nil)
+	}
+	return response.Success(c, "Custom roles fetched successfully", out, nil)
+}
+
+// POST /api/v1/roles/create-role
+func (h *Handlers) CreateRole(c *fiber.Ctx) error {
+	userID, orgID, ok := middleware.SessionActor(c)
+	if !ok {
+		return response.Error(c, "User is not associated with an organization", 403, nil)
+	}
+	var body roleBody
+	if err := c.BodyParser(&body); err != nil {
+		return response.Error(c, "Invalid request body", 400, nil)
+	}
+	role, err := h.Service.CreateRole(c.Context(), rolesvc.RoleInput{
+		OrgID: orgID, ActorUserID: userID, Name: body.Name, Description: body.Description, Permissions: body.Permissions,
+	})
+	if err != nil {
+		return roleError(c, err)
+	}
+	middleware.Audit(c, h.Audit, auditsvc.Entry{
+		Action:     auditsvc.ActionCustomRoleCreated,
+		TargetType: "custom_role",
+		TargetID:   role.RoleID.String(),
+		After:      role,
+	})
+	return response.SuccessCreated(c, "Custom role created successfully", role, nil)
+}
+
+// PUT /api/v1/roles/update-role — replaces name, description and permissions; holders are logged out.
+func (h *Handlers) UpdateRole(c *fiber.Ctx) error {
+	userID, orgID, ok := middleware.SessionActor(c)
+	if !ok {
+		return response.Error(c, "User is not associated with an organization", 403, nil)
+	}
+	var body roleBody
+	if err := c.BodyParser(&body); err != nil {
+		return response.Error(c, "Invalid request body", 400, nil)
+	}
+	roleID, err := uuid.Parse(body.RoleID)
+	if err != nil {
+		return response.Error(c, "Invalid role_id", 400, nil)
+	}
+	role, err := h.Service.UpdateRole(c.Context(), roleID, rolesvc.RoleInput{
+		OrgID: orgID, ActorUserID: userID, Name: body.Name, Description: body.Description, Permissions: body.Permissions,
+	})
+	if err != nil {
+		return roleError(c, err)
+	}
+	middleware.Audit(c, h.Audit, auditsvc.Entry{
+		Action:     auditsvc.ActionCustomRoleUpdated,
+		TargetType: "custom_role",
+		TargetID:   roleID.String(),
+		After:      role,
+	})
+	return response.Success(c, "Custom role updated successfully", role, nil)
+}
+
+// DELETE /api/v1/roles/delete-role
+func (h *Handlers) DeleteRole(c *fiber.Ctx) error {
+	_, orgID, ok := middleware.SessionActor(c)
+	if !ok {
+		return response.Error(c, "User is not associated with an organization", 403, nil)
+	}
+	var body roleBody
+	if err := c.BodyParser(&body); err != nil {
+		return response.Error(c, "Invalid request body", 400, nil)
+	}
+	roleID, err := uuid.Parse(body.RoleID)
+	if err != nil {
+		return response.Error(c, "Invalid role_id", 400, nil)
+	}
+	if err := h.Service.DeleteRole(c.Context(), orgID, roleID); err != nil {
+		return roleError(c, err)
+	}
+	middleware.Audit(c, h.Audit, auditsvc.Entry{
+		Action:     auditsvc.ActionCustomRoleDeleted,
+		TargetType: "custom_role",
+		TargetID:   roleID.String(),
+	})
+	return response.Success(c, "Custom role deleted successfully", nil, nil)
+}
+
+func roleError(c *fiber.Ctx, err error) error {
+	if code, ok := roleErrorStatus[err.Error()]; ok {
+		return response.Error(c, err.Error(), code, nil)
+	}
+	return response.Error(c, "Internal Server Error", 500, nil)
+}
@@ -0,0 +1,229 @@
+package roles
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	rolesvc "troo-backend/internal/application/roles"
+	usersvc "troo-backend/internal/application/user"
+	"troo-backend/internal/domain"
+	userhandler "troo-backend/internal/interfaces/handlers/user"
+	"troo-backend/internal/middleware"
+	"troo-backend/internal/pkg/constants"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/glebarez/sqlite"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type rolesFixture struct {
+	db    *gorm.DB
+	rdb   *redis.Client
+	app   *fiber.App
+	orgID uuid.UUID
+}
+
+// setupRolesTest builds the session the way login does: X-Test-User is loaded with its custom role.
+func setupRolesTest(t *testing.T) *rolesFixture {
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() {
+		rdb.Close()
+		mr.Close()
+	})
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&domain.CustomRole{}, &domain.User{}, &domain.OrgMembership{}))
+
+	f := &rolesFixture{db: db, rdb: rdb, orgID: uuid.New()}
+	h := &Handlers{Service: &rolesvc.Service{DB: db, Rdb: rdb}}
+	uh := &userhandler.Handlers{Service: &usersvc.Service{DB: db, Rdb: rdb}}
+
+	f.app = fiber.New()
+	f.app.Use(func(c *fiber.Ctx) error {
+		var u domain.User
+		if err := db.Preload("CustomRole").Where("user_id = ?", c.Get("X-Test-User")).First(&u).Error; err != nil {
+			return c.Next()
+		}
+		orgID := u.OrgID.String()
+		su := middleware.SessionUser{UserID: u.UserID.String(), Role: u.Role, OrgID: &orgID, EmailVerified: u.EmailVerified}
+		if u.CustomRole != nil {
+			roleID := u.CustomRole.RoleID.String()
+			su.CustomRoleID = &roleID
+			su.Permissions = u.CustomRole.PermissionList()
+		}
+		middleware.SetSessionUser(c, su)
+		// Round-trip through JSON as the Redis session store does.
+		b, _ := json.Marshal(middleware.GetUser(c))
+		var stored map[string]interface{}
+		_ = json.Unmarshal(b, &stored)
+		c.Locals("user", stored)
+		return c.Next()
+	})
+	f.app.Post("/create-role", middleware.AuthorizePermission(constants.ManageRoles), h.CreateRole)
+	f.app.Put("/update-role", middleware.AuthorizePermission(constants.ManageRoles), h.UpdateRole)
+	f.app.Delete("/delete-role", middleware.AuthorizePermission(constants.ManageRoles), h.DeleteRole)
+	f.app.Patch("/assign-role", middleware.AuthorizePermission(constants.AssignRole), uh.UpdateRole)
+	f.app.Delete("/remove-user", middleware.AuthorizePermission(constants.RemoveUser), uh.RemoveUser)
+	f.app.Post("/retire", middleware.AuthorizePermission(constants.RetireCredits), func(c *fiber.Ctx) error { return c.SendStatus(200) })
+	f.app.Post("/buy", middleware.AuthorizePermission(constants.BuyCredits), func(c *fiber.Ctx) error { return c.SendStatus(200) })
+	return f
+}
+
+func (f *rolesFixture) user(t *testing.T, role string) uuid.UUID {
+	id := uuid.New()
+	require.NoError(t, f.db.Create(&domain.User{
+		UserID: id, UserName: id.String(), Email: id.String() + "@example.com", PasswordHash: "x", Fullname: "Test User", Role: role, OrgID: &f.orgID, EmailVerified: true,
+	}).Error)
+	return id
+}
+
+func (f *rolesFixture) do(t *testing.T, method, path string, user uuid.UUID, body interface{}) (int, map[string]interface{}) {
+	b, _ := json.Marshal(body)
+	req := httptest.NewRequest(method, path, bytes.NewReader(b))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Test-User", user.String())
+	resp, err := f.app.Test(req)
+	require.NoError(t, err)
+	var result map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&result)
+	return resp.StatusCode, result
+}
+
+func (f *rolesFixture) createRole(t *testing.T, actor uuid.UUID, name string, perms ...string) string {
+	code, result := f.do(t, "POST", "/create-role", actor, map[string]interface{}{"name": name, "permissions": perms})
+	require.Equal(t, 201, code, result)
+	return result["data"].(map[string]interface{})["role_id"].(string)
+}
+
+func TestCustomRole_PermissionsHonouredByAuthorizePermission(t *testing.T) {
+	f := setupRolesTest(t)
+	owner := f.user(t, constants.Superadmin)
+	admin := f.user(t, constants.Admin)
+	officer := f.user(t, constants.Manager)
+
+	roleID := f.createRole(t, owner, "Retirement Officer", constants.RetireCredits, constants.ViewData)
+
+	code, result := f.do(t, "PATCH", "/assign-role", admin, map[string]string{"user_id": officer.String(), "custom_role_id": roleID})
+	require.Equal(t, 200, code, result)
+	var stored domain.User
+	require.NoError(t, f.db.Where("user_id = ?", officer).First(&stored).Error)
+	assert.Equal(t, constants.Viewer, stored.Role)
+	require.NotNil(t, stored.CustomRoleID)
+
+	code, _ = f.do(t, "POST", "/retire", officer, nil)
+	assert.Equal(t, 200, code)
+	// Managers may buy, but the custom role replaces the manager permissions entirely.
+	code, _ = f.do(t, "POST", "/buy", officer, nil)
+	assert.Equal(t, 403, code)
+
+	// Holders are logged out when the role changes.
+	require.NoError(t, f.rdb.SAdd(context.Background(), "user_sessions:"+officer.String(), "sid-1").Err())
+	require.NoError(t, f.rdb.Set(context.Background(), middleware.SessionRedisPrefix+"sid-1", "{}", 0).Err())
+	code, _ = f.do(t, "PUT", "/update-role", owner, map[string]interface{}{
+		"role_id": roleID, "name": "Retirement Officer", "permissions": []string{constants.RetireCredits, constants.BuyCredits},
+	})
+	require.Equal(t, 200, code)
+	assert.Equal(t, int64(0), f.rdb.Exists(context.Background(), middleware.SessionRedisPrefix+"sid-1").Val())
+	code, _ = f.do(t, "POST", "/buy", officer, nil)
+	assert.Equal(t, 200, code)
+
+	code, _ = f.do(t, "DELETE", "/delete-role", owner, map[string]string{"role_id": roleID})
+	assert.Equal(t, 409, code)
+
+	// Back to a built-in role clears the custom role.
+	code, _ = f.do(t, "PATCH", "/assign-role", admin, map[string]string{"user_id": officer.String(), "role": constants.Manager})
+	require.Equal(t, 200, code)
+	code, _ = f.do(t, "DELETE", "/delete-role", owner, map[string]string{"role_id": roleID})
+	assert.Equal(t, 200, code)
+}
+
+func TestCustomRole_Validation(t *testing.T) {
+	f := setupRolesTest(t)
+	owner := f.user(t, constants.Superadmin)
+	admin := f.user(t, constants.Admin)
+
+	code, _ := f.do(t, "POST", "/create-role", admin, map[string]interface{}{"name": "Auditor", "permissions": []string{constants.ViewData}})
+	assert.Equal(t, 403, code)
+
+	code, _ = f.do(t, "POST", "/create-role", owner, map[string]interface{}{"name": "Admin", "permissions": []string{constants.ViewData}})
+	assert.Equal(t, 400, code)
+	code, result := f.do(t, "POST", "/create-role", owner, map[string]interface{}{"name": "Escalator", "permissions": []string{constants.ManageRoles}})
+	assert.Equal(t, 400, code)
+	assert.Equal(t, "Permission cannot be granted to a custom role", result["error"].(map[string]interface{})["message"])
+	code, _ = f.do(t, "POST", "/create-role", owner, map[string]interface{}{"name": "Auditor", "permissions": []string{"fly_to_moon"}})
+	assert.Equal(t, 400, code)
+
+	f.createRole(t, owner, "Auditor", constants.ViewData)
+	code, _ = f.do(t, "POST", "/create-role", owner, map[string]interface{}{"name": "auditor", "permissions": []string{constants.ViewData}})
+	assert.Equal(t, 409, code)
+}
+
+func TestCustomRole_Governance(t *testing.T) {
+	f := setupRolesTest(t)
+	owner := f.user(t, constants.Superadmin)
+	admin := f.user(t, constants.Admin)
+	target := f.user(t, constants.Viewer)
+
+	// Roles carrying admin-level permissions are governed like the admin role.
+	deputy := f.createRole(t, owner, "Deputy", constants.InviteUser, constants.RemoveUser, constants.AssignRole, constants.ViewData)
+	code, result := f.do(t, "PATCH", "/assign-role", admin, map[string]string{"user_id": target.String(), "custom_role_id": deputy})
+	assert.Equal(t, 400, code)
+	assert.Equal(t, "Only superadmins can assign custom roles with admin permissions", result["error"].(map[string]interface{})["message"])
+	code, _ = f.do(t, "PATCH", "/assign-role", owner, map[string]string{"user_id": target.String(), "custom_role_id": deputy})
+	require.Equal(t, 200, code)
+
+	// Admins cannot remove a privileged custom-role holder, and the holder cannot touch admins.
+	code, _ = f.do(t, "DELETE", "/remove-user", admin, map[string]string{"user_id": target.String()})
+	assert.Equal(t, 400, code)
+	code, result = f.do(t, "DELETE", "/remove-user", target, map[string]string{"user_id": admin.String()})
+	assert.Equal(t, 400, code)
+	assert.Equal(t, "Admins cannot remove admins or superadmins", result["error"].(map[string]interface{})["message"])
+	code, result = f.do(t, "PATCH", "/assign-role", target, map[string]string{"user_id": admin.String(), "role": constants.Viewer})
+	assert.Equal(t, 400, code)
+	assert.Equal(t, "Only admins can change the role of an admin or superadmin", result["error"].(map[string]interface{})["message"])
+
+	// Roles from another org are not assignable.
+	other := domain.CustomRole{OrgID: uuid.New(), Name: "Elsewhere", Permissions: []byte(`["view_data"]`)}
+	require.NoError(t, f.db.Create(&other).Error)
+	code, result = f.do(t, "PATCH", "/assign-role", owner, map[string]string{"user_id": admin.String(), "custom_role_id": other.RoleID.String()})
+	assert.Equal(t, 400, code)
+	assert.Equal(t, "Custom role not found", result["error"].(map[string]interface{})["message"])
+}
+
+func TestCustomRole_HeldInSecondaryOrg(t *testing.T) {
+	f := setupRolesTest(t)
+	owner := f.user(t, constants.Superadmin)
+	roleID := f.createRole(t, owner, "Retirement Officer", constants.RetireCredits)
+
+	// The holder's home org is elsewhere; the role exists only on their membership of this org.
+	home := uuid.New()
+	holder := domain.User{UserName: "holder", Email: "holder@example.com", PasswordHash: "x", Fullname: "Holder", Role: constants.Admin, OrgID: &home, EmailVerified: true}
+	require.NoError(t, f.db.Create(&holder).Error)
+	rid := uuid.MustParse(roleID)
+	require.NoError(t, f.db.Create(&domain.OrgMembership{UserID: holder.UserID, OrgID: f.orgID, Role: constants.Viewer, CustomRoleID: &rid}).Error)
+
+	require.NoError(t, f.rdb.SAdd(context.Background(), "user_sessions:"+holder.UserID.String(), "sid-2").Err())
+	require.NoError(t, f.rdb.Set(context.Background(), middleware.SessionRedisPrefix+"sid-2", "{}", 0).Err())
+	code, result := f.do(t, "PUT", "/update-role", owner, map[string]interface{}{
+		"role_id": roleID, "name": "Retirement Officer", "permissions": []string{constants.RetireCredits, constants.ViewData},
+	})
+	require.Equal(t, 200, code, result)
+	assert.Equal(t, float64(1), result["data"].(map[string]interface{})["user_count"])
+	assert.Equal(t, int64(0), f.rdb.Exists(context.Background(), middleware.SessionRedisPrefix+"sid-2").Val())
+
+	code, _ = f.do(t, "DELETE", "/delete-role", owner, map[string]string{"role_id": roleID})
+	assert.Equal(t, 409, code)
+	var count int64
+	require.NoError(t, f.db.Model(&domain.CustomRole{}).Where("role_id = ?", roleID).Count(&count).Error)
+	assert.Equal(t, int64(1), count)
+}
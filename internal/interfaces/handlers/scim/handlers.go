@@ -0,0 +1,320 @@
+package scim
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	auditsvc "troo-backend/internal/application/audit"
+	policies "troo-backend/internal/application/policies/user"
+	scimsvc "troo-backend/internal/application/scim"
+	"troo-backend/internal/middleware"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// contentType is the SCIM media type (RFC 7644 §3.1).
+const contentType = "application/scim+json"
+
+// orgLocal holds the org the SCIM token belongs to.
+const orgLocal = "scim_org_id"
+
+// Handlers serve /api/v1/scim/v2 to an org's identity provider. Responses use SCIM's own JSON, not the
+// API envelope.
+type Handlers struct {
+	Service *scimsvc.Service
+	Audit   *auditsvc.Service
+}
+
+type scimError struct {
+	status   int
+	scimType string
+}
+
+var scimErrors = map[error]scimError{
+	scimsvc.ErrUserNotFound:                     {404, ""},
+	scimsvc.ErrGroupNotFound:                    {404, ""},
+	scimsvc.ErrUserExists:                       {409, "uniqueness"},
+	scimsvc.ErrGroupExists:                      {409, "uniqueness"},
+	scimsvc.ErrInvalidUserName:                  {400, "invalidValue"},
+	scimsvc.ErrUnverifiedDomain:                 {400, "invalidValue"},
+	scimsvc.ErrUnknownGroup:                     {400, "invalidValue"},
+	scimsvc.ErrInvalidMember:                    {400, "invalidValue"},
+	scimsvc.ErrGroupImmutable:                   {400, "mutability"},
+	scimsvc.ErrInvalidFilter:                    {400, "invalidFilter"},
+	scimsvc.ErrInvalidPatch:                     {400, "invalidValue"},
+	scimsvc.ErrIdentityManaged:                  {409, "mutability"},
+	scimsvc.ErrRoleNotAssignable:                {403, ""},
+	policies.ErrOrgMustHaveAtLeastOneSuperadmin: {409, "mutability"},
+	policies.ErrUserDoesNotBelongToYourOrg:      {409, ""},
+	policies.ErrCannotModifyUsersOutsideYourOrg: {409, ""},
+	policies.ErrCustomRoleNotFound:              {404, ""},
+}
+
+// Authenticate resolves "Authorization: Bearer <SCIM token>" to the org every handler acts on.
+func (h *Handlers) Authenticate(c *fiber.Ctx) error {
+	header := c.Get(fiber.HeaderAuthorization)
+	if len(header) < 7 || !strings.EqualFold(header[:7], "Bearer ") {
+		return sendError(c, 401, "", scimsvc.ErrInvalidToken.Error())
+	}
+	orgID, err := h.Service.Authenticate(c.Context(), header[7:])
+	if err == scimsvc.ErrInvalidToken {
+		return sendError(c, 401, "", err.Error())
+	}
+	if err != nil {
+		return sendError(c, 500, "", "Internal Server Error")
+	}
+	c.Locals(orgLocal, orgID)
+	return c.Next()
+}
+
+// GET /api/v1/scim/v2/ServiceProviderConfig
+func (h *Handlers) ServiceProviderConfig(c *fiber.Ctx) error {
+	return send(c, 200, fiber.Map{
+		"schemas":        []string{"urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"},
+		"patch":          fiber.Map{"supported": true},
+		"bulk":           fiber.Map{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":         fiber.Map{"supported": true, "maxResults": 200},
+		"changePassword": fiber.Map{"supported": false},
+		"sort":           fiber.Map{"supported": false},
+		"etag":           fiber.Map{"supported": false},
+		"authenticationSchemes": []fiber.Map{{
+			"type":        "oauthbearertoken",
+			"name":        "OAuth Bearer Token",
+			"description": "The organization's SCIM token from /api/v1/orgs/rotate-scim-token",
+		}},
+	})
+}
+
+// GET /api/v1/scim/v2/Users — query: filter (userName or externalId eq), startIndex, count.
+func (h *Handlers) ListUsers(c *fiber.Ctx) error {
+	offset, limit := scimsvc.Page(c.Query("startIndex"), c.Query("count"))
+	users, total, err := h.Service.ListUsers(c.Context(), orgID(c), c.Query("filter"), offset, limit)
+	if err != nil {
+		return fail(c, err)
+	}
+	return sendList(c, total, offset, users, len(users))
+}
+
+// GET /api/v1/scim/v2/Users/:id
+func (h *Handlers) GetUser(c *fiber.Ctx) error {
+	user, err := h.Service.GetUser(c.Context(), orgID(c), c.Params("id"))
+	if err != nil {
+		return fail(c, err)
+	}
+	return send(c, 200, user)
+}
+
+// POST /api/v1/scim/v2/Users
+func (h *Handlers) CreateUser(c *fiber.Ctx) error {
+	var in scimsvc.UserInput
+	if err := json.Unmarshal(c.Body(), &in); err != nil {
+		return sendError(c, 400, "invalidSyntax", "Invalid request body")
+	}
+	org := orgID(c)
+	user, err := h.Service.CreateUser(c.Context(), org, in)
+	if err != nil {
+		return fail(c, err)
+	}
+	h.audit(c, org, auditsvc.ActionSCIMProvisioned, "user", user.ID, nil, user)
+	return send(c, 201, user)
+}
+
+// PUT /api/v1/scim/v2/Users/:id
+func (h *Handlers) ReplaceUser(c *fiber.Ctx) error {
+	var in scimsvc.UserInput
+	if err := json.Unmarshal(c.Body(), &in); err != nil {
+		return sendError(c, 400, "invalidSyntax", "Invalid request body")
+	}
+	org := orgID(c)
+	before, err := h.Service.GetUser(c.Context(), org, c.Params("id"))
+	if err != nil {
+		return fail(c, err)
+	}
+	user, err := h.Service.ReplaceUser(c.Context(), org, before.ID, in)
+	if err != nil {
+		return fail(c, err)
+	}
+	h.auditUser(c, org, before, user)
+	return send(c, 200, user)
+}
+
+// PATCH /api/v1/scim/v2/Users/:id — body: PatchOp with add/replace operations (active false deactivates).
+func (h *Handlers) PatchUser(c *fiber.Ctx) error {
+	ops, ok := patchOps(c)
+	if !ok {
+		return sendError(c, 400, "invalidSyntax", "Invalid request body")
+	}
+	org := orgID(c)
+	before, err := h.Service.GetUser(c.Context(), org, c.Params("id"))
+	if err != nil {
+		return fail(c, err)
+	}
+	user, err := h.Service.PatchUser(c.Context(), org, before.ID, ops)
+	if err != nil {
+		return fail(c, err)
+	}
+	h.auditUser(c, org, before, user)
+	return send(c, 200, user)
+}
+
+// DELETE /api/v1/scim/v2/Users/:id — removes the user from the org and ends their sessions; the account remains.
+func (h *Handlers) DeleteUser(c *fiber.Ctx) error {
+	org := orgID(c)
+	before, err := h.Service.GetUser(c.Context(), org, c.Params("id"))
+	if err != nil {
+		return fail(c, err)
+	}
+	if err := h.Service.DeleteUser(c.Context(), org, before.ID); err != nil {
+		return fail(c, err)
+	}
+	h.audit(c, org, auditsvc.ActionSCIMDeprovisioned, "user", before.ID, before, nil)
+	return c.SendStatus(204)
+}
+
+// GET /api/v1/scim/v2/Groups — query: filter (displayName eq), startIndex, count.
+func (h *Handlers) ListGroups(c *fiber.Ctx) error {
+	offset, limit := scimsvc.Page(c.Query("startIndex"), c.Query("count"))
+	groups, total, err := h.Service.ListGroups(c.Context(), orgID(c), c.Query("filter"), offset, limit)
+	if err != nil {
+		return fail(c, err)
+	}
+	return sendList(c, total, offset, groups, len(groups))
+}
+
+// GET /api/v1/scim/v2/Groups/:id
+func (h *Handlers) GetGroup(c *fiber.Ctx) error {
+	group, err := h.Service.GetGroup(c.Context(), orgID(c), c.Params("id"))
+	if err != nil {
+		return fail(c, err)
+	}
+	return send(c, 200, group)
+}
+
+// POST /api/v1/scim/v2/Groups — groups are the org's roles, so this only reports whether displayName names one.
+func (h *Handlers) CreateGroup(c *fiber.Ctx) error {
+	var in scimsvc.GroupInput
+	if err := json.Unmarshal(c.Body(), &in); err != nil {
+		return sendError(c, 400, "invalidSyntax", "Invalid request body")
+	}
+	return fail(c, h.Service.CreateGroup(c.Context(), orgID(c), in))
+}
+
+// PUT /api/v1/scim/v2/Groups/:id — replaces the role's members.
+func (h *Handlers) ReplaceGroup(c *fiber.Ctx) error {
+	var in scimsvc.GroupInput
+	if err := json.Unmarshal(c.Body(), &in); err != nil {
+		return sendError(c, 400, "invalidSyntax", "Invalid request body")
+	}
+	org := orgID(c)
+	before, err := h.Service.GetGroup(c.Context(), org, c.Params("id"))
+	if err != nil {
+		return fail(c, err)
+	}
+	group, err := h.Service.ReplaceGroup(c.Context(), org, before.ID, in)
+	if err != nil {
+		return fail(c, err)
+	}
+	h.audit(c, org, auditsvc.ActionSCIMGroupUpdated, "group", group.ID, before.Members, group.Members)
+	return send(c, 200, group)
+}
+
+// PATCH /api/v1/scim/v2/Groups/:id — add, remove or replace members; each change is a role assignment.
+func (h *Handlers) PatchGroup(c *fiber.Ctx) error {
+	ops, ok := patchOps(c)
+	if !ok {
+		return sendError(c, 400, "invalidSyntax", "Invalid request body")
+	}
+	org := orgID(c)
+	before, err := h.Service.GetGroup(c.Context(), org, c.Params("id"))
+	if err != nil {
+		return fail(c, err)
+	}
+	group, err := h.Service.PatchGroup(c.Context(), org, before.ID, ops)
+	if err != nil {
+		return fail(c, err)
+	}
+	h.audit(c, org, auditsvc.ActionSCIMGroupUpdated, "group", group.ID, before.Members, group.Members)
+	return send(c, 200, group)
+}
+
+// DELETE /api/v1/scim/v2/Groups/:id — roles are managed in Troo, never deleted by the provider.
+func (h *Handlers) DeleteGroup(c *fiber.Ctx) error {
+	if _, err := h.Service.GetGroup(c.Context(), orgID(c), c.Params("id")); err != nil {
+		return fail(c, err)
+	}
+	return fail(c, scimsvc.ErrGroupImmutable)
+}
+
+// auditUser records a PUT or PATCH, as a deprovisioning or (re)provisioning when it changed active.
+func (h *Handlers) auditUser(c *fiber.Ctx, org uuid.UUID, before, after *scimsvc.User) {
+	action := auditsvc.ActionSCIMUpdated
+	switch {
+	case before.Active && !after.Active:
+		action = auditsvc.ActionSCIMDeprovisioned
+	case !before.Active && after.Active:
+		action = auditsvc.ActionSCIMProvisioned
+	}
+	h.audit(c, org, action, "user", after.ID, before, after)
+}
+
+// audit records a change made by the org's identity provider, which has no acting user.
+func (h *Handlers) audit(c *fiber.Ctx, org uuid.UUID, action, targetType, targetID string, before, after interface{}) {
+	middleware.Audit(c, h.Audit, auditsvc.Entry{
+		OrgID:      &org,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		Before:     before,
+		After:      after,
+	})
+}
+
+func orgID(c *fiber.Ctx) uuid.UUID {
+	id, _ := c.Locals(orgLocal).(uuid.UUID)
+	return id
+}
+
+func patchOps(c *fiber.Ctx) ([]scimsvc.PatchOperation, bool) {
+	var body struct {
+		Operations []scimsvc.PatchOperation `json:"Operations"`
+	}
+	if err := json.Unmarshal(c.Body(), &body); err != nil || len(body.Operations) == 0 {
+		return nil, false
+	}
+	return body.Operations, true
+}
+
+func send(c *fiber.Ctx, status int, v interface{}) error {
+	return c.Status(status).JSON(v, contentType)
+}
+
+func sendList(c *fiber.Ctx, total int64, offset int, resources interface{}, n int) error {
+	return send(c, 200, fiber.Map{
+		"schemas":      []string{scimsvc.ListResponseSchema},
+		"totalResults": total,
+		"startIndex":   offset + 1,
+		"itemsPerPage": n,
+		"Resources":    resources,
+	})
+}
+
+func sendError(c *fiber.Ctx, status int, scimType, detail string) error {
+	body := fiber.Map{
+		"schemas": []string{scimsvc.ErrorSchema},
+		"status":  strconv.Itoa(status),
+		"detail":  detail,
+	}
+	if scimType != "" {
+		body["scimType"] = scimType
+	}
+	return send(c, status, body)
+}
+
+func fail(c *fiber.Ctx, err error) error {
+	if e, ok := scimErrors[err]; ok {
+		return sendError(c, e.status, e.scimType, err.Error())
+	}
+	return sendError(c, 500, "", "Internal Server Error")
+}
@@ -0,0 +1,345 @@
+package scim
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"troo-backend/internal/application/memberships"
+	scimsvc "troo-backend/internal/application/scim"
+	"troo-backend/internal/domain"
+	"troo-backend/internal/middleware"
+	"troo-backend/internal/pkg/constants"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/glebarez/sqlite"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+type scimFixture struct {
+	db      *gorm.DB
+	rdb     *redis.Client
+	app     *fiber.App
+	svc     *scimsvc.Service
+	token   string
+	orgID   uuid.UUID
+	owner   domain.User
+	manager domain.User
+	outside domain.User
+	role    domain.CustomRole
+}
+
+// setupSCIMTest creates an org with a sole superadmin, a manager and a custom role, a member of another org,
+// and a SCIM token for the first org.
+func setupSCIMTest(t *testing.T) *scimFixture {
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	t.Cleanup(mr.Close)
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&domain.CustomRole{}, &domain.User{}, &domain.OrgMembership{}, &domain.OrgSCIMToken{}, &domain.SCIMUser{}, &domain.OrgSSOConfig{}))
+
+	f := &scimFixture{db: db, rdb: redis.NewClient(&redis.Options{Addr: mr.Addr()}), orgID: uuid.New()}
+	otherOrg := uuid.New()
+	f.owner = domain.User{UserID: uuid.New(), UserName: "owner", Email: "owner@acme.com", Fullname: "Olive Owner", Role: constants.Superadmin, OrgID: &f.orgID}
+	f.manager = domain.User{UserID: uuid.New(), UserName: "max", Email: "max@acme.com", Fullname: "Max Manager", Role: constants.Manager, OrgID: &f.orgID}
+	f.outside = domain.User{UserID: uuid.New(), UserName: "eve", Email: "eve@beta.com", Fullname: "Eve", Role: constants.Admin, OrgID: &otherOrg}
+	for _, u := range []*domain.User{&f.owner, &f.manager, &f.outside} {
+		require.NoError(t, db.Create(u).Error)
+	}
+	require.NoError(t, db.Create(&domain.OrgSSOConfig{
+		OrgID: f.orgID, Issuer: "https://idp.acme.com", ClientID: "troo",
+		EmailDomains: datatypes.JSON(`["acme.com","acme.io"]`), VerifiedDomains: datatypes.JSON(`["acme.com"]`),
+	}).Error)
+	f.role = domain.CustomRole{OrgID: f.orgID, Name: "Auditor", Permissions: datatypes.JSON(`["view_data","view_audit_log"]`)}
+	require.NoError(t, db.Create(&f.role).Error)
+
+	f.svc = &scimsvc.Service{DB: db, Rdb: f.rdb}
+	f.token, err = f.svc.RotateToken(context.Background(), f.orgID, f.owner.UserID)
+	require.NoError(t, err)
+
+	h := &Handlers{Service: f.svc}
+	f.app = fiber.New()
+	g := f.app.Group("/scim/v2", h.Authenticate)
+	g.Get("/Users", h.ListUsers)
+	g.Post("/Users", h.CreateUser)
+	g.Get("/Users/:id", h.GetUser)
+	g.Put("/Users/:id", h.ReplaceUser)
+	g.Patch("/Users/:id", h.PatchUser)
+	g.Delete("/Users/:id", h.DeleteUser)
+	g.Get("/Groups", h.ListGroups)
+	g.Post("/Groups", h.CreateGroup)
+	g.Get("/Groups/:id", h.GetGroup)
+	g.Put("/Groups/:id", h.ReplaceGroup)
+	g.Patch("/Groups/:id", h.PatchGroup)
+	g.Delete("/Groups/:id", h.DeleteGroup)
+	return f
+}
+
+func (f *scimFixture) do(t *testing.T, method, path string, body interface{}) (int, map[string]interface{}) {
+	return f.doAs(t, f.token, method, path, body)
+}
+
+func (f *scimFixture) doAs(t *testing.T, token, method, path string, body interface{}) (int, map[string]interface{}) {
+	var r *bytes.Reader
+	if body != nil {
+		b, _ := json.Marshal(body)
+		r = bytes.NewReader(b)
+	} else {
+		r = bytes.NewReader(nil)
+	}
+	req := httptest.NewRequest(method, path, r)
+	req.Header.Set("Content-Type", "application/scim+json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := f.app.Test(req)
+	require.NoError(t, err)
+	var out map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&out)
+	return resp.StatusCode, out
+}
+
+// signIn stores a session for userID the way the session middleware indexes it.
+func (f *scimFixture) signIn(t *testing.T, userID uuid.UUID) string {
+	sid := uuid.NewString()
+	ctx := context.Background()
+	require.NoError(t, f.rdb.Set(ctx, middleware.SessionRedisPrefix+sid, `{}`, 0).Err())
+	require.NoError(t, f.rdb.SAdd(ctx, "user_sessions:"+userID.String(), sid).Err())
+	return sid
+}
+
+func (f *scimFixture) signedIn(sid string) bool {
+	return f.rdb.Exists(context.Background(), middleware.SessionRedisPrefix+sid).Val() == 1
+}
+
+func (f *scimFixture) user(t *testing.T, id string) domain.User {
+	var u domain.User
+	require.NoError(t, f.db.Where("user_id = ?", id).First(&u).Error)
+	return u
+}
+
+func patchOp(ops ...map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{"schemas": []string{scimsvc.PatchOpSchema}, "Operations": ops}
+}
+
+func TestSCIM_UserLifecycle(t *testing.T) {
+	f := setupSCIMTest(t)
+
+	code, _ := f.doAs(t, "", "GET", "/scim/v2/Users", nil)
+	assert.Equal(t, 401, code)
+	code, _ = f.doAs(t, "troo_scim_forged", "GET", "/scim/v2/Users", nil)
+	assert.Equal(t, 401, code)
+
+	code, out := f.do(t, "POST", "/scim/v2/Users", map[string]interface{}{
+		"schemas": []string{scimsvc.UserSchema}, "userName": "New.Hire@Acme.com", "externalId": "okta-1",
+		"name": map[string]string{"givenName": "Nia", "familyName": "Hire"},
+	})
+	require.Equal(t, 201, code, out)
+	id := out["id"].(string)
+	assert.Equal(t, "new.hire@acme.com", out["userName"])
+	assert.Equal(t, "Nia Hire", out["displayName"])
+	assert.Equal(t, true, out["active"])
+	assert.Equal(t, constants.Viewer, out["groups"].([]interface{})[0].(map[string]interface{})["value"])
+	created := f.user(t, id)
+	assert.Equal(t, f.orgID, *created.OrgID)
+	assert.True(t, created.EmailVerified)
+
+	code, out = f.do(t, "POST", "/scim/v2/Users", map[string]interface{}{"userName": "new.hire@acme.com"})
+	assert.Equal(t, 409, code)
+	assert.Equal(t, "uniqueness", out["scimType"])
+	code, _ = f.do(t, "POST", "/scim/v2/Users", map[string]interface{}{"userName": "nhire"})
+	assert.Equal(t, 400, code)
+
+	code, out = f.do(t, "GET", `/scim/v2/Users?filter=userName%20eq%20%22NEW.HIRE%40acme.com%22`, nil)
+	require.Equal(t, 200, code, out)
+	assert.Equal(t, float64(1), out["totalResults"])
+	code, out = f.do(t, "GET", `/scim/v2/Users?filter=externalId%20eq%20%22okta-1%22`, nil)
+	require.Equal(t, 200, code, out)
+	assert.Equal(t, id, out["Resources"].([]interface{})[0].(map[string]interface{})["id"])
+	code, out = f.do(t, "GET", `/scim/v2/Users?filter=title%20co%20%22x%22`, nil)
+	assert.Equal(t, 400, code)
+	assert.Equal(t, "invalidFilter", out["scimType"])
+	code, out = f.do(t, "GET", "/scim/v2/Users?count=2", nil)
+	require.Equal(t, 200, code)
+	assert.Equal(t, float64(3), out["totalResults"])
+	assert.Len(t, out["Resources"], 2)
+
+	// Deactivation removes the user from the org and ends their sessions; the provider can still read them.
+	sid := f.signIn(t, created.UserID)
+	code, out = f.do(t, "PATCH", "/scim/v2/Users/"+id, patchOp(
+		map[string]interface{}{"op": "Replace", "path": "active", "value": "False"},
+		map[string]interface{}{"op": "replace", "path": "name.familyName", "value": "Hired"},
+	))
+	require.Equal(t, 200, code, out)
+	assert.Equal(t, false, out["active"])
+	assert.Equal(t, "Nia Hired", out["displayName"])
+	assert.Nil(t, f.user(t, id).OrgID)
+	assert.False(t, f.signedIn(sid))
+	code, out = f.do(t, "GET", "/scim/v2/Users/"+id, nil)
+	require.Equal(t, 200, code)
+	assert.Equal(t, false, out["active"])
+
+	code, out = f.do(t, "PATCH", "/scim/v2/Users/"+id, patchOp(map[string]interface{}{"op": "replace", "value": map[string]interface{}{"active": true}}))
+	require.Equal(t, 200, code, out)
+	assert.Equal(t, true, out["active"])
+	assert.Equal(t, f.orgID, *f.user(t, id).OrgID)
+
+	code, out = f.do(t, "PUT", "/scim/v2/Users/"+f.owner.UserID.String(), map[string]interface{}{"userName": "owner@acme.com", "active": false})
+	assert.Equal(t, 409, code, "the last superadmin cannot be deprovisioned")
+	assert.Equal(t, "Organization must have at least one superadmin", out["detail"])
+	code, out = f.do(t, "PUT", "/scim/v2/Users/"+id, map[string]interface{}{"userName": "max@acme.com"})
+	assert.Equal(t, 409, code, "email taken")
+	assert.Equal(t, "uniqueness", out["scimType"])
+	code, _ = f.do(t, "GET", "/scim/v2/Users/"+f.outside.UserID.String(), nil)
+	assert.Equal(t, 404, code)
+
+	sid = f.signIn(t, created.UserID)
+	code, _ = f.do(t, "DELETE", "/scim/v2/Users/"+id, nil)
+	assert.Equal(t, 204, code)
+	assert.False(t, f.signedIn(sid))
+	code, _ = f.do(t, "GET", "/scim/v2/Users/"+id, nil)
+	assert.Equal(t, 404, code)
+
+	old := f.token
+	var err error
+	f.token, err = f.svc.RotateToken(context.Background(), f.orgID, f.owner.UserID)
+	require.NoError(t, err)
+	code, _ = f.doAs(t, old, "GET", "/scim/v2/Users", nil)
+	assert.Equal(t, 401, code, "rotation invalidates the previous token")
+}
+
+func TestSCIM_IdentityChangesOnlyForUsersTheOrgOwns(t *testing.T) {
+	f := setupSCIMTest(t)
+	managerID := f.manager.UserID.String()
+
+	// The manager joined without SCIM: the provider may deactivate them but not rename them.
+	code, out := f.do(t, "PUT", "/scim/v2/Users/"+managerID, map[string]interface{}{"userName": "max@acme.com", "displayName": "Max Manager"})
+	require.Equal(t, 200, code, out)
+	code, out = f.do(t, "PUT", "/scim/v2/Users/"+managerID, map[string]interface{}{"userName": "boss@acme.com"})
+	assert.Equal(t, 409, code)
+	assert.Equal(t, "mutability", out["scimType"])
+	code, _ = f.do(t, "PATCH", "/scim/v2/Users/"+managerID, patchOp(map[string]interface{}{"op": "replace", "path": "displayName", "value": "Someone Else"}))
+	assert.Equal(t, 409, code)
+	assert.Equal(t, "max@acme.com", f.user(t, managerID).Email)
+
+	code, out = f.do(t, "POST", "/scim/v2/Users", map[string]interface{}{"userName": "nia@acme.com", "displayName": "Nia"})
+	require.Equal(t, 201, code, out)
+	id := out["id"].(string)
+	require.NoError(t, f.db.Model(&domain.User{}).Where("user_id = ?", id).Update("email_verified", false).Error)
+	code, out = f.do(t, "PATCH", "/scim/v2/Users/"+id, patchOp(map[string]interface{}{"op": "replace", "path": "userName", "value": "nia.h@acme.com"}))
+	require.Equal(t, 200, code, out)
+	u := f.user(t, id)
+	assert.Equal(t, "nia.h@acme.com", u.Email)
+	assert.False(t, u.EmailVerified, "the provider does not vouch for email addresses")
+
+	// Once the user also belongs to another org, their identity is no longer this org's to change.
+	nia := f.user(t, id)
+	require.NoError(t, memberships.Add(f.db, &nia, uuid.New(), constants.Viewer, nil))
+	code, _ = f.do(t, "PATCH", "/scim/v2/Users/"+id, patchOp(map[string]interface{}{"op": "replace", "path": "userName", "value": "nia@acme.com"}))
+	assert.Equal(t, 409, code)
+	assert.Equal(t, "nia.h@acme.com", f.user(t, id).Email)
+}
+
+func TestSCIM_UserNamesAtVerifiedDomainsOnly(t *testing.T) {
+	f := setupSCIMTest(t)
+	for _, userName := range []string{"ceo@beta.com", "new.hire@acme.io"} {
+		code, out := f.do(t, "POST", "/scim/v2/Users", map[string]interface{}{"userName": userName})
+		assert.Equal(t, 400, code, userName)
+		assert.Equal(t, "invalidValue", out["scimType"])
+	}
+	var count int64
+	require.NoError(t, f.db.Model(&domain.User{}).Where("email IN ?", []string{"ceo@beta.com", "new.hire@acme.io"}).Count(&count).Error)
+	assert.Zero(t, count)
+
+	code, out := f.do(t, "POST", "/scim/v2/Users", map[string]interface{}{"userName": "nia@acme.com"})
+	require.Equal(t, 201, code, out)
+	id := out["id"].(string)
+	code, out = f.do(t, "PATCH", "/scim/v2/Users/"+id, patchOp(map[string]interface{}{"op": "replace", "path": "userName", "value": "nia@beta.com"}))
+	assert.Equal(t, 400, code)
+	assert.Equal(t, scimsvc.ErrUnverifiedDomain.Error(), out["detail"])
+	assert.Equal(t, "nia@acme.com", f.user(t, id).Email)
+}
+
+func TestSCIM_GroupsMapToRoles(t *testing.T) {
+	f := setupSCIMTest(t)
+	managerID := f.manager.UserID.String()
+	roleID := f.role.RoleID.String()
+
+	code, out := f.do(t, "GET", "/scim/v2/Groups", nil)
+	require.Equal(t, 200, code, out)
+	assert.Equal(t, float64(5), out["totalResults"])
+	code, out = f.do(t, "GET", `/scim/v2/Groups?filter=displayName%20eq%20%22auditor%22`, nil)
+	require.Equal(t, 200, code, out)
+	assert.Equal(t, roleID, out["Resources"].([]interface{})[0].(map[string]interface{})["id"])
+
+	code, out = f.do(t, "POST", "/scim/v2/Groups", map[string]interface{}{"displayName": "Admin"})
+	assert.Equal(t, 409, code)
+	assert.Equal(t, "uniqueness", out["scimType"])
+	code, _ = f.do(t, "POST", "/scim/v2/Groups", map[string]interface{}{"displayName": "Finance"})
+	assert.Equal(t, 400, code)
+
+	// Joining a group assigns its role and signs the member out.
+	sid := f.signIn(t, f.manager.UserID)
+	code, out = f.do(t, "PATCH", "/scim/v2/Groups/"+roleID, patchOp(map[string]interface{}{
+		"op": "add", "path": "members", "value": []map[string]string{{"value": managerID}},
+	}))
+	require.Equal(t, 200, code, out)
+	assert.Len(t, out["members"], 1)
+	u := f.user(t, managerID)
+	assert.Equal(t, constants.Viewer, u.Role)
+	assert.Equal(t, f.role.RoleID, *u.CustomRoleID)
+	assert.False(t, f.signedIn(sid))
+	code, out = f.do(t, "GET", "/scim/v2/Users/"+managerID, nil)
+	require.Equal(t, 200, code)
+	assert.Equal(t, "Auditor", out["groups"].([]interface{})[0].(map[string]interface{})["display"])
+
+	code, out = f.do(t, "PATCH", "/scim/v2/Groups/"+roleID, patchOp(map[string]interface{}{
+		"op": "remove", "path": `members[value eq "` + managerID + `"]`,
+	}))
+	require.Equal(t, 200, code, out)
+	assert.Empty(t, out["members"])
+	u = f.user(t, managerID)
+	assert.Equal(t, constants.Viewer, u.Role)
+	assert.Nil(t, u.CustomRoleID)
+
+	code, out = f.do(t, "PUT", "/scim/v2/Groups/admin", map[string]interface{}{
+		"displayName": "admin", "members": []map[string]string{{"value": managerID}},
+	})
+	require.Equal(t, 200, code, out)
+	assert.Equal(t, constants.Admin, f.user(t, managerID).Role)
+
+	code, out = f.do(t, "PATCH", "/scim/v2/Groups/superadmin", patchOp(map[string]interface{}{
+		"op": "remove", "path": "members", "value": []map[string]string{{"value": f.owner.UserID.String()}},
+	}))
+	assert.Equal(t, 409, code, "the last superadmin stays")
+	assert.Equal(t, constants.Superadmin, f.user(t, f.owner.UserID.String()).Role)
+
+	code, _ = f.do(t, "PATCH", "/scim/v2/Groups/superadmin", patchOp(map[string]interface{}{
+		"op": "add", "path": "members", "value": []map[string]string{{"value": managerID}},
+	}))
+	assert.Equal(t, 403, code, "superadmin is above what the provider may assign")
+	assert.Equal(t, constants.Admin, f.user(t, managerID).Role)
+
+	code, _ = f.do(t, "PATCH", "/scim/v2/Groups/manager", patchOp(map[string]interface{}{
+		"op": "add", "path": "members", "value": []map[string]string{{"value": f.outside.UserID.String()}},
+	}))
+	assert.Equal(t, 400, code, "members must belong to the org")
+	assert.Equal(t, constants.Admin, f.user(t, f.outside.UserID.String()).Role)
+
+	code, out = f.do(t, "PATCH", "/scim/v2/Groups/admin", patchOp(map[string]interface{}{"op": "replace", "path": "displayName", "value": "Admins"}))
+	assert.Equal(t, 400, code)
+	assert.Equal(t, "mutability", out["scimType"])
+	code, _ = f.do(t, "DELETE", "/scim/v2/Groups/admin", nil)
+	assert.Equal(t, 400, code)
+	code, _ = f.do(t, "GET", "/scim/v2/Groups/"+uuid.NewString(), nil)
+	assert.Equal(t, 404, code)
+}
@@ -0,0 +1,211 @@
+package subaccounts
+
+import (
+	auditsvc "troo-backend/internal/application/audit"
+	subsvc "troo-backend/internal/application/subaccounts"
+	"troo-backend/internal/domain"
+	"troo-backend/internal/middleware"
+	"troo-backend/internal/pkg/decimal"
+	"troo-backend/internal/pkg/response"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type Handlers struct {
+	Service *subsvc.Service
+	Audit   *auditsvc.Service
+}
+
+type subAccountBody struct {
+	SubAccountID string  `json:"sub_account_id"`
+	Name         *string `json:"name"`
+	Description  *string `json:"description"`
+}
+
+var subAccountErrorStatus = map[string]int{
+	"Sub-account name is required":                                  400,
+	"Cannot transfer to the same account":                           400,
+	"Insufficient available credits to transfer":                    400,
+	"Sub-account not found":                                         404,
+	"A sub-account with this name already exists":                   409,
+	"Sub-account still holds or lists credits":                      409,
+	"Sub-account holdings do not match the organization's holdings": 409,
+}
+
+// GET /api/v1/sub-accounts/view-sub-accounts — holdings and retired credits of the main account and each sub-account.
+func (h *Handlers) ViewSubAccounts(c *fiber.Ctx) error {
+	_, orgID, ok := middleware.SessionActor(c)
+	if !ok {
+		return response.Error(c, "User is not associated with an organization", 403, nil)
+	}
+	out, err := h.Service.Report(c.Context(), orgID)
+	if err != nil {
+		return subAccountError(c, err)
+	}
+	return response.Success(c, "Sub-accounts fetched successfully", out, nil)
+}
+
+// POST /api/v1/sub-accounts/create-sub-account — body: name (unique within the org), description.
+func (h *Handlers) CreateSubAccount(c *fiber.Ctx) error {
+	userID, orgID, ok := middleware.SessionActor(c)
+	if !ok {
+		return response.Error(c, "User is not associated with an organization", 403, nil)
+	}
+	var body subAccountBody
+	if err := c.BodyParser(&body); err != nil {
+		return response.Error(c, "Invalid request body", 400, nil)
+	}
+	name := ""
+	if body.Name != nil {
+		name = *body.Name
+	}
+	sub, err := h.Service.Create(c.Context(), orgID, userID, name, body.Description)
+	if err != nil {
+		return subAccountError(c, err)
+	}
+	middleware.Audit(c, h.Audit, auditsvc.Entry{
+		Action:     auditsvc.ActionSubAccountCreated,
+		TargetType: "sub_account",
+		TargetID:   sub.SubAccountID.String(),
+		After:      sub,
+	})
+	return response.SuccessCreated(c, "Sub-account created successfully", sub, nil)
+}
+
+// PATCH /api/v1/sub-accounts/update-sub-account — body: sub_account_id, and name and/or description.
+func (h *Handlers) UpdateSubAccount(c *fiber.Ctx) error {
+	_, orgID, ok := middleware.SessionActor(c)
+	if !ok {
+		return response.Error(c, "User is not associated with an organization", 403, nil)
+	}
+	var body subAccountBody
+	if err := c.BodyParser(&body); err != nil {
+		return response.Error(c, "Invalid request body", 400, nil)
+	}
+	subID, err := uuid.Parse(body.SubAccountID)
+	if err != nil {
+		return response.Error(c, "Invalid sub_account_id", 400, nil)
+	}
+	sub, err := h.Service.Update(c.Context(), orgID, subID, body.Name, body.Description)
+	if err != nil {
+		return subAccountError(c, err)
+	}
+	middleware.Audit(c, h.Audit, auditsvc.Entry{
+		Action:     auditsvc.ActionSubAccountUpdated,
+		TargetType: "sub_account",
+		TargetID:   sub.SubAccountID.String(),
+		After:      sub,
+	})
+	return response.Success(c, "Sub-account updated successfully", sub, nil)
+}
+
+// DELETE /api/v1/sub-accounts/delete-sub-account — body: sub_account_id. Only empty sub-accounts can be deleted.
+func (h *Handlers) DeleteSubAccount(c *fiber.Ctx) error {
+	_, orgID, ok := middleware.SessionActor(c)
+	if !ok {
+		return response.Error(c, "User is not associated with an organization", 403, nil)
+	}
+	var body subAccountBody
+	if err := c.BodyParser(&body); err != nil {
+		return response.Error(c, "Invalid request body", 400, nil)
+	}
+	subID, err := uuid.Parse(body.SubAccountID)
+	if err != nil {
+		return response.Error(c, "Invalid sub_account_id", 400, nil)
+	}
+	if err := h.Service.Delete(c.Context(), orgID, subID); err != nil {
+		return subAccountError(c, err)
+	}
+	middleware.Audit(c, h.Audit, auditsvc.Entry{
+		Action:     auditsvc.ActionSubAccountDeleted,
+		TargetType: "sub_account",
+		TargetID:   subID.String(),
+	})
+	return response.Success(c, "Sub-account deleted successfully", nil, nil)
+}
+
+// POST /api/v1/sub-accounts/transfer-credits — body: project_id, from_sub_account_id, to_sub_account_id, amount.
+// An absent or empty account id is the org's main account.
+func (h *Handlers) TransferCredits(c *fiber.Ctx) error {
+	userID, orgID, ok := middleware.SessionActor(c)
+	if !ok {
+		return response.Error(c, "User is not associated with an organization", 403, nil)
+	}
+	var body struct {
+		ProjectID        string          `json:"project_id"`
+		FromSubAccountID string          `json:"from_sub_account_id"`
+		ToSubAccountID   string          `json:"to_sub_account_id"`
+		Amount           decimal.Decimal `json:"amount"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return response.Error(c, "Missing required fields", 400, nil)
+	}
+	if body.ProjectID == "" || body.Amount.IsZero() {
+		return response.Error(c, "Missing required fields", 400, nil)
+	}
+	projectID, err := uuid.Parse(body.ProjectID)
+	if err != nil {
+		return response.Error(c, "Invalid project_id", 400, nil)
+	}
+	body.Amount = body.Amount.Round(domain.CreditScale)
+	if body.Amount.Sign() <= 0 {
+		return response.Error(c, "Amount must be a positive number", 400, nil)
+	}
+	from, err := accountID(body.FromSubAccountID)
+	if err != nil {
+		return response.Error(c, "Invalid from_sub_account_id", 400, nil)
+	}
+	to, err := accountID(body.ToSubAccountID)
+	if err != nil {
+		return response.Error(c, "Invalid to_sub_account_id", 400, nil)
+	}
+	record, err := h.Service.Transfer(c.Context(), &userID, orgID, projectID, from, to, body.Amount)
+	if err != nil {
+		return subAccountError(c, err)
+	}
+	middleware.Audit(c, h.Audit, auditsvc.Entry{
+		Action:     auditsvc.ActionInternalTransfer,
+		TargetType: "project",
+		TargetID:   projectID.String(),
+		After:      fiber.Map{"from_sub_account_id": from, "to_sub_account_id": to, "amount": body.Amount},
+	})
+	return response.Success(c, "Transfer successful", record, nil)
+}
+
+// GET /api/v1/sub-accounts/view-transactions?sub_account_id= — the account's transactions, newest first.
+// Without sub_account_id, the main account's.
+func (h *Handlers) ViewTransactions(c *fiber.Ctx) error {
+	_, orgID, ok := middleware.SessionActor(c)
+	if !ok {
+		return response.Error(c, "User is not associated with an organization", 403, nil)
+	}
+	subID, err := accountID(c.Query("sub_account_id"))
+	if err != nil {
+		return response.Error(c, "Invalid sub_account_id", 400, nil)
+	}
+	out, err := h.Service.Transactions(c.Context(), orgID, subID)
+	if err != nil {
+		return subAccountError(c, err)
+	}
+	return response.Success(c, "Transactions fetched successfully", out, nil)
+}
+
+func subAccountError(c *fiber.Ctx, err error) error {
+	if code, ok := subAccountErrorStatus[err.Error()]; ok {
+		return response.Error(c, err.Error(), code, nil)
+	}
+	return response.Error(c, "Internal Server Error", 500, nil)
+}
+
+// accountID parses an optional account id; empty is the main account (nil).
+func accountID(s string) (*uuid.UUID, error) {
+	if s == "" {
+		return nil, nil
+	}
+	id, err := uuid.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	return &id, nil
+}
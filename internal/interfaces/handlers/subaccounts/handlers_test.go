@@ -0,0 +1,195 @@
+package subaccounts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	subsvc "troo-backend/internal/application/subaccounts"
+	tradesvc "troo-backend/internal/application/trading"
+	"troo-backend/internal/application/verification"
+	"troo-backend/internal/domain"
+	"troo-backend/internal/middleware"
+	"troo-backend/internal/pkg/constants"
+	"troo-backend/internal/pkg/decimal"
+
+	"github.com/glebarez/sqlite"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type subAccountsFixture struct {
+	db        *gorm.DB
+	app       *fiber.App
+	orgID     uuid.UUID
+	projectID uuid.UUID
+}
+
+func setupSubAccountsTest(t *testing.T) *subAccountsFixture {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(
+		&domain.Org{}, &domain.Holding{}, &domain.Listing{}, &domain.ListingEvent{}, &domain.Transaction{},
+		&domain.RetirementCertificate{}, &domain.IcrProject{}, &domain.OrgVerification{},
+		&domain.SubAccount{}, &domain.SubAccountHolding{},
+	))
+	f := &subAccountsFixture{db: db, orgID: uuid.New(), projectID: uuid.New()}
+	name := "Mangrove Restoration"
+	require.NoError(t, db.Create(&domain.Org{OrgID: f.orgID, OrgName: "Acme", OrgCode: "AC-000001", CountryCode: "SG"}).Error)
+	require.NoError(t, db.Create(&domain.OrgVerification{OrgID: f.orgID, Status: verification.StatusVerified}).Error)
+	require.NoError(t, db.Create(&domain.IcrProject{ID: f.projectID, FullName: &name, Status: "validated"}).Error)
+	require.NoError(t, db.Create(&domain.Holding{OrgID: f.orgID, ProjectID: f.projectID, CreditBalance: decimal.NewFromInt(100)}).Error)
+
+	h := &Handlers{Service: &subsvc.Service{DB: db}}
+	userID := uuid.New().String()
+	f.app = fiber.New()
+	f.app.Use(func(c *fiber.Ctx) error {
+		c.Locals("user", map[string]interface{}{"user_id": userID, "role": constants.Superadmin, "org_id": f.orgID.String(), "email_verified": true})
+		return c.Next()
+	})
+	f.app.Get("/view-sub-accounts", middleware.AuthorizePermission(constants.ViewData), h.ViewSubAccounts)
+	f.app.Post("/create-sub-account", middleware.AuthorizePermission(constants.UpdateOrg), h.CreateSubAccount)
+	f.app.Patch("/update-sub-account", middleware.AuthorizePermission(constants.UpdateOrg), h.UpdateSubAccount)
+	f.app.Delete("/delete-sub-account", middleware.AuthorizePermission(constants.UpdateOrg), h.DeleteSubAccount)
+	f.app.Post("/transfer-credits", middleware.AuthorizePermission(constants.TransferCredits), h.TransferCredits)
+	f.app.Get("/view-transactions", middleware.AuthorizePermission(constants.ViewData), h.ViewTransactions)
+	return f
+}
+
+func (f *subAccountsFixture) do(t *testing.T, method, path string, body interface{}) (int, map[string]interface{}) {
+	b, _ := json.Marshal(body)
+	req := httptest.NewRequest(method, path, bytes.NewReader(b))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := f.app.Test(req)
+	require.NoError(t, err)
+	var out map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&out)
+	return resp.StatusCode, out
+}
+
+func (f *subAccountsFixture) create(t *testing.T, name string) uuid.UUID {
+	code, out := f.do(t, "POST", "/create-sub-account", map[string]string{"name": name})
+	require.Equal(t, 201, code, out)
+	id, err := uuid.Parse(out["data"].(map[string]interface{})["sub_account_id"].(string))
+	require.NoError(t, err)
+	return id
+}
+
+func TestSubAccounts_TransferSellAndRetire(t *testing.T) {
+	f := setupSubAccountsTest(t)
+	fund := f.create(t, "Fund A")
+	code, _ := f.do(t, "POST", "/create-sub-account", map[string]string{"name": "fund a"})
+	assert.Equal(t, 409, code)
+
+	// Allocate 60 of the 100 credits to the fund; the main account keeps the rest.
+	move := map[string]interface{}{"project_id": f.projectID, "to_sub_account_id": fund, "amount": 60}
+	code, out := f.do(t, "POST", "/transfer-credits", move)
+	require.Equal(t, 200, code, out)
+	assert.Equal(t, subsvc.TypeInternalTransfer, out["data"].(map[string]interface{})["type"])
+	code, _ = f.do(t, "POST", "/transfer-credits", move)
+	assert.Equal(t, 400, code)
+	code, _ = f.do(t, "POST", "/transfer-credits", map[string]interface{}{"project_id": f.projectID, "amount": 1})
+	assert.Equal(t, 400, code)
+
+	// Selling and retiring draw on the named account only.
+	trading := &tradesvc.Service{DB: f.db}
+	ctx := context.Background()
+	_, err := trading.SellCredits(ctx, nil, f.orgID, nil, f.projectID, decimal.NewFromInt(50), decimal.NewFromInt(5))
+	assert.EqualError(t, err, "Insufficient credits to sell")
+	_, err = trading.SellCredits(ctx, nil, f.orgID, &fund, f.projectID, decimal.NewFromInt(20), decimal.NewFromInt(5))
+	require.NoError(t, err)
+	_, err = trading.RetireCredits(ctx, nil, f.orgID, &fund, f.projectID, decimal.NewFromInt(45), nil, nil)
+	assert.Error(t, err)
+	_, err = trading.RetireCredits(ctx, nil, f.orgID, &fund, f.projectID, decimal.NewFromInt(10), nil, nil)
+	require.NoError(t, err)
+
+	code, out = f.do(t, "GET", "/view-sub-accounts", nil)
+	require.Equal(t, 200, code)
+	data := out["data"].(map[string]interface{})
+	main := data["main"].(map[string]interface{})
+	assert.Equal(t, float64(40), main["credit_balance"])
+	assert.Equal(t, float64(0), main["locked_for_sale"])
+	sub := data["sub_accounts"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "Fund A", sub["name"])
+	assert.Equal(t, float64(50), sub["credit_balance"])
+	assert.Equal(t, float64(20), sub["locked_for_sale"])
+	assert.Equal(t, float64(10), sub["retired"])
+
+	code, out = f.do(t, "GET", "/view-transactions?sub_account_id="+fund.String(), nil)
+	require.Equal(t, 200, code)
+	assert.Len(t, out["data"], 2)
+	code, out = f.do(t, "GET", "/view-transactions", nil)
+	require.Equal(t, 200, code)
+	assert.Len(t, out["data"], 1)
+
+	// A sub-account with credits or an open listing cannot be deleted.
+	code, _ = f.do(t, "DELETE", "/delete-sub-account", map[string]string{"sub_account_id": fund.String()})
+	assert.Equal(t, 409, code)
+	empty := f.create(t, "Client B")
+	code, _ = f.do(t, "PATCH", "/update-sub-account", map[string]string{"sub_account_id": empty.String(), "name": "Fund A"})
+	assert.Equal(t, 409, code)
+	code, _ = f.do(t, "DELETE", "/delete-sub-account", map[string]string{"sub_account_id": empty.String()})
+	assert.Equal(t, 200, code)
+	code, _ = f.do(t, "DELETE", "/delete-sub-account", map[string]string{"sub_account_id": empty.String()})
+	assert.Equal(t, 404, code)
+}
+
+func TestSubAccounts_OtherOrgSubAccountNotFound(t *testing.T) {
+	f := setupSubAccountsTest(t)
+	other := domain.SubAccount{OrgID: uuid.New(), Name: "Theirs"}
+	require.NoError(t, f.db.Create(&other).Error)
+
+	code, _ := f.do(t, "POST", "/transfer-credits", map[string]interface{}{"project_id": f.projectID, "to_sub_account_id": other.SubAccountID, "amount": 5})
+	assert.Equal(t, 404, code)
+	code, _ = f.do(t, "GET", "/view-transactions?sub_account_id="+other.SubAccountID.String(), nil)
+	assert.Equal(t, 404, code)
+	_, err := (&tradesvc.Service{DB: f.db}).RetireCredits(context.Background(), nil, f.orgID, &other.SubAccountID, f.projectID, decimal.NewFromInt(5), nil, nil)
+	assert.Equal(t, subsvc.ErrNotFound, err)
+}
+
+func TestSubAccounts_LedgerMismatchIsAnError(t *testing.T) {
+	f := setupSubAccountsTest(t)
+	fund := f.create(t, "Fund")
+	code, out := f.do(t, "POST", "/transfer-credits", map[string]interface{}{"project_id": f.projectID, "to_sub_account_id": fund, "amount": 30})
+	require.Equal(t, 200, code, out)
+
+	// Express moves credits out of the org's Holding without the sub-accounts: they now hold more than the org.
+	require.NoError(t, f.db.Model(&domain.Holding{}).Where("org_id = ?", f.orgID).Update("credit_balance", decimal.NewFromInt(20)).Error)
+
+	code, out = f.do(t, "GET", "/view-sub-accounts", nil)
+	assert.Equal(t, 409, code, out)
+	code, out = f.do(t, "POST", "/transfer-credits", map[string]interface{}{"project_id": f.projectID, "from_sub_account_id": fund, "amount": 5})
+	assert.Equal(t, 409, code, out)
+	code, _ = f.do(t, "POST", "/transfer-credits", map[string]interface{}{"project_id": f.projectID, "to_sub_account_id": fund, "amount": 5})
+	assert.Equal(t, 409, code)
+
+	err := f.db.Transaction(func(tx *gorm.DB) error {
+		return subsvc.Apply(tx, f.orgID, &fund, f.projectID, decimal.NewFromInt(-40), decimal.Zero)
+	})
+	assert.Equal(t, subsvc.ErrLedgerMismatch, err)
+	var row domain.SubAccountHolding
+	require.NoError(t, f.db.Where("sub_account_id = ?", fund).First(&row).Error)
+	assert.Equal(t, "30", row.CreditBalance.String())
+}
+
+// Credits arriving after a Delete (whose Check passed before it) are refused instead of landing in a
+// sub-account that no longer exists.
+func TestSubAccounts_NoCreditsArriveInADeletedSubAccount(t *testing.T) {
+	f := setupSubAccountsTest(t)
+	fund := f.create(t, "Fund")
+	code, out := f.do(t, "DELETE", "/delete-sub-account", map[string]interface{}{"sub_account_id": fund})
+	require.Equal(t, 200, code, out)
+
+	err := f.db.Transaction(func(tx *gorm.DB) error {
+		return subsvc.Apply(tx, f.orgID, &fund, f.projectID, decimal.NewFromInt(5), decimal.Zero)
+	})
+	assert.Equal(t, subsvc.ErrNotFound, err)
+	var rows int64
+	require.NoError(t, f.db.Model(&domain.SubAccountHolding{}).Where("sub_account_id = ?", fund).Count(&rows).Error)
+	assert.Zero(t, rows)
+}
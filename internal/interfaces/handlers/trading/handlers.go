@@ -1,12 +1,19 @@
 package trading
 
 import (
-	"math"
+	"errors"
+	"fmt"
 	"os"
-	"strconv"
 
+	approvalsvc "troo-backend/internal/application/approvals"
+	auditsvc "troo-backend/internal/application/audit"
+	limitsvc "troo-backend/internal/application/limits"
+	"troo-backend/internal/application/subaccounts"
 	tradesvc "troo-backend/internal/application/trading"
+	"troo-backend/internal/application/verification"
+	"troo-backend/internal/domain"
 	"troo-backend/internal/middleware"
+	"troo-backend/internal/pkg/decimal"
 	"troo-backend/internal/pkg/response"
 
 	"github.com/gofiber/fiber/v2"
@@ -18,6 +25,12 @@ import (
 type Handlers struct {
 	Service       *tradesvc.Service
 	StripeCreator StripePaymentIntentCreator
+	// Approvals holds transfers/retirements matching the org's four-eyes policy; nil executes everything directly.
+	Approvals *approvalsvc.Service
+	// Limits enforces per-user/per-role trading limits; nil means no limits.
+	Limits *limitsvc.Service
+	// Audit records completed trades and held requests; nil records nothing.
+	Audit *auditsvc.Service
 }
 
 // StripePaymentIntentCreator abstracts Stripe PaymentIntent creation for testability.
@@ -61,13 +74,14 @@ func (r *RealStripeCreator) Create(amountCents int64, currency string, metadata
 // BuyCredits POST /api/v1/trading/buy-credits — ONLY creates Stripe PaymentIntent.
 func (h *Handlers) BuyCredits(c *fiber.Ctx) error {
 	var body struct {
-		ListingID string  `json:"listing_id"`
-		Amount    float64 `json:"amount"`
+		ListingID    string          `json:"listing_id"`
+		Amount       decimal.Decimal `json:"amount"`
+		SubAccountID *string         `json:"sub_account_id"`
 	}
 	if err := c.BodyParser(&body); err != nil {
 		return response.Error(c, "Missing required fields", 400, nil)
 	}
-	if body.ListingID == "" || body.Amount == 0 {
+	if body.ListingID == "" || body.Amount.IsZero() {
 		return response.Error(c, "Missing required fields", 400, nil)
 	}
 	if _, err := uuid.Parse(body.ListingID); err != nil {
@@ -78,33 +92,68 @@ func (h *Handlers) BuyCredits(c *fiber.Ctx) error {
 	if actor == nil || actor.OrgID == "" {
 		return response.Error(c, "Invalid UUID format for buyer_org_id", 400, nil)
 	}
-	if _, err := uuid.Parse(actor.OrgID); err != nil {
+	buyerOrgID, err := uuid.Parse(actor.OrgID)
+	if err != nil {
 		return response.Error(c, "Invalid UUID format for buyer_org_id", 400, nil)
 	}
+	subAccountID, err := h.subAccount(c, buyerOrgID, body.SubAccountID)
+	if err != nil {
+		return subAccountError(c, err)
+	}
 
-	if body.Amount <= 0 {
+	body.Amount = body.Amount.Round(domain.CreditScale)
+	if body.Amount.Sign() <= 0 {
 		return response.Error(c, "Amount must be a positive number", 400, nil)
 	}
 
-	amountCents := int64(math.Round(body.Amount * 100))
+	amountCents := body.Amount.Shift(2).IntPart()
 
 	if h.StripeCreator == nil {
 		return response.Error(c, "Stripe not configured", 500, nil)
 	}
 
-	pi, err := h.StripeCreator.Create(amountCents, "sgd", map[string]string{
+	listingID, _ := uuid.Parse(body.ListingID)
+	if err := h.Service.CheckSeller(c.Context(), listingID); err != nil {
+		if errors.Is(err, verification.ErrNotVerified) {
+			return response.Error(c, err.Error(), 403, nil)
+		}
+		return response.Error(c, "Internal Server Error", 500, nil)
+	}
+	usage, err := h.reserveLimits(c, actor, limitsvc.ReserveInput{Kind: limitsvc.KindBuy, ListingID: &listingID, Amount: body.Amount})
+	if err != nil {
+		return limitError(c, err)
+	}
+
+	metadata := map[string]string{
 		"listing_id":     body.ListingID,
 		"buyer_org_id":   actor.OrgID,
-		"credits_amount": strconv.FormatFloat(body.Amount, 'f', 2, 64),
-	})
+		"buyer_user_id":  actor.UserID,
+		"credits_amount": body.Amount.StringFixed(domain.CreditScale),
+	}
+	if subAccountID != nil {
+		metadata["buyer_sub_account_id"] = subAccountID.String()
+	}
+	pi, err := h.StripeCreator.Create(amountCents, "sgd", metadata)
 	if err != nil {
+		h.Limits.Release(c.Context(), usage)
 		code := 500
 		if e, ok := err.(*fiber.Error); ok {
 			code = e.Code
 		}
 		return response.Error(c, err.Error(), code, nil)
 	}
+	// The webhook keeps the reservation when the payment succeeds and releases it when it fails;
+	// an abandoned payment stops counting after limitsvc.PaymentHold.
+	if err := h.Limits.AttachPayment(c.Context(), usage, pi.ID); err != nil {
+		return response.Error(c, "Internal Server Error", 500, nil)
+	}
 
+	middleware.Audit(c, h.Audit, auditsvc.Entry{
+		Action:     auditsvc.ActionPurchaseStarted,
+		TargetType: "listing",
+		TargetID:   body.ListingID,
+		After:      fiber.Map{"amount": body.Amount, "payment_intent_id": pi.ID},
+	})
 	return response.Success(c, "Payment intent created", fiber.Map{
 		"payment_intent_id": pi.ID,
 		"client_secret":     pi.ClientSecret,
@@ -114,9 +163,10 @@ func (h *Handlers) BuyCredits(c *fiber.Ctx) error {
 // SellCredits POST /api/v1/trading/sell-credits
 func (h *Handlers) SellCredits(c *fiber.Ctx) error {
 	var body struct {
-		ProjectID string  `json:"project_id"`
-		Amount    float64 `json:"amount"`
-		Price     float64 `json:"price"`
+		ProjectID    string          `json:"project_id"`
+		Amount       decimal.Decimal `json:"amount"`
+		Price        decimal.Decimal `json:"price"`
+		SubAccountID *string         `json:"sub_account_id"`
 	}
 	if err := c.BodyParser(&body); err != nil {
 		return response.Error(c, "Missing required fields", 400, nil)
@@ -126,7 +176,7 @@ func (h *Handlers) SellCredits(c *fiber.Ctx) error {
 	if actor == nil || actor.OrgID == "" {
 		return response.Error(c, "User not associated with organization", 403, nil)
 	}
-	if body.ProjectID == "" || body.Amount == 0 || body.Price == 0 {
+	if body.ProjectID == "" || body.Amount.IsZero() || body.Price.IsZero() {
 		return response.Error(c, "Missing required fields", 400, nil)
 	}
 	orgID, _ := uuid.Parse(actor.OrgID)
@@ -134,35 +184,55 @@ func (h *Handlers) SellCredits(c *fiber.Ctx) error {
 	if err != nil {
 		return response.Error(c, "Invalid project_id", 400, nil)
 	}
-	if body.Amount <= 0 {
+	body.Amount = body.Amount.Round(domain.CreditScale)
+	if body.Amount.Sign() <= 0 {
 		return response.Error(c, "Invalid amount", 400, nil)
 	}
-	if body.Price <= 0 {
+	body.Price = body.Price.Round(domain.CreditScale)
+	if body.Price.Sign() <= 0 {
 		return response.Error(c, "Invalid price", 400, nil)
 	}
+	subAccountID, err := h.subAccount(c, orgID, body.SubAccountID)
+	if err != nil {
+		return subAccountError(c, err)
+	}
+
+	usage, err := h.reserveLimits(c, actor, limitsvc.ReserveInput{Kind: limitsvc.KindSell, ProjectID: projectID, Amount: body.Amount})
+	if err != nil {
+		return limitError(c, err)
+	}
 
-	result, err := h.Service.SellCredits(c.Context(), orgID, projectID, body.Amount, body.Price)
+	result, err := h.Service.SellCredits(c.Context(), actor.userUUID(), orgID, subAccountID, projectID, body.Amount, body.Price)
 	if err != nil {
+		h.Limits.Release(c.Context(), usage)
 		statusMap := map[string]int{
 			"Org not found":                      404,
 			"No holdings found for this project":  404,
 			"Insufficient credits to sell":         400,
 			"Project not found":                    404,
+			"Organization must be verified to sell or transfer credits": 403,
 		}
 		if code, ok := statusMap[err.Error()]; ok {
 			return response.Error(c, err.Error(), code, nil)
 		}
 		return response.Error(c, "Internal Server Error", 500, nil)
 	}
+	middleware.Audit(c, h.Audit, auditsvc.Entry{
+		Action:     auditsvc.ActionCreditsSold,
+		TargetType: "listing",
+		TargetID:   fmt.Sprint(result["listing_id"]),
+		After:      fiber.Map{"project_id": projectID, "sub_account_id": subAccountID, "amount": body.Amount, "price": body.Price, "credits_available": result["credits_available"]},
+	})
 	return response.Success(c, "Listing created/updated successfully", result, nil)
 }
 
 // TransferCredits POST /api/v1/trading/transfer-credits
 func (h *Handlers) TransferCredits(c *fiber.Ctx) error {
 	var body struct {
-		ToOrgCode string  `json:"to_org_code"`
-		ProjectID string  `json:"project_id"`
-		Amount    float64 `json:"amount"`
+		ToOrgCode    string          `json:"to_org_code"`
+		ProjectID    string          `json:"project_id"`
+		Amount       decimal.Decimal `json:"amount"`
+		SubAccountID *string         `json:"sub_account_id"`
 	}
 	if err := c.BodyParser(&body); err != nil {
 		return response.Error(c, "Missing required fields", 400, nil)
@@ -172,7 +242,7 @@ func (h *Handlers) TransferCredits(c *fiber.Ctx) error {
 	if actor == nil || actor.OrgID == "" {
 		return response.Error(c, "User not associated with an organization", 403, nil)
 	}
-	if body.ToOrgCode == "" || body.ProjectID == "" || body.Amount == 0 {
+	if body.ToOrgCode == "" || body.ProjectID == "" || body.Amount.IsZero() {
 		return response.Error(c, "Missing required fields", 400, nil)
 	}
 	fromOrgID, _ := uuid.Parse(actor.OrgID)
@@ -180,23 +250,64 @@ func (h *Handlers) TransferCredits(c *fiber.Ctx) error {
 	if err != nil {
 		return response.Error(c, "Invalid UUID format for project_id", 400, nil)
 	}
-	if body.Amount <= 0 {
+	body.Amount = body.Amount.Round(domain.CreditScale)
+	if body.Amount.Sign() <= 0 {
 		return response.Error(c, "Amount must be a positive number", 400, nil)
 	}
+	subAccountID, err := h.subAccount(c, fromOrgID, body.SubAccountID)
+	if err != nil {
+		return subAccountError(c, err)
+	}
+
+	// Held transfers still count towards the requester's daily volume.
+	usage, err := h.reserveLimits(c, actor, limitsvc.ReserveInput{Kind: limitsvc.KindTransfer, ProjectID: projectID, Amount: body.Amount})
+	if err != nil {
+		return limitError(c, err)
+	}
+
+	if h.Approvals != nil {
+		requestedBy, _ := uuid.Parse(actor.UserID)
+		pending, err := h.Approvals.SubmitIfRequired(c.Context(), approvalsvc.SubmitInput{
+			OrgID:        fromOrgID,
+			RequestedBy:  requestedBy,
+			Type:         approvalsvc.TypeTransfer,
+			ProjectID:    projectID,
+			SubAccountID: subAccountID,
+			Amount:       body.Amount,
+			ToOrgCode:    &body.ToOrgCode,
+			LimitUsageID: usageID(usage),
+		})
+		if err != nil {
+			h.Limits.Release(c.Context(), usage)
+			return response.Error(c, "Internal Server Error", 500, nil)
+		}
+		if pending != nil {
+			h.auditHeld(c, pending)
+			return response.SuccessAccepted(c, "Transfer pending approval", pending, nil)
+		}
+	}
 
-	result, err := h.Service.TransferCredits(c.Context(), fromOrgID, projectID, body.ToOrgCode, body.Amount)
+	result, err := h.Service.TransferCredits(c.Context(), actor.userUUID(), fromOrgID, subAccountID, projectID, body.ToOrgCode, body.Amount)
 	if err != nil {
+		h.Limits.Release(c.Context(), usage)
 		statusMap := map[string]int{
 			"Cannot transfer to the same organization":    400,
 			"No Holdings found for this project":           404,
 			"Insufficient available credits to transfer":   400,
 			"Target organization not found":                404,
+			"Organization must be verified to sell or transfer credits": 403,
 		}
 		if code, ok := statusMap[err.Error()]; ok {
 			return response.Error(c, err.Error(), code, nil)
 		}
 		return response.Error(c, "Internal Server Error", 500, nil)
 	}
+	middleware.Audit(c, h.Audit, auditsvc.Entry{
+		Action:     auditsvc.ActionCreditsTransferred,
+		TargetType: "project",
+		TargetID:   projectID.String(),
+		After:      fiber.Map{"to_org_code": body.ToOrgCode, "sub_account_id": subAccountID, "amount": body.Amount},
+	})
 	return response.Success(c, "Transfer successful", result, nil)
 }
 
@@ -212,18 +323,20 @@ func (h *Handlers) RetireCredits(c *fiber.Ctx) error {
 	}
 
 	var body struct {
-		ProjectID   string  `json:"project_id"`
-		Amount      float64 `json:"amount"`
-		Purpose     *string `json:"purpose"`
-		Beneficiary *string `json:"beneficiary"`
+		ProjectID    string          `json:"project_id"`
+		Amount       decimal.Decimal `json:"amount"`
+		Purpose      *string         `json:"purpose"`
+		Beneficiary  *string         `json:"beneficiary"`
+		SubAccountID *string         `json:"sub_account_id"`
 	}
 	if err := c.BodyParser(&body); err != nil {
 		return response.Error(c, "project_id and amount are required", 400, nil)
 	}
-	if body.ProjectID == "" || body.Amount == 0 {
+	if body.ProjectID == "" || body.Amount.IsZero() {
 		return response.Error(c, "project_id and amount are required", 400, nil)
 	}
-	if body.Amount <= 0 {
+	body.Amount = body.Amount.Round(domain.CreditScale)
+	if body.Amount.Sign() <= 0 {
 		return response.Error(c, "Amount must be a positive number", 400, nil)
 	}
 
@@ -231,17 +344,73 @@ func (h *Handlers) RetireCredits(c *fiber.Ctx) error {
 	if err != nil {
 		return response.Error(c, "Invalid project_id", 400, nil)
 	}
+	subAccountID, err := h.subAccount(c, orgID, body.SubAccountID)
+	if err != nil {
+		return subAccountError(c, err)
+	}
+
+	usage, err := h.reserveLimits(c, actor, limitsvc.ReserveInput{Kind: limitsvc.KindRetire, ProjectID: projectID, Amount: body.Amount})
+	if err != nil {
+		return limitError(c, err)
+	}
 
-	result, err := h.Service.RetireCredits(c.Context(), orgID, projectID, body.Amount, body.Purpose, body.Beneficiary)
+	if h.Approvals != nil {
+		requestedBy, _ := uuid.Parse(actor.UserID)
+		pending, err := h.Approvals.SubmitIfRequired(c.Context(), approvalsvc.SubmitInput{
+			OrgID:        orgID,
+			RequestedBy:  requestedBy,
+			Type:         approvalsvc.TypeRetire,
+			ProjectID:    projectID,
+			SubAccountID: subAccountID,
+			Amount:       body.Amount,
+			Purpose:      body.Purpose,
+			Beneficiary:  body.Beneficiary,
+			LimitUsageID: usageID(usage),
+		})
+		if err != nil {
+			h.Limits.Release(c.Context(), usage)
+			return response.Error(c, "Internal Server Error", 500, nil)
+		}
+		if pending != nil {
+			h.auditHeld(c, pending)
+			return response.SuccessAccepted(c, "Retirement pending approval", pending, nil)
+		}
+	}
+
+	result, err := h.Service.RetireCredits(c.Context(), actor.userUUID(), orgID, subAccountID, projectID, body.Amount, body.Purpose, body.Beneficiary)
 	if err != nil {
+		h.Limits.Release(c.Context(), usage)
 		return response.Error(c, err.Error(), 400, nil)
 	}
+	middleware.Audit(c, h.Audit, auditsvc.Entry{
+		Action:     auditsvc.ActionCreditsRetired,
+		TargetType: "retirement_certificate",
+		TargetID:   fmt.Sprint(result["certificate_id"]),
+		After: fiber.Map{
+			"project_id":         projectID,
+			"sub_account_id":     subAccountID,
+			"amount":             body.Amount,
+			"purpose":            body.Purpose,
+			"beneficiary":        body.Beneficiary,
+			"certificate_number": result["certificate_number"],
+		},
+	})
 	return response.Success(c, "Credits retired successfully", result, nil)
 }
 
 type tradingActor struct {
 	UserID string
 	OrgID  string
+	Role   string
+}
+
+// userUUID is the acting user for listing events and transactions; nil if the session id is not a UUID.
+func (a *tradingActor) userUUID() *uuid.UUID {
+	id, err := uuid.Parse(a.UserID)
+	if err != nil {
+		return nil
+	}
+	return &id
 }
 
 func getActorTrading(c *fiber.Ctx) *tradingActor {
@@ -254,13 +423,86 @@ func getActorTrading(c *fiber.Ctx) *tradingActor {
 		return nil
 	}
 	userID, _ := m["user_id"].(string)
+	role, _ := m["role"].(string)
 	orgID := ""
 	if o, ok := m["org_id"]; ok && o != nil {
 		if s, ok := o.(string); ok {
 			orgID = s
 		}
 	}
-	return &tradingActor{UserID: userID, OrgID: orgID}
+	return &tradingActor{UserID: userID, OrgID: orgID, Role: role}
+}
+
+// usageID returns the id of a reservation, or nil when no limit applied.
+func usageID(usage *domain.TradingLimitUsage) *uuid.UUID {
+	if usage == nil {
+		return nil
+	}
+	return &usage.UsageID
+}
+
+// reserveLimits counts the trade against the actor's trading limits (no-op without Limits).
+// The returned usage must be released if the trade does not go through.
+func (h *Handlers) reserveLimits(c *fiber.Ctx, actor *tradingActor, in limitsvc.ReserveInput) (*domain.TradingLimitUsage, error) {
+	if h.Limits == nil {
+		return nil, nil
+	}
+	in.OrgID, _ = uuid.Parse(actor.OrgID)
+	in.UserID, _ = uuid.Parse(actor.UserID)
+	in.Role = actor.Role
+	return h.Limits.Reserve(c.Context(), in)
+}
+
+// auditHeld records a transfer or retirement that was held for four-eyes approval instead of executed.
+func (h *Handlers) auditHeld(c *fiber.Ctx, req *domain.ApprovalRequest) {
+	middleware.Audit(c, h.Audit, auditsvc.Entry{
+		Action:     auditsvc.ActionApprovalRequested,
+		TargetType: "approval_request",
+		TargetID:   req.RequestID.String(),
+		After:      req,
+	})
+}
+
+var errInvalidSubAccount = errors.New("Invalid sub_account_id")
+
+// subAccount parses the optional sub_account_id of a trade and checks it is one of the org's sub-accounts.
+// Absent or empty is the main account (nil).
+func (h *Handlers) subAccount(c *fiber.Ctx, orgID uuid.UUID, raw *string) (*uuid.UUID, error) {
+	if raw == nil || *raw == "" {
+		return nil, nil
+	}
+	id, err := uuid.Parse(*raw)
+	if err != nil {
+		return nil, errInvalidSubAccount
+	}
+	if err := subaccounts.Check(h.Service.DB.WithContext(c.Context()), orgID, &id); err != nil {
+		return nil, err
+	}
+	return &id, nil
+}
+
+func subAccountError(c *fiber.Ctx, err error) error {
+	switch err {
+	case errInvalidSubAccount:
+		return response.Error(c, err.Error(), 400, nil)
+	case subaccounts.ErrNotFound:
+		return response.Error(c, err.Error(), 404, nil)
+	}
+	return response.Error(c, "Internal Server Error", 500, nil)
+}
+
+func limitError(c *fiber.Ctx, err error) error {
+	statusMap := map[string]int{
+		"Listing not found":                          404,
+		"Project not allowed by your trading limits": 403,
+		"Amount exceeds your per-trade limit":        403,
+		"Daily spend limit exceeded":                 403,
+		"Daily transfer volume limit exceeded":       403,
+	}
+	if code, ok := statusMap[err.Error()]; ok {
+		return response.Error(c, err.Error(), code, nil)
+	}
+	return response.Error(c, "Internal Server Error", 500, nil)
 }
 
 func init() {
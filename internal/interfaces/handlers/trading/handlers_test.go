@@ -7,7 +7,9 @@ import (
 	"testing"
 
 	tradesvc "troo-backend/internal/application/trading"
+	"troo-backend/internal/application/verification"
 	"troo-backend/internal/domain"
+	"troo-backend/internal/pkg/decimal"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
@@ -26,13 +28,24 @@ func (f *fakeStripe) Create(amountCents int64, currency string, metadata map[str
 	}, nil
 }
 
+// countingStripe counts the PaymentIntents created.
+type countingStripe struct {
+	fakeStripe
+	calls int
+}
+
+func (f *countingStripe) Create(amountCents int64, currency string, metadata map[string]string) (*StripePaymentIntentResult, error) {
+	f.calls++
+	return f.fakeStripe.Create(amountCents, currency, metadata)
+}
+
 func setupTradingTest(t *testing.T) (*Handlers, *gorm.DB) {
 	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
 	require.NoError(t, err)
 	require.NoError(t, db.AutoMigrate(
 		&domain.Listing{}, &domain.Holding{}, &domain.Org{},
 		&domain.Transaction{}, &domain.RetirementCertificate{},
-		&domain.IcrProject{},
+		&domain.IcrProject{}, &domain.OrgVerification{}, &domain.SubAccount{}, &domain.SubAccountHolding{},
 	))
 	svc := &tradesvc.Service{DB: db}
 	h := &Handlers{Service: svc, StripeCreator: &fakeStripe{}}
@@ -82,6 +95,45 @@ func TestBuyCredits_ReturnsPaymentIntent(t *testing.T) {
 	assert.Equal(t, "pi_test_123_secret_abc", data["client_secret"])
 }
 
+func TestBuyCredits_UnverifiedSellerRefusedBeforePayment(t *testing.T) {
+	h, db := setupTradingTest(t)
+	stripe := &countingStripe{}
+	h.StripeCreator = stripe
+	seller := domain.Org{OrgName: "Seller", OrgCode: "SE-000001", CountryCode: "SG"}
+	require.NoError(t, db.Create(&seller).Error)
+	listing := domain.Listing{ProjectID: uuid.New(), SellerID: &seller.OrgID, CreditsAvailable: decimal.NewFromInt(100), PricePerCredit: decimal.NewFromInt(5), Status: "open"}
+	require.NoError(t, db.Create(&listing).Error)
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("user", map[string]interface{}{
+			"user_id": uuid.New().String(),
+			"org_id":  uuid.New().String(),
+		})
+		return c.Next()
+	})
+	app.Post("/buy-credits", h.BuyCredits)
+	buy := func() (int, map[string]interface{}) {
+		body, _ := json.Marshal(map[string]interface{}{"listing_id": listing.ListingID.String(), "amount": 10})
+		req := httptest.NewRequest("POST", "/buy-credits", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		var result map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&result)
+		return resp.StatusCode, result
+	}
+
+	code, result := buy()
+	assert.Equal(t, 403, code)
+	assert.Equal(t, verification.ErrNotVerified.Error(), result["error"].(map[string]interface{})["message"])
+	assert.Zero(t, stripe.calls)
+
+	require.NoError(t, db.Create(&domain.OrgVerification{OrgID: seller.OrgID, Status: verification.StatusVerified}).Error)
+	code, _ = buy()
+	assert.Equal(t, 200, code)
+	assert.Equal(t, 1, stripe.calls)
+}
+
 func TestSellCredits_MissingOrg(t *testing.T) {
 	h, _ := setupTradingTest(t)
 	app := fiber.New()
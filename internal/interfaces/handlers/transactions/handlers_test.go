@@ -1,11 +1,13 @@
 package transactions
 
 import (
+	"encoding/json"
 	"net/http/httptest"
 	"testing"
 
 	txsvc "troo-backend/internal/application/transactions"
 	"troo-backend/internal/domain"
+	"troo-backend/internal/pkg/decimal"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
@@ -59,3 +61,29 @@ func TestGetTransactions_EmptyResult(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, 200, resp.StatusCode)
 }
+
+func TestGetTransactions_ActorShownOnlyToActingOrg(t *testing.T) {
+	h, db := setupTxTest(t)
+	buyer, seller, actor := uuid.New(), uuid.New(), uuid.New()
+	require.NoError(t, db.Create(&domain.Transaction{
+		Type: "buy", FromOrgID: &seller, ToOrgID: &buyer, ProjectID: uuid.New(), Amount: decimal.NewFromInt(5), ActorUserID: &actor,
+	}).Error)
+
+	get := func(orgID uuid.UUID) map[string]interface{} {
+		app := fiber.New()
+		app.Use(func(c *fiber.Ctx) error {
+			c.Locals("user", map[string]interface{}{"user_id": uuid.New().String(), "org_id": orgID.String()})
+			return c.Next()
+		})
+		app.Get("/get-transactions", h.GetTransactions)
+		resp, err := app.Test(httptest.NewRequest("GET", "/get-transactions", nil))
+		require.NoError(t, err)
+		require.Equal(t, 200, resp.StatusCode)
+		var body map[string]interface{}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		return body["data"].([]interface{})[0].(map[string]interface{})
+	}
+
+	assert.Equal(t, actor.String(), get(buyer)["actor_user_id"])
+	assert.Nil(t, get(seller)["actor_user_id"])
+}
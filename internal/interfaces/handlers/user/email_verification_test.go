@@ -0,0 +1,167 @@
+package user
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"troo-backend/internal/middleware"
+	"troo-backend/internal/pkg/constants"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeVerifySender captures verification links; the other emails are dropped.
+type fakeVerifySender struct{ links chan string }
+
+func (f *fakeVerifySender) SendWelcome(ctx context.Context, toEmail, firstName string) error {
+	return nil
+}
+func (f *fakeVerifySender) SendInvite(ctx context.Context, toEmail, inviteLink, orgName, role, subject string) error {
+	return nil
+}
+func (f *fakeVerifySender) SendAccountUpdated(ctx context.Context, toEmail, firstName string) error {
+	return nil
+}
+func (f *fakeVerifySender) SendPasswordReset(ctx context.Context, toEmail, firstName, resetLink string) error {
+	return nil
+}
+func (f *fakeVerifySender) SendAccountLocked(ctx context.Context, toEmail, firstName, resetLink string) error {
+	return nil
+}
+func (f *fakeVerifySender) SendVerifyEmail(ctx context.Context, toEmail, firstName, verifyLink string) error {
+	f.links <- verifyLink
+	return nil
+}
+
+func (f *fakeVerifySender) token(t *testing.T) string {
+	select {
+	case link := <-f.links:
+		u, err := url.Parse(link)
+		require.NoError(t, err)
+		assert.Equal(t, "/verify-email", u.Path)
+		return u.Query().Get("token")
+	case <-time.After(2 * time.Second):
+		t.Fatal("no verification email sent")
+		return ""
+	}
+}
+
+// setupVerifyApp keeps one session across requests, as the Redis store would.
+func setupVerifyApp(t *testing.T) (*fiber.App, *Handlers, *fakeVerifySender, map[string]interface{}) {
+	h, svc, _, _ := setupUserTest(t)
+	sender := &fakeVerifySender{links: make(chan string, 4)}
+	svc.EmailSender = sender
+	svc.AppBaseURL = "https://atlas.troo.earth"
+
+	session := map[string]interface{}{}
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("session_data", session)
+		c.Locals("user", session["user"])
+		c.Locals("session_id", "sid-1")
+		return c.Next()
+	})
+	app.Post("/create-user", h.CreateUser)
+	app.Put("/update-user", h.UpdateUser)
+	app.Post("/verify-email", h.VerifyEmail)
+	app.Post("/resend-verification", h.ResendVerification)
+	app.Post("/create-listing", middleware.AuthorizePermission(constants.CreateListing), func(c *fiber.Ctx) error { return c.SendStatus(200) })
+	app.Get("/view", middleware.AuthorizePermission(constants.ViewData), func(c *fiber.Ctx) error { return c.SendStatus(200) })
+	return app, h, sender, session
+}
+
+func send(t *testing.T, app *fiber.App, method, path string, body interface{}) (int, map[string]interface{}) {
+	b, _ := json.Marshal(body)
+	req := httptest.NewRequest(method, path, bytes.NewReader(b))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	var out map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&out)
+	return resp.StatusCode, out
+}
+
+func TestEmailVerification_RegistrationGatesTrading(t *testing.T) {
+	app, _, sender, session := setupVerifyApp(t)
+
+	code, out := send(t, app, "POST", "/create-user", map[string]string{
+		"user_name": "jane", "email": "jane@example.com", "password": "Pass1!word", "fullname": "Jane Doe",
+	})
+	require.Equal(t, 201, code, out)
+	assert.Equal(t, false, out["data"].(map[string]interface{})["email_verified"])
+	token := sender.token(t)
+	promote(session)
+
+	// Unverified users keep read access but lose trading permissions.
+	code, _ = send(t, app, "GET", "/view", nil)
+	assert.Equal(t, 200, code)
+	code, out = send(t, app, "POST", "/create-listing", nil)
+	assert.Equal(t, 403, code)
+	assert.Equal(t, "Email address must be verified to perform this action", out["error"].(map[string]interface{})["message"])
+
+	// Resends are throttled.
+	code, _ = send(t, app, "POST", "/resend-verification", nil)
+	assert.Equal(t, 429, code)
+
+	code, _ = send(t, app, "POST", "/verify-email", map[string]string{"token": "nope"})
+	assert.Equal(t, 400, code)
+	code, _ = send(t, app, "POST", "/verify-email", map[string]string{"token": token})
+	require.Equal(t, 200, code)
+	code, _ = send(t, app, "POST", "/create-listing", nil)
+	assert.Equal(t, 200, code)
+
+	code, _ = send(t, app, "POST", "/verify-email", map[string]string{"token": token})
+	assert.Equal(t, 400, code)
+	code, _ = send(t, app, "POST", "/resend-verification", nil)
+	assert.Equal(t, 409, code)
+}
+
+func TestEmailVerification_EmailChangeRequiresReverification(t *testing.T) {
+	app, h, sender, session := setupVerifyApp(t)
+	code, _ := send(t, app, "POST", "/create-user", map[string]string{
+		"user_name": "jane", "email": "jane@example.com", "password": "Pass1!word", "fullname": "Jane Doe",
+	})
+	require.Equal(t, 201, code)
+	promote(session)
+	first := sender.token(t)
+	code, _ = send(t, app, "POST", "/verify-email", map[string]string{"token": first})
+	require.Equal(t, 200, code)
+
+	// Another device is signed in as the same user.
+	ctx := context.Background()
+	userID := session["user"].(map[string]interface{})["user_id"].(string)
+	require.NoError(t, h.Service.Rdb.SAdd(ctx, constants.UserSessionsPrefix+userID, "sid-2").Err())
+	require.NoError(t, h.Service.Rdb.Set(ctx, middleware.SessionRedisPrefix+"sid-2", "{}", 0).Err())
+
+	code, out := send(t, app, "PUT", "/update-user", map[string]string{"email": "Jane@New.example.com"})
+	require.Equal(t, 200, code, out)
+	assert.Equal(t, false, out["data"].(map[string]interface{})["user"].(map[string]interface{})["email_verified"])
+	second := sender.token(t)
+
+	code, _ = send(t, app, "POST", "/create-listing", nil)
+	assert.Equal(t, 403, code)
+	assert.Equal(t, int64(0), h.Service.Rdb.Exists(ctx, middleware.SessionRedisPrefix+"sid-2").Val())
+	assert.True(t, h.Service.Rdb.SIsMember(ctx, constants.UserSessionsPrefix+userID, "sid-1").Val())
+
+	code, _ = send(t, app, "POST", "/verify-email", map[string]string{"token": second})
+	require.Equal(t, 200, code)
+	code, _ = send(t, app, "POST", "/create-listing", nil)
+	assert.Equal(t, 200, code)
+
+	// Updating other fields leaves the flag alone.
+	code, out = send(t, app, "PUT", "/update-user", map[string]string{"fullname": "Jane Smith"})
+	require.Equal(t, 200, code)
+	assert.Equal(t, true, out["data"].(map[string]interface{})["user"].(map[string]interface{})["email_verified"])
+}
+
+// promote makes the registered user a manager, as joining an org would, so only verification gates trading.
+func promote(session map[string]interface{}) {
+	session["user"].(map[string]interface{})["role"] = constants.Manager
+}
@@ -1,21 +1,23 @@
 package user
 
 import (
+	auditsvc "troo-backend/internal/application/audit"
+	"troo-backend/internal/application/memberships"
 	usersvc "troo-backend/internal/application/user"
 	"troo-backend/internal/domain"
 	"troo-backend/internal/middleware"
+	"troo-backend/internal/pkg/constants"
 	"troo-backend/internal/pkg/response"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 )
 
-const userSessionsPrefix = "user_sessions:"
-
 // Handlers holds the user service and session config for create-user (session + cookie).
 type Handlers struct {
 	Service *usersvc.Service
 	Config  middleware.SessionConfig
+	Audit   *auditsvc.Service
 }
 
 // CreateUserRequest body (Express: user_name, email, password, fullname).
@@ -52,19 +54,21 @@ func (h *Handlers) CreateUser(c *fiber.Ctx) error {
 	// Rotate session and set identity (Express: session.regenerate, session.user, sAdd user_sessions)
 	sid := middleware.RegenerateSessionID(c)
 	middleware.SetSessionUser(c, middleware.SessionUser{
-		UserID:   u.UserID.String(),
-		Fullname: u.Fullname,
-		Email:    u.Email,
-		Role:     u.Role,
-		OrgID:    orgIDStr,
+		UserID:        u.UserID.String(),
+		Fullname:      u.Fullname,
+		Email:         u.Email,
+		Role:          u.Role,
+		OrgID:         orgIDStr,
+		EmailVerified: u.EmailVerified,
+		AuthMethod:    middleware.AuthMethodPassword,
 	})
 	if h.Service.Rdb != nil {
-		_ = h.Service.Rdb.SAdd(c.Context(), userSessionsPrefix+u.UserID.String(), sid).Err()
+		_ = h.Service.Rdb.SAdd(c.Context(), constants.UserSessionsPrefix+u.UserID.String(), sid).Err()
 	}
 
-	// Cookie: troo.sid = "s:"+sid (Express: same as login, no domain when setting)
+	// Cookie: signed troo.sid (Express: same as login, no domain when setting)
 	cookie := middleware.SessionCookieConfig(h.Config)
-	cookie.Value = "s:" + sid
+	cookie.Value = middleware.SessionCookieValue(h.Config, sid)
 	c.Cookie(&cookie)
 
 	// Return user as data directly so frontend extractRegisterAuthObject(response.data?.data) gets fullname etc. on first load (onboarding shows name instead of "USER").
@@ -91,9 +95,61 @@ func (h *Handlers) UpdateUser(c *fiber.Ctx) error {
 	if err != nil {
 		return mapUpdateError(c, err)
 	}
+	// An email change ends the user's sessions; this one is kept, now unverified.
+	if _, ok := body["email"]; ok && !u.EmailVerified {
+		middleware.SetSessionEmail(c, u.Email, false)
+		if sid := middleware.GetSessionID(c); sid != "" && h.Service.Rdb != nil {
+			_ = h.Service.Rdb.SAdd(c.Context(), constants.UserSessionsPrefix+userID, sid).Err()
+		}
+	}
 	return response.Success(c, "User updated successfully", fiber.Map{"user": safeUser(u)}, nil)
 }
 
+// VerifyEmail POST /api/v1/users/verify-email — body: token. Public, as the emailed link may be opened signed out.
+func (h *Handlers) VerifyEmail(c *fiber.Ctx) error {
+	var body struct {
+		Token string `json:"token"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return response.Error(c, "Invalid request body", 400, nil)
+	}
+	u, err := h.Service.VerifyEmail(c.Context(), body.Token)
+	if err != nil {
+		switch err.Error() {
+		case "Verification token is required", "Invalid or expired verification token":
+			return response.Error(c, err.Error(), 400, nil)
+		default:
+			return response.Error(c, "Internal Server Error", 500, nil)
+		}
+	}
+	// The signed-in session picks up the flag now; other sessions pick it up at their next login.
+	if actor := getSessionActor(c); actor != nil && actor.UserID == u.UserID.String() {
+		middleware.SetSessionEmail(c, u.Email, true)
+	}
+	return response.Success(c, "Email verified successfully", fiber.Map{"user": safeUser(u)}, nil)
+}
+
+// ResendVerification POST /api/v1/users/resend-verification — emails a new link for the session user's email.
+func (h *Handlers) ResendVerification(c *fiber.Ctx) error {
+	actor := getSessionActor(c)
+	if actor == nil {
+		return response.Unauthorized(c, "Unauthorized")
+	}
+	if err := h.Service.ResendVerification(c.Context(), actor.UserID); err != nil {
+		switch err.Error() {
+		case "Email already verified":
+			return response.Error(c, err.Error(), 409, nil)
+		case "Verification email can only be resent once per minute":
+			return response.Error(c, err.Error(), 429, nil)
+		case "User not found":
+			return response.Error(c, err.Error(), 404, nil)
+		default:
+			return response.Error(c, "Internal Server Error", 500, nil)
+		}
+	}
+	return response.Success(c, "Verification email sent", nil, nil)
+}
+
 // ViewUser GET /api/v1/users/view-user — returns the session user (user_id from session).
 func (h *Handlers) ViewUser(c *fiber.Ctx) error {
 	actor := getSessionActor(c)
@@ -109,10 +165,11 @@ func (h *Handlers) ViewUser(c *fiber.Ctx) error {
 	return response.Success(c, "User found", fiber.Map{"user": safeUser(u)}, nil)
 }
 
-// UpdateRoleRequest body: user_id, role (Express updateUserRoleController).
+// UpdateRoleRequest body: user_id, role (Express updateUserRoleController); custom_role_id assigns an org-defined role instead.
 type UpdateRoleRequest struct {
-	UserID string `json:"user_id"`
-	Role   string `json:"role"`
+	UserID       string `json:"user_id"`
+	Role         string `json:"role"`
+	CustomRoleID string `json:"custom_role_id"`
 }
 
 // UpdateRole PATCH /api/v1/users/update-role — requires ASSIGN_ROLE (middleware applied on route).
@@ -121,7 +178,7 @@ func (h *Handlers) UpdateRole(c *fiber.Ctx) error {
 	if err := c.BodyParser(&req); err != nil {
 		return response.Error(c, "user_id and role are required", 400, nil)
 	}
-	if req.UserID == "" || req.Role == "" {
+	if req.UserID == "" || (req.Role == "" && req.CustomRoleID == "") {
 		return response.Error(c, "user_id and role are required", 400, nil)
 	}
 
@@ -130,17 +187,26 @@ func (h *Handlers) UpdateRole(c *fiber.Ctx) error {
 		return response.Unauthorized(c, "Unauthorized")
 	}
 
+	before, _ := h.Service.ViewMember(c.Context(), req.UserID, actor.OrgID)
 	u, err := h.Service.UpdateUserRole(c.Context(), usersvc.UpdateUserRoleInput{
-		ActorUserID:  actor.UserID,
-		ActorRole:    actor.Role,
-		TargetUserID: req.UserID,
-		TargetRole:   req.Role,
-		OrgID:        actor.OrgID,
+		ActorUserID:        actor.UserID,
+		ActorRole:          actor.Role,
+		TargetUserID:       req.UserID,
+		TargetRole:         req.Role,
+		OrgID:              actor.OrgID,
+		TargetCustomRoleID: req.CustomRoleID,
 	})
 	if err != nil {
 		// Express: return res.error(error.message, 400)
 		return response.Error(c, err.Error(), 400, nil)
 	}
+	middleware.Audit(c, h.Audit, auditsvc.Entry{
+		Action:     auditsvc.ActionRoleChanged,
+		TargetType: "user",
+		TargetID:   u.UserID.String(),
+		Before:     roleState(before),
+		After:      roleState(u),
+	})
 	return response.Success(c, "User role updated successfully", fiber.Map{"user": safeUser(u)}, nil)
 }
 
@@ -167,6 +233,7 @@ func (h *Handlers) RemoveUser(c *fiber.Ctx) error {
 		return response.Unauthorized(c, "Unauthorized")
 	}
 
+	before, _ := h.Service.ViewMember(c.Context(), req.UserID, actor.OrgID)
 	err := h.Service.RemoveUserFromOrg(c.Context(), usersvc.RemoveUserFromOrgInput{
 		ActorUserID:  actor.UserID,
 		ActorRole:    actor.Role,
@@ -176,9 +243,146 @@ func (h *Handlers) RemoveUser(c *fiber.Ctx) error {
 	if err != nil {
 		return response.Error(c, err.Error(), 400, nil)
 	}
+	var removed interface{}
+	if before != nil {
+		removed = fiber.Map{"email": before.Email, "role": before.Role, "custom_role_id": nilUUIDString(before.CustomRoleID)}
+	}
+	middleware.Audit(c, h.Audit, auditsvc.Entry{
+		Action:     auditsvc.ActionUserRemoved,
+		TargetType: "user",
+		TargetID:   req.UserID,
+		Before:     removed,
+	})
 	return response.Success(c, "User removed from organization", nil, nil)
 }
 
+// ForceLogout DELETE /api/v1/users/force-logout — body: user_id. Signs a member of the actor's org out everywhere.
+func (h *Handlers) ForceLogout(c *fiber.Ctx) error {
+	var req RemoveUserRequest
+	if err := c.BodyParser(&req); err != nil || req.UserID == "" {
+		return response.Error(c, "user_id is required", 400, nil)
+	}
+	if _, err := uuid.Parse(req.UserID); err != nil {
+		return response.Error(c, "Invalid user ID format (must be a valid UUID)", 400, nil)
+	}
+
+	actor := getSessionActor(c)
+	if actor == nil {
+		return response.Unauthorized(c, "Unauthorized")
+	}
+
+	err := h.Service.ForceLogout(c.Context(), usersvc.RemoveUserFromOrgInput{
+		ActorUserID:  actor.UserID,
+		ActorRole:    actor.Role,
+		TargetUserID: req.UserID,
+		OrgID:        actor.OrgID,
+	})
+	if err != nil {
+		switch err.Error() {
+		case "User not found":
+			return response.Error(c, err.Error(), 404, nil)
+		case "User does not belong to your organization", "Admins cannot sign out admins or superadmins":
+			return response.Error(c, err.Error(), 403, nil)
+		case "Use your active sessions to sign yourself out":
+			return response.Error(c, err.Error(), 400, nil)
+		default:
+			return response.Error(c, "Internal Server Error", 500, nil)
+		}
+	}
+	middleware.Audit(c, h.Audit, auditsvc.Entry{
+		Action:     auditsvc.ActionUserSignedOut,
+		TargetType: "user",
+		TargetID:   req.UserID,
+	})
+	return response.Success(c, "User signed out of all sessions", nil, nil)
+}
+
+// ViewMemberships GET /api/v1/users/view-memberships — the session user's orgs, marking the session's.
+func (h *Handlers) ViewMemberships(c *fiber.Ctx) error {
+	actor := getSessionActor(c)
+	if actor == nil {
+		return response.Unauthorized(c, "Unauthorized")
+	}
+	list, err := h.Service.ListMemberships(c.Context(), actor.UserID, actor.OrgID)
+	if err != nil {
+		return mapViewError(c, err)
+	}
+	return response.Success(c, "Memberships found", fiber.Map{"memberships": list}, nil)
+}
+
+// SwitchOrgRequest body: org_id of one of the session user's memberships.
+type SwitchOrgRequest struct {
+	OrgID string `json:"org_id"`
+}
+
+// SwitchOrg POST /api/v1/users/switch-org — moves the session to another of the user's orgs: org_id, role,
+// custom role permissions and the org's 2FA requirement. Only this session moves; the user's other sessions
+// and home org stay as they are. A TOTP check already passed carries over. A session signed in through an
+// org's SSO is bound to that org.
+func (h *Handlers) SwitchOrg(c *fiber.Ctx) error {
+	var req SwitchOrgRequest
+	if err := c.BodyParser(&req); err != nil || req.OrgID == "" {
+		return response.Error(c, "org_id is required", 400, nil)
+	}
+	orgID, err := uuid.Parse(req.OrgID)
+	if err != nil {
+		return response.Error(c, "Invalid org_id", 400, nil)
+	}
+	actor := getSessionActor(c)
+	if actor == nil {
+		return response.Unauthorized(c, "Unauthorized")
+	}
+	sessionUser, _ := middleware.GetUser(c).(map[string]interface{})
+	if ssoOrgID := middleware.SSOOrgID(sessionUser); ssoOrgID != "" && ssoOrgID != orgID.String() {
+		return response.Error(c, middleware.ErrSSOSessionBound.Error(), 403, nil)
+	}
+	res, err := h.Service.SwitchOrg(c.Context(), actor.UserID, orgID)
+	if err != nil {
+		if err == memberships.ErrNotMember {
+			return response.Error(c, err.Error(), 403, nil)
+		}
+		return mapViewError(c, err)
+	}
+	u := res.User
+	twoFactor, _ := sessionUser["two_factor"].(bool)
+	next := middleware.SessionUser{
+		UserID:            u.UserID.String(),
+		Fullname:          u.Fullname,
+		Email:             u.Email,
+		Role:              u.Role,
+		OrgID:             nilUUIDString(u.OrgID),
+		EmailVerified:     u.EmailVerified,
+		TwoFactor:         twoFactor,
+		TwoFactorRequired: res.Org.RequireTwoFactor,
+	}
+	if u.CustomRole != nil {
+		roleID := u.CustomRole.RoleID.String()
+		next.CustomRoleID = &roleID
+		next.Permissions = u.CustomRole.PermissionList()
+	}
+	if platformRole, ok := sessionUser["platform_role"].(string); ok {
+		next.PlatformRole = &platformRole
+	}
+	next.AuthMethod, _ = sessionUser["auth_method"].(string)
+	if ssoOrgID, ok := sessionUser["sso_org_id"].(string); ok {
+		next.SSOOrgID = &ssoOrgID
+	}
+	middleware.SetSessionUser(c, next)
+
+	middleware.Audit(c, h.Audit, auditsvc.Entry{
+		Action:     auditsvc.ActionOrgSwitched,
+		TargetType: "user",
+		TargetID:   actor.UserID,
+		Before:     fiber.Map{"org_id": actor.OrgID},
+		After:      fiber.Map{"org_id": next.OrgID, "role": u.Role, "custom_role_id": next.CustomRoleID},
+	})
+	return response.Success(c, "Switched organization", fiber.Map{
+		"user":        safeUser(u),
+		"org_name":    res.Org.OrgName,
+		"permissions": next.Permissions,
+	}, nil)
+}
+
 type sessionActor struct {
 	UserID string
 	Role   string
@@ -214,15 +418,25 @@ func safeUser(u *domain.User) fiber.Map {
 		orgID = u.OrgID.String()
 	}
 	return fiber.Map{
-		"user_id":   u.UserID.String(),
-		"fullname":  u.Fullname,
-		"user_name": u.UserName,
-		"email":     u.Email,
-		"org_id":    orgID,
-		"role":      u.Role,
-		"createdAt": u.CreatedAt,
-		"updatedAt": u.UpdatedAt,
+		"user_id":        u.UserID.String(),
+		"fullname":       u.Fullname,
+		"user_name":      u.UserName,
+		"email":          u.Email,
+		"email_verified": u.EmailVerified,
+		"org_id":         orgID,
+		"role":           u.Role,
+		"custom_role_id": nilUUIDString(u.CustomRoleID),
+		"createdAt":      u.CreatedAt,
+		"updatedAt":      u.UpdatedAt,
+	}
+}
+
+// roleState is the audited view of a user's role; nil when the user could not be loaded.
+func roleState(u *domain.User) interface{} {
+	if u == nil {
+		return nil
 	}
+	return fiber.Map{"role": u.Role, "custom_role_id": nilUUIDString(u.CustomRoleID)}
 }
 
 func nilUUIDString(u *uuid.UUID) *string {
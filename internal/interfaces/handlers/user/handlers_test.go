@@ -1,11 +1,13 @@
 package user
 
 import (
+	"context"
 	"bytes"
 	"encoding/json"
 	"net/http/httptest"
 	"testing"
 
+	"troo-backend/internal/application/memberships"
 	usersvc "troo-backend/internal/application/user"
 	"troo-backend/internal/domain"
 	"troo-backend/internal/middleware"
@@ -31,7 +33,7 @@ func setupUserTest(t *testing.T) (*Handlers, *usersvc.Service, *redis.Client, *g
 	})
 	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
 	require.NoError(t, err)
-	require.NoError(t, db.AutoMigrate(&domain.User{}))
+	require.NoError(t, db.AutoMigrate(&domain.User{}, &domain.OrgMembership{}, &domain.EmailVerificationToken{}))
 	svc := &usersvc.Service{DB: db, Rdb: rdb}
 	handlers := &Handlers{
 		Service: svc,
@@ -175,3 +177,172 @@ func TestRemoveUser_SelfRemovalRejected(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
 }
+
+// Test ForceLogout: admins sign members out everywhere, but not other admins or people outside the org.
+func TestForceLogout_SignsMemberOut(t *testing.T) {
+	h, _, rdb, db := setupUserTest(t)
+	orgID := uuid.New()
+	admin, member, otherAdmin, outsider := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	otherOrg := uuid.New()
+	for _, u := range []domain.User{
+		{UserID: admin, UserName: "admin", Email: "admin@test.com", PasswordHash: "x", Fullname: "A", Role: constants.Admin, OrgID: &orgID},
+		{UserID: member, UserName: "member", Email: "member@test.com", PasswordHash: "x", Fullname: "M", Role: constants.Manager, OrgID: &orgID},
+		{UserID: otherAdmin, UserName: "admin2", Email: "admin2@test.com", PasswordHash: "x", Fullname: "B", Role: constants.Admin, OrgID: &orgID},
+		{UserID: outsider, UserName: "out", Email: "out@test.com", PasswordHash: "x", Fullname: "O", Role: constants.Manager, OrgID: &otherOrg},
+	} {
+		require.NoError(t, db.Create(&u).Error)
+	}
+	ctx := context.Background()
+	rdb.SAdd(ctx, constants.UserSessionsPrefix+member.String(), "sid-m")
+	rdb.Set(ctx, middleware.SessionRedisPrefix+"sid-m", "{}", 0)
+
+	app := fiber.New()
+	oid := orgID.String()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("user", map[string]interface{}{"user_id": admin.String(), "role": constants.Admin, "org_id": oid})
+		return c.Next()
+	})
+	app.Use(middleware.AuthorizePermission(constants.RemoveUser))
+	app.Delete("/force-logout", h.ForceLogout)
+	forceLogout := func(id uuid.UUID) int {
+		body, _ := json.Marshal(map[string]string{"user_id": id.String()})
+		req := httptest.NewRequest("DELETE", "/force-logout", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return resp.StatusCode
+	}
+
+	assert.Equal(t, fiber.StatusBadRequest, forceLogout(admin))
+	assert.Equal(t, fiber.StatusForbidden, forceLogout(otherAdmin))
+	assert.Equal(t, fiber.StatusForbidden, forceLogout(outsider))
+	assert.Equal(t, fiber.StatusNotFound, forceLogout(uuid.New()))
+	assert.Equal(t, fiber.StatusOK, forceLogout(member))
+	assert.Equal(t, int64(0), rdb.Exists(ctx, middleware.SessionRedisPrefix+"sid-m").Val())
+}
+
+func TestMemberships_SwitchOrgAndPerOrgGovernance(t *testing.T) {
+	h, _, _, db := setupUserTest(t)
+	require.NoError(t, db.AutoMigrate(&domain.Org{}, &domain.CustomRole{}))
+	orgA, orgB := uuid.New(), uuid.New()
+	for _, o := range []domain.Org{
+		{OrgID: orgA, OrgName: "Alpha", OrgCode: "AL-000001", CountryCode: "GB"},
+		{OrgID: orgB, OrgName: "Beta", OrgCode: "BE-000001", CountryCode: "GB", RequireTwoFactor: true},
+	} {
+		require.NoError(t, db.Create(&o).Error)
+	}
+	ownerA, ownerB, consultant := uuid.New(), uuid.New(), uuid.New()
+	for _, u := range []domain.User{
+		{UserID: ownerA, UserName: "a", Email: "a@test.com", PasswordHash: "x", Fullname: "A", Role: constants.Superadmin, OrgID: &orgA},
+		{UserID: ownerB, UserName: "b", Email: "b@test.com", PasswordHash: "x", Fullname: "B", Role: constants.Superadmin, OrgID: &orgB},
+		{UserID: consultant, UserName: "c", Email: "c@test.com", PasswordHash: "x", Fullname: "C", Role: constants.Viewer, OrgID: &orgA},
+	} {
+		require.NoError(t, db.Create(&u).Error)
+	}
+	var c domain.User
+	require.NoError(t, db.Where("user_id = ?", consultant).First(&c).Error)
+	require.NoError(t, memberships.Add(db, &c, orgB, constants.Manager, nil))
+
+	sendAs := func(session map[string]interface{}, method, path string, body interface{}) (int, map[string]interface{}) {
+		app := fiber.New()
+		app.Use(func(c *fiber.Ctx) error {
+			c.Locals("user", session)
+			return c.Next()
+		})
+		app.Get("/view-memberships", h.ViewMemberships)
+		app.Post("/switch-org", h.SwitchOrg)
+		app.Patch("/update-role", h.UpdateRole)
+		app.Delete("/remove-user", h.RemoveUser)
+		var reader *bytes.Reader
+		if body != nil {
+			b, _ := json.Marshal(body)
+			reader = bytes.NewReader(b)
+		} else {
+			reader = bytes.NewReader(nil)
+		}
+		req := httptest.NewRequest(method, path, reader)
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		var out map[string]interface{}
+		_ = json.NewDecoder(resp.Body).Decode(&out)
+		return resp.StatusCode, out
+	}
+	send := func(method, path string, actor uuid.UUID, role string, orgID uuid.UUID, body interface{}) (int, map[string]interface{}) {
+		return sendAs(map[string]interface{}{"user_id": actor.String(), "role": role, "org_id": orgID.String()}, method, path, body)
+	}
+	home := func() domain.User {
+		var u domain.User
+		require.NoError(t, db.Where("user_id = ?", consultant).First(&u).Error)
+		return u
+	}
+
+	status, out := send("GET", "/view-memberships", consultant, constants.Viewer, orgA, nil)
+	require.Equal(t, fiber.StatusOK, status, out)
+	list := out["data"].(map[string]interface{})["memberships"].([]interface{})
+	require.Len(t, list, 2)
+	roles := map[string]interface{}{}
+	for _, m := range list {
+		entry := m.(map[string]interface{})
+		roles[entry["org_name"].(string)] = entry["role"]
+		assert.Equal(t, entry["org_id"] == orgA.String(), entry["active"])
+	}
+	assert.Equal(t, map[string]interface{}{"Alpha": constants.Viewer, "Beta": constants.Manager}, roles)
+
+	// Switching moves only the session to the other org's role; the Users row keeps the home org, so the
+	// user's other sessions stay where they are
+	status, out = send("POST", "/switch-org", consultant, constants.Viewer, orgA, map[string]string{"org_id": orgB.String()})
+	require.Equal(t, fiber.StatusOK, status, out)
+	assert.Equal(t, constants.Manager, out["data"].(map[string]interface{})["user"].(map[string]interface{})["role"])
+	u := home()
+	assert.Equal(t, orgA, *u.OrgID)
+	assert.Equal(t, constants.Viewer, u.Role)
+	status, _ = send("POST", "/switch-org", consultant, constants.Manager, orgB, map[string]string{"org_id": uuid.New().String()})
+	assert.Equal(t, fiber.StatusForbidden, status)
+
+	// The session marks its own org active
+	status, out = send("GET", "/view-memberships", consultant, constants.Manager, orgB, nil)
+	require.Equal(t, fiber.StatusOK, status, out)
+	for _, m := range out["data"].(map[string]interface{})["memberships"].([]interface{}) {
+		entry := m.(map[string]interface{})
+		assert.Equal(t, entry["org_id"] == orgB.String(), entry["active"])
+	}
+
+	// A session signed in through Alpha's SSO stays in Alpha
+	ssoSession := map[string]interface{}{
+		"user_id": consultant.String(), "role": constants.Viewer, "org_id": orgA.String(),
+		"auth_method": middleware.AuthMethodSSO, "sso_org_id": orgA.String(),
+	}
+	status, out = sendAs(ssoSession, "POST", "/switch-org", map[string]string{"org_id": orgB.String()})
+	assert.Equal(t, fiber.StatusForbidden, status)
+	assert.Equal(t, middleware.ErrSSOSessionBound.Error(), out["error"].(map[string]interface{})["message"])
+	status, _ = sendAs(ssoSession, "POST", "/switch-org", map[string]string{"org_id": orgA.String()})
+	assert.Equal(t, fiber.StatusOK, status)
+
+	// Alpha governs only its own membership, even while the consultant works in Beta
+	status, out = send("PATCH", "/update-role", ownerA, constants.Superadmin, orgA, map[string]string{"user_id": consultant.String(), "role": constants.Admin})
+	require.Equal(t, fiber.StatusOK, status, out)
+	var m domain.OrgMembership
+	require.NoError(t, db.Where("user_id = ? AND org_id = ?", consultant, orgA).First(&m).Error)
+	assert.Equal(t, constants.Admin, m.Role)
+	var beta domain.OrgMembership
+	require.NoError(t, db.Where("user_id = ? AND org_id = ?", consultant, orgB).First(&beta).Error)
+	assert.Equal(t, constants.Manager, beta.Role)
+
+	// Leaving the home org moves it to the remaining membership
+	status, _ = send("DELETE", "/remove-user", ownerA, constants.Superadmin, orgA, map[string]string{"user_id": consultant.String()})
+	require.Equal(t, fiber.StatusOK, status)
+	u = home()
+	assert.Equal(t, orgB, *u.OrgID)
+	assert.Equal(t, constants.Manager, u.Role)
+
+	// Leaving the home org with no other membership leaves the user without an org
+	status, _ = send("DELETE", "/remove-user", ownerB, constants.Superadmin, orgB, map[string]string{"user_id": consultant.String()})
+	require.Equal(t, fiber.StatusOK, status)
+	u = home()
+	assert.Nil(t, u.OrgID)
+	assert.Equal(t, constants.Viewer, u.Role)
+	var count int64
+	db.Model(&domain.OrgMembership{}).Where("user_id = ?", consultant).Count(&count)
+	assert.Zero(t, count)
+}
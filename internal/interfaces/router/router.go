@@ -4,37 +4,58 @@ import (
 	"net/http"
 
 	"github.com/redis/go-redis/v9"
+	apikeysvc "troo-backend/internal/application/apikeys"
+	approvalsvc "troo-backend/internal/application/approvals"
+	auditsvc "troo-backend/internal/application/audit"
 	authsvc "troo-backend/internal/application/auth"
 	emailsvc "troo-backend/internal/application/emails"
 	holdsvc "troo-backend/internal/application/holdings"
 	invsvc "troo-backend/internal/application/invitations"
+	limitsvc "troo-backend/internal/application/limits"
 	lesvc "troo-backend/internal/application/listingevents"
 	listsvc "troo-backend/internal/application/listings"
 	mktsvc "troo-backend/internal/application/marketplace"
 	orgsvc "troo-backend/internal/application/org"
+	platformsvc "troo-backend/internal/application/platform"
+	profilesvc "troo-backend/internal/application/profiles"
 	retsvc "troo-backend/internal/application/retirements"
+	rolesvc "troo-backend/internal/application/roles"
+	scimsvc "troo-backend/internal/application/scim"
+	ssosvc "troo-backend/internal/application/sso"
+	subaccountsvc "troo-backend/internal/application/subaccounts"
 	tradesvc "troo-backend/internal/application/trading"
 	txsvc "troo-backend/internal/application/transactions"
 	uploadsvc "troo-backend/internal/application/uploads"
 	usersvc "troo-backend/internal/application/user"
+	verifysvc "troo-backend/internal/application/verification"
 	"troo-backend/internal/config"
 	"troo-backend/internal/infrastructure/database"
+	apikeyhandler "troo-backend/internal/interfaces/handlers/apikeys"
+	approvalhandler "troo-backend/internal/interfaces/handlers/approvals"
+	audithandler "troo-backend/internal/interfaces/handlers/audit"
 	authhandler "troo-backend/internal/interfaces/handlers/auth"
 	healthhandler "troo-backend/internal/interfaces/handlers/health"
 	holdhandler "troo-backend/internal/interfaces/handlers/holdings"
 	invhandler "troo-backend/internal/interfaces/handlers/invitations"
+	limithandler "troo-backend/internal/interfaces/handlers/limits"
 	lehandler "troo-backend/internal/interfaces/handlers/listingevents"
 	listhandler "troo-backend/internal/interfaces/handlers/listings"
 	mkthandler "troo-backend/internal/interfaces/handlers/marketplace"
 	orghandler "troo-backend/internal/interfaces/handlers/org"
 	payhandler "troo-backend/internal/interfaces/handlers/payments"
+	platformhandler "troo-backend/internal/interfaces/handlers/platform"
+	profilehandler "troo-backend/internal/interfaces/handlers/profiles"
 	rethandler "troo-backend/internal/interfaces/handlers/retirements"
+	rolehandler "troo-backend/internal/interfaces/handlers/roles"
+	scimhandler "troo-backend/internal/interfaces/handlers/scim"
+	subaccounthandler "troo-backend/internal/interfaces/handlers/subaccounts"
 	tradehandler "troo-backend/internal/interfaces/handlers/trading"
 	txhandler "troo-backend/internal/interfaces/handlers/transactions"
 	uploadhandler "troo-backend/internal/interfaces/handlers/uploads"
 	userhandler "troo-backend/internal/interfaces/handlers/user"
 	"troo-backend/internal/middleware"
 	"troo-backend/internal/pkg/constants"
+	"troo-backend/internal/pkg/secretbox"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/adaptor"
@@ -56,12 +77,22 @@ func (g *gormDBPinger) Ping() error {
 	return sqlDB.Ping()
 }
 
-func CreateApp(cfg *config.Config) (*fiber.App, *gorm.DB, *redis.Client, error) {
-	app := fiber.New(fiber.Config{
+// fiberConfig takes the client IP from X-Forwarded-For only when the request comes from one of
+// cfg.TrustedProxies; see middleware.ForwardedFor.
+func fiberConfig(cfg *config.Config) fiber.Config {
+	return fiber.Config{
 		DisableStartupMessage:   true,
 		ErrorHandler:            middleware.ErrorHandler,
 		EnableTrustedProxyCheck: true,
-	})
+		TrustedProxies:          cfg.TrustedProxies,
+		ProxyHeader:             fiber.HeaderXForwardedFor,
+		EnableIPValidation:      true,
+	}
+}
+
+func CreateApp(cfg *config.Config) (*fiber.App, *gorm.DB, *redis.Client, error) {
+	app := fiber.New(fiberConfig(cfg))
+	app.Use(middleware.ForwardedFor())
 
 	app.Use(middleware.CORS(middleware.CORSConfig{
 		AllowedSuffix: cfg.FrontendURLEndsWith,
@@ -73,17 +104,22 @@ func CreateApp(cfg *config.Config) (*fiber.App, *gorm.DB, *redis.Client, error)
 		return stripeWebhook.HandleWebhook(c)
 	})
 
-	sessionHandler, redisClient, err := middleware.Session(middleware.SessionConfig{
+	sessionCfg := middleware.SessionConfig{
 		Secret:            cfg.SessionSecret,
+		PreviousSecrets:   cfg.SessionPreviousSecrets,
 		RedisURL:          cfg.RedisURL,
 		AllowCrossSiteDev: cfg.AllowCrossSiteDev,
 		IsProduction:      cfg.Env == "production",
-	})
+		IdleTimeout:       cfg.SessionIdleTimeout,
+		MaxLifetime:       cfg.SessionMaxLifetime,
+	}
+	sessionHandler, redisClient, err := middleware.Session(sessionCfg)
 	if err != nil {
 		return nil, nil, nil, err
 	}
 	rdb := redisClient
 	app.Use(sessionHandler)
+	app.Use(middleware.ReadOnlyImpersonation())
 	app.Use(middleware.HealthMarker(rdb))
 	app.Use(middleware.ResponseFormatter())
 	app.Use(middleware.Tracing())
@@ -117,54 +153,145 @@ func CreateApp(cfg *config.Config) (*fiber.App, *gorm.DB, *redis.Client, error)
 		hh.DB = &gormDBPinger{db: db}
 	}
 
-	sessionCfg := middleware.SessionConfig{
-		Secret:            cfg.SessionSecret,
-		RedisURL:          cfg.RedisURL,
-		AllowCrossSiteDev: cfg.AllowCrossSiteDev,
-		IsProduction:      cfg.Env == "production",
+	// Transactional email via Brevo, same env as Express: SENDINBLUE_API_KEY, MAIL_FROM
+	var emailSender emailsvc.Sender
+	if cfg.SendinblueAPIKey != "" {
+		emailSender = &emailsvc.BrevoClient{APIKey: cfg.SendinblueAPIKey, MailFrom: cfg.MailFrom}
 	}
 
 	var userFinder authsvc.UserFinder
+	var accounts *authsvc.Service
+	// Audit log (append-only); nil without a database, which makes every Audit call a no-op.
+	var audits *auditsvc.Service
+	// OIDC single sign-on; the provider redirects to the frontend, which posts code and state to complete-sso
+	var sso *ssosvc.Service
 	if db != nil {
 		userFinder = &authsvc.GormUserFinder{DB: db}
+		audits = &auditsvc.Service{DB: db}
+		if rdb != nil {
+			accounts = &authsvc.Service{DB: db, Rdb: rdb, EmailSender: emailSender, AppBaseURL: cfg.InviteBaseURL}
+			if cfg.SecretsEncryptionKey != "" {
+				if accounts.Secrets, err = secretbox.New(cfg.SecretsEncryptionKey); err != nil {
+					return nil, nil, nil, err
+				}
+			}
+			sso = &ssosvc.Service{DB: db, Rdb: rdb, RedirectURL: cfg.InviteBaseURL + "/sso/callback"}
+		}
 	}
 	ah := &authhandler.Handlers{
 		UserFinder: userFinder,
+		Service:    accounts,
 		Rdb:        rdb,
 		Config:     sessionCfg,
+		Audit:      audits,
+		SSO:        sso,
 	}
 	authGroup := app.Group("/api/v1/auth")
 	authGroup.Post("/login", ah.Login)
 	authGroup.Get("/me", ah.Me)
 	authGroup.Delete("/logout", ah.Logout)
+	authGroup.Post("/forgot-password", ah.ForgotPassword)
+	authGroup.Post("/reset-password", ah.ResetPassword)
+	authGroup.Post("/start-sso", ah.StartSSO)
+	authGroup.Post("/complete-sso", ah.CompleteSSO)
+	// Two-factor: verify-2fa finishes a pending login; the rest manage the signed-in user's enrolment
+	authGroup.Post("/verify-2fa", ah.VerifyTwoFactor)
+	authGroup.Post("/setup-2fa", middleware.RequireAuth(), ah.SetupTwoFactor)
+	authGroup.Post("/enable-2fa", middleware.RequireAuth(), ah.EnableTwoFactor)
+	authGroup.Post("/disable-2fa", middleware.RequireAuth(), ah.DisableTwoFactor)
+	authGroup.Post("/regenerate-recovery-codes", middleware.RequireAuth(), ah.RegenerateRecoveryCodes)
+	// Active sessions of the signed-in user
+	authGroup.Get("/sessions", middleware.RequireAuth(), ah.ListSessions)
+	authGroup.Delete("/revoke-session", middleware.RequireAuth(), ah.RevokeSession)
+	authGroup.Delete("/revoke-other-sessions", middleware.RequireAuth(), ah.RevokeOtherSessions)
 
 	if db != nil {
 		stripeWebhook.DB = db
+		stripeWebhook.Audit = audits
+		stripeWebhook.Limits = &limitsvc.Service{DB: db}
 	}
 
 	if db != nil && rdb != nil {
-		// User (with optional welcome email via Brevo, same env as Express: SENDINBLUE_API_KEY, MAIL_FROM)
-		var emailSender emailsvc.Sender
-		if cfg.SendinblueAPIKey != "" {
-			emailSender = &emailsvc.BrevoClient{APIKey: cfg.SendinblueAPIKey, MailFrom: cfg.MailFrom}
-		}
-		us := &usersvc.Service{DB: db, Rdb: rdb, EmailSender: emailSender}
-		uh := &userhandler.Handlers{Service: us, Config: sessionCfg}
+		// User (with optional welcome and verification emails)
+		us := &usersvc.Service{DB: db, Rdb: rdb, EmailSender: emailSender, AppBaseURL: cfg.InviteBaseURL}
+		uh := &userhandler.Handlers{Service: us, Config: sessionCfg, Audit: audits}
 		// create-user is public (registration); same as Express
 		app.Post("/api/v1/users/create-user", uh.CreateUser)
+		// verify-email is public too: the emailed link may be opened signed out
+		app.Post("/api/v1/users/verify-email", uh.VerifyEmail)
 		ug := app.Group("/api/v1/users", middleware.RequireAuth())
 		ug.Put("/update-user", uh.UpdateUser)
 		ug.Get("/view-user", uh.ViewUser)
+		ug.Post("/resend-verification", uh.ResendVerification)
+		// Users may belong to several orgs; the session works in one at a time
+		ug.Get("/view-memberships", uh.ViewMemberships)
+		ug.Post("/switch-org", uh.SwitchOrg)
 		ug.Patch("/update-role", middleware.AuthorizePermission(constants.AssignRole), uh.UpdateRole)
 		ug.Delete("/remove-user", middleware.AuthorizePermission(constants.RemoveUser), uh.RemoveUser)
+		// Whoever may remove a member may also sign them out everywhere
+		ug.Delete("/force-logout", middleware.AuthorizePermission(constants.RemoveUser), uh.ForceLogout)
+
+		// Custom roles (org-defined permission sets; holders are checked by AuthorizePermission via their session)
+		rlh := &rolehandler.Handlers{Service: &rolesvc.Service{DB: db, Rdb: rdb}, Audit: audits}
+		rlg := app.Group("/api/v1/roles", middleware.RequireAuth())
+		rlg.Get("/view-permissions", middleware.AuthorizePermission(constants.ViewData), rlh.ViewPermissions)
+		rlg.Get("/view-roles", middleware.AuthorizePermission(constants.ViewData), rlh.ViewRoles)
+		rlg.Post("/create-role", middleware.AuthorizePermission(constants.ManageRoles), rlh.CreateRole)
+		rlg.Put("/update-role", middleware.AuthorizePermission(constants.ManageRoles), rlh.UpdateRole)
+		rlg.Delete("/delete-role", middleware.AuthorizePermission(constants.ManageRoles), rlh.DeleteRole)
+
+		// Org API keys for integrations; only the groups mounting keyAuth below accept them
+		aks := &apikeysvc.Service{DB: db}
+		keyAuth := middleware.APIKeyAuth(aks)
+		akh := &apikeyhandler.Handlers{Service: aks, Audit: audits}
+		akg := app.Group("/api/v1/api-keys", middleware.RequireAuth())
+		akg.Get("/view-keys", middleware.AuthorizePermission(constants.ManageAPIKeys), akh.ViewKeys)
+		akg.Post("/create-key", middleware.AuthorizePermission(constants.ManageAPIKeys), akh.CreateKey)
+		akg.Delete("/revoke-key", middleware.AuthorizePermission(constants.ManageAPIKeys), akh.RevokeKey)
 
 		// Org
-		os := &orgsvc.Service{DB: db}
-		oh := &orghandler.Handlers{Service: os, Config: sessionCfg}
+		os := &orgsvc.Service{DB: db, Rdb: rdb}
+		scims := &scimsvc.Service{DB: db, Rdb: rdb}
+		verifications := &verifysvc.Service{DB: db}
+		oh := &orghandler.Handlers{Service: os, Config: sessionCfg, Audit: audits, SSO: sso, SCIM: scims, Verification: verifications}
 		og := app.Group("/api/v1/orgs", middleware.RequireAuth())
 		og.Post("/create-org", oh.CreateOrg)
 		og.Get("/view-org", oh.ViewOrg)
 		og.Patch("/update-org", oh.UpdateOrg)
+		og.Get("/view-verification", middleware.AuthorizePermission(constants.ViewData), oh.ViewVerification)
+		og.Post("/submit-verification", middleware.AuthorizePermission(constants.UpdateOrg), oh.SubmitVerification)
+		og.Put("/update-security-policy", middleware.AuthorizePermission(constants.ManageSecurityPolicy), oh.UpdateSecurityPolicy)
+		og.Get("/view-sso-config", middleware.AuthorizePermission(constants.ManageSecurityPolicy), oh.ViewSSOConfig)
+		og.Put("/update-sso-config", middleware.AuthorizePermission(constants.ManageSecurityPolicy), oh.UpdateSSOConfig)
+		og.Post("/verify-sso-domain", middleware.AuthorizePermission(constants.ManageSecurityPolicy), oh.VerifySSODomain)
+		og.Get("/view-scim-token", middleware.AuthorizePermission(constants.ManageSecurityPolicy), oh.ViewSCIMToken)
+		og.Post("/rotate-scim-token", middleware.AuthorizePermission(constants.ManageSecurityPolicy), oh.RotateSCIMToken)
+		og.Delete("/revoke-scim-token", middleware.AuthorizePermission(constants.ManageSecurityPolicy), oh.RevokeSCIMToken)
+		og.Put("/update-public-profile", middleware.AuthorizePermission(constants.UpdateOrg), oh.UpdatePublicProfile)
+		og.Post("/close-org", middleware.AuthorizePermission(constants.CloseOrg), oh.CloseOrg)
+
+		// Public org profiles (no session), for buyers showcasing their retirements
+		pfh := &profilehandler.Handlers{Service: &profilesvc.Service{DB: db}}
+		pfg := app.Group("/api/v1/public")
+		pfg.Get("/view-org/:org_code", pfh.ViewOrg)
+		pfg.Get("/view-retirements/:org_code", pfh.ViewRetirements)
+
+		// SCIM 2.0 provisioning for the org's identity provider, authenticated by the org's SCIM token
+		sch := &scimhandler.Handlers{Service: scims, Audit: audits}
+		scg := app.Group("/api/v1/scim/v2", sch.Authenticate)
+		scg.Get("/ServiceProviderConfig", sch.ServiceProviderConfig)
+		scg.Get("/Users", sch.ListUsers)
+		scg.Post("/Users", sch.CreateUser)
+		scg.Get("/Users/:id", sch.GetUser)
+		scg.Put("/Users/:id", sch.ReplaceUser)
+		scg.Patch("/Users/:id", sch.PatchUser)
+		scg.Delete("/Users/:id", sch.DeleteUser)
+		scg.Get("/Groups", sch.ListGroups)
+		scg.Post("/Groups", sch.CreateGroup)
+		scg.Get("/Groups/:id", sch.GetGroup)
+		scg.Put("/Groups/:id", sch.ReplaceGroup)
+		scg.Patch("/Groups/:id", sch.PatchGroup)
+		scg.Delete("/Groups/:id", sch.DeleteGroup)
 
 		// Uploads — sign URL uses SUPABASE_URL (e.g. https://xwsiuytkbefejvoqpjyg.supabase.co/storage/v1/...)
 		sc := &uploadsvc.HTTPClient{BaseURL: cfg.SupabaseURL, SecretKey: cfg.SupabaseSecretKey}
@@ -177,23 +304,24 @@ func CreateApp(cfg *config.Config) (*fiber.App, *gorm.DB, *redis.Client, error)
 		// Holdings
 		hs := &holdsvc.Service{DB: db}
 		holdh := &holdhandler.Handlers{Service: hs}
-		hg := app.Group("/api/v1/holdings", middleware.RequireAuth())
+		hg := app.Group("/api/v1/holdings", keyAuth, middleware.RequireAuth())
 		hg.Get("/view-holdings", holdh.ViewHoldings)
 		hg.Post("/view-project", holdh.ViewProject)
 
 		// Marketplace
 		ms := &mktsvc.Service{DB: db, ICR: nil}
 		mh := &mkthandler.Handlers{Service: ms}
-		mg := app.Group("/api/v1/marketplace", middleware.RequireAuth())
+		mg := app.Group("/api/v1/marketplace", keyAuth, middleware.RequireAuth())
 		mg.Get("/projects", mh.GetAllProjects)
 		mg.Get("/projects/:id", mh.GetProjectByID)
-		mg.Post("/admin-sync", mh.AdminSync)
+		// Registry sync is a platform action; kept at its old path for existing clients, operator-only like /platform.
+		mg.Post("/admin-sync", middleware.AuthorizePlatformPermission(constants.SyncRegistry), mh.AdminSync)
 
 		// Listings
 		ls := &listsvc.Service{DB: db}
-		lh := &listhandler.Handlers{Service: ls}
-		lg := app.Group("/api/v1/listings", middleware.RequireAuth())
-		lg.Post("/create-listing", lh.CreateListing)
+		lh := &listhandler.Handlers{Service: ls, Audit: audits}
+		lg := app.Group("/api/v1/listings", middleware.RequireAuth(), middleware.Idempotency(rdb))
+		lg.Post("/create-listing", middleware.AuthorizePlatformPermission(constants.CreateRegistryListing), lh.CreateListing)
 		lg.Get("/get-all-listings", lh.GetAllListings)
 		lg.Get("/get-org-listings", lh.GetOrgListings)
 		lg.Get("/get-listing/:listing_id", lh.GetListingByID)
@@ -204,9 +332,39 @@ func CreateApp(cfg *config.Config) (*fiber.App, *gorm.DB, *redis.Client, error)
 		lg.Put("/edit-listing", lh.EditListing)
 		lg.Post("/cancel-listing", lh.CancelListing)
 
+		// Platform back-office (operators only; org roles including superadmin never pass)
+		plh := &platformhandler.Handlers{
+			Service:      &platformsvc.Service{DB: db, Rdb: rdb},
+			Holdings:     hs,
+			Transactions: &txsvc.Service{DB: db},
+			Listings:     ls,
+			Audit:        audits,
+			Verification: verifications,
+		}
+		pg := app.Group("/api/v1/platform", middleware.RequireAuth(), middleware.Idempotency(rdb))
+		pg.Post("/marketplace/admin-sync", middleware.AuthorizePlatformPermission(constants.SyncRegistry), mh.AdminSync)
+		pg.Post("/listings/create-listing", middleware.AuthorizePlatformPermission(constants.CreateRegistryListing), lh.CreateListing)
+		pg.Get("/view-operators", middleware.AuthorizePlatformPermission(constants.ManageOperators), plh.ViewOperators)
+		pg.Post("/grant-operator", middleware.AuthorizePlatformPermission(constants.ManageOperators), plh.GrantOperator)
+		pg.Delete("/revoke-operator", middleware.AuthorizePlatformPermission(constants.ManageOperators), plh.RevokeOperator)
+		pg.Get("/search-users", middleware.AuthorizePlatformPermission(constants.ViewPlatformData), plh.SearchUsers)
+		pg.Get("/search-orgs", middleware.AuthorizePlatformPermission(constants.ViewPlatformData), plh.SearchOrgs)
+		pg.Get("/view-org-holdings/:org_id", middleware.AuthorizePlatformPermission(constants.ViewPlatformData), plh.ViewOrgHoldings)
+		pg.Get("/view-org-transactions/:org_id", middleware.AuthorizePlatformPermission(constants.ViewPlatformData), plh.ViewOrgTransactions)
+		pg.Get("/view-org-listings/:org_id", middleware.AuthorizePlatformPermission(constants.ViewPlatformData), plh.ViewOrgListings)
+		pg.Get("/view-org-adjustments/:org_id", middleware.AuthorizePlatformPermission(constants.ViewPlatformData), plh.ViewOrgAdjustments)
+		pg.Post("/force-close-listing", middleware.AuthorizePlatformPermission(constants.ForceCloseListings), plh.ForceCloseListing)
+		pg.Post("/adjust-holding", middleware.AuthorizePlatformPermission(constants.AdjustHoldings), plh.AdjustHolding)
+		pg.Post("/impersonate", middleware.AuthorizePlatformPermission(constants.ViewPlatformData), plh.StartImpersonation)
+		pg.Delete("/impersonate", middleware.AuthorizePlatformPermission(constants.ViewPlatformData), plh.StopImpersonation)
+		pg.Get("/view-verifications", middleware.AuthorizePlatformPermission(constants.ViewPlatformData), plh.ViewVerifications)
+		pg.Post("/start-verification-review", middleware.AuthorizePlatformPermission(constants.ReviewVerifications), plh.StartVerificationReview)
+		pg.Post("/approve-verification", middleware.AuthorizePlatformPermission(constants.ReviewVerifications), plh.ApproveVerification)
+		pg.Post("/reject-verification", middleware.AuthorizePlatformPermission(constants.ReviewVerifications), plh.RejectVerification)
+
 		// Invitations
 		is := &invsvc.Service{DB: db, EmailSender: emailSender, InviteBaseURL: cfg.InviteBaseURL}
-		ih := &invhandler.Handlers{Service: is, Config: sessionCfg}
+		ih := &invhandler.Handlers{Service: is, Config: sessionCfg, Audit: audits}
 		app.Post("/api/v1/invitations/public/check-token", ih.CheckToken)
 		ig := app.Group("/api/v1/invitations", middleware.RequireAuth())
 		ig.Post("/create-invite", middleware.AuthorizePermission(constants.InviteUser), ih.SendInvite)
@@ -215,35 +373,73 @@ func CreateApp(cfg *config.Config) (*fiber.App, *gorm.DB, *redis.Client, error)
 		ig.Get("/view-invites", middleware.AuthorizePermission(constants.ViewData), ih.ListOrgInvitations)
 		ig.Post("/resend-invite", middleware.AuthorizePermission(constants.InviteUser), ih.ResendInvite)
 
-		// Trading
+		// Trading (transfers/retirements above the org's approval policy are held for a second admin)
 		ts := &tradesvc.Service{DB: db}
+		aps := &approvalsvc.Service{DB: db}
+		lms := &limitsvc.Service{DB: db}
 		th := &tradehandler.Handlers{
 			Service:       ts,
 			StripeCreator: &tradehandler.RealStripeCreator{SecretKey: cfg.StripeSecretKey},
+			Approvals:     aps,
+			Limits:        lms,
+			Audit:         audits,
 		}
-		tg := app.Group("/api/v1/trading", middleware.RequireAuth())
+		tg := app.Group("/api/v1/trading", keyAuth, middleware.RequireAuth(), middleware.Idempotency(rdb))
 		tg.Post("/buy-credits", middleware.AuthorizePermission(constants.BuyCredits), th.BuyCredits)
 		tg.Post("/sell-credits", middleware.AuthorizePermission(constants.SellCredits), th.SellCredits)
 		tg.Post("/retire-credits", middleware.AuthorizePermission(constants.RetireCredits), th.RetireCredits)
 		tg.Post("/transfer-credits", middleware.AuthorizePermission(constants.TransferCredits), th.TransferCredits)
 
+		// Sub-accounts (credits attributed to business units, clients or funds within the org)
+		sah := &subaccounthandler.Handlers{Service: &subaccountsvc.Service{DB: db}, Audit: audits}
+		sag := app.Group("/api/v1/sub-accounts", middleware.RequireAuth(), middleware.Idempotency(rdb))
+		sag.Get("/view-sub-accounts", middleware.AuthorizePermission(constants.ViewData), sah.ViewSubAccounts)
+		sag.Post("/create-sub-account", middleware.AuthorizePermission(constants.UpdateOrg), sah.CreateSubAccount)
+		sag.Patch("/update-sub-account", middleware.AuthorizePermission(constants.UpdateOrg), sah.UpdateSubAccount)
+		sag.Delete("/delete-sub-account", middleware.AuthorizePermission(constants.UpdateOrg), sah.DeleteSubAccount)
+		sag.Post("/transfer-credits", middleware.AuthorizePermission(constants.TransferCredits), sah.TransferCredits)
+		sag.Get("/view-transactions", middleware.AuthorizePermission(constants.ViewData), sah.ViewTransactions)
+
+		// Approvals (four-eyes control for transfers and retirements)
+		aph := &approvalhandler.Handlers{Service: aps, Audit: audits}
+		apg := app.Group("/api/v1/approvals", middleware.RequireAuth(), middleware.Idempotency(rdb))
+		apg.Get("/view-policy", middleware.AuthorizePermission(constants.ViewData), aph.ViewPolicy)
+		apg.Put("/update-policy", middleware.AuthorizePermission(constants.ManageApprovalPolicy), aph.UpdatePolicy)
+		apg.Get("/view-requests", middleware.AuthorizePermission(constants.ViewData), aph.ViewRequests)
+		apg.Post("/approve-request", middleware.AuthorizePermission(constants.ApproveCredits), aph.ApproveRequest)
+		apg.Post("/reject-request", middleware.AuthorizePermission(constants.ApproveCredits), aph.RejectRequest)
+
+		// Audit log (per-org view and CSV export of recorded security and business actions)
+		adh := &audithandler.Handlers{Service: audits}
+		adg := app.Group("/api/v1/audit", middleware.RequireAuth())
+		adg.Get("/view-logs", middleware.AuthorizePermission(constants.ViewAuditLog), adh.ViewLogs)
+		adg.Get("/export-logs", middleware.AuthorizePermission(constants.ViewAuditLog), adh.ExportLogs)
+
+		// Trading limits (per-user / per-role caps enforced by the trading handlers)
+		lmh := &limithandler.Handlers{Service: lms}
+		lmg := app.Group("/api/v1/trading-limits", middleware.RequireAuth())
+		lmg.Get("/view-limits", middleware.AuthorizePermission(constants.ManageTradingLimits), lmh.ViewLimits)
+		lmg.Get("/view-my-limit", middleware.AuthorizePermission(constants.ViewData), lmh.ViewMyLimit)
+		lmg.Put("/set-limit", middleware.AuthorizePermission(constants.ManageTradingLimits), lmh.SetLimit)
+		lmg.Delete("/remove-limit", middleware.AuthorizePermission(constants.ManageTradingLimits), lmh.RemoveLimit)
+
 		// Retirements
 		rs := &retsvc.Service{DB: db}
 		rh := &rethandler.Handlers{Service: rs}
-		rg := app.Group("/api/v1/retirements", middleware.RequireAuth())
+		rg := app.Group("/api/v1/retirements", keyAuth, middleware.RequireAuth())
 		rg.Get("/view-org", rh.ViewOrg)
 		rg.Post("/view-one", rh.ViewOne)
 
 		// Transactions
 		txs := &txsvc.Service{DB: db}
 		txh := &txhandler.Handlers{Service: txs}
-		txg := app.Group("/api/v1/transactions", middleware.RequireAuth())
+		txg := app.Group("/api/v1/transactions", keyAuth, middleware.RequireAuth())
 		txg.Get("/get-transactions", txh.GetTransactions)
 
 		// ListingEvents
 		les := &lesvc.Service{DB: db}
 		leh := &lehandler.Handlers{Service: les}
-		leg := app.Group("/api/v1/listing-events", middleware.RequireAuth())
+		leg := app.Group("/api/v1/listing-events", keyAuth, middleware.RequireAuth())
 		leg.Get("/get-org-listing-events", leh.GetOrgListingEvents)
 	}
 
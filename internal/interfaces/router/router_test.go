@@ -0,0 +1,42 @@
+package router
+
+import (
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"troo-backend/internal/config"
+	"troo-backend/internal/middleware"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// app.Test connects from 0.0.0.0, so trusting that address stands in for the load balancer.
+func clientIP(t *testing.T, trusted []string, forwardedFor string) string {
+	t.Helper()
+	app := fiber.New(fiberConfig(&config.Config{TrustedProxies: trusted}))
+	app.Use(middleware.ForwardedFor())
+	app.Get("/ip", func(c *fiber.Ctx) error { return c.SendString(c.IP()) })
+
+	req := httptest.NewRequest("GET", "/ip", nil)
+	if forwardedFor != "" {
+		req.Header.Set(fiber.HeaderXForwardedFor, forwardedFor)
+	}
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	return string(body)
+}
+
+func TestClientIP_FromForwardedHeader(t *testing.T) {
+	proxy := []string{"0.0.0.0"}
+
+	assert.Equal(t, "203.0.113.7", clientIP(t, proxy, "203.0.113.7"))
+	// A client-supplied X-Forwarded-For is ignored; only the hop our proxy appended counts.
+	assert.Equal(t, "203.0.113.7", clientIP(t, proxy, "198.51.100.1, 203.0.113.7"))
+	assert.Equal(t, "0.0.0.0", clientIP(t, proxy, ""))
+	// From an untrusted address the header is not believed at all.
+	assert.Equal(t, "0.0.0.0", clientIP(t, []string{"10.0.0.1"}, "203.0.113.7"))
+}
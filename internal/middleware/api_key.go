@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"context"
+	"strings"
+
+	"troo-backend/internal/domain"
+	"troo-backend/internal/pkg/constants"
+	"troo-backend/internal/pkg/response"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// APIKeyAuthenticator resolves bearer tokens (the application apikeys service). Unusable keys are reported as
+// domain.ErrInvalidAPIKey.
+type APIKeyAuthenticator interface {
+	Authenticate(ctx context.Context, token string) (*domain.APIKeyIdentity, error)
+}
+
+// APIKeyAuth accepts "Authorization: Bearer <org API key>" in place of a session. The key becomes the request
+// user: its creator with role viewer and the key's permissions, which AuthorizePermission checks like a custom
+// role's. Requests without a bearer token pass through to the session. Mount it only on groups meant for
+// integrations, before RequireAuth; the session is neither read nor saved for a key.
+func APIKeyAuth(keys APIKeyAuthenticator) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		header := c.Get(fiber.HeaderAuthorization)
+		if len(header) < 7 || !strings.EqualFold(header[:7], "Bearer ") {
+			return c.Next()
+		}
+		id, err := keys.Authenticate(c.Context(), header[7:])
+		if err == domain.ErrInvalidAPIKey {
+			return response.Unauthorized(c, err.Error())
+		}
+		if err != nil {
+			return response.Error(c, "Internal Server Error", 500, nil)
+		}
+		c.Locals("session_id", "")
+		c.Locals("session_data", make(map[string]interface{}))
+		c.Locals(userLocal, map[string]interface{}{
+			"user_id":        id.User.UserID.String(),
+			"fullname":       id.User.Fullname,
+			"email":          id.User.Email,
+			"role":           constants.Viewer,
+			"org_id":         id.Key.OrgID.String(),
+			"email_verified": id.User.EmailVerified,
+			"api_key_id":     id.Key.KeyID.String(),
+			"permissions":    id.Key.PermissionList(),
+		})
+		return c.Next()
+	}
+}
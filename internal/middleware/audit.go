@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"context"
+
+	"troo-backend/internal/domain"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+// AuditRecorder persists audit entries (the application audit service). Implementations must accept a nil
+// receiver as a no-op, since handlers pass their optional *audit.Service straight through.
+type AuditRecorder interface {
+	Record(ctx context.Context, e domain.AuditEntry) error
+}
+
+// Audit records e for the current request. Actor and org default to the session user, and IP and trace id
+// (from Tracing) are always taken from the request. The audited action has already happened, so a failed
+// write is logged rather than turned into an error response. A nil recorder is a no-op.
+func Audit(c *fiber.Ctx, rec AuditRecorder, e domain.AuditEntry) {
+	if e.OrgID == nil {
+		if m, ok := GetUser(c).(map[string]interface{}); ok {
+			e.OrgID = sessionUUID(m["org_id"])
+		}
+	}
+	AuditPlatform(c, rec, e)
+}
+
+// AuditPlatform is Audit for platform operator actions: e.OrgID is the org acted upon and never defaults
+// to the operator's own org (nil for registry listings without a seller).
+func AuditPlatform(c *fiber.Ctx, rec AuditRecorder, e domain.AuditEntry) {
+	if rec == nil {
+		return
+	}
+	if m, ok := GetUser(c).(map[string]interface{}); ok && e.ActorID == nil {
+		e.ActorID = sessionUUID(m["user_id"])
+	}
+	e.IP = c.IP()
+	e.TraceID = GetTraceID(c)
+	if err := rec.Record(c.Context(), e); err != nil {
+		log.Warn().Err(err).Str("action", e.Action).Str("trace_id", e.TraceID).Msg("audit log write failed")
+	}
+}
+
+// sessionUUID parses a session value that is a string after a Redis round-trip, or a *string within the
+// request that set it (SetSessionUser).
+func sessionUUID(v interface{}) *uuid.UUID {
+	var s string
+	switch t := v.(type) {
+	case string:
+		s = t
+	case *string:
+		if t != nil {
+			s = *t
+		}
+	}
+	id, err := uuid.Parse(s)
+	if err != nil {
+		return nil
+	}
+	return &id
+}
@@ -4,6 +4,7 @@ import (
 	"troo-backend/internal/pkg/response"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
 )
 
 const userLocal = "user"
@@ -25,3 +26,21 @@ func RequireAuth() fiber.Handler {
 func GetUser(c *fiber.Ctx) interface{} {
 	return c.Locals(userLocal)
 }
+
+// SessionActor returns the session user's id and org id; ok is false when either is missing.
+func SessionActor(c *fiber.Ctx) (userID, orgID uuid.UUID, ok bool) {
+	m, isMap := GetUser(c).(map[string]interface{})
+	if !isMap {
+		return uuid.Nil, uuid.Nil, false
+	}
+	userStr, _ := m["user_id"].(string)
+	orgStr, _ := m["org_id"].(string)
+	var err error
+	if userID, err = uuid.Parse(userStr); err != nil {
+		return uuid.Nil, uuid.Nil, false
+	}
+	if orgID, err = uuid.Parse(orgStr); err != nil {
+		return uuid.Nil, uuid.Nil, false
+	}
+	return userID, orgID, true
+}
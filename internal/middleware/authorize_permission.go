@@ -8,6 +8,10 @@ import (
 )
 
 // AuthorizePermission returns a handler that checks the session user's role against PERMISSION_ROLES (Express parity).
+// Users holding a custom role are checked against the role's permission set stored in their session instead,
+// and so are org API keys (APIKeyAuth).
+// Trading permissions (constants.IsTradingPermission) additionally require a verified email and, when the org
+// requires it, a session that passed two-factor authentication.
 // Unconfigured permission -> 500 "Permission configuration error"; role not allowed -> 403 "User is Forbidden from performing this action".
 func AuthorizePermission(permission string) fiber.Handler {
 	return func(c *fiber.Ctx) error {
@@ -23,9 +27,21 @@ func AuthorizePermission(permission string) fiber.Handler {
 		if !ok || len(roles) == 0 {
 			return response.Error(c, "Permission configuration error", 500, nil)
 		}
-		if !constants.AllowedRole(permission, role) {
+		if perms, custom := getPermissionsFromUser(user); custom {
+			if !hasPermission(perms, permission) {
+				return response.Error(c, "User is Forbidden from performing this action", 403, nil)
+			}
+		} else if !constants.AllowedRole(permission, role) {
 			return response.Error(c, "User is Forbidden from performing this action", 403, nil)
 		}
+		if constants.IsTradingPermission(permission) {
+			if !EmailVerified(user) {
+				return response.Error(c, "Email address must be verified to perform this action", 403, nil)
+			}
+			if m, _ := user.(map[string]interface{}); m["two_factor_required"] == true && m["two_factor"] != true {
+				return response.Error(c, "Your organization requires two-factor authentication for this action", 403, nil)
+			}
+		}
 		return c.Next()
 	}
 }
@@ -38,3 +54,48 @@ func getRoleFromUser(user interface{}) string {
 	r, _ := m["role"].(string)
 	return r
 }
+
+// getPermissionsFromUser returns the custom role or API key permission set; custom is false for built-in roles.
+// The list is []string when set in this request and []interface{} once read back from Redis.
+func getPermissionsFromUser(user interface{}) (perms []string, custom bool) {
+	m, ok := user.(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	roleID, _ := m["custom_role_id"].(string)
+	keyID, _ := m["api_key_id"].(string)
+	if roleID == "" && keyID == "" {
+		return nil, false
+	}
+	switch v := m["permissions"].(type) {
+	case []string:
+		return v, true
+	case []interface{}:
+		for _, p := range v {
+			if s, ok := p.(string); ok {
+				perms = append(perms, s)
+			}
+		}
+	}
+	return perms, true
+}
+
+func hasPermission(perms []string, permission string) bool {
+	for _, p := range perms {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// EmailVerified reports whether the session user has verified their email. SetSessionUser always stores the flag;
+// sessions created before it existed lack it and belong to accounts that were grandfathered as verified.
+func EmailVerified(user interface{}) bool {
+	m, ok := user.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	v, ok := m["email_verified"].(bool)
+	return !ok || v
+}
@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"troo-backend/internal/pkg/constants"
+	"troo-backend/internal/pkg/response"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// AuthorizePlatformPermission returns a handler that checks the session user's platform role against PlatformPermissionRoles.
+// Org roles (including superadmin) never pass: no platform role -> 403 "Platform operator access required".
+func AuthorizePlatformPermission(permission string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user := GetUser(c)
+		if user == nil {
+			return response.Unauthorized(c, "Unauthorized")
+		}
+		role := GetPlatformRole(c)
+		if role == "" {
+			return response.Error(c, "Platform operator access required", 403, nil)
+		}
+		if roles, ok := constants.PlatformPermissionRoles[permission]; !ok || len(roles) == 0 {
+			return response.Error(c, "Permission configuration error", 500, nil)
+		}
+		if !constants.AllowedPlatformRole(permission, role) {
+			return response.Error(c, "User is Forbidden from performing this action", 403, nil)
+		}
+		return c.Next()
+	}
+}
+
+// GetPlatformRole returns the session user's platform role ("" for non-operators).
+func GetPlatformRole(c *fiber.Ctx) string {
+	m, ok := GetUser(c).(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	r, _ := m["platform_role"].(string)
+	return r
+}
@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ForwardedFor trusts one proxy hop, like Express's `trust proxy 1`. When the request comes from a trusted
+// proxy, X-Forwarded-For is cut down to the address that proxy appended, so c.IP() cannot be spoofed by a
+// client sending its own X-Forwarded-For. Requests from anywhere else keep the socket address.
+func ForwardedFor() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !c.IsProxyTrusted() {
+			c.Request().Header.Del(fiber.HeaderXForwardedFor)
+			return c.Next()
+		}
+		if v := c.Get(fiber.HeaderXForwardedFor); v != "" {
+			last := strings.TrimSpace(v[strings.LastIndex(v, ",")+1:])
+			c.Request().Header.Set(fiber.HeaderXForwardedFor, last)
+		}
+		return c.Next()
+	}
+}
@@ -0,0 +1,146 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"troo-backend/internal/pkg/response"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	IdempotencyKeyHeader      = "Idempotency-Key"
+	IdempotentReplayedHeader  = "Idempotent-Replayed"
+	idempotencyPrefix         = "idempotency:"
+	idempotencyTTL            = 24 * time.Hour
+	idempotencyInFlightTTL    = time.Minute
+	idempotencyMaxKeyLength   = 255
+	idempotencyStateInFlight  = "in_flight"
+	idempotencyStateCompleted = "completed"
+)
+
+// idempotencyRecord is what Redis holds per actor and key: an in-flight marker while the first request runs,
+// then the first response, replayed verbatim on retries.
+type idempotencyRecord struct {
+	State       string `json:"state"`
+	Fingerprint string `json:"fingerprint"`
+	Status      int    `json:"status,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	Body        []byte `json:"body,omitempty"`
+}
+
+// Idempotency replays the first response of a mutating request when the client retries it with the same
+// Idempotency-Key header. Keys are scoped to the session user, the org they act in and the API key, if any, so
+// two actors, one user in two orgs, or a key and its creator's session can never see each other's responses.
+// Requests without the header, non-mutating methods and unauthenticated requests pass straight through.
+// 5xx responses and handler errors release the key so the retry runs again.
+func Idempotency(rdb *redis.Client) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		key := c.Get(IdempotencyKeyHeader)
+		if key == "" || rdb == nil || !isMutatingMethod(c.Method()) {
+			return c.Next()
+		}
+		if len(key) > idempotencyMaxKeyLength {
+			return response.Error(c, "Idempotency-Key must be at most 255 characters", 400, nil)
+		}
+		actor := idempotencyActor(c)
+		if actor == "" {
+			return c.Next()
+		}
+
+		ctx := context.Background()
+		redisKey := idempotencyPrefix + actor + ":" + key
+		fingerprint := requestFingerprint(c)
+
+		claim, _ := json.Marshal(idempotencyRecord{State: idempotencyStateInFlight, Fingerprint: fingerprint})
+		claimed, err := rdb.SetNX(ctx, redisKey, claim, idempotencyInFlightTTL).Result()
+		if err != nil {
+			// Redis down: serve the request rather than block trading.
+			log.Warn().Err(err).Msg("Idempotency key claim failed; processing without idempotency")
+			return c.Next()
+		}
+
+		if !claimed {
+			var rec idempotencyRecord
+			b, err := rdb.Get(ctx, redisKey).Bytes()
+			if err != nil || json.Unmarshal(b, &rec) != nil {
+				return response.Error(c, "A request with this Idempotency-Key is already in progress", 409, nil)
+			}
+			if rec.Fingerprint != fingerprint {
+				return response.Error(c, "Idempotency-Key has already been used for a different request", 422, nil)
+			}
+			if rec.State != idempotencyStateCompleted {
+				return response.Error(c, "A request with this Idempotency-Key is already in progress", 409, nil)
+			}
+			c.Set(IdempotentReplayedHeader, "true")
+			if rec.ContentType != "" {
+				c.Set(fiber.HeaderContentType, rec.ContentType)
+			}
+			return c.Status(rec.Status).Send(rec.Body)
+		}
+
+		if err := c.Next(); err != nil {
+			rdb.Del(ctx, redisKey)
+			return err
+		}
+
+		status := c.Response().StatusCode()
+		if status >= 500 {
+			rdb.Del(ctx, redisKey)
+			return nil
+		}
+		done, _ := json.Marshal(idempotencyRecord{
+			State:       idempotencyStateCompleted,
+			Fingerprint: fingerprint,
+			Status:      status,
+			ContentType: string(c.Response().Header.ContentType()),
+			Body:        c.Response().Body(),
+		})
+		if err := rdb.Set(ctx, redisKey, done, idempotencyTTL).Err(); err != nil {
+			log.Warn().Err(err).Msg("Idempotency response store failed")
+		}
+		return nil
+	}
+}
+
+func isMutatingMethod(method string) bool {
+	switch method {
+	case fiber.MethodPost, fiber.MethodPut, fiber.MethodPatch, fiber.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// idempotencyActor is the scope of a key: user, active org and API key (empty for sessions), or "" when no one is
+// signed in.
+func idempotencyActor(c *fiber.Ctx) string {
+	m, ok := GetUser(c).(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	userID, _ := m["user_id"].(string)
+	if userID == "" {
+		return ""
+	}
+	orgID, _ := m["org_id"].(string)
+	keyID, _ := m["api_key_id"].(string)
+	return userID + ":" + orgID + ":" + keyID
+}
+
+// requestFingerprint ties a key to one method, path and body, so reusing a key for a different request is rejected
+// instead of replaying an unrelated response.
+func requestFingerprint(c *fiber.Ctx) string {
+	h := sha256.New()
+	h.Write([]byte(c.Method()))
+	h.Write([]byte{0})
+	h.Write([]byte(c.Path()))
+	h.Write([]byte{0})
+	h.Write(c.Body())
+	return hex.EncodeToString(h.Sum(nil))
+}
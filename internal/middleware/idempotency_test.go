@@ -0,0 +1,141 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"troo-backend/internal/pkg/response"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gofiber/fiber/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupIdempotencyApp mounts a counting handler behind Idempotency; the session user comes from the X-Test-User
+// header, their active org from X-Test-Org and an API key from X-Test-Key.
+func setupIdempotencyApp(t *testing.T, handler fiber.Handler) *fiber.App {
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() {
+		rdb.Close()
+		mr.Close()
+	})
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		if id := c.Get("X-Test-User"); id != "" {
+			user := map[string]interface{}{"user_id": id, "role": "admin", "org_id": c.Get("X-Test-Org")}
+			if key := c.Get("X-Test-Key"); key != "" {
+				user["api_key_id"] = key
+			}
+			c.Locals("user", user)
+		}
+		return c.Next()
+	})
+	app.Post("/sell", Idempotency(rdb), handler)
+	return app
+}
+
+func idempotentPost(t *testing.T, app *fiber.App, user, key, body string, headers ...string) (*http.Response, string) {
+	req := httptest.NewRequest("POST", "/sell", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Test-User", user)
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	if key != "" {
+		req.Header.Set(IdempotencyKeyHeader, key)
+	}
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	b, _ := io.ReadAll(resp.Body)
+	return resp, string(b)
+}
+
+func TestIdempotency_ReplaysFirstResponse(t *testing.T) {
+	calls := 0
+	app := setupIdempotencyApp(t, func(c *fiber.Ctx) error {
+		calls++
+		return response.Success(c, "Listing created/updated successfully", fiber.Map{"call": calls}, nil)
+	})
+
+	first, firstBody := idempotentPost(t, app, "u1", "key-1", `{"amount":10}`)
+	second, secondBody := idempotentPost(t, app, "u1", "key-1", `{"amount":10}`)
+
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, 200, second.StatusCode)
+	assert.Equal(t, firstBody, secondBody)
+	assert.Empty(t, first.Header.Get(IdempotentReplayedHeader))
+	assert.Equal(t, "true", second.Header.Get(IdempotentReplayedHeader))
+}
+
+func TestIdempotency_ScopedPerActorAndOptional(t *testing.T) {
+	calls := 0
+	app := setupIdempotencyApp(t, func(c *fiber.Ctx) error {
+		calls++
+		return response.Success(c, "ok", nil, nil)
+	})
+
+	idempotentPost(t, app, "u1", "shared", `{}`)
+	idempotentPost(t, app, "u2", "shared", `{}`)
+	idempotentPost(t, app, "u1", "", `{}`)
+	idempotentPost(t, app, "u1", "", `{}`)
+
+	assert.Equal(t, 4, calls)
+}
+
+func TestIdempotency_ScopedPerOrgAndAPIKey(t *testing.T) {
+	calls := 0
+	app := setupIdempotencyApp(t, func(c *fiber.Ctx) error {
+		calls++
+		return response.Success(c, "ok", nil, nil)
+	})
+
+	idempotentPost(t, app, "u1", "shared", `{}`, "X-Test-Org", "org-a")
+	// The same user after switching to org B, then through an API key of org A.
+	resp, _ := idempotentPost(t, app, "u1", "shared", `{}`, "X-Test-Org", "org-b")
+	assert.Empty(t, resp.Header.Get(IdempotentReplayedHeader))
+	resp, _ = idempotentPost(t, app, "u1", "shared", `{}`, "X-Test-Org", "org-a", "X-Test-Key", "key-1")
+	assert.Empty(t, resp.Header.Get(IdempotentReplayedHeader))
+	assert.Equal(t, 3, calls)
+
+	// Back in org A the first response is replayed.
+	resp, _ = idempotentPost(t, app, "u1", "shared", `{}`, "X-Test-Org", "org-a")
+	assert.Equal(t, "true", resp.Header.Get(IdempotentReplayedHeader))
+	assert.Equal(t, 3, calls)
+}
+
+func TestIdempotency_KeyReusedForDifferentBody(t *testing.T) {
+	app := setupIdempotencyApp(t, func(c *fiber.Ctx) error {
+		return response.Success(c, "ok", nil, nil)
+	})
+
+	idempotentPost(t, app, "u1", "key-1", `{"amount":10}`)
+	resp, body := idempotentPost(t, app, "u1", "key-1", `{"amount":20}`)
+
+	assert.Equal(t, 422, resp.StatusCode)
+	assert.Contains(t, body, "Idempotency-Key has already been used for a different request")
+}
+
+func TestIdempotency_ServerErrorReleasesKey(t *testing.T) {
+	calls := 0
+	app := setupIdempotencyApp(t, func(c *fiber.Ctx) error {
+		calls++
+		if calls == 1 {
+			return response.Error(c, "Internal Server Error", 500, nil)
+		}
+		return response.Success(c, "ok", nil, nil)
+	})
+
+	first, _ := idempotentPost(t, app, "u1", "key-1", `{}`)
+	second, _ := idempotentPost(t, app, "u1", "key-1", `{}`)
+
+	assert.Equal(t, 500, first.StatusCode)
+	assert.Equal(t, 200, second.StatusCode)
+	assert.Equal(t, 2, calls)
+}
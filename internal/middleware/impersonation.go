@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"strings"
+
+	"troo-backend/internal/pkg/constants"
+	"troo-backend/internal/pkg/response"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const impersonatingOrgKey = "impersonating_org_id"
+
+// SetImpersonation starts (orgID set) or stops (nil) read-only impersonation for the session user.
+func SetImpersonation(c *fiber.Ctx, orgID *string) {
+	data, _ := c.Locals("session_data").(map[string]interface{})
+	u, _ := data["user"].(map[string]interface{})
+	if u == nil {
+		return
+	}
+	if orgID == nil {
+		delete(u, impersonatingOrgKey)
+	} else {
+		u[impersonatingOrgKey] = *orgID
+	}
+	c.Locals("user", u)
+}
+
+// ReadOnlyImpersonation lets a platform operator browse the API as a viewer of another org.
+// While impersonating, GET requests outside /api/v1/platform see a viewer of that org (impersonated_by set);
+// any other method is refused except on /api/v1/platform and logout. Run after Session.
+func ReadOnlyImpersonation() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		m, ok := GetUser(c).(map[string]interface{})
+		if !ok {
+			return c.Next()
+		}
+		orgID, _ := m[impersonatingOrgKey].(string)
+		if orgID == "" {
+			return c.Next()
+		}
+		if platformRole, _ := m["platform_role"].(string); platformRole == "" {
+			return c.Next()
+		}
+		path := c.Path()
+		if strings.HasPrefix(path, "/api/v1/platform/") || path == "/api/v1/auth/logout" {
+			return c.Next()
+		}
+		if c.Method() != fiber.MethodGet && c.Method() != fiber.MethodHead {
+			return response.Error(c, "Impersonation is read-only", 403, nil)
+		}
+		c.Locals("user", map[string]interface{}{
+			"user_id":         m["user_id"],
+			"fullname":        m["fullname"],
+			"email":           m["email"],
+			"role":            constants.Viewer,
+			"org_id":          orgID,
+			"impersonated_by": m["user_id"],
+		})
+		return c.Next()
+	}
+}
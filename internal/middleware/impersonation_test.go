@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupImpersonationApp keeps one session across requests, as the Redis store would, for a platform operator.
+func setupImpersonationApp(t *testing.T) *fiber.App {
+	session := map[string]interface{}{
+		"user": map[string]interface{}{"user_id": "op-1", "role": "viewer", "org_id": nil, "platform_role": "platform_support"},
+	}
+	app := fiber.New()
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("session_data", session)
+		c.Locals("user", session["user"])
+		return c.Next()
+	})
+	app.Use(ReadOnlyImpersonation())
+	app.Post("/api/v1/platform/impersonate", func(c *fiber.Ctx) error {
+		org := "org-9"
+		SetImpersonation(c, &org)
+		return c.SendStatus(200)
+	})
+	app.Delete("/api/v1/platform/impersonate", func(c *fiber.Ctx) error {
+		SetImpersonation(c, nil)
+		return c.SendStatus(200)
+	})
+	app.Get("/api/v1/holdings/view-holdings", func(c *fiber.Ctx) error { return c.JSON(GetUser(c)) })
+	app.Post("/api/v1/trading/buy-credits", func(c *fiber.Ctx) error { return c.SendStatus(200) })
+	return app
+}
+
+func call(t *testing.T, app *fiber.App, method, path string) (int, map[string]interface{}) {
+	resp, err := app.Test(httptest.NewRequest(method, path, nil))
+	require.NoError(t, err)
+	var body map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&body)
+	return resp.StatusCode, body
+}
+
+func TestReadOnlyImpersonation(t *testing.T) {
+	app := setupImpersonationApp(t)
+
+	_, user := call(t, app, "GET", "/api/v1/holdings/view-holdings")
+	assert.Nil(t, user["org_id"])
+
+	code, _ := call(t, app, "POST", "/api/v1/platform/impersonate")
+	require.Equal(t, 200, code)
+
+	code, user = call(t, app, "GET", "/api/v1/holdings/view-holdings")
+	require.Equal(t, 200, code)
+	assert.Equal(t, "org-9", user["org_id"])
+	assert.Equal(t, "viewer", user["role"])
+	assert.Equal(t, "op-1", user["impersonated_by"])
+	assert.Nil(t, user["platform_role"])
+
+	code, body := call(t, app, "POST", "/api/v1/trading/buy-credits")
+	assert.Equal(t, 403, code)
+	assert.Equal(t, "Impersonation is read-only", body["error"].(map[string]interface{})["message"])
+
+	code, _ = call(t, app, "DELETE", "/api/v1/platform/impersonate")
+	require.Equal(t, 200, code)
+	code, _ = call(t, app, "POST", "/api/v1/trading/buy-credits")
+	assert.Equal(t, 200, code)
+}
@@ -1,11 +1,14 @@
 package middleware
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
-	"strings"
+	"errors"
 	"time"
 
+	"troo-backend/internal/pkg/constants"
+
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
@@ -14,17 +17,46 @@ import (
 // SessionConfig for Redis-backed session; cookie and Redis format match Express/connect-redis.
 type SessionConfig struct {
 	Secret            string
+	PreviousSecrets   []string // still accepted when verifying cookies, for rotating Secret
 	RedisURL          string
 	AllowCrossSiteDev bool
 	IsProduction      bool
+	// IdleTimeout ends a session unused for this long; MaxLifetime ends it this long after sign-in however
+	// active it is. Both default to 24h, the Express TTL and cookie max age.
+	IdleTimeout time.Duration
+	MaxLifetime time.Duration
+}
+
+func (cfg SessionConfig) idleTimeout() time.Duration {
+	if cfg.IdleTimeout > 0 {
+		return cfg.IdleTimeout
+	}
+	return sessionMaxAge
+}
+
+func (cfg SessionConfig) maxLifetime() time.Duration {
+	if cfg.MaxLifetime > 0 {
+		return cfg.MaxLifetime
+	}
+	return sessionMaxAge
+}
+
+// refreshWindow is how stale last_seen may get before a request rewrites the session to slide its idle expiry.
+func (cfg SessionConfig) refreshWindow() time.Duration {
+	if w := cfg.idleTimeout() / 4; w < sessionRefreshWindow {
+		return w
+	}
+	return sessionRefreshWindow
 }
 
 const (
 	sessionCookieName  = "troo.sid"
 	SessionCookieName  = "troo.sid" // exported for auth logout (clear cookie)
-	sessionPrefix      = "session:"
-	SessionRedisPrefix = "session:" // exported for auth logout (Del key)
+	sessionPrefix      = constants.SessionRedisPrefix
+	SessionRedisPrefix = constants.SessionRedisPrefix // exported for auth logout (Del key)
 	sessionMaxAge      = 24 * time.Hour
+	// sessionRefreshWindow caps how often an unchanged session is rewritten just to extend its TTL.
+	sessionRefreshWindow = 5 * time.Minute
 )
 
 // SessionUser is the shape stored in session under "user" (Express parity).
@@ -34,11 +66,37 @@ type SessionUser struct {
 	Email    string  `json:"email"`
 	Role     string  `json:"role"`
 	OrgID    *string `json:"org_id"`
+	// Set only for users holding an org-defined custom role; Permissions then replaces the Role check.
+	CustomRoleID *string  `json:"custom_role_id,omitempty"`
+	Permissions  []string `json:"permissions,omitempty"`
+	// Set only for platform operators (constants.ValidPlatformRoles); checked by AuthorizePlatformPermission.
+	PlatformRole *string `json:"platform_role,omitempty"`
+	// EmailVerified gates trading permissions in AuthorizePermission.
+	EmailVerified bool `json:"email_verified"`
+	// TwoFactor is set when the session passed a TOTP check; TwoFactorRequired when the user's org demands it
+	// for trading permissions.
+	TwoFactor         bool `json:"two_factor"`
+	TwoFactorRequired bool `json:"two_factor_required"`
+	// AuthMethod is how the session signed in (AuthMethodPassword or AuthMethodSSO). An SSO session is bound to
+	// SSOOrgID, the org whose identity provider vouched for it, and cannot move to another org.
+	AuthMethod string  `json:"auth_method,omitempty"`
+	SSOOrgID   *string `json:"sso_org_id,omitempty"`
 }
 
+const (
+	AuthMethodPassword = "password"
+	AuthMethodSSO      = "sso"
+)
+
+// ErrSSOSessionBound refuses moving a session signed in through an org's single sign-on to another org.
+var ErrSSOSessionBound = errors.New("This session signed in through your organization's single sign-on and cannot switch organization")
+
 // Session returns a Fiber middleware that loads/saves session from Redis.
 // Cookie name "troo.sid", Redis key prefix "session:", same TTL and flags as Express.
 func Session(cfg SessionConfig) (fiber.Handler, *redis.Client, error) {
+	if cfg.IsProduction && cfg.Secret == "" {
+		return nil, nil, errors.New("SESSION_SECRET is required in production")
+	}
 	opt, err := redis.ParseURL(cfg.RedisURL)
 	if err != nil {
 		return nil, nil, err
@@ -46,24 +104,33 @@ func Session(cfg SessionConfig) (fiber.Handler, *redis.Client, error) {
 	rdb := redis.NewClient(opt)
 
 	return func(c *fiber.Ctx) error {
-		sessionID := c.Cookies(sessionCookieName)
-		// Express cookie may be "s:id" or "s:id.signature"; use first part as id
-		if strings.HasPrefix(sessionID, "s:") {
-			parts := strings.SplitN(sessionID[2:], ".", 2)
-			sessionID = parts[0]
-		}
+		// Express cookie is "s:id.signature"; a forged or tampered cookie gets a fresh anonymous session
+		sessionID := sessionIDFromCookie(cfg, c.Cookies(sessionCookieName))
 		key := sessionPrefix + sessionID
 
 		var data map[string]interface{}
+		var loaded []byte
 		if sessionID != "" {
 			b, err := rdb.Get(context.Background(), key).Bytes()
-			if err == nil {
-				_ = json.Unmarshal(b, &data)
+			if err == nil && json.Unmarshal(b, &data) == nil {
+				loaded = b
+			}
+		}
+		if data != nil && sessionExpired(cfg, data, time.Now()) {
+			// Past its absolute lifetime: sign out, however recently it was used
+			rdb.Del(context.Background(), key)
+			if u, ok := data["user"].(map[string]interface{}); ok {
+				if uid, _ := u["user_id"].(string); uid != "" {
+					rdb.SRem(context.Background(), constants.UserSessionsPrefix+uid, sessionID)
+				}
 			}
+			data, loaded = nil, nil
 		}
 		if data == nil {
 			data = make(map[string]interface{})
 		}
+		// Touch before the handler so /auth/me reports the expiry this request sets
+		touchSessionMeta(c, cfg, data)
 
 		// Store in Locals for handlers
 		c.Locals("session_data", data)
@@ -79,18 +146,107 @@ func Session(cfg SessionConfig) (fiber.Handler, *redis.Client, error) {
 			return err
 		}
 
-		// Persist if we have a session id (e.g. after login)
+		// Persist if we have a session id (e.g. after login) and the data changed; an unchanged session
+		// is only rewritten when touchSessionMeta moves last_seen, once per refresh window
 		if sid, _ := c.Locals("session_id").(string); sid != "" {
 			updated, _ := c.Locals("session_data").(map[string]interface{})
 			if updated != nil {
-				b, _ := json.Marshal(updated)
-				rdb.Set(context.Background(), sessionPrefix+sid, b, sessionMaxAge)
+				touchSessionMeta(c, cfg, updated)
+				saveSession(rdb, cfg, sid, sid == sessionID, loaded, updated)
 			}
 		}
 		return nil
 	}, rdb, nil
 }
 
+// saveSession writes data under sid with a TTL of the idle timeout, cut short by the absolute lifetime.
+// sameID reports whether sid is the session that was loaded as loaded, so unchanged data can be skipped.
+func saveSession(rdb *redis.Client, cfg SessionConfig, sid string, sameID bool, loaded []byte, data map[string]interface{}) {
+	ctx := context.Background()
+	if len(data) == 0 {
+		if sameID && loaded != nil {
+			rdb.Del(ctx, sessionPrefix+sid)
+		}
+		return
+	}
+	b, _ := json.Marshal(data)
+	if sameID && bytes.Equal(b, loaded) {
+		return
+	}
+	ttl := cfg.idleTimeout()
+	if createdAt, ok := sessionMetaTime(data, "created_at"); ok {
+		if left := time.Until(createdAt.Add(cfg.maxLifetime())); left < ttl {
+			ttl = left
+		}
+	}
+	if ttl <= 0 {
+		rdb.Del(ctx, sessionPrefix+sid)
+		return
+	}
+	rdb.Set(ctx, sessionPrefix+sid, b, ttl)
+}
+
+// sessionExpired reports whether a signed-in session is past its absolute lifetime.
+func sessionExpired(cfg SessionConfig, data map[string]interface{}, now time.Time) bool {
+	createdAt, ok := sessionMetaTime(data, "created_at")
+	return ok && !now.Before(createdAt.Add(cfg.maxLifetime()))
+}
+
+func sessionMetaTime(data map[string]interface{}, field string) (time.Time, bool) {
+	meta, _ := data[SessionMetaKey].(map[string]interface{})
+	s, _ := meta[field].(string)
+	t, err := time.Parse(time.RFC3339, s)
+	return t, err == nil
+}
+
+// SessionExpiry returns when the current signed-in session ends: idleAt if no further request is made,
+// expiresAt at the latest.
+func SessionExpiry(c *fiber.Ctx, cfg SessionConfig) (idleAt, expiresAt time.Time, ok bool) {
+	data, _ := c.Locals("session_data").(map[string]interface{})
+	lastSeen, ok1 := sessionMetaTime(data, "last_seen")
+	createdAt, ok2 := sessionMetaTime(data, "created_at")
+	if !ok1 || !ok2 {
+		return time.Time{}, time.Time{}, false
+	}
+	expiresAt = createdAt.Add(cfg.maxLifetime())
+	idleAt = lastSeen.Add(cfg.idleTimeout())
+	if idleAt.After(expiresAt) {
+		idleAt = expiresAt
+	}
+	return idleAt, expiresAt, true
+}
+
+// SessionMetaKey holds when and from where a signed-in session was created and last used, for the user's
+// list of active sessions.
+const SessionMetaKey = constants.SessionMetaKey
+
+// touchSessionMeta records the request on a signed-in session; the first touch sets created_at. last_seen,
+// ip and user_agent only move once per refresh window, so most requests leave the session unchanged.
+func touchSessionMeta(c *fiber.Ctx, cfg SessionConfig, data map[string]interface{}) {
+	if data["user"] == nil {
+		return
+	}
+	meta, _ := data[SessionMetaKey].(map[string]interface{})
+	if meta == nil {
+		meta = map[string]interface{}{}
+		data[SessionMetaKey] = meta
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	if _, ok := meta["created_at"]; !ok {
+		meta["created_at"] = now
+	}
+	if lastSeen, ok := sessionMetaTime(data, "last_seen"); ok && time.Since(lastSeen) < cfg.refreshWindow() {
+		return
+	}
+	ua := c.Get(fiber.HeaderUserAgent)
+	if len(ua) > 256 {
+		ua = ua[:256]
+	}
+	meta["last_seen"] = now
+	meta["ip"] = c.IP()
+	meta["user_agent"] = ua
+}
+
 // GetSessionID returns the current session ID from context (for login/logout).
 func GetSessionID(c *fiber.Ctx) string {
 	sid, _ := c.Locals("session_id").(string)
@@ -104,19 +260,113 @@ func SetSessionUser(c *fiber.Ctx, user SessionUser) {
 	if data == nil {
 		data = make(map[string]interface{})
 	}
-	data["user"] = map[string]interface{}{
-		"user_id":  user.UserID,
-		"fullname": user.Fullname,
-		"email":    user.Email,
-		"role":     user.Role,
-		"org_id":   user.OrgID,
+	u := map[string]interface{}{
+		"user_id":        user.UserID,
+		"fullname":       user.Fullname,
+		"email":          user.Email,
+		"role":           user.Role,
+		"org_id":         user.OrgID,
+		"email_verified": user.EmailVerified,
+	}
+	if user.TwoFactor {
+		u["two_factor"] = true
 	}
+	if user.TwoFactorRequired {
+		u["two_factor_required"] = true
+	}
+	if user.CustomRoleID != nil {
+		u["custom_role_id"] = *user.CustomRoleID
+		u["permissions"] = user.Permissions
+	}
+	if user.PlatformRole != nil {
+		u["platform_role"] = *user.PlatformRole
+	}
+	if user.AuthMethod != "" {
+		u["auth_method"] = user.AuthMethod
+	}
+	if user.SSOOrgID != nil {
+		u["sso_org_id"] = *user.SSOOrgID
+	}
+	data["user"] = u
 	c.Locals("session_data", data)
 	c.Locals("user", data["user"])
 }
 
+// SSOOrgID returns the org an SSO session is bound to, or "" for a session that signed in otherwise.
+func SSOOrgID(user interface{}) string {
+	u, _ := user.(map[string]interface{})
+	orgID, _ := u["sso_org_id"].(string)
+	return orgID
+}
+
+// SetSessionEmail updates the session user's email and verification flag in place, keeping the other fields.
+func SetSessionEmail(c *fiber.Ctx, email string, verified bool) {
+	data, _ := c.Locals("session_data").(map[string]interface{})
+	u, ok := data["user"].(map[string]interface{})
+	if !ok {
+		return
+	}
+	u["email"] = email
+	u["email_verified"] = verified
+	c.Locals("user", u)
+}
+
+// pendingTwoFactorKey holds a login that passed the password check but still owes a TOTP code. The session
+// has no "user" until the code is verified, so RequireAuth keeps refusing it.
+const pendingTwoFactorKey = "pending_2fa"
+
+// SetPendingTwoFactor replaces the session data with a pending two-step login for userID. ssoOrgID is the org
+// an SSO sign-in came through, "" for a password one.
+func SetPendingTwoFactor(c *fiber.Ctx, userID, ssoOrgID string, expiresAt time.Time) {
+	c.Locals("session_data", map[string]interface{}{
+		pendingTwoFactorKey: map[string]interface{}{
+			"user_id":    userID,
+			"sso_org_id": ssoOrgID,
+			"expires_at": expiresAt.Unix(),
+			"attempts":   0,
+		},
+	})
+	c.Locals("user", nil)
+}
+
+// GetPendingTwoFactor returns the user id, SSO org and failed attempts of an unexpired pending login.
+func GetPendingTwoFactor(c *fiber.Ctx) (userID, ssoOrgID string, attempts int, ok bool) {
+	data, _ := c.Locals("session_data").(map[string]interface{})
+	p, _ := data[pendingTwoFactorKey].(map[string]interface{})
+	userID, _ = p["user_id"].(string)
+	expiresAt, _ := toInt64(p["expires_at"])
+	if userID == "" || time.Now().Unix() > expiresAt {
+		return "", "", 0, false
+	}
+	ssoOrgID, _ = p["sso_org_id"].(string)
+	n, _ := toInt64(p["attempts"])
+	return userID, ssoOrgID, int(n), true
+}
+
+// RecordPendingTwoFactorFailure counts a wrong code against the pending login.
+func RecordPendingTwoFactorFailure(c *fiber.Ctx) {
+	data, _ := c.Locals("session_data").(map[string]interface{})
+	if p, ok := data[pendingTwoFactorKey].(map[string]interface{}); ok {
+		n, _ := toInt64(p["attempts"])
+		p["attempts"] = n + 1
+	}
+}
+
+// toInt64 reads a number that may have been round-tripped through JSON (float64).
+func toInt64(v interface{}) (int64, bool) {
+	switch n := v.(type) {
+	case int:
+		return int64(n), true
+	case int64:
+		return n, true
+	case float64:
+		return int64(n), true
+	}
+	return 0, false
+}
+
 // RegenerateSessionID creates a new session ID and sets it in Locals (cookie set by handler).
-// Cookie value should be "s:"+returned ID for Express compatibility.
+// Cookie value should be SessionCookieValue(cfg, returned ID) for Express compatibility.
 func RegenerateSessionID(c *fiber.Ctx) string {
 	newID := uuid.New().String()
 	c.Locals("session_id", newID)
@@ -140,7 +390,7 @@ func SessionCookieConfig(cfg SessionConfig) fiber.Cookie {
 		Name:     sessionCookieName,
 		Value:    "",
 		Path:     "/",
-		MaxAge:   int(sessionMaxAge.Seconds()),
+		MaxAge:   int(cfg.maxLifetime().Seconds()),
 		HTTPOnly: true,
 		Secure:   secure,
 		SameSite: sameSite,
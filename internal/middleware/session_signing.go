@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/url"
+	"strings"
+)
+
+// Session ids in troo.sid are signed the way express-session does it (cookie-signature): the value is
+// "s:" + id + "." + base64(HMAC-SHA256(secret, id)) without padding. New cookies are signed with
+// SessionConfig.Secret; PreviousSecrets are still accepted so the secret can be rotated without logging
+// everyone out.
+
+// SessionCookieValue returns the troo.sid value for a session id. Without a secret (development only,
+// Session refuses to start without one in production) the id is sent unsigned.
+func SessionCookieValue(cfg SessionConfig, id string) string {
+	if cfg.Secret == "" {
+		return "s:" + id
+	}
+	return "s:" + signSessionID(id, cfg.Secret)
+}
+
+// sessionIDFromCookie returns the session id in a troo.sid value, or "" when the cookie must be ignored:
+// a bad signature, or an unsigned cookie while a secret is configured in production.
+func sessionIDFromCookie(cfg SessionConfig, raw string) string {
+	if strings.Contains(raw, "%") {
+		// express-session URL-encodes the cookie ("s%3A...")
+		if v, err := url.PathUnescape(raw); err == nil {
+			raw = v
+		}
+	}
+	secrets := cfg.secrets()
+	if strings.HasPrefix(raw, "s:") {
+		signed := raw[2:]
+		if dot := strings.LastIndexByte(signed, '.'); dot > 0 {
+			id := signed[:dot]
+			if len(secrets) == 0 {
+				return id
+			}
+			for _, secret := range secrets {
+				if hmac.Equal([]byte(signSessionID(id, secret)), []byte(signed)) {
+					return id
+				}
+			}
+			return ""
+		}
+		raw = signed
+	}
+	// Unsigned id: tolerated outside production so local tools and old cookies keep working.
+	if cfg.IsProduction && len(secrets) > 0 {
+		return ""
+	}
+	return raw
+}
+
+func signSessionID(id, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(id))
+	return id + "." + strings.TrimRight(base64.StdEncoding.EncodeToString(mac.Sum(nil)), "=")
+}
+
+// secrets returns the current secret followed by the previous ones still accepted.
+func (cfg SessionConfig) secrets() []string {
+	var out []string
+	for _, s := range append([]string{cfg.Secret}, cfg.PreviousSecrets...) {
+		if s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignSessionID_MatchesCookieSignature(t *testing.T) {
+	// Example from the cookie-signature README, which express-session uses.
+	assert.Equal(t, "hello.DGDUkGlIkCzPz+C0B064FNgHdEjox7ch8tOBGslZ5QI", signSessionID("hello", "tobiiscool"))
+}
+
+func TestSessionIDFromCookie(t *testing.T) {
+	cfg := SessionConfig{Secret: "new", PreviousSecrets: []string{"old"}, IsProduction: true}
+	signed := SessionCookieValue(cfg, "sid-1")
+
+	assert.Equal(t, "sid-1", sessionIDFromCookie(cfg, signed))
+	assert.Equal(t, "sid-1", sessionIDFromCookie(cfg, "s%3A"+signed[2:]), "URL-encoded as Express writes it")
+	assert.Equal(t, "sid-1", sessionIDFromCookie(cfg, SessionCookieValue(SessionConfig{Secret: "old"}, "sid-1")), "rotated secret")
+	assert.Empty(t, sessionIDFromCookie(cfg, SessionCookieValue(SessionConfig{Secret: "other"}, "sid-1")))
+	assert.Empty(t, sessionIDFromCookie(cfg, "s:sid-2"+signed[len("s:sid-1"):]), "signature for another id")
+	assert.Empty(t, sessionIDFromCookie(cfg, "s:sid-1"))
+	assert.Empty(t, sessionIDFromCookie(cfg, "sid-1"))
+
+	dev := SessionConfig{Secret: "new"}
+	assert.Equal(t, "sid-1", sessionIDFromCookie(dev, "s:sid-1"), "unsigned tolerated outside production")
+	assert.Empty(t, sessionIDFromCookie(dev, SessionCookieValue(SessionConfig{Secret: "other"}, "sid-1")), "bad signatures never are")
+}
+
+func TestSession_IgnoresForgedCookie(t *testing.T) {
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	t.Cleanup(mr.Close)
+	cfg := SessionConfig{Secret: "s3cret", RedisURL: "redis://" + mr.Addr(), IsProduction: true}
+	handler, _, err := Session(cfg)
+	require.NoError(t, err)
+	require.NoError(t, mr.Set(SessionRedisPrefix+"sid-1", `{"user":{"user_id":"u-1"}}`))
+
+	app := fiber.New()
+	app.Use(handler)
+	app.Get("/whoami", func(c *fiber.Ctx) error { return c.JSON(GetUser(c)) })
+	whoami := func(cookie string) string {
+		req := httptest.NewRequest("GET", "/whoami", nil)
+		req.Header.Set("Cookie", SessionCookieName+"="+cookie)
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		b := make([]byte, 256)
+		n, _ := resp.Body.Read(b)
+		return string(b[:n])
+	}
+
+	assert.Contains(t, whoami(SessionCookieValue(cfg, "sid-1")), "u-1")
+	assert.Equal(t, "null", whoami("s:sid-1"))
+	assert.Equal(t, "null", whoami(SessionCookieValue(SessionConfig{Secret: "guess"}, "sid-1")))
+
+	_, _, err = Session(SessionConfig{RedisURL: "redis://" + mr.Addr(), IsProduction: true})
+	assert.Error(t, err)
+}
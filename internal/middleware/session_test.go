@@ -0,0 +1,90 @@
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSession_SlidingAndAbsoluteExpiry(t *testing.T) {
+	mr, err := miniredis.Run()
+	require.NoError(t, err)
+	t.Cleanup(mr.Close)
+	cfg := SessionConfig{RedisURL: "redis://" + mr.Addr(), IdleTimeout: 30 * time.Minute, MaxLifetime: 2 * time.Hour}
+	handler, _, err := Session(cfg)
+	require.NoError(t, err)
+
+	app := fiber.New()
+	app.Use(handler)
+	app.Get("/whoami", func(c *fiber.Ctx) error {
+		idleAt, expiresAt, _ := SessionExpiry(c, cfg)
+		return c.JSON(fiber.Map{"user": GetUser(c), "idle_expires_at": idleAt, "expires_at": expiresAt})
+	})
+	app.Post("/note", func(c *fiber.Ctx) error {
+		c.Locals("session_data").(map[string]interface{})["note"] = "hi"
+		return c.SendStatus(200)
+	})
+	call := func(method, path string) map[string]interface{} {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Cookie", SessionCookieName+"=s:sid-1")
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		var out map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&out)
+		return out
+	}
+	seed := func(createdAgo, seenAgo time.Duration) string {
+		now := time.Now().UTC()
+		v := fmt.Sprintf(`{"session_meta":{"created_at":%q,"ip":"0.0.0.0","last_seen":%q,"user_agent":""},"user":{"user_id":"u-1"}}`,
+			now.Add(-createdAgo).Format(time.RFC3339), now.Add(-seenAgo).Format(time.RFC3339))
+		require.NoError(t, mr.Set(SessionRedisPrefix+"sid-1", v))
+		return v
+	}
+	key := SessionRedisPrefix + "sid-1"
+
+	// Recently seen and unchanged: not rewritten.
+	stored := seed(10*time.Minute, time.Minute)
+	out := call("GET", "/whoami")
+	assert.NotNil(t, out["user"])
+	got, _ := mr.Get(key)
+	assert.Equal(t, stored, got)
+	assert.Zero(t, mr.TTL(key))
+
+	// A change is saved straight away.
+	call("POST", "/note")
+	got, _ = mr.Get(key)
+	assert.Contains(t, got, `"note":"hi"`)
+	assert.Equal(t, 30*time.Minute, mr.TTL(key))
+
+	// Crossing the refresh window slides the idle expiry.
+	seed(10*time.Minute, 6*time.Minute)
+	out = call("GET", "/whoami")
+	assert.Equal(t, 30*time.Minute, mr.TTL(key))
+	idleAt, _ := time.Parse(time.RFC3339, out["idle_expires_at"].(string))
+	assert.WithinDuration(t, time.Now().Add(30*time.Minute), idleAt, 2*time.Second)
+
+	// Idle past the timeout: gone.
+	mr.FastForward(31 * time.Minute)
+	assert.Nil(t, call("GET", "/whoami")["user"])
+
+	// Activity cannot outlive the absolute lifetime.
+	seed(2*time.Hour-10*time.Minute, 6*time.Minute)
+	out = call("GET", "/whoami")
+	assert.NotNil(t, out["user"])
+	assert.LessOrEqual(t, mr.TTL(key), 10*time.Minute)
+	assert.Greater(t, mr.TTL(key), 9*time.Minute)
+	idleAt, _ = time.Parse(time.RFC3339, out["idle_expires_at"].(string))
+	assert.Equal(t, out["expires_at"], out["idle_expires_at"])
+	assert.WithinDuration(t, time.Now().Add(10*time.Minute), idleAt, 2*time.Second)
+
+	seed(2*time.Hour, 0)
+	assert.Nil(t, call("GET", "/whoami")["user"])
+	assert.False(t, mr.Exists(key))
+}
@@ -2,19 +2,45 @@ package constants
 
 // PermissionRoles maps each permission to roles allowed to perform it (Express PERMISSION_ROLES).
 var PermissionRoles = map[string][]string{
-	ViewData:        {Viewer, Manager, Admin, Superadmin},
-	BuyCredits:      {Manager, Admin, Superadmin},
-	SellCredits:     {Manager, Admin, Superadmin},
-	RetireCredits:   {Manager, Admin, Superadmin},
-	TransferCredits: {Admin, Superadmin},
-	CreateListing:   {Manager, Admin, Superadmin},
-	EditListing:     {Manager, Admin, Superadmin},
-	CancelListing:   {Manager, Admin, Superadmin},
-	InviteUser:      {Admin, Superadmin},
-	RemoveUser:      {Admin, Superadmin},
-	AssignRole:      {Admin, Superadmin},
-	ManageAdmins:    {Superadmin},
-	UpdateOrg:       {Admin, Superadmin},
+	ViewData:             {Viewer, Manager, Admin, Superadmin},
+	BuyCredits:           {Manager, Admin, Superadmin},
+	SellCredits:          {Manager, Admin, Superadmin},
+	RetireCredits:        {Manager, Admin, Superadmin},
+	TransferCredits:      {Admin, Superadmin},
+	CreateListing:        {Manager, Admin, Superadmin},
+	EditListing:          {Manager, Admin, Superadmin},
+	CancelListing:        {Manager, Admin, Superadmin},
+	InviteUser:           {Admin, Superadmin},
+	RemoveUser:           {Admin, Superadmin},
+	AssignRole:           {Admin, Superadmin},
+	ManageAdmins:         {Superadmin},
+	UpdateOrg:            {Admin, Superadmin},
+	ApproveCredits:       {Admin, Superadmin},
+	ManageApprovalPolicy: {Superadmin},
+	ManageTradingLimits:  {Admin, Superadmin},
+	ManageRoles:          {Superadmin},
+	ViewAuditLog:         {Admin, Superadmin},
+	ManageSecurityPolicy: {Superadmin},
+	ManageAPIKeys:        {Superadmin},
+	CloseOrg:             {Superadmin},
+}
+
+// tradingPermissions move credits. They are refused, whatever the role, to accounts with an unverified email
+// and, in orgs that require it, to sessions that did not pass two-factor authentication.
+var tradingPermissions = map[string]bool{
+	BuyCredits:      true,
+	SellCredits:     true,
+	RetireCredits:   true,
+	TransferCredits: true,
+	CreateListing:   true,
+	EditListing:     true,
+	CancelListing:   true,
+	ApproveCredits:  true,
+}
+
+// IsTradingPermission reports whether permission moves credits (see tradingPermissions).
+func IsTradingPermission(permission string) bool {
+	return tradingPermissions[permission]
 }
 
 // AllowedRole returns true if role is in the list of allowed roles for the permission.
@@ -30,3 +56,16 @@ func AllowedRole(permission, role string) bool {
 	}
 	return false
 }
+
+// GrantablePermission reports whether permission may be put in an org-defined custom role.
+// Superadmin-only permissions are never grantable, so a custom role cannot be used to escalate.
+func GrantablePermission(permission string) bool {
+	roles, ok := PermissionRoles[permission]
+	return ok && !(len(roles) == 1 && roles[0] == Superadmin)
+}
+
+// PrivilegedPermission reports whether permission is beyond what a manager holds (admin level).
+// Custom roles containing one are governed like the admin role.
+func PrivilegedPermission(permission string) bool {
+	return !AllowedRole(permission, Manager)
+}
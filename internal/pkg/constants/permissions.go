@@ -14,4 +14,12 @@ const (
 	AssignRole     = "assign_role"
 	ManageAdmins   = "manage_admins"
 	UpdateOrg      = "update_org"
+	ApproveCredits = "approve_credits"
+	ManageApprovalPolicy = "manage_approval_policy"
+	ManageTradingLimits = "manage_trading_limits"
+	ManageRoles    = "manage_roles"
+	ViewAuditLog   = "view_audit_log"
+	ManageSecurityPolicy = "manage_security_policy"
+	ManageAPIKeys  = "manage_api_keys"
+	CloseOrg       = "close_org"
 )
@@ -0,0 +1,52 @@
+package constants
+
+// Platform roles are held by Troo operators (PlatformOperators table), independently of any org role.
+const (
+	PlatformAdmin   = "platform_admin"
+	PlatformSupport = "platform_support"
+)
+
+// ValidPlatformRoles is the set of allowed PlatformOperators.role values.
+var ValidPlatformRoles = []string{PlatformSupport, PlatformAdmin}
+
+// Platform permissions guard cross-org back-office routes; they are never part of PermissionRoles.
+const (
+	SyncRegistry          = "sync_registry"
+	CreateRegistryListing = "create_registry_listing"
+	ManageOperators       = "manage_operators"
+	ViewPlatformData      = "view_platform_data"
+	ForceCloseListings    = "force_close_listings"
+	AdjustHoldings        = "adjust_holdings"
+	ReviewVerifications   = "review_verifications"
+)
+
+// PlatformPermissionRoles maps each platform permission to the platform roles allowed to perform it.
+var PlatformPermissionRoles = map[string][]string{
+	SyncRegistry:          {PlatformAdmin},
+	CreateRegistryListing: {PlatformAdmin},
+	ManageOperators:       {PlatformAdmin},
+	ViewPlatformData:      {PlatformSupport, PlatformAdmin},
+	ForceCloseListings:    {PlatformAdmin},
+	AdjustHoldings:        {PlatformAdmin},
+	ReviewVerifications:   {PlatformAdmin},
+}
+
+// IsValidPlatformRole returns true if role is one of the platform roles.
+func IsValidPlatformRole(role string) bool {
+	for _, r := range ValidPlatformRoles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowedPlatformRole returns true if the platform role may perform the platform permission.
+func AllowedPlatformRole(permission, role string) bool {
+	for _, r := range PlatformPermissionRoles[permission] {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,11 @@
+package constants
+
+// Redis layout of login sessions, shared by the session middleware and the services that list or end sessions.
+const (
+	// SessionRedisPrefix + session id holds the session JSON (Express connect-redis "session:").
+	SessionRedisPrefix = "session:"
+	// UserSessionsPrefix + user id is the set of that user's session ids.
+	UserSessionsPrefix = "user_sessions:"
+	// SessionMetaKey is the session field holding when and from where it was created and last used.
+	SessionMetaKey = "session_meta"
+)
@@ -0,0 +1,107 @@
+// Package decimal wraps github.com/shopspring/decimal for credit amounts and prices. Values are exact from DB scan
+// through arithmetic, so balances never accumulate binary rounding errors, and they render as JSON numbers (12.5,
+// not "12.5"), which is what API clients written against Express already parse. The JSON shape is set on this
+// type rather than through the library's process-wide MarshalJSONWithoutQuotes, so other decimals are unaffected.
+package decimal
+
+import (
+	"database/sql/driver"
+
+	"github.com/shopspring/decimal"
+)
+
+// Decimal is an exact decimal number.
+type Decimal struct {
+	d decimal.Decimal
+}
+
+// Zero is 0.
+var Zero = Decimal{}
+
+func NewFromInt(v int64) Decimal {
+	return Decimal{decimal.NewFromInt(v)}
+}
+
+func NewFromFloat(v float64) Decimal {
+	return Decimal{decimal.NewFromFloat(v)}
+}
+
+func NewFromString(s string) (Decimal, error) {
+	d, err := decimal.NewFromString(s)
+	return Decimal{d}, err
+}
+
+// RequireFromString is NewFromString that panics on invalid input; for constants and tests.
+func RequireFromString(s string) Decimal {
+	return Decimal{decimal.RequireFromString(s)}
+}
+
+// Max returns the largest of its arguments.
+func Max(first Decimal, rest ...Decimal) Decimal {
+	out := first
+	for _, d := range rest {
+		if d.GreaterThan(out) {
+			out = d
+		}
+	}
+	return out
+}
+
+// Min returns the smallest of its arguments.
+func Min(first Decimal, rest ...Decimal) Decimal {
+	out := first
+	for _, d := range rest {
+		if d.LessThan(out) {
+			out = d
+		}
+	}
+	return out
+}
+
+func (d Decimal) Add(d2 Decimal) Decimal { return Decimal{d.d.Add(d2.d)} }
+func (d Decimal) Sub(d2 Decimal) Decimal { return Decimal{d.d.Sub(d2.d)} }
+func (d Decimal) Mul(d2 Decimal) Decimal { return Decimal{d.d.Mul(d2.d)} }
+func (d Decimal) Neg() Decimal           { return Decimal{d.d.Neg()} }
+func (d Decimal) Abs() Decimal           { return Decimal{d.d.Abs()} }
+
+// Round rounds to places decimal places, half away from zero.
+func (d Decimal) Round(places int32) Decimal { return Decimal{d.d.Round(places)} }
+
+// Shift multiplies d by 10^shift.
+func (d Decimal) Shift(shift int32) Decimal { return Decimal{d.d.Shift(shift)} }
+
+func (d Decimal) Cmp(d2 Decimal) int                 { return d.d.Cmp(d2.d) }
+func (d Decimal) Equal(d2 Decimal) bool              { return d.d.Equal(d2.d) }
+func (d Decimal) LessThan(d2 Decimal) bool           { return d.d.LessThan(d2.d) }
+func (d Decimal) LessThanOrEqual(d2 Decimal) bool    { return d.d.LessThanOrEqual(d2.d) }
+func (d Decimal) GreaterThan(d2 Decimal) bool        { return d.d.GreaterThan(d2.d) }
+func (d Decimal) GreaterThanOrEqual(d2 Decimal) bool { return d.d.GreaterThanOrEqual(d2.d) }
+func (d Decimal) Sign() int                          { return d.d.Sign() }
+func (d Decimal) IsZero() bool                       { return d.d.IsZero() }
+func (d Decimal) IsPositive() bool                   { return d.d.IsPositive() }
+func (d Decimal) IsNegative() bool                   { return d.d.IsNegative() }
+func (d Decimal) Exponent() int32                    { return d.d.Exponent() }
+func (d Decimal) InexactFloat64() float64            { return d.d.InexactFloat64() }
+func (d Decimal) String() string                     { return d.d.String() }
+func (d Decimal) StringFixed(places int32) string    { return d.d.StringFixed(places) }
+func (d Decimal) IntPart() int64                     { return d.d.IntPart() }
+
+// MarshalJSON renders d as a JSON number.
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return []byte(d.d.String()), nil
+}
+
+// UnmarshalJSON accepts a JSON number or a quoted decimal string.
+func (d *Decimal) UnmarshalJSON(b []byte) error {
+	return d.d.UnmarshalJSON(b)
+}
+
+// Scan implements sql.Scanner for decimal columns.
+func (d *Decimal) Scan(value interface{}) error {
+	return d.d.Scan(value)
+}
+
+// Value implements driver.Valuer; the column receives the exact string form.
+func (d Decimal) Value() (driver.Value, error) {
+	return d.d.Value()
+}
@@ -0,0 +1,22 @@
+package decimal
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecimal_JSONNumberWithoutGlobalFlag(t *testing.T) {
+	out, err := json.Marshal(map[string]interface{}{"amount": RequireFromString("12.50"), "plain": decimal.RequireFromString("12.5")})
+	require.NoError(t, err)
+	// Only the wrapper renders as a number; the library's own type keeps its default.
+	assert.JSONEq(t, `{"amount":12.5,"plain":"12.5"}`, string(out))
+	assert.False(t, decimal.MarshalJSONWithoutQuotes)
+
+	var in struct{ A, B Decimal }
+	require.NoError(t, json.Unmarshal([]byte(`{"A":0.1,"B":"0.2"}`), &in))
+	assert.Equal(t, "0.3", in.A.Add(in.B).String())
+}
@@ -0,0 +1,277 @@
+// Package oidc is a minimal OpenID Connect relying party: discovery, the authorization code flow with PKCE
+// (S256) and RS256 ID token verification against the provider's JWKS.
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// clockSkew is how far exp and iat may be off between the provider's clock and ours.
+const clockSkew = time.Minute
+
+var b64 = base64.RawURLEncoding
+
+// ErrInvalidIDToken is returned (wrapped) for any ID token that fails verification.
+var ErrInvalidIDToken = errors.New("invalid ID token")
+
+// Provider is an identity provider's discovery document.
+type Provider struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+
+	client *http.Client
+}
+
+// Claims are the ID token claims used for sign-in.
+type Claims struct {
+	Subject       string `json:"sub"`
+	Email         string `json:"email"`
+	EmailVerified *bool  `json:"email_verified"`
+	Name          string `json:"name"`
+}
+
+// Discover fetches issuer's /.well-known/openid-configuration. client may be nil for http.DefaultClient.
+func Discover(ctx context.Context, client *http.Client, issuer string) (*Provider, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	issuer = strings.TrimSuffix(issuer, "/")
+	var p Provider
+	if err := getJSON(ctx, client, issuer+"/.well-known/openid-configuration", &p); err != nil {
+		return nil, err
+	}
+	if strings.TrimSuffix(p.Issuer, "/") != issuer {
+		return nil, fmt.Errorf("oidc: discovery issuer %q does not match %q", p.Issuer, issuer)
+	}
+	if p.AuthorizationEndpoint == "" || p.TokenEndpoint == "" || p.JWKSURI == "" {
+		return nil, errors.New("oidc: discovery document is missing endpoints")
+	}
+	p.client = client
+	return &p, nil
+}
+
+// RandomString returns n random bytes, base64url encoded, for state, nonce and PKCE verifiers.
+func RandomString(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return b64.EncodeToString(b)
+}
+
+// Challenge is the S256 PKCE challenge for verifier.
+func Challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return b64.EncodeToString(sum[:])
+}
+
+// AuthCodeURL is where to send the browser to sign in.
+func (p *Provider) AuthCodeURL(clientID, redirectURI, state, nonce, verifier string) string {
+	q := url.Values{}
+	q.Set("response_type", "code")
+	q.Set("client_id", clientID)
+	q.Set("redirect_uri", redirectURI)
+	q.Set("scope", "openid email profile")
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	q.Set("code_challenge", Challenge(verifier))
+	q.Set("code_challenge_method", "S256")
+	sep := "?"
+	if strings.Contains(p.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return p.AuthorizationEndpoint + sep + q.Encode()
+}
+
+// Exchange redeems an authorization code at the token endpoint (client_secret_basic) and returns the raw ID token.
+func (p *Provider) Exchange(ctx context.Context, clientID, clientSecret, redirectURI, code, verifier string) (string, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", redirectURI)
+	form.Set("code_verifier", verifier)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(clientID), url.QueryEscape(clientSecret))
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	var out struct {
+		IDToken string `json:"id_token"`
+		Error   string `json:"error"`
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	_ = json.Unmarshal(body, &out)
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("oidc: token endpoint returned %d %s", resp.StatusCode, out.Error)
+	}
+	if out.IDToken == "" {
+		return "", errors.New("oidc: token response has no id_token")
+	}
+	return out.IDToken, nil
+}
+
+// Verify checks an RS256 ID token's signature, issuer, audience, expiry and nonce and returns its claims.
+func (p *Provider) Verify(ctx context.Context, raw, clientID, nonce string, now time.Time) (*Claims, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: malformed", ErrInvalidIDToken)
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil || header.Alg != "RS256" {
+		return nil, fmt.Errorf("%w: unsupported header", ErrInvalidIDToken)
+	}
+	key, err := p.key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := b64.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed signature", ErrInvalidIDToken)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig); err != nil {
+		return nil, fmt.Errorf("%w: bad signature", ErrInvalidIDToken)
+	}
+
+	var std struct {
+		Issuer   string      `json:"iss"`
+		Audience interface{} `json:"aud"`
+		AZP      string      `json:"azp"`
+		Expiry   int64       `json:"exp"`
+		IssuedAt int64       `json:"iat"`
+		Nonce    string      `json:"nonce"`
+	}
+	var claims Claims
+	if decodeSegment(parts[1], &std) != nil || decodeSegment(parts[1], &claims) != nil {
+		return nil, fmt.Errorf("%w: malformed claims", ErrInvalidIDToken)
+	}
+	if strings.TrimSuffix(std.Issuer, "/") != strings.TrimSuffix(p.Issuer, "/") {
+		return nil, fmt.Errorf("%w: wrong issuer", ErrInvalidIDToken)
+	}
+	aud := audiences(std.Audience)
+	if !contains(aud, clientID) || (len(aud) > 1 && std.AZP != clientID) {
+		return nil, fmt.Errorf("%w: wrong audience", ErrInvalidIDToken)
+	}
+	if now.After(time.Unix(std.Expiry, 0).Add(clockSkew)) {
+		return nil, fmt.Errorf("%w: expired", ErrInvalidIDToken)
+	}
+	if time.Unix(std.IssuedAt, 0).After(now.Add(clockSkew)) {
+		return nil, fmt.Errorf("%w: issued in the future", ErrInvalidIDToken)
+	}
+	if nonce == "" || std.Nonce != nonce {
+		return nil, fmt.Errorf("%w: wrong nonce", ErrInvalidIDToken)
+	}
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%w: no subject", ErrInvalidIDToken)
+	}
+	return &claims, nil
+}
+
+// key returns the provider's RSA signing key with kid; an empty kid matches a sole key.
+func (p *Provider) key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := getJSON(ctx, p.client, p.JWKSURI, &set); err != nil {
+		return nil, err
+	}
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+		if kid != "" && k.Kid != kid {
+			continue
+		}
+		if kid == "" && len(set.Keys) > 1 {
+			break
+		}
+		n, errN := b64.DecodeString(k.N)
+		e, errE := b64.DecodeString(k.E)
+		if errN != nil || errE != nil {
+			break
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	}
+	return nil, fmt.Errorf("%w: unknown signing key", ErrInvalidIDToken)
+}
+
+func getJSON(ctx context.Context, client *http.Client, u string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("oidc: GET %s returned %d", u, resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
+
+func decodeSegment(seg string, v interface{}) error {
+	b, err := b64.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// audiences reads aud, which is a string or an array of strings.
+func audiences(v interface{}) []string {
+	switch a := v.(type) {
+	case string:
+		return []string{a}
+	case []interface{}:
+		out := make([]string, 0, len(a))
+		for _, s := range a {
+			if s, ok := s.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,96 @@
+package oidc_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/url"
+	"testing"
+	"time"
+
+	"troo-backend/internal/pkg/oidc"
+	"troo-backend/internal/pkg/oidc/oidctest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCodeFlowWithPKCE(t *testing.T) {
+	idp := oidctest.NewServer("client-1", "secret-1")
+	defer idp.Close()
+	ctx := context.Background()
+	p, err := oidc.Discover(ctx, nil, idp.Issuer()+"/")
+	require.NoError(t, err)
+
+	verifier := oidc.RandomString(32)
+	authURL := p.AuthCodeURL("client-1", "https://app.example.com/sso/callback", "state-1", "nonce-1", verifier)
+	u, _ := url.Parse(authURL)
+	assert.Equal(t, oidc.Challenge(verifier), u.Query().Get("code_challenge"))
+
+	code, state, err := idp.Authorize(authURL, map[string]interface{}{"sub": "u-1", "email": "jane@acme.com"})
+	require.NoError(t, err)
+	assert.Equal(t, "state-1", state)
+
+	_, err = p.Exchange(ctx, "client-1", "secret-1", "https://app.example.com/sso/callback", code, "wrong-verifier")
+	assert.Error(t, err, "PKCE verifier must match")
+	code, _, _ = idp.Authorize(authURL, map[string]interface{}{"sub": "u-1", "email": "jane@acme.com"})
+	raw, err := p.Exchange(ctx, "client-1", "secret-1", "https://app.example.com/sso/callback", code, verifier)
+	require.NoError(t, err)
+	claims, err := p.Verify(ctx, raw, "client-1", "nonce-1", time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, "u-1", claims.Subject)
+	assert.Equal(t, "jane@acme.com", claims.Email)
+}
+
+func TestVerifyRejects(t *testing.T) {
+	idp := oidctest.NewServer("client-1", "secret-1")
+	defer idp.Close()
+	ctx := context.Background()
+	p, err := oidc.Discover(ctx, nil, idp.Issuer())
+	require.NoError(t, err)
+	good := map[string]interface{}{"sub": "u-1", "nonce": "n"}
+	with := func(k string, v interface{}) map[string]interface{} {
+		c := map[string]interface{}{}
+		for key, val := range good {
+			c[key] = val
+		}
+		c[k] = v
+		return c
+	}
+
+	_, err = p.Verify(ctx, idp.IDToken(good), "client-1", "n", time.Now())
+	require.NoError(t, err)
+
+	cases := map[string]string{
+		"wrong nonce":    idp.IDToken(with("nonce", "other")),
+		"wrong audience": idp.IDToken(with("aud", "client-2")),
+		"multiple aud":   idp.IDToken(with("aud", []string{"client-1", "client-2"})),
+		"wrong issuer":   idp.IDToken(with("iss", "https://evil.example.com")),
+		"expired":        idp.IDToken(with("exp", time.Now().Add(-time.Hour).Unix())),
+		"no subject":     idp.IDToken(with("sub", "")),
+		"tampered":       idp.IDToken(good)[:20] + "x" + idp.IDToken(good)[21:],
+	}
+	for name, raw := range cases {
+		_, err := p.Verify(ctx, raw, "client-1", "n", time.Now())
+		assert.True(t, errors.Is(err, oidc.ErrInvalidIDToken), name)
+	}
+
+	other := oidctest.NewServer("client-1", "secret-1")
+	defer other.Close()
+	_, err = p.Verify(ctx, other.IDToken(with("iss", idp.Issuer())), "client-1", "n", time.Now())
+	assert.True(t, errors.Is(err, oidc.ErrInvalidIDToken), "signed by another key")
+}
+
+func TestNewClient_RefusesNonPublicAddresses(t *testing.T) {
+	idp := oidctest.NewServer("client-1", "secret-1")
+	defer idp.Close()
+	_, err := oidc.Discover(context.Background(), oidc.NewClient(time.Second), idp.Issuer())
+	assert.ErrorIs(t, err, oidc.ErrForbiddenAddress)
+
+	for ip, public := range map[string]bool{
+		"8.8.8.8": true, "2606:4700::1111": true,
+		"127.0.0.1": false, "10.1.2.3": false, "169.254.169.254": false, "100.64.0.1": false, "::1": false, "fe80::1": false,
+	} {
+		assert.Equal(t, public, oidc.PublicIP(net.ParseIP(ip)), ip)
+	}
+}
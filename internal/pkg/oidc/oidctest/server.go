@@ -0,0 +1,153 @@
+// Package oidctest runs a local OpenID Connect provider for tests: discovery, JWKS and a token endpoint
+// that checks the client secret, redirect URI and PKCE verifier. Sign-in is simulated with Authorize.
+package oidctest
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const keyID = "test-key"
+
+var b64 = base64.RawURLEncoding
+
+// Server is a mock identity provider for one client.
+type Server struct {
+	*httptest.Server
+	ClientID     string
+	ClientSecret string
+
+	key   *rsa.PrivateKey
+	mu    sync.Mutex
+	codes map[string]grant
+}
+
+type grant struct {
+	redirectURI string
+	challenge   string
+	nonce       string
+	claims      map[string]interface{}
+}
+
+// NewServer starts a provider; Close it when done.
+func NewServer(clientID, clientSecret string) *Server {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	s := &Server{ClientID: clientID, ClientSecret: clientSecret, key: key, codes: map[string]grant{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", s.discovery)
+	mux.HandleFunc("/jwks", s.jwks)
+	mux.HandleFunc("/token", s.token)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// Issuer is the provider's issuer URL.
+func (s *Server) Issuer() string {
+	return s.URL
+}
+
+// Authorize stands in for the user signing in at authURL: it checks the request and returns the code and
+// state the provider would redirect back with. claims become the ID token's (sub, email, ...).
+func (s *Server) Authorize(authURL string, claims map[string]interface{}) (code, state string, err error) {
+	u, err := url.Parse(authURL)
+	if err != nil {
+		return "", "", err
+	}
+	q := u.Query()
+	if q.Get("client_id") != s.ClientID || q.Get("response_type") != "code" {
+		return "", "", errors.New("oidctest: bad authorization request")
+	}
+	if q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" {
+		return "", "", errors.New("oidctest: PKCE is required")
+	}
+	b := make([]byte, 16)
+	rand.Read(b)
+	code = b64.EncodeToString(b)
+	s.mu.Lock()
+	s.codes[code] = grant{redirectURI: q.Get("redirect_uri"), challenge: q.Get("code_challenge"), nonce: q.Get("nonce"), claims: claims}
+	s.mu.Unlock()
+	return code, q.Get("state"), nil
+}
+
+// IDToken signs claims, filling in iss, aud, iat and exp unless set.
+func (s *Server) IDToken(claims map[string]interface{}) string {
+	c := map[string]interface{}{
+		"iss": s.Issuer(),
+		"aud": s.ClientID,
+		"iat": time.Now().Unix(),
+		"exp": time.Now().Add(5 * time.Minute).Unix(),
+	}
+	for k, v := range claims {
+		c[k] = v
+	}
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": keyID})
+	payload, _ := json.Marshal(c)
+	signing := b64.EncodeToString(header) + "." + b64.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signing))
+	sig, _ := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, digest[:])
+	return signing + "." + b64.EncodeToString(sig)
+}
+
+func (s *Server) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{
+		"issuer":                 s.Issuer(),
+		"authorization_endpoint": s.URL + "/authorize",
+		"token_endpoint":         s.URL + "/token",
+		"jwks_uri":               s.URL + "/jwks",
+	})
+}
+
+func (s *Server) jwks(w http.ResponseWriter, r *http.Request) {
+	pub := s.key.PublicKey
+	writeJSON(w, http.StatusOK, map[string]interface{}{"keys": []map[string]string{{
+		"kty": "RSA", "use": "sig", "alg": "RS256", "kid": keyID,
+		"n": b64.EncodeToString(pub.N.Bytes()),
+		"e": b64.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+	}}})
+}
+
+func (s *Server) token(w http.ResponseWriter, r *http.Request) {
+	id, secret, ok := r.BasicAuth()
+	id, _ = url.QueryUnescape(id)
+	secret, _ = url.QueryUnescape(secret)
+	if !ok || id != s.ClientID || secret != s.ClientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+	code := r.PostFormValue("code")
+	s.mu.Lock()
+	g, found := s.codes[code]
+	delete(s.codes, code)
+	s.mu.Unlock()
+	sum := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+	if !found || r.PostFormValue("grant_type") != "authorization_code" ||
+		r.PostFormValue("redirect_uri") != g.redirectURI || b64.EncodeToString(sum[:]) != g.challenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+	claims := map[string]interface{}{"nonce": g.nonce}
+	for k, v := range g.claims {
+		claims[k] = v
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"token_type": "Bearer", "access_token": "unused", "id_token": s.IDToken(claims)})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
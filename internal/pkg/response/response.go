@@ -54,6 +54,19 @@ func SuccessCreated(c *fiber.Ctx, message string, data interface{}, metadata int
 	})
 }
 
+// SuccessAccepted sends a 202 Accepted response with the standard success format (request queued, not yet executed).
+func SuccessAccepted(c *fiber.Ctx, message string, data interface{}, metadata interface{}) error {
+	if metadata == nil {
+		metadata = map[string]interface{}{}
+	}
+	return c.Status(fiber.StatusAccepted).JSON(SuccessBody{
+		Status:   statusSuccess,
+		Message:  message,
+		Data:     data,
+		Metadata: metadata,
+	})
+}
+
 // Error sends a response with the standard error format.
 func Error(c *fiber.Ctx, message string, statusCode int, details interface{}) error {
 	if details == nil {
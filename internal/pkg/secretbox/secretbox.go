@@ -0,0 +1,72 @@
+// Package secretbox encrypts small secrets, such as TOTP seeds, for storage with AES-256-GCM under a key
+// from the environment, so a database dump alone does not reveal them.
+package secretbox
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+// prefix marks sealed values; stored values without it predate encryption and are plaintext.
+const prefix = "v1:"
+
+// ErrInvalidKey is returned by New for a key that is not base64 of 32 bytes.
+var ErrInvalidKey = errors.New("secretbox: key must be 32 bytes, base64 encoded")
+
+// Box seals and opens values under one key.
+type Box struct {
+	aead cipher.AEAD
+}
+
+// New returns a Box for key, the base64 (standard encoding) of 32 random bytes, e.g. `openssl rand -base64 32`.
+func New(key string) (*Box, error) {
+	k, err := base64.StdEncoding.DecodeString(strings.TrimSpace(key))
+	if err != nil || len(k) != 32 {
+		return nil, ErrInvalidKey
+	}
+	block, err := aes.NewCipher(k)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Box{aead: aead}, nil
+}
+
+// Seal encrypts plaintext with a random nonce.
+func (b *Box) Seal(plaintext string) (string, error) {
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := b.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return prefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts a value from Seal. A value stored before encryption was introduced is returned as it is.
+func (b *Box) Open(stored string) (string, error) {
+	if !IsSealed(stored) {
+		return stored, nil
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(stored, prefix))
+	if err != nil || len(raw) < b.aead.NonceSize() {
+		return "", errors.New("secretbox: malformed value")
+	}
+	n := b.aead.NonceSize()
+	plain, err := b.aead.Open(nil, raw[:n], raw[n:], nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
+
+// IsSealed reports whether stored was produced by Seal.
+func IsSealed(stored string) bool {
+	return strings.HasPrefix(stored, prefix)
+}
@@ -0,0 +1,49 @@
+package secretbox
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newKey(t *testing.T) string {
+	k := make([]byte, 32)
+	_, err := rand.Read(k)
+	require.NoError(t, err)
+	return base64.StdEncoding.EncodeToString(k)
+}
+
+func TestSealOpen(t *testing.T) {
+	b, err := New(newKey(t))
+	require.NoError(t, err)
+
+	sealed, err := b.Seal("JBSWY3DPEHPK3PXP")
+	require.NoError(t, err)
+	assert.True(t, IsSealed(sealed))
+	assert.NotContains(t, sealed, "JBSWY3DPEHPK3PXP")
+	again, _ := b.Seal("JBSWY3DPEHPK3PXP")
+	assert.NotEqual(t, sealed, again, "random nonce")
+
+	plain, err := b.Open(sealed)
+	require.NoError(t, err)
+	assert.Equal(t, "JBSWY3DPEHPK3PXP", plain)
+
+	// Values stored before encryption pass through.
+	plain, err = b.Open("JBSWY3DPEHPK3PXP")
+	require.NoError(t, err)
+	assert.Equal(t, "JBSWY3DPEHPK3PXP", plain)
+
+	other, _ := New(newKey(t))
+	_, err = other.Open(sealed)
+	assert.Error(t, err)
+}
+
+func TestNew_RejectsBadKeys(t *testing.T) {
+	for _, key := range []string{"", "not base64!", base64.StdEncoding.EncodeToString([]byte("short"))} {
+		_, err := New(key)
+		assert.ErrorIs(t, err, ErrInvalidKey, key)
+	}
+}
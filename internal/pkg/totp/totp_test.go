@@ -0,0 +1,34 @@
+package totp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// RFC 6238 appendix B vectors for the SHA-1 key "12345678901234567890", truncated to 6 digits.
+func TestCodeAt_RFC6238Vectors(t *testing.T) {
+	secret := b32.EncodeToString([]byte("12345678901234567890"))
+	for unix, want := range map[int64]string{59: "287082", 1111111109: "081804", 1234567890: "005924", 2000000000: "279037"} {
+		got, err := CodeAt(secret, Step(time.Unix(unix, 0)))
+		require.NoError(t, err)
+		assert.Equal(t, want, got, unix)
+	}
+}
+
+func TestValidate_AcceptsOneStepOfDrift(t *testing.T) {
+	secret := GenerateSecret()
+	now := time.Now()
+	prev, _ := CodeAt(secret, Step(now)-1)
+	step, ok := Validate(secret, prev, now)
+	assert.True(t, ok)
+	assert.Equal(t, Step(now)-1, step)
+
+	old, _ := CodeAt(secret, Step(now)-3)
+	_, ok = Validate(secret, old, now)
+	assert.False(t, ok)
+	_, ok = Validate(secret, "12345", now)
+	assert.False(t, ok)
+}